@@ -0,0 +1,425 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Azure/go-amqp/internal/debug"
+	"github.com/Azure/go-amqp/internal/encoding"
+	"github.com/Azure/go-amqp/internal/frames"
+)
+
+// SessionRequest is a session the peer has asked to begin on a
+// server-side connection. It must be accepted or rejected promptly
+// as the connection doesn't process incoming frames until then.
+type SessionRequest struct {
+	conn          *Conn
+	remoteChannel uint16
+	begin         *frames.PerformBegin
+
+	// resp is sent the session once the request has been decided,
+	// or nil if a channel couldn't be allocated.
+	resp chan *Session
+	once sync.Once
+}
+
+// NextSession waits for the peer to begin a session.
+//
+// It's only supported on connections returned by Listener.Accept.
+func (c *Conn) NextSession(ctx context.Context) (_ *SessionRequest, err error) {
+	defer func() { err = c.translateErr(err) }()
+
+	if c.sessionReqs == nil {
+		return nil, errors.New("amqp: peer-initiated sessions are only supported on server-side connections")
+	}
+	select {
+	case req := <-c.sessionReqs:
+		return req, nil
+	case <-c.done:
+		return nil, c.doneErr
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// requestSession hands the peer's begin to NextSession and waits for it to be
+// decided. It returns false if the connection is closing.
+// It's called by connReader.
+func (c *Conn) requestSession(remoteChannel uint16, begin *frames.PerformBegin) (*Session, bool) {
+	req := &SessionRequest{
+		conn:          c,
+		remoteChannel: remoteChannel,
+		begin:         begin,
+		resp:          make(chan *Session, 1),
+	}
+	select {
+	case c.sessionReqs <- req:
+	case <-c.rxtxExit:
+		return nil, false
+	}
+	select {
+	case s := <-req.resp:
+		return s, true
+	case <-c.rxtxExit:
+		return nil, false
+	}
+}
+
+// Accept begins the session.
+//
+// opts: pass nil to accept the default values.
+func (r *SessionRequest) Accept(opts *SessionOptions) (_ *Session, err error) {
+	defer func() { err = r.conn.translateErr(err) }()
+
+	err = errors.New("amqp: session request already accepted or rejected")
+	var s *Session
+	r.once.Do(func() {
+		s, err = r.accept(opts)
+	})
+	return s, err
+}
+
+// Reject begins the session and immediately ends it with e, as
+// required by the protocol. It doesn't wait for the peer to
+// acknowledge the end.
+func (r *SessionRequest) Reject(e *Error) (err error) {
+	defer func() { err = r.conn.translateErr(err) }()
+
+	err = errors.New("amqp: session request already accepted or rejected")
+	r.once.Do(func() {
+		var s *Session
+		if s, err = r.accept(nil); err != nil {
+			return
+		}
+		s.endError = e
+		s.closeOnce.Do(func() { close(s.close) })
+	})
+	return err
+}
+
+func (r *SessionRequest) accept(opts *SessionOptions) (*Session, error) {
+	s, err := r.conn.newSession(opts)
+	if err != nil {
+		// the peer exceeded the negotiated channel-max; connReader terminates the connection
+		r.resp <- nil
+		return nil, err
+	}
+	s.remoteChannel = r.remoteChannel
+
+	begin := s.beginFrame()
+	begin.RemoteChannel = &r.remoteChannel
+	debug.Log(1, "TX (SessionRequest.Accept): %s", begin)
+	if err := s.txFrame(begin, nil); err != nil {
+		r.conn.deleteSession(s)
+		r.resp <- nil
+		return nil, err
+	}
+
+	r.conn.logger.Debug("session begun", "channel", s.channel, "remoteChannel", s.remoteChannel)
+	go s.mux(r.begin)
+	r.resp <- s
+	return s, nil
+}
+
+// LinkRequest is a link the peer has asked to attach to a session on
+// a server-side connection. It must be accepted or rejected promptly
+// as the session doesn't process incoming frames until then.
+type LinkRequest struct {
+	// Name is the name of the link.
+	Name string
+
+	// Receiver is true when the peer attached as a sender, in which case
+	// the request must be accepted with AcceptReceiver. Otherwise it must
+	// be accepted with AcceptSender.
+	Receiver bool
+
+	// SourceAddress is the address of the peer's requested source.
+	SourceAddress string
+
+	// TargetAddress is the address of the peer's requested target.
+	TargetAddress string
+
+	// DynamicAddress is true when the peer asked for the node to be created
+	// dynamically. The address of the node is then provided with
+	// SenderOptions.SourceAddress or ReceiverOptions.TargetAddress.
+	DynamicAddress bool
+
+	// SenderSettleMode is the sender settlement mode requested by the peer.
+	SenderSettleMode *SenderSettleMode
+
+	// ReceiverSettleMode is the receiver settlement mode requested by the peer.
+	ReceiverSettleMode *ReceiverSettleMode
+
+	// Properties contains the link properties sent by the peer.
+	Properties map[string]any
+
+	// OfferedCapabilities contains the link capabilities offered by the peer.
+	OfferedCapabilities []string
+
+	// DesiredCapabilities contains the link capabilities desired by the peer.
+	DesiredCapabilities []string
+
+	// Coordinator is true when the peer attached as a transaction controller,
+	// in which case the request must be accepted with AcceptCoordinator.
+	Coordinator bool
+
+	session *Session
+	attach  *frames.PerformAttach
+
+	// resp is sent the link once the request has been decided,
+	// or nil if the attach couldn't be answered.
+	resp chan *link
+	once sync.Once
+}
+
+// NextLink waits for the peer to attach a link to the session.
+//
+// It's only supported on sessions of connections returned by Listener.Accept.
+func (s *Session) NextLink(ctx context.Context) (_ *LinkRequest, err error) {
+	defer func() { err = s.conn.translateErr(err) }()
+
+	if s.linkReqs == nil {
+		return nil, errors.New("amqp: peer-initiated links are only supported on server-side connections")
+	}
+	select {
+	case req := <-s.linkReqs:
+		return req, nil
+	case <-s.done:
+		return nil, s.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// SourceFilterValue retrieves the value of the specified filter from the
+// source requested by the peer. It returns nil if the filter wasn't set.
+func (r *LinkRequest) SourceFilterValue(name string) any {
+	if r.attach.Source == nil {
+		return nil
+	}
+	filter, ok := r.attach.Source.Filter[encoding.Symbol(name)]
+	if !ok {
+		return nil
+	}
+	return filter.Value
+}
+
+// muxRequestLink hands the peer's attach to NextLink and waits for it to be
+// decided. It returns false if the connection has terminated.
+// It's called by the session mux.
+func (s *Session) muxRequestLink(attach *frames.PerformAttach) (*link, bool) {
+	req := &LinkRequest{
+		Name:               attach.Name,
+		Receiver:           attach.Role == encoding.RoleSender,
+		SenderSettleMode:   attach.SenderSettleMode,
+		ReceiverSettleMode: attach.ReceiverSettleMode,
+		session:            s,
+		attach:             attach,
+		resp:               make(chan *link, 1),
+	}
+	if attach.Source != nil {
+		req.SourceAddress = attach.Source.Address
+		if !req.Receiver {
+			req.DynamicAddress = attach.Source.Dynamic
+		}
+	}
+	if attach.Coordinator != nil {
+		req.Coordinator = true
+	}
+	if attach.Target != nil {
+		req.TargetAddress = attach.Target.Address
+		if req.Receiver {
+			req.DynamicAddress = attach.Target.Dynamic
+		}
+	}
+	req.OfferedCapabilities = symbolStrings(attach.OfferedCapabilities)
+	req.DesiredCapabilities = symbolStrings(attach.DesiredCapabilities)
+	if attach.Properties != nil {
+		req.Properties = make(map[string]any, len(attach.Properties))
+		for k, v := range attach.Properties {
+			req.Properties[string(k)] = v
+		}
+	}
+
+	select {
+	case s.linkReqs <- req:
+	case <-s.close:
+		// the session is ending, the attach is moot
+		return nil, true
+	case <-s.conn.done:
+		return nil, false
+	}
+	select {
+	case l := <-req.resp:
+		return l, true
+	case <-s.conn.done:
+		return nil, false
+	}
+}
+
+// AcceptSender attaches the link as a Sender. The request's Receiver field must be false.
+//
+// The target is the one requested by the peer. SettlementMode overrides the sender
+// settlement mode requested by the peer, and SourceAddress overrides the requested
+// source address. Name, RequestedReceiverSettleMode, and the Target* options are
+// ignored. If an error is returned, the request is rejected.
+//
+// opts: pass nil to accept the default values.
+func (r *LinkRequest) AcceptSender(opts *SenderOptions) (_ *Sender, err error) {
+	defer func() { err = r.session.conn.translateErr(err) }()
+
+	err = errors.New("amqp: link request already accepted or rejected")
+	var snd *Sender
+	r.once.Do(func() {
+		if snd, err = r.acceptSender(opts); err != nil {
+			r.reject(&Error{Condition: ErrCondNotAllowed, Description: err.Error()})
+		}
+	})
+	return snd, err
+}
+
+func (r *LinkRequest) acceptSender(opts *SenderOptions) (*Sender, error) {
+	if r.Receiver {
+		return nil, errors.New("amqp: the peer attached as a sender, use AcceptReceiver")
+	}
+	s, err := newSender(r.TargetAddress, r.session, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.l.key.name = r.Name
+	if r.attach.Target != nil {
+		s.l.target = r.attach.Target
+	}
+	if s.l.source.Address == "" {
+		s.l.source.Address = r.SourceAddress
+	}
+	s.l.dynamicAddr = false
+	if s.l.senderSettleMode == nil {
+		s.l.senderSettleMode = r.SenderSettleMode
+	}
+	s.l.receiverSettleMode = r.ReceiverSettleMode
+
+	// see Sender.attach
+	if senderSettleModeValue(s.l.senderSettleMode) != SenderSettleModeSettled && receiverSettleModeValue(s.l.receiverSettleMode) == ReceiverSettleModeSecond {
+		return nil, errors.New("sender does not support exactly-once guarantee")
+	}
+
+	s.l.rx = make(chan frames.FrameBody, 1)
+	if err := s.l.acceptAttach(r.attach, func(pa *frames.PerformAttach) {
+		pa.Role = encoding.RoleSender
+	}); err != nil {
+		return nil, err
+	}
+
+	s.transfers = make(chan frames.PerformTransfer)
+	go s.mux()
+	r.resp <- &s.l
+	return s, nil
+}
+
+// AcceptReceiver attaches the link as a Receiver. The request's Receiver field must be true.
+//
+// The source, including any filters, is the one requested by the peer. SettlementMode
+// overrides the receiver settlement mode requested by the peer, and TargetAddress overrides
+// the requested target address. Name, RequestedSenderSettleMode, Filters, and the Sender*
+// options are ignored. If an error is returned, the request is rejected.
+//
+// opts: pass nil to accept the default values.
+func (r *LinkRequest) AcceptReceiver(opts *ReceiverOptions) (_ *Receiver, err error) {
+	defer func() { err = r.session.conn.translateErr(err) }()
+
+	err = errors.New("amqp: link request already accepted or rejected")
+	var rcv *Receiver
+	r.once.Do(func() {
+		if rcv, err = r.acceptReceiver(opts, false); err != nil {
+			r.reject(&Error{Condition: ErrCondNotAllowed, Description: err.Error()})
+		}
+	})
+	return rcv, err
+}
+
+func (r *LinkRequest) acceptReceiver(opts *ReceiverOptions, coordinator bool) (*Receiver, error) {
+	if !r.Receiver {
+		return nil, errors.New("amqp: the peer attached as a receiver, use AcceptSender")
+	}
+	if r.Coordinator && !coordinator {
+		return nil, errors.New("amqp: the peer attached as a transaction controller, use AcceptCoordinator")
+	}
+	rcv, err := newReceiver(r.SourceAddress, r.session, opts)
+	if err != nil {
+		return nil, err
+	}
+	rcv.l.key.name = r.Name
+	if r.attach.Source != nil {
+		rcv.l.source = r.attach.Source
+	}
+	if rcv.l.target.Address == "" {
+		rcv.l.target.Address = r.TargetAddress
+	}
+	rcv.l.dynamicAddr = false
+	if rcv.l.receiverSettleMode == nil {
+		rcv.l.receiverSettleMode = r.ReceiverSettleMode
+	}
+	rcv.l.senderSettleMode = r.SenderSettleMode
+
+	rcv.prepareAttach()
+	// deliveryCount is a sequence number, must initialize to sender's initial sequence number
+	rcv.l.deliveryCount = r.attach.InitialDeliveryCount
+	rcv.messages = newPrefetchQueue(rcv.maxCredit)
+	rcv.charges.budget = r.session.budget
+	if err := rcv.l.acceptAttach(r.attach, func(pa *frames.PerformAttach) {
+		pa.Role = encoding.RoleReceiver
+		if coordinator {
+			pa.Target = nil
+			pa.Coordinator = r.attach.Coordinator
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	go rcv.mux()
+	rcv.startBatching()
+	r.resp <- &rcv.l
+	return rcv, nil
+}
+
+// Reject refuses the link with e. As required by the protocol, the link is
+// attached without a terminus and then detached with e. It doesn't wait
+// for the peer to acknowledge the detach.
+func (r *LinkRequest) Reject(e *Error) (err error) {
+	defer func() { err = r.session.conn.translateErr(err) }()
+
+	err = errors.New("amqp: link request already accepted or rejected")
+	r.once.Do(func() {
+		err = r.reject(e)
+	})
+	return err
+}
+
+func (r *LinkRequest) reject(e *Error) error {
+	l := &link{
+		key:         linkKey{name: r.Name, role: !r.attach.Role},
+		session:     r.session,
+		rx:          make(chan frames.FrameBody, 1),
+		close:       make(chan struct{}),
+		detached:    make(chan struct{}),
+		debugReq:    make(chan chan string),
+		detachError: e,
+	}
+	if err := l.acceptAttach(r.attach, func(pa *frames.PerformAttach) {
+		pa.Role = l.key.role
+		// no terminus signals the peer that a detach follows
+		pa.Source = nil
+		pa.Target = nil
+		pa.Coordinator = nil
+	}); err != nil {
+		r.resp <- nil
+		return fmt.Errorf("amqp: rejecting link %q: %w", r.Name, err)
+	}
+
+	r.resp <- l
+	go l.muxDetach(context.Background(), nil, nil)
+	return nil
+}
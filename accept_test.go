@@ -0,0 +1,272 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServerConn returns a client connection and the server-side
+// connection it's connected to.
+func newTestServerConn(t *testing.T) (client, server *Conn) {
+	l := newTestListener(t, nil)
+
+	client, err := Dial("amqp://"+l.Addr().String(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	server, err = l.Accept(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+	return client, server
+}
+
+// acceptTestSession begins a session from client and accepts it on server.
+func acceptTestSession(t *testing.T, client, server *Conn) (clientSession, serverSession *Session) {
+	type result struct {
+		s   *Session
+		err error
+	}
+	accepted := make(chan result, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, err := server.NextSession(ctx)
+		if err != nil {
+			accepted <- result{err: err}
+			return
+		}
+		s, err := req.Accept(nil)
+		accepted <- result{s: s, err: err}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	clientSession, err := client.NewSession(ctx, nil)
+	require.NoError(t, err)
+	res := <-accepted
+	require.NoError(t, res.err)
+	return clientSession, res.s
+}
+
+func TestAcceptLinks(t *testing.T) {
+	client, server := newTestServerConn(t)
+	clientSession, serverSession := acceptTestSession(t, client, server)
+
+	type result struct {
+		snd *Sender
+		rcv *Receiver
+		err error
+	}
+	accepted := make(chan result, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			req, err := serverSession.NextLink(ctx)
+			cancel()
+			if err != nil {
+				accepted <- result{err: err}
+				return
+			}
+			var res result
+			if req.Receiver {
+				if req.TargetAddress != "in" || req.Properties["key"] != "value" {
+					res.err = errors.New("unexpected receiver request")
+				} else {
+					res.rcv, res.err = req.AcceptReceiver(&ReceiverOptions{Credit: 10})
+				}
+			} else if req.SourceFilterValue(selectorFilter) != "color = 'red'" {
+				res.err = errors.New("unexpected sender request")
+			} else {
+				res.snd, res.err = req.AcceptSender(&SenderOptions{SettlementMode: SenderSettleModeSettled.Ptr()})
+			}
+			accepted <- res
+		}
+	}()
+
+	// client to server
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	clientSnd, err := clientSession.NewSender(ctx, "in", &SenderOptions{
+		Properties: map[string]any{"key": "value"},
+	})
+	cancel()
+	require.NoError(t, err)
+	res := <-accepted
+	require.NoError(t, res.err)
+	serverRcv := res.rcv
+	require.Equal(t, clientSnd.LinkName(), serverRcv.LinkName())
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	sendErr := make(chan error, 1)
+	go func() { sendErr <- clientSnd.Send(ctx, NewMessage([]byte("ping"))) }()
+	msg, err := serverRcv.Receive(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(msg.GetData()))
+	require.NoError(t, serverRcv.AcceptMessage(ctx, msg))
+	require.NoError(t, <-sendErr)
+	cancel()
+
+	// server to client
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	clientRcv, err := clientSession.NewReceiver(ctx, "out", &ReceiverOptions{
+		SettlementMode: ReceiverSettleModeFirst.Ptr(),
+		Filters:        []LinkFilter{NewSelectorFilter("color = 'red'")},
+	})
+	cancel()
+	require.NoError(t, err)
+	res = <-accepted
+	require.NoError(t, res.err)
+	serverSnd := res.snd
+	require.Equal(t, "out", serverSnd.l.source.Address)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	require.NoError(t, serverSnd.Send(ctx, NewMessage([]byte("pong"))))
+	msg, err = clientRcv.Receive(ctx)
+	cancel()
+	require.NoError(t, err)
+	require.Equal(t, "pong", string(msg.GetData()))
+
+	// closing the client link detaches the server link
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	require.NoError(t, clientSnd.Close(ctx))
+	_, err = serverRcv.Receive(ctx)
+	cancel()
+	var linkErr *DetachError
+	require.ErrorAs(t, err, &linkErr)
+}
+
+func TestLinkCapabilities(t *testing.T) {
+	client, server := newTestServerConn(t)
+	clientSession, serverSession := acceptTestSession(t, client, server)
+
+	type result struct {
+		req *LinkRequest
+		rcv *Receiver
+		err error
+	}
+	accepted := make(chan result, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, err := serverSession.NextLink(ctx)
+		if err != nil {
+			accepted <- result{err: err}
+			return
+		}
+		rcv, err := req.AcceptReceiver(&ReceiverOptions{
+			OfferedCapabilities: []string{"SHARED-SUBS"},
+			Properties:          map[string]any{"com.microsoft:epoch": int64(2)},
+		})
+		accepted <- result{req: req, rcv: rcv, err: err}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	snd, err := clientSession.NewSender(ctx, "in", &SenderOptions{
+		DesiredCapabilities: []string{"SHARED-SUBS", "DELAYED_DELIVERY"},
+		OfferedCapabilities: []string{"ANONYMOUS-RELAY"},
+	})
+	require.NoError(t, err)
+	res := <-accepted
+	require.NoError(t, res.err)
+
+	// the server got the client's capabilities
+	require.Equal(t, []string{"ANONYMOUS-RELAY"}, res.req.OfferedCapabilities)
+	require.Equal(t, []string{"SHARED-SUBS", "DELAYED_DELIVERY"}, res.req.DesiredCapabilities)
+	require.Equal(t, []string{"ANONYMOUS-RELAY"}, res.rcv.RemoteOfferedCapabilities())
+	require.Nil(t, res.rcv.RemoteProperties())
+
+	// the client got the server's
+	require.Equal(t, []string{"SHARED-SUBS"}, snd.RemoteOfferedCapabilities())
+	require.Nil(t, snd.RemoteDesiredCapabilities())
+	require.Equal(t, map[string]any{"com.microsoft:epoch": int64(2)}, snd.RemoteProperties())
+}
+
+func TestRejectLink(t *testing.T) {
+	client, server := newTestServerConn(t)
+	clientSession, serverSession := acceptTestSession(t, client, server)
+
+	rejected := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, err := serverSession.NextLink(ctx)
+		if err != nil {
+			rejected <- err
+			return
+		}
+		// accepting with the wrong role rejects the link
+		_, err = req.AcceptSender(nil)
+		if err == nil {
+			rejected <- errors.New("expected error")
+			return
+		}
+		rejected <- req.Reject(&Error{Condition: ErrCondNotFound})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	_, err := clientSession.NewSender(ctx, "missing", nil)
+	cancel()
+	var amqpErr *Error
+	require.ErrorAs(t, err, &amqpErr)
+	require.Equal(t, ErrCondNotAllowed, amqpErr.Condition)
+	require.ErrorContains(t, <-rejected, "already accepted or rejected")
+
+	// the session remains usable
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, err := serverSession.NextLink(ctx)
+		if err != nil {
+			rejected <- err
+			return
+		}
+		rejected <- req.Reject(&Error{Condition: ErrCondNotFound, Description: "no such node"})
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	_, err = clientSession.NewReceiver(ctx, "missing", nil)
+	cancel()
+	require.ErrorAs(t, err, &amqpErr)
+	require.Equal(t, ErrCondNotFound, amqpErr.Condition)
+	require.NoError(t, <-rejected)
+}
+
+func TestRejectSession(t *testing.T) {
+	client, server := newTestServerConn(t)
+
+	rejected := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, err := server.NextSession(ctx)
+		if err != nil {
+			rejected <- err
+			return
+		}
+		rejected <- req.Reject(&Error{Condition: ErrCondResourceLimitExceeded})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	session, err := client.NewSession(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, <-rejected)
+
+	// the session has been ended by the server
+	_, err = session.NewSender(ctx, "target", nil)
+	var sessionErr *SessionError
+	require.ErrorAs(t, err, &sessionErr)
+	require.NotNil(t, sessionErr.RemoteErr)
+	require.Equal(t, ErrCondResourceLimitExceeded, sessionErr.RemoteErr.Condition)
+}
+
+func TestNextSessionClientConn(t *testing.T) {
+	client, _ := newTestServerConn(t)
+	_, err := client.NextSession(context.Background())
+	require.Error(t, err)
+}
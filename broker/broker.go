@@ -0,0 +1,478 @@
+// Package broker implements in-memory AMQP nodes on top of amqp.Listener,
+// allowing a lightweight broker to be embedded in applications for local
+// testing and edge deployments.
+//
+// A Queue delivers each message to one consumer, while a Topic delivers
+// each message to every consumer subscribed to its subject.
+//
+// Queued messages are persisted through a Store, which keeps them in memory
+// by default. Plug in a durable Store to keep queues across restarts.
+//
+// Clients can declare local transactions with the broker's transaction
+// coordinator. Messages published and deliveries settled in a transaction
+// only take effect once it commits.
+//
+// Queues and topics can be managed by clients through the management node
+// at ManagementAddress.
+//
+// For testing, Server serves a broker on a local port, and Options.FaultHook
+// injects delays, rejections, lost messages, detaches and disconnects at the
+// points reached by links, as scripted by a Script.
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+// Default broker options
+const (
+	defaultCredit = 100
+)
+
+// detachTimeout bounds the wait for the peer to acknowledge
+// the detach of a link whose node was deleted.
+const detachTimeout = 5 * time.Second
+
+// Options contains the optional settings for configuring a Broker.
+type Options struct {
+	// AutoCreateQueues creates a queue when a link attaches to an
+	// address that doesn't exist. Otherwise, the link is rejected
+	// with amqp:not-found.
+	//
+	// Default: false.
+	AutoCreateQueues bool
+
+	// Credit is the link credit granted to producers.
+	//
+	// Default: 100.
+	Credit uint32
+
+	// Store persists the messages of queues. Queues recover the
+	// unsettled messages in Store when they are declared.
+	//
+	// Default: NewMemoryStore().
+	Store Store
+
+	// FaultHook is called at each FaultPoint reached while handling the links
+	// attached to queues and topics, and returns the fault to inject there.
+	// Use it to test how applications handle broker failures. Script
+	// implements a FaultHook injecting faults according to rules.
+	//
+	// Default: nil.
+	FaultHook func(FaultEvent) Fault
+}
+
+// Broker routes links attached by clients to nodes by address.
+type Broker struct {
+	autoCreate bool
+	credit     uint32
+	store      Store
+	txns       *transactions
+	faultHook  func(FaultEvent) Fault
+
+	mu      sync.Mutex
+	queues  map[string]*Queue
+	topics  map[string]*Topic
+	replies map[string]*amqp.Sender // management response links by target address
+}
+
+// New creates a Broker without any nodes.
+//
+// opts: pass nil to accept the default values.
+func New(opts *Options) *Broker {
+	b := &Broker{
+		credit:  defaultCredit,
+		store:   NewMemoryStore(),
+		txns:    newTransactions(),
+		queues:  map[string]*Queue{},
+		topics:  map[string]*Topic{},
+		replies: map[string]*amqp.Sender{},
+	}
+	if opts == nil {
+		return b
+	}
+	b.autoCreate = opts.AutoCreateQueues
+	if opts.Credit > 0 {
+		b.credit = opts.Credit
+	}
+	if opts.Store != nil {
+		b.store = opts.Store
+	}
+	b.faultHook = opts.FaultHook
+	return b
+}
+
+// DeclareQueue returns the queue with the specified name,
+// creating it if it doesn't exist.
+//
+// A created queue recovers its unsettled messages from the broker's Store.
+// It returns the error from Store.Recover, in which case the queue isn't created.
+func (b *Broker) DeclareQueue(name string) (*Queue, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		q = newQueue(name, b.store)
+		if err := q.recover(); err != nil {
+			return nil, err
+		}
+		b.queues[name] = q
+	}
+	return q, nil
+}
+
+// Queue returns the queue with the specified name, or nil if it doesn't exist.
+func (b *Broker) Queue(name string) *Queue {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.queues[name]
+}
+
+// DeleteQueue deletes the queue with the specified name, discarding its
+// messages and detaching the links attached to it. It returns false if
+// the queue doesn't exist.
+func (b *Broker) DeleteQueue(name string) bool {
+	b.mu.Lock()
+	q, ok := b.queues[name]
+	delete(b.queues, name)
+	b.mu.Unlock()
+	if !ok {
+		return false
+	}
+	q.delete()
+	return true
+}
+
+// DeclareTopic returns the topic with the specified name,
+// creating it if it doesn't exist.
+//
+// Queues and topics share the address space. Links attached to an
+// address used by both a queue and a topic are attached to the queue.
+func (b *Broker) DeclareTopic(name string) *Topic {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t, ok := b.topics[name]
+	if !ok {
+		t = newTopic(name)
+		b.topics[name] = t
+	}
+	return t
+}
+
+// Topic returns the topic with the specified name, or nil if it doesn't exist.
+func (b *Broker) Topic(name string) *Topic {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.topics[name]
+}
+
+// DeleteTopic deletes the topic with the specified name, detaching the
+// links attached to it. It returns false if the topic doesn't exist.
+func (b *Broker) DeleteTopic(name string) bool {
+	b.mu.Lock()
+	t, ok := b.topics[name]
+	delete(b.topics, name)
+	b.mu.Unlock()
+	if !ok {
+		return false
+	}
+	close(t.deleted)
+	return true
+}
+
+// Serve accepts connections from l and serves them until ctx completes
+// or l is closed. It returns the error from amqp.Listener.Accept.
+// Served connections are closed when ctx completes.
+func (b *Broker) Serve(ctx context.Context, l *amqp.Listener) error {
+	for {
+		conn, err := l.Accept(ctx)
+		if err != nil {
+			return err
+		}
+		go b.ServeConn(ctx, conn)
+	}
+}
+
+// ServeConn accepts the sessions and links begun by the peer on conn until
+// ctx completes or conn is closed. conn is closed when ctx completes.
+func (b *Broker) ServeConn(ctx context.Context, conn *amqp.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	for {
+		req, err := conn.NextSession(ctx)
+		if err != nil {
+			return
+		}
+		session, err := req.Accept(nil)
+		if err != nil {
+			return
+		}
+		go b.serveSession(ctx, conn, session)
+	}
+}
+
+// serveSession accepts the links attached by the peer to session.
+func (b *Broker) serveSession(ctx context.Context, conn *amqp.Conn, session *amqp.Session) {
+	for {
+		req, err := session.NextLink(ctx)
+		if err != nil {
+			return
+		}
+		b.attach(ctx, conn, req)
+	}
+}
+
+// attach accepts or rejects req depending on whether its node exists.
+func (b *Broker) attach(ctx context.Context, conn *amqp.Conn, req *amqp.LinkRequest) {
+	if req.Coordinator {
+		c, err := req.AcceptCoordinator(&amqp.ReceiverOptions{Credit: b.credit})
+		if err == nil {
+			go func() { _ = c.Serve(ctx, b.txns) }()
+		}
+		return
+	}
+	if req.DynamicAddress {
+		_ = req.Reject(&amqp.Error{
+			Condition:   amqp.ErrCondNotImplemented,
+			Description: "dynamic nodes are not supported",
+		})
+		return
+	}
+
+	address := req.SourceAddress
+	if req.Receiver {
+		address = req.TargetAddress
+	}
+	if address == ManagementAddress {
+		b.attachManagement(ctx, req)
+		return
+	}
+
+	in := b.newInjector(conn, address, req.Name)
+	fault := in.inject(ctx, FaultPointAttach, nil)
+	if fault.CloseConn {
+		return
+	}
+	if fault.Error != nil {
+		_ = req.Reject(fault.Error)
+		return
+	}
+	q := b.Queue(address)
+	if q == nil {
+		if t := b.Topic(address); t != nil {
+			b.attachTopic(ctx, req, t, in, fault)
+			return
+		}
+	}
+	if q == nil && b.autoCreate {
+		var err error
+		if q, err = b.DeclareQueue(address); err != nil {
+			_ = req.Reject(&amqp.Error{
+				Condition:   amqp.ErrCondInternalError,
+				Description: err.Error(),
+			})
+			return
+		}
+	}
+	if q == nil {
+		_ = req.Reject(&amqp.Error{
+			Condition:   amqp.ErrCondNotFound,
+			Description: fmt.Sprintf("node %q not found", address),
+		})
+		return
+	}
+
+	if req.Receiver {
+		if rcv := b.acceptProducer(req, fault); rcv != nil {
+			go serveLink(ctx, q.deleted, rcv, func(ctx context.Context) {
+				b.produce(ctx, rcv, in, q.Enqueue)
+			})
+		}
+		return
+	}
+
+	if snd := b.acceptConsumer(req, fault); snd != nil {
+		go serveLink(ctx, q.deleted, snd, func(ctx context.Context) {
+			q.dispatch(ctx, snd, b.txns, in)
+		})
+	}
+}
+
+// attachTopic accepts req as a publisher to t, or as a subscriber
+// to the messages published to t that match its subject filter.
+func (b *Broker) attachTopic(ctx context.Context, req *amqp.LinkRequest, t *Topic, in *injector, fault Fault) {
+	if req.Receiver {
+		if rcv := b.acceptProducer(req, fault); rcv != nil {
+			go serveLink(ctx, t.deleted, rcv, func(ctx context.Context) {
+				b.produce(ctx, rcv, in, func(msg *amqp.Message) error {
+					t.Publish(msg)
+					return nil
+				})
+			})
+		}
+		return
+	}
+
+	pattern := "#"
+	if v := req.SourceFilterValue(subjectFilter); v != nil {
+		p, ok := v.(string)
+		if !ok {
+			_ = req.Reject(&amqp.Error{
+				Condition:   amqp.ErrCondInvalidField,
+				Description: fmt.Sprintf("subject filter must be a string, got %T", v),
+			})
+			return
+		}
+		pattern = p
+	}
+
+	// subscribe before attaching so no message published after
+	// the attach completes is missed
+	sub := t.subscribe(pattern)
+	snd := b.acceptConsumer(req, fault)
+	if snd == nil {
+		t.unsubscribe(sub)
+		return
+	}
+	go serveLink(ctx, t.deleted, snd, func(ctx context.Context) {
+		// a detached consumer is only noticed when the next message is dispatched
+		sub.q.dispatch(ctx, snd, b.txns, in)
+		t.unsubscribe(sub)
+	})
+}
+
+// serveLink calls serve with a context that's canceled when deleted is
+// closed, in which case link is detached once serve returns.
+func serveLink(ctx context.Context, deleted <-chan struct{}, link interface{ Close(context.Context) error }, serve func(context.Context)) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-deleted:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	serve(ctx)
+
+	select {
+	case <-deleted:
+		detach(link)
+	default:
+	}
+}
+
+// detach closes link, waiting up to detachTimeout for the peer to acknowledge it.
+func detach(link interface{ Close(context.Context) error }) {
+	ctx, cancel := context.WithTimeout(context.Background(), detachTimeout)
+	defer cancel()
+	_ = link.Close(ctx)
+}
+
+// acceptProducer accepts the link from a client sending messages to a node.
+// It returns nil if the link couldn't be attached, or was detached by fault.
+func (b *Broker) acceptProducer(req *amqp.LinkRequest, fault Fault) *amqp.Receiver {
+	rcv, err := req.AcceptReceiver(&amqp.ReceiverOptions{Credit: b.credit})
+	if err != nil {
+		return nil
+	}
+	if fault.Detach {
+		detach(rcv)
+		return nil
+	}
+	return rcv
+}
+
+// acceptConsumer accepts the link from a client receiving messages from a node.
+// It returns nil if the link couldn't be attached, or was detached by fault.
+func (b *Broker) acceptConsumer(req *amqp.LinkRequest, fault Fault) *amqp.Sender {
+	snd, err := req.AcceptSender(&amqp.SenderOptions{IgnoreDispositionErrors: true})
+	if err != nil {
+		return nil
+	}
+	if fault.Detach {
+		detach(snd)
+		return nil
+	}
+	return snd
+}
+
+// produce passes the messages received from a producer to deliver
+// and accepts them, until the link is detached. Messages that deliver
+// fails to handle are rejected with the *amqp.Error it returns, or
+// amqp:internal-error.
+//
+// Messages sent in a transaction are only passed to deliver once it commits.
+func (b *Broker) produce(ctx context.Context, rcv *amqp.Receiver, in *injector, deliver func(*amqp.Message) error) {
+	for {
+		msg, err := rcv.Receive(ctx)
+		if err != nil {
+			return
+		}
+		fault := in.inject(ctx, FaultPointEnqueue, msg)
+		if fault.interrupts() {
+			if fault.Detach {
+				detach(rcv)
+			}
+			return
+		}
+		var rejectErr *amqp.Error
+		switch {
+		case fault.Error != nil:
+			rejectErr = fault.Error
+		case !fault.Drop:
+			rejectErr = b.enqueue(msg, deliver)
+		}
+		if rejectErr != nil {
+			err = rcv.RejectMessage(ctx, msg, rejectErr)
+		} else {
+			err = rcv.AcceptMessage(ctx, msg)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// enqueue passes msg to deliver, or enlists it in its transaction. It returns
+// the error to reject msg with, or nil if it must be accepted.
+func (b *Broker) enqueue(msg *amqp.Message, deliver func(*amqp.Message) error) *amqp.Error {
+	if txnID := msg.TransactionID(); txnID != nil {
+		if !b.txns.enlist(txnID, func(commit bool) error {
+			if !commit {
+				return nil
+			}
+			return deliver(msg)
+		}) {
+			return &amqp.Error{
+				Condition:   amqp.ErrCondTransactionUnknownID,
+				Description: fmt.Sprintf("transaction %q not found", txnID),
+			}
+		}
+		return nil
+	}
+	err := deliver(msg)
+	if err == nil {
+		return nil
+	}
+	var rejectErr *amqp.Error
+	if errors.As(err, &rejectErr) {
+		return rejectErr
+	}
+	return &amqp.Error{
+		Condition:   amqp.ErrCondInternalError,
+		Description: err.Error(),
+	}
+}
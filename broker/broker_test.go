@@ -0,0 +1,415 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSession(t *testing.T, b *Broker) *amqp.Session {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := amqp.NewListener(ln, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- b.Serve(ctx, l) }()
+	t.Cleanup(func() {
+		cancel()
+		<-served
+		_ = l.Close()
+	})
+
+	conn, err := amqp.Dial("amqp://"+l.Addr().String(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	return session
+}
+
+func TestBrokerFIFO(t *testing.T) {
+	b := New(nil)
+	q, err := b.DeclareQueue("q")
+	require.NoError(t, err)
+	session := newTestSession(t, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	snd, err := session.NewSender(ctx, "q", nil)
+	require.NoError(t, err)
+	for _, body := range []string{"one", "two", "three"} {
+		require.NoError(t, snd.Send(ctx, amqp.NewMessage([]byte(body))))
+	}
+	require.Equal(t, 3, q.Len())
+
+	rcv, err := session.NewReceiver(ctx, "q", nil)
+	require.NoError(t, err)
+	for _, body := range []string{"one", "two", "three"} {
+		msg, err := rcv.Receive(ctx)
+		require.NoError(t, err)
+		require.Equal(t, body, string(msg.GetData()))
+		require.NoError(t, rcv.AcceptMessage(ctx, msg))
+	}
+	require.Zero(t, q.Len())
+}
+
+func TestBrokerRedelivery(t *testing.T) {
+	b := New(nil)
+	q, err := b.DeclareQueue("q")
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(amqp.NewMessage([]byte("hello"))))
+	session := newTestSession(t, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rcv, err := session.NewReceiver(ctx, "q", &amqp.ReceiverOptions{Credit: 1})
+	require.NoError(t, err)
+
+	msg, err := rcv.Receive(ctx)
+	require.NoError(t, err)
+	require.NoError(t, rcv.ReleaseMessage(ctx, msg))
+
+	msg, err = rcv.Receive(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(msg.GetData()))
+	require.NoError(t, rcv.ModifyMessage(ctx, msg, &amqp.ModifyMessageOptions{
+		DeliveryFailed: true,
+		Annotations:    amqp.Annotations{"reason": "retry"},
+	}))
+
+	msg, err = rcv.Receive(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, msg.Header)
+	require.EqualValues(t, 1, msg.Header.DeliveryCount)
+	require.Equal(t, "retry", msg.Annotations["reason"])
+	require.NoError(t, rcv.AcceptMessage(ctx, msg))
+	require.Zero(t, q.Len())
+}
+
+func TestBrokerStoreRecovery(t *testing.T) {
+	store := NewMemoryStore()
+	b := New(&Options{Store: store})
+	q, err := b.DeclareQueue("q")
+	require.NoError(t, err)
+	for _, body := range []string{"one", "two", "three"} {
+		require.NoError(t, q.Enqueue(amqp.NewMessage([]byte(body))))
+	}
+	session := newTestSession(t, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rcv, err := session.NewReceiver(ctx, "q", &amqp.ReceiverOptions{Credit: 1})
+	require.NoError(t, err)
+	msg, err := rcv.Receive(ctx)
+	require.NoError(t, err)
+	require.NoError(t, rcv.AcceptMessage(ctx, msg))
+	require.NoError(t, rcv.Close(ctx))
+
+	// a new broker recovers the unsettled messages
+	q, err = New(&Options{Store: store}).DeclareQueue("q")
+	require.NoError(t, err)
+	require.Equal(t, 2, q.Len())
+
+	stored, err := store.Recover("q")
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	require.Equal(t, "two", string(stored[0].Message.GetData()))
+	require.Equal(t, "three", string(stored[1].Message.GetData()))
+}
+
+type failingStore struct {
+	Store
+	err error
+}
+
+func (f *failingStore) Append(string, *amqp.Message) (uint64, error) {
+	return 0, f.err
+}
+
+func TestBrokerStoreAppendError(t *testing.T) {
+	b := New(&Options{
+		AutoCreateQueues: true,
+		Store:            &failingStore{Store: NewMemoryStore(), err: errors.New("disk full")},
+	})
+	session := newTestSession(t, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	snd, err := session.NewSender(ctx, "q", nil)
+	require.NoError(t, err)
+	err = snd.Send(ctx, amqp.NewMessage([]byte("hello")))
+	var amqpErr *amqp.Error
+	require.True(t, errors.As(err, &amqpErr), "unexpected error %v", err)
+	require.Equal(t, amqp.ErrCondInternalError, amqpErr.Condition)
+	require.Zero(t, b.Queue("q").Len())
+}
+
+func TestBrokerNotFound(t *testing.T) {
+	session := newTestSession(t, New(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := session.NewReceiver(ctx, "missing", nil)
+	var amqpErr *amqp.Error
+	require.True(t, errors.As(err, &amqpErr), "unexpected error %v", err)
+	require.Equal(t, amqp.ErrCondNotFound, amqpErr.Condition)
+}
+
+func TestBrokerAutoCreateQueues(t *testing.T) {
+	b := New(&Options{AutoCreateQueues: true})
+	session := newTestSession(t, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	snd, err := session.NewSender(ctx, "created", nil)
+	require.NoError(t, err)
+	require.NoError(t, snd.Send(ctx, amqp.NewMessage([]byte("hello"))))
+
+	q := b.Queue("created")
+	require.NotNil(t, q)
+	require.Equal(t, 1, q.Len())
+}
+
+func TestBrokerTopicFanout(t *testing.T) {
+	b := New(nil)
+	topic := b.DeclareTopic("events")
+	session := newTestSession(t, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	all, err := session.NewReceiver(ctx, "events", nil)
+	require.NoError(t, err)
+	kitchen, err := session.NewReceiver(ctx, "events", &amqp.ReceiverOptions{
+		Filters: []amqp.LinkFilter{SubjectFilter("sensors.kitchen.*")},
+	})
+	require.NoError(t, err)
+	// never receives, mustn't hold up the other subscriptions
+	slow, err := session.NewReceiver(ctx, "events", &amqp.ReceiverOptions{Credit: 1})
+	require.NoError(t, err)
+	require.Equal(t, 3, topic.Subscriptions())
+
+	snd, err := session.NewSender(ctx, "events", nil)
+	require.NoError(t, err)
+	subjects := []string{"sensors.kitchen.temperature", "sensors.garage.temperature", "sensors.kitchen.humidity"}
+	for _, subject := range subjects {
+		msg := amqp.NewMessage([]byte(subject))
+		msg.Properties = &amqp.MessageProperties{Subject: &subject}
+		require.NoError(t, snd.Send(ctx, msg))
+	}
+
+	receive := func(rcv *amqp.Receiver, want ...string) {
+		for _, subject := range want {
+			msg, err := rcv.Receive(ctx)
+			require.NoError(t, err)
+			require.Equal(t, subject, string(msg.GetData()))
+			require.NoError(t, rcv.AcceptMessage(ctx, msg))
+		}
+	}
+	receive(all, subjects...)
+	receive(kitchen, subjects[0], subjects[2])
+
+	// the topic doesn't retain messages for subscriptions created later
+	late, err := session.NewReceiver(ctx, "events", nil)
+	require.NoError(t, err)
+	subject := "sensors.garage.humidity"
+	msg := amqp.NewMessage([]byte(subject))
+	msg.Properties = &amqp.MessageProperties{Subject: &subject}
+	require.NoError(t, snd.Send(ctx, msg))
+	receive(late, subject)
+	receive(all, subject)
+	receive(slow, subjects...)
+}
+
+func TestMatchSubject(t *testing.T) {
+	tests := []struct {
+		pattern string
+		subject string
+		match   bool
+	}{
+		{pattern: "#", subject: "", match: true},
+		{pattern: "#", subject: "a.b.c", match: true},
+		{pattern: "a.b", subject: "a.b", match: true},
+		{pattern: "a.b", subject: "a.c", match: false},
+		{pattern: "a.b", subject: "a.b.c", match: false},
+		{pattern: "a.*", subject: "a.b", match: true},
+		{pattern: "a.*", subject: "a", match: false},
+		{pattern: "a.*", subject: "a.b.c", match: false},
+		{pattern: "*.b", subject: "a.b", match: true},
+		{pattern: "a.#", subject: "a", match: true},
+		{pattern: "a.#", subject: "a.b.c", match: true},
+		{pattern: "a.#.c", subject: "a.c", match: true},
+		{pattern: "a.#.c", subject: "a.b.b.c", match: true},
+		{pattern: "a.#.c", subject: "a.b.d", match: false},
+		{pattern: "#.c", subject: "b", match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.subject, func(t *testing.T) {
+			require.Equal(t, tt.match, matchSubject(strings.Split(tt.pattern, "."), strings.Split(tt.subject, ".")))
+		})
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	txns := newTransactions()
+	ctx := context.Background()
+
+	q := newQueue("q", nil)
+	commitID, err := txns.Declare(ctx)
+	require.NoError(t, err)
+	rollbackID, err := txns.Declare(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, commitID, rollbackID)
+
+	for _, txnID := range [][]byte{commitID, rollbackID} {
+		require.True(t, txns.enlist(txnID, func(commit bool) error {
+			if !commit {
+				return nil
+			}
+			return q.Enqueue(amqp.NewMessage([]byte("hello")))
+		}))
+	}
+	require.Zero(t, q.Len())
+
+	require.NoError(t, txns.Discharge(ctx, rollbackID, true))
+	require.Zero(t, q.Len())
+	require.NoError(t, txns.Discharge(ctx, commitID, false))
+	require.Equal(t, 1, q.Len())
+
+	// discharged transactions no longer exist
+	require.False(t, txns.enlist(commitID, func(bool) error { return nil }))
+	err = txns.Discharge(ctx, commitID, false)
+	var amqpErr *amqp.Error
+	require.True(t, errors.As(err, &amqpErr), "unexpected error %v", err)
+	require.Equal(t, amqp.ErrCondTransactionUnknownID, amqpErr.Condition)
+
+	// outcomes received after the discharge are applied as it was
+	var committed []bool
+	for _, txnID := range [][]byte{commitID, rollbackID} {
+		require.True(t, txns.enlistOutcome(txnID, func(commit bool) error {
+			committed = append(committed, commit)
+			return nil
+		}))
+	}
+	require.Equal(t, []bool{true, false}, committed)
+	require.False(t, txns.enlistOutcome([]byte("unknown"), func(bool) error { return nil }))
+
+	// the rest of the work is applied when some fails to commit
+	failID, err := txns.Declare(ctx)
+	require.NoError(t, err)
+	require.True(t, txns.enlist(failID, func(bool) error { return errors.New("disk full") }))
+	applied := false
+	require.True(t, txns.enlist(failID, func(bool) error {
+		applied = true
+		return nil
+	}))
+	err = txns.Discharge(ctx, failID, false)
+	require.True(t, errors.As(err, &amqpErr), "unexpected error %v", err)
+	require.Equal(t, amqp.ErrCondInternalError, amqpErr.Condition)
+	require.Contains(t, amqpErr.Description, "partially committed")
+	require.True(t, applied)
+
+	// only the most recently discharged transactions are remembered
+	for i := 0; i < maxDischarged; i++ {
+		txnID, err := txns.Declare(ctx)
+		require.NoError(t, err)
+		require.NoError(t, txns.Discharge(ctx, txnID, false))
+	}
+	require.Len(t, txns.discharged, maxDischarged)
+	require.False(t, txns.enlistOutcome(commitID, func(bool) error { return nil }))
+}
+
+func TestManagement(t *testing.T) {
+	b := New(nil)
+	session := newTestSession(t, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	replyTo := "client-replies"
+	rcv, err := session.NewReceiver(ctx, ManagementAddress, &amqp.ReceiverOptions{TargetAddress: replyTo})
+	require.NoError(t, err)
+	snd, err := session.NewSender(ctx, ManagementAddress, nil)
+	require.NoError(t, err)
+
+	var nextID uint64
+	request := func(props map[string]any, body any) *amqp.Message {
+		nextID++
+		msg := &amqp.Message{
+			Properties:            &amqp.MessageProperties{MessageID: nextID, ReplyTo: &replyTo},
+			ApplicationProperties: props,
+			Value:                 body,
+		}
+		require.NoError(t, snd.Send(ctx, msg))
+		resp, err := rcv.Receive(ctx)
+		require.NoError(t, err)
+		require.Equal(t, nextID, resp.Properties.CorrelationID)
+		return resp
+	}
+	statusCode := func(resp *amqp.Message) int32 {
+		return resp.ApplicationProperties["statusCode"].(int32)
+	}
+
+	resp := request(map[string]any{"operation": "CREATE", "type": ManagementTypeQueue, "name": "q"}, map[string]any{})
+	require.EqualValues(t, 201, statusCode(resp))
+	require.NotNil(t, b.Queue("q"))
+	resp = request(map[string]any{"operation": "CREATE", "type": ManagementTypeQueue, "name": "q"}, map[string]any{})
+	require.EqualValues(t, 409, statusCode(resp))
+	resp = request(map[string]any{"operation": "CREATE", "type": ManagementTypeTopic, "name": "events"}, map[string]any{})
+	require.EqualValues(t, 201, statusCode(resp))
+	require.NotNil(t, b.Topic("events"))
+
+	require.NoError(t, b.Queue("q").Enqueue(amqp.NewMessage([]byte("hello"))))
+	resp = request(map[string]any{"operation": "READ", "type": ManagementTypeQueue, "identity": "q"}, map[string]any{})
+	require.EqualValues(t, 200, statusCode(resp))
+	attrs := resp.Value.(map[string]any)
+	require.Equal(t, "q", attrs["name"])
+	require.EqualValues(t, 1, attrs["messageCount"])
+
+	resp = request(map[string]any{"operation": "QUERY", "entityType": ManagementTypeTopic}, map[string]any{
+		"attributeNames": []string{"name", "subscriptionCount"},
+	})
+	require.EqualValues(t, 200, statusCode(resp))
+	require.EqualValues(t, 1, resp.ApplicationProperties["count"])
+	results := resp.Value.(map[string]any)["results"].([]any)
+	require.Equal(t, []any{[]any{"events", int64(0)}}, results)
+
+	// deleting a queue detaches its consumers
+	consumer, err := session.NewReceiver(ctx, "q", &amqp.ReceiverOptions{Credit: 1})
+	require.NoError(t, err)
+	msg, err := consumer.Receive(ctx)
+	require.NoError(t, err)
+	resp = request(map[string]any{"operation": "DELETE", "type": ManagementTypeQueue, "name": "q"}, map[string]any{})
+	require.EqualValues(t, 204, statusCode(resp))
+	require.Nil(t, b.Queue("q"))
+	_, err = consumer.Receive(ctx)
+	var detachErr *amqp.DetachError
+	require.ErrorAs(t, err, &detachErr)
+	_ = consumer.AcceptMessage(ctx, msg)
+
+	resp = request(map[string]any{"operation": "READ", "type": ManagementTypeQueue, "name": "q"}, map[string]any{})
+	require.EqualValues(t, 404, statusCode(resp))
+	resp = request(map[string]any{"operation": "UPDATE", "type": ManagementTypeTopic, "name": "events"}, map[string]any{})
+	require.EqualValues(t, 501, statusCode(resp))
+
+	// requests without a reply-to are rejected
+	err = snd.Send(ctx, &amqp.Message{ApplicationProperties: map[string]any{"operation": "GET-TYPES"}, Value: map[string]any{}})
+	var amqpErr *amqp.Error
+	require.True(t, errors.As(err, &amqpErr), "unexpected error %v", err)
+	require.Equal(t, amqp.ErrCondInvalidField, amqpErr.Condition)
+}
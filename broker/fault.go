@@ -0,0 +1,200 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+// FaultPoint is a point in the handling of a link at which faults can be injected.
+type FaultPoint int
+
+const (
+	// FaultPointAttach is reached when a client attaches a link to a node,
+	// before the link is accepted.
+	FaultPointAttach FaultPoint = iota
+
+	// FaultPointEnqueue is reached when a message is received from a
+	// producer, before it's added to the node and accepted.
+	FaultPointEnqueue
+
+	// FaultPointDispatch is reached when a message has been taken from a
+	// node to be delivered to a consumer, before it's sent.
+	FaultPointDispatch
+)
+
+func (p FaultPoint) String() string {
+	switch p {
+	case FaultPointAttach:
+		return "attach"
+	case FaultPointEnqueue:
+		return "enqueue"
+	case FaultPointDispatch:
+		return "dispatch"
+	default:
+		return fmt.Sprintf("FaultPoint(%d)", int(p))
+	}
+}
+
+// FaultEvent describes a FaultPoint reached by the broker.
+type FaultEvent struct {
+	// Point is the point reached.
+	Point FaultPoint
+
+	// Address is the address of the node the link is attached to.
+	Address string
+
+	// LinkName is the name of the link.
+	LinkName string
+
+	// Message is the message being enqueued or dispatched.
+	// It's nil for FaultPointAttach and must not be modified.
+	Message *amqp.Message
+}
+
+// Fault is the failure injected at a FaultPoint. The zero value injects no failure.
+type Fault struct {
+	// Delay is how long the broker waits before carrying on, or
+	// injecting the other failures. It applies to all fault points.
+	Delay time.Duration
+
+	// Error rejects the link being attached at FaultPointAttach, or the
+	// message being enqueued at FaultPointEnqueue. It's ignored at
+	// FaultPointDispatch.
+	Error *amqp.Error
+
+	// Drop discards the message being enqueued or dispatched. Dropped
+	// messages are accepted by the broker at FaultPointEnqueue, so the
+	// producer believes they were delivered, and removed from the node
+	// without being sent at FaultPointDispatch. It's ignored at
+	// FaultPointAttach.
+	Drop bool
+
+	// Detach detaches the link. At FaultPointAttach, the link is detached
+	// right after being attached. Messages being enqueued are left
+	// unsettled, and messages being dispatched are returned to the node.
+	Detach bool
+
+	// CloseConn closes the connection of the link, with the same effects on
+	// messages as Detach. At FaultPointAttach, the link isn't attached.
+	CloseConn bool
+}
+
+// FaultRule injects Fault at the events matching Point and Address.
+type FaultRule struct {
+	// Point is the fault point of the matching events.
+	Point FaultPoint
+
+	// Address is the node address of the matching events.
+	// An empty address matches all nodes.
+	Address string
+
+	// After is the number of matching events let through
+	// before the fault is injected.
+	After int
+
+	// Count is the number of times the fault is injected.
+	// When zero, it's injected at every matching event after the first After.
+	Count int
+
+	// Fault is the failure injected.
+	Fault Fault
+}
+
+// Script injects faults according to a list of rules. Pass its Hook
+// method to Options.FaultHook.
+//
+//	script := broker.NewScript(broker.FaultRule{
+//		Point:   broker.FaultPointEnqueue,
+//		Address: "orders",
+//		After:   2,
+//		Count:   1,
+//		Fault:   broker.Fault{CloseConn: true},
+//	})
+//	b := broker.New(&broker.Options{FaultHook: script.Hook})
+type Script struct {
+	mu    sync.Mutex
+	rules []FaultRule
+	seen  []int // the number of events matched by each rule
+}
+
+// NewScript creates a Script with the specified rules.
+func NewScript(rules ...FaultRule) *Script {
+	return &Script{rules: rules, seen: make([]int, len(rules))}
+}
+
+// Hook returns the fault of the first rule matching ev that's due to inject
+// it, or the zero Fault. Every rule matching ev counts it, whether it's due
+// or not.
+func (s *Script) Hook(ev FaultEvent) Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fault *Fault
+	for i, r := range s.rules {
+		if r.Point != ev.Point || (r.Address != "" && r.Address != ev.Address) {
+			continue
+		}
+		s.seen[i]++
+		n := s.seen[i]
+		if fault == nil && n > r.After && (r.Count == 0 || n <= r.After+r.Count) {
+			fault = &s.rules[i].Fault
+		}
+	}
+	if fault == nil {
+		return Fault{}
+	}
+	return *fault
+}
+
+// injector injects the faults returned by the broker's FaultHook
+// into the handling of a link. A nil *injector injects no faults.
+type injector struct {
+	hook    func(FaultEvent) Fault
+	conn    *amqp.Conn
+	address string
+	name    string
+}
+
+// newInjector returns the injector for the link named name attached to
+// address on conn, or nil if the broker doesn't inject faults.
+func (b *Broker) newInjector(conn *amqp.Conn, address, name string) *injector {
+	if b.faultHook == nil {
+		return nil
+	}
+	return &injector{hook: b.faultHook, conn: conn, address: address, name: name}
+}
+
+// inject returns the fault to inject at point, once its delay has elapsed.
+// It closes the connection when the fault requests it.
+func (in *injector) inject(ctx context.Context, point FaultPoint, msg *amqp.Message) Fault {
+	if in == nil {
+		return Fault{}
+	}
+	f := in.hook(FaultEvent{
+		Point:    point,
+		Address:  in.address,
+		LinkName: in.name,
+		Message:  msg,
+	})
+	if f.Delay > 0 {
+		timer := time.NewTimer(f.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	if f.CloseConn {
+		_ = in.conn.Close()
+	}
+	return f
+}
+
+// interrupts returns true if f detaches the link or closes its connection.
+func (f Fault) interrupts() bool {
+	return f.Detach || f.CloseConn
+}
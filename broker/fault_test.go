@@ -0,0 +1,151 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/require"
+)
+
+func newFaultSession(t *testing.T, rules ...FaultRule) (*Broker, *amqp.Session) {
+	b := New(&Options{
+		AutoCreateQueues: true,
+		FaultHook:        NewScript(rules...).Hook,
+	})
+	srv, err := NewServer(b, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+
+	conn, err := amqp.Dial(srv.URL, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	return b, session
+}
+
+func TestScript(t *testing.T) {
+	drop := Fault{Drop: true}
+	detach := Fault{Detach: true}
+	s := NewScript(
+		FaultRule{Point: FaultPointEnqueue, Address: "q", After: 1, Count: 2, Fault: drop},
+		FaultRule{Point: FaultPointEnqueue, Fault: detach},
+	)
+
+	var got []Fault
+	for i := 0; i < 4; i++ {
+		got = append(got, s.Hook(FaultEvent{Point: FaultPointEnqueue, Address: "q"}))
+	}
+	require.Equal(t, []Fault{detach, drop, drop, detach}, got)
+	require.Equal(t, detach, s.Hook(FaultEvent{Point: FaultPointEnqueue, Address: "other"}))
+	require.Equal(t, Fault{}, s.Hook(FaultEvent{Point: FaultPointDispatch, Address: "q"}))
+}
+
+func TestFaultAttach(t *testing.T) {
+	_, session := newFaultSession(t,
+		FaultRule{Point: FaultPointAttach, Address: "rejected", Fault: Fault{
+			Error: &amqp.Error{Condition: amqp.ErrCondUnauthorizedAccess},
+		}},
+		FaultRule{Point: FaultPointAttach, Address: "detached", Fault: Fault{Detach: true}},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := session.NewSender(ctx, "rejected", nil)
+	var amqpErr *amqp.Error
+	require.ErrorAs(t, err, &amqpErr)
+	require.Equal(t, amqp.ErrCondUnauthorizedAccess, amqpErr.Condition)
+
+	rcv, err := session.NewReceiver(ctx, "detached", nil)
+	require.NoError(t, err)
+	_, err = rcv.Receive(ctx)
+	var detachErr *amqp.DetachError
+	require.ErrorAs(t, err, &detachErr)
+
+	snd, err := session.NewSender(ctx, "other", nil)
+	require.NoError(t, err)
+	require.NoError(t, snd.Send(ctx, amqp.NewMessage([]byte("hello"))))
+}
+
+func TestFaultEnqueue(t *testing.T) {
+	b, session := newFaultSession(t,
+		FaultRule{Point: FaultPointEnqueue, Count: 1, Fault: Fault{Drop: true}},
+		FaultRule{Point: FaultPointEnqueue, After: 1, Count: 1, Fault: Fault{
+			Error: &amqp.Error{Condition: amqp.ErrCondResourceLimitExceeded},
+		}},
+		FaultRule{Point: FaultPointEnqueue, After: 3, Count: 1, Fault: Fault{Detach: true}},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	snd, err := session.NewSender(ctx, "q", &amqp.SenderOptions{IgnoreDispositionErrors: true})
+	require.NoError(t, err)
+
+	// dropped, then rejected, then enqueued
+	require.NoError(t, snd.Send(ctx, amqp.NewMessage([]byte("dropped"))))
+	err = snd.Send(ctx, amqp.NewMessage([]byte("rejected")))
+	var amqpErr *amqp.Error
+	require.ErrorAs(t, err, &amqpErr)
+	require.Equal(t, amqp.ErrCondResourceLimitExceeded, amqpErr.Condition)
+	require.NoError(t, snd.Send(ctx, amqp.NewMessage([]byte("enqueued"))))
+	require.Equal(t, 1, b.Queue("q").Len())
+
+	// the link is detached on the fourth message, which isn't enqueued
+	err = snd.Send(ctx, amqp.NewMessage([]byte("detached")))
+	var detachErr *amqp.DetachError
+	require.ErrorAs(t, err, &detachErr)
+	require.Equal(t, 1, b.Queue("q").Len())
+}
+
+func TestFaultDispatch(t *testing.T) {
+	b, session := newFaultSession(t,
+		FaultRule{Point: FaultPointDispatch, Count: 1, Fault: Fault{Drop: true}},
+		FaultRule{Point: FaultPointDispatch, After: 1, Count: 1, Fault: Fault{Delay: 10 * time.Millisecond, Detach: true}},
+	)
+	q, err := b.DeclareQueue("q")
+	require.NoError(t, err)
+	for _, body := range []string{"dropped", "redelivered"} {
+		require.NoError(t, q.Enqueue(amqp.NewMessage([]byte(body))))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the first message is dropped, and the link detached before the second is sent
+	rcv, err := session.NewReceiver(ctx, "q", nil)
+	require.NoError(t, err)
+	_, err = rcv.Receive(ctx)
+	var detachErr *amqp.DetachError
+	require.ErrorAs(t, err, &detachErr)
+
+	rcv, err = session.NewReceiver(ctx, "q", nil)
+	require.NoError(t, err)
+	msg, err := rcv.Receive(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "redelivered", string(msg.GetData()))
+	require.NoError(t, rcv.AcceptMessage(ctx, msg))
+	require.Zero(t, q.Len())
+}
+
+func TestFaultCloseConn(t *testing.T) {
+	b, session := newFaultSession(t,
+		FaultRule{Point: FaultPointEnqueue, Address: "q", After: 1, Fault: Fault{CloseConn: true}},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	snd, err := session.NewSender(ctx, "q", nil)
+	require.NoError(t, err)
+	require.NoError(t, snd.Send(ctx, amqp.NewMessage([]byte("enqueued"))))
+
+	err = snd.Send(ctx, amqp.NewMessage([]byte("lost")))
+	var connErr *amqp.ConnError
+	require.ErrorAs(t, err, &connErr)
+	require.False(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, 1, b.Queue("q").Len())
+}
@@ -0,0 +1,313 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/go-amqp"
+)
+
+// ManagementAddress is the address of the broker's management node.
+//
+// The management node implements the request/response operations of the
+// AMQP Management specification (working draft 16), so the queues and topics
+// of the broker can be managed with existing AMQP tooling.
+//
+// Requests are sent to ManagementAddress with the operation in the
+// "operation" application property. Responses are sent to the address in the
+// request's reply-to, which is either the target address of a link attached
+// from ManagementAddress or the name of a queue. Responses carry the status in
+// the "statusCode" and "statusDescription" application properties, and their
+// correlation-id is the request's message-id.
+//
+// The following operations are supported:
+//   - CREATE, READ, and DELETE the entity identified by the "type" and "name"
+//     (or "identity") application properties.
+//   - QUERY the attributes listed in the "attributeNames" entry of the request
+//     body, of the entities of the type in the "entityType" application
+//     property or of every type if it's absent.
+//   - GET-TYPES returns the supported entity types.
+const ManagementAddress = "$management"
+
+// Entity types of the management node.
+const (
+	ManagementTypeQueue = "queue"
+	ManagementTypeTopic = "topic"
+)
+
+// attachManagement accepts req as a client sending requests to the management
+// node, or as a client receiving responses at the link's target address.
+func (b *Broker) attachManagement(ctx context.Context, req *amqp.LinkRequest) {
+	if req.Receiver {
+		if rcv := b.acceptProducer(req, Fault{}); rcv != nil {
+			go b.produce(ctx, rcv, nil, func(msg *amqp.Message) error {
+				return b.reply(ctx, msg, b.manage(msg))
+			})
+		}
+		return
+	}
+
+	if req.TargetAddress == "" {
+		_ = req.Reject(&amqp.Error{
+			Condition:   amqp.ErrCondInvalidField,
+			Description: "links receiving management responses require a target address",
+		})
+		return
+	}
+	snd, err := req.AcceptSender(&amqp.SenderOptions{
+		SettlementMode: amqp.SenderSettleModeSettled.Ptr(),
+	})
+	if err != nil {
+		return
+	}
+	b.mu.Lock()
+	b.replies[req.TargetAddress] = snd
+	b.mu.Unlock()
+}
+
+// reply sends resp to the address in the reply-to of req.
+// Links detached by the client are only noticed when sending to them fails.
+func (b *Broker) reply(ctx context.Context, req, resp *amqp.Message) error {
+	if req.Properties == nil || req.Properties.ReplyTo == nil {
+		return &amqp.Error{
+			Condition:   amqp.ErrCondInvalidField,
+			Description: "management requests require a reply-to address",
+		}
+	}
+	replyTo := *req.Properties.ReplyTo
+	resp.Properties.To = &replyTo
+
+	b.mu.Lock()
+	snd := b.replies[replyTo]
+	b.mu.Unlock()
+	if snd != nil {
+		err := snd.Send(ctx, resp)
+		if err == nil {
+			return nil
+		}
+		b.mu.Lock()
+		if b.replies[replyTo] == snd {
+			delete(b.replies, replyTo)
+		}
+		b.mu.Unlock()
+	}
+
+	if q := b.Queue(replyTo); q != nil {
+		return q.Enqueue(resp)
+	}
+	return &amqp.Error{
+		Condition:   amqp.ErrCondNotFound,
+		Description: fmt.Sprintf("reply-to node %q not found", replyTo),
+	}
+}
+
+// manage performs the management operation requested by req and returns the response.
+func (b *Broker) manage(req *amqp.Message) *amqp.Message {
+	resp := &amqp.Message{
+		Properties:            &amqp.MessageProperties{},
+		ApplicationProperties: map[string]any{},
+		Value:                 map[string]any{},
+	}
+	if req.Properties != nil {
+		resp.Properties.CorrelationID = req.Properties.MessageID
+		if resp.Properties.CorrelationID == nil {
+			resp.Properties.CorrelationID = req.Properties.CorrelationID
+		}
+	}
+
+	status := http.StatusOK
+	var err error
+	switch op := stringProperty(req, "operation"); op {
+	case "CREATE":
+		status, err = b.manageCreate(req, resp)
+	case "READ":
+		status, err = b.manageRead(req, resp)
+	case "DELETE":
+		status, err = b.manageDelete(req)
+	case "QUERY":
+		status, err = b.manageQuery(req, resp)
+	case "GET-TYPES":
+		resp.Value = map[string]any{
+			ManagementTypeQueue: []string{},
+			ManagementTypeTopic: []string{},
+		}
+	case "":
+		status, err = http.StatusBadRequest, errors.New("missing operation")
+	default:
+		status, err = http.StatusNotImplemented, fmt.Errorf("operation %q is not supported", op)
+	}
+
+	description := http.StatusText(status)
+	if err != nil {
+		description = err.Error()
+	}
+	resp.ApplicationProperties["statusCode"] = int32(status)
+	resp.ApplicationProperties["statusDescription"] = description
+	return resp
+}
+
+// manageCreate creates the entity identified by req.
+func (b *Broker) manageCreate(req, resp *amqp.Message) (int, error) {
+	typ, name, err := entityOf(req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if b.Queue(name) != nil || b.Topic(name) != nil {
+		return http.StatusConflict, fmt.Errorf("node %q already exists", name)
+	}
+	switch typ {
+	case ManagementTypeQueue:
+		q, err := b.DeclareQueue(name)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		resp.Value = queueAttributes(q)
+	case ManagementTypeTopic:
+		resp.Value = topicAttributes(b.DeclareTopic(name))
+	}
+	return http.StatusCreated, nil
+}
+
+// manageRead returns the attributes of the entity identified by req.
+func (b *Broker) manageRead(req, resp *amqp.Message) (int, error) {
+	typ, name, err := entityOf(req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	switch typ {
+	case ManagementTypeQueue:
+		if q := b.Queue(name); q != nil {
+			resp.Value = queueAttributes(q)
+			return http.StatusOK, nil
+		}
+	case ManagementTypeTopic:
+		if t := b.Topic(name); t != nil {
+			resp.Value = topicAttributes(t)
+			return http.StatusOK, nil
+		}
+	}
+	return http.StatusNotFound, fmt.Errorf("%s %q not found", typ, name)
+}
+
+// manageDelete deletes the entity identified by req.
+func (b *Broker) manageDelete(req *amqp.Message) (int, error) {
+	typ, name, err := entityOf(req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	var deleted bool
+	switch typ {
+	case ManagementTypeQueue:
+		deleted = b.DeleteQueue(name)
+	case ManagementTypeTopic:
+		deleted = b.DeleteTopic(name)
+	}
+	if !deleted {
+		return http.StatusNotFound, fmt.Errorf("%s %q not found", typ, name)
+	}
+	return http.StatusNoContent, nil
+}
+
+// manageQuery returns the requested attributes of the entities of the requested type.
+func (b *Broker) manageQuery(req, resp *amqp.Message) (int, error) {
+	typ := stringProperty(req, "entityType")
+	if typ != "" && typ != ManagementTypeQueue && typ != ManagementTypeTopic {
+		return http.StatusBadRequest, fmt.Errorf("unknown entity type %q", typ)
+	}
+
+	var attributeNames any
+	switch body := req.Value.(type) {
+	case map[string]any:
+		attributeNames = body["attributeNames"]
+	case map[any]any:
+		attributeNames = body["attributeNames"]
+	}
+	var names []string
+	switch v := attributeNames.(type) {
+	case []string:
+		names = v
+	case []any:
+		for _, name := range v {
+			s, ok := name.(string)
+			if !ok {
+				return http.StatusBadRequest, fmt.Errorf("attribute names must be strings, got %T", name)
+			}
+			names = append(names, s)
+		}
+	}
+
+	var entities []map[string]any
+	b.mu.Lock()
+	if typ == "" || typ == ManagementTypeQueue {
+		for _, q := range b.queues {
+			entities = append(entities, queueAttributes(q))
+		}
+	}
+	if typ == "" || typ == ManagementTypeTopic {
+		for _, t := range b.topics {
+			entities = append(entities, topicAttributes(t))
+		}
+	}
+	b.mu.Unlock()
+
+	if len(names) == 0 {
+		names = []string{"name", "identity", "type"}
+	}
+	results := make([]any, len(entities))
+	for i, attrs := range entities {
+		row := make([]any, len(names))
+		for j, name := range names {
+			row[j] = attrs[name]
+		}
+		results[i] = row
+	}
+	resp.Value = map[string]any{
+		"attributeNames": names,
+		"results":        results,
+	}
+	resp.ApplicationProperties["count"] = int32(len(results))
+	return http.StatusOK, nil
+}
+
+// entityOf returns the type and name of the entity a request operates on.
+func entityOf(req *amqp.Message) (string, string, error) {
+	typ := stringProperty(req, "type")
+	if typ != ManagementTypeQueue && typ != ManagementTypeTopic {
+		return "", "", fmt.Errorf("unknown entity type %q", typ)
+	}
+	name := stringProperty(req, "name")
+	if name == "" {
+		name = stringProperty(req, "identity")
+	}
+	if name == "" {
+		return "", "", errors.New("missing entity name")
+	}
+	return typ, name, nil
+}
+
+// stringProperty returns the string application property of msg with the specified key,
+// or the empty string if it isn't set or isn't a string.
+func stringProperty(msg *amqp.Message, key string) string {
+	s, _ := msg.ApplicationProperties[key].(string)
+	return s
+}
+
+func queueAttributes(q *Queue) map[string]any {
+	return map[string]any{
+		"name":         q.Name(),
+		"identity":     q.Name(),
+		"type":         ManagementTypeQueue,
+		"messageCount": int64(q.Len()),
+	}
+}
+
+func topicAttributes(t *Topic) map[string]any {
+	return map[string]any{
+		"name":              t.Name(),
+		"identity":          t.Name(),
+		"type":              ManagementTypeTopic,
+		"subscriptionCount": int64(t.Subscriptions()),
+	}
+}
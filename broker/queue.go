@@ -0,0 +1,254 @@
+package broker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Azure/go-amqp"
+)
+
+// Queue is a FIFO node. Each message is delivered to one of the consumers
+// attached to the queue that has link credit.
+//
+// A message is removed from the queue once a consumer accepts or rejects it,
+// or when it was sent pre-settled. Released and modified messages are returned
+// to the head of the queue for redelivery, and the delivery count of modified
+// messages is incremented when requested.
+//
+// Messages are appended to the broker's Store when enqueued and settled in it
+// once removed from the queue. Changes made by modified outcomes aren't stored.
+type Queue struct {
+	name  string
+	store Store // nil when messages aren't persisted
+
+	mu       sync.Mutex
+	messages []queued
+	avail    chan struct{} // closed when messages are added, nil when nobody is waiting
+	deleted  chan struct{} // closed by delete
+}
+
+// queued is a message waiting in a Queue.
+type queued struct {
+	id  uint64 // the ID assigned by the Store, zero when not persisted
+	msg *amqp.Message
+}
+
+func newQueue(name string, store Store) *Queue {
+	return &Queue{name: name, store: store, deleted: make(chan struct{})}
+}
+
+// recover adds the unsettled messages of the queue in its store.
+func (q *Queue) recover() error {
+	if q.store == nil {
+		return nil
+	}
+	stored, err := q.store.Recover(q.name)
+	if err != nil {
+		return err
+	}
+	for _, s := range stored {
+		q.push(queued{id: s.ID, msg: s.Message}, false)
+	}
+	return nil
+}
+
+// Name returns the queue's address.
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// Len returns the number of messages waiting to be delivered.
+// Messages that are being delivered are not included.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+// Enqueue adds a copy of msg to the tail of the queue.
+// It returns the error from Store.Append, in which case msg isn't added.
+func (q *Queue) Enqueue(msg *amqp.Message) error {
+	item := queued{msg: copyMessage(msg)}
+	if q.store != nil {
+		id, err := q.store.Append(q.name, item.msg)
+		if err != nil {
+			return err
+		}
+		item.id = id
+	}
+	q.push(item, false)
+	return nil
+}
+
+// settle removes item from the store once it has left the queue for good.
+func (q *Queue) settle(item queued) {
+	if q.store == nil {
+		return
+	}
+	// the message was consumed, a failure only causes it to be redelivered after recovery
+	_ = q.store.Settle(q.name, item.id)
+}
+
+// delete discards the messages of the queue. Messages
+// returned to the queue afterwards are discarded too.
+func (q *Queue) delete() {
+	q.mu.Lock()
+	messages := q.messages
+	q.messages = nil
+	close(q.deleted)
+	q.mu.Unlock()
+	for _, item := range messages {
+		q.settle(item)
+	}
+}
+
+// push adds item to the tail, or the head when redelivering, and wakes waiting consumers.
+// item is discarded if the queue has been deleted.
+func (q *Queue) push(item queued, head bool) {
+	q.mu.Lock()
+	select {
+	case <-q.deleted:
+		q.mu.Unlock()
+		q.settle(item)
+		return
+	default:
+	}
+	if head {
+		q.messages = append([]queued{item}, q.messages...)
+	} else {
+		q.messages = append(q.messages, item)
+	}
+	if q.avail != nil {
+		close(q.avail)
+		q.avail = nil
+	}
+	q.mu.Unlock()
+}
+
+// pop removes the message at the head of the queue, waiting for one if it's empty.
+func (q *Queue) pop(ctx context.Context) (queued, error) {
+	for {
+		q.mu.Lock()
+		if len(q.messages) > 0 {
+			item := q.messages[0]
+			q.messages[0] = queued{}
+			q.messages = q.messages[1:]
+			q.mu.Unlock()
+			return item, nil
+		}
+		if q.avail == nil {
+			q.avail = make(chan struct{})
+		}
+		avail := q.avail
+		q.mu.Unlock()
+
+		select {
+		case <-avail:
+		case <-ctx.Done():
+			return queued{}, ctx.Err()
+		}
+	}
+}
+
+// dispatch delivers messages to a consumer until the link is detached.
+// A message is only taken from the queue once the consumer has credit.
+//
+// The outcomes of deliveries settled in a transaction are applied when it
+// commits. The messages are redelivered if it rolls back.
+func (q *Queue) dispatch(ctx context.Context, snd *amqp.Sender, txns *transactions, in *injector) {
+	for {
+		if err := snd.WaitForCredit(ctx); err != nil {
+			return
+		}
+		item, err := q.pop(ctx)
+		if err != nil {
+			return
+		}
+
+		fault := in.inject(ctx, FaultPointDispatch, item.msg)
+		if fault.interrupts() {
+			q.push(item, true)
+			if fault.Detach {
+				detach(snd)
+			}
+			return
+		}
+		if fault.Drop {
+			q.settle(item)
+			continue
+		}
+
+		outcome, err := snd.SendWithOutcome(ctx, item.msg)
+		if err != nil {
+			// the consumer is gone, the message might not have been delivered
+			q.push(item, true)
+			return
+		}
+
+		if outcome.TransactionID == nil {
+			q.complete(item, outcome)
+			continue
+		}
+		if !txns.enlistOutcome(outcome.TransactionID, func(commit bool) error {
+			if commit {
+				q.complete(item, outcome)
+			} else {
+				q.push(item, true)
+			}
+			return nil
+		}) {
+			// the transaction doesn't exist, ignore the outcome
+			q.push(item, true)
+		}
+	}
+}
+
+// complete applies the outcome of the delivery of item.
+func (q *Queue) complete(item queued, outcome amqp.Outcome) {
+	switch outcome.Type {
+	case amqp.OutcomeReleased:
+		q.push(item, true)
+	case amqp.OutcomeModified:
+		// msg may share its sections with copies delivered to other consumers
+		msg := copyMessage(item.msg)
+		if outcome.DeliveryFailed {
+			header := amqp.MessageHeader{}
+			if msg.Header != nil {
+				header = *msg.Header
+			}
+			header.DeliveryCount++
+			msg.Header = &header
+		}
+		if len(outcome.Annotations) > 0 {
+			annotations := make(amqp.Annotations, len(msg.Annotations)+len(outcome.Annotations))
+			for k, v := range msg.Annotations {
+				annotations[k] = v
+			}
+			for k, v := range outcome.Annotations {
+				annotations[k] = v
+			}
+			msg.Annotations = annotations
+		}
+		q.push(queued{id: item.id, msg: msg}, true)
+	default:
+		q.settle(item)
+	}
+}
+
+// copyMessage copies the sections of msg, dropping its delivery state
+// so it can be sent on another link.
+func copyMessage(msg *amqp.Message) *amqp.Message {
+	return &amqp.Message{
+		Format:                msg.Format,
+		Header:                msg.Header,
+		DeliveryAnnotations:   msg.DeliveryAnnotations,
+		Annotations:           msg.Annotations,
+		Properties:            msg.Properties,
+		ApplicationProperties: msg.ApplicationProperties,
+		Data:                  msg.Data,
+		Value:                 msg.Value,
+		Sequence:              msg.Sequence,
+		Footer:                msg.Footer,
+		RawPayload:            msg.RawPayload,
+	}
+}
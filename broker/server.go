@@ -0,0 +1,71 @@
+package broker
+
+import (
+	"context"
+	"net"
+
+	"github.com/Azure/go-amqp"
+)
+
+// Server serves a Broker on a local TCP port, so the integration tests of
+// applications can run against it hermetically, in the manner of httptest.Server.
+//
+//	srv, err := broker.NewServer(broker.New(&broker.Options{AutoCreateQueues: true}), nil)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer srv.Close()
+//	conn, err := amqp.Dial(srv.URL, nil)
+type Server struct {
+	// URL is the URL of the server, of the form amqp://127.0.0.1:port.
+	// Pass it to amqp.Dial.
+	URL string
+
+	// Broker is the broker served.
+	Broker *Broker
+
+	l      *amqp.Listener
+	cancel context.CancelFunc
+	served chan struct{}
+}
+
+// NewServer starts serving b on a random port of the loopback interface.
+//
+// opts: pass nil to accept the default values.
+func NewServer(b *Broker, opts *amqp.ListenerOptions) (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	l, err := amqp.NewListener(ln, opts)
+	if err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+
+	scheme := "amqp"
+	if opts != nil && opts.TLSConfig != nil {
+		scheme = "amqps"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		URL:    scheme + "://" + l.Addr().String(),
+		Broker: b,
+		l:      l,
+		cancel: cancel,
+		served: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.served)
+		_ = b.Serve(ctx, l)
+	}()
+	return s, nil
+}
+
+// Close stops the server and closes the connections it served.
+func (s *Server) Close() error {
+	s.cancel()
+	err := s.l.Close()
+	<-s.served
+	return err
+}
@@ -0,0 +1,86 @@
+package broker
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Azure/go-amqp"
+)
+
+// Store persists the messages of queues so they survive restarts of the broker.
+//
+// Implementations must be safe for concurrent use. Messages can be
+// serialized with amqp.Message.MarshalBinary and restored with
+// amqp.Message.UnmarshalBinary.
+type Store interface {
+	// Append stores msg at the tail of the named queue and returns
+	// an ID that identifies it within the queue.
+	Append(queue string, msg *amqp.Message) (uint64, error)
+
+	// Settle removes the message with the specified ID from the named
+	// queue once it has been consumed.
+	Settle(queue string, id uint64) error
+
+	// Recover returns the messages of the named queue that haven't been
+	// settled, in the order they were appended.
+	Recover(queue string) ([]StoredMessage, error)
+}
+
+// StoredMessage is a message recovered from a Store.
+type StoredMessage struct {
+	// ID is the ID returned by Store.Append.
+	ID uint64
+
+	// Message is the stored message.
+	Message *amqp.Message
+}
+
+// memoryStore is a Store that keeps messages in memory.
+type memoryStore struct {
+	mu     sync.Mutex
+	nextID uint64
+	queues map[string]map[uint64]*amqp.Message
+}
+
+// NewMemoryStore creates a Store that keeps messages in memory.
+//
+// Messages survive a Broker being replaced with another one using the
+// same Store, but are lost when the process exits.
+func NewMemoryStore() Store {
+	return &memoryStore{queues: map[string]map[uint64]*amqp.Message{}}
+}
+
+func (m *memoryStore) Append(queue string, msg *amqp.Message) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msgs, ok := m.queues[queue]
+	if !ok {
+		msgs = map[uint64]*amqp.Message{}
+		m.queues[queue] = msgs
+	}
+	m.nextID++
+	msgs[m.nextID] = msg
+	return m.nextID, nil
+}
+
+func (m *memoryStore) Settle(queue string, id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.queues[queue], id)
+	return nil
+}
+
+func (m *memoryStore) Recover(queue string) ([]StoredMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msgs := m.queues[queue]
+	stored := make([]StoredMessage, 0, len(msgs))
+	for id, msg := range msgs {
+		stored = append(stored, StoredMessage{ID: id, Message: msg})
+	}
+	// IDs are assigned in ascending order
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].ID < stored[j].ID
+	})
+	return stored, nil
+}
@@ -0,0 +1,134 @@
+package broker
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/Azure/go-amqp"
+)
+
+const (
+	// the filter used by Qpid and ActiveMQ to bind subscriptions to subjects
+	subjectFilter     = "apache.org:legacy-amqp-topic-binding:string"
+	subjectFilterCode = uint64(0x0000468C00000001)
+)
+
+// SubjectFilter returns a source filter that subscribes a Receiver attached
+// to a Topic to the messages whose subject matches pattern.
+//
+// A subject consists of words separated by dots. In pattern, the word "*"
+// matches exactly one word and the word "#" matches zero or more words.
+// For example, "sensors.*.temperature" matches "sensors.kitchen.temperature"
+// and "sensors.#" matches any subject starting with "sensors.", as well as
+// "sensors" itself. Messages without a subject have an empty subject.
+//
+// Receivers attached to a Topic without a subject filter receive all messages.
+func SubjectFilter(pattern string) amqp.LinkFilter {
+	return amqp.NewLinkFilter(subjectFilter, subjectFilterCode, pattern)
+}
+
+// Topic is a publish/subscribe node. Each message published to the topic is
+// delivered to every consumer whose subject filter matches it.
+//
+// Each consumer attached to the topic has its own subscription, which buffers
+// matching messages until the consumer grants credit for them, so a slow
+// consumer doesn't delay the others. A subscription and the messages it
+// buffers are discarded when its consumer detaches. Released and modified
+// messages are redelivered to the same consumer.
+type Topic struct {
+	name string
+
+	mu      sync.Mutex
+	subs    map[*subscription]struct{}
+	deleted chan struct{} // closed by Broker.DeleteTopic
+}
+
+// subscription buffers the messages matching pattern for one consumer.
+type subscription struct {
+	pattern []string
+	q       *Queue
+}
+
+func newTopic(name string) *Topic {
+	return &Topic{
+		name:    name,
+		subs:    map[*subscription]struct{}{},
+		deleted: make(chan struct{}),
+	}
+}
+
+// Name returns the topic's address.
+func (t *Topic) Name() string {
+	return t.name
+}
+
+// Subscriptions returns the number of consumers attached to the topic.
+func (t *Topic) Subscriptions() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.subs)
+}
+
+// Publish delivers a copy of msg to the subscriptions matching its subject.
+// The message is discarded when no subscriptions match.
+func (t *Topic) Publish(msg *amqp.Message) {
+	var subject []string
+	if msg.Properties != nil && msg.Properties.Subject != nil {
+		subject = strings.Split(*msg.Properties.Subject, ".")
+	} else {
+		subject = []string{""}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for sub := range t.subs {
+		if matchSubject(sub.pattern, subject) {
+			// subscriptions aren't persisted so enqueueing can't fail
+			_ = sub.q.Enqueue(msg)
+		}
+	}
+}
+
+// subscribe adds a subscription to the messages matching pattern.
+func (t *Topic) subscribe(pattern string) *subscription {
+	sub := &subscription{
+		pattern: strings.Split(pattern, "."),
+		q:       newQueue(t.name, nil),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subs[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes sub, discarding the messages it buffers.
+func (t *Topic) unsubscribe(sub *subscription) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.subs, sub)
+}
+
+// matchSubject reports whether the words of subject match those of pattern.
+func matchSubject(pattern, subject []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "#":
+			for i := 0; i <= len(subject); i++ {
+				if matchSubject(pattern[1:], subject[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(subject) == 0 {
+				return false
+			}
+		default:
+			if len(subject) == 0 || subject[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, subject = pattern[1:], subject[1:]
+	}
+	return len(subject) == 0
+}
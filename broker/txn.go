@@ -0,0 +1,113 @@
+package broker
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/Azure/go-amqp"
+)
+
+// maxDischarged is the number of discharged transactions remembered for the
+// outcomes received after their discharge.
+const maxDischarged = 1024
+
+// transactions implements amqp.TransactionHandler for the coordinators of a Broker.
+//
+// The messages published and the outcomes of the deliveries settled in a
+// transaction are enlisted in it, and only take effect once it commits.
+//
+// Discharging a transaction applies its work even if some of it fails, such
+// as a message published to a queue that has since been deleted. The
+// transaction is then partially committed, which is reported with
+// amqp:internal-error rather than amqp:transaction:rollback.
+type transactions struct {
+	mu             sync.Mutex
+	nextID         uint64
+	work           map[string][]func(commit bool) error
+	discharged     map[string]bool // recently discharged transactions, to whether they committed
+	dischargeOrder []string        // the keys of discharged, oldest first
+}
+
+func newTransactions() *transactions {
+	return &transactions{
+		work:       map[string][]func(commit bool) error{},
+		discharged: map[string]bool{},
+	}
+}
+
+func (t *transactions) Declare(ctx context.Context) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	txnID := []byte(strconv.FormatUint(t.nextID, 10))
+	t.work[string(txnID)] = nil
+	return txnID, nil
+}
+
+func (t *transactions) Discharge(ctx context.Context, txnID []byte, fail bool) error {
+	t.mu.Lock()
+	work, ok := t.work[string(txnID)]
+	delete(t.work, string(txnID))
+	if ok {
+		t.discharged[string(txnID)] = !fail
+		t.dischargeOrder = append(t.dischargeOrder, string(txnID))
+		if len(t.dischargeOrder) > maxDischarged {
+			delete(t.discharged, t.dischargeOrder[0])
+			t.dischargeOrder = t.dischargeOrder[1:]
+		}
+	}
+	t.mu.Unlock()
+	if !ok {
+		return &amqp.Error{Condition: amqp.ErrCondTransactionUnknownID}
+	}
+
+	var firstErr error
+	for _, w := range work {
+		if err := w(!fail); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		// the rest of the work was still applied
+		return &amqp.Error{
+			Condition:   amqp.ErrCondInternalError,
+			Description: "transaction partially committed: " + firstErr.Error(),
+		}
+	}
+	return nil
+}
+
+// enlist adds work to be done when the transaction is discharged, with commit
+// set if it committed. It returns false if the transaction doesn't exist.
+func (t *transactions) enlist(txnID []byte, work func(commit bool) error) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.work[string(txnID)]; !ok {
+		return false
+	}
+	t.work[string(txnID)] = append(t.work[string(txnID)], work)
+	return true
+}
+
+// enlistOutcome enlists work applying the outcome of a delivery settled in
+// the transaction. The outcome is received by the queue's dispatch goroutine,
+// which can run after the discharge sent next by the consumer was handled,
+// in which case work is done immediately. It returns false if the
+// transaction was never declared, or was discharged so long ago that it's
+// no longer remembered.
+func (t *transactions) enlistOutcome(txnID []byte, work func(commit bool) error) bool {
+	t.mu.Lock()
+	if _, ok := t.work[string(txnID)]; ok {
+		t.work[string(txnID)] = append(t.work[string(txnID)], work)
+		t.mu.Unlock()
+		return true
+	}
+	commit, ok := t.discharged[string(txnID)]
+	t.mu.Unlock()
+	if !ok {
+		return false
+	}
+	_ = work(commit)
+	return true
+}
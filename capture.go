@@ -0,0 +1,186 @@
+package amqp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp/internal/capture"
+	"github.com/Azure/go-amqp/internal/frames"
+)
+
+// CaptureFileOptions contains the optional settings for NewCaptureFile.
+type CaptureFileOptions struct {
+	// MaxBackups is the number of rotated files to keep.
+	// Rotated files are named path.1 (most recent) through path.MaxBackups.
+	//
+	// Default: 3.
+	MaxBackups int
+
+	// MaxSize is the size in bytes at which the capture file is rotated.
+	//
+	// Default: 104857600 (100 MiB).
+	MaxSize int64
+}
+
+// CaptureFile records the raw frames sent and received by connections,
+// with timestamps and direction markers, for offline analysis. Frames
+// are captured after TLS decryption. Use cmd/amqpcapture to decode it.
+//
+// A CaptureFile can be shared by multiple connections; records are tagged
+// with the connection's container ID. See ConnOptions.Capture.
+//
+// Capture files contain message payloads and SASL credentials in clear text.
+type CaptureFile struct {
+	path       string
+	maxBackups int
+	maxSize    int64
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	buf  []byte
+	err  error // first write error, capturing stops once set
+}
+
+// NewCaptureFile creates a capture file at path. An existing file at
+// path is rotated.
+//
+// opts: pass nil to accept the default values.
+func NewCaptureFile(path string, opts *CaptureFileOptions) (*CaptureFile, error) {
+	cf := &CaptureFile{
+		path:       path,
+		maxBackups: 3,
+		maxSize:    100 * 1024 * 1024,
+	}
+	if opts != nil {
+		if opts.MaxBackups < 0 {
+			return nil, fmt.Errorf("invalid MaxBackups value %d", opts.MaxBackups)
+		} else if opts.MaxBackups > 0 {
+			cf.maxBackups = opts.MaxBackups
+		}
+		if opts.MaxSize < 0 {
+			return nil, fmt.Errorf("invalid MaxSize value %d", opts.MaxSize)
+		} else if opts.MaxSize > 0 {
+			cf.maxSize = opts.MaxSize
+		}
+	}
+
+	if fi, err := os.Stat(path); err == nil && fi.Size() > 0 {
+		if err := cf.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := cf.open(); err != nil {
+		return nil, err
+	}
+	return cf, nil
+}
+
+// open creates a new file at cf.path and writes the capture header.
+func (cf *CaptureFile) open() error {
+	f, err := os.OpenFile(cf.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(capture.Magic); err != nil {
+		f.Close()
+		return err
+	}
+	cf.f = f
+	cf.size = int64(len(capture.Magic))
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N, ..., path to path.1, discarding the oldest.
+func (cf *CaptureFile) rotate() error {
+	for i := cf.maxBackups; i > 0; i-- {
+		src := cf.path
+		if i > 1 {
+			src = fmt.Sprintf("%s.%d", cf.path, i-1)
+		}
+		if err := os.Rename(src, fmt.Sprintf("%s.%d", cf.path, i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if cf.maxBackups == 0 {
+		return os.Remove(cf.path)
+	}
+	return nil
+}
+
+// record appends a frame to the capture file.
+// Write errors stop the capture and are reported by Close.
+func (cf *CaptureFile) record(dir capture.Direction, connID string, frame []byte) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if cf.err != nil || cf.f == nil {
+		return
+	}
+
+	cf.buf = capture.Append(cf.buf[:0], capture.Record{
+		Time:      time.Now(),
+		Direction: dir,
+		ConnID:    connID,
+		Frame:     frame,
+	})
+
+	if cf.size > int64(len(capture.Magic)) && cf.size+int64(len(cf.buf)) > cf.maxSize {
+		if cf.err = cf.f.Close(); cf.err != nil {
+			return
+		}
+		cf.f = nil
+		if cf.err = cf.rotate(); cf.err != nil {
+			return
+		}
+		if cf.err = cf.open(); cf.err != nil {
+			return
+		}
+	}
+
+	n, err := cf.f.Write(cf.buf)
+	cf.size += int64(n)
+	cf.err = err
+}
+
+// Close flushes and closes the capture file. Frames sent or received
+// afterwards aren't recorded. It returns the first error encountered
+// while writing, if any.
+func (cf *CaptureFile) Close() error {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if cf.f == nil {
+		return cf.err
+	}
+	err := cf.f.Close()
+	cf.f = nil
+	if cf.err != nil {
+		return cf.err
+	}
+	return err
+}
+
+// captureInbound records a received frame, reassembling its header.
+func (c *Conn) captureInbound(h frames.Header, body []byte) {
+	if c.capture == nil {
+		return
+	}
+	frame := make([]byte, frames.HeaderSize, frames.HeaderSize+len(body))
+	binary.BigEndian.PutUint32(frame[0:4], h.Size)
+	frame[4] = h.DataOffset
+	frame[5] = h.FrameType
+	binary.BigEndian.PutUint16(frame[6:8], h.Channel)
+	c.capture.record(capture.Inbound, c.containerID, append(frame, body...))
+}
+
+// captureOutbound records a sent frame.
+func (c *Conn) captureOutbound(frame []byte) {
+	if c.capture == nil {
+		return
+	}
+	c.capture.record(capture.Outbound, c.containerID, frame)
+}
@@ -0,0 +1,112 @@
+package amqp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/go-amqp/internal/buffer"
+	"github.com/Azure/go-amqp/internal/capture"
+	"github.com/Azure/go-amqp/internal/frames"
+	"github.com/Azure/go-amqp/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func readCaptureFile(t *testing.T, path string) []capture.Record {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	r, err := capture.NewReader(f)
+	require.NoError(t, err)
+	var recs []capture.Record
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return recs
+		}
+		require.NoError(t, err)
+		recs = append(recs, rec)
+	}
+}
+
+func TestConnCapture(t *testing.T) {
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch req.(type) {
+		case *mocks.AMQPProto:
+			return []byte{'A', 'M', 'Q', 'P', 0, 1, 0, 0}, nil
+		case *frames.PerformOpen:
+			return mocks.PerformOpen("container")
+		case *frames.PerformClose:
+			return mocks.PerformClose(nil)
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "conn.amqpcap")
+	cf, err := NewCaptureFile(path, nil)
+	require.NoError(t, err)
+
+	conn, err := NewConn(mocks.NewNetConn(responder), &ConnOptions{
+		Capture:     cf,
+		ContainerID: "my-container",
+	})
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.NoError(t, cf.Close())
+
+	recs := readCaptureFile(t, path)
+	require.GreaterOrEqual(t, len(recs), 3)
+	for i, dir := range []capture.Direction{capture.Outbound, capture.Inbound, capture.Outbound} {
+		require.Equal(t, dir, recs[i].Direction)
+		require.Equal(t, "my-container", recs[i].ConnID)
+		require.False(t, recs[i].Time.IsZero())
+	}
+
+	// the captured bytes are complete frames
+	buf := buffer.New(recs[1].Frame)
+	hdr, err := frames.ParseHeader(buf)
+	require.NoError(t, err)
+	require.EqualValues(t, len(recs[1].Frame), hdr.Size)
+	body, err := frames.ParseBody(buf)
+	require.NoError(t, err)
+	open, ok := body.(*frames.PerformOpen)
+	require.True(t, ok)
+	require.Equal(t, "container", open.ContainerID)
+}
+
+func TestCaptureFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rotate.amqpcap")
+	require.NoError(t, os.WriteFile(path, []byte("previous"), 0o600))
+
+	cf, err := NewCaptureFile(path, &CaptureFileOptions{MaxBackups: 2, MaxSize: 64})
+	require.NoError(t, err)
+
+	// the existing file is rotated on creation
+	prev, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	require.Equal(t, "previous", string(prev))
+
+	frame := make([]byte, 40)
+	for i := 0; i < 4; i++ {
+		frame[0] = byte(i)
+		cf.record(capture.Outbound, "c", frame)
+	}
+	require.NoError(t, cf.Close())
+
+	// each record fills a file so only the newest ones remain
+	for suffix, want := range map[string]byte{"": 3, ".1": 2, ".2": 1} {
+		recs := readCaptureFile(t, path+suffix)
+		require.Len(t, recs, 1)
+		require.Equal(t, want, recs[0].Frame[0])
+	}
+	_, err = os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err))
+
+	_, err = NewCaptureFile(path, &CaptureFileOptions{MaxSize: -1})
+	require.Error(t, err)
+}
@@ -0,0 +1,117 @@
+// Package cbs implements the put-token operation of AMQP Claims-Based
+// Security (CBS), used to authorize the links of a connection to
+// services such as Azure Event Hubs and Service Bus.
+//
+// Tokens are sent to the $cbs node of the peer for an audience, usually the
+// URL of the entity links are attached to, and must be put again before
+// they expire. Renewing tokens is left to the caller.
+//
+//	client, err := cbs.NewClient(ctx, session, nil)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer client.Close(ctx)
+//	err = client.PutToken(ctx, "amqps://ns.servicebus.windows.net/queue", cbs.Token{
+//		Type:       cbs.TokenTypeJWT,
+//		Value:      jwt,
+//		Expiration: expiresOn,
+//	})
+package cbs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/management"
+)
+
+// Address is the address of the CBS node.
+const Address = "$cbs"
+
+// Token types accepted by Azure services.
+const (
+	TokenTypeJWT = "jwt"
+	TokenTypeSAS = "servicebus.windows.net:sastoken"
+)
+
+// Token is a security token put to the CBS node.
+type Token struct {
+	// Type is the type of the token, such as TokenTypeJWT.
+	Type string
+
+	// Value is the token.
+	Value string
+
+	// Expiration is the time the token expires, sent in the "expiration"
+	// application property in seconds since the Unix epoch.
+	// It's omitted if zero.
+	Expiration time.Time
+}
+
+// ClientOptions contains the optional settings for configuring a Client.
+type ClientOptions struct {
+	// Address is the address of the CBS node.
+	//
+	// Default: Address.
+	Address string
+
+	// Timeout is the maximum time PutToken waits for the response, in
+	// addition to the deadline of the context passed to it.
+	//
+	// Default: 0 (no timeout).
+	Timeout time.Duration
+}
+
+// Client puts tokens to the CBS node of a connection.
+//
+// A Client is safe for concurrent use by multiple goroutines.
+type Client struct {
+	mgmt *management.Client
+}
+
+// NewClient attaches the links of a Client to the CBS node on session.
+//
+// opts: pass nil to accept the default values.
+func NewClient(ctx context.Context, session *amqp.Session, opts *ClientOptions) (*Client, error) {
+	mgmtOpts := &management.ClientOptions{Address: Address}
+	if opts != nil {
+		if opts.Address != "" {
+			mgmtOpts.Address = opts.Address
+		}
+		mgmtOpts.Timeout = opts.Timeout
+	}
+	mgmt, err := management.NewClient(ctx, session, mgmtOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{mgmt: mgmt}, nil
+}
+
+// Close detaches the links of the client.
+func (c *Client) Close(ctx context.Context) error {
+	return c.mgmt.Close(ctx)
+}
+
+// PutToken puts token to the CBS node for audience. If the node refuses
+// the token, a *management.StatusError is returned.
+func (c *Client) PutToken(ctx context.Context, audience string, token Token) error {
+	if token.Type == "" {
+		return errors.New("cbs: missing token type")
+	}
+	req := &management.Request{
+		Operation: "put-token",
+		Type:      token.Type,
+		Name:      audience,
+		Body:      token.Value,
+	}
+	if !token.Expiration.IsZero() {
+		req.Properties = map[string]any{
+			"expiration": strconv.FormatInt(token.Expiration.Unix(), 10),
+		}
+	}
+	_, err := c.mgmt.Do(ctx, req)
+	return err
+}
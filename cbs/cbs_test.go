@@ -0,0 +1,111 @@
+package cbs
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/management"
+	"github.com/stretchr/testify/require"
+)
+
+// serveTestCBS serves a CBS node accepting the tokens valid for "audience"
+// on a single connection, and returns the session of a client connected to it.
+func serveTestCBS(t *testing.T) *amqp.Session {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := amqp.NewListener(ln, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	go func() {
+		conn, err := l.Accept(ctx)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sreq, err := conn.NextSession(ctx)
+		if err != nil {
+			return
+		}
+		session, err := sreq.Accept(nil)
+		if err != nil {
+			return
+		}
+
+		var requests *amqp.Receiver
+		var responses *amqp.Sender
+		for requests == nil || responses == nil {
+			req, err := session.NextLink(ctx)
+			if err != nil {
+				return
+			}
+			if req.Receiver {
+				requests, err = req.AcceptReceiver(&amqp.ReceiverOptions{Credit: 10})
+			} else {
+				responses, err = req.AcceptSender(nil)
+			}
+			if err != nil {
+				return
+			}
+		}
+
+		for {
+			msg, err := requests.Receive(ctx)
+			if err != nil {
+				return
+			}
+			_ = requests.AcceptMessage(ctx, msg)
+			status := http.StatusAccepted
+			props := msg.ApplicationProperties
+			if props["operation"] != "put-token" || props["type"] != TokenTypeJWT ||
+				props["name"] != "audience" || props["expiration"] != "1700000000" || msg.Value != "token" {
+				status = http.StatusUnauthorized
+			}
+			resp := &amqp.Message{
+				Properties: &amqp.MessageProperties{CorrelationID: msg.Properties.MessageID},
+				ApplicationProperties: map[string]any{
+					"status-code":        int32(status),
+					"status-description": http.StatusText(status),
+				},
+			}
+			if err := responses.Send(ctx, resp); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn, err := amqp.Dial("amqp://"+l.Addr().String(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	return session
+}
+
+func TestPutToken(t *testing.T) {
+	session := serveTestCBS(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := NewClient(ctx, session, nil)
+	require.NoError(t, err)
+	defer client.Close(ctx)
+
+	token := Token{
+		Type:       TokenTypeJWT,
+		Value:      "token",
+		Expiration: time.Unix(1700000000, 0),
+	}
+	require.NoError(t, client.PutToken(ctx, "audience", token))
+
+	err = client.PutToken(ctx, "other", token)
+	var statusErr *management.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+}
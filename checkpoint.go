@@ -0,0 +1,173 @@
+package amqp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Checkpoint is the position of the last message processed from a source,
+// read from the annotations set by brokers such as Azure Event Hubs.
+type Checkpoint struct {
+	// SequenceNumber is the x-opt-sequence-number annotation of the message.
+	SequenceNumber int64
+
+	// Offset is the x-opt-offset annotation of the message, if any.
+	Offset string
+
+	// EnqueuedTime is the x-opt-enqueued-time annotation of the message,
+	// if any.
+	EnqueuedTime time.Time
+}
+
+// Message annotations read by CheckpointFromMessage.
+const (
+	annotationSequenceNumber = "x-opt-sequence-number"
+	annotationOffset         = "x-opt-offset"
+	annotationEnqueuedTime   = "x-opt-enqueued-time"
+)
+
+// CheckpointFromMessage returns the Checkpoint of msg, and false if it
+// doesn't have a sequence number.
+func CheckpointFromMessage(msg *Message) (Checkpoint, bool) {
+	seq, ok := msg.Annotations[annotationSequenceNumber].(int64)
+	if !ok {
+		return Checkpoint{}, false
+	}
+	cp := Checkpoint{SequenceNumber: seq}
+	switch offset := msg.Annotations[annotationOffset].(type) {
+	case string:
+		cp.Offset = offset
+	case int64:
+		cp.Offset = fmt.Sprint(offset)
+	}
+	if t, ok := msg.Annotations[annotationEnqueuedTime].(time.Time); ok {
+		cp.EnqueuedTime = t
+	}
+	return cp, true
+}
+
+// Filter returns the selector filter starting a receiver after the message
+// of the Checkpoint.
+func (cp Checkpoint) Filter() LinkFilter {
+	return NewSelectorFilter(fmt.Sprintf("amqp.annotation.%s > %d", annotationSequenceNumber, cp.SequenceNumber))
+}
+
+// CheckpointStore persists the Checkpoint of the last message processed
+// from each source. See ConsumerOptions.Checkpoints.
+//
+// Implementations must be safe for concurrent use.
+type CheckpointStore interface {
+	// Load returns the Checkpoint saved for source, and false if there's none.
+	Load(ctx context.Context, source string) (Checkpoint, bool, error)
+
+	// Save saves cp as the Checkpoint of source.
+	Save(ctx context.Context, source string, cp Checkpoint) error
+}
+
+// CheckpointFilters returns the filters starting a receiver on source after
+// the Checkpoint saved in store, or nil if there's none.
+func CheckpointFilters(ctx context.Context, store CheckpointStore, source string) ([]LinkFilter, error) {
+	cp, ok, err := store.Load(ctx, source)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return []LinkFilter{cp.Filter()}, nil
+}
+
+// CheckpointOptions configures the checkpoints saved by a Consumer.
+// See ConsumerOptions.Checkpoints.
+type CheckpointOptions struct {
+	// Store is where the checkpoints are saved.
+	Store CheckpointStore
+
+	// Source is the source the checkpoints are saved for.
+	Source string
+
+	// Interval is the number of messages handled between checkpoints.
+	//
+	// Default: 1.
+	Interval int
+}
+
+// FileCheckpointStore is a CheckpointStore saving the checkpoints of all
+// sources to a JSON file, replaced on each Save.
+type FileCheckpointStore struct {
+	path string
+
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+// NewFileCheckpointStore opens the CheckpointStore at path. The file is
+// created by the first Save if it doesn't exist.
+func NewFileCheckpointStore(path string) (*FileCheckpointStore, error) {
+	s := &FileCheckpointStore{
+		path:        path,
+		checkpoints: map[string]Checkpoint{},
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.checkpoints); err != nil {
+		return nil, fmt.Errorf("amqp: reading checkpoints from %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Load implements the CheckpointStore interface for FileCheckpointStore.
+func (s *FileCheckpointStore) Load(ctx context.Context, source string) (Checkpoint, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, ok := s.checkpoints[source]
+	return cp, ok, nil
+}
+
+// Save implements the CheckpointStore interface for FileCheckpointStore.
+func (s *FileCheckpointStore) Save(ctx context.Context, source string, cp Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, existed := s.checkpoints[source]
+	s.checkpoints[source] = cp
+	if err := s.write(); err != nil {
+		if existed {
+			s.checkpoints[source] = prev
+		} else {
+			delete(s.checkpoints, source)
+		}
+		return err
+	}
+	return nil
+}
+
+// write replaces the file with the checkpoints, once they're written.
+func (s *FileCheckpointStore) write() error {
+	data, err := json.Marshal(s.checkpoints)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,17 @@
+package amqp
+
+import (
+	"github.com/Azure/go-amqp/internal/clock"
+)
+
+// Clock provides the current time and the timers used by a connection, its
+// sessions, and its links, such as keepalives, idle and connect timeouts,
+// disposition batching, and stall detection.
+//
+// Set ConnOptions.Clock to a mocks.FakeClock to advance time deterministically
+// in tests. Read and write deadlines are computed with the Clock, but it's up
+// to the net.Conn to enforce them, as mocks.NetConn does.
+type Clock = clock.Clock
+
+// Timer is a single event timer created by a Clock.
+type Timer = clock.Timer
@@ -0,0 +1,55 @@
+// Command amqpinterop runs the conformance scenarios of package interop
+// against a broker and prints their results.
+//
+// Usage:
+//
+//	amqpinterop [-timeout duration] [-prefix prefix] url
+//
+// The URL is passed to amqp.Dial, so credentials in its user info are used
+// for SASL PLAIN authentication. It exits with status 1 if any scenario fails.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Azure/go-amqp/interop"
+)
+
+func main() {
+	timeout := flag.Duration("timeout", 30*time.Second, "the timeout of each scenario")
+	prefix := flag.String("prefix", "", "the prefix of the node addresses, followed by the scenario name (default: interop.<name>.<random suffix>)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-timeout duration] [-prefix prefix] url\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := &interop.Options{Timeout: *timeout}
+	if *prefix != "" {
+		opts.Address = func(scenario string) string {
+			return *prefix + scenario
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := interop.Run(ctx, flag.Arg(0), opts)
+	_, _ = report.WriteTo(os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if !report.Passed() {
+		os.Exit(1)
+	}
+}
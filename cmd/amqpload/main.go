@@ -0,0 +1,65 @@
+// Command amqpload generates load against a broker with package loadgen
+// and prints the resulting throughput and latencies.
+//
+// Usage:
+//
+//	amqpload [flags] url address
+//
+// The URL is passed to amqp.Dial, so credentials in its user info are used
+// for SASL PLAIN authentication. Messages are sent to and received from the
+// node at address. It exits with status 1 if any message failed to be sent
+// or received.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/loadgen"
+)
+
+func main() {
+	opts := &loadgen.Options{}
+	flag.IntVar(&opts.Senders, "senders", 1, "the number of senders")
+	flag.IntVar(&opts.Receivers, "receivers", 1, "the number of receivers, or -1 to only send messages")
+	flag.IntVar(&opts.MessageSize, "size", 1024, "the size of the messages, in bytes")
+	flag.Float64Var(&opts.Rate, "rate", 0, "the total number of messages sent per second, or 0 to send as fast as possible")
+	flag.DurationVar(&opts.Duration, "duration", 10*time.Second, "how long to send messages")
+	flag.DurationVar(&opts.DrainTimeout, "drain", 5*time.Second, "how long to wait for messages in flight once sending stops")
+	settled := flag.Bool("settled", false, "send messages pre-settled")
+	credit := flag.Uint("credit", 0, "the link credit of the receivers (default: the receiver's default)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] url address\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *settled {
+		opts.SenderOptions = &amqp.SenderOptions{SettlementMode: amqp.SenderSettleModeSettled.Ptr()}
+	}
+	if *credit > 0 {
+		opts.ReceiverOptions = &amqp.ReceiverOptions{Credit: uint32(*credit)}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	res, err := loadgen.Run(ctx, flag.Arg(0), flag.Arg(1), opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	_, _ = res.WriteTo(os.Stdout)
+	if res.SendErrors > 0 || res.ReceiveErrors > 0 {
+		os.Exit(1)
+	}
+}
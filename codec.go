@@ -0,0 +1,132 @@
+package amqp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+)
+
+// BodyCodec encodes and decodes message bodies for a content type.
+//
+// Implementations must be safe for concurrent use.
+type BodyCodec interface {
+	// Marshal returns the encoding of v.
+	Marshal(v any) ([]byte, error)
+
+	// Unmarshal decodes data into the value pointed to by v.
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]BodyCodec{
+		"application/json": jsonCodec{},
+	}
+)
+
+// RegisterBodyCodec registers codec for the specified content type,
+// replacing any codec previously registered for it.
+//
+// Content types are matched on the media type only; parameters
+// such as charset are ignored. A codec for application/json is
+// registered by default.
+func RegisterBodyCodec(contentType string, codec BodyCodec) {
+	if codec == nil {
+		panic("amqp: RegisterBodyCodec codec is nil")
+	}
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[mediaType(contentType)] = codec
+}
+
+// LookupBodyCodec returns the codec registered for the specified content type.
+func LookupBodyCodec(contentType string) (BodyCodec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[mediaType(contentType)]
+	return codec, ok
+}
+
+// mediaType returns the normalized media type of contentType without parameters.
+func mediaType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// NewMessageWithBody returns a *Message with v encoded as its single data
+// payload, using the codec registered for contentType.
+//
+// The message's Properties.ContentType is set to contentType.
+func NewMessageWithBody(contentType string, v any) (*Message, error) {
+	msg := &Message{}
+	if err := msg.EncodeBody(contentType, v); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// EncodeBody replaces the message's body with v encoded as a single data
+// payload, using the codec registered for contentType.
+//
+// Properties.ContentType is set to contentType. Any Value or Sequence
+// body is cleared.
+func (m *Message) EncodeBody(contentType string, v any) error {
+	codec, ok := LookupBodyCodec(contentType)
+	if !ok {
+		return fmt.Errorf("amqp: no body codec registered for content-type %q", contentType)
+	}
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if m.Properties == nil {
+		m.Properties = &MessageProperties{}
+	}
+	m.Properties.ContentType = &contentType
+	m.Data = [][]byte{data}
+	m.Value = nil
+	m.Sequence = nil
+	return nil
+}
+
+// DecodeBody decodes the message's data payload into the value pointed
+// to by v, using the codec registered for Properties.ContentType.
+//
+// Multiple data sections are concatenated before decoding.
+func (m *Message) DecodeBody(v any) error {
+	if m.Properties == nil || m.Properties.ContentType == nil {
+		return errors.New("amqp: message has no content-type")
+	}
+	contentType := *m.Properties.ContentType
+	codec, ok := LookupBodyCodec(contentType)
+	if !ok {
+		return fmt.Errorf("amqp: no body codec registered for content-type %q", contentType)
+	}
+	if m.Data == nil {
+		return errors.New("amqp: message has no data payload")
+	}
+
+	data := m.GetData()
+	if len(m.Data) > 1 {
+		data = bytes.Join(m.Data, nil)
+	}
+	return codec.Unmarshal(data, v)
+}
+
+// jsonCodec is the BodyCodec for application/json.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,59 @@
+// Package cbor provides an amqp.BodyCodec for CBOR (RFC 8949) message bodies.
+//
+// Importing this package registers the codec for ContentType:
+//
+//	import _ "github.com/Azure/go-amqp/codec/cbor"
+//
+// It is a separate module so that applications that don't use CBOR
+// do not depend on github.com/fxamacker/cbor.
+package cbor
+
+import (
+	amqp "github.com/Azure/go-amqp"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ContentType is the content-type the codec is registered for.
+const ContentType = "application/cbor"
+
+func init() {
+	amqp.RegisterBodyCodec(ContentType, Codec{})
+}
+
+// Codec is an amqp.BodyCodec for CBOR.
+//
+// The zero value uses the default encoding and decoding options of
+// github.com/fxamacker/cbor/v2. Use NewCodec to customize them.
+type Codec struct {
+	enc cbor.EncMode
+	dec cbor.DecMode
+}
+
+// NewCodec creates a Codec with the specified options.
+func NewCodec(encOpts cbor.EncOptions, decOpts cbor.DecOptions) (Codec, error) {
+	enc, err := encOpts.EncMode()
+	if err != nil {
+		return Codec{}, err
+	}
+	dec, err := decOpts.DecMode()
+	if err != nil {
+		return Codec{}, err
+	}
+	return Codec{enc: enc, dec: dec}, nil
+}
+
+// Marshal implements the amqp.BodyCodec interface.
+func (c Codec) Marshal(v any) ([]byte, error) {
+	if c.enc == nil {
+		return cbor.Marshal(v)
+	}
+	return c.enc.Marshal(v)
+}
+
+// Unmarshal implements the amqp.BodyCodec interface.
+func (c Codec) Unmarshal(data []byte, v any) error {
+	if c.dec == nil {
+		return cbor.Unmarshal(data, v)
+	}
+	return c.dec.Unmarshal(data, v)
+}
@@ -0,0 +1,50 @@
+package cbor
+
+import (
+	"testing"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type payload struct {
+	Name  string
+	Count int
+	Tags  []string
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	in := payload{Name: "foo", Count: 2, Tags: []string{"a", "b"}}
+
+	msg, err := amqp.NewMessageWithBody(ContentType, in)
+	require.NoError(t, err)
+	require.Equal(t, ContentType, *msg.Properties.ContentType)
+
+	var out payload
+	require.NoError(t, msg.DecodeBody(&out))
+	require.Equal(t, in, out)
+}
+
+func TestNewCodec(t *testing.T) {
+	codec, err := NewCodec(cbor.CanonicalEncOptions(), cbor.DecOptions{DupMapKey: cbor.DupMapKeyEnforcedAPF})
+	require.NoError(t, err)
+
+	data, err := codec.Marshal(map[string]int{"b": 2, "a": 1})
+	require.NoError(t, err)
+	var m map[string]int
+	require.NoError(t, codec.Unmarshal(data, &m))
+	require.Equal(t, map[string]int{"a": 1, "b": 2}, m)
+
+	// canonical encoding sorts the map keys: {"a": 1, "b": 2}
+	require.Equal(t, []byte{0xa2, 0x61, 'a', 0x01, 0x61, 'b', 0x02}, data)
+
+	// {"a": 1, "a": 2}
+	dup := []byte{0xa2, 0x61, 'a', 0x01, 0x61, 'a', 0x02}
+	require.NoError(t, Codec{}.Unmarshal(dup, &m))
+	var dupErr *cbor.DupMapKeyError
+	require.ErrorAs(t, codec.Unmarshal(dup, &m), &dupErr)
+
+	_, err = NewCodec(cbor.EncOptions{Sort: 100}, cbor.DecOptions{})
+	require.Error(t, err)
+}
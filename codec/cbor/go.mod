@@ -0,0 +1,16 @@
+module github.com/Azure/go-amqp/codec/cbor
+
+go 1.18
+
+require (
+	github.com/Azure/go-amqp v0.18.1
+	github.com/fxamacker/cbor/v2 v2.4.0
+	github.com/stretchr/testify v1.7.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+)
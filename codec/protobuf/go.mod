@@ -0,0 +1,15 @@
+module github.com/Azure/go-amqp/codec/protobuf
+
+go 1.18
+
+require (
+	github.com/Azure/go-amqp v0.18.1
+	github.com/stretchr/testify v1.7.1
+	google.golang.org/protobuf v1.28.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+)
@@ -0,0 +1,50 @@
+// Package protobuf provides an amqp.BodyCodec for Protocol Buffers message bodies.
+//
+// Importing this package registers the codec for ContentType:
+//
+//	import _ "github.com/Azure/go-amqp/codec/protobuf"
+//
+// It is a separate module so that applications that don't use protobuf
+// do not depend on google.golang.org/protobuf.
+package protobuf
+
+import (
+	"fmt"
+
+	amqp "github.com/Azure/go-amqp"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is the content-type the codec is registered for.
+const ContentType = "application/x-protobuf"
+
+func init() {
+	amqp.RegisterBodyCodec(ContentType, Codec{})
+}
+
+// Codec is an amqp.BodyCodec that encodes values implementing proto.Message.
+type Codec struct {
+	// MarshalOptions are used when encoding. Defaults to the zero value.
+	MarshalOptions proto.MarshalOptions
+
+	// UnmarshalOptions are used when decoding. Defaults to the zero value.
+	UnmarshalOptions proto.UnmarshalOptions
+}
+
+// Marshal implements the amqp.BodyCodec interface.
+func (c Codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %T does not implement proto.Message", v)
+	}
+	return c.MarshalOptions.Marshal(m)
+}
+
+// Unmarshal implements the amqp.BodyCodec interface.
+func (c Codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T does not implement proto.Message", v)
+	}
+	return c.UnmarshalOptions.Unmarshal(data, m)
+}
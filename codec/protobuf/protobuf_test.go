@@ -0,0 +1,42 @@
+package protobuf
+
+import (
+	"testing"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	in, err := structpb.NewStruct(map[string]any{
+		"name":  "foo",
+		"count": 2,
+		"tags":  []any{"a", "b"},
+	})
+	require.NoError(t, err)
+
+	msg, err := amqp.NewMessageWithBody(ContentType, in)
+	require.NoError(t, err)
+	require.Equal(t, ContentType, *msg.Properties.ContentType)
+
+	out := &structpb.Struct{}
+	require.NoError(t, msg.DecodeBody(out))
+	require.True(t, proto.Equal(in, out))
+}
+
+func TestCodecNotProtoMessage(t *testing.T) {
+	_, err := amqp.NewMessageWithBody(ContentType, "foo")
+	require.Error(t, err)
+
+	data, err := Codec{}.Marshal(wrapperspb.String("foo"))
+	require.NoError(t, err)
+	var s string
+	require.Error(t, Codec{}.Unmarshal(data, &s))
+
+	out := &wrapperspb.StringValue{}
+	require.NoError(t, Codec{}.Unmarshal(data, out))
+	require.Equal(t, "foo", out.GetValue())
+}
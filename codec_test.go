@@ -0,0 +1,70 @@
+package amqp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type upperCodec struct{}
+
+func (upperCodec) Marshal(v any) ([]byte, error) {
+	return []byte(strings.ToUpper(v.(string))), nil
+}
+
+func (upperCodec) Unmarshal(data []byte, v any) error {
+	*v.(*string) = strings.ToLower(string(data))
+	return nil
+}
+
+func TestBodyCodecJSON(t *testing.T) {
+	type payload struct {
+		Name  string
+		Count int
+	}
+
+	msg, err := NewMessageWithBody("application/json", payload{Name: "foo", Count: 2})
+	require.NoError(t, err)
+	require.Equal(t, "application/json", *msg.Properties.ContentType)
+	require.Equal(t, `{"Name":"foo","Count":2}`, string(msg.GetData()))
+
+	// content-type parameters are ignored and data sections are concatenated
+	ct := "Application/JSON; charset=utf-8"
+	msg.Properties.ContentType = &ct
+	msg.Data = [][]byte{[]byte(`{"Name":"bar",`), []byte(`"Count":3}`)}
+	var p payload
+	require.NoError(t, msg.DecodeBody(&p))
+	require.Equal(t, payload{Name: "bar", Count: 3}, p)
+
+	var syntaxErr *json.SyntaxError
+	msg.Data = [][]byte{[]byte("{")}
+	require.ErrorAs(t, msg.DecodeBody(&p), &syntaxErr)
+}
+
+func TestBodyCodecErrors(t *testing.T) {
+	var v string
+	require.Error(t, NewMessage([]byte("x")).DecodeBody(&v))
+
+	_, err := NewMessageWithBody("application/x-unknown", "x")
+	require.Error(t, err)
+
+	ct := "application/x-unknown"
+	msg := &Message{Properties: &MessageProperties{ContentType: &ct}, Data: [][]byte{{1}}}
+	require.Error(t, msg.DecodeBody(&v))
+}
+
+func TestRegisterBodyCodec(t *testing.T) {
+	RegisterBodyCodec("text/x-upper", upperCodec{})
+
+	msg, err := NewMessageWithBody("text/x-upper", "hello")
+	require.NoError(t, err)
+	require.Equal(t, []byte("HELLO"), msg.GetData())
+
+	var s string
+	require.NoError(t, msg.DecodeBody(&s))
+	require.Equal(t, "hello", s)
+
+	require.Panics(t, func() { RegisterBodyCodec("text/x-nil", nil) })
+}
@@ -0,0 +1,156 @@
+package amqp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math"
+)
+
+// Compression compresses and decompresses message data payloads.
+//
+// Implementations must be safe for concurrent use.
+type Compression interface {
+	// ContentEncoding returns the value recorded in the message's
+	// content-encoding property, e.g. "gzip".
+	ContentEncoding() string
+
+	// Compress returns the compressed form of data.
+	Compress(data []byte) ([]byte, error)
+
+	// Decompress returns the decompressed form of data.
+	Decompress(data []byte) ([]byte, error)
+}
+
+// LimitedDecompressor can be implemented by a Compression to stop
+// decompressing once the output exceeds a limit, so a small payload can't
+// inflate without bound before the receiver's limits are checked.
+// Otherwise, the output of Decompress is checked once it returns.
+type LimitedDecompressor interface {
+	// DecompressLimit is Decompress, returning at most limit+1 bytes.
+	DecompressLimit(data []byte, limit uint64) ([]byte, error)
+}
+
+// GzipCompression returns a Compression that uses gzip at the specified
+// compression level. See the compress/gzip package for valid levels.
+// It implements LimitedDecompressor.
+//
+// Other schemes, such as zstd, can be supported by implementing Compression.
+func GzipCompression(level int) Compression {
+	return gzipCompression{level: level}
+}
+
+type gzipCompression struct {
+	level int
+}
+
+func (gzipCompression) ContentEncoding() string {
+	return "gzip"
+}
+
+func (g gzipCompression) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, g.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g gzipCompression) Decompress(data []byte) ([]byte, error) {
+	return g.DecompressLimit(data, math.MaxInt64)
+}
+
+func (gzipCompression) DecompressLimit(data []byte, limit uint64) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if limit >= math.MaxInt64 {
+		return io.ReadAll(r)
+	}
+	return io.ReadAll(io.LimitReader(r, int64(limit)+1))
+}
+
+// compressMessage returns a shallow copy of msg with its data payloads
+// compressed. msg is returned unchanged if it has no data payloads, is
+// already content-encoded, has a raw payload, or its data is smaller than threshold.
+func compressMessage(c Compression, threshold int, msg *Message) (*Message, error) {
+	if len(msg.Data) == 0 || msg.RawPayload != nil || (msg.Properties != nil && msg.Properties.ContentEncoding != nil) {
+		return msg, nil
+	}
+
+	size := 0
+	for _, d := range msg.Data {
+		size += len(d)
+	}
+	if size < threshold {
+		return msg, nil
+	}
+
+	compressed := *msg
+	compressed.Data = make([][]byte, len(msg.Data))
+	for i, d := range msg.Data {
+		cd, err := c.Compress(d)
+		if err != nil {
+			return nil, err
+		}
+		compressed.Data[i] = cd
+	}
+
+	var props MessageProperties
+	if msg.Properties != nil {
+		props = *msg.Properties
+	}
+	encoding := c.ContentEncoding()
+	props.ContentEncoding = &encoding
+	compressed.Properties = &props
+	return &compressed, nil
+}
+
+// decompressMessage decompresses msg's data payloads in place when its
+// content-encoding matches one of cs, and clears the content-encoding.
+// A *MessageSizeError is returned if the decompressed payloads exceed
+// limit bytes, zero for no limit.
+func decompressMessage(cs []Compression, msg *Message, limit uint64) error {
+	if msg.Properties == nil || msg.Properties.ContentEncoding == nil {
+		return nil
+	}
+
+	for _, c := range cs {
+		if c.ContentEncoding() != *msg.Properties.ContentEncoding {
+			continue
+		}
+		// the message is left unchanged if decompression fails
+		data := make([][]byte, len(msg.Data))
+		var size uint64
+		for i, d := range msg.Data {
+			var dd []byte
+			var err error
+			if ld, ok := c.(LimitedDecompressor); ok && limit != 0 {
+				dd, err = ld.DecompressLimit(d, limit-size)
+			} else {
+				dd, err = c.Decompress(d)
+			}
+			if err != nil {
+				return err
+			}
+			size += uint64(len(dd))
+			if limit != 0 && size > limit {
+				return &MessageSizeError{DeliveryID: msg.deliveryID, Size: size, MaxMessageSize: limit}
+			}
+			data[i] = dd
+		}
+		msg.Data = data
+		msg.Properties.ContentEncoding = nil
+		return nil
+	}
+	return nil
+}
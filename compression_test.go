@@ -0,0 +1,128 @@
+package amqp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressMessage(t *testing.T) {
+	c := GzipCompression(gzip.BestSpeed)
+	data := bytes.Repeat([]byte("compress me "), 100)
+	subject := "subject"
+	msg := &Message{
+		Properties: &MessageProperties{Subject: &subject},
+		Data:       [][]byte{data, data},
+	}
+
+	compressed, err := compressMessage(c, 0, msg)
+	require.NoError(t, err)
+	require.NotSame(t, msg, compressed)
+	require.Equal(t, "gzip", *compressed.Properties.ContentEncoding)
+	require.Equal(t, "subject", *compressed.Properties.Subject)
+	require.Less(t, len(compressed.Data[0]), len(data))
+
+	// the original message is unmodified
+	require.Nil(t, msg.Properties.ContentEncoding)
+	require.Equal(t, data, msg.Data[0])
+
+	require.NoError(t, decompressMessage([]Compression{c}, compressed, 0))
+	require.Nil(t, compressed.Properties.ContentEncoding)
+	require.Equal(t, [][]byte{data, data}, compressed.Data)
+}
+
+func TestCompressMessageSkipped(t *testing.T) {
+	c := GzipCompression(gzip.DefaultCompression)
+
+	// below threshold
+	msg := NewMessage([]byte("small"))
+	got, err := compressMessage(c, 10, msg)
+	require.NoError(t, err)
+	require.Same(t, msg, got)
+
+	// already encoded
+	enc := "identity"
+	msg = NewMessage([]byte("data"))
+	msg.Properties = &MessageProperties{ContentEncoding: &enc}
+	got, err = compressMessage(c, 0, msg)
+	require.NoError(t, err)
+	require.Same(t, msg, got)
+
+	// no data payloads
+	msg = &Message{Value: "value"}
+	got, err = compressMessage(c, 0, msg)
+	require.NoError(t, err)
+	require.Same(t, msg, got)
+
+	// unknown encoding isn't decompressed
+	msg = NewMessage([]byte("data"))
+	msg.Properties = &MessageProperties{ContentEncoding: &enc}
+	require.NoError(t, decompressMessage([]Compression{c}, msg, 0))
+	require.Equal(t, "identity", *msg.Properties.ContentEncoding)
+
+	// corrupt payloads are reported
+	gz := "gzip"
+	msg.Properties.ContentEncoding = &gz
+	require.Error(t, decompressMessage([]Compression{c}, msg, 0))
+}
+
+func TestDecompressMessageLimit(t *testing.T) {
+	c := GzipCompression(gzip.BestCompression)
+	data := make([]byte, 1<<20)
+	compressed, err := compressMessage(c, 0, &Message{Data: [][]byte{data[:100], data}})
+	require.NoError(t, err)
+	require.Less(t, len(compressed.Data[1]), 2000)
+
+	var sizeErr *MessageSizeError
+	require.ErrorAs(t, decompressMessage([]Compression{c}, compressed, 1000), &sizeErr)
+	require.Equal(t, uint64(1000), sizeErr.MaxMessageSize)
+	// decompression stops past the limit
+	require.Equal(t, uint64(1001), sizeErr.Size)
+
+	// the payloads fit in the limit
+	require.NoError(t, decompressMessage([]Compression{c}, compressed, 100+1<<20))
+	require.Equal(t, [][]byte{data[:100], data}, compressed.Data)
+}
+
+// unlimitedCompression wraps a Compression without implementing LimitedDecompressor.
+type unlimitedCompression struct {
+	Compression
+}
+
+func TestDecompressMessageLimitUnlimitedDecompressor(t *testing.T) {
+	c := unlimitedCompression{GzipCompression(gzip.BestCompression)}
+	compressed, err := compressMessage(c, 0, NewMessage(make([]byte, 1<<20)))
+	require.NoError(t, err)
+
+	// the output is checked once Decompress returns
+	var sizeErr *MessageSizeError
+	require.ErrorAs(t, decompressMessage([]Compression{c}, compressed, 1000), &sizeErr)
+	require.Equal(t, uint64(1<<20), sizeErr.Size)
+	require.Equal(t, "gzip", *compressed.Properties.ContentEncoding)
+}
+
+func TestReceiverDecompressedSizeLimit(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		opts      *ReceiverOptions
+		remoteMax uint64
+		want      uint64
+	}{
+		{name: "default", want: defaultMaxDecompressedSize},
+		{name: "max decompressed size", opts: &ReceiverOptions{MaxDecompressedSize: 1000}, want: 1000},
+		{name: "smaller max message size", opts: &ReceiverOptions{MaxMessageSize: 1000}, want: 1000},
+		{name: "larger max message size", opts: &ReceiverOptions{MaxDecompressedSize: 1000, MaxMessageSize: 2000}, want: 1000},
+		{name: "peer's max message size", remoteMax: 500, want: 500},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newReceiver("source", nil, tt.opts)
+			require.NoError(t, err)
+			if tt.remoteMax != 0 {
+				r.l.maxMessageSize = tt.remoteMax
+			}
+			require.Equal(t, tt.want, r.decompressedSizeLimit())
+		})
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"net"
 	"net/url"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/Azure/go-amqp/internal/bitmap"
 	"github.com/Azure/go-amqp/internal/buffer"
+	"github.com/Azure/go-amqp/internal/clock"
 	"github.com/Azure/go-amqp/internal/debug"
 	"github.com/Azure/go-amqp/internal/encoding"
 	"github.com/Azure/go-amqp/internal/frames"
@@ -25,19 +28,76 @@ const (
 	defaultIdleTimeout  = 1 * time.Minute
 	defaultMaxFrameSize = 65536
 	defaultMaxSessions  = 65536
+	defaultReadQueue    = 16
+)
+
+const (
+	// idleDeadlineSlack divides the idle timeout into the period that must
+	// elapse before the read deadline is moved again. The connection is
+	// closed after between 15/16 of the idle timeout and the idle timeout
+	// without receiving anything.
+	idleDeadlineSlack = 16
+
+	// timerWheelTick is the resolution of the timers of links.
+	timerWheelTick = 10 * time.Millisecond
 )
 
 // ConnOptions contains the optional settings for configuring an AMQP connection.
 type ConnOptions struct {
+	// Capture records the raw frames sent and received on the connection.
+	// See NewCaptureFile.
+	//
+	// Default: nil (no capture).
+	Capture *CaptureFile
+
+	// Clock provides the current time and timers to the connection.
+	//
+	// Default: the system clock.
+	Clock Clock
+
 	// ContainerID sets the container-id to use when opening the connection.
 	//
 	// A container ID will be randomly generated if this option is not used.
 	ContainerID string
 
+	// DecodeLimits constrains the values accepted when decoding
+	// frames and messages received on the connection.
+	//
+	// Default: no limits.
+	DecodeLimits *DecodeLimits
+
+	// ErrorHook, when set, is called with every non-nil error before it's
+	// returned to the caller by methods on Conn, Session, Sender, and
+	// Receiver, and by Dial and NewConn. The error it returns is returned
+	// in place of the original, allowing errors to be wrapped, annotated,
+	// or normalized in one place. If it returns nil, the original error
+	// is returned.
+	//
+	// ErrorHook may be called concurrently.
+	ErrorHook func(err error) error
+
+	// FrameTrace is called with every performative sent to or received
+	// from the peer, including SASL frames, to aid wire-level debugging.
+	//
+	// It's called from the connection's network goroutines and must not block.
+	//
+	// Default: nil.
+	FrameTrace func(FrameTraceEvent)
+
 	// HostName sets the hostname sent in the AMQP
 	// Open frame and TLS ServerName (if not otherwise set).
 	HostName string
 
+	// HostnameOverride sets the hostname sent in the AMQP Open frame only,
+	// taking precedence over HostName and the host of the dialed address,
+	// which are still used for the TLS ServerName and to follow redirects.
+	//
+	// RabbitMQ selects the virtual host of the connection from it, using
+	// the "vhost:<name>" convention, e.g. "vhost:/orders".
+	//
+	// Default: "" (the hostname is the one of HostName).
+	HostnameOverride string
+
 	// IdleTimeout specifies the maximum period between
 	// receiving frames from the peer.
 	//
@@ -46,6 +106,20 @@ type ConnOptions struct {
 	// Default: 1 minute (60000000000).
 	IdleTimeout time.Duration
 
+	// LinkEventHook is called with link lifecycle events, such as attach,
+	// detach, and changes in link credit, for every link on the connection.
+	//
+	// It's called from the links' goroutines and must not block.
+	//
+	// Default: nil.
+	LinkEventHook func(LinkEvent)
+
+	// Logger receives diagnostic messages for the connection and
+	// its sessions and links.
+	//
+	// Default: nil (no logging).
+	Logger Logger
+
 	// MaxFrameSize sets the maximum frame size that
 	// the connection will accept.
 	//
@@ -60,9 +134,53 @@ type ConnOptions struct {
 	// Default: 65535.
 	MaxSessions uint16
 
+	// ReadQueueDepth is the number of frames read from the network that can
+	// wait to be processed by sessions. Once it's reached, the connection
+	// stops reading from the network until sessions catch up, so that TCP
+	// flow control slows the peer down. A negative value disables queueing,
+	// each frame must then be processed before the next one is read.
+	//
+	// Default: 16.
+	ReadQueueDepth int
+
+	// ResourceLimits caps the unsettled deliveries and buffered bytes of
+	// all the links of the connection. See also SessionOptions.ResourceLimits.
+	//
+	// Default: no limits.
+	ResourceLimits *ResourceLimits
+
+	// Metrics receives measurements for the connection and
+	// its sessions and links.
+	//
+	// Default: nil (no metrics).
+	Metrics Metrics
+
+	// Profile adjusts the client to interoperate with brokers that deviate
+	// from the AMQP specification, such as ProfileIBMMQ.
+	//
+	// Default: the zero Profile, following the specification.
+	Profile Profile
+
 	// Properties sets an entry in the connection properties map sent to the server.
 	Properties map[string]any
 
+	// ProtocolMode determines how violations of the AMQP specification
+	// by the peer are handled. Use ProtocolModeLenient to interoperate
+	// with older brokers.
+	//
+	// Default: ProtocolModeDefault.
+	ProtocolMode ProtocolMode
+
+	// Rand is the source of randomness for the generated container-id and
+	// link names. Set it to crypto/rand.Reader, or to a seeded source for
+	// reproducible tests. Delivery tags are sequential and don't use it.
+	// The loadgen and interop packages also use it for the names they
+	// generate; retry jitter and servicebus message IDs have their own
+	// Rand options.
+	//
+	// Default: a math/rand source seeded with the current time.
+	Rand io.Reader
+
 	// SASLType contains the specified SASL authentication mechanism.
 	SASLType SASLType
 
@@ -82,10 +200,91 @@ type ConnOptions struct {
 	// providing a URL scheme of "amqps://" is sufficient.
 	TLSConfig *tls.Config
 
+	// Watchdog enables a watchdog detecting the connection's internal
+	// goroutines no longer making progress, turning hangs into errors
+	// that can be diagnosed.
+	//
+	// Default: nil (disabled).
+	Watchdog *WatchdogOptions
+
+	// WriteCoalesceWindow is how long the connection waits for more frames
+	// to send after the first one, to write them to the network together.
+	// Frames already waiting to be sent are always written together, up to
+	// 64 KiB at a time, so this only helps senders producing frames at a
+	// high rate, at the cost of added latency.
+	//
+	// Default: 0 (frames are written as soon as no more are waiting).
+	WriteCoalesceWindow time.Duration
+
 	// test hook
 	dialer dialer
 }
 
+// DecodeLimits constrains the values accepted by the decoder so that hostile
+// or corrupt input can't force excessive allocations or recursion.
+//
+// A value that exceeds a limit causes decoding to fail. For frames this terminates
+// the connection; for messages the receiving link is detached.
+type DecodeLimits struct {
+	// MaxDepth is the maximum nesting depth of compound values
+	// (lists, maps, arrays, composites, and described types) within a value.
+	//
+	// Default: 0 (no limit).
+	MaxDepth int
+
+	// MaxElements is the maximum number of elements in a single list,
+	// array, or map. Map keys and values are counted individually.
+	//
+	// Performatives are encoded as lists so this value must be large
+	// enough to accommodate their fields (at most 14).
+	//
+	// Default: 0 (no limit).
+	MaxElements uint32
+
+	// MaxLength is the maximum length in bytes of a single
+	// binary, string, or symbol value. This includes message
+	// data sections.
+	//
+	// Default: 0 (no limit).
+	MaxLength uint32
+}
+
+func (d *DecodeLimits) limits() buffer.Limits {
+	if d == nil {
+		return buffer.Limits{}
+	}
+	return buffer.Limits{
+		MaxDepth:    d.MaxDepth,
+		MaxElements: d.MaxElements,
+		MaxLength:   d.MaxLength,
+	}
+}
+
+// ProtocolMode determines how violations of the AMQP specification
+// by the peer are handled.
+type ProtocolMode uint8
+
+const (
+	// ProtocolModeDefault closes the connection or session on violations
+	// that leave its state inconsistent, and logs and ignores unexpected frames.
+	ProtocolModeDefault ProtocolMode = iota
+
+	// ProtocolModeStrict closes the connection, session, or link on any
+	// violation detected, including unexpected frames.
+	ProtocolModeStrict
+
+	// ProtocolModeLenient logs and ignores the violations it can recover from:
+	//   - unknown fields at the end of performatives and their composite fields are skipped
+	//   - frames for channels without a session are dropped
+	//   - begin frames for sessions that weren't requested are dropped
+	//   - attach frames answering links that weren't requested are dropped
+	//   - flow frames without next-incoming-id after the session began are
+	//     processed as if they were sent while it was beginning
+	//
+	// The violations ignored are counted in ConnStats.ProtocolViolations.
+	ProtocolModeLenient
+)
+
 // Dial connects to an AMQP server.
 //
 // If the addr includes a scheme, it must be "amqp", "amqps", or "amqp+ssl".
@@ -98,11 +297,15 @@ type ConnOptions struct {
 func Dial(addr string, opts *ConnOptions) (*Conn, error) {
 	c, err := dialConn(addr, opts)
 	if err != nil {
-		return nil, err
+		var hook func(error) error
+		if opts != nil {
+			hook = opts.ErrorHook
+		}
+		return nil, applyErrorHook(hook, err)
 	}
 	err = c.start()
 	if err != nil {
-		return nil, err
+		return nil, c.translateErr(err)
 	}
 	return c, nil
 }
@@ -112,17 +315,40 @@ func Dial(addr string, opts *ConnOptions) (*Conn, error) {
 func NewConn(conn net.Conn, opts *ConnOptions) (*Conn, error) {
 	c, err := newConn(conn, opts)
 	if err != nil {
-		return nil, err
+		var hook func(error) error
+		if opts != nil {
+			hook = opts.ErrorHook
+		}
+		return nil, applyErrorHook(hook, err)
 	}
 	err = c.start()
 	if err != nil {
-		return nil, err
+		return nil, c.translateErr(err)
 	}
 	return c, nil
 }
 
+// applyErrorHook passes err through hook, if set.
+// The original error is returned if the hook returns nil.
+func applyErrorHook(hook func(error) error, err error) error {
+	if err == nil || hook == nil {
+		return err
+	}
+	if hookErr := hook(err); hookErr != nil {
+		return hookErr
+	}
+	return err
+}
+
+// translateErr passes err through ConnOptions.ErrorHook.
+func (c *Conn) translateErr(err error) error {
+	return applyErrorHook(c.errorHook, err)
+}
+
 // Conn is an AMQP connection.
 type Conn struct {
+	stats connStats // must be first for 64-bit atomic alignment on 32-bit platforms
+
 	net            net.Conn      // underlying connection
 	connectTimeout time.Duration // time to wait for reads/writes during conn establishment
 	dialer         dialer        // used for testing purposes, it allows faking dialing TCP/TLS endpoints
@@ -135,40 +361,73 @@ type Conn struct {
 	// SASL
 	saslHandlers map[encoding.Symbol]stateFunc // map of supported handlers keyed by SASL mechanism, SASL not negotiated if nil
 	saslComplete bool                          // SASL negotiation complete; internal *except* for SASL auth methods
+	saslServer   []saslServerMechanism         // mechanisms offered by server-side connections, SASL not negotiated if nil
+	saslIdentity string                        // identity authenticated by the client's SASL mechanism
+	authorize    func(PeerInfo) error          // authorizes clients of server-side connections, optional
 
 	// local settings
 	maxFrameSize uint32                  // max frame size to accept
 	channelMax   uint16                  // maximum number of channels to allow
 	hostname     string                  // hostname of remote server (set explicitly or parsed from URL)
+	openHostname string                  // hostname sent in the open frame when it differs from hostname
 	idleTimeout  time.Duration           // maximum period between receiving frames
 	properties   map[encoding.Symbol]any // additional properties sent upon connection open
 	containerID  string                  // set explicitly or randomly generated
+	rand         io.Reader               // source of generated IDs, nil for the package's source
+	decodeLimits buffer.Limits           // limits applied when decoding frames
+	protocolMode ProtocolMode            // how the peer's protocol violations are handled
+	profile      Profile                 // adjustments for non-conforming brokers
+	errorHook    func(error) error       // applied to errors before they're returned to callers
+	logger       Logger                  // receives diagnostic messages, never nil
+	frameTrace   func(FrameTraceEvent)   // optional callback for each performative sent or received
+	metrics      Metrics                 // receives measurements, never nil
+	capture      *CaptureFile            // optional raw frame capture
+	linkEvents   func(LinkEvent)         // optional callback for link lifecycle events
+	clock        clock.Clock             // provides the time and timers, never nil
+	timers       clock.Clock             // provides the frequently reset timers of links, never nil
+	budget       *resourceBudget         // resources held by links, nil if unlimited
+	coalesce     time.Duration           // how long to wait for more frames to write together
 
 	// peer settings
 	peerIdleTimeout  time.Duration // maximum period between sending frames
 	peerMaxFrameSize uint32        // maximum frame size peer will accept
+	peerContainerID  string        // container-id sent by peer
 
 	// conn state
 	done    chan struct{} // indicates the connection has terminated
 	doneErr error         // contains the error state returned from Close(); DO NOT TOUCH outside of conn.go until Done has been closed!
 
 	// connReader and connWriter management
-	rxtxExit  chan struct{} // signals connReader and connWriter to exit
-	closeOnce sync.Once     // ensures that close() is only called once
+	rxtxExit   chan struct{} // signals connReader and connWriter to exit
+	closeOnce  sync.Once     // ensures that close() is only called once
+	closeErrMu sync.Mutex
+	closeErr   *Error // sent to the peer in the close performative, guarded by closeErrMu
+
+	// watchdog
+	watchdog       *WatchdogOptions // nil when disabled
+	readerActivity *activity        // connReader's, nil when the watchdog is disabled
+	writerActivity *activity        // connWriter's, nil when the watchdog is disabled
+	teardownErr    error            // why the watchdog closed the connection, guarded by closeErrMu
 
 	// session tracking
 	channels            *bitmap.Bitmap
 	sessionsByChannel   map[uint16]*Session
 	sessionsByChannelMu sync.RWMutex
+	sessionReqs         chan *SessionRequest // sessions begun by the peer, nil unless server-side
 
 	// connReader
-	rxBuf  buffer.Buffer // incoming bytes buffer
-	rxDone chan struct{} // closed when connReader exits
-	rxErr  error         // contains last error reading from c.net; DO NOT TOUCH outside of connReader until rxDone has been closed!
+	rxBuf           buffer.Buffer     // incoming bytes buffer
+	rxDone          chan struct{}     // closed when connReader and connNetReader exit
+	rxErr           error             // contains last error reading from c.net; DO NOT TOUCH outside of connReader until rxDone has been closed!
+	rxQueue         chan frames.Frame // frames read by connNetReader, waiting to be processed by connReader
+	rxNetErr        error             // the error connNetReader stopped on; DO NOT TOUCH until rxQueue has been closed!
+	rxDispatchDone  chan struct{}     // closed when connReader exits
+	readDeadlineSet time.Time         // when the read deadline was last moved for the idle timeout, zero once it's cleared
 
 	// connWriter
 	txFrame chan frames.Frame // AMQP frames to be sent by connWriter
 	txBuf   buffer.Buffer     // buffer for marshaling frames before transmitting
+	txBatch []frames.Frame    // frames marshaled in txBuf by connWriter, not yet written
 	txDone  chan struct{}     // closed when connWriter exits
 	txErr   error             // contains last error writing to c.net; DO NOT TOUCH outside of connWriter until txDone has been closed!
 }
@@ -252,10 +511,13 @@ func newConn(netConn net.Conn, opts *ConnOptions) (*Conn, error) {
 		peerMaxFrameSize:  defaultMaxFrameSize,
 		channelMax:        defaultMaxSessions - 1, // -1 because channel-max starts at zero
 		idleTimeout:       defaultIdleTimeout,
-		containerID:       shared.RandString(40),
+		logger:            nopLogger{},
+		metrics:           nopMetrics{},
+		clock:             clock.Real,
 		done:              make(chan struct{}),
 		rxtxExit:          make(chan struct{}),
 		rxDone:            make(chan struct{}),
+		rxDispatchDone:    make(chan struct{}),
 		txFrame:           make(chan frames.Frame),
 		txDone:            make(chan struct{}),
 		sessionsByChannel: map[uint16]*Session{},
@@ -266,12 +528,44 @@ func newConn(netConn net.Conn, opts *ConnOptions) (*Conn, error) {
 		opts = &ConnOptions{}
 	}
 
-	if opts.ContainerID != "" {
-		c.containerID = opts.ContainerID
+	c.rand = opts.Rand
+	c.containerID = opts.ContainerID
+	if c.containerID == "" {
+		id, err := c.randString(40)
+		if err != nil {
+			return nil, fmt.Errorf("amqp: generating container-id: %w", err)
+		}
+		c.containerID = id
+	}
+	c.capture = opts.Capture
+	if opts.Clock != nil {
+		c.clock = opts.Clock
+	}
+	c.timers = clock.NewWheel(c.clock, timerWheelTick)
+	c.errorHook = opts.ErrorHook
+	c.linkEvents = opts.LinkEventHook
+	c.frameTrace = opts.FrameTrace
+	if opts.Logger != nil {
+		c.logger = opts.Logger
+	}
+	if opts.Metrics != nil {
+		c.metrics = opts.Metrics
+	}
+	if opts.DecodeLimits != nil {
+		if opts.DecodeLimits.MaxDepth < 0 {
+			return nil, fmt.Errorf("invalid DecodeLimits.MaxDepth value %d", opts.DecodeLimits.MaxDepth)
+		}
+		c.decodeLimits = opts.DecodeLimits.limits()
 	}
 	if opts.HostName != "" {
 		c.hostname = opts.HostName
 	}
+	c.openHostname = opts.HostnameOverride
+	if opts.ProtocolMode > ProtocolModeLenient {
+		return nil, fmt.Errorf("invalid ProtocolMode %d", opts.ProtocolMode)
+	}
+	c.protocolMode = opts.ProtocolMode
+	c.profile = opts.Profile
 	if opts.IdleTimeout > 0 {
 		c.idleTimeout = opts.IdleTimeout
 	} else if opts.IdleTimeout < 0 {
@@ -285,6 +579,19 @@ func newConn(netConn net.Conn, opts *ConnOptions) (*Conn, error) {
 	if opts.MaxSessions > 0 {
 		c.channelMax = opts.MaxSessions
 	}
+	readQueue := defaultReadQueue
+	if opts.ReadQueueDepth > 0 {
+		readQueue = opts.ReadQueueDepth
+	} else if opts.ReadQueueDepth < 0 {
+		readQueue = 0
+	}
+	c.rxQueue = make(chan frames.Frame, readQueue)
+	if opts.ResourceLimits != nil {
+		if err := opts.ResourceLimits.validate(); err != nil {
+			return nil, err
+		}
+		c.budget = newResourceBudget(opts.ResourceLimits, nil)
+	}
 	if opts.SASLType != nil {
 		if err := opts.SASLType(c); err != nil {
 			return nil, err
@@ -302,12 +609,31 @@ func newConn(netConn net.Conn, opts *ConnOptions) (*Conn, error) {
 	if opts.TLSConfig != nil {
 		c.tlsConfig = opts.TLSConfig.Clone()
 	}
+	if opts.Watchdog != nil {
+		if opts.Watchdog.Threshold < 0 {
+			return nil, fmt.Errorf("invalid Watchdog.Threshold value %v", opts.Watchdog.Threshold)
+		}
+		watchdog := *opts.Watchdog
+		c.watchdog = &watchdog
+	}
+	if opts.WriteCoalesceWindow < 0 {
+		return nil, fmt.Errorf("invalid WriteCoalesceWindow value %v", opts.WriteCoalesceWindow)
+	}
+	c.coalesce = opts.WriteCoalesceWindow
 	if opts.dialer != nil {
 		c.dialer = opts.dialer
 	}
 	return c, nil
 }
 
+// randString returns a base64 encoded string of n bytes read from the connection's source of randomness.
+func (c *Conn) randString(n int) (string, error) {
+	if c.rand == nil {
+		return shared.RandString(n), nil
+	}
+	return shared.RandStringFrom(c.rand, n)
+}
+
 func (c *Conn) initTLSConfig() {
 	// create a new config if not already set
 	if c.tlsConfig == nil {
@@ -323,31 +649,67 @@ func (c *Conn) initTLSConfig() {
 // start establishes the connection and begins multiplexing network IO.
 // It is an error to call Start() on a connection that's been closed.
 func (c *Conn) start() error {
+	return c.startWith(c.negotiateProto)
+}
+
+// startWith is like start but runs the connection establishment state
+// machine from the specified initial state. Server-side connections use
+// this to negotiate in the opposite direction.
+func (c *Conn) startWith(initial stateFunc) error {
 	// run connection establishment state machine
-	for state := c.negotiateProto; state != nil; {
+	for state := initial; state != nil; {
 		var err error
 		state, err = state()
 		// check if err occurred
 		if err != nil {
+			c.logger.Error("connection establishment failed", "hostname", c.hostname, "error", err)
 			close(c.txDone) // close here since connWriter hasn't been started yet
 			close(c.rxDone)
 			_ = c.Close()
 			return err
 		}
 	}
+	c.logger.Info("connection opened", "hostname", c.hostname, "containerID", c.containerID)
+	c.metrics.ConnOpened()
 
 	// we can't create the channel bitmap until the connection has been established.
 	// this is because our peer can tell us the max channels they support.
 	c.channels = bitmap.New(uint32(c.channelMax))
 
+	c.readerActivity = newActivity(c)
+	c.writerActivity = newActivity(c)
+
 	go c.connWriter()
+	go c.connNetReader()
 	go c.connReader()
+	if c.watchdog != nil {
+		go c.runWatchdog()
+	}
 
 	return nil
 }
 
+// PeerCertificate returns the certificate the peer presented during the TLS
+// handshake, or nil if TLS isn't used or the certificate wasn't verified.
+//
+// On server-side connections, client certificates are only verified when
+// ListenerOptions.TLSConfig requires it.
+func (c *Conn) PeerCertificate() *x509.Certificate {
+	tlsConn, ok := c.net.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	state := tlsConn.ConnectionState()
+	if len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
+
 // Close closes the connection.
-func (c *Conn) Close() error {
+func (c *Conn) Close() (err error) {
+	defer func() { err = c.translateErr(err) }()
+
 	c.close()
 	var connErr *ConnError
 	if errors.As(c.doneErr, &connErr) && connErr.RemoteErr == nil && connErr.inner == nil {
@@ -381,7 +743,13 @@ func (c *Conn) close() {
 			c.rxErr = nil
 		}
 
-		if c.txErr == nil && c.rxErr == nil && closeErr == nil {
+		c.closeErrMu.Lock()
+		teardownErr := c.teardownErr
+		c.closeErrMu.Unlock()
+
+		if teardownErr != nil {
+			c.doneErr = &ConnError{inner: teardownErr}
+		} else if c.txErr == nil && c.rxErr == nil && closeErr == nil {
 			// if there are no errors, it means user initiated close() and we shut down cleanly
 			c.doneErr = &ConnError{}
 		} else if amqpErr, ok := c.rxErr.(*Error); ok {
@@ -394,16 +762,37 @@ func (c *Conn) close() {
 		} else {
 			c.doneErr = &ConnError{inner: closeErr}
 		}
+
+		var closedErr error
+		if connErr := c.doneErr.(*ConnError); connErr.RemoteErr == nil && connErr.inner == nil {
+			c.logger.Info("connection closed", "hostname", c.hostname)
+		} else {
+			closedErr = c.doneErr
+			c.logger.Error("connection closed with error", "hostname", c.hostname, "error", c.doneErr)
+		}
+		// channels is only allocated once the connection has been established
+		if c.channels != nil {
+			c.metrics.ConnClosed(closedErr)
+		}
 	})
 }
 
-func (c *Conn) NewSession(ctx context.Context, opts *SessionOptions) (*Session, error) {
+func (c *Conn) NewSession(ctx context.Context, opts *SessionOptions) (_ *Session, err error) {
+	defer func() { err = c.translateErr(err) }()
+
+	if opts != nil && opts.ResourceLimits != nil {
+		if err := opts.ResourceLimits.validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	session, err := c.newSession(opts)
 	if err != nil {
 		return nil, err
 	}
 
 	if err := session.begin(ctx); err != nil {
+		c.logger.Warn("session begin failed", "channel", session.channel, "error", err)
 		return nil, err
 	}
 
@@ -434,28 +823,75 @@ func (c *Conn) deleteSession(s *Session) {
 	c.channels.Remove(uint32(s.channel))
 }
 
-// connReader reads from the net.Conn, decodes frames, and either handles
+// connNetReader reads from the net.Conn and decodes frames, queueing them
+// for connReader. When the queue is full it stops reading, so that TCP flow
+// control pushes back on the peer instead of frames piling up in memory.
+func (c *Conn) connNetReader() {
+	defer func() {
+		close(c.rxQueue)
+		// connReader owns rxErr until it exits
+		<-c.rxDispatchDone
+		close(c.rxDone)
+	}()
+
+	for {
+		fr, err := c.readFrame()
+		if err != nil {
+			c.rxNetErr = err
+			return
+		}
+
+		select {
+		case c.rxQueue <- fr:
+			continue
+		default:
+		}
+
+		// the queue is full, stop reading until connReader catches up
+		c.stats.readPaused()
+		select {
+		case c.rxQueue <- fr:
+		case <-c.rxtxExit:
+			return
+		}
+	}
+}
+
+// connReader takes the frames read by connNetReader and either handles
 // them here as appropriate or sends them to the session.rx channel.
 func (c *Conn) connReader() {
 	defer func() {
-		close(c.rxDone)
+		close(c.rxDispatchDone)
 		c.close()
 	}()
 
 	var sessionsByRemoteChannel = make(map[uint16]*Session)
 	var err error
 	for {
+		c.readerActivity.end()
 		if err != nil {
 			debug.Log(1, "connReader terminal error: %v", err)
+			c.logger.Debug("connection reader stopped", "hostname", c.hostname, "error", err)
+			var sizeErr *FrameSizeError
+			if errors.As(err, &sizeErr) {
+				c.closeErrMu.Lock()
+				c.closeErr = &Error{Condition: ErrCondFrameSizeTooLarge, Description: sizeErr.Error()}
+				c.closeErrMu.Unlock()
+			}
 			c.rxErr = err
 			return
 		}
 
-		var fr frames.Frame
-		fr, err = c.readFrame()
-		if err != nil {
+		fr, more := <-c.rxQueue
+		if !more {
+			err = c.rxNetErr
+			if err == nil {
+				// connNetReader stopped because the connection is closing
+				return
+			}
 			continue
 		}
+		c.readerActivity.begin()
 
 		var (
 			session *Session
@@ -476,17 +912,33 @@ func (c *Conn) connReader() {
 
 		// RemoteChannel should be used when frame is Begin
 		case *frames.PerformBegin:
+			if body.RemoteChannel == nil && c.sessionReqs != nil {
+				// the peer is beginning a session on a server-side connection
+				session, ok = c.requestSession(fr.Channel, body)
+				if !ok {
+					return
+				}
+				if session == nil {
+					err = newFrameError(fr.Channel, fr.Body, nil, fmt.Errorf("reached connection channel max (%d)", c.channelMax))
+					continue
+				}
+				sessionsByRemoteChannel[fr.Channel] = session
+				continue
+			}
 			if body.RemoteChannel == nil {
-				// since we only support remotely-initiated sessions, this is an error
-				// TODO: it would be ideal to not have this kill the connection
-				err = fmt.Errorf("%T: nil RemoteChannel", fr.Body)
+				// client connections only support locally-initiated sessions, so this is an error
+				if fe := newFrameError(fr.Channel, fr.Body, nil, fmt.Errorf("%T: nil RemoteChannel", fr.Body)); !c.tolerate(fe) {
+					err = fe
+				}
 				continue
 			}
 			c.sessionsByChannelMu.RLock()
 			session, ok = c.sessionsByChannel[*body.RemoteChannel]
 			c.sessionsByChannelMu.RUnlock()
 			if !ok {
-				err = fmt.Errorf("unexpected remote channel number %d", *body.RemoteChannel)
+				if fe := newFrameError(fr.Channel, fr.Body, nil, fmt.Errorf("unexpected remote channel number %d", *body.RemoteChannel)); !c.tolerate(fe) {
+					err = fe
+				}
 				continue
 			}
 
@@ -496,7 +948,9 @@ func (c *Conn) connReader() {
 		case *frames.PerformEnd:
 			session, ok = sessionsByRemoteChannel[fr.Channel]
 			if !ok {
-				err = fmt.Errorf("%T: didn't find channel %d in sessionsByRemoteChannel (PerformEnd)", fr.Body, fr.Channel)
+				if fe := newFrameError(fr.Channel, fr.Body, nil, fmt.Errorf("%T: didn't find channel %d in sessionsByRemoteChannel (PerformEnd)", fr.Body, fr.Channel)); !c.tolerate(fe) {
+					err = fe
+				}
 				continue
 			}
 			// we MUST remove the remote channel from our map as soon as we receive
@@ -508,7 +962,9 @@ func (c *Conn) connReader() {
 			// pass on performative to the correct session
 			session, ok = sessionsByRemoteChannel[fr.Channel]
 			if !ok {
-				err = fmt.Errorf("%T: didn't find channel %d in sessionsByRemoteChannel", fr.Body, fr.Channel)
+				if fe := newFrameError(fr.Channel, fr.Body, nil, fmt.Errorf("%T: didn't find channel %d in sessionsByRemoteChannel", fr.Body, fr.Channel)); !c.tolerate(fe) {
+					err = fe
+				}
 				continue
 			}
 		}
@@ -521,6 +977,18 @@ func (c *Conn) connReader() {
 	}
 }
 
+// tolerate returns true if the protocol violation described by fe is to be
+// ignored, logging it, rather than tearing down the connection or session.
+func (c *Conn) tolerate(fe *FrameError) bool {
+	if c.protocolMode != ProtocolModeLenient {
+		return false
+	}
+	c.stats.protocolViolation()
+	debug.Log(1, "ignoring protocol violation: %v", fe)
+	c.logger.Warn("ignoring protocol violation", "channel", fe.Channel, "error", fe.inner, "frame", fe.Summary)
+	return true
+}
+
 // readFrame reads a complete frame from c.net.
 // it assumes that any read deadline has already been applied.
 // used externally by SASL only.
@@ -544,11 +1012,16 @@ func (c *Conn) readFrame() (frames.Frame, error) {
 		// need to read more if buf doesn't contain the complete frame
 		// or there's not enough in buf to parse the header
 		if frameInProgress || c.rxBuf.Len() < frames.HeaderSize {
-			// we MUST reset the idle timeout before each read from net.Conn
+			// we MUST reset the idle timeout before each read from net.Conn.
+			// at high message rates most reads follow the previous one closely,
+			// so the deadline is only moved once a fraction of it has elapsed.
 			if c.idleTimeout > 0 {
-				_ = c.net.SetReadDeadline(time.Now().Add(c.idleTimeout))
+				if now := c.clock.Now(); now.Sub(c.readDeadlineSet) >= c.idleTimeout/idleDeadlineSlack {
+					_ = c.net.SetReadDeadline(now.Add(c.idleTimeout))
+					c.readDeadlineSet = now
+				}
 			}
-			err := c.rxBuf.ReadFromOnce(c.net)
+			err := c.readNet()
 			if err != nil {
 				debug.Log(1, "readFrame error: %v", err)
 				return frames.Frame{}, err
@@ -570,8 +1043,15 @@ func (c *Conn) readFrame() (frames.Frame, error) {
 			frameInProgress = true
 		}
 
-		// check size is reasonable
-		if currentHeader.Size > math.MaxInt32 { // make max size configurable
+		// check size is reasonable, before buffering any of the frame
+		if currentHeader.Size > c.maxFrameSize {
+			return frames.Frame{}, &FrameSizeError{
+				Channel:      currentHeader.Channel,
+				Size:         currentHeader.Size,
+				MaxFrameSize: c.maxFrameSize,
+			}
+		}
+		if currentHeader.Size > math.MaxInt32 {
 			return frames.Frame{}, errors.New("payload too large")
 		}
 
@@ -584,8 +1064,11 @@ func (c *Conn) readFrame() (frames.Frame, error) {
 		frameInProgress = false
 
 		// check if body is empty (keepalive)
+		c.stats.frameRead()
+
 		if bodySize == 0 {
 			debug.Log(3, "received keep-alive frame")
+			c.captureInbound(currentHeader, nil)
 			continue
 		}
 
@@ -594,11 +1077,17 @@ func (c *Conn) readFrame() (frames.Frame, error) {
 		if !ok {
 			return frames.Frame{}, fmt.Errorf("buffer EOF; requested bytes: %d, actual size: %d", bodySize, c.rxBuf.Len())
 		}
+		c.captureInbound(currentHeader, b)
 
-		parsedBody, err := frames.ParseBody(buffer.New(b))
+		body := buffer.New(b)
+		body.SetLimits(c.decodeLimits)
+		body.SetSkipUnknownFields(c.protocolMode == ProtocolModeLenient)
+		parsedBody, err := frames.ParseBody(body)
 		if err != nil {
-			return frames.Frame{}, err
+			return frames.Frame{}, newFrameError(currentHeader.Channel, nil, b, err)
 		}
+		c.traceFrame(false, currentHeader.Channel, parsedBody, int(currentHeader.Size))
+		c.metrics.FrameReceived(performativeName(parsedBody), int(currentHeader.Size))
 
 		return frames.Frame{Channel: currentHeader.Channel, Body: parsedBody}, nil
 	}
@@ -622,19 +1111,22 @@ func (c *Conn) connWriter() {
 		// 0 disables keepalives
 		keepalivesEnabled = keepaliveInterval > 0
 		// set if enable, nil if not; nil channels block forever
-		keepalive <-chan time.Time
+		keepalive      <-chan time.Time
+		keepaliveTimer clock.Timer
 	)
 
 	if keepalivesEnabled {
-		ticker := time.NewTicker(keepaliveInterval)
-		defer ticker.Stop()
-		keepalive = ticker.C
+		keepaliveTimer = c.clock.NewTimer(keepaliveInterval)
+		defer keepaliveTimer.Stop()
+		keepalive = keepaliveTimer.C()
 	}
 
 	var err error
 	for {
+		c.writerActivity.end()
 		if err != nil {
 			debug.Log(1, "connWriter terminal error: %v", err)
+			c.logger.Debug("connection writer stopped", "hostname", c.hostname, "error", err)
 			c.txErr = err
 			return
 		}
@@ -642,15 +1134,19 @@ func (c *Conn) connWriter() {
 		select {
 		// frame write request
 		case fr := <-c.txFrame:
-			err = c.writeFrame(fr)
-			if err == nil && fr.Done != nil {
-				close(fr.Done)
-			}
+			c.writerActivity.begin()
+			err = c.writeFrames(fr)
 
 		// keepalive timer
 		case <-keepalive:
+			c.writerActivity.begin()
 			debug.Log(3, "sending keep-alive frame")
-			_, err = c.net.Write(keepaliveFrame)
+			c.captureOutbound(keepaliveFrame)
+			_, err = c.writeNet(keepaliveFrame)
+			if err == nil {
+				c.stats.frameWritten()
+			}
+			keepaliveTimer.Reset(keepaliveInterval)
 			// It would be slightly more efficient in terms of network
 			// resources to reset the timer each time a frame is sent.
 			// However, keepalives are small (8 bytes) and the interval
@@ -665,7 +1161,9 @@ func (c *Conn) connWriter() {
 			// SHOULD wait for the ack but we don't HAVE to, in order
 			// to be resilient to bad actors etc.  so we just send
 			// the close performative and exit.
-			cls := &frames.PerformClose{}
+			c.closeErrMu.Lock()
+			cls := &frames.PerformClose{Error: c.closeErr}
+			c.closeErrMu.Unlock()
 			debug.Log(1, "TX (connWriter): %s", cls)
 			c.txErr = c.writeFrame(frames.Frame{
 				Type: frames.TypeAMQP,
@@ -680,37 +1178,123 @@ func (c *Conn) connWriter() {
 // used externally by SASL only.
 func (c *Conn) writeFrame(fr frames.Frame) error {
 	if c.connectTimeout != 0 {
-		_ = c.net.SetWriteDeadline(time.Now().Add(c.connectTimeout))
+		_ = c.net.SetWriteDeadline(c.clock.Now().Add(c.connectTimeout))
 	}
 
-	// writeFrame into txBuf
 	c.txBuf.Reset()
+	if err := c.marshalFrame(fr); err != nil {
+		return err
+	}
+	return c.flushFrames(1)
+}
+
+// writeFrames writes fr, along with the frames sent after it until none
+// are waiting, the coalesce window elapses, or maxCoalescedWrite bytes
+// are reached, in a single write to the network. The Done channels of the
+// frames are closed once they're written.
+func (c *Conn) writeFrames(fr frames.Frame) error {
+	c.txBuf.Reset()
+	c.txBatch = append(c.txBatch[:0], fr)
+	if err := c.marshalFrame(fr); err != nil {
+		return err
+	}
+
+	var window <-chan time.Time
+	if c.coalesce > 0 {
+		timer := c.clock.NewTimer(c.coalesce)
+		defer timer.Stop()
+		window = timer.C()
+	}
+
+coalesce:
+	for c.txBuf.Len() < maxCoalescedWrite {
+		select {
+		case fr := <-c.txFrame:
+			c.txBatch = append(c.txBatch, fr)
+			if err := c.marshalFrame(fr); err != nil {
+				return err
+			}
+			continue
+		default:
+		}
+		if window == nil {
+			break
+		}
+		select {
+		case fr := <-c.txFrame:
+			c.txBatch = append(c.txBatch, fr)
+			if err := c.marshalFrame(fr); err != nil {
+				return err
+			}
+		case <-window:
+			break coalesce
+		case <-c.rxtxExit:
+			break coalesce
+		}
+	}
+
+	if err := c.flushFrames(len(c.txBatch)); err != nil {
+		return err
+	}
+	for i, fr := range c.txBatch {
+		if fr.Done != nil {
+			close(fr.Done)
+		}
+		c.txBatch[i] = frames.Frame{}
+	}
+	return nil
+}
+
+// maxCoalescedWrite is the size in bytes past which connWriter
+// stops waiting for more frames to write together.
+const maxCoalescedWrite = 64 * 1024
+
+// marshalFrame appends fr to txBuf.
+func (c *Conn) marshalFrame(fr frames.Frame) error {
+	start := c.txBuf.Len()
 	err := frames.Write(&c.txBuf, fr)
 	if err != nil {
 		return err
 	}
 
 	// validate the frame isn't exceeding peer's max frame size
-	requiredFrameSize := c.txBuf.Len()
+	requiredFrameSize := c.txBuf.Len() - start
 	if uint64(requiredFrameSize) > uint64(c.peerMaxFrameSize) {
 		return fmt.Errorf("%T frame size %d larger than peer's max frame size %d", fr, requiredFrameSize, c.peerMaxFrameSize)
 	}
 
-	// write to network
-	n, err := c.net.Write(c.txBuf.Bytes())
-	if l := c.txBuf.Len(); n > 0 && n < l && err != nil {
-		debug.Log(1, "wrote %d bytes less than len %d: %v", n, l, err)
+	// trace before writing so the frame is reported ahead of any response to it
+	c.traceFrame(true, fr.Channel, fr.Body, requiredFrameSize)
+	if fr.Body != nil {
+		c.metrics.FrameSent(performativeName(fr.Body), requiredFrameSize)
 	}
-	return err
+
+	c.captureOutbound(c.txBuf.Bytes()[start:])
+	return nil
+}
+
+// flushFrames writes the n frames in txBuf to the network.
+func (c *Conn) flushFrames(n int) error {
+	written, err := c.writeNet(c.txBuf.Bytes())
+	if l := c.txBuf.Len(); written > 0 && written < l && err != nil {
+		debug.Log(1, "wrote %d bytes less than len %d: %v", written, l, err)
+	}
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		c.stats.frameWritten()
+	}
+	return nil
 }
 
 // writeProtoHeader writes an AMQP protocol header to the
 // network
 func (c *Conn) writeProtoHeader(pID protoID) error {
 	if c.connectTimeout != 0 {
-		_ = c.net.SetWriteDeadline(time.Now().Add(c.connectTimeout))
+		_ = c.net.SetWriteDeadline(c.clock.Now().Add(c.connectTimeout))
 	}
-	_, err := c.net.Write([]byte{'A', 'M', 'Q', 'P', byte(pID), 1, 0, 0})
+	_, err := c.writeNet([]byte{'A', 'M', 'Q', 'P', byte(pID), 1, 0, 0})
 	return err
 }
 
@@ -799,10 +1383,10 @@ func (c *Conn) readProtoHeader() (protoHeader, error) {
 	if c.rxBuf.Len() == 0 {
 		for {
 			if c.connectTimeout != 0 {
-				_ = c.net.SetReadDeadline(time.Now().Add(c.connectTimeout))
+				_ = c.net.SetReadDeadline(c.clock.Now().Add(c.connectTimeout))
 			}
 
-			err := c.rxBuf.ReadFromOnce(c.net)
+			err := c.readNet()
 			if err != nil {
 				return protoHeader{}, err
 			}
@@ -816,6 +1400,7 @@ func (c *Conn) readProtoHeader() (protoHeader, error) {
 		// reset outside the loop
 		if c.connectTimeout != 0 {
 			_ = c.net.SetReadDeadline(time.Time{})
+			c.readDeadlineSet = time.Time{}
 		}
 	}
 
@@ -849,6 +1434,7 @@ func (c *Conn) startTLS() (stateFunc, error) {
 	c.initTLSConfig()
 
 	_ = c.net.SetReadDeadline(time.Time{}) // clear timeout
+	c.readDeadlineSet = time.Time{}
 
 	// wrap existing net.Conn and perform TLS handshake
 	tlsConn := tls.Client(c.net, c.tlsConfig)
@@ -867,14 +1453,7 @@ func (c *Conn) startTLS() (stateFunc, error) {
 // openAMQP round trips the AMQP open performative
 func (c *Conn) openAMQP() (stateFunc, error) {
 	// send open frame
-	open := &frames.PerformOpen{
-		ContainerID:  c.containerID,
-		Hostname:     c.hostname,
-		MaxFrameSize: c.maxFrameSize,
-		ChannelMax:   c.channelMax,
-		IdleTimeout:  c.idleTimeout / 2, // per spec, advertise half our idle timeout
-		Properties:   c.properties,
-	}
+	open := c.openFrame()
 	debug.Log(1, "TX (openAMQP): %s", open)
 	err := c.writeFrame(frames.Frame{
 		Type:    frames.TypeAMQP,
@@ -896,7 +1475,31 @@ func (c *Conn) openAMQP() (stateFunc, error) {
 	}
 	debug.Log(1, "RX (openAMQP): %s", o)
 
-	// update peer settings
+	c.updatePeerSettings(o)
+
+	// connection established, exit state machine
+	return nil, nil
+}
+
+// openFrame returns the open performative announcing our local settings.
+func (c *Conn) openFrame() *frames.PerformOpen {
+	hostname := c.hostname
+	if c.openHostname != "" {
+		hostname = c.openHostname
+	}
+	return &frames.PerformOpen{
+		ContainerID:  c.containerID,
+		Hostname:     hostname,
+		MaxFrameSize: c.maxFrameSize,
+		ChannelMax:   c.channelMax,
+		IdleTimeout:  c.idleTimeout / 2, // per spec, advertise half our idle timeout
+		Properties:   c.properties,
+	}
+}
+
+// updatePeerSettings applies the settings from the peer's open performative.
+func (c *Conn) updatePeerSettings(o *frames.PerformOpen) {
+	c.peerContainerID = o.ContainerID
 	if o.MaxFrameSize > 0 {
 		c.peerMaxFrameSize = o.MaxFrameSize
 	}
@@ -907,9 +1510,6 @@ func (c *Conn) openAMQP() (stateFunc, error) {
 	if o.ChannelMax < c.channelMax {
 		c.channelMax = o.ChannelMax
 	}
-
-	// connection established, exit state machine
-	return nil, nil
 }
 
 // negotiateSASL returns the SASL handler for the first matched
@@ -970,8 +1570,11 @@ func (c *Conn) saslOutcome() (stateFunc, error) {
 // After setup, conn.connReader handles incoming frames.
 func (c *Conn) readSingleFrame() (frames.Frame, error) {
 	if c.connectTimeout != 0 {
-		_ = c.net.SetDeadline(time.Now().Add(c.connectTimeout))
-		defer func() { _ = c.net.SetDeadline(time.Time{}) }()
+		_ = c.net.SetDeadline(c.clock.Now().Add(c.connectTimeout))
+		defer func() {
+			_ = c.net.SetDeadline(time.Time{})
+			c.readDeadlineSet = time.Time{}
+		}()
 	}
 
 	fr, err := c.readFrame()
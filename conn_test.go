@@ -1,18 +1,24 @@
 package amqp
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/Azure/go-amqp/internal/buffer"
 	"github.com/Azure/go-amqp/internal/encoding"
 	"github.com/Azure/go-amqp/internal/frames"
-	"github.com/Azure/go-amqp/internal/mocks"
 	"github.com/Azure/go-amqp/internal/test"
+	"github.com/Azure/go-amqp/mocks"
 	"github.com/stretchr/testify/require"
 )
 
@@ -56,6 +62,17 @@ func TestConnOptions(t *testing.T) {
 				}
 			},
 		},
+		{
+			label: "ConnHostnameOverride",
+			opts: ConnOptions{
+				HostName:         "testhost",
+				HostnameOverride: "vhost:/orders",
+			},
+			verify: func(t *testing.T, c *Conn) {
+				require.Equal(t, "testhost", c.hostname)
+				require.Equal(t, "vhost:/orders", c.openFrame().Hostname)
+			},
+		},
 		{
 			label: "ConnTLSConfig",
 			opts: ConnOptions{
@@ -132,6 +149,24 @@ func TestConnOptions(t *testing.T) {
 				MaxSessions: 0,
 			},
 		},
+		{
+			label: "ConnDecodeLimits",
+			opts: ConnOptions{
+				DecodeLimits: &DecodeLimits{MaxDepth: 8, MaxElements: 64, MaxLength: 1024},
+			},
+			verify: func(t *testing.T, c *Conn) {
+				require.Equal(t, 8, c.decodeLimits.MaxDepth)
+				require.Equal(t, uint32(64), c.decodeLimits.MaxElements)
+				require.Equal(t, uint32(1024), c.decodeLimits.MaxLength)
+			},
+		},
+		{
+			label: "ConnDecodeLimits_Invalid",
+			fails: true,
+			opts: ConnOptions{
+				DecodeLimits: &DecodeLimits{MaxDepth: -1},
+			},
+		},
 		{
 			label: "ConnContainerID",
 			opts: ConnOptions{
@@ -143,6 +178,22 @@ func TestConnOptions(t *testing.T) {
 				}
 			},
 		},
+		{
+			label: "ConnRand",
+			opts: ConnOptions{
+				Rand: bytes.NewReader(make([]byte, 40)),
+			},
+			verify: func(t *testing.T, c *Conn) {
+				require.Equal(t, strings.Repeat("A", 54), c.containerID)
+			},
+		},
+		{
+			label: "ConnRand exhausted",
+			fails: true,
+			opts: ConnOptions{
+				Rand: bytes.NewReader(nil),
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -158,6 +209,25 @@ func TestConnOptions(t *testing.T) {
 	}
 }
 
+func TestConnRandLinkNames(t *testing.T) {
+	// enough randomness for the container-id and one link name, 40 bytes each
+	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
+	conn, err := NewConn(netConn, &ConnOptions{Rand: bytes.NewReader(make([]byte, 80))})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	snd, err := session.NewSender(ctx, "target", nil)
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("A", 54), snd.LinkName())
+
+	_, err = session.NewSender(ctx, "target", nil)
+	require.ErrorIs(t, err, io.EOF)
+}
+
 type fakeDialer struct {
 	fail bool
 }
@@ -357,6 +427,274 @@ func TestServerSideClose(t *testing.T) {
 	require.Equal(t, "*Error{Condition: Close, Description: mock server error, Info: map[]}", connErr.Error())
 }
 
+type hookedError struct {
+	err error
+}
+
+func (e *hookedError) Error() string { return "hooked: " + e.err.Error() }
+
+func (e *hookedError) Unwrap() error { return e.err }
+
+func TestConnErrorHook(t *testing.T) {
+	var hookCalls int
+	opts := &ConnOptions{
+		ErrorHook: func(err error) error {
+			hookCalls++
+			return &hookedError{err: err}
+		},
+	}
+
+	// errors creating the conn are passed through the hook
+	_, err := NewConn(mocks.NewNetConn(nil), &ConnOptions{
+		ErrorHook:    opts.ErrorHook,
+		MaxFrameSize: 1,
+	})
+	var hookedErr *hookedError
+	require.ErrorAs(t, err, &hookedErr)
+	require.Equal(t, 1, hookCalls)
+
+	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
+	client, err := NewConn(netConn, opts)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", nil)
+	cancel()
+	require.NoError(t, err)
+	require.Equal(t, 1, hookCalls)
+
+	// errors returned before any I/O are passed through the hook
+	_, err = snd.Reattach(context.Background())
+	require.ErrorAs(t, err, &hookedErr)
+	require.EqualError(t, hookedErr.err, "amqp: sender isn't suspended")
+	require.Equal(t, 2, hookCalls)
+
+	// closing with no errors doesn't call the hook
+	require.NoError(t, client.Close())
+	require.Equal(t, 2, hookCalls)
+
+	// errors from methods on a closed conn are passed through the hook once
+	err = snd.Send(context.Background(), NewMessage([]byte("test")))
+	require.ErrorAs(t, err, &hookedErr)
+	var connErr *ConnError
+	require.ErrorAs(t, err, &connErr)
+	require.Equal(t, 3, hookCalls)
+
+	_, err = client.NewSession(context.Background(), nil)
+	require.ErrorAs(t, err, &hookedErr)
+	require.Equal(t, 4, hookCalls)
+}
+
+func TestConnErrorHookReturnsNil(t *testing.T) {
+	_, err := NewConn(mocks.NewNetConn(nil), &ConnOptions{
+		ErrorHook:    func(error) error { return nil },
+		MaxFrameSize: 1,
+	})
+	require.Error(t, err)
+}
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) record(level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, level+" "+msg)
+}
+
+func (l *recordingLogger) Debug(msg string, _ ...any) { l.record("DEBUG", msg) }
+func (l *recordingLogger) Info(msg string, _ ...any)  { l.record("INFO", msg) }
+func (l *recordingLogger) Warn(msg string, _ ...any)  { l.record("WARN", msg) }
+func (l *recordingLogger) Error(msg string, _ ...any) { l.record("ERROR", msg) }
+
+func TestConnLogger(t *testing.T) {
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch req.(type) {
+		case *mocks.AMQPProto:
+			return []byte{'A', 'M', 'Q', 'P', 0, 1, 0, 0}, nil
+		case *frames.PerformOpen:
+			return mocks.PerformOpen("container")
+		case *frames.PerformBegin:
+			return mocks.PerformBegin(0)
+		case *frames.PerformEnd:
+			return mocks.PerformEnd(0, nil)
+		case *frames.PerformClose:
+			return mocks.PerformClose(nil)
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+
+	logger := &recordingLogger{}
+	conn, err := NewConn(mocks.NewNetConn(responder), &ConnOptions{Logger: logger})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	session, err := conn.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	require.NoError(t, session.Close(ctx))
+	cancel()
+	require.NoError(t, conn.Close())
+
+	require.Equal(t, []string{
+		"INFO connection opened",
+		"DEBUG session begun",
+		"DEBUG session ended",
+		"INFO connection closed",
+	}, logger.entries)
+}
+
+func TestConnStats(t *testing.T) {
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch req.(type) {
+		case *mocks.AMQPProto:
+			return []byte{'A', 'M', 'Q', 'P', 0, 1, 0, 0}, nil
+		case *frames.PerformOpen:
+			return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformOpen{
+				ContainerID:  "container",
+				MaxFrameSize: 4096,
+				ChannelMax:   16,
+				IdleTimeout:  time.Minute,
+			})
+		case *frames.PerformBegin:
+			return mocks.PerformBegin(0)
+		case *frames.PerformClose:
+			return mocks.PerformClose(nil)
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+
+	conn, err := NewConn(mocks.NewNetConn(responder), &ConnOptions{IdleTimeout: -1})
+	require.NoError(t, err)
+
+	stats := conn.Stats()
+	require.Equal(t, "container", stats.PeerContainerID)
+	require.EqualValues(t, 4096, stats.PeerMaxFrameSize)
+	require.EqualValues(t, defaultMaxFrameSize, stats.MaxFrameSize)
+	require.EqualValues(t, 16, stats.ChannelMax)
+	require.Zero(t, stats.IdleTimeout)
+	require.Equal(t, time.Minute, stats.PeerIdleTimeout)
+	require.Zero(t, stats.Sessions)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	_, err = conn.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+
+	stats = conn.Stats()
+	require.Equal(t, 1, stats.Sessions)
+	require.EqualValues(t, 2, stats.FramesRead)
+	require.EqualValues(t, 2, stats.FramesWritten)
+	require.Greater(t, stats.BytesRead, uint64(0))
+	require.Greater(t, stats.BytesWritten, uint64(0))
+	require.False(t, stats.LastRead.IsZero())
+	require.False(t, stats.LastWrite.IsZero())
+
+	require.NoError(t, conn.Close())
+	require.Greater(t, conn.Stats().FramesWritten, stats.FramesWritten)
+}
+
+func TestConnDebugDump(t *testing.T) {
+	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
+
+	client, err := NewConn(netConn, &ConnOptions{ContainerID: "my-container"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	_, err = session.NewSender(ctx, "target", &SenderOptions{Name: "test-link"})
+	cancel()
+	require.NoError(t, err)
+
+	sendInitialFlowFrame(t, netConn, 0, 100)
+
+	// wait for the flow frame to be processed
+	var sb strings.Builder
+	require.Eventually(t, func() bool {
+		sb.Reset()
+		require.NoError(t, client.DebugDump(&sb))
+		return strings.Contains(sb.String(), "credit=100")
+	}, time.Second, 10*time.Millisecond)
+	dump := sb.String()
+	require.Contains(t, dump, "conn containerID=my-container peerContainerID=container")
+	require.Contains(t, dump, "state=open")
+	require.Contains(t, dump, "session channel=0 remoteChannel=0")
+	require.Contains(t, dump, "links=1")
+	require.Contains(t, dump, `link name=test-link role=sender handle=0 remoteHandle=0 target="target" credit=100 deliveryCount=0`)
+
+	require.NoError(t, client.Close())
+
+	sb.Reset()
+	require.NoError(t, client.DebugDump(&sb))
+	require.Contains(t, sb.String(), "state=closed")
+}
+
+func TestConnFrameTrace(t *testing.T) {
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch req.(type) {
+		case *mocks.AMQPProto:
+			return []byte{'A', 'M', 'Q', 'P', 0, 1, 0, 0}, nil
+		case *frames.PerformOpen:
+			return mocks.PerformOpen("container")
+		case *frames.PerformBegin:
+			return mocks.PerformBegin(0)
+		case *frames.PerformClose:
+			return mocks.PerformClose(nil)
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+
+	var (
+		mu     sync.Mutex
+		events []FrameTraceEvent
+	)
+	conn, err := NewConn(mocks.NewNetConn(responder), &ConnOptions{
+		FrameTrace: func(ev FrameTraceEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, ev)
+		},
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	_, err = conn.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.GreaterOrEqual(t, len(events), 5)
+	for i, want := range []struct {
+		outbound     bool
+		performative string
+	}{
+		{true, "open"},
+		{false, "open"},
+		{true, "begin"},
+		{false, "begin"},
+		{true, "close"},
+	} {
+		require.Equal(t, want.outbound, events[i].Outbound, "event %d", i)
+		require.Equal(t, want.performative, events[i].Performative, "event %d", i)
+		require.NotEmpty(t, events[i].Summary)
+		require.Greater(t, events[i].Size, frames.HeaderSize)
+	}
+	require.Contains(t, events[1].Summary, "container")
+}
+
 func TestKeepAlives(t *testing.T) {
 	// closing conn can race with keep-alive ticks, so sometimes we get
 	// two in this test.  the test needs to receive at least one keep-alive,
@@ -438,6 +776,95 @@ func TestKeepAlivesIdleTimeout(t *testing.T) {
 	require.NoError(t, conn.Close())
 }
 
+func TestKeepAlivesFakeClock(t *testing.T) {
+	keepAlives := make(chan struct{}, 1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch req.(type) {
+		case *mocks.AMQPProto:
+			return []byte{'A', 'M', 'Q', 'P', 0, 1, 0, 0}, nil
+		case *frames.PerformOpen:
+			return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformOpen{ContainerID: "container", IdleTimeout: time.Minute})
+		case *mocks.KeepAlive:
+			keepAlives <- struct{}{}
+			return nil, nil
+		case *frames.PerformClose:
+			return mocks.PerformClose(nil)
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+
+	clk := mocks.NewFakeClock(time.Now())
+	conn, err := NewConn(mocks.NewNetConn(responder), &ConnOptions{Clock: clk})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return clk.Timers() == 1 }, time.Second, time.Millisecond)
+
+	// keepalives are sent at half the peer's idle timeout
+	clk.Advance(29 * time.Second)
+	select {
+	case <-keepAlives:
+		t.Fatal("unexpected keepalive")
+	default:
+	}
+	for i := 0; i < 2; i++ {
+		clk.Advance(time.Second)
+		select {
+		case <-keepAlives:
+		case <-time.After(time.Second):
+			t.Fatal("didn't receive a keepalive")
+		}
+		require.Eventually(t, func() bool { return clk.Timers() == 1 }, time.Second, time.Millisecond)
+		clk.Advance(29 * time.Second)
+	}
+}
+
+func TestIdleTimeoutFakeClock(t *testing.T) {
+	clk := mocks.NewFakeClock(time.Now())
+	netConn := mocks.NewNetConn(senderFrameHandler(SenderSettleModeUnsettled))
+	netConn.Clock = clk
+	conn, err := NewConn(netConn, &ConnOptions{Clock: clk, IdleTimeout: time.Minute})
+	require.NoError(t, err)
+	// the read deadline and the keepalive timer
+	require.Eventually(t, func() bool { return clk.Timers() == 2 }, time.Second, time.Millisecond)
+
+	clk.Advance(59 * time.Second)
+	select {
+	case <-conn.done:
+		t.Fatal("connection closed before the idle timeout")
+	default:
+	}
+	clk.Advance(time.Second)
+	select {
+	case <-conn.done:
+	case <-time.After(time.Second):
+		t.Fatal("connection wasn't closed after the idle timeout")
+	}
+	var connErr *ConnError
+	require.ErrorAs(t, conn.Close(), &connErr)
+}
+
+func TestIdleTimeoutSilentAfterOpen(t *testing.T) {
+	clk := mocks.NewFakeClock(time.Now())
+	netConn := mocks.NewNetConn(senderFrameHandler(SenderSettleModeUnsettled))
+	netConn.Clock = clk
+	// the deadline set while reading the peer's open is cleared once it's read
+	conn, err := NewConn(netConn, &ConnOptions{Clock: clk, IdleTimeout: time.Minute, Timeout: time.Minute})
+	require.NoError(t, err)
+	// the read deadline and the keepalive timer
+	require.Eventually(t, func() bool { return clk.Timers() == 2 }, time.Second, time.Millisecond)
+
+	// the peer doesn't send anything after its open
+	clk.Advance(time.Minute)
+	select {
+	case <-conn.done:
+	case <-time.After(time.Second):
+		t.Fatal("connection wasn't closed after the idle timeout")
+	}
+	var connErr *ConnError
+	require.ErrorAs(t, conn.Close(), &connErr)
+}
+
 func TestConnReaderError(t *testing.T) {
 	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
 	conn, err := newConn(netConn, nil)
@@ -454,6 +881,65 @@ func TestConnReaderError(t *testing.T) {
 	}
 }
 
+func TestConnReadQueueBackpressure(t *testing.T) {
+	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
+	conn, err := newConn(netConn, &ConnOptions{ReadQueueDepth: 2})
+	require.NoError(t, err)
+	require.Equal(t, 2, conn.Stats().ReadQueueDepth)
+
+	// only run the network reader, so the queued frames aren't processed
+	go conn.connNetReader()
+	fr, err := mocks.PerformBegin(0)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		netConn.SendFrame(fr)
+	}
+
+	// two frames are queued and a third is held while reading is paused
+	require.Eventually(t, func() bool { return conn.Stats().ReadPauses == 1 }, time.Second, time.Millisecond)
+	require.Equal(t, 2, conn.Stats().ReadQueued)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 2, conn.Stats().ReadQueued)
+	require.EqualValues(t, 3, conn.Stats().FramesRead)
+
+	// reading resumes once a frame is processed
+	<-conn.rxQueue
+	require.Eventually(t, func() bool { return conn.Stats().ReadPauses == 2 }, time.Second, time.Millisecond)
+	require.EqualValues(t, 4, conn.Stats().FramesRead)
+
+	close(conn.rxtxExit)
+	close(conn.rxDispatchDone)
+	<-conn.rxDone
+}
+
+func TestConnFrameSizeTooLarge(t *testing.T) {
+	closeErr := make(chan *Error, 1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		if cls, ok := req.(*frames.PerformClose); ok {
+			closeErr <- cls.Error
+		}
+		return senderFrameHandler(SenderSettleModeUnsettled)(req)
+	}
+	netConn := mocks.NewNetConn(responder)
+	conn, err := NewConn(netConn, &ConnOptions{MaxFrameSize: 1024})
+	require.NoError(t, err)
+
+	// only the header of the oversized frame is sent
+	netConn.SendFrame([]byte{0, 0x10, 0, 0, 2, 0, 0, 1})
+	select {
+	case <-conn.done:
+	case <-time.After(time.Second):
+		t.Fatal("connection wasn't closed")
+	}
+	require.Equal(t, ErrCondFrameSizeTooLarge, (<-closeErr).Condition)
+
+	var sizeErr *FrameSizeError
+	require.ErrorAs(t, conn.Close(), &sizeErr)
+	require.EqualValues(t, 1, sizeErr.Channel)
+	require.EqualValues(t, 0x100000, sizeErr.Size)
+	require.EqualValues(t, 1024, sizeErr.MaxFrameSize)
+}
+
 func TestConnWriterError(t *testing.T) {
 	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
 	conn, err := newConn(netConn, nil)
@@ -473,6 +959,66 @@ func TestConnWriterError(t *testing.T) {
 	}
 }
 
+// countingNetConn counts the calls to Write.
+type countingNetConn struct {
+	*mocks.NetConn
+	writes int32
+}
+
+func (c *countingNetConn) Write(b []byte) (int, error) {
+	atomic.AddInt32(&c.writes, 1)
+	return c.NetConn.Write(b)
+}
+
+func TestConnWriteCoalescing(t *testing.T) {
+	flows := make(chan uint32, 5)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *mocks.AMQPProto:
+			return []byte{'A', 'M', 'Q', 'P', 0, 1, 0, 0}, nil
+		case *frames.PerformOpen:
+			return mocks.PerformOpen("container")
+		case *frames.PerformFlow:
+			flows <- tt.NextOutgoingID
+			return nil, nil
+		case *frames.PerformClose:
+			return mocks.PerformClose(nil)
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	netConn := &countingNetConn{NetConn: mocks.NewNetConn(responder)}
+	conn, err := NewConn(netConn, &ConnOptions{WriteCoalesceWindow: time.Second})
+	require.NoError(t, err)
+	writes := atomic.LoadInt32(&netConn.writes)
+	framesWritten := conn.Stats().FramesWritten
+
+	// frames sent within the window are written together
+	var done chan encoding.DeliveryState
+	for i := uint32(0); i < 5; i++ {
+		done = make(chan encoding.DeliveryState)
+		require.NoError(t, conn.sendFrame(frames.Frame{
+			Type: frames.TypeAMQP,
+			Body: &frames.PerformFlow{NextOutgoingID: i},
+			Done: done,
+		}))
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("frames weren't written")
+	}
+	for i := uint32(0); i < 5; i++ {
+		require.Equal(t, i, <-flows)
+	}
+	require.EqualValues(t, writes+1, atomic.LoadInt32(&netConn.writes))
+	require.Equal(t, framesWritten+5, conn.Stats().FramesWritten)
+	require.NoError(t, conn.Close())
+
+	_, err = NewConn(mocks.NewNetConn(responder), &ConnOptions{WriteCoalesceWindow: -1})
+	require.Error(t, err)
+}
+
 func TestConnWithZeroByteReads(t *testing.T) {
 	responder := func(req frames.FrameBody) ([]byte, error) {
 		switch req.(type) {
@@ -764,6 +1310,8 @@ func TestClientNewSessionMissingRemoteChannel(t *testing.T) {
 				OutgoingWindow: 1000,
 				HandleMax:      math.MaxInt16,
 			})
+		case *frames.PerformClose:
+			return mocks.PerformClose(nil)
 		default:
 			return nil, fmt.Errorf("unhandled frame %T", req)
 		}
@@ -777,11 +1325,112 @@ func TestClientNewSessionMissingRemoteChannel(t *testing.T) {
 		MaxLinks: 1,
 	})
 	cancel()
-	require.Error(t, err)
 	require.Nil(t, session)
+	var frameErr *FrameError
+	require.ErrorAs(t, err, &frameErr)
+	require.EqualValues(t, 0, frameErr.Channel)
+	require.Contains(t, frameErr.Summary, "Begin")
+	require.NotEmpty(t, frameErr.Data)
+	require.False(t, frameErr.Truncated)
+	require.Error(t, client.Close())
+}
+
+func TestConnMalformedFrame(t *testing.T) {
+	// a performative list that's truncated after its descriptor
+	body := []byte{0x0, 0x53, 0x11, 0xc0, 0x10, 0x5}
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch req.(type) {
+		case *mocks.AMQPProto:
+			return []byte{'A', 'M', 'Q', 'P', 0, 1, 0, 0}, nil
+		case *frames.PerformOpen:
+			return mocks.PerformOpen("container")
+		case *frames.PerformBegin:
+			fr := []byte{0, 0, 0, byte(frames.HeaderSize + len(body)), 2, 0, 0, 3}
+			return append(fr, body...), nil
+		case *frames.PerformClose:
+			return mocks.PerformClose(nil)
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	netConn := mocks.NewNetConn(responder)
+
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.Nil(t, session)
+	var frameErr *FrameError
+	require.ErrorAs(t, err, &frameErr)
+	require.EqualValues(t, 3, frameErr.Channel)
+	require.Empty(t, frameErr.Summary)
+	require.Equal(t, body, frameErr.Data)
+	require.Contains(t, frameErr.Error(), "data: 005311c01005")
+	require.Contains(t, frameErr.Dump(), "00 53 11 c0 10 05")
+	require.Error(t, client.Close())
+}
+
+func TestConnFrameErrorData(t *testing.T) {
+	// a begin without remote-channel, its uints are encoded in full
+	// rather than as smalluint
+	body := []byte{
+		0x0, 0x53, 0x11, 0xc0, 0x11, 0x4,
+		0x40,
+		0x70, 0x0, 0x0, 0x0, 0x1,
+		0x70, 0x0, 0x0, 0x13, 0x88,
+		0x70, 0x0, 0x0, 0x3, 0xe8,
+	}
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch req.(type) {
+		case *mocks.AMQPProto:
+			return []byte{'A', 'M', 'Q', 'P', 0, 1, 0, 0}, nil
+		case *frames.PerformOpen:
+			return mocks.PerformOpen("container")
+		case *frames.PerformBegin:
+			fr := []byte{0, 0, 0, byte(frames.HeaderSize + len(body)), 2, 0, 0, 0}
+			return append(fr, body...), nil
+		case *frames.PerformClose:
+			return mocks.PerformClose(nil)
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	netConn := mocks.NewNetConn(responder)
+
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.Nil(t, session)
+	var frameErr *FrameError
+	require.ErrorAs(t, err, &frameErr)
+	require.Contains(t, frameErr.Summary, "Begin")
+	// the frame was decoded, so it's encoded again rather than kept as received
+	require.NotEqual(t, body, frameErr.Data)
+	want, err := frames.ParseBody(buffer.New(body))
+	require.NoError(t, err)
+	got, err := frames.ParseBody(buffer.New(frameErr.Data))
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	require.False(t, frameErr.Truncated)
 	require.Error(t, client.Close())
 }
 
+func TestFrameErrorTruncated(t *testing.T) {
+	raw := make([]byte, maxFrameDumpSize+10)
+	fe := newFrameError(1, nil, raw, errors.New("bad frame"))
+	require.Len(t, fe.Data, maxFrameDumpSize)
+	require.True(t, fe.Truncated)
+	require.True(t, strings.HasSuffix(fe.Error(), "..."))
+	require.ErrorContains(t, fe, "bad frame")
+
+	// the captured data must not alias the source buffer
+	raw[0] = 0xff
+	require.Zero(t, fe.Data[0])
+}
+
 func TestClientNewSessionInvalidInitialResponse(t *testing.T) {
 	responder := func(req frames.FrameBody) ([]byte, error) {
 		switch req.(type) {
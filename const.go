@@ -1,6 +1,10 @@
 package amqp
 
-import "github.com/Azure/go-amqp/internal/encoding"
+import (
+	"fmt"
+
+	"github.com/Azure/go-amqp/internal/encoding"
+)
 
 // Sender Settlement Modes
 const (
@@ -91,3 +95,79 @@ const (
 // terminus-expiry-policy are subsequently re-met, the expiry timer restarts
 // from its originally configured timeout value.
 type ExpiryPolicy = encoding.ExpiryPolicy
+
+// DistributionMode specifies how messages are distributed from the source of
+// a receiver to the receivers attached to it.
+type DistributionMode string
+
+const (
+	// DistributionModeMove moves each message to a single receiver,
+	// removing it from the source, as done by queues.
+	DistributionModeMove DistributionMode = "move"
+
+	// DistributionModeCopy copies messages to the receiver, leaving them
+	// available to the others, as done by topics and browsers.
+	DistributionModeCopy DistributionMode = "copy"
+)
+
+// Terminus capabilities understood by Qpid Dispatch Router, for brokers and
+// services connecting to a router network. See SenderOptions.TargetCapabilities
+// and ReceiverOptions.SenderCapabilities.
+const (
+	// CapabilityWaypoint identifies the links attached for the waypoints
+	// of an address, which route its messages through a queue of a broker.
+	// The phase of the waypoints after the first one is appended, e.g.
+	// "qd.waypoint.2".
+	CapabilityWaypoint = "qd.waypoint"
+
+	// CapabilityFallback attaches to the fallback destination of an
+	// address, which receives the messages that no other receiver of the
+	// address can accept.
+	CapabilityFallback = "qd.fallback"
+)
+
+// RoutingType requests the routing semantics of the node a link attaches to,
+// through the capabilities of its terminus. Brokers such as ActiveMQ Artemis
+// resolve an address to a queue or a topic from them, rather than from their
+// defaults, and create the node accordingly when auto-creation is enabled.
+type RoutingType uint8
+
+const (
+	// RoutingTypeDefault leaves the routing semantics to the broker.
+	RoutingTypeDefault RoutingType = iota
+
+	// RoutingTypeAnycast requests point-to-point semantics, where each
+	// message is delivered to a single consumer, with the queue and ANYCAST
+	// capabilities.
+	RoutingTypeAnycast
+
+	// RoutingTypeMulticast requests publish-subscribe semantics, where each
+	// message is delivered to every subscriber, with the topic and MULTICAST
+	// capabilities.
+	RoutingTypeMulticast
+)
+
+// capabilities returns the terminus capabilities requesting the routing type.
+func (r RoutingType) capabilities() []encoding.Symbol {
+	switch r {
+	case RoutingTypeAnycast:
+		return []encoding.Symbol{"queue", "ANYCAST"}
+	case RoutingTypeMulticast:
+		return []encoding.Symbol{"topic", "MULTICAST"}
+	}
+	return nil
+}
+
+// String implements the fmt.Stringer interface for RoutingType.
+func (r RoutingType) String() string {
+	switch r {
+	case RoutingTypeDefault:
+		return "default"
+	case RoutingTypeAnycast:
+		return "anycast"
+	case RoutingTypeMulticast:
+		return "multicast"
+	default:
+		return fmt.Sprintf("unknown routing type %d", uint8(r))
+	}
+}
@@ -0,0 +1,311 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp/internal/debug"
+)
+
+// ConsumerOptions contains the optional settings for configuring a Consumer.
+type ConsumerOptions struct {
+	// Concurrency is the maximum number of messages handled concurrently.
+	//
+	// Default: 1.
+	Concurrency int
+
+	// RetryPolicy determines if, and when, a failed receiver is recreated.
+	// Consecutive failures are counted from the last message received.
+	//
+	// Default: ExponentialBackoff(nil).
+	RetryPolicy RetryPolicy
+
+	// Checkpoints, when set, saves the Checkpoint of the messages handled,
+	// accepted or rejected, to resume from after a restart. The receiver
+	// function starts its receiver after the last one saved by setting the
+	// filters returned by CheckpointFilters. Messages handled concurrently
+	// can complete out of order, so only a Checkpoint following the last
+	// one saved is saved.
+	//
+	// Default: nil.
+	Checkpoints *CheckpointOptions
+}
+
+// Consumer passes the messages received on a Receiver to a MessageHandler,
+// running up to ConsumerOptions.Concurrency handlers concurrently.
+//
+// Messages are accepted when the handler returns nil, and rejected with
+// the error it returns otherwise, as done by Router. Messages being handled
+// when the Consumer is stopped, whose handler fails after its context is
+// canceled, are released to be redelivered.
+//
+// The receiver is attached by the receiver function when the Consumer is
+// started, and again after it fails with a retryable error. It's
+// responsible for dialing a new connection when the error requires it, as
+// classified by ClassifyError. Receivers created with
+// ReceiverOptions.ManualCredits are granted one credit per free handler,
+// so messages are only delivered when they can be handled. Otherwise the
+// receiver manages its credit, and ReceiverOptions.Credit bounds the
+// messages received but not yet handled.
+type Consumer struct {
+	newReceiver func(ctx context.Context) (*Receiver, error)
+	handler     MessageHandler
+	concurrency int
+	policy      RetryPolicy
+	checkpoints *CheckpointOptions // nil when disabled
+
+	cpMu      sync.Mutex
+	cpHandled int        // messages handled since the last checkpoint
+	cpLast    Checkpoint // last checkpoint saved
+	cpSaved   bool       // a checkpoint was saved
+
+	mu      sync.Mutex
+	started bool
+	stop    context.CancelFunc // stops receiving messages
+	abort   context.CancelFunc // cancels the context of the handlers
+	done    chan struct{}      // closed once the Consumer stopped
+	err     error              // error that stopped the Consumer, set before done is closed
+
+	// stateChanged, when set, is called from run as the receiver is
+	// attached and fails, see SubscriptionManager.
+	stateChanged func(state SubscriptionState, err error)
+}
+
+// NewConsumer creates a Consumer passing the messages received on the
+// receiver returned by receiver to h. Call Start to start receiving.
+//
+// opts: pass nil to accept the default values.
+func NewConsumer(receiver func(ctx context.Context) (*Receiver, error), h MessageHandler, opts *ConsumerOptions) *Consumer {
+	c := &Consumer{
+		newReceiver: receiver,
+		handler:     h,
+		concurrency: 1,
+		done:        make(chan struct{}),
+	}
+	if opts != nil {
+		if opts.Concurrency > 0 {
+			c.concurrency = opts.Concurrency
+		}
+		c.policy = opts.RetryPolicy
+		if opts.Checkpoints != nil {
+			checkpoints := *opts.Checkpoints
+			if checkpoints.Interval <= 0 {
+				checkpoints.Interval = 1
+			}
+			c.checkpoints = &checkpoints
+		}
+	}
+	if c.policy == nil {
+		c.policy = ExponentialBackoff(nil)
+	}
+	return c
+}
+
+// Start starts receiving and handling messages in the background, until
+// Stop is called, or the receiver fails with an error that isn't retryable
+// or for which the retry policy stops retrying. A Consumer can only be
+// started once.
+func (c *Consumer) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return errors.New("amqp: consumer has already been started")
+	}
+	c.started = true
+
+	var receiveCtx, handlerCtx context.Context
+	receiveCtx, c.stop = context.WithCancel(context.Background())
+	handlerCtx, c.abort = context.WithCancel(context.Background())
+	go c.run(receiveCtx, handlerCtx)
+	return nil
+}
+
+// Stop stops receiving messages, waits for the messages being handled to
+// be settled, and detaches the receiver. It returns the error that stopped
+// the Consumer before Stop was called, if any.
+//
+// If ctx completes first, the context passed to the handlers is canceled,
+// and ctx.Err() is returned once they return.
+func (c *Consumer) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.started {
+		// there's nothing to stop, but it can't be started anymore
+		c.started = true
+		c.stop, c.abort = func() {}, func() {}
+		close(c.done)
+	}
+	c.mu.Unlock()
+
+	c.stop()
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		c.abort()
+		<-c.done
+		return ctx.Err()
+	}
+}
+
+// Done returns a channel that's closed once the Consumer has stopped.
+func (c *Consumer) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error that stopped the Consumer, once Done is closed.
+// It's nil if the Consumer was stopped by Stop.
+func (c *Consumer) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// run attaches the receiver, and receives messages until ctx is canceled
+// or the receiver can't be recreated.
+func (c *Consumer) run(ctx, handlerCtx context.Context) {
+	var detaching sync.WaitGroup // tracks the receivers being detached
+	defer func() {
+		detaching.Wait()
+		c.abort()
+		if c.err != nil {
+			c.setState(SubscriptionFailed, c.err)
+		} else {
+			c.setState(SubscriptionClosed, nil)
+		}
+		close(c.done)
+	}()
+
+	sem := make(chan struct{}, c.concurrency) // held by each handler
+	for attempt := 1; ; attempt++ {
+		c.setState(SubscriptionAttaching, nil)
+		rcv, err := c.newReceiver(ctx)
+		if err == nil {
+			c.setState(SubscriptionActive, nil)
+			var handlers sync.WaitGroup
+			var received bool
+			received, err = c.consume(ctx, handlerCtx, rcv, sem, &handlers)
+			if received {
+				attempt = 1
+			}
+
+			// the receiver is detached once its messages are settled
+			detaching.Add(1)
+			go func() {
+				defer detaching.Done()
+				handlers.Wait()
+				ctx, cancel := context.WithTimeout(context.Background(), consumerDetachTimeout)
+				defer cancel()
+				_ = rcv.Close(ctx)
+			}()
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		if !IsRetryable(err) {
+			c.err = err
+			return
+		}
+		delay, ok := c.policy.Delay(attempt, err)
+		if !ok {
+			c.err = err
+			return
+		}
+		c.setState(SubscriptionBackoff, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// setState calls stateChanged, if set.
+func (c *Consumer) setState(state SubscriptionState, err error) {
+	if c.stateChanged != nil {
+		c.stateChanged(state, err)
+	}
+}
+
+// consume receives messages on rcv, and starts a handler for each, until
+// ctx is canceled or rcv fails. It returns true if any message was received.
+func (c *Consumer) consume(ctx, handlerCtx context.Context, rcv *Receiver, sem chan struct{}, handlers *sync.WaitGroup) (bool, error) {
+	manual := !rcv.autoSendFlow
+	if manual {
+		credit := uint32(c.concurrency)
+		if credit > rcv.maxCredit {
+			credit = rcv.maxCredit
+		}
+		if err := rcv.IssueCredit(credit); err != nil {
+			return false, err
+		}
+	}
+
+	received := false
+	for {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return received, ctx.Err()
+		}
+		msg, err := rcv.Receive(ctx)
+		if err != nil {
+			<-sem
+			return received, err
+		}
+		received = true
+
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			defer func() { <-sem }()
+			if err := c.handler(handlerCtx, msg); err != nil && handlerCtx.Err() != nil {
+				_ = rcv.ReleaseMessage(context.Background(), msg)
+			} else {
+				settle(rcv, msg, err)
+				c.checkpoint(msg)
+			}
+			if manual {
+				_ = rcv.IssueCredit(1)
+			}
+		}()
+	}
+}
+
+// checkpoint saves the Checkpoint of msg, which was handled, if it's time to.
+func (c *Consumer) checkpoint(msg *Message) {
+	if c.checkpoints == nil {
+		return
+	}
+	cp, ok := CheckpointFromMessage(msg)
+	if !ok {
+		return
+	}
+
+	c.cpMu.Lock()
+	defer c.cpMu.Unlock()
+	if c.cpSaved && cp.SequenceNumber <= c.cpLast.SequenceNumber {
+		return
+	}
+	c.cpHandled++
+	if c.cpHandled < c.checkpoints.Interval {
+		return
+	}
+	if err := c.checkpoints.Store.Save(context.Background(), c.checkpoints.Source, cp); err != nil {
+		debug.Log(1, "saving checkpoint of %s: %v", c.checkpoints.Source, err)
+		return
+	}
+	c.cpHandled = 0
+	c.cpLast, c.cpSaved = cp, true
+}
+
+// consumerDetachTimeout is how long a Consumer waits for the peer to
+// acknowledge the detach of its receiver.
+const consumerDetachTimeout = 5 * time.Second
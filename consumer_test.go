@@ -0,0 +1,186 @@
+package amqp_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumer(t *testing.T) {
+	// the first attach fails
+	b := broker.New(&broker.Options{
+		AutoCreateQueues: true,
+		FaultHook: broker.NewScript(broker.FaultRule{
+			Point:   broker.FaultPointAttach,
+			Address: "in",
+			Count:   1,
+			Fault:   broker.Fault{Error: &amqp.Error{Condition: amqp.ErrCondResourceLimitExceeded}},
+		}).Hook,
+	})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+	in, err := b.DeclareQueue("in")
+	require.NoError(t, err)
+	for _, body := range []string{"first", "bad", "second", "third"} {
+		require.NoError(t, in.Enqueue(amqp.NewMessage([]byte(body))))
+	}
+
+	var attaches int
+	var mu sync.Mutex
+	var handled []string
+	var running, maxRunning int
+	consumer := amqp.NewConsumer(func(ctx context.Context) (*amqp.Receiver, error) {
+		attaches++
+		conn, err := amqp.Dial(srv.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		session, err := conn.NewSession(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return session.NewReceiver(ctx, "in", &amqp.ReceiverOptions{ManualCredits: true, Credit: 10})
+	}, func(ctx context.Context, msg *amqp.Message) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		running--
+		handled = append(handled, string(msg.GetData()))
+		if string(msg.GetData()) == "bad" {
+			return errors.New("bad message")
+		}
+		return nil
+	}, &amqp.ConsumerOptions{
+		Concurrency: 2,
+		RetryPolicy: amqp.ConstantBackoff(time.Millisecond, 3),
+	})
+	require.NoError(t, consumer.Start())
+	require.Error(t, consumer.Start())
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 4
+	}, 5*time.Second, time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, consumer.Stop(ctx))
+	require.NoError(t, consumer.Err())
+
+	// the receiver was attached again, and the messages were all settled
+	require.Equal(t, 2, attaches)
+	require.LessOrEqual(t, maxRunning, 2)
+	require.ElementsMatch(t, []string{"first", "bad", "second", "third"}, handled)
+	require.Zero(t, in.Len())
+}
+
+func TestConsumerStopTimeout(t *testing.T) {
+	b := broker.New(&broker.Options{AutoCreateQueues: true})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+	in, err := b.DeclareQueue("in")
+	require.NoError(t, err)
+	require.NoError(t, in.Enqueue(amqp.NewMessage([]byte("slow"))))
+
+	handling := make(chan struct{})
+	consumer := amqp.NewConsumer(func(ctx context.Context) (*amqp.Receiver, error) {
+		conn, err := amqp.Dial(srv.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		session, err := conn.NewSession(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return session.NewReceiver(ctx, "in", &amqp.ReceiverOptions{Credit: 1})
+	}, func(ctx context.Context, msg *amqp.Message) error {
+		close(handling)
+		<-ctx.Done()
+		return ctx.Err()
+	}, nil)
+	require.NoError(t, consumer.Start())
+	<-handling
+
+	// the handler is canceled, and its message released
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, consumer.Stop(ctx), context.DeadlineExceeded)
+	<-consumer.Done()
+	require.Eventually(t, func() bool { return in.Len() == 1 }, time.Second, time.Millisecond)
+}
+
+func TestConsumerCheckpoints(t *testing.T) {
+	b := broker.New(&broker.Options{AutoCreateQueues: true})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+	in, err := b.DeclareQueue("in")
+	require.NoError(t, err)
+	for seq := int64(0); seq < 4; seq++ {
+		msg := amqp.NewMessage([]byte("test"))
+		msg.Annotations = amqp.Annotations{"x-opt-sequence-number": seq, "x-opt-offset": "1024"}
+		require.NoError(t, in.Enqueue(msg))
+	}
+	conn, err := amqp.Dial(srv.URL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	path := filepath.Join(t.TempDir(), "checkpoints.json")
+	store, err := amqp.NewFileCheckpointStore(path)
+	require.NoError(t, err)
+	newReceiver := func(ctx context.Context) (*amqp.Receiver, error) {
+		filters, err := amqp.CheckpointFilters(ctx, store, "in")
+		if err != nil {
+			return nil, err
+		}
+		session, err := conn.NewSession(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return session.NewReceiver(ctx, "in", &amqp.ReceiverOptions{Filters: filters})
+	}
+	var handled int32
+	consumer := amqp.NewConsumer(newReceiver, func(ctx context.Context, msg *amqp.Message) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, &amqp.ConsumerOptions{
+		Checkpoints: &amqp.CheckpointOptions{Store: store, Source: "in", Interval: 2},
+	})
+	require.NoError(t, consumer.Start())
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&handled) == 4 }, 5*time.Second, time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, consumer.Stop(ctx))
+
+	// the next receiver starts after the last message handled
+	store, err = amqp.NewFileCheckpointStore(path)
+	require.NoError(t, err)
+	cp, ok, err := store.Load(ctx, "in")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, amqp.Checkpoint{SequenceNumber: 3, Offset: "1024"}, cp)
+	filters, err := amqp.CheckpointFilters(ctx, store, "in")
+	require.NoError(t, err)
+	require.Len(t, filters, 1)
+	filters, err = amqp.CheckpointFilters(ctx, store, "other")
+	require.NoError(t, err)
+	require.Nil(t, filters)
+}
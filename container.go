@@ -0,0 +1,255 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/Azure/go-amqp/internal/shared"
+)
+
+// ContainerOptions contains the optional settings for configuring a Container.
+type ContainerOptions struct {
+	// ContainerID is the container-id announced by every connection of the container.
+	//
+	// Default: an ID generated with ConnOptions.Rand.
+	ContainerID string
+
+	// ConnOptions are the default options of the connections
+	// dialed by, and accepted by the listeners of, the container.
+	// ConnOptions.ContainerID is ignored.
+	//
+	// Default: nil.
+	ConnOptions *ConnOptions
+}
+
+// ConnHandler receives the events of a connection owned by a Container.
+// Each field is optional.
+type ConnHandler struct {
+	// Opened is called in its own goroutine once the connection has
+	// been opened. ctx is canceled when the container is stopped.
+	//
+	// The connection isn't closed when Opened returns.
+	Opened func(ctx context.Context, conn *Conn)
+
+	// Closed is called once the connection has been closed, with the
+	// error Conn.Close returns.
+	Closed func(conn *Conn, err error)
+}
+
+// Container is an AMQP container owning a set of client and server
+// connections which share a container-id and default options.
+//
+// Connections are dialed with Dial, and accepted from the listeners
+// added with Listen. Stopping the container closes them all.
+type Container struct {
+	id    string
+	idErr error // failure to generate id, returned by Dial and Listen
+	opts  ConnOptions
+
+	ctx    context.Context // canceled by Stop
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // tracks handlers and listener accept loops
+
+	mu        sync.Mutex
+	conns     map[*Conn]struct{}
+	listeners []*Listener
+}
+
+// NewContainer creates a Container without any connections.
+//
+// opts: pass nil to accept the default values.
+func NewContainer(opts *ContainerOptions) *Container {
+	c := &Container{
+		id:    shared.RandString(40),
+		conns: map[*Conn]struct{}{},
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	if opts == nil {
+		return c
+	}
+	if opts.ConnOptions != nil {
+		c.opts = *opts.ConnOptions
+	}
+	if opts.ContainerID != "" {
+		c.id = opts.ContainerID
+	} else if c.opts.Rand != nil {
+		c.id, c.idErr = shared.RandStringFrom(c.opts.Rand, 40)
+		if c.idErr != nil {
+			c.idErr = fmt.Errorf("amqp: generating container-id: %w", c.idErr)
+		}
+	}
+	return c
+}
+
+// ID returns the container-id.
+func (c *Container) ID() string {
+	return c.id
+}
+
+// Conns returns the open connections of the container.
+func (c *Container) Conns() []*Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conns := make([]*Conn, 0, len(c.conns))
+	for conn := range c.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// Dial connects to an AMQP server as Dial does, using the container's
+// container-id, and adds the connection to the container.
+//
+// opts: pass nil to use the container's default options.
+// h: pass nil if the connection's events aren't needed.
+func (c *Container) Dial(addr string, opts *ConnOptions, h *ConnHandler) (*Conn, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, errContainerStopped
+	}
+	if c.idErr != nil {
+		return nil, c.idErr
+	}
+	conn, err := Dial(addr, c.connOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	c.add(conn, h)
+	return conn, nil
+}
+
+// Listen creates a Listener accepting connections from ln as NewListener
+// does, using the container's container-id. Accepted connections are added
+// to the container until it's stopped, which also closes the Listener.
+//
+// opts: pass nil to use the container's default options.
+// h: pass nil if the connections' events aren't needed. Set Opened to
+// serve the sessions the clients begin.
+func (c *Container) Listen(ln net.Listener, opts *ListenerOptions, h *ConnHandler) (*Listener, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, errContainerStopped
+	}
+	if c.idErr != nil {
+		return nil, c.idErr
+	}
+	lopts := ListenerOptions{}
+	if opts != nil {
+		lopts = *opts
+	}
+	lopts.ConnOptions = c.connOptions(lopts.ConnOptions)
+	l, err := NewListener(ln, &lopts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.ctx.Err() != nil {
+		c.mu.Unlock()
+		_ = l.Close()
+		return nil, errContainerStopped
+	}
+	c.listeners = append(c.listeners, l)
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	go func() {
+		defer c.wg.Done()
+		for {
+			conn, err := l.Accept(c.ctx)
+			if err != nil {
+				return
+			}
+			c.add(conn, h)
+		}
+	}()
+	return l, nil
+}
+
+// Run blocks until ctx completes or Stop is called, then stops the
+// container and waits for the Opened handlers to return.
+// It returns ctx.Err() if ctx completed, or nil if Stop was called.
+func (c *Container) Run(ctx context.Context) error {
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-c.ctx.Done():
+	}
+	c.Stop()
+	c.wg.Wait()
+	return err
+}
+
+// Stop closes the listeners and connections of the container and cancels
+// the context passed to Opened handlers. Dial and Listen fail once the
+// container has been stopped. Stop doesn't wait for the handlers to return.
+func (c *Container) Stop() {
+	c.mu.Lock()
+	c.cancel()
+	listeners := c.listeners
+	c.listeners = nil
+	conns := make([]*Conn, 0, len(c.conns))
+	for conn := range c.conns {
+		conns = append(conns, conn)
+	}
+	c.mu.Unlock()
+
+	for _, l := range listeners {
+		_ = l.Close()
+	}
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+}
+
+// connOptions returns opts, or the container's default options if nil, with the container-id set.
+func (c *Container) connOptions(opts *ConnOptions) *ConnOptions {
+	o := c.opts
+	if opts != nil {
+		o = *opts
+	}
+	o.ContainerID = c.id
+	return &o
+}
+
+// add tracks conn until it's closed and calls the handlers of h.
+func (c *Container) add(conn *Conn, h *ConnHandler) {
+	if h == nil {
+		h = &ConnHandler{}
+	}
+
+	c.mu.Lock()
+	stopped := c.ctx.Err() != nil
+	if !stopped {
+		c.conns[conn] = struct{}{}
+		if h.Opened != nil {
+			c.wg.Add(1)
+		}
+	}
+	c.mu.Unlock()
+
+	if stopped {
+		// raced with Stop
+		_ = conn.Close()
+	} else if h.Opened != nil {
+		go func() {
+			defer c.wg.Done()
+			h.Opened(c.ctx, conn)
+		}()
+	}
+
+	go func() {
+		<-conn.done
+		c.mu.Lock()
+		delete(c.conns, conn)
+		c.mu.Unlock()
+		if h.Closed != nil {
+			// the connection has terminated, Close only returns its error
+			h.Closed(conn, conn.Close())
+		}
+	}()
+}
+
+var errContainerStopped = errors.New("amqp: container has been stopped")
@@ -0,0 +1,93 @@
+package amqp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainer(t *testing.T) {
+	server := NewContainer(&ContainerOptions{
+		ContainerID: "server",
+		ConnOptions: &ConnOptions{MaxSessions: 10},
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	sessions := make(chan *Session, 1)
+	l, err := server.Listen(ln, nil, &ConnHandler{
+		Opened: func(ctx context.Context, conn *Conn) {
+			req, err := conn.NextSession(ctx)
+			if err != nil {
+				return
+			}
+			s, err := req.Accept(nil)
+			if err != nil {
+				return
+			}
+			sessions <- s
+		},
+	})
+	require.NoError(t, err)
+
+	ran := make(chan error, 1)
+	go func() { ran <- server.Run(context.Background()) }()
+
+	client := NewContainer(&ContainerOptions{ContainerID: "client"})
+	closed := make(chan error, 1)
+	conn, err := client.Dial("amqp://"+l.Addr().String(), nil, &ConnHandler{
+		Closed: func(conn *Conn, err error) { closed <- err },
+	})
+	require.NoError(t, err)
+	require.Equal(t, []*Conn{conn}, client.Conns())
+
+	stats := conn.Stats()
+	require.Equal(t, "server", stats.PeerContainerID)
+	require.EqualValues(t, 10, stats.ChannelMax)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	select {
+	case s := <-sessions:
+		require.Equal(t, "client", s.conn.Stats().PeerContainerID)
+	case <-ctx.Done():
+		t.Fatal("session wasn't accepted")
+	}
+	require.Len(t, server.Conns(), 1)
+
+	// stopping the server closes its connections and listeners
+	server.Stop()
+	select {
+	case err := <-ran:
+		require.NoError(t, err)
+	case <-ctx.Done():
+		t.Fatal("Run didn't return")
+	}
+	select {
+	case err := <-closed:
+		// the server closed the connection without an error
+		require.NoError(t, err)
+	case <-ctx.Done():
+		t.Fatal("client connection wasn't closed")
+	}
+	require.Empty(t, client.Conns())
+
+	_, err = server.Listen(ln, nil, nil)
+	require.ErrorIs(t, err, errContainerStopped)
+	_, err = net.Dial("tcp", l.Addr().String())
+	require.Error(t, err)
+}
+
+func TestContainerRunContextDone(t *testing.T) {
+	c := NewContainer(nil)
+	require.NotEmpty(t, c.ID())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, c.Run(ctx), context.Canceled)
+	_, err := c.Dial("amqp://127.0.0.1:1", nil, nil)
+	require.ErrorIs(t, err, errContainerStopped)
+}
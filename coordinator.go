@@ -0,0 +1,128 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/go-amqp/internal/encoding"
+)
+
+// TransactionHandler implements the transactions of a Coordinator.
+//
+// Errors of type *Error are reported to the transaction controller as-is,
+// for example with ErrCondTransactionUnknownID or ErrCondTransactionRollback.
+// Other errors are reported with ErrCondInternalError.
+type TransactionHandler interface {
+	// Declare begins a transaction and returns its ID.
+	Declare(ctx context.Context) ([]byte, error)
+
+	// Discharge ends the transaction with the specified ID. Its work is
+	// committed, or rolled back when fail is true.
+	Discharge(ctx context.Context, txnID []byte, fail bool) error
+}
+
+// Coordinator is the server-side end of a link attached by a transaction
+// controller, which declares and discharges transactions through it.
+//
+// The transfers and dispositions that are part of a transaction are received
+// on other links. See Message.TransactionID and Outcome.TransactionID.
+type Coordinator struct {
+	rcv *Receiver
+}
+
+// AcceptCoordinator attaches the link as a Coordinator. The request's Coordinator field must be true.
+//
+// The options are the same as for AcceptReceiver. If an error is returned, the request is rejected.
+//
+// opts: pass nil to accept the default values.
+func (r *LinkRequest) AcceptCoordinator(opts *ReceiverOptions) (_ *Coordinator, err error) {
+	defer func() { err = r.session.conn.translateErr(err) }()
+
+	err = errors.New("amqp: link request already accepted or rejected")
+	var rcv *Receiver
+	r.once.Do(func() {
+		if !r.Coordinator {
+			err = errors.New("amqp: the peer didn't attach as a transaction controller")
+		} else {
+			rcv, err = r.acceptReceiver(opts, true)
+		}
+		if err != nil {
+			r.reject(&Error{Condition: ErrCondNotAllowed, Description: err.Error()})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Coordinator{rcv: rcv}, nil
+}
+
+// Serve passes the declare and discharge requests received from the transaction
+// controller to h, and settles them with the results, until the link is detached
+// or ctx completes. Requests are handled one at a time, in the order they are
+// received. It returns the error that caused it to stop.
+func (c *Coordinator) Serve(ctx context.Context, h TransactionHandler) error {
+	for {
+		msg, err := c.rcv.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		if err := c.handle(ctx, h, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle passes a single request to h and settles it.
+func (c *Coordinator) handle(ctx context.Context, h TransactionHandler, msg *Message) (err error) {
+	defer func() { err = c.rcv.l.translateErr(err) }()
+
+	var state encoding.DeliveryState
+	switch req := msg.Value.(type) {
+	case *encoding.Declare:
+		if req.GlobalID != nil {
+			state = &encoding.StateRejected{Error: &Error{
+				Condition:   ErrCondNotImplemented,
+				Description: "global transactions are not supported",
+			}}
+			break
+		}
+		txnID, err := h.Declare(ctx)
+		if err != nil {
+			state = &encoding.StateRejected{Error: transactionError(err)}
+			break
+		}
+		state = &encoding.StateDeclared{TxnID: txnID}
+	case *encoding.Discharge:
+		if err := h.Discharge(ctx, req.TxnID, req.Fail); err != nil {
+			state = &encoding.StateRejected{Error: transactionError(err)}
+			break
+		}
+		state = &encoding.StateAccepted{}
+	default:
+		state = &encoding.StateRejected{Error: &Error{
+			Condition:   ErrCondDecodeError,
+			Description: "expected a declare or discharge message",
+		}}
+	}
+
+	if !msg.shouldSendDisposition() {
+		return nil
+	}
+	return c.rcv.messageDisposition(ctx, msg, state)
+}
+
+// transactionError converts an error returned by a TransactionHandler.
+func transactionError(err error) *Error {
+	var amqpErr *Error
+	if errors.As(err, &amqpErr) {
+		return amqpErr
+	}
+	return &Error{Condition: ErrCondInternalError, Description: err.Error()}
+}
+
+// Close detaches the link.
+//
+// If ctx expires while waiting for the peer's response, ctx.Err() will be returned.
+func (c *Coordinator) Close(ctx context.Context) error {
+	return c.rcv.Close(ctx)
+}
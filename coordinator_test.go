@@ -0,0 +1,156 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp/internal/encoding"
+	"github.com/Azure/go-amqp/internal/frames"
+	"github.com/stretchr/testify/require"
+)
+
+type testTxnHandler struct {
+	mu         sync.Mutex
+	declared   int
+	discharged map[string]bool // txn ID to fail
+}
+
+func (h *testTxnHandler) Declare(ctx context.Context) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.declared++
+	return []byte(fmt.Sprintf("txn-%d", h.declared)), nil
+}
+
+func (h *testTxnHandler) Discharge(ctx context.Context, txnID []byte, fail bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var n int
+	if _, err := fmt.Sscanf(string(txnID), "txn-%d", &n); err != nil || n < 1 || n > h.declared {
+		return &Error{Condition: ErrCondTransactionUnknownID}
+	}
+	if h.discharged == nil {
+		h.discharged = map[string]bool{}
+	}
+	h.discharged[string(txnID)] = fail
+	return nil
+}
+
+// newTestController attaches a sender to the transaction coordinator of the peer.
+func newTestController(ctx context.Context, session *Session) (*Sender, error) {
+	s, err := newSender("", session, &SenderOptions{IgnoreDispositionErrors: true})
+	if err != nil {
+		return nil, err
+	}
+	s.l.rx = make(chan frames.FrameBody, 1)
+	if err := s.l.attach(ctx, func(pa *frames.PerformAttach) {
+		pa.Role = encoding.RoleSender
+		pa.Target = nil
+		pa.Coordinator = &frames.Coordinator{
+			Capabilities: encoding.MultiSymbol{"amqp:local-transactions"},
+		}
+	}, func(*frames.PerformAttach) {}); err != nil {
+		return nil, err
+	}
+	s.transfers = make(chan frames.PerformTransfer)
+	go s.mux()
+	return s, nil
+}
+
+func TestCoordinator(t *testing.T) {
+	client, server := newTestServerConn(t)
+	clientSession, serverSession := acceptTestSession(t, client, server)
+
+	h := &testTxnHandler{}
+	served := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, err := serverSession.NextLink(ctx)
+		if err != nil {
+			served <- err
+			return
+		}
+		if !req.Coordinator {
+			served <- errors.New("expected a coordinator request")
+			return
+		}
+		_, err = req.AcceptReceiver(nil)
+		served <- err
+	}()
+
+	// the controller is rejected when accepted as a receiver
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	_, err := newTestController(ctx, clientSession)
+	cancel()
+	require.Error(t, err)
+	require.EqualError(t, <-served, "amqp: the peer attached as a transaction controller, use AcceptCoordinator")
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, err := serverSession.NextLink(ctx)
+		if err != nil {
+			served <- err
+			return
+		}
+		c, err := req.AcceptCoordinator(nil)
+		if err != nil {
+			served <- err
+			return
+		}
+		served <- c.Serve(ctx, h)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	controller, err := newTestController(ctx, clientSession)
+	require.NoError(t, err)
+
+	state, err := controller.sendAndWait(ctx, &Message{Value: &encoding.Declare{}})
+	require.NoError(t, err)
+	declared, ok := state.(*encoding.StateDeclared)
+	require.True(t, ok, "unexpected state %v", state)
+	require.Equal(t, []byte("txn-1"), declared.TxnID)
+
+	state, err = controller.sendAndWait(ctx, &Message{Value: &encoding.Discharge{TxnID: declared.TxnID, Fail: true}})
+	require.NoError(t, err)
+	require.IsType(t, &encoding.StateAccepted{}, state)
+	h.mu.Lock()
+	require.Equal(t, map[string]bool{"txn-1": true}, h.discharged)
+	h.mu.Unlock()
+
+	state, err = controller.sendAndWait(ctx, &Message{Value: &encoding.Discharge{TxnID: []byte("txn-2")}})
+	require.NoError(t, err)
+	rejected, ok := state.(*encoding.StateRejected)
+	require.True(t, ok, "unexpected state %v", state)
+	require.Equal(t, ErrCondTransactionUnknownID, rejected.Error.Condition)
+
+	state, err = controller.sendAndWait(ctx, NewMessage([]byte("not a request")))
+	require.NoError(t, err)
+	rejected, ok = state.(*encoding.StateRejected)
+	require.True(t, ok, "unexpected state %v", state)
+	require.Equal(t, ErrCondDecodeError, rejected.Error.Condition)
+
+	require.NoError(t, controller.Close(ctx))
+	var detachErr *DetachError
+	require.ErrorAs(t, <-served, &detachErr)
+}
+
+func TestOutcomeTransactional(t *testing.T) {
+	outcome := newOutcome(&encoding.StateTransactional{
+		TxnID:   []byte("txn-1"),
+		Outcome: &encoding.StateRejected{Error: &Error{Condition: ErrCondInvalidField}},
+	})
+	require.Equal(t, OutcomeRejected, outcome.Type)
+	require.Equal(t, ErrCondInvalidField, outcome.Error.Condition)
+	require.Equal(t, []byte("txn-1"), outcome.TransactionID)
+
+	outcome = newOutcome(&encoding.StateTransactional{TxnID: []byte("txn-1")})
+	require.Equal(t, OutcomeUnknown, outcome.Type)
+	require.Equal(t, []byte("txn-1"), outcome.TransactionID)
+}
@@ -0,0 +1,102 @@
+package amqp
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// debugDumpTimeout bounds how long DebugDump waits for busy goroutines.
+const debugDumpTimeout = time.Second
+
+// DebugDump writes a snapshot of the connection's state to w, including
+// all sessions and links with their flow control windows, link credit,
+// and counts of unsettled deliveries. It's intended to be attached to
+// bug reports, especially when an operation appears to hang.
+//
+// Session and link state is owned by internal goroutines. DebugDump waits
+// up to one second for them to respond; goroutines that don't respond in
+// time are reported as not responding, which usually identifies where
+// the connection is stuck.
+//
+// The format is intended for humans and may change between releases.
+func (c *Conn) DebugDump(w io.Writer) error {
+	deadline := time.After(debugDumpTimeout)
+	var sb strings.Builder
+
+	state := "open"
+	select {
+	case <-c.done:
+		state = fmt.Sprintf("closed (%v)", c.doneErr)
+	default:
+	}
+	stats := c.Stats()
+	fmt.Fprintf(&sb, "conn containerID=%s peerContainerID=%s hostname=%s state=%s\n", c.containerID, stats.PeerContainerID, c.hostname, state)
+	fmt.Fprintf(&sb, "  maxFrameSize=%d peerMaxFrameSize=%d channelMax=%d idleTimeout=%s peerIdleTimeout=%s\n",
+		stats.MaxFrameSize, stats.PeerMaxFrameSize, stats.ChannelMax, stats.IdleTimeout, stats.PeerIdleTimeout)
+	fmt.Fprintf(&sb, "  bytesRead=%d bytesWritten=%d framesRead=%d framesWritten=%d lastRead=%s lastWrite=%s\n",
+		stats.BytesRead, stats.BytesWritten, stats.FramesRead, stats.FramesWritten, formatDumpTime(stats.LastRead), formatDumpTime(stats.LastWrite))
+
+	c.sessionsByChannelMu.RLock()
+	sessions := make([]*Session, 0, len(c.sessionsByChannel))
+	for _, s := range c.sessionsByChannel {
+		sessions = append(sessions, s)
+	}
+	c.sessionsByChannelMu.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].channel < sessions[j].channel })
+
+	for _, s := range sessions {
+		fmt.Fprintf(&sb, "  session channel=%d %s\n", s.channel, debugQuery(s.debugReq, s.done, deadline))
+
+		s.linksMu.RLock()
+		links := make([]*link, 0, len(s.linksByKey))
+		for _, l := range s.linksByKey {
+			links = append(links, l)
+		}
+		s.linksMu.RUnlock()
+		sort.Slice(links, func(i, j int) bool { return links[i].key.name < links[j].key.name })
+
+		for _, l := range links {
+			fmt.Fprintf(&sb, "    link name=%s role=%s %s\n", l.key.name, strings.ToLower(l.key.role.String()), debugQuery(l.debugReq, l.detached, deadline))
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// debugQuery requests a description of its state from the goroutine servicing req.
+func debugQuery(req chan chan string, done <-chan struct{}, deadline <-chan time.Time) string {
+	resp := make(chan string, 1)
+	select {
+	case req <- resp:
+		return <-resp
+	case <-done:
+		return "state=exited"
+	case <-deadline:
+		return "state=not-responding"
+	}
+}
+
+func formatDumpTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// debugState describes the link's state. It must only be called from the link's mux.
+func (l *link) debugState() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "handle=%d remoteHandle=%d", l.handle, l.remoteHandle)
+	if l.source != nil && l.source.Address != "" {
+		fmt.Fprintf(&sb, " source=%q", l.source.Address)
+	}
+	if l.target != nil && l.target.Address != "" {
+		fmt.Fprintf(&sb, " target=%q", l.target.Address)
+	}
+	fmt.Fprintf(&sb, " credit=%d deliveryCount=%d", l.availableCredit, l.deliveryCount)
+	return sb.String()
+}
@@ -0,0 +1,212 @@
+package amqp
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/go-amqp/internal/clock"
+	"github.com/Azure/go-amqp/internal/encoding"
+)
+
+// DuplicatePolicy determines how a Receiver settles the duplicates detected
+// by its DedupCache.
+type DuplicatePolicy uint8
+
+const (
+	// DuplicateAccept settles duplicates with the accepted outcome, so
+	// they're removed from the source.
+	DuplicateAccept DuplicatePolicy = iota
+
+	// DuplicateRelease settles duplicates with the released outcome.
+	DuplicateRelease
+)
+
+// DedupCacheOptions contains the optional settings for configuring a
+// DedupCache.
+type DedupCacheOptions struct {
+	// Property is the application property holding the key of a message.
+	// Messages without the property aren't checked for duplicates.
+	//
+	// Default: "" (the message-id property).
+	Property string
+
+	// MaxEntries is the maximum number of keys recorded. Once reached, the
+	// least recently seen key is evicted.
+	//
+	// Default: 10000.
+	MaxEntries int
+
+	// TTL is how long a key is recorded after its message was accepted.
+	//
+	// Default: 0 (keys are only evicted by MaxEntries).
+	TTL time.Duration
+
+	// Duplicates determines how duplicates are settled.
+	//
+	// Default: DuplicateAccept.
+	Duplicates DuplicatePolicy
+
+	// Clock provides the current time used to expire keys.
+	//
+	// Default: the system clock.
+	Clock Clock
+}
+
+// DedupCache records the keys of the messages accepted by receivers, for
+// consumers of at-least-once links that need to process each message once.
+// Messages received with a key already recorded are settled as configured
+// by DedupCacheOptions.Duplicates, instead of being returned from Receive.
+//
+// A DedupCache can be shared by multiple receivers, including the ones
+// recreated after a failure. See ReceiverOptions.Dedup.
+type DedupCache struct {
+	property   string
+	maxEntries int
+	ttl        time.Duration
+	duplicates DuplicatePolicy
+	clock      clock.Clock
+
+	detected uint64 // updated atomically
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List // of *dedupEntry, most recently seen first
+}
+
+// dedupEntry is a key recorded by a DedupCache.
+type dedupEntry struct {
+	key     string
+	expires time.Time // zero when keys don't expire
+}
+
+// NewDedupCache creates an empty DedupCache.
+//
+// opts: pass nil to accept the default values.
+func NewDedupCache(opts *DedupCacheOptions) (*DedupCache, error) {
+	c := &DedupCache{
+		maxEntries: 10000,
+		clock:      clock.Real,
+		entries:    map[string]*list.Element{},
+	}
+	if opts != nil {
+		c.property = opts.Property
+		if opts.MaxEntries < 0 {
+			return nil, fmt.Errorf("invalid MaxEntries value %d", opts.MaxEntries)
+		} else if opts.MaxEntries > 0 {
+			c.maxEntries = opts.MaxEntries
+		}
+		if opts.TTL < 0 {
+			return nil, fmt.Errorf("invalid TTL value %d", opts.TTL)
+		}
+		c.ttl = opts.TTL
+		if opts.Duplicates > DuplicateRelease {
+			return nil, fmt.Errorf("invalid Duplicates value %d", opts.Duplicates)
+		}
+		c.duplicates = opts.Duplicates
+		if opts.Clock != nil {
+			c.clock = opts.Clock
+		}
+	}
+	return c, nil
+}
+
+// Len returns the number of keys recorded, including expired ones not
+// evicted yet.
+func (c *DedupCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Duplicates returns the number of duplicates detected.
+func (c *DedupCache) Duplicates() uint64 {
+	return atomic.LoadUint64(&c.detected)
+}
+
+// seen returns true if the key of msg is recorded.
+func (c *DedupCache) seen(msg *Message) bool {
+	key, ok := c.key(msg)
+	if !ok {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	if entry := elem.Value.(*dedupEntry); !entry.expires.IsZero() && !c.clock.Now().Before(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return false
+	}
+	c.lru.MoveToFront(elem)
+	atomic.AddUint64(&c.detected, 1)
+	return true
+}
+
+// accepted records the key of msg, which was accepted.
+func (c *DedupCache) accepted(msg *Message) {
+	if c == nil {
+		return
+	}
+	key, ok := c.key(msg)
+	if !ok {
+		return
+	}
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = c.clock.Now().Add(c.ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*dedupEntry).expires = expires
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&dedupEntry{key: key, expires: expires})
+	for len(c.entries) > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*dedupEntry).key)
+	}
+}
+
+// duplicateState returns the delivery state settling duplicates.
+func (c *DedupCache) duplicateState() encoding.DeliveryState {
+	if c.duplicates == DuplicateRelease {
+		return &encoding.StateReleased{}
+	}
+	return &encoding.StateAccepted{}
+}
+
+// key returns the key of msg, and false if it has none.
+// Keys of different types don't collide.
+func (c *DedupCache) key(msg *Message) (string, bool) {
+	var v any
+	if c.property == "" {
+		if msg.Properties != nil {
+			v = msg.Properties.MessageID
+		}
+	} else {
+		v = msg.ApplicationProperties[c.property]
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return "s:" + v, true
+	case []byte:
+		return "b:" + string(v), true
+	default:
+		return fmt.Sprintf("%T:%v", v, v), true
+	}
+}
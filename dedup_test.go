@@ -0,0 +1,54 @@
+package amqp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupCache(t *testing.T) {
+	clk := mocks.NewFakeClock(time.Now())
+	c, err := NewDedupCache(&DedupCacheOptions{MaxEntries: 2, TTL: time.Minute, Clock: clk})
+	require.NoError(t, err)
+	withID := func(id any) *Message {
+		return &Message{Properties: &MessageProperties{MessageID: id}}
+	}
+
+	c.accepted(withID("a"))
+	c.accepted(withID([]byte("a")))
+	c.accepted(&Message{})
+	require.Equal(t, 2, c.Len())
+	require.True(t, c.seen(withID("a")))
+	require.True(t, c.seen(withID([]byte("a"))))
+	require.False(t, c.seen(withID(uint64(1))))
+	require.False(t, c.seen(&Message{}))
+
+	// the least recently seen key is evicted
+	c.seen(withID("a"))
+	c.accepted(withID(uint64(1)))
+	require.Equal(t, 2, c.Len())
+	require.True(t, c.seen(withID("a")))
+	require.False(t, c.seen(withID([]byte("a"))))
+
+	// keys expire after the TTL
+	clk.Advance(time.Minute)
+	require.False(t, c.seen(withID("a")))
+	require.Equal(t, 1, c.Len())
+	require.EqualValues(t, 4, c.Duplicates())
+
+	// keys can be read from an application property
+	c, err = NewDedupCache(&DedupCacheOptions{Property: "order"})
+	require.NoError(t, err)
+	c.accepted(withID("a"))
+	c.accepted(&Message{ApplicationProperties: map[string]any{"order": int64(42)}})
+	require.Equal(t, 1, c.Len())
+	require.True(t, c.seen(&Message{ApplicationProperties: map[string]any{"order": int64(42)}}))
+	require.False(t, c.seen(&Message{ApplicationProperties: map[string]any{"order": "42"}}))
+
+	_, err = NewDedupCache(&DedupCacheOptions{MaxEntries: -1})
+	require.Error(t, err)
+	_, err = NewDedupCache(&DedupCacheOptions{Duplicates: DuplicateRelease + 1})
+	require.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package amqp
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// deliveryShards is the number of shards of a deliveryMap.
+// Consecutive delivery IDs are in different shards.
+const deliveryShards = 16
+
+// deliveryMap maps delivery IDs to values. It's split into shards with
+// their own lock, so that concurrent settlements of different deliveries
+// rarely contend. The zero value is ready to use.
+type deliveryMap[V any] struct {
+	count  int32 // accessed atomically
+	shards [deliveryShards]deliveryShard[V]
+}
+
+type deliveryShard[V any] struct {
+	mu sync.Mutex
+	m  map[uint32]V
+}
+
+func (d *deliveryMap[V]) shard(id uint32) *deliveryShard[V] {
+	return &d.shards[id%deliveryShards]
+}
+
+// store sets the value of id.
+func (d *deliveryMap[V]) store(id uint32, v V) {
+	s := d.shard(id)
+	s.mu.Lock()
+	if s.m == nil {
+		s.m = map[uint32]V{}
+	}
+	if _, ok := s.m[id]; !ok {
+		atomic.AddInt32(&d.count, 1)
+	}
+	s.m[id] = v
+	s.mu.Unlock()
+}
+
+// remove deletes id, returning its value and true if it was present.
+func (d *deliveryMap[V]) remove(id uint32) (V, bool) {
+	s := d.shard(id)
+	s.mu.Lock()
+	v, ok := s.m[id]
+	if ok {
+		delete(s.m, id)
+		atomic.AddInt32(&d.count, -1)
+	}
+	s.mu.Unlock()
+	return v, ok
+}
+
+// drain deletes every delivery, calling fn with each of them.
+// fn must not call methods of d.
+func (d *deliveryMap[V]) drain(fn func(id uint32, v V)) {
+	for i := range d.shards {
+		s := &d.shards[i]
+		s.mu.Lock()
+		for id, v := range s.m {
+			delete(s.m, id)
+			atomic.AddInt32(&d.count, -1)
+			fn(id, v)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// len returns the number of deliveries.
+func (d *deliveryMap[V]) len() int {
+	return int(atomic.LoadInt32(&d.count))
+}
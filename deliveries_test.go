@@ -0,0 +1,52 @@
+package amqp
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryMap(t *testing.T) {
+	var d deliveryMap[int]
+	require.Zero(t, d.len())
+	_, ok := d.remove(1)
+	require.False(t, ok)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				id := uint32(w*100 + i)
+				d.store(id, int(id))
+				if i%2 == 0 {
+					v, ok := d.remove(id)
+					require.True(t, ok)
+					require.Equal(t, int(id), v)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	require.Equal(t, 200, d.len())
+
+	// storing an existing delivery replaces its value
+	d.store(1, -1)
+	require.Equal(t, 200, d.len())
+
+	var ids []int
+	d.drain(func(id uint32, v int) {
+		if id == 1 {
+			require.Equal(t, -1, v)
+		}
+		ids = append(ids, int(id))
+	})
+	require.Zero(t, d.len())
+	require.Len(t, ids, 200)
+	sort.Ints(ids)
+	require.Equal(t, 1, ids[0])
+	require.Equal(t, 399, ids[199])
+}
@@ -1,17 +1,32 @@
 package amqp
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/Azure/go-amqp/internal/buffer"
 	"github.com/Azure/go-amqp/internal/encoding"
+	"github.com/Azure/go-amqp/internal/frames"
 )
 
 // ErrCond is an AMQP defined error condition.
 // See http://docs.oasis-open.org/amqp/core/v1.0/os/amqp-core-transport-v1.0-os.html#type-amqp-error for info on their meaning.
+//
+// ErrCond implements the error interface so it can be used with errors.Is
+// to check the condition of an *Error, or of the RemoteErr of a *ConnError,
+// *SessionError, or *DetachError, anywhere in an error chain.
 type ErrCond = encoding.ErrCond
 
 // Error Conditions
 const (
 	// AMQP Errors
 	ErrCondDecodeError           ErrCond = "amqp:decode-error"
+	ErrCondFrameSizeTooLarge     ErrCond = "amqp:frame-size-too-large"
 	ErrCondFrameSizeTooSmall     ErrCond = "amqp:frame-size-too-small"
 	ErrCondIllegalState          ErrCond = "amqp:illegal-state"
 	ErrCondInternalError         ErrCond = "amqp:internal-error"
@@ -42,6 +57,11 @@ const (
 	ErrCondMessageSizeExceeded   ErrCond = "amqp:link:message-size-exceeded"
 	ErrCondStolen                ErrCond = "amqp:link:stolen"
 	ErrCondTransferLimitExceeded ErrCond = "amqp:link:transfer-limit-exceeded"
+
+	// Transaction Errors
+	ErrCondTransactionRollback  ErrCond = "amqp:transaction:rollback"
+	ErrCondTransactionTimeout   ErrCond = "amqp:transaction:timeout"
+	ErrCondTransactionUnknownID ErrCond = "amqp:transaction:unknown-id"
 )
 
 // Error is an AMQP error.
@@ -52,12 +72,22 @@ type DetachError struct {
 	// RemoteErr contains any error information provided by the peer if the peer detached the link.
 	RemoteErr *Error
 
-	inner error
+	// Suspended is true if the link was detached without being closed,
+	// by Sender.Detach or Receiver.Detach or by the peer. The peer retains
+	// the link's terminus state, and the link can be resumed with
+	// Sender.Reattach or Receiver.Reattach.
+	Suspended bool
+
+	inner  error
+	remote bool // detached by the peer without an error
 }
 
 // Error implements the error interface for DetachError.
 func (e *DetachError) Error() string {
 	if e.RemoteErr == nil && e.inner == nil {
+		if e.Suspended {
+			return "amqp: link detached"
+		}
 		return "amqp: link closed"
 	} else if e.RemoteErr != nil {
 		return e.RemoteErr.Error()
@@ -65,6 +95,258 @@ func (e *DetachError) Error() string {
 	return e.inner.Error()
 }
 
+// Unwrap returns the RemoteErr if set, else the underlying error, if any.
+//
+// This allows errors.Is to match error conditions, e.g.
+// errors.Is(err, ErrCondNotFound), and errors.As to retrieve the
+// *Error or any wrapped error.
+func (e *DetachError) Unwrap() error {
+	if e.RemoteErr != nil {
+		return e.RemoteErr
+	}
+	return e.inner
+}
+
+// LinkStolenError is returned by methods on Sender/Receiver when the peer detached
+// the link because another client attached a link with the same name (or, for
+// brokers that support it, a higher epoch). It's also returned from
+// Session.NewSender/NewReceiver when the peer refuses the attach for the same
+// reason, such as a receiver attaching with a lower epoch than the current one.
+//
+// Retrying is unlikely to succeed as the other client now owns the link.
+//
+// LinkStolenError wraps a *DetachError, so errors.As can be used to
+// retrieve either type.
+type LinkStolenError struct {
+	// RemoteErr contains the error information provided by the peer.
+	RemoteErr *Error
+}
+
+// Error implements the error interface for LinkStolenError.
+func (e *LinkStolenError) Error() string {
+	return e.RemoteErr.Error()
+}
+
+// Unwrap returns the *DetachError for the link.
+func (e *LinkStolenError) Unwrap() error {
+	return &DetachError{RemoteErr: e.RemoteErr}
+}
+
+// LinkRedirectError is returned when the peer detaches a link with the
+// amqp:link:redirect condition, indicating the link should be attached to
+// a different node.
+//
+// It's returned from Session.NewSender/NewReceiver when the redirect occurs
+// during attach, and from methods on Sender/Receiver afterwards. See
+// SenderOptions.FollowRedirects and ReceiverOptions.FollowRedirects to
+// follow redirects to a node on the same connection automatically.
+type LinkRedirectError struct {
+	// RemoteErr contains the error information provided by the peer.
+	RemoteErr *Error
+
+	// Hostname is the hostname of the container hosting the node to
+	// redirect to. It's used as the hostname in the open frame when
+	// connecting to it. Empty if not provided.
+	Hostname string
+
+	// NetworkHost is the DNS hostname or IP address of the machine
+	// hosting the node. Empty if not provided.
+	NetworkHost string
+
+	// Port is the port number on NetworkHost. Zero if not provided.
+	Port uint16
+
+	// Address is the address of the node to redirect to.
+	// Empty if not provided.
+	Address string
+
+	err error // the error wrapped by LinkRedirectError
+}
+
+// Error implements the error interface for LinkRedirectError.
+func (e *LinkRedirectError) Error() string {
+	return e.RemoteErr.Error()
+}
+
+// Unwrap returns the underlying error. This is a *DetachError when the link
+// was detached after being attached, else the *Error sent by the peer.
+func (e *LinkRedirectError) Unwrap() error {
+	return e.err
+}
+
+// newLinkRedirectError creates a *LinkRedirectError from remoteErr, wrapping err.
+func newLinkRedirectError(remoteErr *Error, err error) *LinkRedirectError {
+	e := &LinkRedirectError{RemoteErr: remoteErr, err: err}
+	e.Hostname, _ = remoteErr.Info["hostname"].(string)
+	e.NetworkHost, _ = remoteErr.Info["network-host"].(string)
+	e.Address, _ = remoteErr.Info["address"].(string)
+	switch port := remoteErr.Info["port"].(type) {
+	case uint16:
+		e.Port = port
+	case uint32:
+		e.Port = uint16(port)
+	case int32:
+		e.Port = uint16(port)
+	case int64:
+		e.Port = uint16(port)
+	case uint64:
+		e.Port = uint16(port)
+	}
+	return e
+}
+
+// remoteDetachError returns the error for a link the peer detached with remoteErr.
+func remoteDetachError(remoteErr *Error) error {
+	if isLinkStolen(remoteErr) {
+		return &LinkStolenError{RemoteErr: remoteErr}
+	}
+	detachErr := &DetachError{RemoteErr: remoteErr}
+	if remoteErr.Condition == ErrCondLinkRedirect {
+		return newLinkRedirectError(remoteErr, detachErr)
+	}
+	return detachErr
+}
+
+// isLinkStolen returns true if remoteErr indicates the link was taken over
+// by another client. Besides amqp:link:stolen, some brokers use
+// amqp:link:detach-forced with a description that explains the takeover.
+func isLinkStolen(remoteErr *Error) bool {
+	switch remoteErr.Condition {
+	case ErrCondStolen:
+		return true
+	case ErrCondDetachForced:
+		desc := strings.ToLower(remoteErr.Description)
+		return strings.Contains(desc, "stolen") || strings.Contains(desc, "epoch")
+	default:
+		return false
+	}
+}
+
+// maxFrameDumpSize is the maximum number of frame bytes captured in a FrameError.
+const maxFrameDumpSize = 256
+
+// FrameError describes a malformed or unexpected frame received from the peer
+// that caused a connection or session to be torn down.
+//
+// It's returned wrapped in a ConnError or SessionError; use errors.As to
+// retrieve it. The details are intended for reporting to the peer's vendor.
+type FrameError struct {
+	// Channel is the channel number the frame was received on.
+	Channel uint16
+
+	// Summary is a description of the decoded frame body.
+	// It's empty if the frame body could not be decoded.
+	Summary string
+
+	// Data contains up to the first 256 bytes of the frame body. It's the
+	// body as received if it could not be decoded, otherwise it's the
+	// decoded body encoded again, which may differ from what was received.
+	Data []byte
+
+	// Truncated is true if the frame body was larger than Data.
+	Truncated bool
+
+	inner error
+}
+
+// newFrameError creates a FrameError for the frame on channel. raw is the
+// encoded frame body if available; otherwise body is re-encoded, so the
+// received bytes aren't kept for every frame in case one is faulty.
+func newFrameError(channel uint16, body frames.FrameBody, raw []byte, err error) *FrameError {
+	fe := &FrameError{Channel: channel, inner: err}
+	if body != nil {
+		fe.Summary = fmt.Sprintf("%v", body)
+		if raw == nil {
+			buf := &buffer.Buffer{}
+			if encoding.Marshal(buf, body) == nil {
+				raw = buf.Bytes()
+			}
+		}
+	}
+	if len(raw) > maxFrameDumpSize {
+		raw = raw[:maxFrameDumpSize]
+		fe.Truncated = true
+	}
+	// copy as raw may alias the connection's read buffer
+	fe.Data = append([]byte(nil), raw...)
+	return fe
+}
+
+// Dump returns a hex dump of Data in the format of encoding/hex.Dump.
+func (e *FrameError) Dump() string {
+	return hex.Dump(e.Data)
+}
+
+// Error implements the error interface for FrameError.
+func (e *FrameError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "amqp: protocol error on channel %d: %v", e.Channel, e.inner)
+	if e.Summary != "" {
+		fmt.Fprintf(&sb, "; frame: %s", e.Summary)
+	}
+	if len(e.Data) > 0 {
+		fmt.Fprintf(&sb, "; data: %x", e.Data)
+		if e.Truncated {
+			sb.WriteString("...")
+		}
+	}
+	return sb.String()
+}
+
+// Unwrap returns the underlying decoding or protocol error.
+func (e *FrameError) Unwrap() error {
+	return e.inner
+}
+
+// FrameSizeError is the error a connection is closed with when the peer
+// sends a frame larger than the max-frame-size advertised to it. The peer
+// is sent an amqp:frame-size-too-large error.
+type FrameSizeError struct {
+	// Channel is the channel number the frame was received on.
+	Channel uint16
+
+	// Size is the size of the frame announced in its header.
+	Size uint32
+
+	// MaxFrameSize is the max-frame-size advertised to the peer.
+	MaxFrameSize uint32
+}
+
+// Error implements the error interface for FrameSizeError.
+func (e *FrameSizeError) Error() string {
+	return fmt.Sprintf("amqp: received frame of %d bytes on channel %d, larger than max-frame-size %d", e.Size, e.Channel, e.MaxFrameSize)
+}
+
+// MessageSizeError is returned when a receiver detaches because it received
+// a message larger than ReceiverOptions.MaxMessageSize, wrapped in a
+// *DetachError, and is the Err of LinkEventMessageSizeExceeded events.
+//
+// It wraps the *Error sent to the peer, so errors.Is can be used to check
+// for ErrCondMessageSizeExceeded.
+type MessageSizeError struct {
+	// DeliveryID is the delivery-id of the oversized message.
+	DeliveryID uint32
+
+	// Size is the size of the message received when the limit was
+	// exceeded. The whole message may be larger.
+	Size uint64
+
+	// MaxMessageSize is the limit that was exceeded.
+	MaxMessageSize uint64
+
+	inner *Error
+}
+
+// Error implements the error interface for MessageSizeError.
+func (e *MessageSizeError) Error() string {
+	return fmt.Sprintf("%s: message of delivery %d is larger than max size of %d", ErrCondMessageSizeExceeded, e.DeliveryID, e.MaxMessageSize)
+}
+
+// Unwrap returns the *Error sent to the peer.
+func (e *MessageSizeError) Unwrap() error {
+	return e.inner
+}
+
 // ConnError is returned by methods on Conn and propagated to Session and Senders/Receivers
 // when the connection has been closed.
 type ConnError struct {
@@ -84,6 +366,14 @@ func (e *ConnError) Error() string {
 	return e.inner.Error()
 }
 
+// Unwrap returns the RemoteErr if set, else the underlying error, if any.
+func (e *ConnError) Unwrap() error {
+	if e.RemoteErr != nil {
+		return e.RemoteErr
+	}
+	return e.inner
+}
+
 // SessionError is returned by methods on Session and propagated to Senders/Receivers
 // when the session has been closed.
 type SessionError struct {
@@ -102,3 +392,215 @@ func (e *SessionError) Error() string {
 	}
 	return e.inner.Error()
 }
+
+// Unwrap returns the RemoteErr if set, else the underlying error, if any.
+func (e *SessionError) Unwrap() error {
+	if e.RemoteErr != nil {
+		return e.RemoteErr
+	}
+	return e.inner
+}
+
+// ErrorInfo returns the Info map of the *Error sent by the peer that
+// caused err. err can be a *ConnError, *SessionError, *DetachError, or
+// *Error, or wrap one of them. Brokers use the Info map to convey details
+// such as tracking IDs, retry-after hints, or redirect targets.
+//
+// The boolean result is false if err doesn't carry an *Error.
+func ErrorInfo(err error) (map[string]any, bool) {
+	if amqpErr := remoteError(err); amqpErr != nil {
+		return amqpErr.Info, true
+	}
+	return nil, false
+}
+
+// remoteError returns the *Error carried by err or nil.
+func remoteError(err error) *Error {
+	var connErr *ConnError
+	if errors.As(err, &connErr) {
+		return connErr.RemoteErr
+	}
+	var sessionErr *SessionError
+	if errors.As(err, &sessionErr) {
+		return sessionErr.RemoteErr
+	}
+	var detachErr *DetachError
+	if errors.As(err, &detachErr) {
+		if detachErr.RemoteErr != nil {
+			return detachErr.RemoteErr
+		}
+		return remoteError(detachErr.inner)
+	}
+	var amqpErr *Error
+	if errors.As(err, &amqpErr) {
+		return amqpErr
+	}
+	return nil
+}
+
+// ErrorSeverity describes what, if anything, needs to be recreated before
+// retrying an operation that failed with an error.
+type ErrorSeverity int
+
+const (
+	// ErrorSeverityNone is returned for a nil error.
+	ErrorSeverityNone ErrorSeverity = iota
+
+	// ErrorSeverityRetry indicates the error is transient and the
+	// operation can be retried on the same link.
+	ErrorSeverityRetry
+
+	// ErrorSeverityRetryLink indicates the link (and its session, if it
+	// was also closed) must be recreated before retrying.
+	ErrorSeverityRetryLink
+
+	// ErrorSeverityRetryConn indicates the connection must be recreated
+	// before retrying.
+	ErrorSeverityRetryConn
+
+	// ErrorSeverityFatal indicates the operation should not be retried.
+	ErrorSeverityFatal
+)
+
+// String implements the fmt.Stringer interface for ErrorSeverity.
+func (s ErrorSeverity) String() string {
+	switch s {
+	case ErrorSeverityNone:
+		return "none"
+	case ErrorSeverityRetry:
+		return "retry"
+	case ErrorSeverityRetryLink:
+		return "retry-link"
+	case ErrorSeverityRetryConn:
+		return "retry-conn"
+	case ErrorSeverityFatal:
+		return "fatal"
+	default:
+		return fmt.Sprintf("unknown severity %d", int(s))
+	}
+}
+
+// ClassifyError returns the ErrorSeverity of err.
+//
+// Errors that were caused by closing a Conn, Session, Sender, or Receiver,
+// errors caused by ctx cancellation, and errors with conditions that indicate
+// a permanent problem (e.g. amqp:unauthorized-access or amqp:not-found),
+// including *LinkStolenError, are fatal. Network errors and connection closures initiated by the peer
+// require a new connection, and link or session closures initiated by the
+// peer require a new link. An *Error returned when a message is rejected
+// is retryable if its condition indicates a transient problem.
+func ClassifyError(err error) ErrorSeverity {
+	if err == nil {
+		return ErrorSeverityNone
+	}
+
+	var stolenErr *LinkStolenError
+	if errors.As(err, &stolenErr) {
+		return ErrorSeverityFatal
+	}
+
+	var connErr *ConnError
+	if errors.As(err, &connErr) {
+		if connErr.RemoteErr != nil {
+			return conditionSeverity(connErr.RemoteErr.Condition, ErrorSeverityRetryConn)
+		} else if connErr.inner == nil {
+			// closed via Conn.Close
+			return ErrorSeverityFatal
+		}
+		return ErrorSeverityRetryConn
+	}
+
+	var sessionErr *SessionError
+	if errors.As(err, &sessionErr) {
+		if sessionErr.RemoteErr != nil {
+			return conditionSeverity(sessionErr.RemoteErr.Condition, ErrorSeverityRetryLink)
+		} else if sessionErr.inner == nil {
+			// closed via Session.Close
+			return ErrorSeverityFatal
+		}
+		return ErrorSeverityRetryLink
+	}
+
+	var detachErr *DetachError
+	if errors.As(err, &detachErr) {
+		if detachErr.RemoteErr != nil {
+			return conditionSeverity(detachErr.RemoteErr.Condition, ErrorSeverityRetryLink)
+		} else if detachErr.inner == nil && detachErr.remote {
+			return ErrorSeverityRetryLink
+		} else if detachErr.inner == nil {
+			// closed via Sender.Close or Receiver.Close, or suspended
+			// via Sender.Detach or Receiver.Detach
+			return ErrorSeverityFatal
+		}
+		return ClassifyError(detachErr.inner)
+	}
+
+	var amqpErr *Error
+	if errors.As(err, &amqpErr) {
+		return conditionSeverity(amqpErr.Condition, ErrorSeverityRetry)
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorSeverityFatal
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorSeverityRetry
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &netErr):
+		return ErrorSeverityRetryConn
+	}
+	return ErrorSeverityFatal
+}
+
+// conditionSeverity returns the ErrorSeverity for an error condition
+// received from the peer. Conditions that indicate a transient problem
+// map to def.
+func conditionSeverity(cond ErrCond, def ErrorSeverity) ErrorSeverity {
+	switch cond {
+	case ErrCondDecodeError,
+		ErrCondFrameSizeTooLarge,
+		ErrCondFrameSizeTooSmall,
+		ErrCondInvalidField,
+		ErrCondMessageSizeExceeded,
+		ErrCondNotAllowed,
+		ErrCondNotFound,
+		ErrCondNotImplemented,
+		ErrCondPreconditionFailed,
+		ErrCondResourceDeleted,
+		ErrCondStolen,
+		ErrCondUnauthorizedAccess:
+		return ErrorSeverityFatal
+	case ErrCondConnectionForced,
+		ErrCondConnectionRedirect,
+		ErrCondFramingError:
+		return ErrorSeverityRetryConn
+	case ErrCondDetachForced,
+		ErrCondErrantLink,
+		ErrCondHandleInUse,
+		ErrCondLinkRedirect,
+		ErrCondTransferLimitExceeded,
+		ErrCondUnattachedHandle,
+		ErrCondWindowViolation:
+		if def == ErrorSeverityRetryConn {
+			return def
+		}
+		return ErrorSeverityRetryLink
+	default:
+		// amqp:internal-error, amqp:resource-limit-exceeded, amqp:resource-locked,
+		// amqp:illegal-state, and conditions not defined by the spec.
+		return def
+	}
+}
+
+// IsRetryable returns true if the operation that failed with err can be
+// retried, possibly after recreating the link or connection.
+//
+// See ClassifyError for details.
+func IsRetryable(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorSeverityRetry, ErrorSeverityRetryLink, ErrorSeverityRetryConn:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,145 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		label    string
+		err      error
+		severity ErrorSeverity
+	}{
+		{"nil", nil, ErrorSeverityNone},
+		{"conn closed locally", &ConnError{}, ErrorSeverityFatal},
+		{"conn network error", &ConnError{inner: &net.OpError{Op: "read", Err: errors.New("reset")}}, ErrorSeverityRetryConn},
+		{"conn forced", &ConnError{RemoteErr: &Error{Condition: ErrCondConnectionForced}}, ErrorSeverityRetryConn},
+		{"conn unauthorized", &ConnError{RemoteErr: &Error{Condition: ErrCondUnauthorizedAccess}}, ErrorSeverityFatal},
+		{"conn detach-forced", &ConnError{RemoteErr: &Error{Condition: ErrCondDetachForced}}, ErrorSeverityRetryConn},
+		{"session closed locally", &SessionError{}, ErrorSeverityFatal},
+		{"session ended by peer", &SessionError{RemoteErr: &Error{Condition: ErrCondInternalError}}, ErrorSeverityRetryLink},
+		{"session window violation", &SessionError{RemoteErr: &Error{Condition: ErrCondWindowViolation}}, ErrorSeverityRetryLink},
+		{"link closed locally", &DetachError{}, ErrorSeverityFatal},
+		{"link detached by peer", &DetachError{remote: true}, ErrorSeverityRetryLink},
+		{"link stolen", &DetachError{RemoteErr: &Error{Condition: ErrCondStolen}}, ErrorSeverityFatal},
+		{"link stolen error", &LinkStolenError{RemoteErr: &Error{Condition: ErrCondDetachForced}}, ErrorSeverityFatal},
+		{"link detach-forced", &DetachError{RemoteErr: &Error{Condition: ErrCondDetachForced}}, ErrorSeverityRetryLink},
+		{"link not found", &DetachError{RemoteErr: &Error{Condition: ErrCondNotFound}}, ErrorSeverityFatal},
+		{"link custom condition", &DetachError{RemoteErr: &Error{Condition: "com.example:server-busy"}}, ErrorSeverityRetryLink},
+		{"link inner conn error", &DetachError{inner: &ConnError{inner: io.EOF}}, ErrorSeverityRetryConn},
+		{"rejected throttled", &Error{Condition: ErrCondResourceLimitExceeded}, ErrorSeverityRetry},
+		{"rejected too large", &Error{Condition: ErrCondMessageSizeExceeded}, ErrorSeverityFatal},
+		{"wrapped", fmt.Errorf("send: %w", &Error{Condition: ErrCondInternalError}), ErrorSeverityRetry},
+		{"canceled", context.Canceled, ErrorSeverityFatal},
+		{"deadline", context.DeadlineExceeded, ErrorSeverityRetry},
+		{"EOF", io.ErrUnexpectedEOF, ErrorSeverityRetryConn},
+		{"other", errors.New("boom"), ErrorSeverityFatal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			require.Equal(t, tt.severity, ClassifyError(tt.err))
+			switch tt.severity {
+			case ErrorSeverityNone, ErrorSeverityFatal:
+				require.False(t, IsRetryable(tt.err))
+			default:
+				require.True(t, IsRetryable(tt.err))
+			}
+		})
+	}
+	require.Equal(t, "retry-link", ErrorSeverityRetryLink.String())
+}
+
+func TestErrorInfo(t *testing.T) {
+	info := map[string]any{"hostname": "example.com"}
+	for _, err := range []error{
+		&ConnError{RemoteErr: &Error{Info: info}},
+		&SessionError{RemoteErr: &Error{Info: info}},
+		&DetachError{RemoteErr: &Error{Info: info}},
+		&DetachError{inner: &SessionError{RemoteErr: &Error{Info: info}}},
+		fmt.Errorf("wrapped: %w", &Error{Info: info}),
+	} {
+		got, ok := ErrorInfo(err)
+		require.True(t, ok, "%T", err)
+		require.Equal(t, info, got)
+	}
+
+	for _, err := range []error{nil, &ConnError{}, &DetachError{}, errors.New("other")} {
+		_, ok := ErrorInfo(err)
+		require.False(t, ok)
+	}
+}
+
+func TestRemoteDetachError(t *testing.T) {
+	for _, remoteErr := range []*Error{
+		{Condition: ErrCondStolen},
+		{Condition: ErrCondDetachForced, Description: "New receiver with higher epoch of '2' is created hence current receiver with epoch '1' is getting disconnected."},
+		{Condition: ErrCondDetachForced, Description: "link stolen by another client"},
+	} {
+		err := remoteDetachError(remoteErr)
+		var stolenErr *LinkStolenError
+		require.ErrorAs(t, err, &stolenErr)
+		require.Same(t, remoteErr, stolenErr.RemoteErr)
+		var detachErr *DetachError
+		require.ErrorAs(t, err, &detachErr)
+		require.Same(t, remoteErr, detachErr.RemoteErr)
+	}
+
+	err := remoteDetachError(&Error{Condition: ErrCondDetachForced, Description: "idle timeout"})
+	var stolenErr *LinkStolenError
+	require.False(t, errors.As(err, &stolenErr))
+	var detachErr *DetachError
+	require.ErrorAs(t, err, &detachErr)
+}
+
+func TestRemoteDetachErrorRedirect(t *testing.T) {
+	err := remoteDetachError(&Error{Condition: ErrCondLinkRedirect, Info: map[string]any{"address": "other", "port": uint16(5672)}})
+	var redirectErr *LinkRedirectError
+	require.ErrorAs(t, err, &redirectErr)
+	require.Equal(t, "other", redirectErr.Address)
+	require.Equal(t, uint16(5672), redirectErr.Port)
+	require.Empty(t, redirectErr.NetworkHost)
+	var detachErr *DetachError
+	require.ErrorAs(t, err, &detachErr)
+	require.Equal(t, ErrCondLinkRedirect, detachErr.RemoteErr.Condition)
+	require.Equal(t, ErrorSeverityRetryLink, ClassifyError(err))
+}
+
+func TestErrorsIsAs(t *testing.T) {
+	remoteErr := &Error{Condition: ErrCondResourceLimitExceeded, Description: "throttled"}
+	for _, err := range []error{
+		remoteErr,
+		&ConnError{RemoteErr: remoteErr},
+		&SessionError{RemoteErr: remoteErr},
+		&DetachError{RemoteErr: remoteErr},
+		&DetachError{inner: &SessionError{RemoteErr: remoteErr}},
+		fmt.Errorf("wrapped: %w", &DetachError{RemoteErr: remoteErr}),
+	} {
+		require.ErrorIs(t, err, ErrCondResourceLimitExceeded)
+		require.NotErrorIs(t, err, ErrCondNotFound)
+		var amqpErr *Error
+		require.ErrorAs(t, err, &amqpErr)
+		require.Same(t, remoteErr, amqpErr)
+	}
+
+	// nested redirect
+	redirectErr := remoteDetachError(&Error{Condition: ErrCondLinkRedirect})
+	require.ErrorIs(t, redirectErr, ErrCondLinkRedirect)
+
+	// underlying errors are reachable
+	err := &DetachError{inner: &ConnError{inner: io.EOF}}
+	require.ErrorIs(t, err, io.EOF)
+	var connErr *ConnError
+	require.ErrorAs(t, err, &connErr)
+
+	// errors closed by the caller don't wrap anything
+	require.Nil(t, errors.Unwrap(&ConnError{}))
+	require.Nil(t, errors.Unwrap(&SessionError{}))
+	require.Nil(t, errors.Unwrap(&DetachError{}))
+}
@@ -0,0 +1,100 @@
+// Package eventhubs implements helpers for receiving from Azure Event Hubs
+// over AMQP, such as the source filters starting a receiver at a position
+// of a partition, and the epoch of exclusive receivers.
+//
+// Receivers attach to the address of a partition of a consumer group:
+//
+//	rcv, err := session.NewReceiver(ctx, "myhub/ConsumerGroups/$Default/Partitions/0", &amqp.ReceiverOptions{
+//		Filters: []amqp.LinkFilter{eventhubs.FromSequenceNumber(seq, false)},
+//	})
+package eventhubs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+// Message annotations set by Event Hubs.
+const (
+	// AnnotationOffset is the offset of an event in its partition.
+	AnnotationOffset = "x-opt-offset"
+
+	// AnnotationSequenceNumber is the sequence number of an event in its partition.
+	AnnotationSequenceNumber = "x-opt-sequence-number"
+
+	// AnnotationEnqueuedTime is the time an event was enqueued.
+	AnnotationEnqueuedTime = "x-opt-enqueued-time"
+
+	// AnnotationPartitionKey is the key used to choose the partition of an event.
+	AnnotationPartitionKey = "x-opt-partition-key"
+)
+
+// LinkPropertyEpoch is the link property holding the epoch of a receiver.
+const LinkPropertyEpoch = "com.microsoft:epoch"
+
+// WithEpoch returns a copy of opts making the receiver exclusive, with the
+// given epoch. Attaching it detaches the receivers of the same partition and
+// consumer group with a lower epoch, or without one, which then fail with an
+// *amqp.LinkStolenError. Attaching a receiver with a lower epoch than the
+// current one fails with an *amqp.LinkStolenError too.
+//
+// opts: pass nil to accept the default values.
+func WithEpoch(opts *amqp.ReceiverOptions, epoch int64) *amqp.ReceiverOptions {
+	var o amqp.ReceiverOptions
+	if opts != nil {
+		o = *opts
+	}
+	// copy the properties so opts isn't modified
+	o.Properties = make(map[string]any, len(o.Properties)+1)
+	if opts != nil {
+		for k, v := range opts.Properties {
+			o.Properties[k] = v
+		}
+	}
+	o.Properties[LinkPropertyEpoch] = epoch
+	return &o
+}
+
+// FromStart returns the filter starting a receiver at the first event
+// retained in the partition.
+func FromStart() amqp.LinkFilter {
+	return FromOffset("-1", false)
+}
+
+// FromLatest returns the filter starting a receiver after the last event
+// enqueued in the partition, so it only receives new events.
+func FromLatest() amqp.LinkFilter {
+	return FromOffset("@latest", false)
+}
+
+// FromOffset returns the filter starting a receiver after the event at
+// offset, or at it if inclusive is set.
+func FromOffset(offset string, inclusive bool) amqp.LinkFilter {
+	// offsets are compared as strings, quotes are escaped by doubling them
+	return selector(AnnotationOffset, inclusive, "'"+strings.ReplaceAll(offset, "'", "''")+"'")
+}
+
+// FromSequenceNumber returns the filter starting a receiver after the event
+// with the sequence number seq, or at it if inclusive is set.
+func FromSequenceNumber(seq int64, inclusive bool) amqp.LinkFilter {
+	return selector(AnnotationSequenceNumber, inclusive, fmt.Sprint(seq))
+}
+
+// FromEnqueuedTime returns the filter starting a receiver at the first event
+// enqueued after t, or at t if inclusive is set. Event Hubs records enqueued
+// times in milliseconds.
+func FromEnqueuedTime(t time.Time, inclusive bool) amqp.LinkFilter {
+	return selector(AnnotationEnqueuedTime, inclusive, fmt.Sprint(t.UnixMilli()))
+}
+
+// selector returns the selector filter comparing the annotation to value.
+func selector(annotation string, inclusive bool, value string) amqp.LinkFilter {
+	op := ">"
+	if inclusive {
+		op = ">="
+	}
+	return amqp.NewSelectorFilter(fmt.Sprintf("amqp.annotation.%s %s %s", annotation, op, value))
+}
@@ -0,0 +1,46 @@
+package eventhubs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/internal/encoding"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilters(t *testing.T) {
+	enqueued := time.UnixMilli(1700000000123)
+	for _, tt := range []struct {
+		filter   amqp.LinkFilter
+		selector string
+	}{
+		{FromStart(), "amqp.annotation.x-opt-offset > '-1'"},
+		{FromLatest(), "amqp.annotation.x-opt-offset > '@latest'"},
+		{FromOffset("4096", true), "amqp.annotation.x-opt-offset >= '4096'"},
+		{FromOffset("it's", false), "amqp.annotation.x-opt-offset > 'it''s'"},
+		{FromSequenceNumber(42, false), "amqp.annotation.x-opt-sequence-number > 42"},
+		{FromEnqueuedTime(enqueued, true), "amqp.annotation.x-opt-enqueued-time >= 1700000000123"},
+	} {
+		f := encoding.Filter{}
+		tt.filter(f)
+		described := f["apache.org:selector-filter:string"]
+		require.NotNil(t, described)
+		require.Equal(t, uint64(0x0000468C00000004), described.Descriptor)
+		require.Equal(t, tt.selector, described.Value)
+	}
+}
+
+func TestWithEpoch(t *testing.T) {
+	opts := WithEpoch(nil, 3)
+	require.Equal(t, map[string]any{LinkPropertyEpoch: int64(3)}, opts.Properties)
+
+	base := &amqp.ReceiverOptions{
+		Credit:     10,
+		Properties: map[string]any{"custom": "value"},
+	}
+	opts = WithEpoch(base, 4)
+	require.Equal(t, uint32(10), opts.Credit)
+	require.Equal(t, map[string]any{"custom": "value", LinkPropertyEpoch: int64(4)}, opts.Properties)
+	require.Equal(t, map[string]any{"custom": "value"}, base.Properties)
+}
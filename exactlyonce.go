@@ -0,0 +1,190 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/go-amqp/internal/debug"
+)
+
+// ExactlyOnceOptions contains the optional settings for configuring an
+// ExactlyOnce.
+type ExactlyOnceOptions struct {
+	// DisableTransactions only relies on duplicate detection, even when the
+	// peer supports transactions.
+	//
+	// Default: false.
+	DisableTransactions bool
+
+	// Targets determines where the duplicate-detection ID of the messages
+	// sent is set.
+	//
+	// Default: DuplicateIDMessageID.
+	Targets DuplicateIDTarget
+
+	// Namespace is combined with the keys to derive the duplicate-detection
+	// IDs. See IdempotentProducerOptions.Namespace.
+	//
+	// Default: "".
+	Namespace string
+}
+
+// ExactlyOnceHandler processes a message received by ExactlyOnce, and
+// returns the messages to send as a result.
+type ExactlyOnceHandler func(ctx context.Context, msg *Message) ([]*Message, error)
+
+// ExactlyOnce receives messages, passes them to an ExactlyOnceHandler, and
+// sends the messages it returns, so that each message received results in
+// its messages being sent once, without losses or duplicates, across
+// failures and restarts.
+//
+// When the peer supports transactions, the messages are sent and the
+// message received is accepted in a transaction, committed once they're all
+// done. Otherwise, the messages are sent first, and the message received is
+// only accepted once they're all accepted, so a failure results in the
+// message being received again.
+//
+// In both cases, the messages sent get a duplicate-detection ID derived from
+// the message-id of the message received, so the copies sent after a failure
+// are discarded by brokers with duplicate detection enabled. The messages
+// received again after their outcome was lost are handled again, unless
+// they're detected by the receiver's ReceiverOptions.Dedup.
+type ExactlyOnce struct {
+	rcv     *Receiver
+	snd     *Sender
+	ctrl    *TransactionController // nil when transactions aren't used
+	stamper duplicateStamper
+}
+
+// NewExactlyOnce creates an ExactlyOnce receiving messages on rcv and
+// sending the results on snd. Unless disabled, it attaches a transaction
+// controller on session, which must be the session of rcv and snd for the
+// transactions to include their work. Peers refusing the controller don't
+// support transactions, and ExactlyOnce only relies on duplicate detection.
+//
+// opts: pass nil to accept the default values.
+func NewExactlyOnce(ctx context.Context, session *Session, rcv *Receiver, snd *Sender, opts *ExactlyOnceOptions) (*ExactlyOnce, error) {
+	e := &ExactlyOnce{
+		rcv:     rcv,
+		snd:     snd,
+		stamper: duplicateStamper{targets: DuplicateIDMessageID},
+	}
+	disableTransactions := false
+	if opts != nil {
+		disableTransactions = opts.DisableTransactions
+		if opts.Targets != 0 {
+			e.stamper.targets = opts.Targets
+		}
+		e.stamper.namespace = opts.Namespace
+	}
+	if disableTransactions {
+		return e, nil
+	}
+
+	ctrl, err := session.NewTransactionController(ctx)
+	var detachErr *DetachError
+	var amqpErr *Error
+	switch {
+	case err == nil:
+		e.ctrl = ctrl
+	case errors.As(err, &detachErr), errors.As(err, &amqpErr):
+		debug.Log(1, "transactions aren't supported, relying on duplicate detection: %v", err)
+	default:
+		return nil, err
+	}
+	return e, nil
+}
+
+// Transactional returns true if transactions are used.
+func (e *ExactlyOnce) Transactional() bool {
+	return e.ctrl != nil
+}
+
+// Process receives the next message and processes it with h. If h returns
+// an error, the message is rejected with it, as done by Router, and Process
+// returns nil. Otherwise, the message is released, to be received again, if
+// the messages returned by h can't be sent, and the error is returned.
+func (e *ExactlyOnce) Process(ctx context.Context, h ExactlyOnceHandler) error {
+	msg, err := e.rcv.Receive(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := h(ctx, msg)
+	if err != nil {
+		settle(e.rcv, msg, err)
+		return nil
+	}
+
+	if err := e.stamp(msg, results); err != nil {
+		_ = e.rcv.ReleaseMessage(ctx, msg)
+		return err
+	}
+	if e.ctrl != nil {
+		return e.processTransaction(ctx, msg, results)
+	}
+	return e.processIdempotent(ctx, msg, results)
+}
+
+// Close detaches the transaction controller, if any. The receiver and
+// sender aren't closed.
+func (e *ExactlyOnce) Close(ctx context.Context) error {
+	if e.ctrl == nil {
+		return nil
+	}
+	return e.ctrl.Close(ctx)
+}
+
+// stamp sets the duplicate-detection IDs of the results of msg, derived
+// from its message-id. Results aren't stamped if msg has no message-id.
+func (e *ExactlyOnce) stamp(msg *Message, results []*Message) error {
+	if msg.Properties == nil || msg.Properties.MessageID == nil {
+		return nil
+	}
+	for i, result := range results {
+		if err := e.stamper.stamp(result, fmt.Sprintf("%v/%d", msg.Properties.MessageID, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processTransaction sends results and accepts msg in a transaction.
+func (e *ExactlyOnce) processTransaction(ctx context.Context, msg *Message, results []*Message) error {
+	txn, err := e.ctrl.Declare(ctx)
+	if err != nil {
+		_ = e.rcv.ReleaseMessage(ctx, msg)
+		return err
+	}
+	for _, result := range results {
+		if err := txn.Send(ctx, e.snd, result); err != nil {
+			_ = txn.Rollback(ctx)
+			_ = e.rcv.ReleaseMessage(ctx, msg)
+			return err
+		}
+	}
+	if err := txn.Accept(ctx, msg); err != nil {
+		_ = txn.Rollback(ctx)
+		return err
+	}
+	// a failed commit is rolled back, and msg is received again
+	return txn.Commit(ctx)
+}
+
+// processIdempotent sends results, and accepts msg once they're accepted.
+func (e *ExactlyOnce) processIdempotent(ctx context.Context, msg *Message, results []*Message) error {
+	for _, result := range results {
+		outcome, err := e.snd.SendWithOutcome(ctx, result)
+		if err == nil && outcome.Type != OutcomeAccepted {
+			err = fmt.Errorf("amqp: message sent with outcome %s", outcome.Type)
+			if outcome.Error != nil {
+				err = outcome.Error
+			}
+		}
+		if err != nil {
+			_ = e.rcv.ReleaseMessage(ctx, msg)
+			return err
+		}
+	}
+	return e.rcv.AcceptMessage(ctx, msg)
+}
@@ -0,0 +1,75 @@
+package amqp_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExactlyOnce(t *testing.T) {
+	for _, disableTransactions := range []bool{false, true} {
+		b := broker.New(&broker.Options{AutoCreateQueues: true})
+		srv, err := broker.NewServer(b, nil)
+		require.NoError(t, err)
+		defer srv.Close()
+		in, err := b.DeclareQueue("in")
+		require.NoError(t, err)
+		for _, id := range []string{"order-1", "bad", "order-2"} {
+			msg := amqp.NewMessage([]byte(id))
+			msg.Properties = &amqp.MessageProperties{MessageID: id}
+			require.NoError(t, in.Enqueue(msg))
+		}
+		out, err := b.DeclareQueue("out")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn, err := amqp.Dial(srv.URL, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		session, err := conn.NewSession(ctx, nil)
+		require.NoError(t, err)
+		rcv, err := session.NewReceiver(ctx, "in", nil)
+		require.NoError(t, err)
+		snd, err := session.NewSender(ctx, "out", nil)
+		require.NoError(t, err)
+		e, err := amqp.NewExactlyOnce(ctx, session, rcv, snd, &amqp.ExactlyOnceOptions{
+			DisableTransactions: disableTransactions,
+			Namespace:           "shipping",
+		})
+		require.NoError(t, err)
+		require.Equal(t, !disableTransactions, e.Transactional())
+
+		handler := func(ctx context.Context, msg *amqp.Message) ([]*amqp.Message, error) {
+			if string(msg.GetData()) == "bad" {
+				return nil, errors.New("bad order")
+			}
+			return []*amqp.Message{
+				amqp.NewMessage([]byte("label")),
+				amqp.NewMessage([]byte("invoice")),
+			}, nil
+		}
+		for i := 0; i < 3; i++ {
+			require.NoError(t, e.Process(ctx, handler))
+		}
+		require.NoError(t, e.Close(ctx))
+		require.Eventually(t, func() bool { return in.Len() == 0 }, time.Second, time.Millisecond, "transactions disabled: %t, queued %d", disableTransactions, in.Len())
+		require.Equal(t, 4, out.Len())
+
+		// the results get the IDs derived from the message processed
+		results, err := session.NewReceiver(ctx, "out", nil)
+		require.NoError(t, err)
+		for _, key := range []string{"order-1/0", "order-1/1", "order-2/0", "order-2/1"} {
+			msg, err := results.Receive(ctx)
+			require.NoError(t, err)
+			require.Equal(t, hex.EncodeToString(amqp.DuplicateID("shipping", key)), msg.Properties.MessageID)
+			require.NoError(t, results.AcceptMessage(ctx, msg))
+		}
+	}
+}
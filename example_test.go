@@ -220,3 +220,40 @@ func ExampleDetachError() {
 		log.Fatalf("unexpected error type %T", err)
 	}
 }
+
+func ExampleRetry() {
+	ctx := context.TODO()
+
+	var sender *amqp.Sender
+	err := amqp.Retry(ctx, amqp.ExponentialBackoff(nil), func(ctx context.Context) error {
+		if sender == nil {
+			// (re)create the connection, session, and sender
+			conn, err := amqp.Dial("amqps://my-namespace.servicebus.windows.net", &amqp.ConnOptions{
+				SASLType: amqp.SASLTypePlain("access-key-name", "access-key"),
+			})
+			if err != nil {
+				return err
+			}
+			session, err := conn.NewSession(ctx, nil)
+			if err != nil {
+				conn.Close()
+				return err
+			}
+			sender, err = session.NewSender(ctx, "/queue-name", nil)
+			if err != nil {
+				conn.Close()
+				return err
+			}
+		}
+
+		err := sender.Send(ctx, amqp.NewMessage([]byte("Hello!")))
+		if amqp.ClassifyError(err) > amqp.ErrorSeverityRetry {
+			// the sender must be recreated before retrying
+			sender = nil
+		}
+		return err
+	})
+	if err != nil {
+		log.Fatal("Sending message:", err)
+	}
+}
@@ -0,0 +1,75 @@
+package amqp
+
+// denseHandles is the number of handles stored in the slice of a
+// handleTable. Greater handles, which peers rarely use, are stored in a map.
+const denseHandles = 1 << 16
+
+// handleTable maps link handles to values. Handles are allocated from
+// zero by both peers, so they index a slice instead of being hashed,
+// keeping lookups fast and compact with tens of thousands of links.
+// The zero value is ready to use.
+type handleTable[V any] struct {
+	dense  []handleEntry[V]
+	sparse map[uint32]V
+	n      int
+}
+
+type handleEntry[V any] struct {
+	v  V
+	ok bool
+}
+
+// get returns the value of h and true, or false if h isn't set.
+func (t *handleTable[V]) get(h uint32) (V, bool) {
+	if h < denseHandles {
+		if int(h) < len(t.dense) {
+			e := t.dense[h]
+			return e.v, e.ok
+		}
+		var zero V
+		return zero, false
+	}
+	v, ok := t.sparse[h]
+	return v, ok
+}
+
+// set sets the value of h.
+func (t *handleTable[V]) set(h uint32, v V) {
+	if h >= denseHandles {
+		if t.sparse == nil {
+			t.sparse = map[uint32]V{}
+		}
+		if _, ok := t.sparse[h]; !ok {
+			t.n++
+		}
+		t.sparse[h] = v
+		return
+	}
+	if int(h) >= len(t.dense) {
+		t.dense = append(t.dense, make([]handleEntry[V], int(h)-len(t.dense)+1)...)
+	}
+	if !t.dense[h].ok {
+		t.n++
+	}
+	t.dense[h] = handleEntry[V]{v: v, ok: true}
+}
+
+// delete unsets h.
+func (t *handleTable[V]) delete(h uint32) {
+	if h >= denseHandles {
+		if _, ok := t.sparse[h]; ok {
+			delete(t.sparse, h)
+			t.n--
+		}
+		return
+	}
+	if int(h) < len(t.dense) && t.dense[h].ok {
+		t.dense[h] = handleEntry[V]{}
+		t.n--
+	}
+}
+
+// len returns the number of handles set.
+func (t *handleTable[V]) len() int {
+	return t.n
+}
@@ -0,0 +1,36 @@
+package amqp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleTable(t *testing.T) {
+	var table handleTable[string]
+	_, ok := table.get(0)
+	require.False(t, ok)
+
+	for _, h := range []uint32{0, 3, denseHandles - 1, denseHandles, 1 << 31} {
+		table.set(h, "link")
+		v, ok := table.get(h)
+		require.True(t, ok)
+		require.Equal(t, "link", v)
+	}
+	require.Equal(t, 5, table.len())
+	_, ok = table.get(1)
+	require.False(t, ok)
+
+	// setting a handle again replaces its value
+	table.set(3, "other")
+	v, _ := table.get(3)
+	require.Equal(t, "other", v)
+	require.Equal(t, 5, table.len())
+
+	for _, h := range []uint32{3, 1 << 31, 7, 1 << 20} {
+		table.delete(h)
+		_, ok := table.get(h)
+		require.False(t, ok)
+	}
+	require.Equal(t, 3, table.len())
+}
@@ -9,7 +9,7 @@ import (
 
 	"github.com/Azure/go-amqp/internal/encoding"
 	"github.com/Azure/go-amqp/internal/frames"
-	"github.com/Azure/go-amqp/internal/mocks"
+	"github.com/Azure/go-amqp/mocks"
 	"github.com/stretchr/testify/require"
 )
 
@@ -0,0 +1,152 @@
+package amqp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// DuplicateIDTarget determines where an IdempotentProducer sets the
+// duplicate-detection ID of a message. Targets can be combined.
+type DuplicateIDTarget uint8
+
+const (
+	// DuplicateIDMessageID sets the message-id property, used for duplicate
+	// detection by Azure Service Bus and Azure Event Hubs.
+	DuplicateIDMessageID DuplicateIDTarget = 1 << iota
+
+	// DuplicateIDArtemis sets the _AMQ_DUPL_ID application property, used
+	// for duplicate detection by ActiveMQ Artemis.
+	DuplicateIDArtemis
+
+	// DuplicateIDDeliveryTag sets the delivery tag. Delivery tags must be
+	// unique among the unsettled deliveries of a link, so a message must
+	// only be sent again once its previous delivery is settled.
+	DuplicateIDDeliveryTag
+)
+
+// ArtemisDuplicateIDProperty is the application property holding the
+// duplicate-detection ID of a message for ActiveMQ Artemis.
+const ArtemisDuplicateIDProperty = "_AMQ_DUPL_ID"
+
+// IdempotentProducerOptions contains the optional settings for configuring
+// an IdempotentProducer.
+type IdempotentProducerOptions struct {
+	// Targets determines where the duplicate-detection ID is set.
+	//
+	// Default: DuplicateIDMessageID.
+	Targets DuplicateIDTarget
+
+	// Annotation, when set, is the message annotation also set to the
+	// duplicate-detection ID, for brokers reading it from an annotation
+	// such as "x-opt-...".
+	//
+	// Default: "".
+	Annotation string
+
+	// Namespace is combined with the keys to derive the duplicate-detection
+	// IDs, so the same key used by different applications sharing a broker
+	// doesn't result in the same ID.
+	//
+	// Default: "".
+	Namespace string
+
+	// ProducerOptions are the options of the underlying Producer.
+	//
+	// Default: nil.
+	ProducerOptions *ProducerOptions
+}
+
+// IdempotentProducer is a Producer setting a duplicate-detection ID on each
+// message, derived from a key provided by the application. A message
+// published again with the same key, such as when an application retries
+// after a failure or restarts from a WAL, gets the same ID, so a broker with
+// duplicate detection enabled discards the copies.
+type IdempotentProducer struct {
+	*Producer
+
+	stamper duplicateStamper
+}
+
+// duplicateStamper sets duplicate-detection IDs on messages.
+type duplicateStamper struct {
+	targets    DuplicateIDTarget
+	annotation string
+	namespace  string
+}
+
+// NewIdempotentProducer creates an IdempotentProducer publishing messages on
+// the sender returned by sender.
+//
+// opts: pass nil to accept the default values.
+func NewIdempotentProducer(sender func(ctx context.Context) (*Sender, error), opts *IdempotentProducerOptions) *IdempotentProducer {
+	p := &IdempotentProducer{stamper: duplicateStamper{targets: DuplicateIDMessageID}}
+	var producerOpts *ProducerOptions
+	if opts != nil {
+		if opts.Targets != 0 {
+			p.stamper.targets = opts.Targets
+		}
+		p.stamper.annotation = opts.Annotation
+		p.stamper.namespace = opts.Namespace
+		producerOpts = opts.ProducerOptions
+	}
+	p.Producer = NewProducer(sender, producerOpts)
+	return p
+}
+
+// Publish sets the duplicate-detection ID derived from key on msg, and
+// publishes it as done by Producer.Publish.
+func (p *IdempotentProducer) Publish(ctx context.Context, key string, msg *Message, done func(Outcome, error)) error {
+	if err := p.Stamp(msg, key); err != nil {
+		return err
+	}
+	return p.Producer.Publish(ctx, msg, done)
+}
+
+// Stamp sets the duplicate-detection ID derived from key on msg, for
+// messages sent on a Sender directly.
+func (p *IdempotentProducer) Stamp(msg *Message, key string) error {
+	return p.stamper.stamp(msg, key)
+}
+
+// stamp sets the duplicate-detection ID derived from key on msg.
+func (s *duplicateStamper) stamp(msg *Message, key string) error {
+	if key == "" {
+		return errors.New("amqp: duplicate-detection key is empty")
+	}
+	id := DuplicateID(s.namespace, key)
+	if s.targets&DuplicateIDMessageID != 0 {
+		if msg.Properties == nil {
+			msg.Properties = &MessageProperties{}
+		}
+		msg.Properties.MessageID = hex.EncodeToString(id)
+	}
+	if s.targets&DuplicateIDArtemis != 0 {
+		if msg.ApplicationProperties == nil {
+			msg.ApplicationProperties = map[string]any{}
+		}
+		msg.ApplicationProperties[ArtemisDuplicateIDProperty] = hex.EncodeToString(id)
+	}
+	if s.targets&DuplicateIDDeliveryTag != 0 {
+		msg.DeliveryTag = id
+	}
+	if s.annotation != "" {
+		if msg.Annotations == nil {
+			msg.Annotations = Annotations{}
+		}
+		msg.Annotations[s.annotation] = hex.EncodeToString(id)
+	}
+	return nil
+}
+
+// DuplicateID returns the 16 bytes duplicate-detection ID derived from
+// namespace and key. Message IDs, properties, and annotations are set to
+// its hexadecimal encoding.
+func DuplicateID(namespace, key string) []byte {
+	h := sha256.New()
+	_, _ = h.Write([]byte(namespace))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	return h.Sum(nil)[:16]
+}
@@ -0,0 +1,64 @@
+package amqp_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentProducer(t *testing.T) {
+	b := broker.New(&broker.Options{AutoCreateQueues: true})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+	conn, err := amqp.Dial(srv.URL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	producer := amqp.NewIdempotentProducer(func(ctx context.Context) (*amqp.Sender, error) {
+		session, err := conn.NewSession(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return session.NewSender(ctx, "out", nil)
+	}, &amqp.IdempotentProducerOptions{
+		Targets:    amqp.DuplicateIDMessageID | amqp.DuplicateIDArtemis,
+		Annotation: "x-opt-dedup-id",
+		Namespace:  "orders",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.Error(t, producer.Publish(ctx, "", amqp.NewMessage([]byte("no key")), nil))
+	require.NoError(t, producer.Publish(ctx, "order-1", amqp.NewMessage([]byte("first")), nil))
+	require.NoError(t, producer.Publish(ctx, "order-1", amqp.NewMessage([]byte("retry")), nil))
+	require.NoError(t, producer.Close(ctx))
+
+	// both copies have the same ID, derived from the namespace and key
+	id := hex.EncodeToString(amqp.DuplicateID("orders", "order-1"))
+	require.NotEqual(t, id, hex.EncodeToString(amqp.DuplicateID("", "order-1")))
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	rcv, err := session.NewReceiver(ctx, "out", nil)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		msg, err := rcv.Receive(ctx)
+		require.NoError(t, err)
+		require.Equal(t, id, msg.Properties.MessageID)
+		require.Equal(t, id, msg.ApplicationProperties[amqp.ArtemisDuplicateIDProperty])
+		require.Equal(t, id, msg.Annotations["x-opt-dedup-id"])
+		require.NoError(t, rcv.AcceptMessage(ctx, msg))
+	}
+
+	// the delivery tag holds the raw ID
+	msg := amqp.NewMessage(nil)
+	tagged := amqp.NewIdempotentProducer(nil, &amqp.IdempotentProducerOptions{Targets: amqp.DuplicateIDDeliveryTag})
+	require.NoError(t, tagged.Stamp(msg, "order-1"))
+	require.Equal(t, amqp.DuplicateID("", "order-1"), msg.DeliveryTag)
+	require.Nil(t, msg.Properties)
+	require.NoError(t, tagged.Close(ctx))
+}
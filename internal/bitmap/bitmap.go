@@ -8,6 +8,7 @@ import (
 type Bitmap struct {
 	max  uint32
 	bits []uint64
+	free int // bits before bits[free] are all set
 }
 
 func New(max uint32) *Bitmap {
@@ -50,6 +51,9 @@ func (b *Bitmap) Remove(n uint32) {
 	}
 
 	b.bits[idx] &= ^uint64(1 << offset)
+	if int(idx) < b.free {
+		b.free = int(idx)
+	}
 }
 
 // next sets and returns the lowest unset bit in the bitmap.
@@ -59,10 +63,13 @@ func (b *Bitmap) Remove(n uint32) {
 // If there are no unset bits below max, the second return
 // value will be false.
 func (b *Bitmap) Next() (uint32, bool) {
-	// find the first unset bit
-	for i, v := range b.bits {
+	// find the first unset bit, skipping the entries known to be full
+	// so that allocating many values doesn't rescan them each time
+	for i := b.free; i < len(b.bits); i++ {
+		v := b.bits[i]
 		// skip if all bits are set
 		if v == ^uint64(0) {
+			b.free = i + 1
 			continue
 		}
 
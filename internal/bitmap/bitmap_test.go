@@ -57,6 +57,15 @@ func TestBitmap(t *testing.T) {
 			next:  64,
 			count: 65,
 		},
+		{
+			max: math.MaxUint32,
+			ops: []any{
+				next(200), rem(5),
+			},
+
+			next:  5,
+			count: 200,
+		},
 		{
 			max: math.MaxUint32,
 			ops: []any{
@@ -9,6 +9,24 @@ import (
 type Buffer struct {
 	b []byte
 	i int
+
+	limits      Limits // decoding limits, zero value means no limits
+	depth       int    // current nesting depth of compound values being decoded
+	zeroCopy    bool   // decoded binary values reference b instead of being copied
+	skipUnknown bool   // composites with unknown trailing fields are accepted
+}
+
+// Limits constrains the values a decoder will accept when reading from a Buffer.
+// A zero value for any field disables that particular limit.
+type Limits struct {
+	// MaxDepth is the maximum nesting depth of compound values.
+	MaxDepth int
+
+	// MaxElements is the maximum number of elements in a list, array, or map.
+	MaxElements uint32
+
+	// MaxLength is the maximum length in bytes of a binary, string, or symbol value.
+	MaxLength uint32
 }
 
 func New(b []byte) *Buffer {
@@ -34,6 +52,54 @@ func (b *Buffer) Skip(n int) {
 func (b *Buffer) Reset() {
 	b.b = b.b[:0]
 	b.i = 0
+	b.depth = 0
+}
+
+// SetLimits sets the decoding limits for values read from b.
+func (b *Buffer) SetLimits(l Limits) {
+	b.limits = l
+}
+
+// Limits returns the decoding limits for values read from b.
+func (b *Buffer) Limits() Limits {
+	return b.limits
+}
+
+// SetZeroCopy controls whether binary values decoded from b
+// reference its underlying storage instead of being copied.
+func (b *Buffer) SetZeroCopy(zeroCopy bool) {
+	b.zeroCopy = zeroCopy
+}
+
+// ZeroCopy returns true if binary values decoded from b
+// reference its underlying storage.
+func (b *Buffer) ZeroCopy() bool {
+	return b.zeroCopy
+}
+
+// SetSkipUnknownFields controls whether composites decoded from b may have
+// more fields than are known, the unknown fields being skipped.
+func (b *Buffer) SetSkipUnknownFields(skip bool) {
+	b.skipUnknown = skip
+}
+
+// SkipUnknownFields returns true if composites decoded from b may have
+// more fields than are known.
+func (b *Buffer) SkipUnknownFields() bool {
+	return b.skipUnknown
+}
+
+// Descend records entry into a compound value.
+// It returns false if the new depth exceeds the MaxDepth limit.
+// Every call to Descend must be paired with a call to Ascend.
+func (b *Buffer) Descend() bool {
+	b.depth++
+	return b.limits.MaxDepth == 0 || b.depth <= b.limits.MaxDepth
+}
+
+// Ascend records exit from a compound value.
+func (b *Buffer) Ascend() {
+	b.depth--
 }
 
 // reclaim shifts used buffer space to the beginning of the
@@ -140,6 +206,13 @@ func (b *Buffer) Bytes() []byte {
 	return b.b[b.i:]
 }
 
+// Attach replaces the underlying storage of b with the capacity of p.
+// Any unread data in b is discarded.
+func (b *Buffer) Attach(p []byte) {
+	b.b = p[:0]
+	b.i = 0
+}
+
 func (b *Buffer) Detach() []byte {
 	temp := b.b
 	b.b = nil
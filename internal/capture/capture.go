@@ -0,0 +1,108 @@
+// Package capture implements the file format used to record raw AMQP frames.
+//
+// A capture file starts with Magic followed by zero or more records:
+//
+//	time      int64   Unix nanoseconds, big-endian
+//	direction uint8   Inbound or Outbound
+//	idLen     uint16  length of the connection ID, big-endian
+//	id        []byte  connection ID, the local container ID
+//	frameLen  uint32  length of the frame, big-endian
+//	frame     []byte  raw frame, including the frame header
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Magic identifies a capture file and its format version.
+const Magic = "AMQPCAP1"
+
+// Direction indicates if a frame was sent or received.
+type Direction uint8
+
+const (
+	Inbound  Direction = '<'
+	Outbound Direction = '>'
+)
+
+// Record is a single captured frame.
+type Record struct {
+	Time      time.Time
+	Direction Direction
+	ConnID    string
+	Frame     []byte
+}
+
+// Append appends the encoding of rec to b and returns the result.
+func Append(b []byte, rec Record) []byte {
+	var fixed [11]byte
+	binary.BigEndian.PutUint64(fixed[0:8], uint64(rec.Time.UnixNano()))
+	fixed[8] = byte(rec.Direction)
+	binary.BigEndian.PutUint16(fixed[9:11], uint16(len(rec.ConnID)))
+	b = append(b, fixed[:]...)
+	b = append(b, rec.ConnID...)
+
+	var frameLen [4]byte
+	binary.BigEndian.PutUint32(frameLen[:], uint32(len(rec.Frame)))
+	b = append(b, frameLen[:]...)
+	return append(b, rec.Frame...)
+}
+
+// Reader reads records from a capture file.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a Reader for r after validating the file's magic.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, fmt.Errorf("reading capture header: %w", err)
+	}
+	if string(magic) != Magic {
+		return nil, errors.New("not an AMQP capture file")
+	}
+	return &Reader{r: br}, nil
+}
+
+// Next returns the next record. It returns io.EOF when there are no more records.
+func (r *Reader) Next() (Record, error) {
+	var fixed [11]byte
+	if _, err := io.ReadFull(r.r, fixed[:]); err != nil {
+		return Record{}, err
+	}
+	rec := Record{
+		Time:      time.Unix(0, int64(binary.BigEndian.Uint64(fixed[0:8]))),
+		Direction: Direction(fixed[8]),
+	}
+
+	id := make([]byte, binary.BigEndian.Uint16(fixed[9:11]))
+	if _, err := io.ReadFull(r.r, id); err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+	rec.ConnID = string(id)
+
+	var frameLen [4]byte
+	if _, err := io.ReadFull(r.r, frameLen[:]); err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+	rec.Frame = make([]byte, binary.BigEndian.Uint32(frameLen[:]))
+	if _, err := io.ReadFull(r.r, rec.Frame); err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+	return rec, nil
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF for truncated records.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
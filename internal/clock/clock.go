@@ -0,0 +1,157 @@
+// Package clock abstracts the passage of time, so that
+// timers can be advanced deterministically in tests.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and timers.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer creates a Timer that sends the current time on its channel after d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event timer, like a *time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is sent when the timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It returns false if the
+	// timer has already fired or been stopped.
+	Stop() bool
+
+	// Reset changes the timer to fire after d. It returns true if
+	// the timer was active.
+	Reset(d time.Duration) bool
+}
+
+// Real is the Clock of the time package.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{t: time.NewTimer(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+func (t realTimer) Reset(d time.Duration) bool {
+	return t.t.Reset(d)
+}
+
+// Fake is a Clock whose time only changes when advanced.
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers map[*fakeTimer]struct{} // active timers
+}
+
+// NewFake creates a Fake set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, timers: map[*fakeTimer]struct{}{}}
+}
+
+// Now returns the fake's current time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTimer creates a Timer that fires once the fake has been advanced by d.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{f: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the fake's time forward by d, firing the timers
+// that expire in the meantime in the order they expire.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+
+	var expired []*fakeTimer
+	for t := range f.timers {
+		if !t.deadline.After(f.now) {
+			expired = append(expired, t)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].deadline.Before(expired[j].deadline)
+	})
+	for _, t := range expired {
+		f.fire(t)
+	}
+}
+
+// Timers returns the number of active timers. Use it to wait for the code
+// under test to create its timers before advancing the fake.
+func (f *Fake) Timers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// fire sends the deadline of t on its channel, dropping it if the previous
+// one hasn't been received, as *time.Timer does. f.mu must be held.
+func (f *Fake) fire(t *fakeTimer) {
+	delete(f.timers, t)
+	select {
+	case t.c <- t.deadline:
+	default:
+	}
+}
+
+type fakeTimer struct {
+	f        *Fake
+	c        chan time.Time
+	deadline time.Time // guarded by f.mu
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	_, active := t.f.timers[t]
+	delete(t.f.timers, t)
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	_, active := t.f.timers[t]
+	t.deadline = t.f.now.Add(d)
+	if d <= 0 {
+		t.f.fire(t)
+		return active
+	}
+	t.f.timers[t] = struct{}{}
+	return active
+}
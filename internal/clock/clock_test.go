@@ -0,0 +1,113 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	require.Equal(t, start, f.Now())
+
+	first := f.NewTimer(time.Second)
+	second := f.NewTimer(2 * time.Second)
+	stopped := f.NewTimer(time.Second)
+	require.Equal(t, 3, f.Timers())
+	require.True(t, stopped.Stop())
+	require.False(t, stopped.Stop())
+
+	f.Advance(999 * time.Millisecond)
+	require.Len(t, first.C(), 0)
+
+	f.Advance(5 * time.Second)
+	require.Equal(t, start.Add(time.Second), <-first.C())
+	require.Equal(t, start.Add(2*time.Second), <-second.C())
+	require.Len(t, stopped.C(), 0)
+	require.Zero(t, f.Timers())
+	require.False(t, first.Stop())
+
+	// reset timers fire relative to the current time
+	require.False(t, first.Reset(time.Second))
+	require.True(t, first.Reset(2*time.Second))
+	f.Advance(2 * time.Second)
+	require.Equal(t, f.Now(), <-first.C())
+
+	// timers without a duration fire immediately
+	require.Equal(t, f.Now(), <-f.NewTimer(0).C())
+}
+
+func TestWheel(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	w := NewWheel(f, 10*time.Millisecond)
+	require.Equal(t, start, w.Now())
+
+	// step advances f, waiting for w to process the elapsed ticks
+	step := func(d time.Duration) {
+		f.Advance(d)
+		require.Eventually(t, func() bool {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			return !w.running || f.Timers() == 1
+		}, time.Second, time.Millisecond)
+	}
+
+	first := w.NewTimer(25 * time.Millisecond)
+	long := w.NewTimer(5 * time.Second)
+	stopped := w.NewTimer(25 * time.Millisecond)
+	require.True(t, stopped.Stop())
+	require.False(t, stopped.Stop())
+	require.Equal(t, 1, f.Timers())
+
+	// timers fire on the first tick after they expire
+	step(20 * time.Millisecond)
+	require.Len(t, first.C(), 0)
+	step(10 * time.Millisecond)
+	require.Equal(t, start.Add(30*time.Millisecond), <-first.C())
+	require.Len(t, stopped.C(), 0)
+	require.False(t, first.Stop())
+
+	// timers further away than the wheel's slots, and gaps spanning all of them
+	step(4 * time.Second)
+	require.Len(t, long.C(), 0)
+	step(time.Second)
+	require.Equal(t, start.Add(5030*time.Millisecond), <-long.C())
+
+	// the wheel stops driving itself once no timers are active
+	w.mu.Lock()
+	require.False(t, w.running)
+	w.mu.Unlock()
+	require.Zero(t, f.Timers())
+
+	// reset timers fire relative to the current time, restarting the wheel
+	require.False(t, first.Reset(time.Second))
+	require.True(t, first.Reset(100*time.Millisecond))
+	require.Equal(t, 1, f.Timers())
+	for i := 0; i < 10; i++ {
+		require.Len(t, first.C(), 0)
+		step(10 * time.Millisecond)
+	}
+	require.Equal(t, f.Now(), <-first.C())
+
+	// timers without a duration fire immediately
+	require.Equal(t, f.Now(), <-w.NewTimer(0).C())
+}
+
+func TestWheelRealClock(t *testing.T) {
+	w := NewWheel(Real, time.Millisecond)
+	start := time.Now()
+	timers := make([]Timer, 100)
+	for i := range timers {
+		timers[i] = w.NewTimer(time.Duration(i%10) * time.Millisecond)
+	}
+	for i := 0; i < len(timers); i += 2 {
+		timers[i].Stop()
+	}
+	for i := 1; i < len(timers); i += 2 {
+		fired := <-timers[i].C()
+		require.False(t, fired.Before(start.Add(time.Duration(i%10)*time.Millisecond)))
+	}
+}
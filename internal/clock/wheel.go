@@ -0,0 +1,197 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// wheelSlots is the number of slots of a Wheel. Timers further away than
+// wheelSlots ticks share slots with nearer ones, and are skipped until due.
+const wheelSlots = 256
+
+// Wheel is a Clock whose timers are kept in a hashed timer wheel, driven by
+// a single timer of another clock that only runs while one of its timers is
+// active. Starting, resetting, and stopping its timers is cheap, which suits
+// timers that are frequently reset or stopped before firing.
+//
+// Timers fire on the first tick after they expire, so up to a tick late.
+type Wheel struct {
+	base   Clock
+	tick   time.Duration
+	origin time.Time
+
+	mu      sync.Mutex
+	slots   [wheelSlots]wheelTimer // sentinels of the lists of the timers in each slot
+	ticks   int64                  // the last tick processed, counted from origin
+	active  int                    // number of active timers
+	running bool                   // the goroutine driving the wheel is running
+	driver  Timer                  // the timer of base firing on each tick while running
+}
+
+// NewWheel creates a Wheel whose timers fire on ticks of the given duration of base.
+func NewWheel(base Clock, tick time.Duration) *Wheel {
+	w := &Wheel{base: base, tick: tick, origin: base.Now()}
+	for i := range w.slots {
+		s := &w.slots[i]
+		s.prev, s.next = s, s
+	}
+	return w
+}
+
+// Now returns the current time of the wheel's clock.
+func (w *Wheel) Now() time.Time {
+	return w.base.Now()
+}
+
+// NewTimer creates a Timer that fires on the first tick after d elapses.
+func (w *Wheel) NewTimer(d time.Duration) Timer {
+	t := &wheelTimer{w: w, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// tickOf returns the tick at, or before, now.
+func (w *Wheel) tickOf(now time.Time) int64 {
+	return int64(now.Sub(w.origin) / w.tick)
+}
+
+// add schedules t on the tick of its deadline. w.mu must be held.
+func (w *Wheel) add(t *wheelTimer, now time.Time) {
+	if !w.running {
+		// the ticks elapsed while no timers were active weren't processed
+		w.ticks = w.tickOf(now)
+		w.running = true
+		if w.driver == nil {
+			w.driver = w.base.NewTimer(w.untilNextTick(now))
+		} else {
+			w.driver.Reset(w.untilNextTick(now))
+		}
+		go w.run()
+	}
+	t.tick = w.tickOf(t.deadline)
+	if t.tick <= w.ticks {
+		t.tick = w.ticks + 1
+	}
+	w.link(t)
+	w.active++
+}
+
+// link inserts t in the list of the slot of its tick. w.mu must be held.
+func (w *Wheel) link(t *wheelTimer) {
+	s := &w.slots[t.tick%wheelSlots]
+	t.prev, t.next = s.prev, s
+	s.prev.next = t
+	s.prev = t
+}
+
+// remove unschedules t, returning false if it wasn't active. w.mu must be held.
+func (w *Wheel) remove(t *wheelTimer) bool {
+	if t.next == nil {
+		return false
+	}
+	t.prev.next, t.next.prev = t.next, t.prev
+	t.prev, t.next = nil, nil
+	w.active--
+	return true
+}
+
+// run drives the wheel until no timers are active.
+func (w *Wheel) run() {
+	w.mu.Lock()
+	driver := w.driver
+	w.mu.Unlock()
+	for {
+		<-driver.C()
+		now := w.base.Now()
+		w.mu.Lock()
+		w.advance(now)
+		if w.active == 0 {
+			w.running = false
+			w.mu.Unlock()
+			return
+		}
+		driver.Reset(w.untilNextTick(now))
+		w.mu.Unlock()
+	}
+}
+
+// untilNextTick returns the duration from now to the next tick.
+func (w *Wheel) untilNextTick(now time.Time) time.Duration {
+	return w.origin.Add(time.Duration(w.tickOf(now)+1) * w.tick).Sub(now)
+}
+
+// advance fires the timers that expired by now. w.mu must be held.
+func (w *Wheel) advance(now time.Time) {
+	target := w.tickOf(now)
+	if target-w.ticks >= wheelSlots {
+		// every slot is due, visit each of them once
+		for i := range w.slots {
+			w.expire(&w.slots[i], target, now)
+		}
+	} else {
+		for tick := w.ticks + 1; tick <= target; tick++ {
+			w.expire(&w.slots[tick%wheelSlots], target, now)
+		}
+	}
+	w.ticks = target
+}
+
+// expire fires the timers of slot s scheduled by tick target which expired
+// by now. Those expiring later in the tick are moved to the next tick.
+func (w *Wheel) expire(s *wheelTimer, target int64, now time.Time) {
+	for t := s.next; t != s; {
+		next := t.next
+		if t.tick <= target {
+			w.remove(t)
+			if t.deadline.After(now) {
+				t.tick = target + 1
+				w.link(t)
+				w.active++
+			} else {
+				t.fire(now)
+			}
+		}
+		t = next
+	}
+}
+
+type wheelTimer struct {
+	w          *Wheel
+	c          chan time.Time
+	deadline   time.Time   // guarded by w.mu
+	tick       int64       // the tick the timer is scheduled on, guarded by w.mu
+	prev, next *wheelTimer // nil when the timer isn't active, guarded by w.mu
+}
+
+func (t *wheelTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *wheelTimer) Stop() bool {
+	t.w.mu.Lock()
+	defer t.w.mu.Unlock()
+	return t.w.remove(t)
+}
+
+func (t *wheelTimer) Reset(d time.Duration) bool {
+	now := t.w.base.Now()
+	t.w.mu.Lock()
+	defer t.w.mu.Unlock()
+	active := t.w.remove(t)
+	t.deadline = now.Add(d)
+	if d <= 0 {
+		t.fire(now)
+		return active
+	}
+	t.w.add(t, now)
+	return active
+}
+
+// fire sends now on the timer's channel, dropping it if the previous
+// one hasn't been received, as *time.Timer does.
+func (t *wheelTimer) fire(now time.Time) {
+	select {
+	case t.c <- now:
+	default:
+	}
+}
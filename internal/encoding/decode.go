@@ -28,13 +28,14 @@ type unmarshaler interface {
 // Pointers to primitive types will be decoded via the appropriate read[Type] function.
 //
 // If i is a pointer to a pointer (**Type), it will be dereferenced and a new instance
-// of (*Type) is allocated via reflection.
+// of (*Type) is allocated via reflection. Wrap such pointers with Optional
+// to avoid the reflection.
 //
 // Common map types (map[string]string, map[Symbol]any, and
 // map[any]any), will be decoded via conversion to the mapStringAny,
 // mapSymbolAny, and mapAnyAny types.
 func Unmarshal(r *buffer.Buffer, i any) error {
-	if tryReadNull(r) {
+	if TryReadNull(r) {
 		return nil
 	}
 
@@ -76,13 +77,13 @@ func Unmarshal(r *buffer.Buffer, i any) error {
 		}
 		*t = val
 	case *uint32:
-		val, err := readUint32(r)
+		val, err := ReadUint32(r)
 		if err != nil {
 			return err
 		}
 		*t = val
 	case **uint32: // fastpath for uint32 pointer fields
-		val, err := readUint32(r)
+		val, err := ReadUint32(r)
 		if err != nil {
 			return err
 		}
@@ -93,6 +94,12 @@ func Unmarshal(r *buffer.Buffer, i any) error {
 			return err
 		}
 		*t = val
+	case **uint16: // fastpath for uint16 pointer fields
+		val, err := readUshort(r)
+		if err != nil {
+			return err
+		}
+		*t = &val
 	case *uint8:
 		val, err := ReadUbyte(r)
 		if err != nil {
@@ -117,6 +124,15 @@ func Unmarshal(r *buffer.Buffer, i any) error {
 			return err
 		}
 		*t = val
+	case **string: // fastpath for optional string fields
+		val, err := ReadString(r)
+		if err != nil {
+			return err
+		}
+		if *t == nil {
+			*t = new(string)
+		}
+		**t = val
 	case *Symbol:
 		s, err := ReadString(r)
 		if err != nil {
@@ -124,13 +140,13 @@ func Unmarshal(r *buffer.Buffer, i any) error {
 		}
 		*t = Symbol(s)
 	case *[]byte:
-		val, err := readBinary(r)
+		val, err := ReadBinary(r)
 		if err != nil {
 			return err
 		}
 		*t = val
 	case *bool:
-		b, err := readBool(r)
+		b, err := ReadBool(r)
 		if err != nil {
 			return err
 		}
@@ -141,6 +157,15 @@ func Unmarshal(r *buffer.Buffer, i any) error {
 			return err
 		}
 		*t = ts
+	case **time.Time: // fastpath for optional timestamp fields
+		ts, err := readTimestamp(r)
+		if err != nil {
+			return err
+		}
+		if *t == nil {
+			*t = new(time.Time)
+		}
+		**t = ts
 	case *[]int8:
 		return (*arrayInt8)(t).Unmarshal(r)
 	case *[]uint16:
@@ -196,6 +221,10 @@ func Unmarshal(r *buffer.Buffer, i any) error {
 			*t = new(StateRejected)
 		case TypeCodeStateReleased:
 			*t = new(StateReleased)
+		case TypeCodeStateDeclared:
+			*t = new(StateDeclared)
+		case TypeCodeStateTransactional:
+			*t = new(StateTransactional)
 		default:
 			return fmt.Errorf("unexpected type %d for deliveryState", type_)
 		}
@@ -208,6 +237,13 @@ func Unmarshal(r *buffer.Buffer, i any) error {
 		}
 		*t = v
 
+	case **Error: // fastpaths for optional composite fields
+		return optional[Error, *Error]{p: t}.Unmarshal(r)
+	case **SenderSettleMode:
+		return optional[SenderSettleMode, *SenderSettleMode]{p: t}.Unmarshal(r)
+	case **ReceiverSettleMode:
+		return optional[ReceiverSettleMode, *ReceiverSettleMode]{p: t}.Unmarshal(r)
+
 	case unmarshaler:
 		return t.Unmarshal(r)
 	default:
@@ -230,30 +266,49 @@ func Unmarshal(r *buffer.Buffer, i any) error {
 	return nil
 }
 
+// Optional returns the field to unmarshal the optional value p points
+// to into, allocating it when it's nil. It avoids the reflection Unmarshal
+// resorts to for pointers to pointers of types it doesn't know.
+func Optional[T any, PT interface {
+	*T
+	unmarshaler
+}](p **T) any {
+	return optional[T, PT]{p: p}
+}
+
+// optional unmarshals into the optional value of type T p points to.
+type optional[T any, PT interface {
+	*T
+	unmarshaler
+}] struct {
+	p **T
+}
+
+func (o optional[T, PT]) Unmarshal(r *buffer.Buffer) error {
+	if *o.p == nil {
+		*o.p = new(T)
+	}
+	return PT(*o.p).Unmarshal(r)
+}
+
 // unmarshalComposite is a helper for use in a composite's unmarshal() function.
 //
 // The composite from r will be unmarshaled into zero or more fields. An error
 // will be returned if typ does not match the decoded type.
 func UnmarshalComposite(r *buffer.Buffer, type_ AMQPType, fields ...UnmarshalField) error {
-	cType, numFields, err := readCompositeHeader(r)
-	if err != nil {
-		return err
+	defer r.Ascend()
+	if !r.Descend() {
+		return depthError(r)
 	}
 
-	// check type matches expectation
-	if cType != type_ {
-		return fmt.Errorf("invalid header %#0x for %#0x", cType, type_)
-	}
-
-	// Validate the field count is less than or equal to the number of fields
-	// provided. Fields may be omitted by the sender if they are not set.
-	if numFields > int64(len(fields)) {
-		return fmt.Errorf("invalid field count %d for %#0x", numFields, type_)
+	numFields, unknown, err := ReadCompositeHeader(r, type_, len(fields))
+	if err != nil {
+		return err
 	}
 
 	for i, field := range fields[:numFields] {
 		// If the field is null and handleNull is set, call it.
-		if tryReadNull(r) {
+		if TryReadNull(r) {
 			if field.HandleNull != nil {
 				err = field.HandleNull()
 				if err != nil {
@@ -280,6 +335,46 @@ func UnmarshalComposite(r *buffer.Buffer, type_ AMQPType, fields ...UnmarshalFie
 		}
 	}
 
+	return SkipFields(r, unknown)
+}
+
+// ReadCompositeHeader reads the header of a composite of type type_ from r,
+// and returns its number of fields, which is at most maxFields, and the number
+// of unknown fields following them. Unknown fields are only accepted when
+// r.SkipUnknownFields is true, and must be skipped with SkipFields once the
+// known fields have been read.
+//
+// It's used by composites decoding their fields themselves rather than
+// with UnmarshalComposite, to avoid its overhead on hot paths.
+func ReadCompositeHeader(r *buffer.Buffer, type_ AMQPType, maxFields int) (fields, unknown int, err error) {
+	cType, numFields, err := readCompositeHeader(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// check type matches expectation
+	if cType != type_ {
+		return 0, 0, fmt.Errorf("invalid header %#0x for %#0x", cType, type_)
+	}
+
+	// Validate the field count is less than or equal to the number of fields
+	// provided. Fields may be omitted by the sender if they are not set.
+	if numFields > int64(maxFields) {
+		if !r.SkipUnknownFields() {
+			return 0, 0, fmt.Errorf("invalid field count %d for %#0x", numFields, type_)
+		}
+		return maxFields, int(numFields) - maxFields, nil
+	}
+	return int(numFields), 0, nil
+}
+
+// SkipFields reads and discards n fields of a composite from r.
+func SkipFields(r *buffer.Buffer, n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := ReadAny(r); err != nil {
+			return fmt.Errorf("skipping unknown field: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -369,6 +464,9 @@ func readListHeader(r *buffer.Buffer) (length int64, _ error) {
 		return 0, fmt.Errorf("type code %#02x is not a recognized list type", type_)
 	}
 
+	if err := checkElements(r, length); err != nil {
+		return 0, err
+	}
 	return length, nil
 }
 
@@ -408,10 +506,21 @@ func readArrayHeader(r *buffer.Buffer) (length int64, _ error) {
 	default:
 		return 0, fmt.Errorf("type code %#02x is not a recognized array type", type_)
 	}
+
+	if err := checkElements(r, length); err != nil {
+		return 0, err
+	}
 	return length, nil
 }
 
+// ReadString reads a string or a symbol from r. Symbols are interned.
 func ReadString(r *buffer.Buffer) (string, error) {
+	return readString(r, false)
+}
+
+// readString reads a string or a symbol from r, interning it if it's a
+// symbol or internStr is true.
+func readString(r *buffer.Buffer, internStr bool) (string, error) {
 	type_, err := readType(r)
 	if err != nil {
 		return "", err
@@ -435,14 +544,23 @@ func ReadString(r *buffer.Buffer) (string, error) {
 		return "", fmt.Errorf("type code %#02x is not a recognized string type", type_)
 	}
 
+	if err := checkLength(r, length); err != nil {
+		return "", err
+	}
+
 	buf, ok := r.Next(length)
 	if !ok {
 		return "", errors.New("invalid length")
 	}
+	if internStr || type_ == TypeCodeSym8 || type_ == TypeCodeSym32 {
+		return interned.intern(buf), nil
+	}
 	return string(buf), nil
 }
 
-func readBinary(r *buffer.Buffer) ([]byte, error) {
+// ReadBinary decodes a binary value. Unlike Unmarshal, it doesn't need a
+// pointer to decode into, so the value doesn't escape to the heap.
+func ReadBinary(r *buffer.Buffer) ([]byte, error) {
 	type_, err := readType(r)
 	if err != nil {
 		return nil, err
@@ -466,6 +584,10 @@ func readBinary(r *buffer.Buffer) ([]byte, error) {
 		return nil, fmt.Errorf("type code %#02x is not a recognized binary type", type_)
 	}
 
+	if err := checkLength(r, length); err != nil {
+		return nil, err
+	}
+
 	if length == 0 {
 		// An empty value and a nil value are distinct,
 		// ensure that the returned value is not nil in this case.
@@ -476,11 +598,15 @@ func readBinary(r *buffer.Buffer) ([]byte, error) {
 	if !ok {
 		return nil, errors.New("invalid length")
 	}
+	if r.ZeroCopy() {
+		// limit capacity so appending to the value can't clobber what follows it
+		return buf[:len(buf):len(buf)], nil
+	}
 	return append([]byte(nil), buf...), nil
 }
 
 func ReadAny(r *buffer.Buffer) (any, error) {
-	if tryReadNull(r) {
+	if TryReadNull(r) {
 		return nil, nil
 	}
 
@@ -492,11 +618,13 @@ func ReadAny(r *buffer.Buffer) (any, error) {
 	switch type_ {
 	// composite
 	case 0x0:
+		// composites count their own nesting
+		// in UnmarshalComposite and DescribedType.Unmarshal
 		return readComposite(r)
 
 	// bool
 	case TypeCodeBool, TypeCodeBoolTrue, TypeCodeBoolFalse:
-		return readBool(r)
+		return ReadBool(r)
 
 	// uint
 	case TypeCodeUbyte:
@@ -506,7 +634,7 @@ func ReadAny(r *buffer.Buffer) (any, error) {
 	case TypeCodeUint,
 		TypeCodeSmallUint,
 		TypeCodeUint0:
-		return readUint32(r)
+		return ReadUint32(r)
 	case TypeCodeUlong,
 		TypeCodeSmallUlong,
 		TypeCodeUlong0:
@@ -532,7 +660,7 @@ func ReadAny(r *buffer.Buffer) (any, error) {
 
 	// binary
 	case TypeCodeVbin8, TypeCodeVbin32:
-		return readBinary(r)
+		return ReadBinary(r)
 
 	// strings
 	case TypeCodeStr8, TypeCodeStr32:
@@ -554,17 +682,17 @@ func ReadAny(r *buffer.Buffer) (any, error) {
 
 	// arrays
 	case TypeCodeArray8, TypeCodeArray32:
-		return readAnyArray(r)
+		return readNested(r, readAnyArray)
 
 	// lists
 	case TypeCodeList0, TypeCodeList8, TypeCodeList32:
-		return readAnyList(r)
+		return readNested(r, readAnyList)
 
 	// maps
 	case TypeCodeMap8:
-		return readAnyMap(r)
+		return readNested(r, readAnyMap)
 	case TypeCodeMap32:
-		return readAnyMap(r)
+		return readNested(r, readAnyMap)
 
 	// TODO: implement
 	case TypeCodeDecimal32:
@@ -580,43 +708,98 @@ func ReadAny(r *buffer.Buffer) (any, error) {
 	}
 }
 
+// readAnyMap decodes a map as a map[string]any when all its keys are strings
+// or symbols, or as a map[any]any otherwise. String keys are decoded directly
+// into a map[string]any, switching to a map[any]any on the first other key,
+// so the common case builds a single map.
 func readAnyMap(r *buffer.Buffer) (any, error) {
-	var m map[any]any
-	err := (*mapAnyAny)(&m).Unmarshal(r)
+	count, err := readMapHeader(r)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(m) == 0 {
-		return m, nil
+	if count == 0 {
+		return map[any]any{}, nil
 	}
 
-	stringKeys := true
-Loop:
-	for key := range m {
-		switch key.(type) {
-		case string:
-		case Symbol:
-		default:
-			stringKeys = false
-			break Loop
+	var (
+		sm map[string]any
+		am map[any]any
+	)
+	for i := uint32(0); i < count; i += 2 {
+		if am == nil && isStringType(r) {
+			key, err := readKey(r)
+			if err != nil {
+				return nil, err
+			}
+			value, err := ReadAny(r)
+			if err != nil {
+				return nil, err
+			}
+			if sm == nil {
+				sm = make(map[string]any, count/2)
+			}
+			sm[key] = value
+			continue
 		}
-	}
 
-	if stringKeys {
-		mm := make(map[string]any, len(m))
-		for key, value := range m {
-			switch key := key.(type) {
-			case string:
-				mm[key] = value
-			case Symbol:
-				mm[string(key)] = value
+		key, err := ReadAny(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := ReadAny(r)
+		if err != nil {
+			return nil, err
+		}
+		if !isHashable(key) {
+			return nil, errors.New("invalid map key")
+		}
+		if am == nil {
+			am = make(map[any]any, count/2)
+			for k, v := range sm {
+				am[k] = v
 			}
 		}
-		return mm, nil
+		am[key] = value
 	}
 
-	return m, nil
+	if am != nil {
+		return am, nil
+	}
+	return sm, nil
+}
+
+// isStringType returns true if the next value in r is a string or a symbol.
+func isStringType(r *buffer.Buffer) bool {
+	type_, err := peekType(r)
+	if err != nil {
+		return false
+	}
+	switch type_ {
+	case TypeCodeStr8, TypeCodeStr32, TypeCodeSym8, TypeCodeSym32:
+		return true
+	default:
+		return false
+	}
+}
+
+// isHashable returns true if v, as returned by ReadAny, can be used as a map key.
+//
+// https://golang.org/ref/spec#Map_types:
+// The comparison operators == and != must be fully defined
+// for operands of the key type; thus the key type must not
+// be a function, map, or slice.
+func isHashable(v any) bool {
+	switch v.(type) {
+	case nil, string, bool, int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32, float64, time.Time, UUID:
+		return true
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Slice, reflect.Func, reflect.Map:
+		return false
+	default:
+		return true
+	}
 }
 
 func readAnyList(r *buffer.Buffer) (any, error) {
@@ -794,6 +977,24 @@ func readComposite(r *buffer.Buffer) (any, error) {
 		t := new(StateReleased)
 		err := t.Unmarshal(r)
 		return t, err
+	case TypeCodeStateDeclared:
+		t := new(StateDeclared)
+		err := t.Unmarshal(r)
+		return t, err
+	case TypeCodeStateTransactional:
+		t := new(StateTransactional)
+		err := t.Unmarshal(r)
+		return t, err
+
+	// Transactions
+	case TypeCodeDeclare:
+		t := new(Declare)
+		err := t.Unmarshal(r)
+		return t, err
+	case TypeCodeDischarge:
+		t := new(Discharge)
+		err := t.Unmarshal(r)
+		return t, err
 
 	case TypeCodeOpen,
 		TypeCodeBegin,
@@ -806,6 +1007,7 @@ func readComposite(r *buffer.Buffer) (any, error) {
 		TypeCodeClose,
 		TypeCodeSource,
 		TypeCodeTarget,
+		TypeCodeCoordinator,
 		TypeCodeMessageHeader,
 		TypeCodeDeliveryAnnotations,
 		TypeCodeMessageAnnotations,
@@ -860,7 +1062,7 @@ func readInt(r *buffer.Buffer) (int, error) {
 		n, err := readUshort(r)
 		return int(n), err
 	case TypeCodeUint0, TypeCodeSmallUint, TypeCodeUint:
-		n, err := readUint32(r)
+		n, err := ReadUint32(r)
 		return int(n), err
 	case TypeCodeUlong0, TypeCodeSmallUlong, TypeCodeUlong:
 		n, err := readUlong(r)
@@ -974,7 +1176,8 @@ func readUshort(r *buffer.Buffer) (uint16, error) {
 	return r.ReadUint16()
 }
 
-func readUint32(r *buffer.Buffer) (uint32, error) {
+// ReadUint32 reads a uint from r.
+func ReadUint32(r *buffer.Buffer) (uint32, error) {
 	type_, err := readType(r)
 	if err != nil {
 		return 0, err
@@ -1040,7 +1243,8 @@ func readDouble(r *buffer.Buffer) (float64, error) {
 	return math.Float64frombits(bits), err
 }
 
-func readBool(r *buffer.Buffer) (bool, error) {
+// ReadBool reads a boolean from r.
+func ReadBool(r *buffer.Buffer) (bool, error) {
 	type_, err := readType(r)
 	if err != nil {
 		return false, err
@@ -1145,5 +1349,42 @@ func readMapHeader(r *buffer.Buffer) (count uint32, _ error) {
 	if int(count) > r.Len() {
 		return 0, errors.New("invalid length")
 	}
+	if err := checkElements(r, int64(count)); err != nil {
+		return 0, err
+	}
 	return count, nil
 }
+
+// checkElements returns an error if count exceeds the
+// MaxElements limit configured on r.
+func checkElements(r *buffer.Buffer, count int64) error {
+	if max := r.Limits().MaxElements; max > 0 && count > int64(max) {
+		return fmt.Errorf("element count %d exceeds limit of %d", count, max)
+	}
+	return nil
+}
+
+// checkLength returns an error if length exceeds the
+// MaxLength limit configured on r.
+func checkLength(r *buffer.Buffer, length int64) error {
+	if max := r.Limits().MaxLength; max > 0 && length > int64(max) {
+		return fmt.Errorf("length %d exceeds limit of %d", length, max)
+	}
+	return nil
+}
+
+// readNested calls read after entering a new level of nesting in r.
+// An error is returned if doing so exceeds the MaxDepth limit configured on r.
+func readNested(r *buffer.Buffer, read func(*buffer.Buffer) (any, error)) (any, error) {
+	defer r.Ascend()
+	if !r.Descend() {
+		return nil, depthError(r)
+	}
+	return read(r)
+}
+
+// depthError returns the error reported when a value in r
+// exceeds the MaxDepth limit configured on r.
+func depthError(r *buffer.Buffer) error {
+	return fmt.Errorf("nesting depth exceeds limit of %d", r.Limits().MaxDepth)
+}
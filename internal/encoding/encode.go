@@ -453,7 +453,8 @@ func writeMap(wr *buffer.Buffer, m any) error {
 	case Unsettled:
 		pairs = len(m) * 2
 		for key, val := range m {
-			err := writeString(wr, key)
+			// the keys are delivery tags
+			err := WriteBinary(wr, []byte(key))
 			if err != nil {
 				return err
 			}
@@ -492,6 +493,8 @@ func writeMap(wr *buffer.Buffer, m any) error {
 				writeInt64(wr, key)
 			case int:
 				writeInt64(wr, int64(key))
+			case uint64:
+				writeUint64(wr, key)
 			default:
 				return fmt.Errorf("unsupported Annotations key type %T", key)
 			}
@@ -24,6 +24,14 @@ func fuzzUnmarshal(data []byte) int {
 		new(*StateReleased),
 		new(StateModified),
 		new(*StateModified),
+		new(StateDeclared),
+		new(*StateDeclared),
+		new(StateTransactional),
+		new(*StateTransactional),
+		new(Declare),
+		new(*Declare),
+		new(Discharge),
+		new(*Discharge),
 		new(mapAnyAny),
 		new(*mapAnyAny),
 		new(mapStringAny),
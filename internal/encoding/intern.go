@@ -0,0 +1,57 @@
+package encoding
+
+import (
+	"sync/atomic"
+
+	"github.com/Azure/go-amqp/internal/buffer"
+)
+
+const (
+	// maxInternLen is the length of the longest string that's interned.
+	maxInternLen = 64
+
+	// internSlots is the number of strings the table holds. It's a power
+	// of two, so a hash is mapped to its slot with a mask.
+	internSlots = 4096
+)
+
+// interned contains the symbols and map keys decoded recently, so that the
+// identical annotation keys, content types, error conditions, etc. of many
+// messages share a single string instead of each holding its own copy.
+var interned internTable
+
+// internTable is a fixed-size, direct-mapped cache of strings. It's safe for
+// concurrent use without locking: a string colliding with another replaces
+// it, so peers sending many distinct strings only cause misses rather than
+// contention between the connections decoding concurrently.
+type internTable struct {
+	slots [internSlots]atomic.Value // string
+}
+
+// intern returns a string equal to b, allocating it only if it isn't in
+// the table. b isn't retained.
+func (t *internTable) intern(b []byte) string {
+	if len(b) > maxInternLen {
+		return string(b)
+	}
+
+	// FNV-1a
+	h := uint32(2166136261)
+	for _, c := range b {
+		h ^= uint32(c)
+		h *= 16777619
+	}
+	slot := &t.slots[h&(internSlots-1)]
+	if s, ok := slot.Load().(string); ok && s == string(b) { // doesn't allocate
+		return s
+	}
+
+	s := string(b)
+	slot.Store(s)
+	return s
+}
+
+// readKey reads a string or symbol map key, interning it.
+func readKey(r *buffer.Buffer) (string, error) {
+	return readString(r, true)
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"reflect"
 	"time"
 	"unicode/utf8"
 
@@ -102,6 +101,12 @@ const (
 	TypeCodeStateReleased AMQPType = 0x26
 	TypeCodeStateModified AMQPType = 0x27
 
+	TypeCodeCoordinator        AMQPType = 0x30
+	TypeCodeDeclare            AMQPType = 0x31
+	TypeCodeDischarge          AMQPType = 0x32
+	TypeCodeStateDeclared      AMQPType = 0x33
+	TypeCodeStateTransactional AMQPType = 0x34
+
 	TypeCodeSASLMechanism AMQPType = 0x40
 	TypeCodeSASLInit      AMQPType = 0x41
 	TypeCodeSASLChallenge AMQPType = 0x42
@@ -323,7 +328,7 @@ func (rl Role) String() string {
 }
 
 func (rl *Role) Unmarshal(r *buffer.Buffer) error {
-	b, err := readBool(r)
+	b, err := ReadBool(r)
 	*rl = Role(b)
 	return err
 }
@@ -353,11 +358,13 @@ func (s *SASLCode) Unmarshal(r *buffer.Buffer) error {
 
 // DeliveryState encapsulates the various concrete delivery states.
 // http://docs.oasis-open.org/amqp/core/v1.0/os/amqp-core-messaging-v1.0-os.html#section-delivery-state
-// TODO: http://docs.oasis-open.org/amqp/core/v1.0/os/amqp-core-transactions-v1.0-os.html#type-declared
+// http://docs.oasis-open.org/amqp/core/v1.0/os/amqp-core-transactions-v1.0-os.html#type-declared
 type DeliveryState interface {
 	deliveryState() // marker method
 }
 
+// Unsettled maps the delivery tags of unsettled deliveries to their state.
+// A nil state means the state isn't known.
 type Unsettled map[string]DeliveryState
 
 func (u Unsettled) Marshal(wr *buffer.Buffer) error {
@@ -372,9 +379,26 @@ func (u *Unsettled) Unmarshal(r *buffer.Buffer) error {
 
 	m := make(Unsettled, count/2)
 	for i := uint32(0); i < count; i += 2 {
-		key, err := ReadString(r)
-		if err != nil {
+		// the keys are binary delivery tags, strings are also
+		// accepted for peers that encode them as such
+		var key string
+		if type_, err := peekType(r); err != nil {
+			return err
+		} else if type_ == TypeCodeVbin8 || type_ == TypeCodeVbin32 {
+			tag, err := ReadBinary(r)
+			if err != nil {
+				return err
+			}
+			key = string(tag)
+		} else if key, err = ReadString(r); err != nil {
+			return err
+		}
+		if type_, err := peekType(r); err != nil {
 			return err
+		} else if type_ == TypeCodeNull {
+			_, _ = r.ReadByte()
+			m[key] = nil
+			continue
 		}
 		var value DeliveryState
 		err = Unmarshal(r, &value)
@@ -452,7 +476,8 @@ func PeekMessageType(buf []byte) (uint8, uint8, error) {
 	return uint8(v), 10, nil
 }
 
-func tryReadNull(r *buffer.Buffer) bool {
+// TryReadNull consumes a null from r, returning true if the next value is null.
+func TryReadNull(r *buffer.Buffer) bool {
 	if r.Len() > 0 && AMQPType(r.Bytes()[0]) == TypeCodeNull {
 		r.Skip(1)
 		return true
@@ -460,9 +485,10 @@ func tryReadNull(r *buffer.Buffer) bool {
 	return false
 }
 
-// Annotations keys must be of type string, int, or int64.
+// Annotations keys must be of type string, uint64, int, or int64.
 //
-// String keys are encoded as AMQP Symbols.
+// String keys are encoded as AMQP Symbols. The AMQP spec only permits
+// symbol and ulong keys; int and int64 keys are encoded as longs.
 type Annotations map[any]any
 
 func (a Annotations) Marshal(wr *buffer.Buffer) error {
@@ -494,6 +520,12 @@ func (a *Annotations) Unmarshal(r *buffer.Buffer) error {
 // ErrCond is one of the error conditions defined in the AMQP spec.
 type ErrCond string
 
+// Error implements the error interface for ErrCond so that
+// conditions can be used as targets for errors.Is.
+func (ec ErrCond) Error() string {
+	return string(ec)
+}
+
 func (ec ErrCond) Marshal(wr *buffer.Buffer) error {
 	return (Symbol)(ec).Marshal(wr)
 }
@@ -559,6 +591,12 @@ func (e *Error) Error() string {
 	return e.String()
 }
 
+// Is returns true if target is an ErrCond equal to e.Condition.
+func (e *Error) Is(target error) bool {
+	cond, ok := target.(ErrCond)
+	return ok && e.Condition == cond
+}
+
 /*
 <type name="received" class="composite" source="list" provides="delivery-state">
     <descriptor name="amqp:received:list" code="0x00000000:0x00000023"/>
@@ -735,6 +773,148 @@ func (sm *StateModified) String() string {
 	return fmt.Sprintf("Modified{DeliveryFailed: %t, UndeliverableHere: %t, MessageAnnotations: %v}", sm.DeliveryFailed, sm.UndeliverableHere, sm.MessageAnnotations)
 }
 
+/*
+<type name="declared" class="composite" source="list" provides="delivery-state, outcome">
+    <descriptor name="amqp:declared:list" code="0x00000000:0x00000033"/>
+    <field name="txn-id" type="*" requires="txn-id" mandatory="true"/>
+</type>
+*/
+
+type StateDeclared struct {
+	// the allocated transaction id
+	TxnID []byte
+}
+
+func (sd *StateDeclared) deliveryState() {}
+
+func (sd *StateDeclared) Marshal(wr *buffer.Buffer) error {
+	return MarshalComposite(wr, TypeCodeStateDeclared, []MarshalField{
+		{Value: &sd.TxnID, Omit: false},
+	})
+}
+
+func (sd *StateDeclared) Unmarshal(r *buffer.Buffer) error {
+	return UnmarshalComposite(r, TypeCodeStateDeclared, []UnmarshalField{
+		{Field: &sd.TxnID, HandleNull: func() error { return errors.New("StateDeclared.TxnID is required") }},
+	}...)
+}
+
+func (sd *StateDeclared) String() string {
+	return fmt.Sprintf("Declared{TxnID: %x}", sd.TxnID)
+}
+
+/*
+<type name="transactional-state" class="composite" source="list" provides="delivery-state">
+    <descriptor name="amqp:transactional-state:list" code="0x00000000:0x00000034"/>
+    <field name="txn-id" type="*" mandatory="true" requires="txn-id"/>
+    <field name="outcome" type="*" requires="outcome"/>
+</type>
+*/
+
+type StateTransactional struct {
+	// identifies the transaction with which the state is associated
+	TxnID []byte
+
+	// provisional outcome
+	//
+	// This field indicates the provisional outcome to be applied if the
+	// transaction commits.
+	Outcome DeliveryState
+}
+
+func (st *StateTransactional) deliveryState() {}
+
+func (st *StateTransactional) Marshal(wr *buffer.Buffer) error {
+	return MarshalComposite(wr, TypeCodeStateTransactional, []MarshalField{
+		{Value: &st.TxnID, Omit: false},
+		{Value: st.Outcome, Omit: st.Outcome == nil},
+	})
+}
+
+func (st *StateTransactional) Unmarshal(r *buffer.Buffer) error {
+	return UnmarshalComposite(r, TypeCodeStateTransactional, []UnmarshalField{
+		{Field: &st.TxnID, HandleNull: func() error { return errors.New("StateTransactional.TxnID is required") }},
+		{Field: &st.Outcome},
+	}...)
+}
+
+func (st *StateTransactional) String() string {
+	return fmt.Sprintf("TransactionalState{TxnID: %x, Outcome: %v}", st.TxnID, st.Outcome)
+}
+
+/*
+<type name="declare" class="composite" source="list">
+    <descriptor name="amqp:declare:list" code="0x00000000:0x00000031"/>
+    <field name="global-id" type="*" requires="global-tx-id"/>
+</type>
+*/
+
+type Declare struct {
+	// global transaction id
+	//
+	// Specifies that the txn-id allocated by this declare MUST be associated
+	// with the indicated global transaction. If not set, the allocated txn-id
+	// will be associated with a local transaction.
+	GlobalID any
+}
+
+func (d *Declare) Marshal(wr *buffer.Buffer) error {
+	return MarshalComposite(wr, TypeCodeDeclare, []MarshalField{
+		{Value: d.GlobalID, Omit: d.GlobalID == nil},
+	})
+}
+
+func (d *Declare) Unmarshal(r *buffer.Buffer) error {
+	return UnmarshalComposite(r, TypeCodeDeclare, []UnmarshalField{
+		{Field: &d.GlobalID},
+	}...)
+}
+
+func (d *Declare) String() string {
+	return fmt.Sprintf("Declare{GlobalID: %v}", d.GlobalID)
+}
+
+/*
+<type name="discharge" class="composite" source="list">
+    <descriptor name="amqp:discharge:list" code="0x00000000:0x00000032"/>
+    <field name="txn-id" type="*" requires="txn-id" mandatory="true"/>
+    <field name="fail" type="boolean"/>
+</type>
+*/
+
+type Discharge struct {
+	// identifies the transaction to be discharged
+	TxnID []byte
+
+	// indicates the transaction has failed
+	//
+	// If set, this flag indicates that the work associated with this transaction
+	// has failed, and the controller wishes the transaction to be rolled back. If
+	// the transaction is associated with a global-id this will render the global
+	// transaction rollback-only. If the transaction is a local transaction, then
+	// this flag controls whether the transaction is committed or aborted when it
+	// is discharged.
+	Fail bool
+}
+
+func (d *Discharge) Marshal(wr *buffer.Buffer) error {
+	return MarshalComposite(wr, TypeCodeDischarge, []MarshalField{
+		{Value: &d.TxnID, Omit: false},
+		{Value: &d.Fail, Omit: !d.Fail},
+	})
+}
+
+func (d *Discharge) Unmarshal(r *buffer.Buffer) error {
+	return UnmarshalComposite(r, TypeCodeDischarge, []UnmarshalField{
+		{Field: &d.TxnID, HandleNull: func() error { return errors.New("Discharge.TxnID is required") }},
+		{Field: &d.Fail},
+	}...)
+}
+
+func (d *Discharge) String() string {
+	return fmt.Sprintf("Discharge{TxnID: %x, Fail: %t}", d.TxnID, d.Fail)
+}
+
 // symbol is an AMQP symbolic string.
 type Symbol string
 
@@ -798,12 +978,7 @@ func (m *mapAnyAny) Unmarshal(r *buffer.Buffer) error {
 			return err
 		}
 
-		// https://golang.org/ref/spec#Map_types:
-		// The comparison operators == and != must be fully defined
-		// for operands of the key type; thus the key type must not
-		// be a function, map, or slice.
-		switch reflect.ValueOf(key).Kind() {
-		case reflect.Slice, reflect.Func, reflect.Map:
+		if !isHashable(key) {
 			return errors.New("invalid map key")
 		}
 
@@ -828,7 +1003,7 @@ func (m *mapStringAny) Unmarshal(r *buffer.Buffer) error {
 
 	mm := make(mapStringAny, count/2)
 	for i := uint32(0); i < count; i += 2 {
-		key, err := ReadString(r)
+		key, err := readKey(r)
 		if err != nil {
 			return err
 		}
@@ -890,6 +1065,20 @@ func (u UUID) String() string {
 	return string(buf[:])
 }
 
+// ParseUUID parses the hex encoded representation described in RFC 4122, Section 3.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	b, err := hex.DecodeString(s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	if err != nil {
+		return u, fmt.Errorf("invalid UUID %q: %v", s, err)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
 func (u UUID) Marshal(wr *buffer.Buffer) error {
 	wr.AppendByte(byte(TypeCodeUUID))
 	wr.Append(u[:])
@@ -948,6 +1137,11 @@ func (t DescribedType) Marshal(wr *buffer.Buffer) error {
 }
 
 func (t *DescribedType) Unmarshal(r *buffer.Buffer) error {
+	defer r.Ascend()
+	if !r.Descend() {
+		return depthError(r)
+	}
+
 	b, err := r.ReadByte()
 	if err != nil {
 		return err
@@ -1748,6 +1942,9 @@ func (a *arrayString) Unmarshal(r *buffer.Buffer) error {
 			if err != nil {
 				return err
 			}
+			if err := checkLength(r, int64(size)); err != nil {
+				return err
+			}
 
 			buf, ok := r.Next(int64(size))
 			if !ok {
@@ -1763,6 +1960,9 @@ func (a *arrayString) Unmarshal(r *buffer.Buffer) error {
 				return errors.New("invalid length")
 			}
 			size := int64(binary.BigEndian.Uint32(buf))
+			if err := checkLength(r, size); err != nil {
+				return err
+			}
 
 			buf, ok = r.Next(size)
 			if !ok {
@@ -1839,12 +2039,15 @@ func (a *arraySymbol) Unmarshal(r *buffer.Buffer) error {
 			if err != nil {
 				return err
 			}
+			if err := checkLength(r, int64(size)); err != nil {
+				return err
+			}
 
 			buf, ok := r.Next(int64(size))
 			if !ok {
 				return errors.New("invalid length")
 			}
-			aa[i] = Symbol(buf)
+			aa[i] = Symbol(interned.intern(buf))
 		}
 	case TypeCodeSym32:
 		for i := range aa {
@@ -1853,12 +2056,15 @@ func (a *arraySymbol) Unmarshal(r *buffer.Buffer) error {
 				return errors.New("invalid length")
 			}
 			size := int64(binary.BigEndian.Uint32(buf))
+			if err := checkLength(r, size); err != nil {
+				return err
+			}
 
 			buf, ok = r.Next(size)
 			if !ok {
 				return errors.New("invalid length")
 			}
-			aa[i] = Symbol(buf)
+			aa[i] = Symbol(interned.intern(buf))
 		}
 	default:
 		return fmt.Errorf("invalid type for []Symbol %02x", type_)
@@ -1929,6 +2135,9 @@ func (a *arrayBinary) Unmarshal(r *buffer.Buffer) error {
 			if err != nil {
 				return err
 			}
+			if err := checkLength(r, int64(size)); err != nil {
+				return err
+			}
 
 			buf, ok := r.Next(int64(size))
 			if !ok {
@@ -1943,6 +2152,9 @@ func (a *arrayBinary) Unmarshal(r *buffer.Buffer) error {
 				return errors.New("invalid length")
 			}
 			size := binary.BigEndian.Uint32(buf)
+			if err := checkLength(r, int64(size)); err != nil {
+				return err
+			}
 
 			buf, ok = r.Next(int64(size))
 			if !ok {
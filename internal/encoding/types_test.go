@@ -1,7 +1,10 @@
 package encoding
 
 import (
+	"fmt"
 	"math"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/Azure/go-amqp/internal/buffer"
@@ -66,3 +69,243 @@ func TestDecodeSmallInts(t *testing.T) {
 		require.Equal(t, int32(-1), val)
 	})
 }
+
+func TestDecodeLimits(t *testing.T) {
+	t.Run("MaxDepth", func(t *testing.T) {
+		buff := &buffer.Buffer{}
+		require.NoError(t, Marshal(buff, []any{[]any{[]any{"nested"}}}))
+		buff.SetLimits(buffer.Limits{MaxDepth: 2})
+
+		_, err := ReadAny(buff)
+		require.ErrorContains(t, err, "nesting depth exceeds limit of 2")
+	})
+
+	t.Run("MaxElements", func(t *testing.T) {
+		buff := &buffer.Buffer{}
+		require.NoError(t, Marshal(buff, []any{"a", "b", "c"}))
+		buff.SetLimits(buffer.Limits{MaxElements: 2})
+
+		_, err := ReadAny(buff)
+		require.ErrorContains(t, err, "element count 3 exceeds limit of 2")
+	})
+
+	t.Run("MaxLength", func(t *testing.T) {
+		buff := &buffer.Buffer{}
+		require.NoError(t, Marshal(buff, []byte("too long")))
+		buff.SetLimits(buffer.Limits{MaxLength: 4})
+
+		_, err := ReadAny(buff)
+		require.ErrorContains(t, err, "length 8 exceeds limit of 4")
+	})
+
+	t.Run("MaxLength array elements", func(t *testing.T) {
+		long := strings.Repeat("x", 300)
+		for _, tt := range []struct {
+			name  string
+			value any
+			into  any
+		}{
+			{name: "str8", value: []string{"ok", "too long"}, into: new([]string)},
+			{name: "str32", value: []string{"ok", long}, into: new([]string)},
+			{name: "sym8", value: []Symbol{"ok", "too long"}, into: new([]Symbol)},
+			{name: "sym32", value: []Symbol{"ok", Symbol(long)}, into: new([]Symbol)},
+			{name: "vbin8", value: [][]byte{[]byte("ok"), []byte("too long")}, into: new([][]byte)},
+			{name: "vbin32", value: [][]byte{[]byte("ok"), []byte(long)}, into: new([][]byte)},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				buff := &buffer.Buffer{}
+				require.NoError(t, Marshal(buff, tt.value))
+				buff.SetLimits(buffer.Limits{MaxLength: 4})
+
+				require.ErrorContains(t, Unmarshal(buff, tt.into), "exceeds limit of 4")
+			})
+		}
+	})
+
+	t.Run("MaxDepth described types", func(t *testing.T) {
+		buff := &buffer.Buffer{}
+		nested := DescribedType{Descriptor: "a", Value: DescribedType{Descriptor: "b", Value: DescribedType{Descriptor: "c", Value: "nested"}}}
+		require.NoError(t, Marshal(buff, nested))
+		buff.SetLimits(buffer.Limits{MaxDepth: 2})
+
+		var dt DescribedType
+		require.ErrorContains(t, dt.Unmarshal(buff), "nesting depth exceeds limit of 2")
+	})
+
+	t.Run("MaxDepth composites", func(t *testing.T) {
+		buff := &buffer.Buffer{}
+		require.NoError(t, Marshal(buff, &StateRejected{Error: &Error{Condition: "amqp:internal-error"}}))
+		buff.SetLimits(buffer.Limits{MaxDepth: 1})
+
+		var sr StateRejected
+		require.ErrorContains(t, sr.Unmarshal(buff), "nesting depth exceeds limit of 1")
+
+		buff.Reset()
+		require.NoError(t, Marshal(buff, &StateRejected{Error: &Error{Condition: "amqp:internal-error"}}))
+		buff.SetLimits(buffer.Limits{MaxDepth: 1})
+
+		_, err := ReadAny(buff)
+		require.ErrorContains(t, err, "nesting depth exceeds limit of 1")
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		buff := &buffer.Buffer{}
+		require.NoError(t, Marshal(buff, []any{[]any{"ok"}}))
+		buff.SetLimits(buffer.Limits{MaxDepth: 2, MaxElements: 1, MaxLength: 2})
+
+		v, err := ReadAny(buff)
+		require.NoError(t, err)
+		require.Equal(t, []any{[]any{"ok"}}, v)
+	})
+}
+
+func TestUnmarshalCompositeUnknownFields(t *testing.T) {
+	encode := func() *buffer.Buffer {
+		buff := &buffer.Buffer{}
+		cond, desc, extra := ErrCond("amqp:internal-error"), "boom", "unknown"
+		require.NoError(t, MarshalComposite(buff, TypeCodeError, []MarshalField{
+			{Value: &cond},
+			{Value: &desc},
+			{Omit: true},
+			{Value: &extra},
+		}))
+		require.NoError(t, Marshal(buff, "next"))
+		return buff
+	}
+
+	var e Error
+	require.ErrorContains(t, Unmarshal(encode(), &e), "invalid field count 4")
+
+	buff := encode()
+	buff.SetSkipUnknownFields(true)
+	require.NoError(t, Unmarshal(buff, &e))
+	require.Equal(t, Error{Condition: "amqp:internal-error", Description: "boom"}, e)
+
+	// decoding resumes after the unknown field
+	v, err := ReadAny(buff)
+	require.NoError(t, err)
+	require.Equal(t, "next", v)
+}
+
+func TestReadAnyMap(t *testing.T) {
+	decode := func(v any) (any, error) {
+		var buf buffer.Buffer
+		require.NoError(t, Marshal(&buf, v))
+		return ReadAny(&buf)
+	}
+
+	v, err := decode(map[Symbol]any{"a": int64(1), "b": "two"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": int64(1), "b": "two"}, v)
+
+	// keys decoded before the first non-string key are kept as strings
+	v, err = decode(Annotations{"a": int64(1), int64(2): "two"})
+	require.NoError(t, err)
+	require.Equal(t, map[any]any{"a": int64(1), int64(2): "two"}, v)
+
+	v, err = decode(map[string]any{})
+	require.NoError(t, err)
+	require.Equal(t, map[any]any{}, v)
+
+	// binary keys can't be used as Go map keys
+	var buf buffer.Buffer
+	buf.Append([]byte{byte(TypeCodeMap8), 5, 2, byte(TypeCodeVbin8), 1, 'k', byte(TypeCodeUint0)})
+	_, err = ReadAny(&buf)
+	require.Error(t, err)
+}
+
+func TestReadStringInterning(t *testing.T) {
+	encode := func(v any) []byte {
+		var buf buffer.Buffer
+		require.NoError(t, Marshal(&buf, v))
+		return buf.Detach()
+	}
+	read := func(data []byte) string {
+		s, err := ReadString(buffer.New(data))
+		require.NoError(t, err)
+		return s
+	}
+
+	sym := encode(Symbol("application/json"))
+	require.Equal(t, "application/json", read(sym))
+	// interned symbols don't allocate
+	require.Zero(t, testing.AllocsPerRun(10, func() { read(sym) }))
+
+	// strings and long symbols aren't interned
+	str := encode("application/json")
+	require.Equal(t, "application/json", read(str))
+	require.NotZero(t, testing.AllocsPerRun(10, func() { read(str) }))
+	long := encode(Symbol(make([]byte, maxInternLen+1)))
+	require.NotZero(t, testing.AllocsPerRun(10, func() { read(long) }))
+
+	// string map keys are interned
+	m := encode(map[string]any{"key": int64(1)})
+	var got map[string]any
+	require.NoError(t, Unmarshal(buffer.New(m), &got))
+	require.Equal(t, map[string]any{"key": int64(1)}, got)
+	require.Zero(t, testing.AllocsPerRun(10, func() { interned.intern([]byte("key")) }))
+}
+
+func TestInternTable(t *testing.T) {
+	var table internTable
+	s := table.intern([]byte("sym"))
+	require.Equal(t, "sym", s)
+	require.Zero(t, testing.AllocsPerRun(10, func() { table.intern([]byte("sym")) }))
+
+	// distinct strings replace those they collide with, the table doesn't grow
+	for i := 0; i < internSlots*2; i++ {
+		sym := fmt.Sprintf("sym%d", i)
+		require.Equal(t, sym, table.intern([]byte(sym)))
+	}
+	require.Equal(t, "sym", table.intern([]byte("sym")))
+}
+
+func TestInternTableConcurrent(t *testing.T) {
+	var table internTable
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				sym := fmt.Sprintf("sym%d", (i*(g+1))%100)
+				if got := table.intern([]byte(sym)); got != sym {
+					t.Errorf("interned %q as %q", sym, got)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkReadAny(b *testing.B) {
+	values := map[string]any{
+		"map": map[string]any{
+			"id":      int64(123456),
+			"name":    "order",
+			"price":   9.99,
+			"express": true,
+		},
+		"list": []any{uint32(1000), "two", int64(-3000), []byte("four"), Symbol("five")},
+		"nested": map[any]any{
+			int64(1): []any{"a", map[string]any{"b": uint64(2)}},
+			"c":      []any{},
+		},
+	}
+	for name, v := range values {
+		b.Run(name, func(b *testing.B) {
+			var buf buffer.Buffer
+			require.NoError(b, Marshal(&buf, v))
+			data := buf.Bytes()
+
+			b.ResetTimer()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ReadAny(buffer.New(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
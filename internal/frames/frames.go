@@ -337,6 +337,40 @@ func (t Target) String() string {
 	)
 }
 
+/*
+<type name="coordinator" class="composite" source="list" provides="target">
+
+	<descriptor name="amqp:coordinator:list" code="0x00000000:0x00000030"/>
+	<field name="capabilities" type="symbol" requires="txn-capability" multiple="true"/>
+
+</type>
+*/
+type Coordinator struct {
+	// the capabilities supported at the coordinator
+	//
+	// When sent by the transaction controller (the sending endpoint), indicates the
+	// desired capabilities of the coordinator. When sent by the resource (the
+	// receiving endpoint), defined the actual capabilities of the coordinator.
+	// http://www.amqp.org/specification/1.0/txn-capabilities
+	Capabilities encoding.MultiSymbol
+}
+
+func (c *Coordinator) Marshal(wr *buffer.Buffer) error {
+	return encoding.MarshalComposite(wr, encoding.TypeCodeCoordinator, []encoding.MarshalField{
+		{Value: &c.Capabilities, Omit: len(c.Capabilities) == 0},
+	})
+}
+
+func (c *Coordinator) Unmarshal(r *buffer.Buffer) error {
+	return encoding.UnmarshalComposite(r, encoding.TypeCodeCoordinator, []encoding.UnmarshalField{
+		{Field: &c.Capabilities},
+	}...)
+}
+
+func (c Coordinator) String() string {
+	return fmt.Sprintf("Coordinator{Capabilities: %v}", c.Capabilities)
+}
+
 // frame is the decoded representation of a frame
 type Frame struct {
 	Type    uint8     // AMQP/SASL
@@ -626,6 +660,12 @@ type PerformAttach struct {
 	// attached to the link. A link with no target will never permit incoming messages.
 	Target *Target
 
+	// the transaction coordinator the messages are sent to
+	//
+	// Coordinator is set instead of Target when the link is used by a transaction
+	// controller to declare and discharge transactions.
+	Coordinator *Coordinator
+
 	// unsettled delivery state
 	//
 	// This is used to indicate any unsettled delivery states when a suspended link is
@@ -691,7 +731,7 @@ func (a *PerformAttach) frameBody() {}
 
 func (a PerformAttach) String() string {
 	return fmt.Sprintf("Attach{Name: %s, Handle: %d, Role: %s, SenderSettleMode: %s, ReceiverSettleMode: %s, "+
-		"Source: %v, Target: %v, Unsettled: %v, IncompleteUnsettled: %t, InitialDeliveryCount: %d, MaxMessageSize: %d, "+
+		"Source: %v, Target: %v, Coordinator: %v, Unsettled: %v, IncompleteUnsettled: %t, InitialDeliveryCount: %d, MaxMessageSize: %d, "+
 		"OfferedCapabilities: %v, DesiredCapabilities: %v, Properties: %v}",
 		a.Name,
 		a.Handle,
@@ -700,6 +740,7 @@ func (a PerformAttach) String() string {
 		a.ReceiverSettleMode,
 		a.Source,
 		a.Target,
+		a.Coordinator,
 		a.Unsettled,
 		a.IncompleteUnsettled,
 		a.InitialDeliveryCount,
@@ -711,6 +752,13 @@ func (a PerformAttach) String() string {
 }
 
 func (a *PerformAttach) Marshal(wr *buffer.Buffer) error {
+	var target any
+	switch {
+	case a.Coordinator != nil:
+		target = a.Coordinator
+	case a.Target != nil:
+		target = a.Target
+	}
 	return encoding.MarshalComposite(wr, encoding.TypeCodeAttach, []encoding.MarshalField{
 		{Value: &a.Name, Omit: false},
 		{Value: &a.Handle, Omit: false},
@@ -718,7 +766,7 @@ func (a *PerformAttach) Marshal(wr *buffer.Buffer) error {
 		{Value: a.SenderSettleMode, Omit: a.SenderSettleMode == nil},
 		{Value: a.ReceiverSettleMode, Omit: a.ReceiverSettleMode == nil},
 		{Value: a.Source, Omit: a.Source == nil},
-		{Value: a.Target, Omit: a.Target == nil},
+		{Value: target, Omit: target == nil},
 		{Value: a.Unsettled, Omit: len(a.Unsettled) == 0},
 		{Value: &a.IncompleteUnsettled, Omit: !a.IncompleteUnsettled},
 		{Value: &a.InitialDeliveryCount, Omit: a.Role == encoding.RoleReceiver},
@@ -736,8 +784,8 @@ func (a *PerformAttach) Unmarshal(r *buffer.Buffer) error {
 		{Field: &a.Role, HandleNull: func() error { return errors.New("Attach.Role is required") }},
 		{Field: &a.SenderSettleMode},
 		{Field: &a.ReceiverSettleMode},
-		{Field: &a.Source},
-		{Field: &a.Target},
+		{Field: encoding.Optional(&a.Source)},
+		{Field: attachTarget{a}},
 		{Field: &a.Unsettled},
 		{Field: &a.IncompleteUnsettled},
 		{Field: &a.InitialDeliveryCount},
@@ -748,6 +796,23 @@ func (a *PerformAttach) Unmarshal(r *buffer.Buffer) error {
 	}...)
 }
 
+// attachTarget decodes the target of an attach into
+// either its Target or its Coordinator.
+type attachTarget struct {
+	a *PerformAttach
+}
+
+func (t attachTarget) Unmarshal(r *buffer.Buffer) error {
+	type_, _, err := encoding.PeekMessageType(r.Bytes())
+	if err != nil {
+		return err
+	}
+	if encoding.AMQPType(type_) == encoding.TypeCodeCoordinator {
+		return encoding.Unmarshal(r, encoding.Optional(&t.a.Coordinator))
+	}
+	return encoding.Unmarshal(r, encoding.Optional(&t.a.Target))
+}
+
 /*
 <type name="flow" class="composite" source="list" provides="frame">
 
@@ -907,20 +972,62 @@ func (f *PerformFlow) Marshal(wr *buffer.Buffer) error {
 	})
 }
 
+// Flow, transfer and disposition performatives are exchanged for every
+// message, so they're decoded field by field instead of with the more
+// general encoding.UnmarshalComposite.
 func (f *PerformFlow) Unmarshal(r *buffer.Buffer) error {
-	return encoding.UnmarshalComposite(r, encoding.TypeCodeFlow, []encoding.UnmarshalField{
-		{Field: &f.NextIncomingID},
-		{Field: &f.IncomingWindow, HandleNull: func() error { return errors.New("Flow.IncomingWindow is required") }},
-		{Field: &f.NextOutgoingID, HandleNull: func() error { return errors.New("Flow.NextOutgoingID is required") }},
-		{Field: &f.OutgoingWindow, HandleNull: func() error { return errors.New("Flow.OutgoingWindow is required") }},
-		{Field: &f.Handle},
-		{Field: &f.DeliveryCount},
-		{Field: &f.LinkCredit},
-		{Field: &f.Available},
-		{Field: &f.Drain},
-		{Field: &f.Echo},
-		{Field: &f.Properties},
-	}...)
+	const numFields = 11
+	n, unknown, err := encoding.ReadCompositeHeader(r, encoding.TypeCodeFlow, numFields)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		if i >= n || encoding.TryReadNull(r) {
+			switch i {
+			case 1:
+				return errors.New("Flow.IncomingWindow is required")
+			case 2:
+				return errors.New("Flow.NextOutgoingID is required")
+			case 3:
+				return errors.New("Flow.OutgoingWindow is required")
+			}
+			continue
+		}
+		switch i {
+		case 0:
+			f.NextIncomingID, err = readOptionalUint32(r)
+		case 1:
+			f.IncomingWindow, err = encoding.ReadUint32(r)
+		case 2:
+			f.NextOutgoingID, err = encoding.ReadUint32(r)
+		case 3:
+			f.OutgoingWindow, err = encoding.ReadUint32(r)
+		case 4:
+			f.Handle, err = readOptionalUint32(r)
+		case 5:
+			f.DeliveryCount, err = readOptionalUint32(r)
+		case 6:
+			f.LinkCredit, err = readOptionalUint32(r)
+		case 7:
+			f.Available, err = readOptionalUint32(r)
+		case 8:
+			f.Drain, err = encoding.ReadBool(r)
+		case 9:
+			f.Echo, err = encoding.ReadBool(r)
+		case 10:
+			err = encoding.Unmarshal(r, &f.Properties)
+		}
+		if err != nil {
+			return fmt.Errorf("unmarshaling field %d: %v", i, err)
+		}
+	}
+	return encoding.SkipFields(r, unknown)
+}
+
+// readOptionalUint32 reads a uint from r into a new *uint32.
+func readOptionalUint32(r *buffer.Buffer) (*uint32, error) {
+	v, err := encoding.ReadUint32(r)
+	return &v, err
 }
 
 /*
@@ -1134,26 +1241,54 @@ func (t *PerformTransfer) Marshal(wr *buffer.Buffer) error {
 }
 
 func (t *PerformTransfer) Unmarshal(r *buffer.Buffer) error {
-	err := encoding.UnmarshalComposite(r, encoding.TypeCodeTransfer, []encoding.UnmarshalField{
-		{Field: &t.Handle, HandleNull: func() error { return errors.New("Transfer.Handle is required") }},
-		{Field: &t.DeliveryID},
-		{Field: &t.DeliveryTag},
-		{Field: &t.MessageFormat},
-		{Field: &t.Settled},
-		{Field: &t.More},
-		{Field: &t.ReceiverSettleMode},
-		{Field: &t.State},
-		{Field: &t.Resume},
-		{Field: &t.Aborted},
-		{Field: &t.Batchable},
-	}...)
+	const numFields = 11
+	n, unknown, err := encoding.ReadCompositeHeader(r, encoding.TypeCodeTransfer, numFields)
 	if err != nil {
 		return err
 	}
+	for i := 0; i < numFields; i++ {
+		if i >= n || encoding.TryReadNull(r) {
+			if i == 0 {
+				return errors.New("Transfer.Handle is required")
+			}
+			continue
+		}
+		switch i {
+		case 0:
+			t.Handle, err = encoding.ReadUint32(r)
+		case 1:
+			t.DeliveryID, err = readOptionalUint32(r)
+		case 2:
+			t.DeliveryTag, err = encoding.ReadBinary(r)
+		case 3:
+			t.MessageFormat, err = readOptionalUint32(r)
+		case 4:
+			t.Settled, err = encoding.ReadBool(r)
+		case 5:
+			t.More, err = encoding.ReadBool(r)
+		case 6:
+			t.ReceiverSettleMode = new(encoding.ReceiverSettleMode)
+			err = t.ReceiverSettleMode.Unmarshal(r)
+		case 7:
+			err = encoding.Unmarshal(r, &t.State)
+		case 8:
+			t.Resume, err = encoding.ReadBool(r)
+		case 9:
+			t.Aborted, err = encoding.ReadBool(r)
+		case 10:
+			t.Batchable, err = encoding.ReadBool(r)
+		}
+		if err != nil {
+			return fmt.Errorf("unmarshaling field %d: %v", i, err)
+		}
+	}
+	if err := encoding.SkipFields(r, unknown); err != nil {
+		return err
+	}
 
 	t.Payload = append([]byte(nil), r.Bytes()...)
 
-	return err
+	return nil
 }
 
 /*
@@ -1232,14 +1367,40 @@ func (d *PerformDisposition) Marshal(wr *buffer.Buffer) error {
 }
 
 func (d *PerformDisposition) Unmarshal(r *buffer.Buffer) error {
-	return encoding.UnmarshalComposite(r, encoding.TypeCodeDisposition, []encoding.UnmarshalField{
-		{Field: &d.Role, HandleNull: func() error { return errors.New("Disposition.Role is required") }},
-		{Field: &d.First, HandleNull: func() error { return errors.New("Disposition.Handle is required") }},
-		{Field: &d.Last},
-		{Field: &d.Settled},
-		{Field: &d.State},
-		{Field: &d.Batchable},
-	}...)
+	const numFields = 6
+	n, unknown, err := encoding.ReadCompositeHeader(r, encoding.TypeCodeDisposition, numFields)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		if i >= n || encoding.TryReadNull(r) {
+			switch i {
+			case 0:
+				return errors.New("Disposition.Role is required")
+			case 1:
+				return errors.New("Disposition.Handle is required")
+			}
+			continue
+		}
+		switch i {
+		case 0:
+			err = d.Role.Unmarshal(r)
+		case 1:
+			d.First, err = encoding.ReadUint32(r)
+		case 2:
+			d.Last, err = readOptionalUint32(r)
+		case 3:
+			d.Settled, err = encoding.ReadBool(r)
+		case 4:
+			err = encoding.Unmarshal(r, &d.State)
+		case 5:
+			d.Batchable, err = encoding.ReadBool(r)
+		}
+		if err != nil {
+			return fmt.Errorf("unmarshaling field %d: %v", i, err)
+		}
+	}
+	return encoding.SkipFields(r, unknown)
 }
 
 /*
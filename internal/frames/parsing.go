@@ -115,10 +115,18 @@ func ParseBody(r *buffer.Buffer) (FrameBody, error) {
 		t := new(SASLMechanisms)
 		err := t.Unmarshal(r)
 		return t, err
+	case encoding.TypeCodeSASLInit:
+		t := new(SASLInit)
+		err := t.Unmarshal(r)
+		return t, err
 	case encoding.TypeCodeSASLChallenge:
 		t := new(SASLChallenge)
 		err := t.Unmarshal(r)
 		return t, err
+	case encoding.TypeCodeSASLResponse:
+		t := new(SASLResponse)
+		err := t.Unmarshal(r)
+		return t, err
 	case encoding.TypeCodeSASLOutcome:
 		t := new(SASLOutcome)
 		err := t.Unmarshal(r)
@@ -128,9 +136,11 @@ func ParseBody(r *buffer.Buffer) (FrameBody, error) {
 	}
 }
 
-// Write encodes fr into buf.
+// Write encodes fr into buf, appending it to the frames already there.
 // split out from conn.WriteFrame for testing purposes.
 func Write(buf *buffer.Buffer, fr Frame) error {
+	start := buf.Len()
+
 	// write header
 	buf.Append([]byte{
 		0, 0, 0, 0, // size, overwrite later
@@ -146,14 +156,12 @@ func Write(buf *buffer.Buffer, fr Frame) error {
 	}
 
 	// validate size
-	if uint(buf.Len()) > math.MaxUint32 {
+	size := uint(buf.Len() - start)
+	if size > math.MaxUint32 {
 		return errors.New("frame too large")
 	}
 
-	// retrieve raw bytes
-	bufBytes := buf.Bytes()
-
 	// write correct size
-	binary.BigEndian.PutUint32(bufBytes, uint32(len(bufBytes)))
+	binary.BigEndian.PutUint32(buf.Bytes()[start:], uint32(size))
 	return nil
 }
@@ -2,6 +2,7 @@ package shared
 
 import (
 	"encoding/base64"
+	"io"
 	"math/rand"
 	"sync"
 	"time"
@@ -32,3 +33,12 @@ func RandString(n int) string {
 	_, _ = pkgRand.Read(b)
 	return base64.RawURLEncoding.EncodeToString(b)
 }
+
+// RandStringFrom returns a base64 encoded string of n bytes read from r.
+func RandStringFrom(r io.Reader, n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
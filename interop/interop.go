@@ -0,0 +1,285 @@
+// Package interop runs a matrix of conformance scenarios against an AMQP 1.0
+// broker and reports which ones pass, so the compatibility of a broker with
+// this module can be verified before it's used in production.
+//
+// The default scenarios cover the sender and receiver settlement modes,
+// large messages split across many frames, link flow control, and the
+// redelivery of released messages. Each scenario runs on its own connection
+// and exchanges messages through its own node, named after the scenario.
+// The broker must create nodes on demand, or the nodes must be declared
+// beforehand with the names passed to Options.Address.
+//
+//	report, err := interop.Run(ctx, "amqp://localhost:5672", nil)
+//	if err != nil {
+//		// handle error
+//	}
+//	report.WriteTo(os.Stdout)
+//	if !report.Passed() {
+//		// the broker isn't compatible
+//	}
+package interop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/internal/shared"
+)
+
+// Default run options
+const (
+	defaultTimeout = 30 * time.Second
+)
+
+// ErrSkipped is returned by scenarios that can't be run against the broker
+// or with this client. Wrap it with Skip.
+var ErrSkipped = errors.New("interop: scenario skipped")
+
+// Skip returns an error wrapping ErrSkipped with the reason the scenario was skipped.
+func Skip(reason string) error {
+	return fmt.Errorf("%w: %s", ErrSkipped, reason)
+}
+
+// Scenario is a conformance check run against a broker.
+type Scenario struct {
+	// Name identifies the scenario in the report.
+	Name string
+
+	// Run exercises the broker through env. It returns nil if the broker
+	// behaved as expected, or an error wrapping ErrSkipped if the scenario
+	// couldn't be run.
+	Run func(ctx context.Context, env *Env) error
+}
+
+// Env is the environment a Scenario runs in.
+type Env struct {
+	// Conn is the connection to the broker. It's closed once the scenario returns.
+	Conn *amqp.Conn
+
+	// Session is a session begun on Conn for the scenario.
+	Session *amqp.Session
+
+	// Address is the address of the node the scenario exchanges messages through.
+	Address string
+}
+
+// Options contains the optional settings for configuring a run.
+type Options struct {
+	// Address returns the address of the node used by the named scenario.
+	//
+	// Default: "interop.<name>.<random suffix>", so runs don't
+	// receive the messages left over by previous runs. The suffix
+	// is read from ConnOptions.Rand when it's set.
+	Address func(scenario string) string
+
+	// ConnOptions configures the connections to the broker.
+	//
+	// Default: nil.
+	ConnOptions *amqp.ConnOptions
+
+	// Scenarios are the scenarios to run, in order.
+	//
+	// Default: DefaultScenarios().
+	Scenarios []Scenario
+
+	// Timeout bounds the duration of each scenario, including connecting to the broker.
+	//
+	// Default: 30 seconds.
+	Timeout time.Duration
+}
+
+// Status is the outcome of a scenario.
+type Status int
+
+const (
+	// StatusPassed indicates that the broker behaved as expected.
+	StatusPassed Status = iota
+
+	// StatusFailed indicates that the scenario failed or timed out.
+	StatusFailed
+
+	// StatusSkipped indicates that the scenario couldn't be run.
+	StatusSkipped
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusPassed:
+		return "PASS"
+	case StatusFailed:
+		return "FAIL"
+	case StatusSkipped:
+		return "SKIP"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// Result is the outcome of running a Scenario.
+type Result struct {
+	// Scenario is the name of the scenario.
+	Scenario string
+
+	// Status is the outcome of the scenario.
+	Status Status
+
+	// Err is the error returned by the scenario, or the error
+	// that prevented it from running. It's nil if the scenario passed.
+	Err error
+
+	// Duration is how long the scenario took, including connecting to the broker.
+	Duration time.Duration
+}
+
+// Report contains the results of a run against a broker.
+type Report struct {
+	// URL is the URL of the broker.
+	URL string
+
+	// Results are the results of the scenarios, in the order they were run.
+	Results []Result
+}
+
+// Passed returns true if no scenario failed. Skipped scenarios don't count as failures.
+func (r *Report) Passed() bool {
+	for _, res := range r.Results {
+		if res.Status == StatusFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo writes a table of the results to w, one scenario per line,
+// followed by a summary line.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	counts := map[Status]int{}
+	for _, res := range r.Results {
+		counts[res.Status]++
+		detail := ""
+		if res.Err != nil {
+			detail = res.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", res.Status, res.Scenario, res.Duration.Round(time.Millisecond), detail)
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(&sb, "%s: %d passed, %d failed, %d skipped\n", r.URL, counts[StatusPassed], counts[StatusFailed], counts[StatusSkipped])
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// Run runs the scenarios against the broker at url and reports their results.
+// The URL is passed to amqp.Dial for each scenario.
+//
+// It returns an error if ctx is done before all the scenarios have run,
+// along with the results of the scenarios that have.
+//
+// opts: pass nil to accept the default values.
+func Run(ctx context.Context, url string, opts *Options) (*Report, error) {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Address == nil {
+		suffix := shared.RandString(6)
+		if o.ConnOptions != nil && o.ConnOptions.Rand != nil {
+			var err error
+			suffix, err = shared.RandStringFrom(o.ConnOptions.Rand, 6)
+			if err != nil {
+				return nil, fmt.Errorf("interop: generating address: %w", err)
+			}
+		}
+		o.Address = func(scenario string) string {
+			return "interop." + scenario + "." + suffix
+		}
+	}
+	if o.Scenarios == nil {
+		o.Scenarios = DefaultScenarios()
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+
+	report := &Report{URL: url}
+	for _, s := range o.Scenarios {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		report.Results = append(report.Results, runScenario(ctx, url, s, &o))
+	}
+	return report, nil
+}
+
+// runScenario runs s on a new connection to the broker at url.
+func runScenario(ctx context.Context, url string, s Scenario, opts *Options) Result {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := func() error {
+		conn, err := dial(ctx, url, opts.ConnOptions)
+		if err != nil {
+			return fmt.Errorf("connecting: %w", err)
+		}
+		defer conn.Close()
+
+		session, err := conn.NewSession(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("beginning session: %w", err)
+		}
+		return s.Run(ctx, &Env{
+			Conn:    conn,
+			Session: session,
+			Address: opts.Address(s.Name),
+		})
+	}()
+
+	res := Result{
+		Scenario: s.Name,
+		Err:      err,
+		Duration: time.Since(start),
+	}
+	switch {
+	case err == nil:
+		res.Status = StatusPassed
+	case errors.Is(err, ErrSkipped):
+		res.Status = StatusSkipped
+	default:
+		res.Status = StatusFailed
+	}
+	return res
+}
+
+// dial connects to url, giving up once ctx is done.
+func dial(ctx context.Context, url string, opts *amqp.ConnOptions) (*amqp.Conn, error) {
+	type dialResult struct {
+		conn *amqp.Conn
+		err  error
+	}
+	done := make(chan dialResult, 1)
+	go func() {
+		conn, err := amqp.Dial(url, opts)
+		done <- dialResult{conn: conn, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.conn, res.err
+	case <-ctx.Done():
+		go func() {
+			if res := <-done; res.conn != nil {
+				_ = res.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,125 @@
+package interop
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBroker(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := amqp.NewListener(ln, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- broker.New(&broker.Options{AutoCreateQueues: true}).Serve(ctx, l) }()
+	t.Cleanup(func() {
+		cancel()
+		<-served
+		_ = l.Close()
+	})
+	return "amqp://" + l.Addr().String()
+}
+
+func TestRunDefaultScenarios(t *testing.T) {
+	url := newTestBroker(t)
+
+	report, err := Run(context.Background(), url, &Options{Timeout: 10 * time.Second})
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	_, err = report.WriteTo(&sb)
+	require.NoError(t, err)
+	require.Contains(t, sb.String(), "5 passed, 1 failed, 1 skipped", sb.String())
+
+	// the broker doesn't support ReceiverSettleModeSecond
+	require.False(t, report.Passed())
+	require.Len(t, report.Results, len(DefaultScenarios()))
+	for _, res := range report.Results {
+		switch res.Scenario {
+		case "unsettled-second":
+			require.Equal(t, StatusFailed, res.Status)
+			var amqpErr *amqp.Error
+			require.ErrorAs(t, res.Err, &amqpErr)
+			require.Equal(t, amqp.ErrCondNotAllowed, amqpErr.Condition)
+		case "transactions":
+			require.Equal(t, StatusSkipped, res.Status)
+			require.ErrorIs(t, res.Err, ErrSkipped)
+		default:
+			require.Equal(t, StatusPassed, res.Status, res.Scenario)
+		}
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	url := newTestBroker(t)
+
+	var addresses []string
+	report, err := Run(context.Background(), url, &Options{
+		Address: func(scenario string) string {
+			addresses = append(addresses, scenario)
+			return scenario
+		},
+		Scenarios: []Scenario{
+			{Name: "fails", Run: func(ctx context.Context, env *Env) error {
+				return errors.New("boom")
+			}},
+			{Name: "times-out", Run: func(ctx context.Context, env *Env) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}},
+			{Name: "passes", Run: func(ctx context.Context, env *Env) error {
+				return nil
+			}},
+		},
+		Timeout: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.False(t, report.Passed())
+	require.Equal(t, []string{"fails", "times-out", "passes"}, addresses)
+
+	require.Equal(t, StatusFailed, report.Results[0].Status)
+	require.EqualError(t, report.Results[0].Err, "boom")
+	require.Equal(t, StatusFailed, report.Results[1].Status)
+	require.ErrorIs(t, report.Results[1].Err, context.DeadlineExceeded)
+	require.Equal(t, StatusPassed, report.Results[2].Status)
+}
+
+func TestRunUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	url := "amqp://" + ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	report, err := Run(context.Background(), url, &Options{
+		Scenarios: DefaultScenarios()[:1],
+	})
+	require.NoError(t, err)
+	require.False(t, report.Passed())
+	require.ErrorContains(t, report.Results[0].Err, "connecting")
+}
+
+func TestRunRandError(t *testing.T) {
+	_, err := Run(context.Background(), "amqp://127.0.0.1:0", &Options{
+		ConnOptions: &amqp.ConnOptions{Rand: iotest.ErrReader(errors.New("no entropy"))},
+	})
+	require.ErrorContains(t, err, "generating address: no entropy")
+}
+
+func TestRunContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := Run(ctx, "amqp://127.0.0.1:1", nil)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Empty(t, report.Results)
+}
@@ -0,0 +1,248 @@
+package interop
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+const (
+	// messageCount is the number of messages exchanged by the settlement scenarios.
+	messageCount = 10
+
+	// largeMessageSize is the size of the payload sent by the large message scenario.
+	// It's larger than the default max frame size of most brokers.
+	largeMessageSize = 1024 * 1024
+
+	// noCreditWait is how long the flow control scenario waits to verify
+	// that no message is delivered to a receiver without credit.
+	noCreditWait = 250 * time.Millisecond
+)
+
+// DefaultScenarios returns the scenarios run by default:
+//
+//   - settled: messages sent pre-settled are received
+//   - unsettled-first: messages sent unsettled are accepted by the broker and
+//     received in ReceiverSettleModeFirst
+//   - unsettled-second: messages are received in ReceiverSettleModeSecond
+//   - large-message: a 1 MiB message is sent and received intact
+//   - flow-control: messages are only delivered to a receiver as it issues credit
+//   - release-redelivery: a released message is delivered again
+//   - transactions: skipped, as this module doesn't implement transaction controllers
+func DefaultScenarios() []Scenario {
+	return []Scenario{
+		{Name: "settled", Run: settled},
+		{Name: "unsettled-first", Run: unsettledFirst},
+		{Name: "unsettled-second", Run: unsettledSecond},
+		{Name: "large-message", Run: largeMessage},
+		{Name: "flow-control", Run: flowControl},
+		{Name: "release-redelivery", Run: releaseRedelivery},
+		{Name: "transactions", Run: transactions},
+	}
+}
+
+func settled(ctx context.Context, env *Env) error {
+	return roundTrip(ctx, env, &amqp.SenderOptions{
+		SettlementMode: amqp.SenderSettleModeSettled.Ptr(),
+	}, nil)
+}
+
+func unsettledFirst(ctx context.Context, env *Env) error {
+	return roundTrip(ctx, env, &amqp.SenderOptions{
+		SettlementMode: amqp.SenderSettleModeUnsettled.Ptr(),
+	}, &amqp.ReceiverOptions{
+		SettlementMode: amqp.ReceiverSettleModeFirst.Ptr(),
+	})
+}
+
+func unsettledSecond(ctx context.Context, env *Env) error {
+	return roundTrip(ctx, env, &amqp.SenderOptions{
+		SettlementMode: amqp.SenderSettleModeUnsettled.Ptr(),
+	}, &amqp.ReceiverOptions{
+		SettlementMode: amqp.ReceiverSettleModeSecond.Ptr(),
+	})
+}
+
+// roundTrip sends messageCount messages and verifies
+// that they're received in order, accepting them.
+func roundTrip(ctx context.Context, env *Env, sndOpts *amqp.SenderOptions, rcvOpts *amqp.ReceiverOptions) error {
+	snd, err := env.Session.NewSender(ctx, env.Address, sndOpts)
+	if err != nil {
+		return fmt.Errorf("attaching sender: %w", err)
+	}
+	defer snd.Close(ctx)
+
+	for i := 0; i < messageCount; i++ {
+		if err := snd.Send(ctx, amqp.NewMessage(body(i))); err != nil {
+			return fmt.Errorf("sending message %d: %w", i, err)
+		}
+	}
+
+	rcv, err := env.Session.NewReceiver(ctx, env.Address, rcvOpts)
+	if err != nil {
+		return fmt.Errorf("attaching receiver: %w", err)
+	}
+	defer rcv.Close(ctx)
+
+	for i := 0; i < messageCount; i++ {
+		if err := receive(ctx, rcv, body(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func largeMessage(ctx context.Context, env *Env) error {
+	data := make([]byte, largeMessageSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	snd, err := env.Session.NewSender(ctx, env.Address, nil)
+	if err != nil {
+		return fmt.Errorf("attaching sender: %w", err)
+	}
+	defer snd.Close(ctx)
+
+	if err := snd.Send(ctx, amqp.NewMessage(data)); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+
+	rcv, err := env.Session.NewReceiver(ctx, env.Address, nil)
+	if err != nil {
+		return fmt.Errorf("attaching receiver: %w", err)
+	}
+	defer rcv.Close(ctx)
+
+	return receive(ctx, rcv, data)
+}
+
+func flowControl(ctx context.Context, env *Env) error {
+	const count = 5
+
+	snd, err := env.Session.NewSender(ctx, env.Address, nil)
+	if err != nil {
+		return fmt.Errorf("attaching sender: %w", err)
+	}
+	defer snd.Close(ctx)
+
+	for i := 0; i < count; i++ {
+		if err := snd.Send(ctx, amqp.NewMessage(body(i))); err != nil {
+			return fmt.Errorf("sending message %d: %w", i, err)
+		}
+	}
+
+	rcv, err := env.Session.NewReceiver(ctx, env.Address, &amqp.ReceiverOptions{
+		Credit:        count,
+		ManualCredits: true,
+	})
+	if err != nil {
+		return fmt.Errorf("attaching receiver: %w", err)
+	}
+	defer rcv.Close(ctx)
+
+	if err := expectNoMessage(ctx, rcv); err != nil {
+		return fmt.Errorf("before issuing credit: %w", err)
+	}
+
+	// deliveries must stop once the credit is used
+	if err := rcv.IssueCredit(2); err != nil {
+		return fmt.Errorf("issuing credit: %w", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := receive(ctx, rcv, body(i)); err != nil {
+			return err
+		}
+	}
+	if err := expectNoMessage(ctx, rcv); err != nil {
+		return fmt.Errorf("after using credit: %w", err)
+	}
+
+	if err := rcv.IssueCredit(count - 2); err != nil {
+		return fmt.Errorf("issuing credit: %w", err)
+	}
+	for i := 2; i < count; i++ {
+		if err := receive(ctx, rcv, body(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func releaseRedelivery(ctx context.Context, env *Env) error {
+	snd, err := env.Session.NewSender(ctx, env.Address, nil)
+	if err != nil {
+		return fmt.Errorf("attaching sender: %w", err)
+	}
+	defer snd.Close(ctx)
+
+	if err := snd.Send(ctx, amqp.NewMessage(body(0))); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+
+	rcv, err := env.Session.NewReceiver(ctx, env.Address, &amqp.ReceiverOptions{Credit: 1})
+	if err != nil {
+		return fmt.Errorf("attaching receiver: %w", err)
+	}
+	defer rcv.Close(ctx)
+
+	msg, err := rcv.Receive(ctx)
+	if err != nil {
+		return fmt.Errorf("receiving message: %w", err)
+	}
+	if err := rcv.ReleaseMessage(ctx, msg); err != nil {
+		return fmt.Errorf("releasing message: %w", err)
+	}
+	return receive(ctx, rcv, body(0))
+}
+
+func transactions(ctx context.Context, env *Env) error {
+	return Skip("this module doesn't implement transaction controllers")
+}
+
+// receive receives a message from rcv, verifies that its data is want, and accepts it.
+func receive(ctx context.Context, rcv *amqp.Receiver, want []byte) error {
+	msg, err := rcv.Receive(ctx)
+	if err != nil {
+		return fmt.Errorf("receiving message: %w", err)
+	}
+	if got := msg.GetData(); !bytes.Equal(got, want) {
+		return fmt.Errorf("received %s, want %s", describe(got), describe(want))
+	}
+	if err := rcv.AcceptMessage(ctx, msg); err != nil {
+		return fmt.Errorf("accepting message: %w", err)
+	}
+	return nil
+}
+
+// expectNoMessage returns an error if rcv receives a message within noCreditWait.
+func expectNoMessage(ctx context.Context, rcv *amqp.Receiver) error {
+	waitCtx, cancel := context.WithTimeout(ctx, noCreditWait)
+	defer cancel()
+	msg, err := rcv.Receive(waitCtx)
+	switch {
+	case err == nil:
+		return fmt.Errorf("received %s without credit", describe(msg.GetData()))
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+		return nil
+	default:
+		return fmt.Errorf("receiving message: %w", err)
+	}
+}
+
+// body returns the data of the i-th message sent by a scenario.
+func body(i int) []byte {
+	return []byte(fmt.Sprintf("message %d", i))
+}
+
+// describe returns a short description of data for error messages.
+func describe(data []byte) string {
+	if len(data) > 32 {
+		return fmt.Sprintf("%d bytes", len(data))
+	}
+	return fmt.Sprintf("%q", data)
+}
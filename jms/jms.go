@@ -0,0 +1,230 @@
+// Package jms implements helpers for the message annotations of the AMQP JMS
+// mapping, used by JMS clients such as Qpid JMS, so messages exchanged with
+// JMS producers and consumers are classified as the intended JMS message
+// and destination types.
+//
+//	msg := jms.NewTextMessage("hello")
+//	jms.SetDestination(msg, "orders", jms.DestinationQueue)
+//	jms.SetReplyTo(msg, replyAddress, jms.DestinationTemporaryQueue)
+package jms
+
+import (
+	"fmt"
+
+	"github.com/Azure/go-amqp"
+)
+
+// Message annotations of the JMS mapping.
+const (
+	// AnnotationMessageType is the JMS message type of a message.
+	AnnotationMessageType = "x-opt-jms-msg-type"
+
+	// AnnotationDestination is the type of the destination of a message,
+	// in its to property.
+	AnnotationDestination = "x-opt-jms-dest"
+
+	// AnnotationReplyTo is the type of the destination replies to a message
+	// are sent to, in its reply-to property.
+	AnnotationReplyTo = "x-opt-jms-reply-to"
+)
+
+// Content types of the data payloads of bytes and object messages.
+const (
+	contentTypeBytes  = "application/octet-stream"
+	contentTypeObject = "application/x-java-serialized-object"
+)
+
+// MessageType is the JMS message type of a message.
+type MessageType int8
+
+const (
+	// MessageTypeMessage is a message without a body.
+	MessageTypeMessage MessageType = iota
+
+	// MessageTypeObject is a message with a serialized Java object body.
+	MessageTypeObject
+
+	// MessageTypeMap is a message with a map body.
+	MessageTypeMap
+
+	// MessageTypeBytes is a message with a bytes body.
+	MessageTypeBytes
+
+	// MessageTypeStream is a message with a list body.
+	MessageTypeStream
+
+	// MessageTypeText is a message with a string body.
+	MessageTypeText
+)
+
+// String implements the fmt.Stringer interface for MessageType.
+func (t MessageType) String() string {
+	switch t {
+	case MessageTypeMessage:
+		return "Message"
+	case MessageTypeObject:
+		return "ObjectMessage"
+	case MessageTypeMap:
+		return "MapMessage"
+	case MessageTypeBytes:
+		return "BytesMessage"
+	case MessageTypeStream:
+		return "StreamMessage"
+	case MessageTypeText:
+		return "TextMessage"
+	default:
+		return fmt.Sprintf("unknown message type %d", int8(t))
+	}
+}
+
+// DestinationType is the type of a JMS destination.
+type DestinationType int8
+
+const (
+	// DestinationQueue is a queue.
+	DestinationQueue DestinationType = iota
+
+	// DestinationTopic is a topic.
+	DestinationTopic
+
+	// DestinationTemporaryQueue is a temporary queue.
+	DestinationTemporaryQueue
+
+	// DestinationTemporaryTopic is a temporary topic.
+	DestinationTemporaryTopic
+)
+
+// String implements the fmt.Stringer interface for DestinationType.
+func (t DestinationType) String() string {
+	switch t {
+	case DestinationQueue:
+		return "Queue"
+	case DestinationTopic:
+		return "Topic"
+	case DestinationTemporaryQueue:
+		return "TemporaryQueue"
+	case DestinationTemporaryTopic:
+		return "TemporaryTopic"
+	default:
+		return fmt.Sprintf("unknown destination type %d", int8(t))
+	}
+}
+
+// NewTextMessage returns a TextMessage with the body text.
+func NewTextMessage(text string) *amqp.Message {
+	msg := &amqp.Message{Value: text}
+	SetMessageType(msg, MessageTypeText)
+	return msg
+}
+
+// NewBytesMessage returns a BytesMessage with the body data.
+func NewBytesMessage(data []byte) *amqp.Message {
+	contentType := amqp.Symbol(contentTypeBytes)
+	msg := &amqp.Message{
+		Data:       [][]byte{data},
+		Properties: &amqp.MessageProperties{ContentType: &contentType},
+	}
+	SetMessageType(msg, MessageTypeBytes)
+	return msg
+}
+
+// NewMapMessage returns a MapMessage with the body m.
+func NewMapMessage(m map[string]any) *amqp.Message {
+	msg := &amqp.Message{Value: m}
+	SetMessageType(msg, MessageTypeMap)
+	return msg
+}
+
+// NewStreamMessage returns a StreamMessage with the body values.
+func NewStreamMessage(values []any) *amqp.Message {
+	msg := &amqp.Message{Sequence: [][]any{values}}
+	SetMessageType(msg, MessageTypeStream)
+	return msg
+}
+
+// SetMessageType sets the JMS message type of msg.
+func SetMessageType(msg *amqp.Message, t MessageType) {
+	setAnnotation(msg, AnnotationMessageType, int8(t))
+}
+
+// GetMessageType returns the JMS message type of msg, as a JMS consumer
+// classifies it: from its annotation, or else from its body. The boolean
+// result is false if the type can't be determined.
+func GetMessageType(msg *amqp.Message) (MessageType, bool) {
+	if t, ok := msg.Annotations[AnnotationMessageType].(int8); ok {
+		return MessageType(t), true
+	}
+	switch {
+	case len(msg.Data) > 0:
+		if msg.Properties != nil && msg.Properties.ContentType != nil && *msg.Properties.ContentType == contentTypeObject {
+			return MessageTypeObject, true
+		}
+		return MessageTypeBytes, true
+	case len(msg.Sequence) > 0:
+		return MessageTypeStream, true
+	}
+	switch msg.Value.(type) {
+	case nil:
+		return MessageTypeMessage, true
+	case string:
+		return MessageTypeText, true
+	case map[string]any, map[any]any:
+		return MessageTypeMap, true
+	case []any:
+		return MessageTypeStream, true
+	case []byte:
+		return MessageTypeBytes, true
+	}
+	return 0, false
+}
+
+// SetDestination sets the to property of msg to address, and its annotation
+// to the type of the destination.
+func SetDestination(msg *amqp.Message, address string, t DestinationType) {
+	properties(msg).To = &address
+	setAnnotation(msg, AnnotationDestination, int8(t))
+}
+
+// GetDestination returns the address and type of the destination of msg.
+// The boolean result is false if msg has no to property or no type.
+func GetDestination(msg *amqp.Message) (string, DestinationType, bool) {
+	if msg.Properties == nil || msg.Properties.To == nil {
+		return "", 0, false
+	}
+	t, ok := msg.Annotations[AnnotationDestination].(int8)
+	return *msg.Properties.To, DestinationType(t), ok
+}
+
+// SetReplyTo sets the reply-to property of msg to address, and its
+// annotation to the type of the destination.
+func SetReplyTo(msg *amqp.Message, address string, t DestinationType) {
+	properties(msg).ReplyTo = &address
+	setAnnotation(msg, AnnotationReplyTo, int8(t))
+}
+
+// GetReplyTo returns the address and type of the destination replies to
+// msg are sent to. The boolean result is false if msg has no reply-to
+// property or no type.
+func GetReplyTo(msg *amqp.Message) (string, DestinationType, bool) {
+	if msg.Properties == nil || msg.Properties.ReplyTo == nil {
+		return "", 0, false
+	}
+	t, ok := msg.Annotations[AnnotationReplyTo].(int8)
+	return *msg.Properties.ReplyTo, DestinationType(t), ok
+}
+
+// properties returns the properties of msg, setting them if needed.
+func properties(msg *amqp.Message) *amqp.MessageProperties {
+	if msg.Properties == nil {
+		msg.Properties = &amqp.MessageProperties{}
+	}
+	return msg.Properties
+}
+
+// setAnnotation sets the message annotation key of msg to v.
+func setAnnotation(msg *amqp.Message, key string, v int8) {
+	if msg.Annotations == nil {
+		msg.Annotations = amqp.Annotations{}
+	}
+	msg.Annotations[key] = v
+}
@@ -0,0 +1,78 @@
+package jms
+
+import (
+	"testing"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTrip returns msg as received by a peer.
+func roundTrip(t *testing.T, msg *amqp.Message) *amqp.Message {
+	b, err := msg.MarshalBinary()
+	require.NoError(t, err)
+	var received amqp.Message
+	require.NoError(t, received.UnmarshalBinary(b))
+	return &received
+}
+
+func TestMessageTypes(t *testing.T) {
+	for _, tt := range []struct {
+		msg  *amqp.Message
+		want MessageType
+	}{
+		{NewTextMessage("hello"), MessageTypeText},
+		{NewBytesMessage([]byte{1, 2}), MessageTypeBytes},
+		{NewMapMessage(map[string]any{"k": "v"}), MessageTypeMap},
+		{NewStreamMessage([]any{int32(1), "two"}), MessageTypeStream},
+	} {
+		msg := roundTrip(t, tt.msg)
+		got, ok := GetMessageType(msg)
+		require.True(t, ok)
+		require.Equal(t, tt.want, got, tt.want.String())
+
+		// the type is inferred from the body without the annotation
+		delete(msg.Annotations, AnnotationMessageType)
+		got, ok = GetMessageType(msg)
+		require.True(t, ok)
+		require.Equal(t, tt.want, got, tt.want.String())
+	}
+
+	objectType := amqp.Symbol(contentTypeObject)
+	got, ok := GetMessageType(&amqp.Message{
+		Data:       [][]byte{{0xac, 0xed}},
+		Properties: &amqp.MessageProperties{ContentType: &objectType},
+	})
+	require.True(t, ok)
+	require.Equal(t, MessageTypeObject, got)
+
+	got, ok = GetMessageType(&amqp.Message{})
+	require.True(t, ok)
+	require.Equal(t, MessageTypeMessage, got)
+
+	_, ok = GetMessageType(&amqp.Message{Value: int64(1)})
+	require.False(t, ok)
+}
+
+func TestDestinations(t *testing.T) {
+	msg := NewTextMessage("hello")
+	_, _, ok := GetDestination(msg)
+	require.False(t, ok)
+	_, _, ok = GetReplyTo(msg)
+	require.False(t, ok)
+
+	SetDestination(msg, "prices", DestinationTopic)
+	SetReplyTo(msg, "temp-1", DestinationTemporaryQueue)
+	msg = roundTrip(t, msg)
+	require.Equal(t, int8(1), msg.Annotations[AnnotationDestination])
+
+	address, typ, ok := GetDestination(msg)
+	require.True(t, ok)
+	require.Equal(t, "prices", address)
+	require.Equal(t, DestinationTopic, typ)
+
+	address, typ, ok = GetReplyTo(msg)
+	require.True(t, ok)
+	require.Equal(t, "temp-1", address)
+	require.Equal(t, DestinationTemporaryQueue, typ)
+}
@@ -0,0 +1,254 @@
+package amqp
+
+import (
+	"fmt"
+	"sync"
+)
+
+// LimitPolicy determines what's done when a delivery would exceed
+// a ResourceLimits limit.
+type LimitPolicy uint8
+
+const (
+	// LimitBlock waits for resources to be released. Send blocks, and
+	// receivers stop processing incoming transfers until the application
+	// receives or settles messages, which also holds up the other links
+	// of the receiver's session.
+	LimitBlock LimitPolicy = iota
+
+	// LimitReject refuses the delivery. Send returns a *LimitError, and
+	// receivers settle incoming deliveries with the rejected outcome and
+	// the amqp:resource-limit-exceeded condition.
+	LimitReject
+
+	// LimitDetach detaches the link with the amqp:resource-limit-exceeded
+	// condition. The link's methods return a *DetachError wrapping a *LimitError.
+	LimitDetach
+)
+
+// ResourceLimits caps the deliveries and bytes held by the links of a
+// connection or session, so that a misbehaving link, or an application
+// that doesn't keep up, can't exhaust the process's memory.
+type ResourceLimits struct {
+	// MaxUnsettled is the maximum number of unsettled deliveries: messages
+	// being sent that the peer hasn't settled yet, and messages received
+	// that the application hasn't settled yet.
+	//
+	// Default: 0 (no limit).
+	MaxUnsettled int
+
+	// MaxBufferedBytes is the maximum total size of the received messages
+	// waiting in the receivers' prefetch queues to be returned by Receive.
+	// A message is always accepted when no other message is buffered,
+	// even if it's larger than the limit.
+	//
+	// Default: 0 (no limit).
+	MaxBufferedBytes int64
+
+	// Policy determines what's done when a delivery would exceed a limit.
+	//
+	// Default: LimitBlock.
+	Policy LimitPolicy
+}
+
+func (l *ResourceLimits) validate() error {
+	if l.MaxUnsettled < 0 {
+		return fmt.Errorf("invalid MaxUnsettled value %d", l.MaxUnsettled)
+	}
+	if l.MaxBufferedBytes < 0 {
+		return fmt.Errorf("invalid MaxBufferedBytes value %d", l.MaxBufferedBytes)
+	}
+	if l.Policy > LimitDetach {
+		return fmt.Errorf("invalid Policy %d", l.Policy)
+	}
+	return nil
+}
+
+// LimitError is returned when a delivery would exceed a ResourceLimits limit.
+//
+// It wraps an *Error with the amqp:resource-limit-exceeded condition, so
+// errors.Is can be used to check for ErrCondResourceLimitExceeded.
+type LimitError struct {
+	// Resource names the limit reached, either "unsettled" or "buffered-bytes".
+	Resource string
+
+	// Limit is the value of the limit reached.
+	Limit int64
+
+	policy LimitPolicy
+}
+
+// Error implements the error interface for LimitError.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: reached the limit of %d %s", ErrCondResourceLimitExceeded, e.Limit, e.Resource)
+}
+
+// Unwrap returns the *Error sent to the peer, if any.
+func (e *LimitError) Unwrap() error {
+	return e.remoteErr()
+}
+
+func (e *LimitError) remoteErr() *Error {
+	return &Error{
+		Condition:   ErrCondResourceLimitExceeded,
+		Description: fmt.Sprintf("reached the limit of %d %s", e.Limit, e.Resource),
+	}
+}
+
+// resourceBudget accounts for the resources held by the links of a connection
+// or session. A session's budget has the connection's as parent, and resources
+// are reserved from both.
+type resourceBudget struct {
+	limits ResourceLimits
+	parent *resourceBudget
+
+	mu        sync.Mutex
+	unsettled int
+	bytes     int64
+	released  chan struct{} // closed when resources are released, nil if nobody waits
+}
+
+// newResourceBudget creates a budget enforcing limits within parent,
+// returning parent if limits is nil.
+func newResourceBudget(limits *ResourceLimits, parent *resourceBudget) *resourceBudget {
+	if limits == nil {
+		return parent
+	}
+	return &resourceBudget{limits: *limits, parent: parent}
+}
+
+// reserve reserves the resources from b and its parents. When a limit is
+// reached with the LimitBlock policy, wait is called with a channel closed
+// once resources are released, and its error is returned if non-nil.
+// Otherwise, a *LimitError is returned.
+func (b *resourceBudget) reserve(unsettled int, bytes int64, wait func(released <-chan struct{}) error) error {
+	for {
+		released, limitErr := b.tryReserve(unsettled, bytes)
+		if limitErr == nil {
+			return nil
+		}
+		if limitErr.policy != LimitBlock {
+			return limitErr
+		}
+		if err := wait(released); err != nil {
+			return err
+		}
+	}
+}
+
+// tryReserve reserves the resources from b and its parents. If a limit is
+// reached, it returns the limit's error and, for the LimitBlock policy, a
+// channel closed once resources of the budget that reached it are released.
+func (b *resourceBudget) tryReserve(unsettled int, bytes int64) (<-chan struct{}, *LimitError) {
+	b.mu.Lock()
+	var limitErr *LimitError
+	switch {
+	case unsettled > 0 && b.limits.MaxUnsettled > 0 && b.unsettled+unsettled > b.limits.MaxUnsettled:
+		limitErr = &LimitError{Resource: "unsettled", Limit: int64(b.limits.MaxUnsettled), policy: b.limits.Policy}
+	case bytes > 0 && b.limits.MaxBufferedBytes > 0 && b.bytes > 0 && b.bytes+bytes > b.limits.MaxBufferedBytes:
+		limitErr = &LimitError{Resource: "buffered-bytes", Limit: b.limits.MaxBufferedBytes, policy: b.limits.Policy}
+	}
+	if limitErr != nil {
+		var released chan struct{}
+		if limitErr.policy == LimitBlock {
+			if b.released == nil {
+				b.released = make(chan struct{})
+			}
+			released = b.released
+		}
+		b.mu.Unlock()
+		return released, limitErr
+	}
+	b.unsettled += unsettled
+	b.bytes += bytes
+	b.mu.Unlock()
+
+	if b.parent != nil {
+		if released, err := b.parent.tryReserve(unsettled, bytes); err != nil {
+			// the parent reserved nothing, only roll back b
+			b.releaseLocal(unsettled, bytes)
+			return released, err
+		}
+	}
+	return nil, nil
+}
+
+// release returns resources reserved from b and its parents.
+func (b *resourceBudget) release(unsettled int, bytes int64) {
+	if unsettled == 0 && bytes == 0 {
+		return
+	}
+	b.releaseLocal(unsettled, bytes)
+	if b.parent != nil {
+		b.parent.release(unsettled, bytes)
+	}
+}
+
+// releaseLocal returns resources reserved from b, but not its parents.
+func (b *resourceBudget) releaseLocal(unsettled int, bytes int64) {
+	b.mu.Lock()
+	b.unsettled -= unsettled
+	b.bytes -= bytes
+	if b.released != nil {
+		close(b.released)
+		b.released = nil
+	}
+	b.mu.Unlock()
+}
+
+// receiverCharges tracks the resources a receiver holds in its budget,
+// returning those still held once the link has detached.
+type receiverCharges struct {
+	budget *resourceBudget
+
+	mu        sync.Mutex
+	unsettled map[uint32]struct{} // delivery IDs of the unsettled messages charged
+	bytes     int64
+	closed    bool
+}
+
+// add records resources reserved for the message with the delivery ID.
+func (c *receiverCharges) add(deliveryID uint32, unsettled bool, bytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if unsettled {
+		if c.unsettled == nil {
+			c.unsettled = map[uint32]struct{}{}
+		}
+		c.unsettled[deliveryID] = struct{}{}
+	}
+	c.bytes += bytes
+}
+
+// settled releases the unsettled delivery charged for the delivery ID, if any.
+func (c *receiverCharges) settled(deliveryID uint32) {
+	c.mu.Lock()
+	_, ok := c.unsettled[deliveryID]
+	delete(c.unsettled, deliveryID)
+	c.mu.Unlock()
+	if ok {
+		c.budget.release(1, 0)
+	}
+}
+
+// received releases the bytes of a message taken from the prefetch queue.
+func (c *receiverCharges) received(bytes int64) {
+	c.mu.Lock()
+	if c.closed || bytes == 0 {
+		c.mu.Unlock()
+		return
+	}
+	c.bytes -= bytes
+	c.mu.Unlock()
+	c.budget.release(0, bytes)
+}
+
+// close releases all the resources still held.
+func (c *receiverCharges) close() {
+	c.mu.Lock()
+	unsettled, bytes := len(c.unsettled), c.bytes
+	c.unsettled, c.bytes = nil, 0
+	c.closed = true
+	c.mu.Unlock()
+	c.budget.release(unsettled, bytes)
+}
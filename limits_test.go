@@ -0,0 +1,217 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp/internal/encoding"
+	"github.com/Azure/go-amqp/internal/frames"
+	"github.com/Azure/go-amqp/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceBudget(t *testing.T) {
+	conn := newResourceBudget(&ResourceLimits{MaxUnsettled: 3, MaxBufferedBytes: 100, Policy: LimitReject}, nil)
+	session := newResourceBudget(&ResourceLimits{MaxUnsettled: 2}, conn)
+	require.Same(t, conn, newResourceBudget(nil, conn))
+
+	noWait := func(<-chan struct{}) error {
+		t.Fatal("unexpected wait")
+		return nil
+	}
+
+	// reserved from both budgets
+	require.NoError(t, session.reserve(1, 60, noWait))
+	require.NoError(t, conn.reserve(1, 0, noWait))
+	require.Equal(t, 2, conn.unsettled)
+	require.EqualValues(t, 60, conn.bytes)
+
+	// the connection's byte limit is reached and nothing stays reserved from the session
+	var limitErr *LimitError
+	require.ErrorAs(t, session.reserve(0, 50, noWait), &limitErr)
+	require.Equal(t, "buffered-bytes", limitErr.Resource)
+	require.EqualValues(t, 100, limitErr.Limit)
+	require.ErrorIs(t, limitErr, ErrCondResourceLimitExceeded)
+	require.EqualValues(t, 60, session.bytes)
+
+	// the session's limit blocks until a delivery is released
+	require.NoError(t, session.reserve(1, 0, noWait))
+	waited := make(chan struct{})
+	go func() {
+		<-waited
+		session.release(1, 0)
+	}()
+	require.NoError(t, session.reserve(1, 0, func(released <-chan struct{}) error {
+		close(waited)
+		<-released
+		return nil
+	}))
+
+	// the wait's error is returned
+	stop := errors.New("stop")
+	require.ErrorIs(t, session.reserve(1, 0, func(<-chan struct{}) error { return stop }), stop)
+
+	// a message is buffered regardless of its size when nothing else is
+	session.release(2, 60)
+	conn.release(1, 0)
+	require.NoError(t, session.reserve(0, 1000, noWait))
+	require.Zero(t, session.unsettled)
+	require.Zero(t, conn.unsettled)
+}
+
+func TestResourceBudgetNestedRefusal(t *testing.T) {
+	conn := newResourceBudget(&ResourceLimits{MaxUnsettled: 1, Policy: LimitReject}, nil)
+	session := newResourceBudget(&ResourceLimits{MaxUnsettled: 10}, conn)
+	noWait := func(<-chan struct{}) error {
+		t.Fatal("unexpected wait")
+		return nil
+	}
+
+	require.NoError(t, session.reserve(1, 0, noWait))
+
+	// refused by the connection, which keeps its reservation
+	for i := 0; i < 2; i++ {
+		var limitErr *LimitError
+		require.ErrorAs(t, session.reserve(1, 0, noWait), &limitErr)
+		require.Equal(t, 1, conn.unsettled)
+		require.Equal(t, 1, session.unsettled)
+	}
+
+	// blocked waiters on the connection aren't woken by a refusal
+	conn.limits.Policy = LimitBlock
+	released, limitErr := conn.tryReserve(1, 0)
+	require.NotNil(t, limitErr)
+	_, limitErr = session.tryReserve(1, 0)
+	require.NotNil(t, limitErr)
+	select {
+	case <-released:
+		t.Fatal("unexpected release")
+	default:
+	}
+	require.Equal(t, 1, conn.unsettled)
+
+	session.release(1, 0)
+	<-released
+	require.Zero(t, conn.unsettled)
+	require.Zero(t, session.unsettled)
+}
+
+func TestReceiverResourceLimitReject(t *testing.T) {
+	const linkHandle = 0
+	dispositions := make(chan *frames.PerformDisposition, 10)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch ff := req.(type) {
+		case *frames.PerformFlow:
+			return nil, nil
+		case *frames.PerformDisposition:
+			dispositions <- ff
+			return nil, nil
+		}
+		return receiverFrameHandlerNoUnhandled(ReceiverSettleModeFirst)(req)
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, &ConnOptions{ResourceLimits: &ResourceLimits{MaxUnsettled: 1, Policy: LimitReject}})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{Credit: 10})
+	cancel()
+	require.NoError(t, err)
+
+	send := func(deliveryID uint32) {
+		b, err := mocks.PerformTransfer(0, linkHandle, deliveryID, []byte("message"))
+		require.NoError(t, err)
+		conn.SendFrame(b)
+	}
+
+	// the second unsettled message is rejected
+	send(1)
+	send(2)
+	dis := <-dispositions
+	require.EqualValues(t, 2, dis.First)
+	rejected, ok := dis.State.(*encoding.StateRejected)
+	require.True(t, ok)
+	require.Equal(t, ErrCondResourceLimitExceeded, rejected.Error.Condition)
+
+	// settling the first makes room for the next
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	msg, err := r.Receive(ctx)
+	cancel()
+	require.NoError(t, err)
+	require.NoError(t, r.AcceptMessage(context.Background(), msg))
+	dis = <-dispositions
+	require.EqualValues(t, 1, dis.First)
+	send(3)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	msg, err = r.Receive(ctx)
+	cancel()
+	require.NoError(t, err)
+	require.EqualValues(t, 3, msg.deliveryID)
+	require.NoError(t, client.Close())
+}
+
+func TestSenderResourceLimit(t *testing.T) {
+	for name, policy := range map[string]LimitPolicy{
+		"block":  LimitBlock,
+		"reject": LimitReject,
+		"detach": LimitDetach,
+	} {
+		t.Run(name, func(t *testing.T) {
+			responder := func(req frames.FrameBody) ([]byte, error) {
+				switch req.(type) {
+				case *frames.PerformTransfer:
+					// never settled
+					return nil, nil
+				}
+				return senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)(req)
+			}
+			netConn := mocks.NewNetConn(responder)
+			client, err := NewConn(netConn, nil)
+			require.NoError(t, err)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			session, err := client.NewSession(ctx, &SessionOptions{
+				ResourceLimits: &ResourceLimits{MaxUnsettled: 1, Policy: policy},
+			})
+			cancel()
+			require.NoError(t, err)
+			ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			snd, err := session.NewSender(ctx, "target", nil)
+			cancel()
+			require.NoError(t, err)
+			sendInitialFlowFrame(t, netConn, 0, 10)
+
+			// the first message is never settled
+			ctx, cancel = context.WithCancel(context.Background())
+			first := make(chan error, 1)
+			go func() { first <- snd.Send(ctx, NewMessage([]byte("first"))) }()
+			require.Eventually(t, func() bool {
+				session.budget.mu.Lock()
+				defer session.budget.mu.Unlock()
+				return session.budget.unsettled == 1
+			}, time.Second, time.Millisecond)
+
+			ctx2, cancel2 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			err = snd.Send(ctx2, NewMessage([]byte("second")))
+			cancel2()
+			switch policy {
+			case LimitBlock:
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+			case LimitReject:
+				var limitErr *LimitError
+				require.ErrorAs(t, err, &limitErr)
+			case LimitDetach:
+				var detachErr *DetachError
+				require.ErrorAs(t, err, &detachErr)
+				require.ErrorIs(t, err, ErrCondResourceLimitExceeded)
+			}
+			cancel()
+			<-first
+			require.NoError(t, client.Close())
+		})
+	}
+}
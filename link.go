@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Azure/go-amqp/internal/clock"
 	"github.com/Azure/go-amqp/internal/debug"
 	"github.com/Azure/go-amqp/internal/encoding"
 	"github.com/Azure/go-amqp/internal/frames"
+	"github.com/Azure/go-amqp/internal/shared"
 )
 
 // linkKey uniquely identifies a link on a connection by name and direction.
@@ -44,13 +48,21 @@ type link struct {
 	// This will be initiated if the service sends back an error or requests the link detach.
 	detached chan struct{}
 
-	detachErrorMu sync.Mutex              // protects detachError
+	// debugReq is serviced by the Sender/Receiver mux for Conn.DebugDump.
+	debugReq chan chan string
+
+	detachErrorMu sync.Mutex              // protects detachError and suspend
 	detachError   *Error                  // error to send to remote on detach, set by closeWithError
+	suspend       bool                    // detach without closing the link, set by detachLink
 	session       *Session                // parent session
 	source        *frames.Source          // used for Receiver links
 	target        *frames.Target          // used for Sender links
 	properties    map[encoding.Symbol]any // additional properties sent upon link attach
 
+	offeredCapabilities encoding.MultiSymbol  // capabilities offered upon link attach
+	desiredCapabilities encoding.MultiSymbol  // capabilities desired upon link attach
+	remoteAttach        *frames.PerformAttach // the peer's attach, nil until attached
+
 	// "The delivery-count is initialized by the sender when a link endpoint is created,
 	// and is incremented whenever a message is sent. Only the sender MAY independently
 	// modify this field. The receiver's value is calculated based on the last known
@@ -68,25 +80,111 @@ type link struct {
 	receiverSettleMode *ReceiverSettleMode
 	maxMessageSize     uint64
 	detachReceived     bool
+	suspended          bool  // detached without closing, by detachLink or the peer
 	err                error // err returned on Close()
+
+	attachTimeout time.Duration // bounds the attach exchange, zero to only use the caller's context
+	detachTimeout time.Duration // bounds waiting for the peer's detach, zero for the default
+	orphaned      bool          // detached without the peer's acknowledgement, see Session.mux
+	unlinked      chan struct{} // closed by Session.mux once it's done with the peer's detach
+	lazy          *lazyAttach   // defers the attach until first use, nil when attached on creation
+}
+
+// setCapabilities sets the capabilities offered and desired upon attach.
+func (l *link) setCapabilities(offered, desired []string) {
+	for _, v := range offered {
+		l.offeredCapabilities = append(l.offeredCapabilities, encoding.Symbol(v))
+	}
+	for _, v := range desired {
+		l.desiredCapabilities = append(l.desiredCapabilities, encoding.Symbol(v))
+	}
+}
+
+// remoteProperties returns a copy of the link properties of the peer's attach.
+func (l *link) remoteProperties() map[string]any {
+	if l.remoteAttach == nil || l.remoteAttach.Properties == nil {
+		return nil
+	}
+	props := make(map[string]any, len(l.remoteAttach.Properties))
+	for k, v := range l.remoteAttach.Properties {
+		props[string(k)] = v
+	}
+	return props
+}
+
+// remoteCapabilities returns the capabilities offered and desired in the peer's attach.
+func (l *link) remoteCapabilities() (offered, desired []string) {
+	if l.remoteAttach == nil {
+		return nil, nil
+	}
+	return symbolStrings(l.remoteAttach.OfferedCapabilities), symbolStrings(l.remoteAttach.DesiredCapabilities)
+}
+
+// symbolStrings returns the symbols of ms as strings, or nil if it's empty.
+func symbolStrings(ms encoding.MultiSymbol) []string {
+	if len(ms) == 0 {
+		return nil
+	}
+	s := make([]string, len(ms))
+	for i, v := range ms {
+		s[i] = string(v)
+	}
+	return s
+}
+
+// defaultDetachTimeout bounds waiting for the peer's detach after a failed attach.
+const defaultDetachTimeout = 5 * time.Second
+
+// setTimeouts sets the attach and detach timeouts from the link's options.
+func (l *link) setTimeouts(attach, detach time.Duration) error {
+	if attach < 0 {
+		return fmt.Errorf("invalid AttachTimeout %v", attach)
+	}
+	if detach < 0 {
+		return fmt.Errorf("invalid DetachTimeout %v", detach)
+	}
+	l.attachTimeout = attach
+	l.detachTimeout = detach
+	return nil
+}
+
+// detachContext returns the context bounding the wait for the peer's detach,
+// using fallback when no DetachTimeout was set. A zero fallback doesn't bound it.
+func (l *link) detachContext(fallback time.Duration) (context.Context, context.CancelFunc) {
+	timeout := l.detachTimeout
+	if timeout == 0 {
+		timeout = fallback
+	}
+	if timeout == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
 }
 
 // attach sends the Attach performative to establish the link with its parent session.
 // this is automatically called by the new*Link constructors.
 func (l *link) attach(ctx context.Context, beforeAttach func(*frames.PerformAttach), afterAttach func(*frames.PerformAttach)) error {
+	if l.attachTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.attachTimeout)
+		defer cancel()
+	}
+
 	if err := l.session.allocateHandle(l); err != nil {
 		return err
 	}
 
 	attach := &frames.PerformAttach{
-		Name:               l.key.name,
-		Handle:             l.handle,
-		ReceiverSettleMode: l.receiverSettleMode,
-		SenderSettleMode:   l.senderSettleMode,
-		MaxMessageSize:     l.maxMessageSize,
-		Source:             l.source,
-		Target:             l.target,
-		Properties:         l.properties,
+		Name:                l.key.name,
+		Handle:              l.handle,
+		ReceiverSettleMode:  l.receiverSettleMode,
+		SenderSettleMode:    l.senderSettleMode,
+		MaxMessageSize:      l.maxMessageSize,
+		Source:              l.source,
+		Target:              l.target,
+		OfferedCapabilities: l.offeredCapabilities,
+		DesiredCapabilities: l.desiredCapabilities,
+		Properties:          l.properties,
 	}
 
 	// link-specific configuration of the attach frame
@@ -95,24 +193,34 @@ func (l *link) attach(ctx context.Context, beforeAttach func(*frames.PerformAtta
 	// send Attach frame
 	debug.Log(1, "TX (attachLink): %s", attach)
 
-	_ = l.session.txFrame(attach, nil)
+	if err := l.txAttach(ctx, attach); err != nil {
+		if ctx.Err() != nil {
+			// the attach wasn't sent, the handle can be reused
+			l.session.deallocateHandle(l)
+		}
+		return err
+	}
+	l.emitEvent(LinkEventAttach, nil)
 
 	// wait for response
 	var fr frames.FrameBody
 	select {
 	case <-ctx.Done():
+	case <-l.session.done:
+		// session has terminated, no need to deallocate in this case
+		return l.session.err
+	case fr = <-l.rx:
+	}
+	if ctx.Err() != nil {
 		// attach was written to the network. assume it was received
-		// and that the ctx was too short to wait for the ack.
+		// and that the ctx was too short to wait for the ack, or was
+		// cancelled while the ack was on its way.
 		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			ctx, cancel := l.detachContext(defaultDetachTimeout)
 			defer cancel()
 			l.muxDetach(ctx, nil, nil)
 		}()
 		return ctx.Err()
-	case <-l.session.done:
-		// session has terminated, no need to deallocate in this case
-		return l.session.err
-	case fr = <-l.rx:
 	}
 	debug.Log(3, "RX (attachLink): %s", fr)
 	resp, ok := fr.(*frames.PerformAttach)
@@ -135,7 +243,7 @@ func (l *link) attach(ctx context.Context, beforeAttach func(*frames.PerformAtta
 		case <-ctx.Done():
 			// if we don't send an ack then we're in violation of the protocol
 			go func() {
-				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				ctx, cancel := l.detachContext(defaultDetachTimeout)
 				defer cancel()
 				l.muxDetach(ctx, nil, nil)
 			}()
@@ -143,6 +251,12 @@ func (l *link) attach(ctx context.Context, beforeAttach func(*frames.PerformAtta
 		case <-l.session.done:
 			return l.session.err
 		case fr = <-l.rx:
+			// wait for the session to finish with the detach, as the
+			// link is attached again when following a redirect
+			select {
+			case <-l.unlinked:
+			case <-l.session.done:
+			}
 			l.session.deallocateHandle(l)
 		}
 
@@ -162,6 +276,13 @@ func (l *link) attach(ctx context.Context, beforeAttach func(*frames.PerformAtta
 		if detach.Error == nil {
 			return fmt.Errorf("received detach with no error specified")
 		}
+		if detach.Error.Condition == ErrCondLinkRedirect {
+			return newLinkRedirectError(detach.Error, detach.Error)
+		}
+		if isLinkStolen(detach.Error) {
+			// e.g. a link with a higher epoch is already attached
+			return &LinkStolenError{RemoteErr: detach.Error}
+		}
 		return detach.Error
 	}
 
@@ -176,15 +297,285 @@ func (l *link) attach(ctx context.Context, beforeAttach func(*frames.PerformAtta
 		l.muxDetach(ctx, nil, nil)
 		return err
 	}
+	l.remoteAttach = resp
 
+	l.logger().Debug("link attached", "name", l.key.name, "role", l.key.role)
+	l.emitEvent(LinkEventAttachConfirmed, nil)
 	return nil
 }
 
+// txAttach sends attach through the session's mux rather than directly on the
+// connection, so it's written after the detach of any link that previously used
+// the same handle. The detach is handed to the mux before the handle is deallocated.
+func (l *link) txAttach(ctx context.Context, attach *frames.PerformAttach) error {
+	select {
+	case l.session.tx <- attach:
+		return nil
+	case <-l.session.done:
+		return l.session.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// acceptAttach completes an attach initiated by the peer by replying with our
+// attach performative. The settlement modes must already reflect those agreed.
+func (l *link) acceptAttach(peer *frames.PerformAttach, beforeAttach func(*frames.PerformAttach)) error {
+	if err := l.session.allocateHandle(l); err != nil {
+		return err
+	}
+	l.remoteHandle = peer.Handle
+
+	attach := &frames.PerformAttach{
+		Name:                l.key.name,
+		Handle:              l.handle,
+		ReceiverSettleMode:  l.receiverSettleMode,
+		SenderSettleMode:    l.senderSettleMode,
+		MaxMessageSize:      l.maxMessageSize,
+		Source:              l.source,
+		Target:              l.target,
+		OfferedCapabilities: l.offeredCapabilities,
+		DesiredCapabilities: l.desiredCapabilities,
+		Properties:          l.properties,
+	}
+
+	// link-specific configuration of the attach frame
+	beforeAttach(attach)
+
+	debug.Log(1, "TX (acceptAttach): %s", attach)
+	// the session's mux is waiting for the link to be accepted, so the attach
+	// can't go through it, but it has already sent any preceding detach
+	if err := l.session.txFrame(attach, nil); err != nil {
+		l.session.deallocateHandle(l)
+		return err
+	}
+	l.emitEvent(LinkEventAttach, nil)
+
+	if l.maxMessageSize == 0 || peer.MaxMessageSize < l.maxMessageSize {
+		l.maxMessageSize = peer.MaxMessageSize
+	}
+	l.remoteAttach = peer
+
+	l.logger().Debug("link attached", "name", l.key.name, "role", l.key.role)
+	l.emitEvent(LinkEventAttachConfirmed, nil)
+	return nil
+}
+
+// logger returns the connection's Logger.
+func (l *link) logger() Logger {
+	if l.session == nil || l.session.conn == nil {
+		return nopLogger{}
+	}
+	return l.session.conn.logger
+}
+
+// newLinkName generates a link name with the source of randomness of session's connection.
+func newLinkName(session *Session) (string, error) {
+	if session == nil || session.conn == nil {
+		return shared.RandString(40), nil
+	}
+	name, err := session.conn.randString(40)
+	if err != nil {
+		return "", fmt.Errorf("amqp: generating link name: %w", err)
+	}
+	return name, nil
+}
+
+// clock returns the connection's Clock.
+func (l *link) clock() clock.Clock {
+	if l.session == nil || l.session.conn == nil {
+		return clock.Real
+	}
+	return l.session.conn.clock
+}
+
+// timers returns the Clock of the connection for timers that are
+// frequently reset or stopped before firing.
+func (l *link) timers() clock.Clock {
+	if l.session == nil || l.session.conn == nil {
+		return clock.Real
+	}
+	return l.session.conn.timers
+}
+
+// profile returns the connection's Profile.
+func (l *link) profile() Profile {
+	if l.session == nil || l.session.conn == nil {
+		return Profile{}
+	}
+	return l.session.conn.profile
+}
+
+// metrics returns the connection's Metrics.
+func (l *link) metrics() Metrics {
+	if l.session == nil || l.session.conn == nil {
+		return nopMetrics{}
+	}
+	return l.session.conn.metrics
+}
+
+// translateErr passes err through the connection's ConnOptions.ErrorHook.
+func (l *link) translateErr(err error) error {
+	if l.session == nil || l.session.conn == nil {
+		return err
+	}
+	return l.session.conn.translateErr(err)
+}
+
+// maxLinkRedirects is the maximum number of redirects followed when attaching a link.
+const maxLinkRedirects = 5
+
+// attachFollowingRedirects calls attach. When followRedirects is true and attach fails
+// with a *LinkRedirectError to a node on the same connection, setAddress is called with
+// the new address and attach is retried, up to maxLinkRedirects times.
+func (l *link) attachFollowingRedirects(ctx context.Context, followRedirects bool, attach func(context.Context) error, setAddress func(string)) (err error) {
+	defer func() {
+		if err != nil {
+			l.logger().Warn("link attach failed", "name", l.key.name, "role", l.key.role, "error", err)
+		}
+	}()
+	for redirects := 0; ; redirects++ {
+		err = attach(ctx)
+		var redirectErr *LinkRedirectError
+		if err == nil || !followRedirects || redirects == maxLinkRedirects || !errors.As(err, &redirectErr) {
+			return err
+		}
+		if redirectErr.Address == "" || (redirectErr.NetworkHost != "" && !strings.EqualFold(redirectErr.NetworkHost, l.session.conn.hostname)) {
+			// redirect to another host requires a new connection
+			return err
+		}
+		debug.Log(1, "link %s: following redirect to %s", l.key.name, redirectErr.Address)
+		l.logger().Info("following link redirect", "name", l.key.name, "address", redirectErr.Address)
+		setAddress(redirectErr.Address)
+	}
+}
+
+// lazyAttach defers attaching a link until it's first used or opened.
+// See SenderOptions.LazyAttach and ReceiverOptions.LazyAttach.
+//
+// The attach isn't bound by the context of the call that started it, so a
+// caller giving up doesn't leave the link half-open; a later call waits
+// for the same attach to complete instead.
+type lazyAttach struct {
+	open     func(context.Context) error // attaches the link
+	sem      chan struct{}               // held while starting an attach or closing
+	attached uint32                      // set atomically once the link is attached
+	pending  chan struct{}               // closed once the attach in progress completes, nil if none, guarded by sem
+	err      error                       // error from the attach, or &DetachError{} if closed first, guarded by sem
+}
+
+func newLazyAttach(open func(context.Context) error) *lazyAttach {
+	return &lazyAttach{open: open, sem: make(chan struct{}, 1)}
+}
+
+// isAttached returns true once the link is attached.
+// A nil *lazyAttach is for a link attached on creation.
+func (a *lazyAttach) isAttached() bool {
+	return a == nil || atomic.LoadUint32(&a.attached) == 1
+}
+
+// ensure attaches the link if it isn't attached yet, or waits for the
+// attach in progress. A failed attach can't be retried, its error is
+// returned by every later call. Completing ctx only stops waiting.
+func (a *lazyAttach) ensure(ctx context.Context) error {
+	for {
+		if a.isAttached() {
+			return nil
+		}
+		select {
+		case a.sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if a.isAttached() {
+			<-a.sem
+			return nil
+		}
+		if a.err != nil {
+			err := a.err
+			<-a.sem
+			return err
+		}
+		if a.pending == nil {
+			a.pending = make(chan struct{})
+			go a.attach(a.pending)
+		}
+		pending := a.pending
+		<-a.sem
+
+		select {
+		case <-pending:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// attach attaches the link, closing pending once done.
+func (a *lazyAttach) attach(pending chan struct{}) {
+	// bounded by the link's AttachTimeout, if any
+	err := a.open(context.Background())
+	a.sem <- struct{}{}
+	if err != nil {
+		a.err = err
+	} else {
+		atomic.StoreUint32(&a.attached, 1)
+	}
+	a.pending = nil
+	close(pending)
+	<-a.sem
+}
+
+// close prevents a link that isn't attached yet from being attached,
+// waiting for the attach in progress if any. It returns true if the link
+// was never attached.
+func (a *lazyAttach) close(ctx context.Context) (bool, error) {
+	for {
+		if a.isAttached() {
+			return false, nil
+		}
+		select {
+		case a.sem <- struct{}{}:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		if a.isAttached() {
+			<-a.sem
+			return false, nil
+		}
+		pending := a.pending
+		if pending == nil {
+			if a.err == nil {
+				a.err = &DetachError{}
+			}
+			<-a.sem
+			return true, nil
+		}
+		<-a.sem
+
+		select {
+		case <-pending:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
 // setSettleModes sets the settlement modes based on the resp frames.PerformAttach.
 //
 // If a settlement mode has been explicitly set locally and it was not honored by the
 // server an error is returned.
 func (l *link) setSettleModes(resp *frames.PerformAttach) error {
+	if l.profile().AssumeRequestedSettleModes {
+		// the peer omitting a mode means it agreed to the one requested
+		if resp.ReceiverSettleMode == nil {
+			resp.ReceiverSettleMode = l.receiverSettleMode
+		}
+		if resp.SenderSettleMode == nil {
+			resp.SenderSettleMode = l.senderSettleMode
+		}
+	}
+
 	var (
 		localRecvSettle = receiverSettleModeValue(l.receiverSettleMode)
 		respRecvSettle  = receiverSettleModeValue(resp.ReceiverSettleMode)
@@ -212,29 +603,57 @@ func (l *link) muxHandleFrame(fr frames.FrameBody) error {
 	// remote side is closing links
 	case *frames.PerformDetach:
 		debug.Log(1, "RX (muxHandleFrame): %s", fr)
-		// don't currently support link detach and reattach
-		if !fr.Closed {
-			return &DetachError{inner: fmt.Errorf("non-closing detach not supported: %+v", fr)}
-		}
 
 		// set detach received and close link
 		l.detachReceived = true
 
+		if !fr.Closed {
+			// the peer suspended the link, it retains the terminus state
+			// so the link can be reattached
+			l.suspended = true
+			l.logger().Debug("link suspended by peer", "name", l.key.name, "error", fr.Error)
+			return &DetachError{RemoteErr: fr.Error, Suspended: true, remote: true}
+		}
+
 		if fr.Error != nil {
-			return &DetachError{RemoteErr: fr.Error}
+			l.logger().Warn("link detached by peer", "name", l.key.name, "error", fr.Error)
+			return remoteDetachError(fr.Error)
 		}
-		return &DetachError{}
+		l.logger().Debug("link detached by peer", "name", l.key.name)
+		return &DetachError{remote: true}
 
 	default:
-		// TODO: evaluate
 		debug.Log(1, "muxHandleFrame: unexpected frame: %s\n", fr)
+		if l.session != nil && l.session.conn != nil && l.session.conn.protocolMode == ProtocolModeStrict {
+			return l.closeWithError(&Error{
+				Condition:   ErrCondNotAllowed,
+				Description: fmt.Sprintf("unexpected %s frame", performativeName(fr)),
+			})
+		}
+		l.logger().Warn("link received unexpected frame", "name", l.key.name, "frame", fr)
 	}
 
 	return nil
 }
 
+// closeWithError starts detaching the link, sending de to the peer.
+// It returns a *DetachError wrapping de.
+func (l *link) closeWithError(de *Error) error {
+	l.closeOnce.Do(func() {
+		l.detachErrorMu.Lock()
+		l.detachError = de
+		l.detachErrorMu.Unlock()
+		close(l.close)
+	})
+	return &DetachError{inner: de}
+}
+
 // Close closes the Sender and AMQP link.
 func (l *link) closeLink(ctx context.Context) error {
+	if closed, err := l.lazy.close(ctx); closed || err != nil {
+		// the link was never attached, there's nothing to detach
+		return err
+	}
 	l.closeOnce.Do(func() { close(l.close) })
 	select {
 	case <-l.detached:
@@ -250,7 +669,55 @@ func (l *link) closeLink(ctx context.Context) error {
 	return l.err
 }
 
+// detachLink suspends the link, detaching it without closing it so the
+// peer retains its terminus state.
+func (l *link) detachLink(ctx context.Context) error {
+	if closed, err := l.lazy.close(ctx); closed || err != nil {
+		// the link was never attached, there's nothing to detach
+		return err
+	}
+	l.closeOnce.Do(func() {
+		l.detachErrorMu.Lock()
+		l.suspend = true
+		l.detachErrorMu.Unlock()
+		close(l.close)
+	})
+	select {
+	case <-l.detached:
+		// mux exited
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	var detachErr *DetachError
+	if errors.As(l.err, &detachErr) && detachErr.Suspended && detachErr.RemoteErr == nil && detachErr.inner == nil {
+		return nil
+	}
+	return l.err
+}
+
+// logDetached logs the end of the link once it's detached.
+func (l *link) logDetached() {
+	var detachErr *DetachError
+	if l.err == nil || (errors.As(l.err, &detachErr) && detachErr.inner == nil) {
+		// closed by the caller, or detached by the peer, which is logged on receipt
+		l.logger().Debug("link detached", "name", l.key.name, "closed", !l.suspended)
+		return
+	}
+	l.logger().Warn("link detached with error", "name", l.key.name, "error", l.err)
+}
+
+// isSuspended returns true if the link was detached without being closed.
+func (l *link) isSuspended() bool {
+	select {
+	case <-l.detached:
+		return l.suspended
+	default:
+		return false
+	}
+}
+
 func (l *link) muxDetach(ctx context.Context, deferred func(), onRXTransfer func(frames.PerformTransfer)) {
+	var sent bool
 	defer func() {
 		// final cleanup and signaling
 
@@ -261,12 +728,18 @@ func (l *link) muxDetach(ctx context.Context, deferred func(), onRXTransfer func
 		if ctx.Err() == nil {
 			// deallocate handle
 			l.session.deallocateHandle(l)
+		} else if sent {
+			// the session deallocates it once the peer's detach arrives
+			l.orphaned = true
 		}
 
 		if deferred != nil {
 			deferred()
 		}
 
+		l.emitEvent(LinkEventDetach, l.err)
+		l.logDetached()
+
 		// signal that the link mux has exited
 		close(l.detached)
 	}()
@@ -284,11 +757,20 @@ func (l *link) muxDetach(ctx context.Context, deferred func(), onRXTransfer func
 
 	l.detachErrorMu.Lock()
 	detachError := l.detachError
+	if l.suspend && !l.detachReceived {
+		l.suspended = true
+		if detachErr := (&DetachError{}); errors.As(l.err, &detachErr) && *detachErr == (DetachError{}) {
+			// closed by detachLink rather than closeLink
+			l.err = &DetachError{Suspended: true}
+		}
+	}
 	l.detachErrorMu.Unlock()
 
+	// a suspended link is detached without closing it, so the
+	// peer retains its terminus state for a later reattach
 	fr := &frames.PerformDetach{
 		Handle: l.handle,
-		Closed: true,
+		Closed: !l.suspended,
 		Error:  detachError,
 	}
 
@@ -299,12 +781,13 @@ Loop:
 			return
 		case l.session.tx <- fr:
 			// after sending the detach frame, break the read loop
+			sent = true
 			break Loop
 		case fr := <-l.rx:
 			// read from link to avoid blocking session.mux
 			switch fr := fr.(type) {
 			case *frames.PerformDetach:
-				if fr.Closed {
+				if fr.Closed || l.suspended {
 					l.detachReceived = true
 				}
 			case *frames.PerformTransfer:
@@ -330,11 +813,12 @@ Loop:
 		case <-ctx.Done():
 			return
 
-		// read from link until detach with Close == true is received
+		// read from link until detach with Close == true is received,
+		// or any detach when suspending the link
 		case fr := <-l.rx:
 			switch fr := fr.(type) {
 			case *frames.PerformDetach:
-				if fr.Closed {
+				if fr.Closed || l.suspended {
 					return
 				}
 			case *frames.PerformTransfer:
@@ -0,0 +1,125 @@
+package amqp
+
+import (
+	"fmt"
+
+	"github.com/Azure/go-amqp/internal/encoding"
+)
+
+// LinkEventType identifies a link lifecycle event.
+type LinkEventType int
+
+const (
+	// LinkEventAttach is emitted when an attach frame is sent to the peer.
+	LinkEventAttach LinkEventType = iota
+
+	// LinkEventAttachConfirmed is emitted when the peer's attach has been
+	// received and the link is ready for use.
+	LinkEventAttachConfirmed
+
+	// LinkEventDetach is emitted when the link has been detached,
+	// either locally or by the peer.
+	LinkEventDetach
+
+	// LinkEventCreditGranted is emitted when a receiver grants link credit
+	// to the peer, or when a sender is granted link credit by the peer.
+	LinkEventCreditGranted
+
+	// LinkEventCreditExhausted is emitted when a delivery uses the last
+	// of the link credit.
+	LinkEventCreditExhausted
+
+	// LinkEventCreditStarved is emitted when a sender has had no link credit
+	// for longer than SenderOptions.CreditStarvationThreshold.
+	LinkEventCreditStarved
+
+	// LinkEventSlowConsumer is emitted when a receiver's prefetch queue has
+	// been full for longer than ReceiverOptions.SlowConsumerThreshold.
+	LinkEventSlowConsumer
+
+	// LinkEventMessageSizeExceeded is emitted when a receiver discards a
+	// message larger than ReceiverOptions.MaxMessageSize, as configured by
+	// ReceiverOptions.OversizedMessages. Err is a *MessageSizeError.
+	LinkEventMessageSizeExceeded
+)
+
+// String implements the fmt.Stringer interface for LinkEventType.
+func (t LinkEventType) String() string {
+	switch t {
+	case LinkEventAttach:
+		return "attach"
+	case LinkEventAttachConfirmed:
+		return "attach-confirmed"
+	case LinkEventDetach:
+		return "detach"
+	case LinkEventCreditGranted:
+		return "credit-granted"
+	case LinkEventCreditExhausted:
+		return "credit-exhausted"
+	case LinkEventCreditStarved:
+		return "credit-starved"
+	case LinkEventSlowConsumer:
+		return "slow-consumer"
+	case LinkEventMessageSizeExceeded:
+		return "message-size-exceeded"
+	default:
+		return fmt.Sprintf("unknown link event %d", int(t))
+	}
+}
+
+// LinkEvent describes a change in a link's lifecycle.
+// See ConnOptions.LinkEventHook.
+type LinkEvent struct {
+	// Type is the kind of event.
+	Type LinkEventType
+
+	// Name is the link name.
+	Name string
+
+	// Receiver is true for receiver links and false for sender links.
+	Receiver bool
+
+	// Source and Target are the addresses of the link's terminus.
+	// For dynamic links, the address assigned by the peer is reported
+	// once the attach has been confirmed.
+	Source string
+	Target string
+
+	// Credit is the link credit available after the event.
+	Credit uint32
+
+	// Err is the error that caused a LinkEventDetach. It's the
+	// error returned by the link's methods after the detach.
+	// For LinkEventMessageSizeExceeded, it's a *MessageSizeError.
+	Err error
+}
+
+// emitEvent calls the connection's LinkEventHook, if set, for the link.
+func (l *link) emitEvent(typ LinkEventType, err error) {
+	if l.session == nil || l.session.conn == nil || l.session.conn.linkEvents == nil {
+		return
+	}
+	ev := LinkEvent{
+		Type:     typ,
+		Name:     l.key.name,
+		Receiver: l.key.role == encoding.RoleReceiver,
+		Credit:   l.availableCredit,
+		Err:      err,
+	}
+	if l.source != nil {
+		ev.Source = l.source.Address
+	}
+	if l.target != nil {
+		ev.Target = l.target.Address
+	}
+	l.session.conn.linkEvents(ev)
+}
+
+// creditConsumed accounts for a completed delivery on the link.
+func (l *link) creditConsumed() {
+	l.deliveryCount++
+	l.availableCredit--
+	if l.availableCredit == 0 {
+		l.emitEvent(LinkEventCreditExhausted, nil)
+	}
+}
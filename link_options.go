@@ -7,9 +7,53 @@ import (
 )
 
 type SenderOptions struct {
+	// AttachTimeout bounds the attach exchange with the peer independently
+	// of the context passed to NewSender. When it elapses,
+	// context.DeadlineExceeded is returned and the half-open link is detached.
+	//
+	// Default: 0 (bounded only by the context).
+	AttachTimeout time.Duration
+
 	// Capabilities is the list of extension capabilities the sender supports.
 	Capabilities []string
 
+	// Compression, when set, compresses the data payloads of sent messages
+	// and records the scheme in the message's content-encoding property.
+	//
+	// Messages without data payloads, or with content-encoding already
+	// set, are sent unchanged. The caller's message is not modified.
+	Compression Compression
+
+	// CompressionThreshold is the minimum total size, in bytes, of a
+	// message's data payloads for it to be compressed.
+	//
+	// Default: 0.
+	CompressionThreshold int
+
+	// CreditStarvationThreshold is how long the sender can have no link
+	// credit before a LinkEventCreditStarved event is emitted, indicating
+	// the peer is throttling the sender. See ConnOptions.LinkEventHook.
+	//
+	// Default: 0 (disabled).
+	CreditStarvationThreshold time.Duration
+
+	// DesiredCapabilities is the list of link capabilities the sender can use
+	// if the peer supports them, sent in the attach performative. Those the
+	// peer supports are returned by Sender.RemoteOfferedCapabilities.
+	//
+	// Default: nil.
+	DesiredCapabilities []string
+
+	// DetachTimeout bounds how long to wait for the peer to acknowledge the
+	// detach of the link, when it's closed or after a failed attach,
+	// independently of the context passed to Close. Once it elapses the link
+	// is considered detached, and its handle is reused when the peer's
+	// acknowledgement eventually arrives.
+	//
+	// Default: 0 (Close waits as long as its context allows, and the
+	// link is detached after a failed attach within 5 seconds).
+	DetachTimeout time.Duration
+
 	// Durability indicates what state of the sender will be retained durably.
 	//
 	// Default: DurabilityNone.
@@ -33,11 +77,33 @@ type SenderOptions struct {
 	// Default: 0.
 	ExpiryTimeout uint32
 
+	// FollowRedirects causes the link to be re-attached to the node indicated
+	// by the peer when it responds to the attach with the amqp:link:redirect
+	// condition. Only redirects to nodes on the same connection are followed;
+	// otherwise a *LinkRedirectError is returned.
+	//
+	// Default: false.
+	FollowRedirects bool
+
 	// IgnoreDispositionErrors controls automatic detach on disposition errors.
 	//
 	// Default: false.
 	IgnoreDispositionErrors bool
 
+	// Interceptors are called in order on every message before it's
+	// validated, compressed, and encoded. See SendInterceptor.
+	Interceptors []SendInterceptor
+
+	// LazyAttach defers the attach exchange with the peer until the Sender
+	// is first used, or opened with Sender.Open, instead of performing it
+	// in NewSender. If the deferred attach fails, its error is returned by
+	// the operation that triggered it and by every later one. The attach
+	// isn't canceled by the context of that operation, which only stops
+	// waiting for it; AttachTimeout bounds it.
+	//
+	// Default: false.
+	LazyAttach bool
+
 	// Name sets the name of the link.
 	//
 	// Link names must be unique per-connection and direction.
@@ -45,6 +111,12 @@ type SenderOptions struct {
 	// Default: randomly generated.
 	Name string
 
+	// OfferedCapabilities is the list of link capabilities the sender supports,
+	// sent in the attach performative.
+	//
+	// Default: nil.
+	OfferedCapabilities []string
+
 	// Properties sets an entry in the link properties map sent to the server.
 	Properties map[string]any
 
@@ -56,6 +128,24 @@ type SenderOptions struct {
 	// Default: Accept the settlement mode set by the server, commonly ModeFirst.
 	RequestedReceiverSettleMode *ReceiverSettleMode
 
+	// ResumeDeliveries retains the unsettled deliveries of the sender, so
+	// when its link is reattached with Sender.Reattach they're resumed rather
+	// than sent again or lost. Deliveries the receiver already has an outcome
+	// for are settled with it, the others are transferred again, flagged as
+	// resumed if the receiver has a record of them.
+	//
+	// The encoded message of each unsettled delivery is retained until it's
+	// settled.
+	//
+	// Default: false.
+	ResumeDeliveries bool
+
+	// RoutingType adds the capabilities requesting the routing semantics
+	// of the target node to TargetCapabilities.
+	//
+	// Default: RoutingTypeDefault.
+	RoutingType RoutingType
+
 	// SettlementMode sets the settlement mode in use by this sender.
 	//
 	// Default: ModeMixed.
@@ -83,13 +173,26 @@ type SenderOptions struct {
 	//
 	// Default: 0.
 	TargetExpiryTimeout uint32
+
+	// ValidateMessages causes Send to call Message.Validate and return
+	// its error instead of sending messages that don't conform to the spec.
+	//
+	// Default: false.
+	ValidateMessages bool
 }
 
 type ReceiverOptions struct {
+	// AttachTimeout bounds the attach exchange with the peer independently
+	// of the context passed to NewReceiver. When it elapses,
+	// context.DeadlineExceeded is returned and the half-open link is detached.
+	//
+	// Default: 0 (bounded only by the context).
+	AttachTimeout time.Duration
+
 	// LinkBatching toggles batching of message disposition.
 	//
 	// When enabled, accepting a message does not send the disposition
-	// to the server until the batch is equal to link credit or the
+	// to the server until the batch reaches BatchMaxSize or the
 	// batch max age expires.
 	//
 	// Default: false.
@@ -103,6 +206,15 @@ type ReceiverOptions struct {
 	// Default: 5 seconds.
 	BatchMaxAge time.Duration
 
+	// BatchMaxSize sets the maximum number of accepted messages in a
+	// disposition batch. The batch is sent to the server once it's reached.
+	// A BatchMaxSize of 1 disables batching.
+	//
+	// Has no effect when Batching is false.
+	//
+	// Default: Credit.
+	BatchMaxSize uint32
+
 	// Capabilities is the list of extension capabilities the receiver supports.
 	Capabilities []string
 
@@ -112,6 +224,61 @@ type ReceiverOptions struct {
 	// Default: 1.
 	Credit uint32
 
+	// DecodeLimits constrains the values accepted when decoding
+	// messages received on the link.
+	//
+	// Default: the DecodeLimits of the connection.
+	DecodeLimits *DecodeLimits
+
+	// Decompression lists the schemes used to automatically decompress
+	// data payloads of received messages. A message is decompressed when
+	// its content-encoding matches a scheme's ContentEncoding, after which
+	// the content-encoding property is cleared.
+	//
+	// Messages that fail to decompress are delivered unchanged. Messages
+	// larger than MaxDecompressedSize or MaxMessageSize once decompressed
+	// are handled according to OversizedMessages.
+	Decompression []Compression
+
+	// Dedup, when set, records the keys of the messages accepted with
+	// AcceptMessage. Messages received with a key already recorded are
+	// settled as configured by the cache, instead of being returned from
+	// Receive. See DedupCache.
+	//
+	// Default: nil.
+	Dedup *DedupCache
+
+	// DesiredCapabilities is the list of link capabilities the receiver can use
+	// if the peer supports them, sent in the attach performative. Those the
+	// peer supports are returned by Receiver.RemoteOfferedCapabilities.
+	//
+	// Default: nil.
+	DesiredCapabilities []string
+
+	// DetachTimeout bounds how long to wait for the peer to acknowledge the
+	// detach of the link, when it's closed or after a failed attach,
+	// independently of the context passed to Close. Once it elapses the link
+	// is considered detached, and its handle is reused when the peer's
+	// acknowledgement eventually arrives.
+	//
+	// Default: 0 (Close waits as long as its context allows, and the
+	// link is detached after a failed attach within 5 seconds).
+	DetachTimeout time.Duration
+
+	// DiscardExpired causes messages that have already expired on arrival
+	// to be released back to the sender instead of being returned from Receive.
+	//
+	// See Message.ExpiryTime for how a message's expiry is determined.
+	//
+	// Default: false.
+	DiscardExpired bool
+
+	// DistributionMode requests how the source distributes messages to
+	// the receiver, e.g. to browse a queue with DistributionModeCopy.
+	//
+	// Default: "" (the source's default distribution mode).
+	DistributionMode DistributionMode
+
 	// Durability indicates what state of the receiver will be retained durably.
 	//
 	// Default: DurabilityNone.
@@ -139,6 +306,25 @@ type ReceiverOptions struct {
 	// If the peer cannot fulfill the filters the link will be detached.
 	Filters []LinkFilter
 
+	// FollowRedirects causes the link to be re-attached to the node indicated
+	// by the peer when it responds to the attach with the amqp:link:redirect
+	// condition. Only redirects to nodes on the same connection are followed;
+	// otherwise a *LinkRedirectError is returned.
+	//
+	// Default: false.
+	FollowRedirects bool
+
+	// LazyAttach defers the attach exchange with the peer until the Receiver
+	// is first used, or opened with Receiver.Open, instead of performing it
+	// in NewReceiver. Credit issued before then is granted once attached.
+	// If the deferred attach fails, its error is returned by the operation
+	// that triggered it and by every later one. The attach isn't canceled
+	// by the context of that operation, which only stops waiting for it;
+	// AttachTimeout bounds it.
+	//
+	// Default: false.
+	LazyAttach bool
+
 	// ManualCredits enables manual credit management for this link.
 	// Credits can be added with IssueCredit(), and links can also be
 	// drained with DrainCredit().
@@ -146,9 +332,20 @@ type ReceiverOptions struct {
 	// flow control is required.
 	ManualCredits bool
 
+	// MaxDecompressedSize bounds the size of the data payloads of a message
+	// decompressed according to Decompression. MaxMessageSize, or the max
+	// message size sent by the peer, applies instead when it's smaller.
+	//
+	// Default: 64 MiB.
+	MaxDecompressedSize uint64
+
 	// MaxMessageSize sets the maximum message size that can
 	// be received on the link.
 	//
+	// The limit is enforced as the transfers of a delivery are received,
+	// so an oversized message is never buffered in full. See
+	// OversizedMessages for what's done with it.
+	//
 	// A size of zero indicates no limit.
 	//
 	// Default: 0.
@@ -161,9 +358,42 @@ type ReceiverOptions struct {
 	// Default: randomly generated.
 	Name string
 
+	// OfferedCapabilities is the list of link capabilities the receiver supports,
+	// sent in the attach performative.
+	//
+	// Default: nil.
+	OfferedCapabilities []string
+
+	// OversizedMessages determines what's done with a message
+	// larger than MaxMessageSize.
+	//
+	// Default: OversizedMessageDetach.
+	OversizedMessages OversizedMessagePolicy
+
 	// Properties sets an entry in the link properties map sent to the server.
 	Properties map[string]any
 
+	// ZeroCopy enables decoding of received messages without copying.
+	//
+	// When enabled, a message's Data sections and binary values reference
+	// memory owned by the message instead of being copied out of the
+	// receive buffer. Call Message.Release once the message's contents are
+	// no longer needed to make that memory available for reuse.
+	//
+	// Default: false.
+	ZeroCopy bool
+
+	// PooledMessages enables reuse of received messages.
+	//
+	// When enabled, the messages returned by Receive and Prefetched are
+	// taken from a pool shared by receivers. Call Message.Release once a
+	// message is settled and no longer needed to return it to the pool,
+	// avoiding an allocation per message in high-throughput pipelines.
+	// Messages that aren't released are reclaimed by the garbage collector.
+	//
+	// Default: false.
+	PooledMessages bool
+
 	// RequestedSenderSettleMode sets the requested sender settlement mode.
 	//
 	// If a settlement mode is explicitly set and the server does not
@@ -172,11 +402,34 @@ type ReceiverOptions struct {
 	// Default: Accept the settlement mode set by the server, commonly ModeMixed.
 	RequestedSenderSettleMode *SenderSettleMode
 
+	// ResumeDeliveries retains the unsettled deliveries of the receiver, so
+	// when its link is reattached with Receiver.Reattach they're resumed
+	// rather than received again or lost. Resumed deliveries that were
+	// already received aren't received again, and the outcomes they were
+	// settled with are sent again.
+	//
+	// Default: false.
+	ResumeDeliveries bool
+
+	// RoutingType adds the capabilities requesting the routing semantics
+	// of the source node to SenderCapabilities.
+	//
+	// Default: RoutingTypeDefault.
+	RoutingType RoutingType
+
 	// SettlementMode sets the settlement mode in use by this receiver.
 	//
 	// Default: ModeFirst.
 	SettlementMode *ReceiverSettleMode
 
+	// SlowConsumerThreshold is how long a received message can wait for
+	// space in the receiver's prefetch queue before a LinkEventSlowConsumer
+	// event is emitted, indicating the application isn't calling Receive
+	// often enough. See ConnOptions.LinkEventHook.
+	//
+	// Default: 0 (disabled).
+	SlowConsumerThreshold time.Duration
+
 	// TargetAddress specifies the target address for this receiver.
 	TargetAddress string
 
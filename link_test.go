@@ -9,7 +9,7 @@ import (
 
 	"github.com/Azure/go-amqp/internal/encoding"
 	"github.com/Azure/go-amqp/internal/frames"
-	"github.com/Azure/go-amqp/internal/mocks"
+	"github.com/Azure/go-amqp/mocks"
 	"github.com/stretchr/testify/require"
 )
 
@@ -30,7 +30,6 @@ func TestLinkFlowThatNeedsToReplenishCredits(t *testing.T) {
 		// we've consumed half of the maximum credit we're allowed to have - reflow!
 		l.maxCredit = 2
 		l.l.availableCredit = 1
-		l.unsettledMessages = map[string]struct{}{}
 
 		select {
 		case l.receiverReady <- struct{}{}:
@@ -83,10 +82,8 @@ func TestLinkFlowWithZeroCredits(t *testing.T) {
 
 	l.maxCredit = 2
 	l.l.availableCredit = 0
-	l.unsettledMessages = map[string]struct{}{
-		"hello":  {},
-		"hello2": {},
-	}
+	l.unsettled.store(0, struct{}{})
+	l.unsettled.store(1, struct{}{})
 
 	select {
 	case l.receiverReady <- struct{}{}:
@@ -234,7 +231,7 @@ func newTestLink(t *testing.T) *Receiver {
 		l: link{
 			source: &frames.Source{},
 			// adding just enough so the debug() print will still work...
-			// debug(1, "FLOW Link Mux half: source: %s, inflight: %d, credit: %d, deliveryCount: %d, messages: %d, unsettled: %d, maxCredit : %d, settleMode: %s", l.source.Address, l.receiver.inFlight.len(), l.l.availableCredit, l.deliveryCount, len(l.messages), l.countUnsettled(), l.receiver.maxCredit, l.receiverSettleMode.String())
+			// debug(1, "FLOW Link Mux half: source: %s, inflight: %d, credit: %d, deliveryCount: %d, messages: %d, unsettled: %d, maxCredit : %d, settleMode: %s", l.source.Address, l.receiver.inFlight.len(), l.l.availableCredit, l.deliveryCount, l.messages.len(), l.countUnsettled(), l.receiver.maxCredit, l.receiverSettleMode.String())
 			detached: make(chan struct{}),
 			session: &Session{
 				tx:   make(chan frames.FrameBody, 100),
@@ -245,6 +242,7 @@ func newTestLink(t *testing.T) *Receiver {
 		},
 		autoSendFlow:  true,
 		inFlight:      inFlight{},
+		messages:      newPrefetchQueue(1),
 		receiverReady: make(chan struct{}, 1),
 	}
 
@@ -311,6 +309,16 @@ func TestNewSendingLink(t *testing.T) {
 				require.Empty(t, l.l.target.Address)
 			},
 		},
+		{
+			label: "with routing type",
+			opts: SenderOptions{
+				RoutingType:        RoutingTypeMulticast,
+				TargetCapabilities: []string{"foo"},
+			},
+			validate: func(t *testing.T, l *Sender) {
+				require.Equal(t, encoding.MultiSymbol{"foo", "topic", "MULTICAST"}, l.l.target.Capabilities)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -417,6 +425,27 @@ func TestNewReceivingLink(t *testing.T) {
 				require.Empty(t, l.l.source.Address)
 			},
 		},
+		{
+			label: "with routing type",
+			opts: ReceiverOptions{
+				RoutingType: RoutingTypeAnycast,
+			},
+			validate: func(t *testing.T, l *Receiver) {
+				require.Equal(t, encoding.MultiSymbol{"queue", "ANYCAST"}, l.l.source.Capabilities)
+				require.Empty(t, l.l.target.Capabilities)
+			},
+		},
+		{
+			label: "with router options",
+			opts: ReceiverOptions{
+				DistributionMode:   DistributionModeCopy,
+				SenderCapabilities: []string{CapabilityFallback},
+			},
+			validate: func(t *testing.T, l *Receiver) {
+				require.Equal(t, encoding.Symbol("copy"), l.l.source.DistributionMode)
+				require.Equal(t, encoding.MultiSymbol{"qd.fallback"}, l.l.source.Capabilities)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -482,3 +511,71 @@ func TestExactlyOnceDoesntWork(t *testing.T) {
 }
 
 // TODO: echo flow frame
+
+func TestLinkLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	conn, err := NewConn(mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)), &ConnOptions{Logger: logger})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	session, err := conn.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	snd, err := session.NewSender(ctx, "target", nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	require.NoError(t, snd.Close(ctx))
+	cancel()
+	require.NoError(t, conn.Close())
+
+	require.Equal(t, []string{
+		"INFO connection opened",
+		"DEBUG session begun",
+		"DEBUG link attached",
+		"DEBUG link detached",
+		"INFO connection closed",
+	}, logger.entries)
+}
+
+func TestLinkLoggerAttachFailed(t *testing.T) {
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *frames.PerformAttach:
+			// no target, the peer refused the link and detaches it
+			b, err := mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformAttach{
+				Name:   tt.Name,
+				Handle: 0,
+				Role:   encoding.RoleReceiver,
+			})
+			if err != nil {
+				return nil, err
+			}
+			detach, err := mocks.PerformDetach(0, 0, &Error{Condition: ErrCondNotFound})
+			if err != nil {
+				return nil, err
+			}
+			return append(b, detach...), nil
+		case *frames.PerformDetach:
+			return nil, nil
+		default:
+			return senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)(req)
+		}
+	}
+	logger := &recordingLogger{}
+	conn, err := NewConn(mocks.NewNetConn(responder), &ConnOptions{Logger: logger})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	session, err := conn.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	_, err = session.NewSender(ctx, "target", nil)
+	cancel()
+	var amqpErr *Error
+	require.ErrorAs(t, err, &amqpErr)
+	require.Equal(t, ErrCondNotFound, amqpErr.Condition)
+	require.NoError(t, conn.Close())
+
+	require.Contains(t, logger.entries, "WARN link attach failed")
+}
@@ -0,0 +1,366 @@
+package amqp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp/internal/debug"
+	"github.com/Azure/go-amqp/internal/frames"
+)
+
+// ListenerOptions contains the optional settings for configuring a Listener.
+type ListenerOptions struct {
+	// ConnOptions configures each accepted connection.
+	//
+	// Timeout bounds each step of the protocol negotiation with the
+	// client, and defaults to 30 seconds, so that clients that connect and
+	// send nothing don't hold on to the connection. IdleTimeout,
+	// MaxFrameSize, and MaxSessions are announced in the open performative
+	// sent in reply to the client's.
+	// HostName, HostnameOverride, SASLType, and TLSConfig are ignored, use
+	// ListenerOptions.TLSConfig to accept TLS connections.
+	//
+	// Default: nil.
+	ConnOptions *ConnOptions
+
+	// TLSConfig enables TLS on accepted connections. Clients must begin the
+	// TLS handshake as soon as they connect, as done when dialing amqps URLs.
+	// It must contain at least one certificate or set GetCertificate.
+	//
+	// Set ClientAuth to tls.RequireAndVerifyClientCert and ClientCAs to
+	// require client certificates, which are then passed to Authorize.
+	//
+	// Default: nil (TLS is not used).
+	TLSConfig *tls.Config
+
+	// Authorize is called once a client has been authenticated, with the
+	// client's verified certificate and SASL identity. A non-nil error refuses
+	// the connection, which is closed with amqp:unauthorized-access unless
+	// the error is an *Error, which is sent to the client instead.
+	//
+	// Authorize is called concurrently for connections that are being negotiated.
+	//
+	// Default: nil (all authenticated clients are accepted).
+	Authorize func(PeerInfo) error
+
+	// SASLTypes contains the SASL mechanisms offered to clients, in order
+	// of preference. When set, clients must authenticate with one of them
+	// before the connection is opened.
+	//
+	// Default: nil (SASL is not negotiated).
+	SASLTypes []SASLServerType
+}
+
+// defaultListenerTimeout bounds the negotiation of accepted connections
+// when ConnOptions.Timeout isn't set.
+const defaultListenerTimeout = 30 * time.Second
+
+// the bounds of the delay before retrying a temporary accept error
+const (
+	minAcceptRetryDelay = 5 * time.Millisecond
+	maxAcceptRetryDelay = time.Second
+)
+
+// PeerInfo describes the client of a connection being negotiated by a Listener.
+type PeerInfo struct {
+	// RemoteAddr is the client's network address.
+	RemoteAddr net.Addr
+
+	// Certificate is the certificate the client presented during the TLS
+	// handshake. It's only set when the certificate has been verified
+	// against ListenerOptions.TLSConfig.ClientCAs.
+	Certificate *x509.Certificate
+
+	// SASLIdentity is the identity the client authenticated as.
+	// See Conn.SASLIdentity.
+	SASLIdentity string
+
+	// HostName is the virtual host requested by the client in its open performative.
+	HostName string
+}
+
+// Listener accepts incoming AMQP connections.
+//
+// Each connection is negotiated in its own goroutine, so a slow or
+// misbehaving client doesn't delay others. Connections that fail
+// negotiation are closed and logged to ConnOptions.Logger.
+type Listener struct {
+	ln        net.Listener
+	opts      ConnOptions
+	saslTypes []SASLServerType
+	tlsConfig *tls.Config
+	authorize func(PeerInfo) error
+	logger    Logger
+
+	conns chan *Conn    // negotiated connections waiting for Accept
+	done  chan struct{} // closed by Close
+
+	acceptDone chan struct{} // closed when acceptLoop exits
+	acceptErr  error         // error returned by ln.Accept; DO NOT TOUCH until acceptDone has been closed!
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewListener creates a Listener that accepts AMQP connections from ln.
+// The Listener takes ownership of ln and closes it when the Listener is closed.
+//
+// opts: pass nil to accept the default values.
+func NewListener(ln net.Listener, opts *ListenerOptions) (*Listener, error) {
+	l := &Listener{
+		ln:         ln,
+		logger:     nopLogger{},
+		conns:      make(chan *Conn),
+		done:       make(chan struct{}),
+		acceptDone: make(chan struct{}),
+	}
+	if opts != nil {
+		if opts.ConnOptions != nil {
+			l.opts = *opts.ConnOptions
+		}
+		l.saslTypes = opts.SASLTypes
+		l.tlsConfig = opts.TLSConfig
+		l.authorize = opts.Authorize
+	}
+	if l.tlsConfig != nil && len(l.tlsConfig.Certificates) == 0 && l.tlsConfig.GetCertificate == nil && l.tlsConfig.GetConfigForClient == nil {
+		return nil, errors.New("amqp: ListenerOptions.TLSConfig doesn't contain a certificate")
+	}
+	l.opts.HostName = ""
+	l.opts.HostnameOverride = ""
+	l.opts.SASLType = nil
+	l.opts.TLSConfig = nil
+	if l.opts.Timeout <= 0 {
+		l.opts.Timeout = defaultListenerTimeout
+	}
+	if l.opts.Logger != nil {
+		l.logger = l.opts.Logger
+	}
+
+	// validate the options up front instead of failing every accepted connection
+	if _, err := l.newConn(nil); err != nil {
+		return nil, err
+	}
+
+	go l.acceptLoop()
+	return l, nil
+}
+
+// Accept waits for and returns the next negotiated connection.
+//
+// Once the Listener has been closed, the error wraps net.ErrClosed.
+func (l *Listener) Accept(ctx context.Context) (*Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.acceptDone:
+		return nil, l.acceptErr
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Addr returns the listener's network address.
+func (l *Listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// Close stops accepting connections and closes the underlying net.Listener.
+// Connections that have already been returned by Accept are not affected,
+// while those still being negotiated are closed.
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+		l.closeErr = l.ln.Close()
+	})
+	return l.closeErr
+}
+
+// acceptLoop accepts network connections until ln is closed.
+// Temporary errors, such as running out of file descriptors, are retried
+// with a capped exponential backoff like net/http.Server.Serve does.
+func (l *Listener) acceptLoop() {
+	defer close(l.acceptDone)
+	var delay time.Duration
+	for {
+		netConn, err := l.ln.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() && !errors.Is(err, net.ErrClosed) {
+				if delay == 0 {
+					delay = minAcceptRetryDelay
+				} else if delay *= 2; delay > maxAcceptRetryDelay {
+					delay = maxAcceptRetryDelay
+				}
+				l.logger.Warn("accept failed, retrying", "delay", delay, "error", err)
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-l.done:
+					// ln is being closed, the next Accept reports it
+					timer.Stop()
+				}
+				continue
+			}
+			debug.Log(1, "acceptLoop terminal error: %v", err)
+			l.acceptErr = err
+			return
+		}
+		delay = 0
+		go l.negotiate(netConn)
+	}
+}
+
+// negotiate performs the server side of connection establishment
+// and hands the connection to Accept.
+func (l *Listener) negotiate(netConn net.Conn) {
+	c, err := l.newConn(netConn)
+	if err != nil {
+		// options were validated in NewListener
+		_ = netConn.Close()
+		return
+	}
+	initial := c.acceptProtoHeader
+	if c.tlsConfig != nil {
+		initial = c.acceptTLS
+	}
+	if err := c.startWith(initial); err != nil {
+		l.logger.Warn("incoming connection negotiation failed", "remoteAddr", netConn.RemoteAddr(), "error", err)
+		return
+	}
+
+	select {
+	case l.conns <- c:
+	case <-l.done:
+		_ = c.Close()
+	}
+}
+
+// newConn creates a server-side connection for netConn.
+func (l *Listener) newConn(netConn net.Conn) (*Conn, error) {
+	c, err := newConn(netConn, &l.opts)
+	if err != nil {
+		return nil, err
+	}
+	c.sessionReqs = make(chan *SessionRequest)
+	c.tlsConfig = l.tlsConfig
+	c.authorize = l.authorize
+	for _, saslType := range l.saslTypes {
+		if err := saslType(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// acceptTLS performs the server side of the TLS handshake the client
+// begins as soon as it connects.
+func (c *Conn) acceptTLS() (stateFunc, error) {
+	if c.connectTimeout != 0 {
+		_ = c.net.SetDeadline(c.clock.Now().Add(c.connectTimeout))
+	}
+	tlsConn := tls.Server(c.net, c.tlsConfig)
+	err := tlsConn.Handshake()
+	if c.connectTimeout != 0 {
+		_ = c.net.SetDeadline(time.Time{})
+		c.readDeadlineSet = time.Time{}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+
+	// swap net.Conn
+	c.net = tlsConn
+	c.tlsComplete = true
+	return c.acceptProtoHeader, nil
+}
+
+// acceptProtoHeader reads the client's protocol header and replies with
+// the header for the protocol to negotiate.
+func (c *Conn) acceptProtoHeader() (stateFunc, error) {
+	p, err := c.readProtoHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	// the protocol we require next, in the order each must be negotiated
+	want := protoAMQP
+	if c.saslServer != nil && !c.saslComplete {
+		want = protoSASL
+	}
+
+	if p.ProtoID != want {
+		// per spec, reply with the header we support before closing
+		_ = c.writeProtoHeader(want)
+		return nil, fmt.Errorf("unexpected protocol header %#00x, expected %#00x", p.ProtoID, want)
+	}
+
+	if err := c.writeProtoHeader(want); err != nil {
+		return nil, err
+	}
+	if want == protoSASL {
+		return c.saslServerMechanisms, nil
+	}
+	return c.acceptOpen, nil
+}
+
+// acceptOpen reads the client's open performative and replies with ours.
+func (c *Conn) acceptOpen() (stateFunc, error) {
+	fr, err := c.readSingleFrame()
+	if err != nil {
+		return nil, err
+	}
+	o, ok := fr.Body.(*frames.PerformOpen)
+	if !ok {
+		return nil, fmt.Errorf("acceptOpen: unexpected frame type %T", fr.Body)
+	}
+	debug.Log(1, "RX (acceptOpen): %s", o)
+
+	// the hostname is the virtual host requested by the client
+	open := c.openFrame()
+	c.hostname = o.Hostname
+	c.updatePeerSettings(o)
+
+	var authErr error
+	if c.authorize != nil {
+		authErr = c.authorize(PeerInfo{
+			RemoteAddr:   c.net.RemoteAddr(),
+			Certificate:  c.PeerCertificate(),
+			SASLIdentity: c.saslIdentity,
+			HostName:     c.hostname,
+		})
+	}
+
+	debug.Log(1, "TX (acceptOpen): %s", open)
+	err = c.writeFrame(frames.Frame{
+		Type:    frames.TypeAMQP,
+		Body:    open,
+		Channel: 0,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if authErr != nil {
+		// the connection is refused by closing it right after the open
+		var amqpErr *Error
+		if !errors.As(authErr, &amqpErr) {
+			amqpErr = &Error{Condition: ErrCondUnauthorizedAccess, Description: authErr.Error()}
+		}
+		cls := &frames.PerformClose{Error: amqpErr}
+		debug.Log(1, "TX (acceptOpen): %s", cls)
+		_ = c.writeFrame(frames.Frame{
+			Type:    frames.TypeAMQP,
+			Body:    cls,
+			Channel: 0,
+		})
+		return nil, fmt.Errorf("connection not authorized: %w", authErr)
+	}
+
+	// connection established, exit state machine
+	return nil, nil
+}
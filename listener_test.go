@@ -0,0 +1,312 @@
+package amqp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestListener(t *testing.T, opts *ListenerOptions) *Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := NewListener(ln, opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	return l
+}
+
+func TestListenerAccept(t *testing.T) {
+	l := newTestListener(t, &ListenerOptions{
+		ConnOptions: &ConnOptions{
+			ContainerID: "server",
+			MaxSessions: 10,
+		},
+	})
+
+	client, err := Dial("amqp://"+l.Addr().String(), &ConnOptions{
+		ContainerID: "client",
+		HostName:    "vhost",
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	server, err := l.Accept(ctx)
+	cancel()
+	require.NoError(t, err)
+
+	clientStats, serverStats := client.Stats(), server.Stats()
+	require.Equal(t, "server", clientStats.PeerContainerID)
+	require.Equal(t, "client", serverStats.PeerContainerID)
+	require.EqualValues(t, 10, clientStats.ChannelMax)
+	require.EqualValues(t, 10, serverStats.ChannelMax)
+	require.Equal(t, "vhost", server.hostname)
+
+	// closing the client closes the server-side connection
+	require.NoError(t, client.Close())
+	select {
+	case <-server.done:
+	case <-time.After(time.Second):
+		t.Fatal("server connection wasn't closed")
+	}
+}
+
+func TestListenerRejectsUnsupportedProtocol(t *testing.T) {
+	l := newTestListener(t, nil)
+
+	// the client asks for SASL which isn't supported
+	_, err := Dial("amqp://"+l.Addr().String(), &ConnOptions{
+		SASLType: SASLTypeAnonymous(),
+		Timeout:  time.Second,
+	})
+	require.ErrorContains(t, err, "unexpected protocol header")
+
+	// failed negotiation doesn't prevent accepting other connections
+	client, err := Dial("amqp://"+l.Addr().String(), nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	server, err := l.Accept(ctx)
+	cancel()
+	require.NoError(t, err)
+	require.NoError(t, server.Close())
+}
+
+func TestListenerNegotiationTimeout(t *testing.T) {
+	l := newTestListener(t, nil)
+	require.Equal(t, defaultListenerTimeout, l.opts.Timeout)
+
+	l = newTestListener(t, &ListenerOptions{ConnOptions: &ConnOptions{Timeout: 50 * time.Millisecond}})
+
+	// a client that sends nothing is disconnected
+	netConn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer netConn.Close()
+	require.NoError(t, netConn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = netConn.Read(make([]byte, 8))
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestListenerClose(t *testing.T) {
+	l := newTestListener(t, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	_, err := l.Accept(ctx)
+	cancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, l.Close())
+	_, err = l.Accept(context.Background())
+	require.True(t, errors.Is(err, net.ErrClosed), "unexpected error %v", err)
+}
+
+func TestNewListenerInvalidOptions(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	l, err := NewListener(ln, &ListenerOptions{ConnOptions: &ConnOptions{MaxFrameSize: 10}})
+	require.Error(t, err)
+	require.Nil(t, l)
+
+	l, err = NewListener(ln, &ListenerOptions{TLSConfig: &tls.Config{}})
+	require.EqualError(t, err, "amqp: ListenerOptions.TLSConfig doesn't contain a certificate")
+	require.Nil(t, l)
+
+	l, err = NewListener(ln, &ListenerOptions{SASLTypes: []SASLServerType{SASLServerExternal(nil)}})
+	require.Error(t, err)
+	require.Nil(t, l)
+}
+
+// newTestCertificate creates a certificate for commonName signed by parent,
+// or self-signed if parent is nil.
+func newTestCertificate(t *testing.T, commonName string, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	signer, signerKey := template, any(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestListenerTLSClientCertificate(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	authorized := make(chan PeerInfo, 1)
+	l := newTestListener(t, &ListenerOptions{
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{newTestCertificate(t, "server", &ca)},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    pool,
+		},
+		SASLTypes: []SASLServerType{SASLServerExternal(func(cert *x509.Certificate, identity string) error {
+			if cert.Subject.CommonName != "client" || identity == "nobody" {
+				return errors.New("not permitted")
+			}
+			return nil
+		})},
+		Authorize: func(info PeerInfo) error {
+			authorized <- info
+			return nil
+		},
+	})
+
+	client, err := Dial("amqps://"+l.Addr().String(), &ConnOptions{
+		SASLType: SASLTypeExternal("tenant"),
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{newTestCertificate(t, "client", &ca)},
+			RootCAs:      pool,
+		},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	server, err := l.Accept(ctx)
+	cancel()
+	require.NoError(t, err)
+	defer server.Close()
+
+	info := <-authorized
+	require.NotNil(t, info.Certificate)
+	require.Equal(t, "client", info.Certificate.Subject.CommonName)
+	require.Equal(t, "tenant", info.SASLIdentity)
+	require.Equal(t, client.net.LocalAddr().String(), info.RemoteAddr.String())
+	require.Equal(t, info.Certificate, server.PeerCertificate())
+	require.Equal(t, "server", client.PeerCertificate().Subject.CommonName)
+
+	// the identity is refused by validate
+	_, err = Dial("amqps://"+l.Addr().String(), &ConnOptions{
+		SASLType: SASLTypeExternal("nobody"),
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{newTestCertificate(t, "client", &ca)},
+			RootCAs:      pool,
+		},
+		Timeout: time.Second,
+	})
+	require.Error(t, err)
+
+	// clients without a certificate fail the handshake
+	_, err = Dial("amqps://"+l.Addr().String(), &ConnOptions{
+		TLSConfig: &tls.Config{RootCAs: pool},
+		Timeout:   time.Second,
+	})
+	require.Error(t, err)
+}
+
+func TestListenerAuthorizeRefuses(t *testing.T) {
+	l := newTestListener(t, &ListenerOptions{
+		SASLTypes: []SASLServerType{SASLServerPlain(func(string, string) error { return nil })},
+		Authorize: func(info PeerInfo) error {
+			if info.SASLIdentity != "admin" {
+				return errors.New("only admin is allowed")
+			}
+			return nil
+		},
+	})
+
+	client, err := Dial("amqp://"+l.Addr().String(), &ConnOptions{
+		SASLType: SASLTypePlain("guest", "guest"),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = client.NewSession(ctx, nil)
+	var connErr *ConnError
+	require.ErrorAs(t, err, &connErr)
+	require.NotNil(t, connErr.RemoteErr)
+	require.Equal(t, ErrCondUnauthorizedAccess, connErr.RemoteErr.Condition)
+	require.Equal(t, "only admin is allowed", connErr.RemoteErr.Description)
+
+	// refused connections aren't returned by Accept
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.Accept(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// flakyListener fails the first failures calls to Accept with err.
+type flakyListener struct {
+	net.Listener
+	failures int32
+	err      error
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if atomic.AddInt32(&l.failures, -1) >= 0 {
+		return nil, l.err
+	}
+	return l.Listener.Accept()
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+func TestListenerAcceptRetriesTemporaryErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := NewListener(&flakyListener{Listener: ln, failures: 3, err: temporaryError{}}, nil)
+	require.NoError(t, err)
+	defer l.Close()
+
+	client, err := Dial("amqp://"+l.Addr().String(), &ConnOptions{Timeout: time.Second})
+	require.NoError(t, err)
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	server, err := l.Accept(ctx)
+	cancel()
+	require.NoError(t, err)
+	require.NoError(t, server.Close())
+}
+
+func TestListenerAcceptStopsOnError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	acceptErr := errors.New("accept failed")
+	l, err := NewListener(&flakyListener{Listener: ln, failures: 1, err: acceptErr}, nil)
+	require.NoError(t, err)
+	defer l.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	_, err = l.Accept(ctx)
+	cancel()
+	require.ErrorIs(t, err, acceptErr)
+}
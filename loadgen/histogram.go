@@ -0,0 +1,166 @@
+package loadgen
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// subBuckets is the number of linear buckets each power of two range
+// is split into, which bounds the relative error of quantiles to 1/subBuckets.
+const (
+	subBucketBits = 4
+	subBuckets    = 1 << subBucketBits
+	bucketCount   = (64 - subBucketBits + 1) * subBuckets
+)
+
+// Histogram records the distribution of latencies with a bounded relative
+// error of about 6%, in constant memory. It's safe for concurrent use.
+type Histogram struct {
+	mu     sync.Mutex
+	counts [bucketCount]uint64
+	count  uint64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// NewHistogram creates an empty Histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// Record adds d to the histogram. Negative durations are recorded as zero.
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[bucketIndex(uint64(d))]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+}
+
+// Merge adds the latencies recorded by other to the histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	other.mu.Lock()
+	counts, count, sum, min, max := other.counts, other.count, other.sum, other.min, other.max
+	other.mu.Unlock()
+	if count == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, c := range counts {
+		h.counts[i] += c
+	}
+	if h.count == 0 || min < h.min {
+		h.min = min
+	}
+	if max > h.max {
+		h.max = max
+	}
+	h.count += count
+	h.sum += sum
+}
+
+// Count returns the number of recorded latencies.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Min returns the smallest recorded latency, or zero if none were recorded.
+func (h *Histogram) Min() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.min
+}
+
+// Max returns the largest recorded latency, or zero if none were recorded.
+func (h *Histogram) Max() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.max
+}
+
+// Mean returns the mean of the recorded latencies, or zero if none were recorded.
+func (h *Histogram) Mean() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Quantile returns the latency below which the fraction q of the recorded
+// latencies fall, e.g. 0.99 for the 99th percentile. q is clamped to [0, 1].
+// It returns zero if no latencies were recorded.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.count == 0 {
+		return 0
+	}
+	q = math.Max(0, math.Min(1, q))
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	if rank <= 1 {
+		return h.min
+	}
+
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			// the bucket's upper bound, within the recorded range
+			d := time.Duration(bucketUpperBound(i))
+			if d > h.max {
+				d = h.max
+			}
+			if d < h.min {
+				d = h.min
+			}
+			return d
+		}
+	}
+	return h.max
+}
+
+// String returns a one line summary of the distribution.
+func (h *Histogram) String() string {
+	return fmt.Sprintf("count=%d min=%v mean=%v p50=%v p90=%v p99=%v p99.9=%v max=%v",
+		h.Count(), h.Min(), h.Mean(), h.Quantile(0.5), h.Quantile(0.9), h.Quantile(0.99), h.Quantile(0.999), h.Max())
+}
+
+// bucketIndex returns the index of the bucket containing v. Values below
+// subBuckets have a bucket each. Larger values are grouped by power of two,
+// and each group is split into subBuckets linear buckets.
+func bucketIndex(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBucketBits - 1
+	return (shift+1)*subBuckets + int(v>>shift) - subBuckets
+}
+
+// bucketUpperBound returns the largest value in the bucket at index i.
+func bucketUpperBound(i int) uint64 {
+	if i < subBuckets {
+		return uint64(i)
+	}
+	shift := i/subBuckets - 1
+	lower := uint64(i%subBuckets+subBuckets) << shift
+	return lower + (1 << shift) - 1
+}
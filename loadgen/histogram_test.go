@@ -0,0 +1,73 @@
+package loadgen
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramEmpty(t *testing.T) {
+	h := NewHistogram()
+	require.Zero(t, h.Count())
+	require.Zero(t, h.Min())
+	require.Zero(t, h.Max())
+	require.Zero(t, h.Mean())
+	require.Zero(t, h.Quantile(0.5))
+}
+
+func TestHistogramQuantiles(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	require.EqualValues(t, 1000, h.Count())
+	require.Equal(t, time.Millisecond, h.Min())
+	require.Equal(t, time.Second, h.Max())
+	require.Equal(t, 500500*time.Microsecond, h.Mean())
+	require.Equal(t, time.Millisecond, h.Quantile(0))
+	require.Equal(t, time.Second, h.Quantile(1))
+
+	for _, q := range []float64{0.5, 0.9, 0.99, 0.999} {
+		want := time.Duration(q * float64(time.Second))
+		got := h.Quantile(q)
+		require.InEpsilon(t, want, got, 1.0/subBuckets, "quantile %v", q)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	for _, v := range []uint64{0, 1, 15, 16, 17, 31, 32, 33, 1000, 1 << 40, 1<<64 - 1} {
+		i := bucketIndex(v)
+		require.Less(t, i, bucketCount)
+		require.GreaterOrEqual(t, bucketUpperBound(i), v)
+		if i > 0 {
+			require.Less(t, bucketUpperBound(i-1), v)
+		}
+	}
+	for i := 0; i < 10000; i++ {
+		v := rand.Uint64() >> rand.Intn(64)
+		require.Equal(t, bucketIndex(v), bucketIndex(bucketUpperBound(bucketIndex(v))))
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	var wg sync.WaitGroup
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			a.Record(time.Duration(i))
+			b.Record(time.Duration(i * 10))
+		}(i)
+	}
+	wg.Wait()
+
+	a.Merge(b)
+	a.Merge(NewHistogram())
+	require.EqualValues(t, 200, a.Count())
+	require.Equal(t, time.Duration(1), a.Min())
+	require.Equal(t, time.Duration(1000), a.Max())
+}
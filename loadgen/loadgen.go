@@ -0,0 +1,413 @@
+// Package loadgen generates load against an AMQP 1.0 broker and measures
+// the resulting throughput and latencies, to soak-test brokers and
+// make performance regressions of this module measurable.
+//
+// A run attaches the configured numbers of senders and receivers to a node,
+// each on its own session of a shared connection. Senders send messages of
+// the configured size at the configured rate until the run's duration has
+// elapsed, and receivers accept them until all of them have been received or
+// the drain timeout expires.
+//
+// Latencies are recorded in Histograms: the time a Send call took, which
+// includes waiting for the broker to settle unsettled messages, and the time
+// from the start of a Send call to the receipt of the message.
+//
+//	res, err := loadgen.Run(ctx, "amqp://localhost:5672", "load", &loadgen.Options{
+//		Senders:   4,
+//		Receivers: 4,
+//		Rate:      1000,
+//		Duration:  time.Minute,
+//	})
+//	if err != nil {
+//		// handle error
+//	}
+//	res.WriteTo(os.Stdout)
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/internal/shared"
+)
+
+// Default run options
+const (
+	defaultMessageSize  = 1024
+	defaultDuration     = 10 * time.Second
+	defaultDrainTimeout = 5 * time.Second
+)
+
+// Application properties set on the messages sent by a run.
+const (
+	// runIDProperty identifies the run that sent a message, so receivers
+	// ignore the messages left in the node by previous runs.
+	runIDProperty = "loadgen-run-id"
+
+	// sentProperty is the time at which the message was sent, in Unix nanoseconds.
+	sentProperty = "loadgen-sent"
+)
+
+// Options contains the optional settings for configuring a run.
+type Options struct {
+	// ConnOptions configures the connection to the broker.
+	// Its Rand, when set, also generates the run ID.
+	//
+	// Default: nil.
+	ConnOptions *amqp.ConnOptions
+
+	// DrainTimeout is how long receivers keep receiving once the
+	// senders have stopped, waiting for the messages in flight.
+	//
+	// Default: 5 seconds.
+	DrainTimeout time.Duration
+
+	// Duration is how long senders send messages.
+	//
+	// Default: 10 seconds.
+	Duration time.Duration
+
+	// MessageSize is the size of the data section of each message, in bytes.
+	//
+	// Default: 1024.
+	MessageSize int
+
+	// Rate is the total number of messages sent per second, spread evenly
+	// across the senders. A rate of 0 sends messages as fast as possible.
+	//
+	// Default: 0.
+	Rate float64
+
+	// Receivers is the number of receivers attached to the node.
+	// Set it to a negative value to only send messages.
+	//
+	// Default: 1.
+	Receivers int
+
+	// ReceiverOptions configures each receiver.
+	//
+	// Default: nil.
+	ReceiverOptions *amqp.ReceiverOptions
+
+	// Senders is the number of senders attached to the node.
+	//
+	// Default: 1.
+	Senders int
+
+	// SenderOptions configures each sender.
+	//
+	// Default: nil.
+	SenderOptions *amqp.SenderOptions
+}
+
+// Result contains the measurements of a run.
+type Result struct {
+	// Sent is the number of messages successfully sent.
+	Sent uint64
+
+	// Received is the number of messages sent by the run that were received.
+	Received uint64
+
+	// SendErrors is the number of Send calls that failed.
+	SendErrors uint64
+
+	// ReceiveErrors is the number of failures to receive or accept a message.
+	// A receiver stops at its first error.
+	ReceiveErrors uint64
+
+	// Duration is how long the senders sent messages.
+	Duration time.Duration
+
+	// SendLatency is the distribution of the durations of successful Send calls.
+	SendLatency *Histogram
+
+	// EndToEndLatency is the distribution of the durations from the start
+	// of the Send call of a message to its receipt.
+	EndToEndLatency *Histogram
+
+	// Err is the first error encountered by a sender or receiver, if any.
+	Err error
+}
+
+// SendRate returns the number of messages sent per second.
+func (r *Result) SendRate() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Sent) / r.Duration.Seconds()
+}
+
+// WriteTo writes a summary of the result to w.
+func (r *Result) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "duration:  %v\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&sb, "sent:      %d (%.1f msg/s, %d errors)\n", r.Sent, r.SendRate(), r.SendErrors)
+	fmt.Fprintf(&sb, "received:  %d (%d errors)\n", r.Received, r.ReceiveErrors)
+	fmt.Fprintf(&sb, "send:      %v\n", r.SendLatency)
+	fmt.Fprintf(&sb, "end-to-end: %v\n", r.EndToEndLatency)
+	if r.Err != nil {
+		fmt.Fprintf(&sb, "error:     %v\n", r.Err)
+	}
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// Run generates load against the node at address of the broker at url,
+// which is passed to amqp.Dial.
+//
+// It returns an error if the connection can't be established or a link
+// can't be attached. Errors encountered while sending and receiving are
+// counted in the Result instead.
+//
+// opts: pass nil to accept the default values.
+func Run(ctx context.Context, url, address string, opts *Options) (*Result, error) {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.DrainTimeout <= 0 {
+		o.DrainTimeout = defaultDrainTimeout
+	}
+	if o.Duration <= 0 {
+		o.Duration = defaultDuration
+	}
+	if o.MessageSize <= 0 {
+		o.MessageSize = defaultMessageSize
+	}
+	if o.Receivers == 0 {
+		o.Receivers = 1
+	}
+	if o.Senders <= 0 {
+		o.Senders = 1
+	}
+	if o.Rate < 0 {
+		return nil, errors.New("loadgen: Rate must not be negative")
+	}
+
+	runID := shared.RandString(16)
+	if o.ConnOptions != nil && o.ConnOptions.Rand != nil {
+		var err error
+		runID, err = shared.RandStringFrom(o.ConnOptions.Rand, 16)
+		if err != nil {
+			return nil, fmt.Errorf("loadgen: generating run ID: %w", err)
+		}
+	}
+
+	conn, err := amqp.Dial(url, o.ConnOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	g := &generator{
+		runID:       runID,
+		opts:        &o,
+		sendLatency: NewHistogram(),
+		e2eLatency:  NewHistogram(),
+		done:        make(chan struct{}),
+		drainedCh:   make(chan struct{}),
+	}
+
+	var receivers []*amqp.Receiver
+	for i := 0; i < o.Receivers; i++ {
+		session, err := conn.NewSession(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		rcv, err := session.NewReceiver(ctx, address, o.ReceiverOptions)
+		if err != nil {
+			return nil, err
+		}
+		receivers = append(receivers, rcv)
+	}
+	var senders []*amqp.Sender
+	for i := 0; i < o.Senders; i++ {
+		session, err := conn.NewSession(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		snd, err := session.NewSender(ctx, address, o.SenderOptions)
+		if err != nil {
+			return nil, err
+		}
+		senders = append(senders, snd)
+	}
+
+	// receivers stop once all the sent messages have been received,
+	// or the drain timeout expires after the senders have stopped
+	rcvCtx, cancelReceivers := context.WithCancel(ctx)
+	defer cancelReceivers()
+	var rcvWG sync.WaitGroup
+	for _, rcv := range receivers {
+		rcvWG.Add(1)
+		go func(rcv *amqp.Receiver) {
+			defer rcvWG.Done()
+			g.receive(rcvCtx, rcv)
+		}(rcv)
+	}
+
+	sndCtx, cancelSenders := context.WithTimeout(ctx, o.Duration)
+	defer cancelSenders()
+	var interval time.Duration
+	if o.Rate > 0 {
+		interval = time.Duration(float64(o.Senders) / o.Rate * float64(time.Second))
+	}
+	start := time.Now()
+	var sndWG sync.WaitGroup
+	for _, snd := range senders {
+		sndWG.Add(1)
+		go func(snd *amqp.Sender) {
+			defer sndWG.Done()
+			g.send(sndCtx, snd, interval)
+		}(snd)
+	}
+	sndWG.Wait()
+	duration := time.Since(start)
+
+	if len(receivers) > 0 {
+		close(g.done)
+		g.checkDrained()
+		select {
+		case <-g.drainedCh:
+		case <-time.After(o.DrainTimeout):
+		case <-ctx.Done():
+		}
+	}
+	cancelReceivers()
+	rcvWG.Wait()
+
+	return &Result{
+		Sent:            atomic.LoadUint64(&g.sent),
+		Received:        atomic.LoadUint64(&g.received),
+		SendErrors:      atomic.LoadUint64(&g.sendErrors),
+		ReceiveErrors:   atomic.LoadUint64(&g.receiveErrors),
+		Duration:        duration,
+		SendLatency:     g.sendLatency,
+		EndToEndLatency: g.e2eLatency,
+		Err:             g.firstErr(),
+	}, nil
+}
+
+// generator holds the state shared by the senders and receivers of a run.
+type generator struct {
+	runID       string
+	opts        *Options
+	sendLatency *Histogram
+	e2eLatency  *Histogram
+
+	sent          uint64 // atomic
+	received      uint64 // atomic
+	sendErrors    uint64 // atomic
+	receiveErrors uint64 // atomic
+
+	done      chan struct{} // closed once the senders have stopped
+	drainedCh chan struct{} // closed once the senders have stopped and all sent messages have been received
+	drainOnce sync.Once
+
+	mu  sync.Mutex
+	err error
+}
+
+// send sends messages on snd every interval until ctx is done.
+func (g *generator) send(ctx context.Context, snd *amqp.Sender, interval time.Duration) {
+	data := make([]byte, g.opts.MessageSize)
+	next := time.Now()
+	for {
+		if interval > 0 {
+			if wait := time.Until(next); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return
+				}
+			}
+			next = next.Add(interval)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		start := time.Now()
+		msg := amqp.NewMessage(data)
+		msg.ApplicationProperties = map[string]any{
+			runIDProperty: g.runID,
+			sentProperty:  start.UnixNano(),
+		}
+		if err := snd.Send(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				// the run is over
+				return
+			}
+			atomic.AddUint64(&g.sendErrors, 1)
+			g.setErr(fmt.Errorf("sending: %w", err))
+			continue
+		}
+		g.sendLatency.Record(time.Since(start))
+		atomic.AddUint64(&g.sent, 1)
+	}
+}
+
+// receive receives and accepts messages from rcv until ctx is done or an error occurs.
+func (g *generator) receive(ctx context.Context, rcv *amqp.Receiver) {
+	for {
+		msg, err := rcv.Receive(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				atomic.AddUint64(&g.receiveErrors, 1)
+				g.setErr(fmt.Errorf("receiving: %w", err))
+			}
+			return
+		}
+		now := time.Now()
+		if err := rcv.AcceptMessage(ctx, msg); err != nil {
+			if ctx.Err() == nil {
+				atomic.AddUint64(&g.receiveErrors, 1)
+				g.setErr(fmt.Errorf("accepting: %w", err))
+			}
+			return
+		}
+		if msg.ApplicationProperties[runIDProperty] != g.runID {
+			continue
+		}
+		if sent, ok := msg.ApplicationProperties[sentProperty].(int64); ok {
+			g.e2eLatency.Record(now.Sub(time.Unix(0, sent)))
+		}
+		atomic.AddUint64(&g.received, 1)
+		g.checkDrained()
+	}
+}
+
+// checkDrained closes drainedCh once the senders have stopped
+// and all the messages they sent have been received.
+func (g *generator) checkDrained() {
+	select {
+	case <-g.done:
+	default:
+		return
+	}
+	if atomic.LoadUint64(&g.received) >= atomic.LoadUint64(&g.sent) {
+		g.drainOnce.Do(func() { close(g.drainedCh) })
+	}
+}
+
+// setErr records err if it's the first error of the run.
+func (g *generator) setErr(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err == nil {
+		g.err = err
+	}
+}
+
+func (g *generator) firstErr() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
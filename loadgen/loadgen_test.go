@@ -0,0 +1,97 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBroker(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := amqp.NewListener(ln, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- broker.New(&broker.Options{AutoCreateQueues: true}).Serve(ctx, l) }()
+	t.Cleanup(func() {
+		cancel()
+		<-served
+		_ = l.Close()
+	})
+	return "amqp://" + l.Addr().String()
+}
+
+func TestRun(t *testing.T) {
+	url := newTestBroker(t)
+
+	res, err := Run(context.Background(), url, "load", &Options{
+		Duration:    200 * time.Millisecond,
+		MessageSize: 100,
+		Rate:        200,
+		Receivers:   2,
+		Senders:     2,
+	})
+	require.NoError(t, err)
+	require.NoError(t, res.Err)
+	require.Zero(t, res.SendErrors)
+	require.Zero(t, res.ReceiveErrors)
+
+	// 200 msg/s for 200ms, with some slack for the pacing of the senders
+	require.InDelta(t, 40, res.Sent, 6)
+	require.Equal(t, res.Sent, res.Received)
+	require.Equal(t, res.Sent, res.SendLatency.Count())
+	require.Equal(t, res.Received, res.EndToEndLatency.Count())
+	require.InDelta(t, 200, res.SendRate(), 40)
+
+	var sb strings.Builder
+	_, err = res.WriteTo(&sb)
+	require.NoError(t, err)
+	require.Contains(t, sb.String(), "received:  ")
+}
+
+func TestRunSendOnly(t *testing.T) {
+	url := newTestBroker(t)
+
+	res, err := Run(context.Background(), url, "load", &Options{
+		Duration:  50 * time.Millisecond,
+		Receivers: -1,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, res.Sent)
+	require.Zero(t, res.Received)
+
+	// a subsequent run ignores the messages left by the previous one
+	res, err = Run(context.Background(), url, "load", &Options{
+		Duration: 50 * time.Millisecond,
+		Rate:     100,
+	})
+	require.NoError(t, err)
+	require.Equal(t, res.Sent, res.Received)
+}
+
+func TestRunErrors(t *testing.T) {
+	url := newTestBroker(t)
+
+	_, err := Run(context.Background(), url, "load", &Options{Rate: -1})
+	require.Error(t, err)
+
+	_, err = Run(context.Background(), url, "$management", &Options{
+		Duration: 50 * time.Millisecond,
+	})
+	require.Error(t, err)
+
+	_, err = Run(context.Background(), url, "load", &Options{
+		ConnOptions: &amqp.ConnOptions{Rand: iotest.ErrReader(errors.New("no entropy"))},
+	})
+	require.ErrorContains(t, err, "generating run ID: no entropy")
+}
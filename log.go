@@ -0,0 +1,23 @@
+package amqp
+
+// Logger receives diagnostic messages about connection, session, and link
+// lifecycle events and errors.
+//
+// args contains alternating key/value pairs. The method set matches
+// *slog.Logger so one can be used directly.
+//
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// nopLogger is the Logger used when ConnOptions.Logger isn't set.
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
@@ -0,0 +1,432 @@
+// Package management implements a client for management nodes following the
+// AMQP Management specification (working draft 16), used to create, query,
+// and delete the entities of brokers such as Artemis, Qpid, and Azure
+// Service Bus.
+//
+// Requests carry the operation, the type of the entity they apply to, and
+// the locales of the client in their application properties. Responses carry
+// an HTTP-like status code, and status codes outside the 2xx range are
+// returned as a *StatusError.
+//
+//	client, err := management.NewClient(ctx, session, nil)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer client.Close(ctx)
+//	attrs, err := client.Create(ctx, "queue", "orders", nil)
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+// Address is the address of the management node of a container.
+const Address = "$management"
+
+// Operations defined by the AMQP Management specification.
+const (
+	OperationCreate        = "CREATE"
+	OperationRead          = "READ"
+	OperationUpdate        = "UPDATE"
+	OperationDelete        = "DELETE"
+	OperationQuery         = "QUERY"
+	OperationGetTypes      = "GET-TYPES"
+	OperationGetAttributes = "GET-ATTRIBUTES"
+	OperationGetOperations = "GET-OPERATIONS"
+	OperationGetMgmtNodes  = "GET-MGMT-NODES"
+)
+
+// ClientOptions contains the optional settings for configuring a Client.
+type ClientOptions struct {
+	// Address is the address of the management node.
+	//
+	// Default: Address.
+	Address string
+
+	// Locales is the list of locales, in order of preference, that
+	// responses should use, sent in the "locales" application property.
+	//
+	// Default: empty, the node's default locale is used.
+	Locales []string
+
+	// ReplyAddress is the target address of the link responses are received on.
+	//
+	// Default: a randomly generated address.
+	ReplyAddress string
+
+	// Timeout is the maximum time a request waits for its response, in
+	// addition to the deadline of the context passed to it.
+	//
+	// Default: 0 (no timeout).
+	Timeout time.Duration
+}
+
+// Request is a request sent to a management node.
+type Request struct {
+	// Operation is the operation to perform, such as OperationCreate.
+	Operation string
+
+	// Type is the type of the entity the operation applies to.
+	// It's required by the entity operations, and optional otherwise.
+	Type string
+
+	// Name is the name of the entity the operation applies to.
+	Name string
+
+	// Identity is the identity of the entity the operation applies to.
+	// It's an alternative to Name for the READ, UPDATE, and DELETE operations.
+	Identity string
+
+	// Properties are the additional application properties of the request,
+	// such as "entityType" for OperationQuery, or vendor-specific arguments.
+	Properties map[string]any
+
+	// Body is the value of the body of the request, such as the attributes
+	// of the entity to create. When nil, an empty map is sent.
+	Body any
+}
+
+// Response is the response of a management node.
+type Response struct {
+	// StatusCode is the HTTP-like status code of the response.
+	StatusCode int
+
+	// StatusDescription is the description of the status, if any.
+	StatusDescription string
+
+	// Message is the response message.
+	Message *amqp.Message
+}
+
+// StatusError is returned when a management node responds
+// with a status code outside the 2xx range.
+type StatusError struct {
+	// StatusCode is the status code of the response.
+	StatusCode int
+
+	// Description is the status description of the response, if any.
+	Description string
+
+	// Response is the response message.
+	Response *amqp.Message
+}
+
+// Error implements the error interface for StatusError.
+func (e *StatusError) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("management: status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("management: status %d: %s", e.StatusCode, e.Description)
+}
+
+// Client sends requests to a management node.
+//
+// A Client is safe for concurrent use by multiple goroutines.
+type Client struct {
+	rpc     *amqp.RPCClient
+	locales string
+}
+
+// NewClient attaches the links of a Client to the management node on session.
+//
+// opts: pass nil to accept the default values.
+func NewClient(ctx context.Context, session *amqp.Session, opts *ClientOptions) (*Client, error) {
+	var o ClientOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Address == "" {
+		o.Address = Address
+	}
+	rpc, err := session.NewRPCClient(ctx, o.Address, &amqp.RPCClientOptions{
+		ReplyAddress:      o.ReplyAddress,
+		ReplyToLinkTarget: true,
+		Timeout:           o.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{rpc: rpc}
+	for i, locale := range o.Locales {
+		if i > 0 {
+			c.locales += " "
+		}
+		c.locales += locale
+	}
+	return c, nil
+}
+
+// Close detaches the links of the client.
+func (c *Client) Close(ctx context.Context) error {
+	return c.rpc.Close(ctx)
+}
+
+// Do sends req and returns the response of the management node.
+// If the status code of the response is outside the 2xx range,
+// the response is returned along with a *StatusError.
+func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
+	if req.Operation == "" {
+		return nil, errors.New("management: missing operation")
+	}
+	props := make(map[string]any, len(req.Properties)+5)
+	for k, v := range req.Properties {
+		props[k] = v
+	}
+	props["operation"] = req.Operation
+	if req.Type != "" {
+		props["type"] = req.Type
+	}
+	if req.Name != "" {
+		props["name"] = req.Name
+	}
+	if req.Identity != "" {
+		props["identity"] = req.Identity
+	}
+	if c.locales != "" {
+		props["locales"] = c.locales
+	}
+	msg := &amqp.Message{
+		ApplicationProperties: props,
+		Value:                 req.Body,
+	}
+	if m, ok := msg.Value.(map[string]any); msg.Value == nil || (ok && m == nil) {
+		msg.Value = map[string]any{}
+	}
+
+	respMsg, err := c.rpc.Call(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	resp := &Response{Message: respMsg}
+	var ok bool
+	if resp.StatusCode, ok = statusCode(respMsg); !ok {
+		return nil, errors.New("management: response is missing its status code")
+	}
+	resp.StatusDescription = statusDescription(respMsg)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, &StatusError{
+			StatusCode:  resp.StatusCode,
+			Description: resp.StatusDescription,
+			Response:    respMsg,
+		}
+	}
+	return resp, nil
+}
+
+// Create creates the entity of type typ named name, with the specified
+// attributes, and returns the attributes of the created entity.
+func (c *Client) Create(ctx context.Context, typ, name string, attributes map[string]any) (map[string]any, error) {
+	return c.entityOperation(ctx, &Request{
+		Operation: OperationCreate,
+		Type:      typ,
+		Name:      name,
+		Body:      attributes,
+	})
+}
+
+// Read returns the attributes of the entity of type typ named name.
+func (c *Client) Read(ctx context.Context, typ, name string) (map[string]any, error) {
+	return c.entityOperation(ctx, &Request{
+		Operation: OperationRead,
+		Type:      typ,
+		Name:      name,
+	})
+}
+
+// Update updates the attributes of the entity of type typ named name,
+// and returns the attributes of the updated entity.
+func (c *Client) Update(ctx context.Context, typ, name string, attributes map[string]any) (map[string]any, error) {
+	return c.entityOperation(ctx, &Request{
+		Operation: OperationUpdate,
+		Type:      typ,
+		Name:      name,
+		Body:      attributes,
+	})
+}
+
+// Delete deletes the entity of type typ named name.
+func (c *Client) Delete(ctx context.Context, typ, name string) error {
+	_, err := c.Do(ctx, &Request{
+		Operation: OperationDelete,
+		Type:      typ,
+		Name:      name,
+	})
+	return err
+}
+
+// QueryResult is the result of a QUERY operation.
+type QueryResult struct {
+	// AttributeNames are the names of the attributes returned for each entity.
+	AttributeNames []string
+
+	// Results contains the values of the attributes of each entity,
+	// in the order of AttributeNames.
+	Results [][]any
+}
+
+// Query returns the values of the attributes named attributeNames of the
+// entities of type entityType, or of every type if entityType is empty.
+// If attributeNames is empty, the node chooses the attributes returned.
+func (c *Client) Query(ctx context.Context, entityType string, attributeNames []string) (*QueryResult, error) {
+	if attributeNames == nil {
+		attributeNames = []string{}
+	}
+	req := &Request{
+		Operation: OperationQuery,
+		Body:      map[string]any{"attributeNames": attributeNames},
+	}
+	if entityType != "" {
+		req.Properties = map[string]any{"entityType": entityType}
+	}
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	body := stringMap(resp.Message.Value)
+	if body == nil {
+		return nil, fmt.Errorf("management: unexpected QUERY response body %T", resp.Message.Value)
+	}
+	res := &QueryResult{}
+	if res.AttributeNames, err = stringList(body["attributeNames"]); err != nil {
+		return nil, err
+	}
+	rows, ok := body["results"].([]any)
+	if !ok && body["results"] != nil {
+		return nil, fmt.Errorf("management: unexpected QUERY results %T", body["results"])
+	}
+	for _, row := range rows {
+		values, ok := row.([]any)
+		if !ok {
+			return nil, fmt.Errorf("management: unexpected QUERY result %T", row)
+		}
+		res.Results = append(res.Results, values)
+	}
+	return res, nil
+}
+
+// GetTypes returns the entity types of the node, mapped to the
+// types they extend, restricted to those extending entityType
+// unless it's empty.
+func (c *Client) GetTypes(ctx context.Context, entityType string) (map[string][]string, error) {
+	req := &Request{Operation: OperationGetTypes}
+	if entityType != "" {
+		req.Properties = map[string]any{"entityType": entityType}
+	}
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	body := stringMap(resp.Message.Value)
+	if body == nil {
+		return nil, fmt.Errorf("management: unexpected GET-TYPES response body %T", resp.Message.Value)
+	}
+	types := make(map[string][]string, len(body))
+	for typ, v := range body {
+		if types[typ], err = stringList(v); err != nil {
+			return nil, err
+		}
+	}
+	return types, nil
+}
+
+// entityOperation sends req and returns the attributes of the entity in the response body.
+func (c *Client) entityOperation(ctx context.Context, req *Request) (map[string]any, error) {
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Message.Value == nil {
+		return nil, nil
+	}
+	attrs := stringMap(resp.Message.Value)
+	if attrs == nil {
+		return nil, fmt.Errorf("management: unexpected %s response body %T", req.Operation, resp.Message.Value)
+	}
+	return attrs, nil
+}
+
+// statusCode returns the status code of resp. Azure services use
+// "status-code" instead of the "statusCode" of the specification.
+func statusCode(resp *amqp.Message) (int, bool) {
+	v, ok := resp.ApplicationProperties["statusCode"]
+	if !ok {
+		v, ok = resp.ApplicationProperties["status-code"]
+	}
+	if !ok {
+		return 0, false
+	}
+	switch v := v.(type) {
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case int:
+		return v, true
+	case uint32:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	case int16:
+		return int(v), true
+	case uint16:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
+// statusDescription returns the status description of resp, if any.
+func statusDescription(resp *amqp.Message) string {
+	v, ok := resp.ApplicationProperties["statusDescription"]
+	if !ok {
+		v = resp.ApplicationProperties["status-description"]
+	}
+	s, _ := v.(string)
+	return s
+}
+
+// stringMap returns v as a map with string keys, or nil if it isn't a map.
+// Empty maps are decoded as map[any]any.
+func stringMap(v any) map[string]any {
+	switch v := v.(type) {
+	case map[string]any:
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, v := range v {
+			s, ok := k.(string)
+			if !ok {
+				return nil
+			}
+			m[s] = v
+		}
+		return m
+	}
+	return nil
+}
+
+// stringList returns v as a list of strings.
+func stringList(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []any:
+		list := make([]string, len(v))
+		for i, s := range v {
+			var ok bool
+			if list[i], ok = s.(string); !ok {
+				return nil, fmt.Errorf("management: expected a list of strings, got %T", s)
+			}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("management: expected a list of strings, got %T", v)
+}
@@ -0,0 +1,69 @@
+package management
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) *Client {
+	srv, err := broker.NewServer(broker.New(nil), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+
+	conn, err := amqp.Dial(srv.URL, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	client, err := NewClient(ctx, session, &ClientOptions{Locales: []string{"en-US", "fr"}})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close(context.Background()) })
+	return client
+}
+
+func TestClient(t *testing.T) {
+	client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	attrs, err := client.Create(ctx, broker.ManagementTypeQueue, "q", nil)
+	require.NoError(t, err)
+	require.Equal(t, "q", attrs["name"])
+
+	_, err = client.Create(ctx, broker.ManagementTypeQueue, "q", nil)
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusConflict, statusErr.StatusCode)
+
+	attrs, err = client.Read(ctx, broker.ManagementTypeQueue, "q")
+	require.NoError(t, err)
+	require.EqualValues(t, 0, attrs["messageCount"])
+
+	res, err := client.Query(ctx, broker.ManagementTypeQueue, []string{"name", "messageCount"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"name", "messageCount"}, res.AttributeNames)
+	require.Equal(t, [][]any{{"q", int64(0)}}, res.Results)
+
+	types, err := client.GetTypes(ctx, "")
+	require.NoError(t, err)
+	require.Contains(t, types, broker.ManagementTypeQueue)
+	require.Contains(t, types, broker.ManagementTypeTopic)
+
+	require.NoError(t, client.Delete(ctx, broker.ManagementTypeQueue, "q"))
+	_, err = client.Read(ctx, broker.ManagementTypeQueue, "q")
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+
+	resp, err := client.Do(ctx, &Request{Operation: "PURGE"})
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+}
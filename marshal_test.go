@@ -42,6 +42,37 @@ var exampleFrames = []struct {
 			},
 		},
 	},
+	{
+		label: "flow",
+		frame: frames.Frame{
+			Type:    frames.TypeAMQP,
+			Channel: 10,
+			Body: &frames.PerformFlow{
+				NextIncomingID: uint32Ptr(12),
+				IncomingWindow: 5000,
+				NextOutgoingID: 34,
+				OutgoingWindow: 5000,
+				Handle:         uint32Ptr(1),
+				DeliveryCount:  uint32Ptr(56),
+				LinkCredit:     uint32Ptr(100),
+				Drain:          true,
+			},
+		},
+	},
+	{
+		label: "disposition",
+		frame: frames.Frame{
+			Type:    frames.TypeAMQP,
+			Channel: 10,
+			Body: &frames.PerformDisposition{
+				Role:    encoding.RoleReceiver,
+				First:   564,
+				Last:    uint32Ptr(570),
+				Settled: true,
+				State:   &encoding.StateAccepted{},
+			},
+		},
+	},
 }
 
 func TestFrameMarshalUnmarshal(t *testing.T) {
@@ -216,6 +247,28 @@ func TestMarshalUnmarshal(t *testing.T) {
 	}
 }
 
+func TestUnmarshalRequiredFields(t *testing.T) {
+	tests := []struct {
+		type_ encoding.AMQPType
+		body  any
+		want  string
+	}{
+		{type_: encoding.TypeCodeFlow, body: &frames.PerformFlow{}, want: "Flow.IncomingWindow is required"},
+		{type_: encoding.TypeCodeTransfer, body: &frames.PerformTransfer{}, want: "Transfer.Handle is required"},
+		{type_: encoding.TypeCodeDisposition, body: &frames.PerformDisposition{}, want: "Disposition.Role is required"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T", tt.body), func(t *testing.T) {
+			// a composite of the type without any field
+			buf := buffer.New([]byte{0x0, byte(encoding.TypeCodeSmallUlong), byte(tt.type_), byte(encoding.TypeCodeList0)})
+			err := encoding.Unmarshal(buf, tt.body)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("expected error %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
 // Regression test for time calculation bug.
 // https://github.com/vcabbage/amqp/issues/173
 func TestIssue173(t *testing.T) {
@@ -337,9 +390,22 @@ var (
 				"fooProp": int32(45),
 			},
 		},
+		&frames.PerformAttach{
+			Name:   "txnController",
+			Handle: 7,
+			Role:   encoding.RoleSender,
+			Source: &frames.Source{
+				ExpiryPolicy: ExpiryPolicySessionEnd,
+				Outcomes:     []encoding.Symbol{"amqp:accepted:list"},
+			},
+			Coordinator: &frames.Coordinator{
+				Capabilities: []encoding.Symbol{"amqp:local-transactions"},
+			},
+		},
 		encoding.Role(true),
 		&encoding.Unsettled{
 			"fooDeliveryTag": &encoding.StateAccepted{},
+			"barDeliveryTag": nil,
 		},
 		&frames.Source{
 			Address:      "fooAddr",
@@ -591,6 +657,21 @@ var (
 				"more": "annotations",
 			},
 		},
+		&encoding.StateDeclared{
+			TxnID: []byte("txn-1"),
+		},
+		&encoding.StateTransactional{
+			TxnID:   []byte("txn-1"),
+			Outcome: &encoding.StateAccepted{},
+		},
+		&encoding.Declare{},
+		&encoding.Discharge{
+			TxnID: []byte("txn-1"),
+			Fail:  true,
+		},
+		&frames.Coordinator{
+			Capabilities: []encoding.Symbol{"amqp:local-transactions"},
+		},
 		encoding.LifetimePolicy(encoding.TypeCodeDeleteOnClose),
 		SenderSettleMode(1),
 		ReceiverSettleMode(1),
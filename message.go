@@ -1,7 +1,11 @@
 package amqp
 
 import (
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
+	"reflect"
 	"time"
 
 	"github.com/Azure/go-amqp/internal/buffer"
@@ -15,10 +19,16 @@ type Message struct {
 	// The upper three octets of a message format code identify a particular message
 	// format. The lowest octet indicates the version of said message format. Any
 	// given version of a format is forwards compatible with all higher versions.
+	//
+	// The default, 0, is the standard AMQP message format. Messages in other
+	// formats are sent and received via RawPayload.
 	Format uint32
 
 	// The DeliveryTag can be up to 32 octets of binary data.
 	// Note that when mode one is enabled there will be no delivery tag.
+	//
+	// On received messages, it's the tag the sender assigned to the
+	// delivery, which identifies it for Receiver.SettleByTag.
 	DeliveryTag []byte
 
 	// The header section carries standard delivery details about the transfer
@@ -99,6 +109,17 @@ type Message struct {
 	// encryption details).
 	Footer Annotations
 
+	// RawPayload contains the encoded message.
+	//
+	// When set on a message being sent, RawPayload is sent as-is and all
+	// other sections are ignored. This allows sending messages in formats
+	// other than the standard format (see Format).
+	//
+	// On received messages with a non-zero Format, RawPayload is populated
+	// and the sections are not decoded. If the format's encoding is compatible
+	// with the standard format, UnmarshalBinary can be used to decode it.
+	RawPayload []byte
+
 	// Mark the message as settled when LinkSenderSettle is ModeMixed.
 	//
 	// This field is ignored when LinkSenderSettle is not ModeMixed.
@@ -106,7 +127,13 @@ type Message struct {
 
 	rcvr       *Receiver // the receiving link
 	deliveryID uint32    // used when sending disposition
+	tag        []byte    // the delivery tag of the received transfer
 	settled    bool      // whether transfer was settled by sender
+	txnID      []byte    // the transaction the transfer was sent in, or is being sent or settled in, if any
+	buf        []byte    // storage referenced by the message when decoded with ReceiverOptions.ZeroCopy
+	pooled     bool      // the message is returned to messagePool by Release
+
+	chargedBytes int64 // bytes held in the session's budget while in the prefetch queue
 }
 
 // NewMessage returns a *Message with data as the payload.
@@ -129,6 +156,33 @@ func (m *Message) GetData() []byte {
 	return m.Data[0]
 }
 
+// TransactionID returns the ID of the transaction the message was sent in,
+// or nil if it wasn't sent in a transaction.
+//
+// The dispositions of messages sent in a transaction are sent as
+// part of the transaction. It's only set on received messages.
+func (m *Message) TransactionID() []byte {
+	return m.txnID
+}
+
+// DeliveryID returns the delivery ID of the transfer the message was
+// received in, which identifies the delivery within its session. The
+// boolean result is false if the message wasn't received.
+func (m *Message) DeliveryID() (uint32, bool) {
+	return m.deliveryID, m.rcvr != nil
+}
+
+// ReceivedDeliveryTag returns a copy of the delivery tag of the transfer
+// the message was received in, or nil if it wasn't received. DeliveryTag
+// is initially the same tag, but it's the application's to modify or reuse
+// for sending the message, while the received tag is kept unchanged.
+func (m *Message) ReceivedDeliveryTag() []byte {
+	if m.tag == nil {
+		return nil
+	}
+	return append([]byte(nil), m.tag...)
+}
+
 // LinkName returns the receiving link name or the empty string.
 func (m *Message) LinkName() string {
 	if m.rcvr != nil {
@@ -137,6 +191,215 @@ func (m *Message) LinkName() string {
 	return ""
 }
 
+// Release makes the memory referenced by the message's Data sections and
+// binary values available for reuse by receivers.
+//
+// It only has an effect on messages received on a link with ReceiverOptions.ZeroCopy
+// or ReceiverOptions.PooledMessages enabled. After calling Release, the contents of the
+// message's Data sections and any binary values MUST NOT be accessed. Messages received
+// with PooledMessages enabled MUST NOT be accessed at all, and must be settled before
+// being released. Calling Release is optional; memory that is not released is reclaimed
+// by the garbage collector.
+func (m *Message) Release() {
+	if m.buf != nil {
+		buf := m.buf[:0]
+		m.buf = nil
+		m.Data = nil
+		zeroCopyPool.Put(&buf)
+	}
+	if m.pooled {
+		*m = Message{}
+		messagePool.Put(m)
+	}
+}
+
+// SetTTL sets the message's time-to-live.
+//
+// Header.TTL is set to ttl and Properties.AbsoluteExpiryTime is set to
+// ttl past Properties.CreationTime, or past the current time when no
+// creation time has been set. A ttl of zero clears both values.
+func (m *Message) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		if m.Header != nil {
+			m.Header.TTL = 0
+		}
+		if m.Properties != nil {
+			m.Properties.AbsoluteExpiryTime = nil
+		}
+		return
+	}
+
+	if m.Header == nil {
+		m.Header = &MessageHeader{Priority: 4}
+	}
+	m.Header.TTL = ttl
+
+	if m.Properties == nil {
+		m.Properties = &MessageProperties{}
+	}
+	start := time.Now()
+	if m.Properties.CreationTime != nil {
+		start = *m.Properties.CreationTime
+	}
+	expiry := start.Add(ttl)
+	m.Properties.AbsoluteExpiryTime = &expiry
+}
+
+// ExpiryTime returns the absolute time when the message expires.
+// The boolean result is false if the message doesn't expire.
+//
+// Properties.AbsoluteExpiryTime takes precedence. Otherwise, the expiry is
+// computed from Header.TTL and Properties.CreationTime when both are set.
+func (m *Message) ExpiryTime() (time.Time, bool) {
+	if m.Properties != nil && m.Properties.AbsoluteExpiryTime != nil && !m.Properties.AbsoluteExpiryTime.IsZero() {
+		return *m.Properties.AbsoluteExpiryTime, true
+	}
+	if m.Header != nil && m.Header.TTL > 0 && m.Properties != nil && m.Properties.CreationTime != nil {
+		return m.Properties.CreationTime.Add(m.Header.TTL), true
+	}
+	return time.Time{}, false
+}
+
+// Expired returns true if the message's expiry time is at or before now.
+func (m *Message) Expired(now time.Time) bool {
+	expiry, ok := m.ExpiryTime()
+	return ok && !now.Before(expiry)
+}
+
+// Message annotations scheduling the delivery of a message.
+const (
+	// read by Azure Service Bus, as a timestamp
+	annotationScheduledEnqueueTime = "x-opt-scheduled-enqueue-time"
+
+	// read by ActiveMQ Artemis, in milliseconds since the Unix epoch
+	annotationDeliveryTime = "x-opt-delivery-time"
+)
+
+// SetScheduledTime schedules the message to be delivered by the broker at
+// t rather than once sent, setting the message annotations read by Azure
+// Service Bus and ActiveMQ Artemis. A zero t clears them.
+//
+// Scheduled messages can't be canceled once sent. To cancel them, use the
+// management operations of the broker, such as the ones of package
+// servicebus, instead.
+func (m *Message) SetScheduledTime(t time.Time) {
+	if t.IsZero() {
+		delete(m.Annotations, annotationScheduledEnqueueTime)
+		delete(m.Annotations, annotationDeliveryTime)
+		return
+	}
+	if m.Annotations == nil {
+		m.Annotations = Annotations{}
+	}
+	m.Annotations[annotationScheduledEnqueueTime] = t
+	m.Annotations[annotationDeliveryTime] = t.UnixMilli()
+}
+
+// ScheduledTime returns the time the message is scheduled to be delivered
+// at. The boolean result is false if the message isn't scheduled.
+func (m *Message) ScheduledTime() (time.Time, bool) {
+	if t, ok := m.Annotations[annotationScheduledEnqueueTime].(time.Time); ok {
+		return t, true
+	}
+	if ms, ok := m.Annotations[annotationDeliveryTime].(int64); ok {
+		return time.UnixMilli(ms), true
+	}
+	return time.Time{}, false
+}
+
+// Validate checks that the message conforms to the constraints of the
+// AMQP spec that aren't enforced when encoding it.
+//
+// The following are checked:
+//   - Header.TTL is not negative
+//   - annotation keys are symbols (string) or ulongs (uint64)
+//   - message-id and correlation-id are of a valid type
+//   - application-properties values are simple types
+//   - the body consists of data, amqp-sequence, or a single amqp-value section
+//   - content-encoding is only set with a data body
+//
+// Messages with a RawPayload are not checked.
+//
+// Validate is called by Sender.Send when SenderOptions.ValidateMessages is set.
+func (m *Message) Validate() error {
+	if m.RawPayload != nil {
+		return nil
+	}
+	if m.Header != nil && m.Header.TTL < 0 {
+		return fmt.Errorf("invalid message: negative TTL %s", m.Header.TTL)
+	}
+	if err := validateAnnotations(m.DeliveryAnnotations); err != nil {
+		return fmt.Errorf("invalid message: delivery-annotations: %w", err)
+	}
+	if err := validateAnnotations(m.Annotations); err != nil {
+		return fmt.Errorf("invalid message: message-annotations: %w", err)
+	}
+	if err := validateAnnotations(m.Footer); err != nil {
+		return fmt.Errorf("invalid message: footer: %w", err)
+	}
+	if p := m.Properties; p != nil {
+		if err := validMessageID(p.MessageID); err != nil {
+			return fmt.Errorf("invalid message: %w", err)
+		}
+		if err := validMessageID(p.CorrelationID); err != nil {
+			return fmt.Errorf("invalid message: correlation-id: %w", err)
+		}
+		if p.ContentEncoding != nil && len(m.Data) == 0 {
+			return errors.New("invalid message: content-encoding must only be set when the body is data")
+		}
+	}
+	for k, v := range m.ApplicationProperties {
+		if !isSimpleType(v) {
+			return fmt.Errorf("invalid message: application-properties value for %q has type %T, must be a simple type", k, v)
+		}
+	}
+
+	sections := 0
+	if len(m.Data) > 0 {
+		sections++
+	}
+	if m.Value != nil {
+		sections++
+	}
+	if len(m.Sequence) > 0 {
+		sections++
+	}
+	switch {
+	case sections == 0:
+		return errors.New("invalid message: body must contain data, a value, or a sequence")
+	case sections > 1:
+		return errors.New("invalid message: body must contain only one of data, a value, or a sequence")
+	}
+	return nil
+}
+
+func validateAnnotations(a Annotations) error {
+	for k := range a {
+		switch k.(type) {
+		case string, encoding.Symbol, uint64:
+		default:
+			return fmt.Errorf("invalid key type %T, must be string or uint64", k)
+		}
+	}
+	return nil
+}
+
+// isSimpleType returns true if v isn't a map, list, or array.
+func isSimpleType(v any) bool {
+	switch v.(type) {
+	case nil, []byte:
+		return true
+	}
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		// UUID is a [16]byte
+		_, ok := v.(UUID)
+		return ok
+	default:
+		return true
+	}
+}
+
 // MarshalBinary encodes the message into binary form.
 func (m *Message) MarshalBinary() ([]byte, error) {
 	buf := &buffer.Buffer{}
@@ -149,6 +412,11 @@ func (m *Message) shouldSendDisposition() bool {
 }
 
 func (m *Message) Marshal(wr *buffer.Buffer) error {
+	if m.RawPayload != nil {
+		wr.Append(m.RawPayload)
+		return nil
+	}
+
 	if m.Header != nil {
 		err := m.Header.Marshal(wr)
 		if err != nil {
@@ -251,7 +519,7 @@ func (m *Message) Unmarshal(r *buffer.Buffer) error {
 
 		case encoding.TypeCodeMessageHeader:
 			discardHeader = false
-			section = &m.Header
+			section = encoding.Optional(&m.Header)
 
 		case encoding.TypeCodeDeliveryAnnotations:
 			section = &m.DeliveryAnnotations
@@ -261,7 +529,7 @@ func (m *Message) Unmarshal(r *buffer.Buffer) error {
 
 		case encoding.TypeCodeMessageProperties:
 			discardHeader = false
-			section = &m.Properties
+			section = encoding.Optional(&m.Properties)
 
 		case encoding.TypeCodeApplicationProperties:
 			section = &m.ApplicationProperties
@@ -270,8 +538,9 @@ func (m *Message) Unmarshal(r *buffer.Buffer) error {
 			r.Skip(int(headerLength))
 
 			var data []byte
-			err = encoding.Unmarshal(r, &data)
-			if err != nil {
+			if b := r.Bytes(); len(b) > 0 && encoding.AMQPType(b[0]) == encoding.TypeCodeNull {
+				r.Skip(1)
+			} else if data, err = encoding.ReadBinary(r); err != nil {
 				return err
 			}
 
@@ -526,3 +795,84 @@ type Annotations = encoding.Annotations
 
 // UUID is a 128 bit identifier as defined in RFC 4122.
 type UUID = encoding.UUID
+
+// NewUUID returns a randomly generated (version 4) UUID.
+func NewUUID() (UUID, error) {
+	return NewUUIDFromReader(rand.Reader)
+}
+
+// NewUUIDFromReader returns a version 4 UUID generated from the random bytes read from r.
+func NewUUIDFromReader(r io.Reader) (UUID, error) {
+	var u UUID
+	if _, err := io.ReadFull(r, u[:]); err != nil {
+		return u, err
+	}
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // variant RFC 4122
+	return u, nil
+}
+
+// ParseUUID parses s in the hex encoded form described in RFC 4122, Section 3
+// (e.g. "6ba7b810-9dad-11d1-80b4-00c04fd430c8").
+func ParseUUID(s string) (UUID, error) {
+	return encoding.ParseUUID(s)
+}
+
+// The following constructors return a MessageID of the corresponding AMQP
+// message-id variant. They can be used for both MessageProperties.MessageID
+// and MessageProperties.CorrelationID.
+
+// MessageIDString returns a message-id encoded as an AMQP string.
+func MessageIDString(id string) MessageID {
+	return id
+}
+
+// MessageIDUlong returns a message-id encoded as an AMQP ulong.
+func MessageIDUlong(id uint64) MessageID {
+	return id
+}
+
+// MessageIDUUID returns a message-id encoded as an AMQP uuid.
+func MessageIDUUID(id UUID) MessageID {
+	return id
+}
+
+// MessageIDBinary returns a message-id encoded as AMQP binary.
+func MessageIDBinary(id []byte) MessageID {
+	return id
+}
+
+// MessageIDAsString returns the value of id if it's the string variant.
+func MessageIDAsString(id MessageID) (string, bool) {
+	v, ok := id.(string)
+	return v, ok
+}
+
+// MessageIDAsUlong returns the value of id if it's the ulong variant.
+func MessageIDAsUlong(id MessageID) (uint64, bool) {
+	v, ok := id.(uint64)
+	return v, ok
+}
+
+// MessageIDAsUUID returns the value of id if it's the uuid variant.
+func MessageIDAsUUID(id MessageID) (UUID, bool) {
+	v, ok := id.(UUID)
+	return v, ok
+}
+
+// MessageIDAsBinary returns the value of id if it's the binary variant.
+func MessageIDAsBinary(id MessageID) ([]byte, bool) {
+	v, ok := id.([]byte)
+	return v, ok
+}
+
+// validMessageID returns an error if id isn't one of
+// the Go types that map to an AMQP message-id variant.
+func validMessageID(id MessageID) error {
+	switch id.(type) {
+	case nil, string, uint64, UUID, []byte:
+		return nil
+	default:
+		return fmt.Errorf("invalid message-id type %T, must be one of string, uint64, UUID, or []byte", id)
+	}
+}
@@ -0,0 +1,749 @@
+package amqp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Azure/go-amqp/internal/encoding"
+)
+
+// jsonMessage is the JSON representation of a Message.
+type jsonMessage struct {
+	Format                uint32                     `json:"format,omitempty"`
+	DeliveryTag           []byte                     `json:"deliveryTag,omitempty"`
+	Header                *jsonHeader                `json:"header,omitempty"`
+	DeliveryAnnotations   json.RawMessage            `json:"deliveryAnnotations,omitempty"`
+	Annotations           json.RawMessage            `json:"annotations,omitempty"`
+	Properties            *jsonProperties            `json:"properties,omitempty"`
+	ApplicationProperties map[string]json.RawMessage `json:"applicationProperties,omitempty"`
+	Data                  [][]byte                   `json:"data,omitempty"`
+	Value                 json.RawMessage            `json:"value,omitempty"`
+	Sequence              []json.RawMessage          `json:"sequence,omitempty"`
+	Footer                json.RawMessage            `json:"footer,omitempty"`
+	RawPayload            []byte                     `json:"rawPayload,omitempty"`
+}
+
+type jsonHeader struct {
+	Durable       bool   `json:"durable,omitempty"`
+	Priority      uint8  `json:"priority"`
+	TTL           int64  `json:"ttl,omitempty"` // milliseconds
+	FirstAcquirer bool   `json:"firstAcquirer,omitempty"`
+	DeliveryCount uint32 `json:"deliveryCount,omitempty"`
+}
+
+type jsonProperties struct {
+	MessageID          json.RawMessage `json:"messageId,omitempty"`
+	UserID             []byte          `json:"userId,omitempty"`
+	To                 *string         `json:"to,omitempty"`
+	Subject            *string         `json:"subject,omitempty"`
+	ReplyTo            *string         `json:"replyTo,omitempty"`
+	CorrelationID      json.RawMessage `json:"correlationId,omitempty"`
+	ContentType        *string         `json:"contentType,omitempty"`
+	ContentEncoding    *string         `json:"contentEncoding,omitempty"`
+	AbsoluteExpiryTime *time.Time      `json:"absoluteExpiryTime,omitempty"`
+	CreationTime       *time.Time      `json:"creationTime,omitempty"`
+	GroupID            *string         `json:"groupId,omitempty"`
+	GroupSequence      *uint32         `json:"groupSequence,omitempty"`
+	ReplyToGroupID     *string         `json:"replyToGroupId,omitempty"`
+}
+
+// jsonValue is a tagged AMQP value. Values with a natural JSON form
+// (null, bool, and string) are written as plain JSON instead.
+type jsonValue struct {
+	Type    string          `json:"type"`
+	Element string          `json:"element,omitempty"` // element type of an array
+	Value   json.RawMessage `json:"value"`
+}
+
+// jsonArrayTypes maps AMQP array element type names to their Go slice types.
+var jsonArrayTypes = map[string]reflect.Type{
+	"byte":      reflect.TypeOf([]int8(nil)),
+	"ubyte":     reflect.TypeOf(encoding.ArrayUByte(nil)),
+	"short":     reflect.TypeOf([]int16(nil)),
+	"ushort":    reflect.TypeOf([]uint16(nil)),
+	"int":       reflect.TypeOf([]int32(nil)),
+	"uint":      reflect.TypeOf([]uint32(nil)),
+	"long":      reflect.TypeOf([]int64(nil)),
+	"ulong":     reflect.TypeOf([]uint64(nil)),
+	"float":     reflect.TypeOf([]float32(nil)),
+	"double":    reflect.TypeOf([]float64(nil)),
+	"boolean":   reflect.TypeOf([]bool(nil)),
+	"string":    reflect.TypeOf([]string(nil)),
+	"symbol":    reflect.TypeOf([]encoding.Symbol(nil)),
+	"binary":    reflect.TypeOf([][]byte(nil)),
+	"timestamp": reflect.TypeOf([]time.Time(nil)),
+	"uuid":      reflect.TypeOf([]UUID(nil)),
+}
+
+// MarshalJSON implements json.Marshaler.
+//
+// All sections of the message are encoded. AMQP values that have no
+// direct JSON equivalent (e.g. ulong, uuid, binary, timestamp, maps with
+// non-string keys) are written as {"type": ..., "value": ...} objects so
+// that UnmarshalJSON restores the original types.
+//
+// Use ToMap for a simpler, lossy representation suitable for logging.
+func (m *Message) MarshalJSON() ([]byte, error) {
+	jm := jsonMessage{
+		Format:      m.Format,
+		DeliveryTag: m.DeliveryTag,
+		Data:        m.Data,
+		RawPayload:  m.RawPayload,
+	}
+	var err error
+
+	if h := m.Header; h != nil {
+		jm.Header = &jsonHeader{
+			Durable:       h.Durable,
+			Priority:      h.Priority,
+			TTL:           h.TTL.Milliseconds(),
+			FirstAcquirer: h.FirstAcquirer,
+			DeliveryCount: h.DeliveryCount,
+		}
+	}
+	if m.DeliveryAnnotations != nil {
+		if jm.DeliveryAnnotations, err = marshalJSONValue(m.DeliveryAnnotations); err != nil {
+			return nil, fmt.Errorf("delivery-annotations: %w", err)
+		}
+	}
+	if m.Annotations != nil {
+		if jm.Annotations, err = marshalJSONValue(m.Annotations); err != nil {
+			return nil, fmt.Errorf("message-annotations: %w", err)
+		}
+	}
+	if p := m.Properties; p != nil {
+		jp := &jsonProperties{
+			UserID:             p.UserID,
+			To:                 p.To,
+			Subject:            p.Subject,
+			ReplyTo:            p.ReplyTo,
+			ContentType:        p.ContentType,
+			ContentEncoding:    p.ContentEncoding,
+			AbsoluteExpiryTime: p.AbsoluteExpiryTime,
+			CreationTime:       p.CreationTime,
+			GroupID:            p.GroupID,
+			GroupSequence:      p.GroupSequence,
+			ReplyToGroupID:     p.ReplyToGroupID,
+		}
+		if p.MessageID != nil {
+			if jp.MessageID, err = marshalJSONValue(p.MessageID); err != nil {
+				return nil, fmt.Errorf("message-id: %w", err)
+			}
+		}
+		if p.CorrelationID != nil {
+			if jp.CorrelationID, err = marshalJSONValue(p.CorrelationID); err != nil {
+				return nil, fmt.Errorf("correlation-id: %w", err)
+			}
+		}
+		jm.Properties = jp
+	}
+	if m.ApplicationProperties != nil {
+		jm.ApplicationProperties = make(map[string]json.RawMessage, len(m.ApplicationProperties))
+		for k, v := range m.ApplicationProperties {
+			if jm.ApplicationProperties[k], err = marshalJSONValue(v); err != nil {
+				return nil, fmt.Errorf("application-properties %q: %w", k, err)
+			}
+		}
+	}
+	if m.Value != nil {
+		if jm.Value, err = marshalJSONValue(m.Value); err != nil {
+			return nil, fmt.Errorf("amqp-value: %w", err)
+		}
+	}
+	for _, seq := range m.Sequence {
+		raw, err := marshalJSONValue(seq)
+		if err != nil {
+			return nil, fmt.Errorf("amqp-sequence: %w", err)
+		}
+		jm.Sequence = append(jm.Sequence, raw)
+	}
+	if m.Footer != nil {
+		if jm.Footer, err = marshalJSONValue(m.Footer); err != nil {
+			return nil, fmt.Errorf("footer: %w", err)
+		}
+	}
+
+	return json.Marshal(jm)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+//
+// It accepts the output of MarshalJSON. Untagged JSON numbers are decoded
+// as int64 when integral and float64 otherwise, JSON arrays as lists and
+// JSON objects without a "type" member as map[string]any.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var jm jsonMessage
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return err
+	}
+
+	*m = Message{
+		Format:      jm.Format,
+		DeliveryTag: jm.DeliveryTag,
+		Data:        jm.Data,
+		RawPayload:  jm.RawPayload,
+	}
+	var err error
+
+	if h := jm.Header; h != nil {
+		m.Header = &MessageHeader{
+			Durable:       h.Durable,
+			Priority:      h.Priority,
+			TTL:           time.Duration(h.TTL) * time.Millisecond,
+			FirstAcquirer: h.FirstAcquirer,
+			DeliveryCount: h.DeliveryCount,
+		}
+	}
+	if m.DeliveryAnnotations, err = unmarshalJSONAnnotations(jm.DeliveryAnnotations); err != nil {
+		return fmt.Errorf("delivery-annotations: %w", err)
+	}
+	if m.Annotations, err = unmarshalJSONAnnotations(jm.Annotations); err != nil {
+		return fmt.Errorf("message-annotations: %w", err)
+	}
+	if jp := jm.Properties; jp != nil {
+		p := &MessageProperties{
+			UserID:             jp.UserID,
+			To:                 jp.To,
+			Subject:            jp.Subject,
+			ReplyTo:            jp.ReplyTo,
+			ContentType:        jp.ContentType,
+			ContentEncoding:    jp.ContentEncoding,
+			AbsoluteExpiryTime: jp.AbsoluteExpiryTime,
+			CreationTime:       jp.CreationTime,
+			GroupID:            jp.GroupID,
+			GroupSequence:      jp.GroupSequence,
+			ReplyToGroupID:     jp.ReplyToGroupID,
+		}
+		if p.MessageID, err = unmarshalJSONValue(jp.MessageID); err != nil {
+			return fmt.Errorf("message-id: %w", err)
+		}
+		if p.CorrelationID, err = unmarshalJSONValue(jp.CorrelationID); err != nil {
+			return fmt.Errorf("correlation-id: %w", err)
+		}
+		m.Properties = p
+	}
+	if jm.ApplicationProperties != nil {
+		m.ApplicationProperties = make(map[string]any, len(jm.ApplicationProperties))
+		for k, raw := range jm.ApplicationProperties {
+			if m.ApplicationProperties[k], err = unmarshalJSONValue(raw); err != nil {
+				return fmt.Errorf("application-properties %q: %w", k, err)
+			}
+		}
+	}
+	if m.Value, err = unmarshalJSONValue(jm.Value); err != nil {
+		return fmt.Errorf("amqp-value: %w", err)
+	}
+	for _, raw := range jm.Sequence {
+		v, err := unmarshalJSONValue(raw)
+		if err != nil {
+			return fmt.Errorf("amqp-sequence: %w", err)
+		}
+		seq, ok := v.([]any)
+		if !ok && v != nil {
+			return fmt.Errorf("amqp-sequence: expected list, got %T", v)
+		}
+		m.Sequence = append(m.Sequence, seq)
+	}
+	if m.Footer, err = unmarshalJSONAnnotations(jm.Footer); err != nil {
+		return fmt.Errorf("footer: %w", err)
+	}
+	return nil
+}
+
+// ToMap returns a lossy representation of the message made only of
+// JSON-friendly values. It is intended for logging and diagnostics;
+// use MarshalJSON when the message needs to be restored later.
+//
+// AMQP type information is dropped: UUIDs become strings, map keys are
+// formatted with fmt.Sprint, and data sections that are valid UTF-8 are
+// returned as strings.
+func (m *Message) ToMap() map[string]any {
+	out := map[string]any{}
+	if m.Format != 0 {
+		out["format"] = m.Format
+	}
+	if m.DeliveryTag != nil {
+		out["deliveryTag"] = m.DeliveryTag
+	}
+	if h := m.Header; h != nil {
+		out["header"] = map[string]any{
+			"durable":       h.Durable,
+			"priority":      h.Priority,
+			"ttl":           h.TTL.String(),
+			"firstAcquirer": h.FirstAcquirer,
+			"deliveryCount": h.DeliveryCount,
+		}
+	}
+	if m.DeliveryAnnotations != nil {
+		out["deliveryAnnotations"] = toJSONFriendly(m.DeliveryAnnotations)
+	}
+	if m.Annotations != nil {
+		out["annotations"] = toJSONFriendly(m.Annotations)
+	}
+	if p := m.Properties; p != nil {
+		props := map[string]any{}
+		set := func(k string, v any) {
+			if rv := reflect.ValueOf(v); v == nil || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+				return
+			} else if rv.Kind() == reflect.Ptr {
+				v = rv.Elem().Interface()
+			}
+			props[k] = toJSONFriendly(v)
+		}
+		set("messageId", p.MessageID)
+		if p.UserID != nil {
+			set("userId", p.UserID)
+		}
+		set("to", p.To)
+		set("subject", p.Subject)
+		set("replyTo", p.ReplyTo)
+		set("correlationId", p.CorrelationID)
+		set("contentType", p.ContentType)
+		set("contentEncoding", p.ContentEncoding)
+		set("absoluteExpiryTime", p.AbsoluteExpiryTime)
+		set("creationTime", p.CreationTime)
+		set("groupId", p.GroupID)
+		set("groupSequence", p.GroupSequence)
+		set("replyToGroupId", p.ReplyToGroupID)
+		out["properties"] = props
+	}
+	if m.ApplicationProperties != nil {
+		out["applicationProperties"] = toJSONFriendly(m.ApplicationProperties)
+	}
+	if m.Data != nil {
+		data := make([]any, len(m.Data))
+		for i, d := range m.Data {
+			if utf8.Valid(d) {
+				data[i] = string(d)
+			} else {
+				data[i] = d
+			}
+		}
+		out["data"] = data
+	}
+	if m.Value != nil {
+		out["value"] = toJSONFriendly(m.Value)
+	}
+	if m.Sequence != nil {
+		seq := make([]any, len(m.Sequence))
+		for i, s := range m.Sequence {
+			seq[i] = toJSONFriendly(s)
+		}
+		out["sequence"] = seq
+	}
+	if m.Footer != nil {
+		out["footer"] = toJSONFriendly(m.Footer)
+	}
+	if m.RawPayload != nil {
+		out["rawPayload"] = m.RawPayload
+	}
+	return out
+}
+
+func marshalJSONValue(v any) (json.RawMessage, error) {
+	ev, err := encodeJSONValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ev)
+}
+
+// encodeJSONValue converts v into a value that encoding/json can marshal
+// without losing its AMQP type.
+func encodeJSONValue(v any) (any, error) {
+	tagged := func(typ string, v any) (any, error) {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return jsonValue{Type: typ, Value: raw}, nil
+	}
+
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v, nil
+	case int8:
+		return tagged("byte", v)
+	case uint8:
+		return tagged("ubyte", v)
+	case int16:
+		return tagged("short", v)
+	case uint16:
+		return tagged("ushort", v)
+	case int32:
+		return tagged("int", v)
+	case uint32:
+		return tagged("uint", v)
+	case int64, int:
+		return tagged("long", v)
+	case uint64, uint:
+		return tagged("ulong", v)
+	case float32:
+		return tagged("float", v)
+	case float64:
+		return tagged("double", v)
+	case encoding.Symbol:
+		return tagged("symbol", string(v))
+	case []byte:
+		return tagged("binary", v)
+	case time.Time:
+		return tagged("timestamp", v)
+	case UUID:
+		return tagged("uuid", v.String())
+	case []any:
+		l := make([]any, len(v))
+		for i := range v {
+			ev, err := encodeJSONValue(v[i])
+			if err != nil {
+				return nil, err
+			}
+			l[i] = ev
+		}
+		return tagged("list", l)
+	case map[string]any:
+		pairs := make([][2]any, 0, len(v))
+		for k, val := range v {
+			pairs = append(pairs, [2]any{k, val})
+		}
+		return encodeJSONMap(pairs)
+	case map[any]any:
+		pairs := make([][2]any, 0, len(v))
+		for k, val := range v {
+			pairs = append(pairs, [2]any{k, val})
+		}
+		return encodeJSONMap(pairs)
+	case Annotations:
+		return encodeJSONValue(map[any]any(v))
+	case map[encoding.Symbol]any:
+		pairs := make([][2]any, 0, len(v))
+		for k, val := range v {
+			pairs = append(pairs, [2]any{k, val})
+		}
+		return encodeJSONMap(pairs)
+	case encoding.DescribedType:
+		return encodeJSONDescribed(v)
+	case *encoding.DescribedType:
+		return encodeJSONDescribed(*v)
+	}
+
+	t := reflect.TypeOf(v)
+	for name, at := range jsonArrayTypes {
+		if at == t {
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			return jsonValue{Type: "array", Element: name, Value: raw}, nil
+		}
+	}
+	return nil, fmt.Errorf("JSON encoding not implemented for %T", v)
+}
+
+// encodeJSONMap encodes map entries as a list of key/value pairs so that
+// non-string keys survive a round trip. Pairs are sorted by key to keep
+// the output stable.
+func encodeJSONMap(pairs [][2]any) (any, error) {
+	sort.Slice(pairs, func(i, j int) bool {
+		return fmt.Sprint(pairs[i][0]) < fmt.Sprint(pairs[j][0])
+	})
+	for i := range pairs {
+		for j := range pairs[i] {
+			ev, err := encodeJSONValue(pairs[i][j])
+			if err != nil {
+				return nil, err
+			}
+			pairs[i][j] = ev
+		}
+	}
+	raw, err := json.Marshal(pairs)
+	if err != nil {
+		return nil, err
+	}
+	return jsonValue{Type: "map", Value: raw}, nil
+}
+
+func encodeJSONDescribed(dt encoding.DescribedType) (any, error) {
+	d, err := encodeJSONValue(dt.Descriptor)
+	if err != nil {
+		return nil, err
+	}
+	v, err := encodeJSONValue(dt.Value)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal([2]any{d, v})
+	if err != nil {
+		return nil, err
+	}
+	return jsonValue{Type: "described", Value: raw}, nil
+}
+
+func unmarshalJSONAnnotations(raw json.RawMessage) (Annotations, error) {
+	v, err := unmarshalJSONValue(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		a := make(Annotations, len(v))
+		for k, val := range v {
+			a[k] = val
+		}
+		return a, nil
+	case map[any]any:
+		return Annotations(v), nil
+	default:
+		return nil, fmt.Errorf("expected map, got %T", v)
+	}
+}
+
+// unmarshalJSONValue is the inverse of marshalJSONValue.
+func unmarshalJSONValue(raw json.RawMessage) (any, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	switch raw[0] {
+	case 'n':
+		return nil, nil
+	case 't', 'f':
+		var b bool
+		err := json.Unmarshal(raw, &b)
+		return b, err
+	case '"':
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		return unmarshalJSONList(items)
+	case '{':
+		// handled below
+	default:
+		var n json.Number
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return nil, err
+		}
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		return n.Float64()
+	}
+
+	var jv jsonValue
+	if err := json.Unmarshal(raw, &jv); err != nil {
+		return nil, err
+	}
+	if jv.Type == "" {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+		m := make(map[string]any, len(fields))
+		for k, f := range fields {
+			v, err := unmarshalJSONValue(f)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	}
+
+	switch jv.Type {
+	case "byte":
+		return unmarshalJSONScalar[int8](jv.Value)
+	case "ubyte":
+		return unmarshalJSONScalar[uint8](jv.Value)
+	case "short":
+		return unmarshalJSONScalar[int16](jv.Value)
+	case "ushort":
+		return unmarshalJSONScalar[uint16](jv.Value)
+	case "int":
+		return unmarshalJSONScalar[int32](jv.Value)
+	case "uint":
+		return unmarshalJSONScalar[uint32](jv.Value)
+	case "long":
+		return unmarshalJSONScalar[int64](jv.Value)
+	case "ulong":
+		return unmarshalJSONScalar[uint64](jv.Value)
+	case "float":
+		return unmarshalJSONScalar[float32](jv.Value)
+	case "double":
+		return unmarshalJSONScalar[float64](jv.Value)
+	case "symbol":
+		return unmarshalJSONScalar[encoding.Symbol](jv.Value)
+	case "binary":
+		return unmarshalJSONScalar[[]byte](jv.Value)
+	case "timestamp":
+		return unmarshalJSONScalar[time.Time](jv.Value)
+	case "uuid":
+		var s string
+		if err := json.Unmarshal(jv.Value, &s); err != nil {
+			return nil, err
+		}
+		return ParseUUID(s)
+	case "list":
+		var items []json.RawMessage
+		if err := json.Unmarshal(jv.Value, &items); err != nil {
+			return nil, err
+		}
+		return unmarshalJSONList(items)
+	case "map":
+		return unmarshalJSONMap(jv.Value)
+	case "described":
+		var parts [2]json.RawMessage
+		if err := json.Unmarshal(jv.Value, &parts); err != nil {
+			return nil, err
+		}
+		d, err := unmarshalJSONValue(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		v, err := unmarshalJSONValue(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return encoding.DescribedType{Descriptor: d, Value: v}, nil
+	case "array":
+		t, ok := jsonArrayTypes[jv.Element]
+		if !ok {
+			return nil, fmt.Errorf("unknown array element type %q", jv.Element)
+		}
+		ptr := reflect.New(t)
+		if err := json.Unmarshal(jv.Value, ptr.Interface()); err != nil {
+			return nil, err
+		}
+		return ptr.Elem().Interface(), nil
+	default:
+		return nil, fmt.Errorf("unknown JSON value type %q", jv.Type)
+	}
+}
+
+func unmarshalJSONScalar[T any](raw json.RawMessage) (any, error) {
+	var v T
+	err := json.Unmarshal(raw, &v)
+	return v, err
+}
+
+func unmarshalJSONList(items []json.RawMessage) (any, error) {
+	l := make([]any, len(items))
+	for i, item := range items {
+		v, err := unmarshalJSONValue(item)
+		if err != nil {
+			return nil, err
+		}
+		l[i] = v
+	}
+	return l, nil
+}
+
+// unmarshalJSONMap decodes a list of key/value pairs. Like the AMQP
+// decoder, it returns map[string]any when all keys are strings and
+// map[any]any otherwise.
+func unmarshalJSONMap(raw json.RawMessage) (any, error) {
+	var pairs [][2]json.RawMessage
+	if err := json.Unmarshal(raw, &pairs); err != nil {
+		return nil, err
+	}
+
+	m := make(map[any]any, len(pairs))
+	stringKeys := true
+	for _, pair := range pairs {
+		k, err := unmarshalJSONValue(pair[0])
+		if err != nil {
+			return nil, err
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, errors.New("map key is not comparable")
+		}
+		v, err := unmarshalJSONValue(pair[1])
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := k.(string); !ok {
+			stringKeys = false
+		}
+		m[k] = v
+	}
+
+	if !stringKeys || len(m) == 0 {
+		return m, nil
+	}
+	mm := make(map[string]any, len(m))
+	for k, v := range m {
+		mm[k.(string)] = v
+	}
+	return mm, nil
+}
+
+// toJSONFriendly converts v into a value that encoding/json can marshal,
+// discarding AMQP type information.
+func toJSONFriendly(v any) any {
+	switch v := v.(type) {
+	case nil, bool, string, []byte, time.Time,
+		int8, uint8, int16, uint16, int32, uint32, int64, uint64, int, uint, float32, float64:
+		return v
+	case encoding.Symbol:
+		return string(v)
+	case UUID:
+		return v.String()
+	case []any:
+		l := make([]any, len(v))
+		for i := range v {
+			l[i] = toJSONFriendly(v[i])
+		}
+		return l
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, val := range v {
+			m[k] = toJSONFriendly(val)
+		}
+		return m
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = toJSONFriendly(val)
+		}
+		return m
+	case Annotations:
+		return toJSONFriendly(map[any]any(v))
+	case map[encoding.Symbol]any:
+		m := make(map[string]any, len(v))
+		for k, val := range v {
+			m[string(k)] = toJSONFriendly(val)
+		}
+		return m
+	case []UUID:
+		l := make([]string, len(v))
+		for i := range v {
+			l[i] = v[i].String()
+		}
+		return l
+	case encoding.DescribedType:
+		return map[string]any{
+			"descriptor": toJSONFriendly(v.Descriptor),
+			"value":      toJSONFriendly(v.Value),
+		}
+	case *encoding.DescribedType:
+		return toJSONFriendly(*v)
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+		// typed arrays already marshal cleanly
+		return v
+	}
+	return fmt.Sprint(v)
+}
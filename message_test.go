@@ -1,7 +1,11 @@
 package amqp
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -81,3 +85,223 @@ func TestMessageWithSequence(t *testing.T) {
 		{"hello2", "world2", int64(21), int64(22), int64(23)},
 	}, newM.Sequence)
 }
+
+func TestMessageIDVariants(t *testing.T) {
+	uuid, err := NewUUID()
+	require.NoError(t, err)
+	require.Equal(t, byte(0x40), uuid[6]&0xf0)
+
+	parsed, err := ParseUUID(uuid.String())
+	require.NoError(t, err)
+	require.Equal(t, uuid, parsed)
+
+	_, err = ParseUUID("not-a-uuid")
+	require.Error(t, err)
+
+	uuid, err = NewUUIDFromReader(bytes.NewReader(bytes.Repeat([]byte{0xff}, 16)))
+	require.NoError(t, err)
+	require.Equal(t, "ffffffff-ffff-4fff-bfff-ffffffffffff", uuid.String())
+	_, err = NewUUIDFromReader(bytes.NewReader(nil))
+	require.ErrorIs(t, err, io.EOF)
+
+	for _, id := range []MessageID{
+		MessageIDString("id"),
+		MessageIDUlong(123),
+		MessageIDUUID(uuid),
+		MessageIDBinary([]byte{1, 2, 3}),
+	} {
+		m := &Message{Properties: &MessageProperties{MessageID: id, CorrelationID: id}}
+		b, err := m.MarshalBinary()
+		require.NoError(t, err)
+
+		var got Message
+		require.NoError(t, got.UnmarshalBinary(b))
+		require.Equal(t, id, got.Properties.MessageID)
+		require.Equal(t, id, got.Properties.CorrelationID)
+	}
+
+	s, ok := MessageIDAsString(MessageIDString("id"))
+	require.True(t, ok)
+	require.Equal(t, "id", s)
+	_, ok = MessageIDAsString(MessageIDUlong(1))
+	require.False(t, ok)
+	u, ok := MessageIDAsUlong(MessageIDUlong(1))
+	require.True(t, ok)
+	require.Equal(t, uint64(1), u)
+	id, ok := MessageIDAsUUID(MessageIDUUID(uuid))
+	require.True(t, ok)
+	require.Equal(t, uuid, id)
+	bin, ok := MessageIDAsBinary(MessageIDBinary([]byte{1}))
+	require.True(t, ok)
+	require.Equal(t, []byte{1}, bin)
+
+	// other types are only reported by Validate
+	m := &Message{Value: 1, Properties: &MessageProperties{MessageID: 5, CorrelationID: int64(6)}}
+	require.Error(t, m.Validate())
+	_, err = m.MarshalBinary()
+	require.NoError(t, err)
+}
+
+func TestMessageTTL(t *testing.T) {
+	m := NewMessage([]byte("data"))
+	_, ok := m.ExpiryTime()
+	require.False(t, ok)
+	require.False(t, m.Expired(time.Now()))
+
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.Properties = &MessageProperties{CreationTime: &created}
+	m.SetTTL(time.Minute)
+	require.Equal(t, time.Minute, m.Header.TTL)
+	require.Equal(t, uint8(4), m.Header.Priority)
+	expiry, ok := m.ExpiryTime()
+	require.True(t, ok)
+	require.Equal(t, created.Add(time.Minute), expiry)
+	require.False(t, m.Expired(created))
+	require.True(t, m.Expired(created.Add(time.Minute)))
+
+	// TTL and creation time are used when there's no absolute expiry
+	m.Properties.AbsoluteExpiryTime = nil
+	expiry, ok = m.ExpiryTime()
+	require.True(t, ok)
+	require.Equal(t, created.Add(time.Minute), expiry)
+
+	m.SetTTL(0)
+	require.Zero(t, m.Header.TTL)
+	require.Nil(t, m.Properties.AbsoluteExpiryTime)
+	_, ok = m.ExpiryTime()
+	require.False(t, ok)
+}
+
+func TestMessageScheduledTime(t *testing.T) {
+	m := &Message{}
+	_, ok := m.ScheduledTime()
+	require.False(t, ok)
+
+	at := time.UnixMilli(1700000000123)
+	m.SetScheduledTime(at)
+	require.Equal(t, at, m.Annotations[annotationScheduledEnqueueTime])
+	require.Equal(t, int64(1700000000123), m.Annotations[annotationDeliveryTime])
+	scheduled, ok := m.ScheduledTime()
+	require.True(t, ok)
+	require.Equal(t, at, scheduled)
+
+	// the Artemis annotation is read without the Service Bus one
+	delete(m.Annotations, annotationScheduledEnqueueTime)
+	scheduled, ok = m.ScheduledTime()
+	require.True(t, ok)
+	require.True(t, at.Equal(scheduled))
+
+	m.SetScheduledTime(time.Time{})
+	require.Empty(t, m.Annotations)
+	_, ok = m.ScheduledTime()
+	require.False(t, ok)
+}
+
+func TestMessageJSON(t *testing.T) {
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := "queue"
+	m := &Message{
+		Format:      1,
+		DeliveryTag: []byte{1, 2},
+		Header: &MessageHeader{
+			Durable:  true,
+			Priority: 4,
+			TTL:      time.Second,
+		},
+		Annotations: Annotations{
+			"x-opt-string": "value",
+			int64(5):       uint64(6),
+		},
+		Properties: &MessageProperties{
+			MessageID:     UUID{1, 2, 3},
+			CorrelationID: uint64(42),
+			To:            &to,
+			CreationTime:  &created,
+		},
+		ApplicationProperties: map[string]any{
+			"int":       int32(-1),
+			"ubyte":     uint8(7),
+			"double":    1.5,
+			"bool":      true,
+			"binary":    []byte{0xff},
+			"timestamp": created,
+			"array":     []int16{1, 2},
+		},
+		Value:  []any{"a", uint16(1), map[string]any{"k": nil}},
+		Footer: Annotations{"sig": []byte("abc")},
+	}
+
+	b, err := json.Marshal(m)
+	require.NoError(t, err)
+
+	var got Message
+	require.NoError(t, json.Unmarshal(b, &got))
+	if diff := cmp.Diff(m, &got, cmpopts.IgnoreUnexported(Message{})); diff != "" {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+
+	mm := m.ToMap()
+	props := mm["properties"].(map[string]any)
+	require.Equal(t, "01020300-0000-0000-0000-000000000000", props["messageId"])
+	require.Equal(t, "queue", props["to"])
+	require.Equal(t, map[string]any{"x-opt-string": "value", "5": uint64(6)}, mm["annotations"])
+	_, err = json.Marshal(mm)
+	require.NoError(t, err)
+
+	_, err = json.Marshal(&Message{Value: struct{}{}})
+	require.Error(t, err)
+}
+
+func TestMessageValidate(t *testing.T) {
+	enc := "gzip"
+	tests := []struct {
+		label string
+		msg   *Message
+		err   string
+	}{
+		{"data", NewMessage([]byte("data")), ""},
+		{"value", &Message{Value: "value"}, ""},
+		{"sequence", &Message{Sequence: [][]any{{1}, {2}}}, ""},
+		{"annotation keys", &Message{Value: 1, Annotations: Annotations{"x-opt-key": 1, uint64(2): 2}}, ""},
+		{"simple app props", &Message{Value: 1, ApplicationProperties: map[string]any{"b": []byte{1}, "u": UUID{}, "n": nil}}, ""},
+		{"no body", &Message{}, "body must contain"},
+		{"multiple bodies", &Message{Data: [][]byte{{1}}, Value: 1}, "only one of"},
+		{"negative TTL", &Message{Value: 1, Header: &MessageHeader{TTL: -time.Second}}, "negative TTL"},
+		{"int annotation key", &Message{Value: 1, Annotations: Annotations{5: 1}}, "message-annotations: invalid key type int"},
+		{"footer key", &Message{Value: 1, Footer: Annotations{int64(5): 1}}, "footer"},
+		{"message-id", &Message{Value: 1, Properties: &MessageProperties{MessageID: 1}}, "invalid message-id type int"},
+		{"correlation-id", &Message{Value: 1, Properties: &MessageProperties{CorrelationID: 1.5}}, "correlation-id"},
+		{"content-encoding", &Message{Value: "v", Properties: &MessageProperties{ContentEncoding: &enc}}, "content-encoding"},
+		{"list app prop", &Message{Value: 1, ApplicationProperties: map[string]any{"l": []any{1}}}, `"l" has type []interface {}`},
+		{"map app prop", &Message{Value: 1, ApplicationProperties: map[string]any{"m": map[string]any{}}}, "must be a simple type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			err := tt.msg.Validate()
+			if tt.err == "" {
+				require.NoError(t, err)
+				_, err = tt.msg.MarshalBinary()
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestMessageRawPayload(t *testing.T) {
+	inner, err := NewMessage([]byte("inner")).MarshalBinary()
+	require.NoError(t, err)
+
+	// the raw payload takes precedence over the sections
+	m := &Message{Format: 0x80013700, RawPayload: inner, Value: "ignored"}
+	require.NoError(t, m.Validate())
+	b, err := m.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, inner, b)
+
+	var decoded Message
+	require.NoError(t, decoded.UnmarshalBinary(m.RawPayload))
+	require.Equal(t, []byte("inner"), decoded.GetData())
+}
@@ -0,0 +1,67 @@
+package amqp
+
+import "github.com/Azure/go-amqp/internal/encoding"
+
+// Metrics receives measurements from a connection and its sessions and links.
+// See the metrics/prometheus package for an adapter to Prometheus.
+//
+// Methods are called from the connection's goroutines; implementations
+// must be safe for concurrent use and must not block.
+type Metrics interface {
+	// ConnOpened is called when a connection has been established.
+	ConnOpened()
+
+	// ConnClosed is called when an established connection is closed.
+	// err is nil if the connection was closed by calling Conn.Close.
+	ConnClosed(err error)
+
+	// FrameSent is called for each performative sent, with the
+	// performative's AMQP name and the encoded frame size in bytes.
+	FrameSent(performative string, size int)
+
+	// FrameReceived is called for each performative received, with the
+	// performative's AMQP name and the encoded frame size in bytes.
+	FrameReceived(performative string, size int)
+
+	// MessageSent is called when Sender.Send returns, with the
+	// sender's target address and the error returned by Send. For
+	// Sender.SendWithReceipt, it's called once the message is settled.
+	MessageSent(address string, err error)
+
+	// MessageReceived is called when a complete message has been received
+	// and is ready for delivery, with the receiver's source address.
+	MessageReceived(address string)
+
+	// MessageSettled is called when a receiver settles a message, with the
+	// receiver's source address and the outcome: "accepted", "rejected",
+	// "released", or "modified".
+	MessageSettled(address string, outcome string)
+
+	// LinkCredit is called when a receiver grants link credit to the peer,
+	// with the receiver's source address, its link name, and the total
+	// credit granted.
+	LinkCredit(address, link string, credit uint32)
+
+	// LinkDetached is called when a receiver's link is detached, with the
+	// receiver's source address and its link name. No further measurements
+	// are reported for the link.
+	LinkDetached(address, link string)
+}
+
+// nopMetrics is the Metrics used when ConnOptions.Metrics isn't set.
+type nopMetrics struct{}
+
+func (nopMetrics) ConnOpened()                       {}
+func (nopMetrics) ConnClosed(error)                  {}
+func (nopMetrics) FrameSent(string, int)             {}
+func (nopMetrics) FrameReceived(string, int)         {}
+func (nopMetrics) MessageSent(string, error)         {}
+func (nopMetrics) MessageReceived(string)            {}
+func (nopMetrics) MessageSettled(string, string)     {}
+func (nopMetrics) LinkCredit(string, string, uint32) {}
+func (nopMetrics) LinkDetached(string, string)       {}
+
+// outcomeName returns the name reported to Metrics.MessageSettled for state.
+func outcomeName(state encoding.DeliveryState) string {
+	return newOutcome(state).Type.String()
+}
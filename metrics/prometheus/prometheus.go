@@ -0,0 +1,171 @@
+// Package prometheus provides an amqp.Metrics implementation that exports
+// measurements as Prometheus metrics.
+//
+//	m := prometheus.New(nil)
+//	registry.MustRegister(m)
+//	conn, err := amqp.Dial(addr, &amqp.ConnOptions{Metrics: m})
+//
+// It is a separate module so that applications that don't use Prometheus
+// do not depend on github.com/prometheus/client_golang.
+package prometheus
+
+import (
+	amqp "github.com/Azure/go-amqp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options contains the optional settings for New.
+type Options struct {
+	// Namespace is prepended to the metric names.
+	//
+	// Default: "amqp".
+	Namespace string
+
+	// ConstLabels are added to every metric, e.g. to identify the application.
+	ConstLabels prometheus.Labels
+
+	// OmitAddress drops the address label from link metrics. Use it when
+	// links are created for many distinct addresses, such as dynamic
+	// reply-to nodes, to keep the number of series bounded.
+	OmitAddress bool
+}
+
+// Metrics implements amqp.Metrics and prometheus.Collector.
+// A single instance can be shared by multiple connections.
+type Metrics struct {
+	omitAddress bool
+
+	connsOpened      prometheus.Counter
+	connsOpen        prometheus.Gauge
+	connErrors       prometheus.Counter
+	framesSent       *prometheus.CounterVec
+	framesReceived   *prometheus.CounterVec
+	bytesSent        prometheus.Counter
+	bytesReceived    prometheus.Counter
+	messagesSent     *prometheus.CounterVec
+	messagesReceived *prometheus.CounterVec
+	messagesSettled  *prometheus.CounterVec
+	linkCredit       *prometheus.GaugeVec
+}
+
+// New creates a Metrics. Register it with a prometheus.Registerer to export the metrics.
+//
+// opts: pass nil to accept the default values.
+func New(opts *Options) *Metrics {
+	if opts == nil {
+		opts = &Options{}
+	}
+	ns := opts.Namespace
+	if ns == "" {
+		ns = "amqp"
+	}
+	counter := func(name, help string) prometheus.Counter {
+		return prometheus.NewCounter(prometheus.CounterOpts{Namespace: ns, Name: name, Help: help, ConstLabels: opts.ConstLabels})
+	}
+	counterVec := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: ns, Name: name, Help: help, ConstLabels: opts.ConstLabels}, labels)
+	}
+
+	return &Metrics{
+		omitAddress:      opts.OmitAddress,
+		connsOpened:      counter("connections_opened_total", "Number of connections established, including reconnects."),
+		connsOpen:        prometheus.NewGauge(prometheus.GaugeOpts{Namespace: ns, Name: "connections_open", Help: "Number of currently open connections.", ConstLabels: opts.ConstLabels}),
+		connErrors:       counter("connection_errors_total", "Number of connections closed due to an error."),
+		framesSent:       counterVec("frames_sent_total", "Number of performatives sent.", "performative"),
+		framesReceived:   counterVec("frames_received_total", "Number of performatives received.", "performative"),
+		bytesSent:        counter("sent_bytes_total", "Number of frame bytes sent."),
+		bytesReceived:    counter("received_bytes_total", "Number of frame bytes received."),
+		messagesSent:     counterVec("messages_sent_total", "Number of messages sent, by result.", "address", "result"),
+		messagesReceived: counterVec("messages_received_total", "Number of messages received.", "address"),
+		messagesSettled:  counterVec("messages_settled_total", "Number of received messages settled, by outcome.", "address", "outcome"),
+		linkCredit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: ns, Name: "link_credit", Help: "Link credit most recently granted by each open receiver.", ConstLabels: opts.ConstLabels,
+		}, []string{"address", "link"}),
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.connsOpened, m.connsOpen, m.connErrors,
+		m.framesSent, m.framesReceived, m.bytesSent, m.bytesReceived,
+		m.messagesSent, m.messagesReceived, m.messagesSettled, m.linkCredit,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (m *Metrics) address(address string) string {
+	if m.omitAddress {
+		return ""
+	}
+	return address
+}
+
+// ConnOpened implements amqp.Metrics.
+func (m *Metrics) ConnOpened() {
+	m.connsOpened.Inc()
+	m.connsOpen.Inc()
+}
+
+// ConnClosed implements amqp.Metrics.
+func (m *Metrics) ConnClosed(err error) {
+	m.connsOpen.Dec()
+	if err != nil {
+		m.connErrors.Inc()
+	}
+}
+
+// FrameSent implements amqp.Metrics.
+func (m *Metrics) FrameSent(performative string, size int) {
+	m.framesSent.WithLabelValues(performative).Inc()
+	m.bytesSent.Add(float64(size))
+}
+
+// FrameReceived implements amqp.Metrics.
+func (m *Metrics) FrameReceived(performative string, size int) {
+	m.framesReceived.WithLabelValues(performative).Inc()
+	m.bytesReceived.Add(float64(size))
+}
+
+// MessageSent implements amqp.Metrics.
+func (m *Metrics) MessageSent(address string, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.messagesSent.WithLabelValues(m.address(address), result).Inc()
+}
+
+// MessageReceived implements amqp.Metrics.
+func (m *Metrics) MessageReceived(address string) {
+	m.messagesReceived.WithLabelValues(m.address(address)).Inc()
+}
+
+// MessageSettled implements amqp.Metrics.
+func (m *Metrics) MessageSettled(address string, outcome string) {
+	m.messagesSettled.WithLabelValues(m.address(address), outcome).Inc()
+}
+
+// LinkCredit implements amqp.Metrics.
+func (m *Metrics) LinkCredit(address, link string, credit uint32) {
+	m.linkCredit.WithLabelValues(m.address(address), link).Set(float64(credit))
+}
+
+// LinkDetached implements amqp.Metrics.
+func (m *Metrics) LinkDetached(address, link string) {
+	m.linkCredit.DeleteLabelValues(m.address(address), link)
+}
+
+var _ amqp.Metrics = (*Metrics)(nil)
@@ -0,0 +1,102 @@
+package prometheus
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBroker(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := amqp.NewListener(ln, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- broker.New(&broker.Options{AutoCreateQueues: true}).Serve(ctx, l) }()
+	t.Cleanup(func() {
+		cancel()
+		<-served
+		_ = l.Close()
+	})
+	return "amqp://" + l.Addr().String()
+}
+
+func TestMetrics(t *testing.T) {
+	url := newTestBroker(t)
+
+	m := New(&Options{Namespace: "test", ConstLabels: prometheus.Labels{"app": "metrics"}})
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(m))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, err := amqp.Dial(url, &amqp.ConnOptions{Metrics: m})
+	require.NoError(t, err)
+	require.Equal(t, float64(1), testutil.ToFloat64(m.connsOpened))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.connsOpen))
+
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	sender, err := session.NewSender(ctx, "queue", nil)
+	require.NoError(t, err)
+	receiver, err := session.NewReceiver(ctx, "queue", &amqp.ReceiverOptions{Credit: 10, Name: "r1"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, sender.Send(ctx, amqp.NewMessage([]byte("hello"))))
+	}
+	for i := 0; i < 3; i++ {
+		msg, err := receiver.Receive(ctx)
+		require.NoError(t, err)
+		require.NoError(t, receiver.AcceptMessage(ctx, msg))
+	}
+	idle, err := session.NewReceiver(ctx, "queue", &amqp.ReceiverOptions{Credit: 5, Name: "r2"})
+	require.NoError(t, err)
+
+	require.Equal(t, float64(3), testutil.ToFloat64(m.messagesSent.WithLabelValues("queue", "success")))
+	require.Zero(t, testutil.ToFloat64(m.messagesSent.WithLabelValues("queue", "error")))
+	require.Equal(t, float64(3), testutil.ToFloat64(m.messagesReceived.WithLabelValues("queue")))
+	require.Equal(t, float64(3), testutil.ToFloat64(m.messagesSettled.WithLabelValues("queue", "accepted")))
+	// links on the same address report their credit separately
+	require.Equal(t, float64(10), testutil.ToFloat64(m.linkCredit.WithLabelValues("queue", "r1")))
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(m.linkCredit.WithLabelValues("queue", "r2")) == 5
+	}, time.Second, time.Millisecond)
+	require.Equal(t, float64(3), testutil.ToFloat64(m.framesSent.WithLabelValues("transfer")))
+	require.Equal(t, float64(3), testutil.ToFloat64(m.framesReceived.WithLabelValues("transfer")))
+	require.NotZero(t, testutil.ToFloat64(m.bytesSent))
+	require.NotZero(t, testutil.ToFloat64(m.bytesReceived))
+
+	// a detached link's credit is no longer exported
+	require.NoError(t, idle.Close(ctx))
+	require.Equal(t, 1, testutil.CollectAndCount(m.linkCredit))
+
+	require.NoError(t, conn.Close())
+	require.Equal(t, float64(0), testutil.ToFloat64(m.connsOpen))
+	require.Zero(t, testutil.ToFloat64(m.connErrors))
+
+	// every metric is exported with the namespace and constant labels
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	require.NotEmpty(t, families)
+	for _, f := range families {
+		require.Regexp(t, "^test_", f.GetName())
+		for _, metric := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			require.Equal(t, "metrics", labels["app"], f.GetName())
+		}
+	}
+}
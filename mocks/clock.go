@@ -0,0 +1,19 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/Azure/go-amqp/internal/clock"
+)
+
+// FakeClock is an amqp.Clock whose time only changes when advanced, so
+// tests can trigger keepalives, timeouts, and other timers deterministically.
+//
+// Use Timers to wait for the code under test to arm its timers
+// before calling Advance.
+type FakeClock = clock.Fake
+
+// NewFakeClock creates a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return clock.NewFake(now)
+}
@@ -0,0 +1,14 @@
+// Package mocks provides a mock AMQP peer for unit-testing code that uses
+// package amqp without a live broker.
+//
+// NetConn is a net.Conn passed to amqp.NewConn. Every frame the connection
+// writes is decoded and handed to a responder, which replies with encoded
+// frames built by EncodeFrame or the Perform* helpers. SendFrame sends frames
+// that aren't replies, such as transfers and flows, at any time.
+// FrameBuilder chains the helpers to build whole scenarios, such as
+// deliveries split across several transfers.
+//
+// The frames passed to the responder are pointers to the performative types,
+// such as Open and Attach, which are aliases of the types used by package
+// amqp, so their fields can be inspected and set directly.
+package mocks
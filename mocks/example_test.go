@@ -0,0 +1,81 @@
+package mocks_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/mocks"
+)
+
+func Example() {
+	// the responder replies to the frames sent by the client
+	netConn := mocks.NewNetConn(func(req mocks.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *mocks.AMQPProto:
+			return mocks.ProtoHeader(mocks.ProtoAMQP)
+		case *mocks.Open:
+			return mocks.PerformOpen("mock-broker")
+		case *mocks.Begin:
+			return mocks.PerformBegin(0)
+		case *mocks.Attach:
+			return mocks.ReceiverAttach(0, tt.Name, 0, amqp.ReceiverSettleModeFirst, nil)
+		case *mocks.Disposition:
+			state, _ := tt.State.(*mocks.StateAccepted)
+			fmt.Println("message accepted:", state != nil)
+			return nil, nil
+		case *mocks.Detach:
+			return mocks.PerformDetach(0, 0, nil)
+		case *mocks.End:
+			return mocks.PerformEnd(0, nil)
+		case *mocks.Close:
+			return mocks.PerformClose(nil)
+		default:
+			// ignore flow frames
+			return nil, nil
+		}
+	})
+
+	conn, err := amqp.NewConn(netConn, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	session, err := conn.NewSession(ctx, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rcv, err := session.NewReceiver(ctx, "test", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// deliver a message to the receiver
+	transfer, err := mocks.PerformTransfer(0, 0, 1, []byte("hello"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	netConn.SendFrame(transfer)
+
+	msg, err := rcv.Receive(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(msg.GetData()))
+	if err := rcv.AcceptMessage(ctx, msg); err != nil {
+		log.Fatal(err)
+	}
+	if err := rcv.Close(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// hello
+	// message accepted: true
+}
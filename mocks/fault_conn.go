@@ -0,0 +1,206 @@
+package mocks
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by the Read or Write call of a FaultConn
+// in which a FaultDisconnect or FaultPartialWrite is injected.
+var ErrInjectedFault = errors.New("mocks: injected fault")
+
+// FaultKind is the kind of failure injected by a FaultConn.
+type FaultKind int
+
+const (
+	// FaultDisconnect closes the connection. The Read or Write call in
+	// which it's injected returns ErrInjectedFault and subsequent calls fail.
+	FaultDisconnect FaultKind = iota
+
+	// FaultPartialWrite writes the first half of the data passed to Write
+	// and returns ErrInjectedFault. The connection is left open, so the
+	// peer receives a truncated frame.
+	FaultPartialWrite
+
+	// FaultTruncateRead returns the first half of the data read by Read and
+	// discards the rest, so the connection reads a truncated frame.
+	FaultTruncateRead
+)
+
+// Fault is a failure scheduled on a FaultConn.
+type Fault struct {
+	// Kind is the kind of failure.
+	Kind FaultKind
+
+	// Call is the 1-based index of the call in which the fault is injected.
+	// Writes are counted for FaultPartialWrite, Reads for FaultTruncateRead,
+	// and both for FaultDisconnect.
+	Call int
+}
+
+// FaultOptions contains the optional settings for configuring a FaultConn.
+type FaultOptions struct {
+	// Latency delays every Read and Write.
+	//
+	// Default: 0.
+	Latency time.Duration
+
+	// Jitter adds a random delay of up to Jitter to Latency.
+	//
+	// Default: 0.
+	Jitter time.Duration
+
+	// ShortReads makes every Read return a random number
+	// of bytes, fewer than requested when possible.
+	//
+	// Default: false.
+	ShortReads bool
+
+	// DisconnectAfter injects a FaultDisconnect once the duration has
+	// elapsed since the FaultConn was created. The connection is closed
+	// without waiting for a Read or Write call.
+	//
+	// Default: 0 (disabled).
+	DisconnectAfter time.Duration
+
+	// DisconnectProbability is the probability, between 0 and 1, that a
+	// FaultDisconnect is injected in any given Read or Write call.
+	//
+	// Default: 0.
+	DisconnectProbability float64
+
+	// Faults are the failures injected on schedule.
+	//
+	// Default: nil.
+	Faults []Fault
+
+	// Seed seeds the source of randomness, so runs with
+	// the same seed and calls inject the same faults.
+	//
+	// Default: the current time.
+	Seed int64
+}
+
+// FaultConn wraps a net.Conn to inject latency, short reads, partial writes,
+// truncated frames, and disconnects, so retry and recovery logic can be
+// verified against realistic failures.
+//
+// Pass it to amqp.NewConn, or return it from a net.Listener, in place of the
+// connection it wraps.
+type FaultConn struct {
+	net.Conn
+
+	opts FaultOptions
+
+	mu     sync.Mutex
+	rand   *rand.Rand
+	reads  int
+	writes int
+	timer  *time.Timer // nil unless DisconnectAfter is set
+}
+
+// NewFaultConn creates a FaultConn injecting failures into conn.
+//
+// opts: pass nil to accept the default values, which inject no failures.
+func NewFaultConn(conn net.Conn, opts *FaultOptions) *FaultConn {
+	f := &FaultConn{Conn: conn}
+	if opts != nil {
+		f.opts = *opts
+	}
+	seed := f.opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	f.rand = rand.New(rand.NewSource(seed))
+	if f.opts.DisconnectAfter > 0 {
+		f.timer = time.AfterFunc(f.opts.DisconnectAfter, func() {
+			_ = f.Conn.Close()
+		})
+	}
+	return f
+}
+
+// Read reads data from the wrapped connection, injecting the scheduled failures.
+func (f *FaultConn) Read(b []byte) (int, error) {
+	kind, ok, delay, size := f.next(false, len(b))
+	time.Sleep(delay)
+	if ok && kind == FaultDisconnect {
+		_ = f.Conn.Close()
+		return 0, ErrInjectedFault
+	}
+	n, err := f.Conn.Read(b[:size])
+	if ok && kind == FaultTruncateRead && n > 1 {
+		n /= 2
+	}
+	return n, err
+}
+
+// Write writes data to the wrapped connection, injecting the scheduled failures.
+func (f *FaultConn) Write(b []byte) (int, error) {
+	kind, ok, delay, _ := f.next(true, len(b))
+	time.Sleep(delay)
+	if !ok {
+		return f.Conn.Write(b)
+	}
+	switch kind {
+	case FaultDisconnect:
+		_ = f.Conn.Close()
+		return 0, ErrInjectedFault
+	case FaultPartialWrite:
+		n, err := f.Conn.Write(b[:len(b)/2])
+		if err == nil {
+			err = ErrInjectedFault
+		}
+		return n, err
+	default:
+		return f.Conn.Write(b)
+	}
+}
+
+// Close closes the wrapped connection.
+func (f *FaultConn) Close() error {
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	return f.Conn.Close()
+}
+
+// next counts a Read or Write call of n bytes and returns the fault to inject in
+// it, if any, along with the delay before the call and the number of bytes to read.
+func (f *FaultConn) next(write bool, n int) (kind FaultKind, ok bool, delay time.Duration, size int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var call int
+	if write {
+		f.writes++
+		call = f.writes
+	} else {
+		f.reads++
+		call = f.reads
+	}
+	for _, fault := range f.opts.Faults {
+		switch {
+		case fault.Kind == FaultDisconnect && fault.Call == f.reads+f.writes,
+			fault.Kind == FaultPartialWrite && write && fault.Call == call,
+			fault.Kind == FaultTruncateRead && !write && fault.Call == call:
+			kind, ok = fault.Kind, true
+		}
+	}
+	if !ok && f.opts.DisconnectProbability > 0 && f.rand.Float64() < f.opts.DisconnectProbability {
+		kind, ok = FaultDisconnect, true
+	}
+
+	delay = f.opts.Latency
+	if f.opts.Jitter > 0 {
+		delay += time.Duration(f.rand.Int63n(int64(f.opts.Jitter)))
+	}
+	size = n
+	if !write && f.opts.ShortReads && n > 1 {
+		size = 1 + f.rand.Intn(n-1)
+	}
+	return
+}
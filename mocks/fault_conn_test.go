@@ -0,0 +1,102 @@
+package mocks
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newFaultPipe returns a FaultConn and the peer end of its connection.
+func newFaultPipe(t *testing.T, opts *FaultOptions) (*FaultConn, net.Conn) {
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return NewFaultConn(client, opts), server
+}
+
+func TestFaultConnPartialWrite(t *testing.T) {
+	conn, peer := newFaultPipe(t, &FaultOptions{
+		Faults: []Fault{{Kind: FaultPartialWrite, Call: 2}},
+	})
+	read := make(chan []byte, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			b := make([]byte, 8)
+			n, _ := peer.Read(b)
+			read <- b[:n]
+		}
+	}()
+
+	n, err := conn.Write([]byte("12345678"))
+	require.NoError(t, err)
+	require.Equal(t, 8, n)
+	require.Equal(t, "12345678", string(<-read))
+
+	n, err = conn.Write([]byte("12345678"))
+	require.ErrorIs(t, err, ErrInjectedFault)
+	require.Equal(t, 4, n)
+	require.Equal(t, "1234", string(<-read))
+}
+
+func TestFaultConnTruncateRead(t *testing.T) {
+	conn, peer := newFaultPipe(t, &FaultOptions{
+		Faults: []Fault{{Kind: FaultTruncateRead, Call: 1}},
+	})
+	go func() { _, _ = peer.Write([]byte("12345678")) }()
+
+	b := make([]byte, 8)
+	n, err := conn.Read(b)
+	require.NoError(t, err)
+	require.Equal(t, "1234", string(b[:n]))
+}
+
+func TestFaultConnShortReads(t *testing.T) {
+	conn, peer := newFaultPipe(t, &FaultOptions{ShortReads: true, Seed: 1})
+	data := []byte("0123456789abcdef")
+	go func() { _, _ = peer.Write(data) }()
+
+	var got []byte
+	for len(got) < len(data) {
+		b := make([]byte, len(data))
+		n, err := conn.Read(b)
+		require.NoError(t, err)
+		require.Less(t, n, len(data))
+		got = append(got, b[:n]...)
+	}
+	require.Equal(t, data, got)
+}
+
+func TestFaultConnDisconnect(t *testing.T) {
+	conn, peer := newFaultPipe(t, &FaultOptions{
+		Faults: []Fault{{Kind: FaultDisconnect, Call: 2}},
+	})
+	go func() { _, _ = io.Copy(io.Discard, peer) }()
+
+	_, err := conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("hello"))
+	require.ErrorIs(t, err, ErrInjectedFault)
+	_, err = conn.Write([]byte("hello"))
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrInjectedFault))
+}
+
+func TestFaultConnDisconnectAfter(t *testing.T) {
+	conn, _ := newFaultPipe(t, &FaultOptions{DisconnectAfter: 10 * time.Millisecond})
+	// the pending Read fails once the connection is closed
+	_, err := conn.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+func TestFaultConnDisconnectProbability(t *testing.T) {
+	conn, peer := newFaultPipe(t, &FaultOptions{DisconnectProbability: 1})
+	go func() { _, _ = io.Copy(io.Discard, peer) }()
+	_, err := conn.Write([]byte("hello"))
+	require.ErrorIs(t, err, ErrInjectedFault)
+}
@@ -0,0 +1,174 @@
+package mocks
+
+import (
+	"fmt"
+
+	"github.com/Azure/go-amqp/internal/buffer"
+	"github.com/Azure/go-amqp/internal/encoding"
+	"github.com/Azure/go-amqp/internal/frames"
+)
+
+// FrameBuilder builds a sequence of encoded frames for scenario tests.
+// Each method appends one or more frames and returns the FrameBuilder, so
+// calls can be chained. The first error stops the building and is returned
+// by Frames and Bytes.
+//
+//	b, err := mocks.NewFrameBuilder().
+//		ProtoHeader(mocks.ProtoAMQP).
+//		Open("container").
+//		Begin(0).
+//		ReceiverAttach(0, "link", 0, amqp.ReceiverSettleModeFirst, nil).
+//		Transfer(0, 0, 1, mocks.EncodeDataSection([]byte("hello")), 3).
+//		Bytes()
+type FrameBuilder struct {
+	frames [][]byte
+	err    error
+}
+
+// NewFrameBuilder creates a FrameBuilder without any frames.
+func NewFrameBuilder() *FrameBuilder {
+	return &FrameBuilder{}
+}
+
+// Frames returns the encoded frames, one per element.
+// Pass them to NetConn.SendFrame individually.
+func (b *FrameBuilder) Frames() ([][]byte, error) {
+	return b.frames, b.err
+}
+
+// Bytes returns the concatenation of the encoded frames.
+func (b *FrameBuilder) Bytes() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	var raw []byte
+	for _, fr := range b.frames {
+		raw = append(raw, fr...)
+	}
+	return raw, nil
+}
+
+// add appends the frame returned by encode.
+func (b *FrameBuilder) add(fr []byte, err error) *FrameBuilder {
+	if b.err != nil {
+		return b
+	}
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.frames = append(b.frames, fr)
+	return b
+}
+
+// ProtoHeader appends the protocol header of the specified protocol.
+func (b *FrameBuilder) ProtoHeader(id ProtoID) *FrameBuilder {
+	return b.add(ProtoHeader(id))
+}
+
+// Frame appends an arbitrary frame of the specified type.
+func (b *FrameBuilder) Frame(t FrameType, channel uint16, body FrameBody) *FrameBuilder {
+	return b.add(EncodeFrame(t, channel, body))
+}
+
+// KeepAlive appends an empty frame.
+func (b *FrameBuilder) KeepAlive() *FrameBuilder {
+	return b.add([]byte{0x00, 0x00, 0x00, 0x08, 0x02, 0x00, 0x00, 0x00}, nil)
+}
+
+// Open appends an open performative, as encoded by PerformOpen.
+func (b *FrameBuilder) Open(containerID string) *FrameBuilder {
+	return b.add(PerformOpen(containerID))
+}
+
+// Begin appends a begin performative, as encoded by PerformBegin.
+func (b *FrameBuilder) Begin(remoteChannel uint16) *FrameBuilder {
+	return b.add(PerformBegin(remoteChannel))
+}
+
+// SenderAttach appends an attach performative, as encoded by SenderAttach.
+func (b *FrameBuilder) SenderAttach(channel uint16, linkName string, linkHandle uint32, mode SenderSettleMode) *FrameBuilder {
+	return b.add(SenderAttach(channel, linkName, linkHandle, mode))
+}
+
+// ReceiverAttach appends an attach performative, as encoded by ReceiverAttach.
+func (b *FrameBuilder) ReceiverAttach(channel uint16, linkName string, linkHandle uint32, mode ReceiverSettleMode, filter Filter) *FrameBuilder {
+	return b.add(ReceiverAttach(channel, linkName, linkHandle, mode, filter))
+}
+
+// Flow appends a flow performative granting credit to the link with the specified handle.
+func (b *FrameBuilder) Flow(channel uint16, linkHandle, deliveryCount, credit uint32) *FrameBuilder {
+	nextIncoming := uint32(0)
+	return b.Frame(FrameAMQP, channel, &frames.PerformFlow{
+		NextIncomingID: &nextIncoming,
+		IncomingWindow: 1000,
+		OutgoingWindow: 1000,
+		NextOutgoingID: nextIncoming + 1,
+		Handle:         &linkHandle,
+		DeliveryCount:  &deliveryCount,
+		LinkCredit:     &credit,
+	})
+}
+
+// Transfer appends the transfer performatives of a delivery, splitting the
+// encoded message in payload across the specified number of frames.
+// Use EncodeDataSection or amqp.Message.MarshalBinary to encode the message.
+func (b *FrameBuilder) Transfer(channel uint16, linkHandle, deliveryID uint32, payload []byte, frameCount int) *FrameBuilder {
+	if b.err != nil {
+		return b
+	}
+	if frameCount < 1 || frameCount > len(payload) {
+		b.err = fmt.Errorf("mocks: can't split %d bytes of payload across %d frames", len(payload), frameCount)
+		return b
+	}
+	format := uint32(0)
+	size := len(payload) / frameCount
+	for i := 0; i < frameCount; i++ {
+		chunk := payload[i*size : (i+1)*size]
+		if i == frameCount-1 {
+			chunk = payload[i*size:]
+		}
+		fr := &frames.PerformTransfer{
+			Handle:  linkHandle,
+			More:    i < frameCount-1,
+			Payload: chunk,
+		}
+		if i == 0 {
+			fr.DeliveryID = &deliveryID
+			fr.DeliveryTag = []byte("tag")
+			fr.MessageFormat = &format
+		}
+		b.Frame(FrameAMQP, channel, fr)
+	}
+	return b
+}
+
+// Disposition appends a settled disposition performative, as encoded by PerformDisposition.
+func (b *FrameBuilder) Disposition(role Role, channel uint16, firstID uint32, lastID *uint32, state DeliveryState) *FrameBuilder {
+	return b.add(PerformDisposition(role, channel, firstID, lastID, state))
+}
+
+// Detach appends a detach performative closing the link, as encoded by PerformDetach.
+func (b *FrameBuilder) Detach(channel uint16, linkHandle uint32, e *Error) *FrameBuilder {
+	return b.add(PerformDetach(channel, linkHandle, e))
+}
+
+// End appends an end performative, as encoded by PerformEnd.
+func (b *FrameBuilder) End(channel uint16, e *Error) *FrameBuilder {
+	return b.add(PerformEnd(channel, e))
+}
+
+// Close appends a close performative, as encoded by PerformClose.
+func (b *FrameBuilder) Close(e *Error) *FrameBuilder {
+	return b.add(PerformClose(e))
+}
+
+// EncodeDataSection encodes a message consisting of a single data section
+// containing data, for use as the payload of a transfer.
+func EncodeDataSection(data []byte) []byte {
+	buf := &buffer.Buffer{}
+	encoding.WriteDescriptor(buf, encoding.TypeCodeApplicationData)
+	// writing to a buffer only fails for data longer than the max uint32
+	_ = encoding.WriteBinary(buf, data)
+	return buf.Detach()
+}
@@ -0,0 +1,67 @@
+package mocks
+
+import (
+	"testing"
+
+	"github.com/Azure/go-amqp/internal/encoding"
+	"github.com/Azure/go-amqp/internal/frames"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameBuilder(t *testing.T) {
+	payload := EncodeDataSection([]byte("hello world"))
+	raw, err := NewFrameBuilder().
+		ProtoHeader(ProtoAMQP).
+		Open("container").
+		Begin(0).
+		ReceiverAttach(0, "link", 0, encoding.ReceiverSettleModeFirst, nil).
+		Flow(0, 0, 0, 10).
+		Transfer(0, 0, 1, payload, 3).
+		KeepAlive().
+		Detach(0, 0, nil).
+		End(0, nil).
+		Close(nil).
+		Frames()
+	require.NoError(t, err)
+	require.Len(t, raw, 12)
+
+	var bodies []FrameBody
+	for _, fr := range raw {
+		body, err := decodeFrame(fr)
+		require.NoError(t, err)
+		bodies = append(bodies, body)
+	}
+	require.IsType(t, &AMQPProto{}, bodies[0])
+	require.IsType(t, &Open{}, bodies[1])
+	require.IsType(t, &Begin{}, bodies[2])
+	require.IsType(t, &Attach{}, bodies[3])
+	require.IsType(t, &Flow{}, bodies[4])
+	require.IsType(t, &KeepAlive{}, bodies[8])
+	require.IsType(t, &Detach{}, bodies[9])
+	require.IsType(t, &End{}, bodies[10])
+	require.IsType(t, &Close{}, bodies[11])
+
+	// the payload is split across the transfers, and only the first carries the delivery ID
+	var joined []byte
+	for i, body := range bodies[5:8] {
+		tr, ok := body.(*frames.PerformTransfer)
+		require.True(t, ok)
+		require.Equal(t, i < 2, tr.More)
+		require.Equal(t, i == 0, tr.DeliveryID != nil)
+		joined = append(joined, tr.Payload...)
+	}
+	require.Equal(t, payload, joined)
+
+	all, err := NewFrameBuilder().Open("container").Begin(0).Bytes()
+	require.NoError(t, err)
+	require.Equal(t, append(append([]byte{}, raw[1]...), raw[2]...), all)
+}
+
+func TestFrameBuilderError(t *testing.T) {
+	_, err := NewFrameBuilder().
+		Open("container").
+		Transfer(0, 0, 1, []byte("ab"), 3).
+		Begin(0).
+		Bytes()
+	require.EqualError(t, err, "mocks: can't split 2 bytes of payload across 3 frames")
+}
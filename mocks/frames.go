@@ -0,0 +1,81 @@
+package mocks
+
+import (
+	"github.com/Azure/go-amqp/internal/encoding"
+	"github.com/Azure/go-amqp/internal/frames"
+)
+
+// FrameBody is the decoded body of a frame, passed to the responder of a NetConn.
+// It's a pointer to one of the performative or SASL* types, AMQPProto, or KeepAlive.
+type FrameBody = frames.FrameBody
+
+// Performatives exchanged once the connection has been negotiated,
+// named after the performatives in the AMQP specification.
+type (
+	Open        = frames.PerformOpen
+	Begin       = frames.PerformBegin
+	Attach      = frames.PerformAttach
+	Flow        = frames.PerformFlow
+	Transfer    = frames.PerformTransfer
+	Disposition = frames.PerformDisposition
+	Detach      = frames.PerformDetach
+	End         = frames.PerformEnd
+	Close       = frames.PerformClose
+)
+
+// Frames exchanged during SASL negotiation.
+type (
+	SASLInit       = frames.SASLInit
+	SASLMechanisms = frames.SASLMechanisms
+	SASLChallenge  = frames.SASLChallenge
+	SASLResponse   = frames.SASLResponse
+	SASLOutcome    = frames.SASLOutcome
+)
+
+// Terminus types of Attach.
+type (
+	Source      = frames.Source
+	Target      = frames.Target
+	Coordinator = frames.Coordinator
+)
+
+// Role is the role of a link endpoint, set in Attach and Disposition.
+type Role = encoding.Role
+
+const (
+	RoleSender   Role = encoding.RoleSender
+	RoleReceiver Role = encoding.RoleReceiver
+)
+
+// SASLCode is the outcome of SASL negotiation, set in SASLOutcome.
+type SASLCode = encoding.SASLCode
+
+const (
+	CodeSASLOK      SASLCode = encoding.CodeSASLOK
+	CodeSASLAuth    SASLCode = encoding.CodeSASLAuth
+	CodeSASLSysPerm SASLCode = encoding.CodeSASLSysPerm
+)
+
+// Delivery states set in Transfer and Disposition.
+type (
+	DeliveryState = encoding.DeliveryState
+	StateReceived = encoding.StateReceived
+	StateAccepted = encoding.StateAccepted
+	StateRejected = encoding.StateRejected
+	StateReleased = encoding.StateReleased
+	StateModified = encoding.StateModified
+)
+
+// Other types used by the fields of frames. Error, SenderSettleMode, and
+// ReceiverSettleMode are the same types as their counterparts in package amqp.
+type (
+	Error              = encoding.Error
+	SenderSettleMode   = encoding.SenderSettleMode
+	ReceiverSettleMode = encoding.ReceiverSettleMode
+	Symbol             = encoding.Symbol
+	MultiSymbol        = encoding.MultiSymbol
+	Filter             = encoding.Filter
+	DescribedType      = encoding.DescribedType
+	Milliseconds       = encoding.Milliseconds
+	Unsettled          = encoding.Unsettled
+)
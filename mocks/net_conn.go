@@ -1,12 +1,14 @@
 package mocks
 
 import (
+	"encoding/binary"
 	"errors"
 	"math"
 	"net"
 	"time"
 
 	"github.com/Azure/go-amqp/internal/buffer"
+	"github.com/Azure/go-amqp/internal/clock"
 	"github.com/Azure/go-amqp/internal/encoding"
 	"github.com/Azure/go-amqp/internal/frames"
 )
@@ -15,7 +17,7 @@ import (
 // Responder is invoked by Write when a frame is received.
 // Return a nil slice/nil error to swallow the frame.
 // Return a non-nil error to simulate a write error.
-func NewNetConn(resp func(frames.FrameBody) ([]byte, error)) *NetConn {
+func NewNetConn(resp func(FrameBody) ([]byte, error)) *NetConn {
 	return &NetConn{
 		ReadErr:  make(chan error),
 		WriteErr: make(chan error, 1),
@@ -47,6 +49,13 @@ type NetConn struct {
 	// Has a buffer of one so setting a pending error won't block.
 	WriteErr chan error
 
+	// Clock enforces read deadlines, set it to the FakeClock passed
+	// in amqp.ConnOptions.Clock to trigger idle timeouts by advancing it.
+	// It must be set before the connection is used.
+	//
+	// Default: the system clock.
+	Clock clock.Clock
+
 	resp      func(frames.FrameBody) ([]byte, error)
 	readDL    readTimer
 	readData  chan []byte
@@ -68,7 +77,7 @@ func (n *NetConn) SendKeepAlive() {
 
 // SendMultiFrameTransfer splits payload into 32-byte chunks, encodes, and sends to the client.
 // Payload must be big enough for at least two chunks.
-func (n *NetConn) SendMultiFrameTransfer(remoteChannel uint16, linkHandle, deliveryID uint32, payload []byte, edit func(int, *frames.PerformTransfer)) error {
+func (n *NetConn) SendMultiFrameTransfer(remoteChannel uint16, linkHandle, deliveryID uint32, payload []byte, edit func(int, *Transfer)) error {
 	bb, err := encodeMultiFrameTransfer(remoteChannel, linkHandle, deliveryID, payload, edit)
 	if err != nil {
 		return err
@@ -109,7 +118,7 @@ func (n *NetConn) Read(b []byte) (int, error) {
 }
 
 // Write is invoked by conn.connWriter when we're being sent frame
-// data.  Every call to Write will invoke the responder callback that
+// data.  Every frame written will invoke the responder callback that
 // must reply with one of three possibilities.
 //  1. an encoded frame and nil error
 //  2. a non-nil error to similate a write failure
@@ -129,16 +138,21 @@ func (n *NetConn) Write(b []byte) (int, error) {
 		// no fake write error
 	}
 
-	frame, err := decodeFrame(b)
-	if err != nil {
-		return 0, err
-	}
-	resp, err := n.resp(frame)
-	if err != nil {
-		return 0, err
-	}
-	if resp != nil {
-		n.readData <- resp
+	// the connection writes frames sent together in a single call
+	for rest := b; len(rest) > 0; {
+		size := frameSize(rest)
+		frame, err := decodeFrame(rest[:size])
+		if err != nil {
+			return 0, err
+		}
+		resp, err := n.resp(frame)
+		if err != nil {
+			return 0, err
+		}
+		if resp != nil {
+			n.readData <- resp
+		}
+		rest = rest[size:]
 	}
 	return len(b), nil
 }
@@ -169,7 +183,8 @@ func (n *NetConn) RemoteAddr() net.Addr {
 }
 
 func (n *NetConn) SetDeadline(t time.Time) error {
-	return errors.New("not used")
+	// called by conn.readSingleFrame while the connection is negotiated
+	return n.SetReadDeadline(t)
 }
 
 func (n *NetConn) SetReadDeadline(t time.Time) error {
@@ -178,7 +193,15 @@ func (n *NetConn) SetReadDeadline(t time.Time) error {
 	if n.readDL != nil && !n.readDL.Stop() {
 		<-n.readDL.C()
 	}
-	n.readDL = timer{t: time.NewTimer(time.Until(t))}
+	if t.IsZero() {
+		n.readDL = newNopTimer()
+		return nil
+	}
+	clk := n.Clock
+	if clk == nil {
+		clk = clock.Real
+	}
+	n.readDL = clk.NewTimer(t.Sub(clk.Now()))
 	return nil
 }
 
@@ -200,13 +223,13 @@ const (
 )
 
 // ProtoHeader adds the initial handshake frame to the list of responses.
-// This frame, and PerformOpen, are needed when calling amqp.New() to create a client.
+// This frame, and PerformOpen, are needed when calling amqp.NewConn to create a client.
 func ProtoHeader(id ProtoID) ([]byte, error) {
 	return []byte{'A', 'M', 'Q', 'P', byte(id), 1, 0, 0}, nil
 }
 
 // PerformOpen appends a PerformOpen frame with the specified container ID.
-// This frame, and ProtoHeader, are needed when calling amqp.New() to create a client.
+// This frame, and ProtoHeader, are needed when calling amqp.NewConn to create a client.
 func PerformOpen(containerID string) ([]byte, error) {
 	// send the default values for max channels and frame size
 	return EncodeFrame(FrameAMQP, 0, &frames.PerformOpen{
@@ -218,7 +241,7 @@ func PerformOpen(containerID string) ([]byte, error) {
 }
 
 // PerformBegin appends a PerformBegin frame with the specified remote channel ID.
-// This frame is needed when making a call to Client.NewSession().
+// This frame is needed when making a call to Conn.NewSession.
 func PerformBegin(remoteChannel uint16) ([]byte, error) {
 	return EncodeFrame(FrameAMQP, remoteChannel, &frames.PerformBegin{
 		RemoteChannel:  &remoteChannel,
@@ -230,8 +253,8 @@ func PerformBegin(remoteChannel uint16) ([]byte, error) {
 }
 
 // SenderAttach encodes a PerformAttach frame with the specified values.
-// This frame is needed when making a call to Session.NewSender().
-func SenderAttach(remoteChannel uint16, linkName string, linkHandle uint32, mode encoding.SenderSettleMode) ([]byte, error) {
+// This frame is needed when making a call to Session.NewSender.
+func SenderAttach(remoteChannel uint16, linkName string, linkHandle uint32, mode SenderSettleMode) ([]byte, error) {
 	return EncodeFrame(FrameAMQP, remoteChannel, &frames.PerformAttach{
 		Name:   linkName,
 		Handle: linkHandle,
@@ -247,8 +270,8 @@ func SenderAttach(remoteChannel uint16, linkName string, linkHandle uint32, mode
 }
 
 // ReceiverAttach appends a PerformAttach frame with the specified values.
-// This frame is needed when making a call to Session.NewReceiver().
-func ReceiverAttach(remoteChannel uint16, linkName string, linkHandle uint32, mode encoding.ReceiverSettleMode, filter encoding.Filter) ([]byte, error) {
+// This frame is needed when making a call to Session.NewReceiver.
+func ReceiverAttach(remoteChannel uint16, linkName string, linkHandle uint32, mode ReceiverSettleMode, filter Filter) ([]byte, error) {
 	return EncodeFrame(FrameAMQP, remoteChannel, &frames.PerformAttach{
 		Name:   linkName,
 		Handle: linkHandle,
@@ -285,7 +308,7 @@ func PerformTransfer(remoteChannel uint16, linkHandle, deliveryID uint32, payloa
 
 // PerformDisposition appends a PerformDisposition frame with the specified values.
 // The firstID MUST match the deliveryID value specified in PerformTransfer.
-func PerformDisposition(role encoding.Role, remoteChannel uint16, firstID uint32, lastID *uint32, state encoding.DeliveryState) ([]byte, error) {
+func PerformDisposition(role Role, remoteChannel uint16, firstID uint32, lastID *uint32, state DeliveryState) ([]byte, error) {
 	return EncodeFrame(FrameAMQP, remoteChannel, &frames.PerformDisposition{
 		Role:    role,
 		First:   firstID,
@@ -296,26 +319,26 @@ func PerformDisposition(role encoding.Role, remoteChannel uint16, firstID uint32
 }
 
 // PerformDetach encodes a PerformDetach frame with an optional error.
-func PerformDetach(remoteChannel uint16, linkHandle uint32, e *encoding.Error) ([]byte, error) {
+func PerformDetach(remoteChannel uint16, linkHandle uint32, e *Error) ([]byte, error) {
 	return EncodeFrame(FrameAMQP, remoteChannel, &frames.PerformDetach{Handle: linkHandle, Closed: true, Error: e})
 }
 
 // PerformEnd encodes a PerformEnd frame with an optional error.
-func PerformEnd(remoteChannel uint16, e *encoding.Error) ([]byte, error) {
+func PerformEnd(remoteChannel uint16, e *Error) ([]byte, error) {
 	return EncodeFrame(FrameAMQP, remoteChannel, &frames.PerformEnd{Error: e})
 }
 
 // PerformClose encodes a PerformClose frame with an optional error.
-func PerformClose(e *encoding.Error) ([]byte, error) {
+func PerformClose(e *Error) ([]byte, error) {
 	return EncodeFrame(FrameAMQP, 0, &frames.PerformClose{Error: e})
 }
 
-// AMQPProto is the frame type passed to FrameCallback() for the initial protocal handshake.
+// AMQPProto is the frame type passed to the responder for the initial protocal handshake.
 type AMQPProto struct {
 	frames.FrameBody
 }
 
-// KeepAlive is the frame type passed to FrameCallback() for keep-alive frames.
+// KeepAlive is the frame type passed to the responder for keep-alive frames.
 type KeepAlive struct {
 	frames.FrameBody
 }
@@ -339,7 +362,7 @@ const (
 )
 
 // EncodeFrame encodes the specified frame to be sent over the wire.
-func EncodeFrame(t FrameType, remoteChannel uint16, f frames.FrameBody) ([]byte, error) {
+func EncodeFrame(t FrameType, remoteChannel uint16, f FrameBody) ([]byte, error) {
 	bodyBuf := buffer.New([]byte{})
 	if err := encoding.Marshal(bodyBuf, f); err != nil {
 		return nil, err
@@ -361,6 +384,19 @@ func EncodeFrame(t FrameType, remoteChannel uint16, f frames.FrameBody) ([]byte,
 	return raw, nil
 }
 
+// frameSize returns the size of the first frame or protocol header in b.
+func frameSize(b []byte) int {
+	if len(b) < frames.HeaderSize || (b[0] == 'A' && b[1] == 'M' && b[2] == 'Q' && b[3] == 'P') {
+		return len(b)
+	}
+	size := int(binary.BigEndian.Uint32(b))
+	if size < frames.HeaderSize || size > len(b) {
+		// let decodeFrame report the malformed frame
+		return len(b)
+	}
+	return size
+}
+
 func decodeFrame(b []byte) (frames.FrameBody, error) {
 	if len(b) > 3 && b[0] == 'A' && b[1] == 'M' && b[2] == 'Q' && b[3] == 'P' {
 		return &AMQPProto{}, nil
@@ -460,15 +496,3 @@ func (n nopTimer) Stop() bool {
 	close(n.t)
 	return true
 }
-
-type timer struct {
-	t *time.Timer
-}
-
-func (t timer) C() <-chan time.Time {
-	return t.t.C
-}
-
-func (t timer) Stop() bool {
-	return t.t.Stop()
-}
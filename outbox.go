@@ -0,0 +1,71 @@
+package amqp
+
+import (
+	"fmt"
+	"time"
+)
+
+// OutboxOptions configures the outbox of a Producer, holding the messages
+// published while its sender is down. See ProducerOptions.Outbox.
+type OutboxOptions struct {
+	// MaxMessages is the maximum number of messages published but not yet
+	// completed. It replaces ProducerOptions.MaxBufferedMessages.
+	//
+	// Default: 10000.
+	MaxMessages int
+
+	// Overflow determines what's done with a message published while the
+	// outbox is full and the sender is down. While the sender is attached,
+	// Publish blocks until there's room.
+	//
+	// Default: OutboxOverflowBlock.
+	Overflow OutboxOverflowPolicy
+}
+
+// OutboxOverflowPolicy determines what a Producer does with a message
+// published while its outbox is full and its sender is down.
+type OutboxOverflowPolicy uint8
+
+const (
+	// OutboxOverflowBlock blocks Publish until there's room in the outbox.
+	OutboxOverflowBlock OutboxOverflowPolicy = iota
+
+	// OutboxOverflowReject returns an *OutboxFullError from Publish.
+	OutboxOverflowReject
+
+	// OutboxOverflowDropOldest drops the oldest message waiting in the
+	// outbox to make room. Its completion callback is called with an
+	// *OutboxFullError.
+	OutboxOverflowDropOldest
+)
+
+// String implements the fmt.Stringer interface for OutboxOverflowPolicy.
+func (p OutboxOverflowPolicy) String() string {
+	switch p {
+	case OutboxOverflowBlock:
+		return "block"
+	case OutboxOverflowReject:
+		return "reject"
+	case OutboxOverflowDropOldest:
+		return "drop-oldest"
+	default:
+		return fmt.Sprintf("unknown outbox overflow policy %d", uint8(p))
+	}
+}
+
+// OutboxFullError is returned for a message that didn't fit in the outbox
+// of a Producer while its sender was down, as configured by
+// OutboxOptions.Overflow.
+type OutboxFullError struct {
+	// MaxMessages is the size of the outbox.
+	MaxMessages int
+}
+
+// Error implements the error interface for OutboxFullError.
+func (e *OutboxFullError) Error() string {
+	return fmt.Sprintf("amqp: producer outbox is full (%d messages)", e.MaxMessages)
+}
+
+// outboxRetryDelay is the delay between attempts to send a message held
+// in the outbox once the retry policy stopped retrying.
+const outboxRetryDelay = time.Second
@@ -0,0 +1,115 @@
+package amqp
+
+import (
+	"github.com/Azure/go-amqp/internal/encoding"
+)
+
+// OutcomeType identifies the outcome of a delivery.
+type OutcomeType uint8
+
+const (
+	// OutcomeUnknown means no outcome was reported, as the message was
+	// sent pre-settled or the receiver settled it without one.
+	OutcomeUnknown OutcomeType = iota
+
+	// OutcomeAccepted means the receiver accepted the message.
+	OutcomeAccepted
+
+	// OutcomeRejected means the receiver rejected the message as invalid.
+	OutcomeRejected
+
+	// OutcomeReleased means the receiver didn't process the message,
+	// and it may be redelivered.
+	OutcomeReleased
+
+	// OutcomeModified means the receiver didn't process the message,
+	// and it may be redelivered after applying the modifications.
+	OutcomeModified
+)
+
+func (t OutcomeType) String() string {
+	switch t {
+	case OutcomeAccepted:
+		return "accepted"
+	case OutcomeRejected:
+		return "rejected"
+	case OutcomeReleased:
+		return "released"
+	case OutcomeModified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// Outcome is the outcome of a delivery, as reported by its receiver.
+type Outcome struct {
+	// Type is the type of outcome.
+	Type OutcomeType
+
+	// Error is the error provided with OutcomeRejected, if any.
+	Error *Error
+
+	// DeliveryFailed is set with OutcomeModified when the delivery
+	// counts as an unsuccessful delivery attempt.
+	DeliveryFailed bool
+
+	// UndeliverableHere is set with OutcomeModified when the message
+	// must not be redelivered to the same receiver.
+	UndeliverableHere bool
+
+	// Annotations contains the message annotations provided with
+	// OutcomeModified, to be merged into the message's annotations.
+	Annotations Annotations
+
+	// TransactionID is the ID of the transaction the receiver settled the
+	// message in, or nil if it wasn't settled in a transaction. Type is the
+	// provisional outcome, which only takes effect once the transaction commits.
+	TransactionID []byte
+}
+
+// newOutcome converts the delivery state received from the peer.
+func newOutcome(state encoding.DeliveryState) Outcome {
+	switch state := state.(type) {
+	case *encoding.StateAccepted:
+		return Outcome{Type: OutcomeAccepted}
+	case *encoding.StateRejected:
+		return Outcome{Type: OutcomeRejected, Error: state.Error}
+	case *encoding.StateReleased:
+		return Outcome{Type: OutcomeReleased}
+	case *encoding.StateModified:
+		return Outcome{
+			Type:              OutcomeModified,
+			DeliveryFailed:    state.DeliveryFailed,
+			UndeliverableHere: state.UndeliverableHere,
+			Annotations:       state.MessageAnnotations,
+		}
+	case *encoding.StateTransactional:
+		outcome := newOutcome(state.Outcome)
+		outcome.TransactionID = state.TxnID
+		return outcome
+	default:
+		return Outcome{}
+	}
+}
+
+// deliveryState converts o to the delivery state sent to the peer, ignoring
+// TransactionID. It returns nil for OutcomeUnknown.
+func (o Outcome) deliveryState() encoding.DeliveryState {
+	switch o.Type {
+	case OutcomeAccepted:
+		return &encoding.StateAccepted{}
+	case OutcomeRejected:
+		return &encoding.StateRejected{Error: o.Error}
+	case OutcomeReleased:
+		return &encoding.StateReleased{}
+	case OutcomeModified:
+		return &encoding.StateModified{
+			DeliveryFailed:     o.DeliveryFailed,
+			UndeliverableHere:  o.UndeliverableHere,
+			MessageAnnotations: o.Annotations,
+		}
+	default:
+		return nil
+	}
+}
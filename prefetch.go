@@ -0,0 +1,106 @@
+package amqp
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// prefetchQueue is the FIFO of messages received on a link but not yet
+// returned by Receive. It's a ring buffer with a fixed capacity, the
+// receiver's credit window. The buffer grows up to the capacity as it's
+// filled, so links with a large credit window that rarely use it, such as
+// the many idle links of a gateway, don't hold memory for it; once grown,
+// pushing and popping don't allocate.
+//
+// Messages are pushed by the receiver's mux and popped by any number of
+// goroutines calling Receive.
+type prefetchQueue struct {
+	mu   sync.Mutex
+	buf  []Message
+	size int   // maximum length of buf, the queue's capacity
+	head int   // index of the oldest message
+	n    int32 // number of messages, written with mu held and atomically
+
+	ready chan struct{} // signaled when a message can be popped
+	space chan struct{} // signaled when a message can be pushed
+}
+
+func newPrefetchQueue(capacity uint32) *prefetchQueue {
+	if capacity == 0 {
+		capacity = 1
+	}
+	return &prefetchQueue{
+		size:  int(capacity),
+		ready: make(chan struct{}, 1),
+		space: make(chan struct{}, 1),
+	}
+}
+
+// push adds msg to the queue, returning false if it's full.
+func (q *prefetchQueue) push(msg *Message) bool {
+	q.mu.Lock()
+	if int(q.n) == len(q.buf) {
+		if len(q.buf) == q.size {
+			q.mu.Unlock()
+			return false
+		}
+		q.grow()
+	}
+	q.buf[(q.head+int(q.n))%len(q.buf)] = *msg
+	atomic.AddInt32(&q.n, 1)
+	q.mu.Unlock()
+	signal(q.ready)
+	return true
+}
+
+// grow doubles the length of buf, up to size. Must be called with mu held.
+func (q *prefetchQueue) grow() {
+	size := 2 * len(q.buf)
+	if size < minPrefetchBuffer {
+		size = minPrefetchBuffer
+	}
+	if size > q.size {
+		size = q.size
+	}
+	buf := make([]Message, size)
+	n := copy(buf, q.buf[q.head:])
+	copy(buf[n:], q.buf[:q.head])
+	q.buf = buf
+	q.head = 0
+}
+
+// minPrefetchBuffer is the initial length of a prefetchQueue's buffer.
+const minPrefetchBuffer = 8
+
+// pop removes the oldest message from the queue, returning false if it's empty.
+func (q *prefetchQueue) pop() (Message, bool) {
+	q.mu.Lock()
+	if q.n == 0 {
+		q.mu.Unlock()
+		return Message{}, false
+	}
+	msg := q.buf[q.head]
+	q.buf[q.head] = Message{} // don't retain the message
+	q.head = (q.head + 1) % len(q.buf)
+	more := atomic.AddInt32(&q.n, -1) > 0
+	q.mu.Unlock()
+	signal(q.space)
+	if more {
+		// pass the signal on, in case other goroutines are waiting
+		signal(q.ready)
+	}
+	return msg, true
+}
+
+// len returns the number of messages in the queue.
+func (q *prefetchQueue) len() int {
+	return int(atomic.LoadInt32(&q.n))
+}
+
+// signal does a non-blocking send on ch, which has a buffer of one.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
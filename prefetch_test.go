@@ -0,0 +1,116 @@
+package amqp
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetchQueue(t *testing.T) {
+	q := newPrefetchQueue(3)
+	_, ok := q.pop()
+	require.False(t, ok)
+
+	// wrap around the ring buffer a few times
+	next := uint32(0)
+	for round := 0; round < 4; round++ {
+		for i := 0; i < 2; i++ {
+			require.True(t, q.push(&Message{deliveryID: next}))
+			next++
+		}
+		require.Equal(t, 2, q.len())
+		msg, ok := q.pop()
+		require.True(t, ok)
+		require.Equal(t, next-2, msg.deliveryID)
+		msg, ok = q.pop()
+		require.True(t, ok)
+		require.Equal(t, next-1, msg.deliveryID)
+	}
+
+	for i := 0; i < 3; i++ {
+		require.True(t, q.push(&Message{deliveryID: uint32(i)}))
+	}
+	require.False(t, q.push(&Message{}))
+	_, ok = q.pop()
+	require.True(t, ok)
+	// popping from a full queue signals there's space
+	select {
+	case <-q.space:
+	default:
+		t.Fatal("no space signaled")
+	}
+	require.True(t, q.push(&Message{}))
+}
+
+func TestPrefetchQueueGrowth(t *testing.T) {
+	q := newPrefetchQueue(20)
+	require.Zero(t, len(q.buf))
+
+	// grow while the ring has wrapped around
+	next, want := uint32(0), uint32(0)
+	for i := 0; i < minPrefetchBuffer; i++ {
+		require.True(t, q.push(&Message{deliveryID: next}))
+		next++
+	}
+	for i := 0; i < 3; i++ {
+		msg, ok := q.pop()
+		require.True(t, ok)
+		require.Equal(t, want, msg.deliveryID)
+		want++
+	}
+	for q.push(&Message{deliveryID: next}) {
+		next++
+	}
+	require.Equal(t, 20, q.len())
+	require.Equal(t, 20, len(q.buf))
+	for ; want < next; want++ {
+		msg, ok := q.pop()
+		require.True(t, ok)
+		require.Equal(t, want, msg.deliveryID)
+	}
+	require.Zero(t, q.len())
+}
+
+func TestPrefetchQueueConcurrentConsumers(t *testing.T) {
+	const count = 1000
+	q := newPrefetchQueue(8)
+
+	var wg sync.WaitGroup
+	received := make(chan uint32, count)
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range q.ready {
+				msg, ok := q.pop()
+				if !ok {
+					continue
+				}
+				if msg.deliveryID == count {
+					// pass the sentinel on to the other consumers
+					for !q.push(&msg) {
+						<-q.space
+					}
+					return
+				}
+				received <- msg.deliveryID
+			}
+		}()
+	}
+
+	for i := uint32(0); i <= count; i++ {
+		for !q.push(&Message{deliveryID: i}) {
+			<-q.space
+		}
+	}
+	wg.Wait()
+	close(received)
+
+	seen := make(map[uint32]bool, count)
+	for id := range received {
+		require.False(t, seen[id])
+		seen[id] = true
+	}
+	require.Len(t, seen, count)
+}
@@ -0,0 +1,520 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/go-amqp/internal/debug"
+)
+
+// ProducerOptions contains the optional settings for configuring a Producer.
+type ProducerOptions struct {
+	// MaxBufferedMessages is the maximum number of messages published but
+	// not yet completed. Publish blocks while the limit is reached, which
+	// bounds the memory held by the Producer.
+	//
+	// Default: 1000.
+	MaxBufferedMessages int
+
+	// MaxInFlight is the maximum number of messages sent concurrently,
+	// waiting for their outcome. Messages are sent in the order they're
+	// published, but with more than one in flight, a message retried after
+	// a failure can be delivered after messages published later.
+	//
+	// Default: 1.
+	MaxInFlight int
+
+	// RetryPolicy determines if, and when, a message that couldn't be sent
+	// is sent again, recreating the sender first if it failed.
+	//
+	// Default: ExponentialBackoff(nil).
+	RetryPolicy RetryPolicy
+
+	// Outbox, when set, holds the messages published while the sender is
+	// down, until it's attached again. They're then sent in the order they
+	// were published. Messages held in the outbox are retried until they're
+	// sent or the Producer is closed, unless they fail with an error that
+	// isn't retryable.
+	//
+	// Default: nil.
+	Outbox *OutboxOptions
+
+	// WAL, when set, persists the messages published until they complete.
+	// The messages that didn't complete before the process stopped, or
+	// before Close timed out, are sent again by the next Producer created
+	// with the same WAL, ahead of the messages it publishes. Messages can
+	// thus be sent more than once.
+	//
+	// Default: nil.
+	WAL WAL
+
+	// OnReplayed is called, as the done callback passed to Publish would
+	// be, once each message sent again from the WAL completes.
+	//
+	// Default: nil.
+	OnReplayed func(msg *Message, outcome Outcome, err error)
+}
+
+// ProducerStats contains statistics for a Producer. See Producer.Stats.
+type ProducerStats struct {
+	// Buffered is the number of messages published but not yet completed.
+	Buffered int
+
+	// Offline is true while the sender is down.
+	Offline bool
+
+	// Replayed is the number of messages held in the outbox while the
+	// sender was down, and sent once it was attached again.
+	Replayed uint64
+
+	// Overflowed is the number of messages rejected or dropped because
+	// the outbox was full.
+	Overflowed uint64
+}
+
+// Producer publishes messages asynchronously on a Sender.
+//
+// Published messages are buffered and sent in the background, and the
+// callback passed to Publish is called once each message completes. The
+// sender is attached by the sender function when the first message is sent,
+// and again after it fails with a retryable error. It's responsible for
+// dialing a new connection when the error requires it, as classified by
+// ClassifyError.
+//
+// A Producer is safe for concurrent use.
+type Producer struct {
+	newSender func(ctx context.Context) (*Sender, error)
+	policy    RetryPolicy
+	queue     chan producerMessage
+	outbox    *OutboxOptions // nil when disabled
+	wal       WAL            // nil when disabled
+	recovered chan struct{}  // closed once the messages in the WAL are queued
+	walErr    error          // error reading the WAL, set before recovered is closed
+
+	offline    int32  // set atomically while the sender is down
+	replayed   uint64 // updated atomically, see ProducerStats
+	overflowed uint64 // updated atomically, see ProducerStats
+
+	ctx    context.Context // canceled when Close gives up on the buffered messages
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // tracks the workers sending messages
+
+	closeOnce sync.Once
+	closing   chan struct{} // closed when Close is called, unblocks Publish
+	closeMu   sync.RWMutex  // held for reading by Publish while queueing, to close queue
+
+	mu      sync.Mutex
+	pending int             // messages published but not completed
+	flushed []chan struct{} // closed once pending drops to zero
+
+	sndMu sync.Mutex // held while attaching snd
+	snd   *Sender    // nil until attached, or after failing
+}
+
+// producerMessage is a message buffered by a Producer.
+type producerMessage struct {
+	msg     *Message
+	done    func(Outcome, error)
+	offline bool   // held while the sender was down
+	walSeq  uint64 // sequence number in the WAL, 0 when disabled
+}
+
+// errProducerClosed is returned by Publish after Close was called.
+var errProducerClosed = errors.New("amqp: producer has been closed")
+
+// producerDetachTimeout is how long a Producer waits for the peer to
+// acknowledge the detach of its sender.
+const producerDetachTimeout = 5 * time.Second
+
+// NewProducer creates a Producer publishing messages on the sender
+// returned by sender.
+//
+// opts: pass nil to accept the default values.
+func NewProducer(sender func(ctx context.Context) (*Sender, error), opts *ProducerOptions) *Producer {
+	p := &Producer{
+		newSender: sender,
+		closing:   make(chan struct{}),
+		recovered: make(chan struct{}),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	maxBuffered, maxInFlight := 1000, 1
+	var onReplayed func(*Message, Outcome, error)
+	if opts != nil {
+		p.wal = opts.WAL
+		onReplayed = opts.OnReplayed
+		if opts.MaxBufferedMessages > 0 {
+			maxBuffered = opts.MaxBufferedMessages
+		}
+		if opts.MaxInFlight > 0 {
+			maxInFlight = opts.MaxInFlight
+		}
+		p.policy = opts.RetryPolicy
+		if opts.Outbox != nil {
+			outbox := *opts.Outbox
+			if outbox.MaxMessages <= 0 {
+				outbox.MaxMessages = 10000
+			}
+			p.outbox = &outbox
+			maxBuffered = outbox.MaxMessages
+		}
+	}
+	if p.policy == nil {
+		p.policy = ExponentialBackoff(nil)
+	}
+
+	// messages being sent count toward the buffered ones
+	if maxInFlight > maxBuffered {
+		maxInFlight = maxBuffered
+	}
+	p.queue = make(chan producerMessage, maxBuffered-maxInFlight)
+	p.wg.Add(maxInFlight)
+	for i := 0; i < maxInFlight; i++ {
+		go p.run()
+	}
+	if p.wal != nil {
+		go p.replay(onReplayed)
+	} else {
+		close(p.recovered)
+	}
+	return p
+}
+
+// Publish buffers msg to be sent, blocking while MaxBufferedMessages are
+// buffered until one completes, ctx completes, or the Producer is closed.
+// With an outbox, OutboxOptions.Overflow applies while the sender is down.
+//
+// done is called once the message completes, from one of the Producer's
+// goroutines, with the outcome reported by the receiver, or with the error
+// that prevented sending it. It must not block. Pass nil if the completion
+// isn't needed.
+//
+// msg must not be modified until it completes.
+func (p *Producer) Publish(ctx context.Context, msg *Message, done func(Outcome, error)) error {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+
+	select {
+	case <-p.closing:
+		return errProducerClosed
+	default:
+	}
+
+	// the messages in the WAL are sent first
+	select {
+	case <-p.recovered:
+		if p.walErr != nil {
+			return p.walErr
+		}
+	case <-p.closing:
+		return errProducerClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	pm := producerMessage{msg: msg, done: done}
+	if p.wal != nil {
+		data, err := msg.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if pm.walSeq, err = p.wal.Append(data); err != nil {
+			return fmt.Errorf("amqp: writing producer WAL: %w", err)
+		}
+	}
+
+	p.mu.Lock()
+	p.pending++
+	p.mu.Unlock()
+
+	if p.outbox != nil && atomic.LoadInt32(&p.offline) == 1 {
+		pm.offline = true
+		if err := p.overflow(); err != nil {
+			p.unpublished(pm)
+			return err
+		}
+	}
+
+	select {
+	case p.queue <- pm:
+		return nil
+	case <-p.closing:
+		p.unpublished(pm)
+		return errProducerClosed
+	case <-ctx.Done():
+		p.unpublished(pm)
+		return ctx.Err()
+	}
+}
+
+// unpublished records that pm completed without being sent.
+func (p *Producer) unpublished(pm producerMessage) {
+	if pm.walSeq != 0 {
+		p.removeWAL(pm.walSeq)
+	}
+	p.completed()
+}
+
+// replay queues the messages in the WAL, calling onReplayed once each
+// completes.
+func (p *Producer) replay(onReplayed func(*Message, Outcome, error)) {
+	defer close(p.recovered)
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+
+	entries, err := p.wal.Entries()
+	if err != nil {
+		p.walErr = fmt.Errorf("amqp: reading producer WAL: %w", err)
+		return
+	}
+	for _, entry := range entries {
+		msg := &Message{}
+		if err := msg.UnmarshalBinary(entry.Data); err != nil {
+			p.walErr = fmt.Errorf("amqp: reading producer WAL entry %d: %w", entry.Seq, err)
+			return
+		}
+		pm := producerMessage{msg: msg, walSeq: entry.Seq}
+		if onReplayed != nil {
+			pm.done = func(outcome Outcome, err error) {
+				onReplayed(msg, outcome, err)
+			}
+		}
+
+		p.mu.Lock()
+		p.pending++
+		p.mu.Unlock()
+		select {
+		case p.queue <- pm:
+		case <-p.closing:
+			// the remaining messages stay in the WAL
+			p.completed()
+			return
+		}
+	}
+}
+
+// removeWAL removes the entry seq from the WAL. Failing to remove it only
+// means the message is sent again by the next Producer using the WAL.
+func (p *Producer) removeWAL(seq uint64) {
+	if err := p.wal.Remove(seq); err != nil {
+		debug.Log(1, "removing producer WAL entry %d: %v", seq, err)
+	}
+}
+
+// overflow applies the outbox's overflow policy while it's full. It returns
+// the error to return from Publish if the message can't be published.
+func (p *Producer) overflow() error {
+	for len(p.queue) == cap(p.queue) {
+		switch p.outbox.Overflow {
+		case OutboxOverflowReject:
+			atomic.AddUint64(&p.overflowed, 1)
+			return &OutboxFullError{MaxMessages: p.outbox.MaxMessages}
+		case OutboxOverflowDropOldest:
+			select {
+			case oldest := <-p.queue:
+				atomic.AddUint64(&p.overflowed, 1)
+				if oldest.done != nil {
+					oldest.done(Outcome{}, &OutboxFullError{MaxMessages: p.outbox.MaxMessages})
+				}
+				p.unpublished(oldest)
+			default:
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// Stats returns statistics for the Producer.
+func (p *Producer) Stats() ProducerStats {
+	p.mu.Lock()
+	buffered := p.pending
+	p.mu.Unlock()
+	return ProducerStats{
+		Buffered:   buffered,
+		Offline:    atomic.LoadInt32(&p.offline) == 1,
+		Replayed:   atomic.LoadUint64(&p.replayed),
+		Overflowed: atomic.LoadUint64(&p.overflowed),
+	}
+}
+
+// Flush blocks until the messages buffered have completed, or ctx completes.
+// Messages published while waiting are waited for too.
+func (p *Producer) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	if p.pending == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	flushed := make(chan struct{})
+	p.flushed = append(p.flushed, flushed)
+	p.mu.Unlock()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting messages, waits for the buffered messages to
+// complete, and detaches the sender.
+//
+// If ctx completes first, the messages not sent yet complete with
+// context.Canceled, and ctx.Err() is returned.
+func (p *Producer) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		close(p.closing)
+		p.closeMu.Lock()
+		close(p.queue)
+		p.closeMu.Unlock()
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(stopped)
+	}()
+
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		p.cancel()
+		<-stopped
+		err = ctx.Err()
+	}
+	p.cancel()
+
+	p.sndMu.Lock()
+	defer p.sndMu.Unlock()
+	if p.snd != nil {
+		p.closeSender(p.snd)
+		p.snd = nil
+	}
+	return err
+}
+
+// run sends the buffered messages until the Producer is closed.
+func (p *Producer) run() {
+	defer p.wg.Done()
+	for pm := range p.queue {
+		outcome, err := p.send(&pm)
+		if err == nil && pm.offline {
+			atomic.AddUint64(&p.replayed, 1)
+		}
+		// messages canceled by Close are sent again from the WAL
+		if pm.walSeq != 0 && (err == nil || p.ctx.Err() == nil) {
+			p.removeWAL(pm.walSeq)
+		}
+		if pm.done != nil {
+			pm.done(outcome, err)
+		}
+		p.completed()
+	}
+}
+
+// send sends pm's message, retrying according to the retry policy. With an
+// outbox, it keeps retrying while the sender is down.
+func (p *Producer) send(pm *producerMessage) (Outcome, error) {
+	for attempt := 1; ; attempt++ {
+		down := false
+		snd, err := p.sender()
+		if err == nil {
+			var outcome Outcome
+			if outcome, err = snd.SendWithOutcome(p.ctx, pm.msg); err == nil {
+				atomic.StoreInt32(&p.offline, 0)
+				return outcome, nil
+			}
+			if linkFailed(err) {
+				p.dropSender(snd)
+				down = true
+			}
+		} else {
+			down = true
+		}
+		if down {
+			atomic.StoreInt32(&p.offline, 1)
+			pm.offline = p.outbox != nil
+		}
+
+		if p.ctx.Err() != nil {
+			return Outcome{}, p.ctx.Err()
+		}
+		if !IsRetryable(err) {
+			return Outcome{}, err
+		}
+		delay, ok := p.policy.Delay(attempt, err)
+		if !ok {
+			if !down || p.outbox == nil {
+				return Outcome{}, err
+			}
+			delay = outboxRetryDelay
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-p.ctx.Done():
+			timer.Stop()
+			return Outcome{}, p.ctx.Err()
+		}
+	}
+}
+
+// sender returns the attached sender, attaching a new one if needed.
+func (p *Producer) sender() (*Sender, error) {
+	p.sndMu.Lock()
+	defer p.sndMu.Unlock()
+	if p.snd == nil {
+		snd, err := p.newSender(p.ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.snd = snd
+	}
+	return p.snd, nil
+}
+
+// dropSender detaches snd, which failed, unless it was already replaced.
+func (p *Producer) dropSender(snd *Sender) {
+	p.sndMu.Lock()
+	defer p.sndMu.Unlock()
+	if p.snd == snd {
+		p.snd = nil
+		go p.closeSender(snd)
+	}
+}
+
+func (p *Producer) closeSender(snd *Sender) {
+	ctx, cancel := context.WithTimeout(context.Background(), producerDetachTimeout)
+	defer cancel()
+	_ = snd.Close(ctx)
+}
+
+// completed records that a published message completed.
+func (p *Producer) completed() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending--
+	if p.pending == 0 {
+		for _, flushed := range p.flushed {
+			close(flushed)
+		}
+		p.flushed = nil
+	}
+}
+
+// linkFailed returns true if err means the link it was returned by
+// is no longer usable.
+func linkFailed(err error) bool {
+	var detachErr *DetachError
+	var sessionErr *SessionError
+	var connErr *ConnError
+	return errors.As(err, &detachErr) || errors.As(err, &sessionErr) || errors.As(err, &connErr)
+}
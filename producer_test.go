@@ -0,0 +1,240 @@
+package amqp_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProducer(t *testing.T) {
+	// the first attach fails
+	b := broker.New(&broker.Options{
+		AutoCreateQueues: true,
+		FaultHook: broker.NewScript(broker.FaultRule{
+			Point:   broker.FaultPointAttach,
+			Address: "out",
+			Count:   1,
+			Fault:   broker.Fault{Error: &amqp.Error{Condition: amqp.ErrCondResourceLimitExceeded}},
+		}).Hook,
+	})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+	out, err := b.DeclareQueue("out")
+	require.NoError(t, err)
+
+	var dials int
+	producer := amqp.NewProducer(func(ctx context.Context) (*amqp.Sender, error) {
+		dials++
+		conn, err := amqp.Dial(srv.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		session, err := conn.NewSession(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return session.NewSender(ctx, "out", nil)
+	}, &amqp.ProducerOptions{
+		MaxBufferedMessages: 2,
+		RetryPolicy:         amqp.ConstantBackoff(time.Millisecond, 3),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var mu sync.Mutex
+	outcomes := map[string]amqp.Outcome{}
+	var errs []error
+	for _, body := range []string{"first", "second", "third"} {
+		body := body
+		require.NoError(t, producer.Publish(ctx, amqp.NewMessage([]byte(body)), func(outcome amqp.Outcome, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			}
+			outcomes[body] = outcome
+		}))
+	}
+	require.NoError(t, producer.Flush(ctx))
+
+	// the sender was attached again
+	require.Equal(t, 2, dials)
+	require.Empty(t, errs)
+	require.Len(t, outcomes, 3)
+	for _, outcome := range outcomes {
+		require.Equal(t, amqp.OutcomeAccepted, outcome.Type)
+	}
+	require.Equal(t, 3, out.Len())
+
+	require.NoError(t, producer.Close(ctx))
+	require.Error(t, producer.Publish(ctx, amqp.NewMessage([]byte("closed")), nil))
+}
+
+func TestProducerCloseTimeout(t *testing.T) {
+	// the sender can't be attached
+	attaching := make(chan struct{})
+	producer := amqp.NewProducer(func(ctx context.Context) (*amqp.Sender, error) {
+		close(attaching)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, nil)
+
+	completed := make(chan error, 1)
+	require.NoError(t, producer.Publish(context.Background(), amqp.NewMessage([]byte("test")), func(_ amqp.Outcome, err error) {
+		completed <- err
+	}))
+	<-attaching
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, producer.Close(ctx), context.DeadlineExceeded)
+	require.ErrorIs(t, <-completed, context.Canceled)
+}
+
+func TestProducerOutbox(t *testing.T) {
+	b := broker.New(&broker.Options{AutoCreateQueues: true})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+	conn, err := amqp.Dial(srv.URL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the broker is unreachable until down is cleared
+	down := int32(1)
+	producer := amqp.NewProducer(func(ctx context.Context) (*amqp.Sender, error) {
+		if atomic.LoadInt32(&down) == 1 {
+			return nil, io.EOF
+		}
+		session, err := conn.NewSession(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return session.NewSender(ctx, "out", nil)
+	}, &amqp.ProducerOptions{
+		RetryPolicy: amqp.ConstantBackoff(time.Millisecond, 1000),
+		Outbox: &amqp.OutboxOptions{
+			MaxMessages: 3,
+			Overflow:    amqp.OutboxOverflowDropOldest,
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var mu sync.Mutex
+	errs := map[string]error{}
+	publish := func(body string) {
+		require.NoError(t, producer.Publish(ctx, amqp.NewMessage([]byte(body)), func(_ amqp.Outcome, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs[body] = err
+		}))
+	}
+	publish("first")
+	require.Eventually(t, func() bool { return producer.Stats().Offline }, time.Second, time.Millisecond)
+
+	// the outbox is full, the oldest message waiting is dropped
+	for _, body := range []string{"second", "third", "fourth"} {
+		publish(body)
+	}
+	require.Equal(t, amqp.ProducerStats{Buffered: 3, Offline: true, Overflowed: 1}, producer.Stats())
+
+	atomic.StoreInt32(&down, 0)
+	require.NoError(t, producer.Flush(ctx))
+	require.Equal(t, amqp.ProducerStats{Replayed: 3, Overflowed: 1}, producer.Stats())
+	var fullErr *amqp.OutboxFullError
+	require.ErrorAs(t, errs["second"], &fullErr)
+	require.Equal(t, 3, fullErr.MaxMessages)
+	require.NoError(t, producer.Close(ctx))
+
+	// the messages were sent in order
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	rcv, err := session.NewReceiver(ctx, "out", nil)
+	require.NoError(t, err)
+	for _, body := range []string{"first", "third", "fourth"} {
+		require.NoError(t, errs[body])
+		msg, err := rcv.Receive(ctx)
+		require.NoError(t, err)
+		require.Equal(t, body, string(msg.GetData()))
+		require.NoError(t, rcv.AcceptMessage(ctx, msg))
+	}
+}
+
+func TestProducerWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "producer.wal")
+	wal, err := amqp.NewFileWAL(path, nil)
+	require.NoError(t, err)
+
+	// the first process stops before its sender is attached
+	attaching := make(chan struct{})
+	var once sync.Once
+	producer := amqp.NewProducer(func(ctx context.Context) (*amqp.Sender, error) {
+		once.Do(func() { close(attaching) })
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, &amqp.ProducerOptions{WAL: wal})
+	for _, body := range []string{"first", "second"} {
+		require.NoError(t, producer.Publish(context.Background(), amqp.NewMessage([]byte(body)), nil))
+	}
+	<-attaching
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, producer.Close(ctx), context.DeadlineExceeded)
+	require.NoError(t, wal.Close())
+
+	b := broker.New(&broker.Options{AutoCreateQueues: true})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+	out, err := b.DeclareQueue("out")
+	require.NoError(t, err)
+	conn, err := amqp.Dial(srv.URL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the next one sends the messages that didn't complete first
+	wal, err = amqp.NewFileWAL(path, nil)
+	require.NoError(t, err)
+	defer wal.Close()
+	var mu sync.Mutex
+	var replayed []string
+	producer = amqp.NewProducer(func(ctx context.Context) (*amqp.Sender, error) {
+		session, err := conn.NewSession(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return session.NewSender(ctx, "out", nil)
+	}, &amqp.ProducerOptions{
+		WAL: wal,
+		OnReplayed: func(msg *amqp.Message, outcome amqp.Outcome, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil && outcome.Type == amqp.OutcomeAccepted {
+				replayed = append(replayed, string(msg.GetData()))
+			}
+		},
+	})
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, producer.Publish(ctx, amqp.NewMessage([]byte("third")), nil))
+	require.NoError(t, producer.Flush(ctx))
+	require.NoError(t, producer.Close(ctx))
+	require.Equal(t, []string{"first", "second"}, replayed)
+	require.Equal(t, 3, out.Len())
+
+	// the WAL is empty once the messages completed
+	entries, err := wal.Entries()
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,50 @@
+package amqp
+
+// Profile adjusts the client to interoperate with brokers that deviate
+// from the AMQP specification.
+//
+// Use one of the predefined profiles, or a custom one combining the
+// adjustments needed. The zero value follows the specification.
+type Profile struct {
+	// Name identifies the profile.
+	Name string
+
+	// AssumeRequestedSettleModes treats settlement modes omitted from the
+	// peer's attach as the ones requested, rather than as the defaults,
+	// for brokers that don't echo back the modes they agreed to.
+	AssumeRequestedSettleModes bool
+
+	// EchoFlow sets the echo flag on the flow frames sent by receivers,
+	// for brokers that only report their link state, including the
+	// completion of drain requests, when asked to.
+	EchoFlow bool
+
+	// FlowBeforeAttach holds the flow frames received for a link before
+	// the peer's attach, delivering them once it's attached, for brokers
+	// that grant credit before replying to the attach. Without it, those
+	// frames are dropped and senders can wait for credit indefinitely.
+	FlowBeforeAttach bool
+}
+
+var (
+	// ProfileIBMMQ is the Profile for the AMQP channels of IBM MQ.
+	ProfileIBMMQ = Profile{
+		Name:                       "ibm-mq",
+		AssumeRequestedSettleModes: true,
+		FlowBeforeAttach:           true,
+	}
+
+	// ProfileQpidBrokerJ is the Profile for Qpid Broker-J releases
+	// prior to 7.0.
+	ProfileQpidBrokerJ = Profile{
+		Name:     "qpid-broker-j",
+		EchoFlow: true,
+	}
+
+	// ProfileActiveMQ5 is the Profile for the AMQP transport of ActiveMQ 5.x.
+	ProfileActiveMQ5 = Profile{
+		Name:                       "activemq-5",
+		AssumeRequestedSettleModes: true,
+		EchoFlow:                   true,
+	}
+)
@@ -0,0 +1,113 @@
+package amqp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp/internal/encoding"
+	"github.com/Azure/go-amqp/internal/frames"
+	"github.com/Azure/go-amqp/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileFlowBeforeAttach(t *testing.T) {
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *frames.PerformAttach:
+			// credit is granted before the attach is answered
+			return mocks.NewFrameBuilder().
+				Flow(0, 0, 0, 10).
+				SenderAttach(0, tt.Name, 0, SenderSettleModeSettled).
+				Bytes()
+		case *frames.PerformTransfer:
+			return nil, nil
+		}
+		return senderFrameHandlerNoUnhandled(SenderSettleModeSettled)(req)
+	}
+	netConn := mocks.NewNetConn(responder)
+	client, err := NewConn(netConn, &ConnOptions{Profile: ProfileIBMMQ})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	snd, err := session.NewSender(ctx, "target", &SenderOptions{SettlementMode: SenderSettleModeSettled.Ptr()})
+	cancel()
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	require.NoError(t, snd.Send(ctx, NewMessage([]byte("hello"))))
+	cancel()
+	require.NoError(t, client.Close())
+}
+
+func TestProfileAssumeRequestedSettleModes(t *testing.T) {
+	for name, profile := range map[string]Profile{
+		"spec":    {},
+		"profile": {AssumeRequestedSettleModes: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			responder := func(req frames.FrameBody) ([]byte, error) {
+				switch tt := req.(type) {
+				case *frames.PerformAttach:
+					// the settlement modes are omitted
+					return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformAttach{
+						Name:   tt.Name,
+						Role:   encoding.RoleSender,
+						Source: &frames.Source{Address: "test"},
+					})
+				}
+				return receiverFrameHandlerNoUnhandled(ReceiverSettleModeFirst)(req)
+			}
+			netConn := mocks.NewNetConn(responder)
+			client, err := NewConn(netConn, &ConnOptions{Profile: profile})
+			require.NoError(t, err)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			session, err := client.NewSession(ctx, nil)
+			cancel()
+			require.NoError(t, err)
+			ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{SettlementMode: ReceiverSettleModeSecond.Ptr()})
+			cancel()
+			if profile.AssumeRequestedSettleModes {
+				require.NoError(t, err)
+				require.Equal(t, ReceiverSettleModeSecond, *r.l.receiverSettleMode)
+			} else {
+				require.ErrorContains(t, err, "receiver settlement mode")
+			}
+			require.NoError(t, client.Close())
+		})
+	}
+}
+
+func TestProfileEchoFlow(t *testing.T) {
+	flows := make(chan *frames.PerformFlow, 1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		if fr, ok := req.(*frames.PerformFlow); ok {
+			flows <- fr
+			return nil, nil
+		}
+		return receiverFrameHandlerNoUnhandled(ReceiverSettleModeFirst)(req)
+	}
+	netConn := mocks.NewNetConn(responder)
+	client, err := NewConn(netConn, &ConnOptions{Profile: ProfileQpidBrokerJ})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	_, err = session.NewReceiver(ctx, "source", nil)
+	cancel()
+	require.NoError(t, err)
+
+	select {
+	case fr := <-flows:
+		require.True(t, fr.Echo)
+	case <-time.After(time.Second):
+		t.Fatal("no flow sent")
+	}
+	require.NoError(t, client.Close())
+}
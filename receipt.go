@@ -0,0 +1,76 @@
+package amqp
+
+import (
+	"context"
+)
+
+// SendReceipt reports the settlement of a message sent with
+// Sender.SendWithReceipt.
+type SendReceipt struct {
+	tag  []byte
+	done chan struct{}
+
+	// set before done is closed
+	outcome Outcome
+	err     error
+}
+
+// SendWithReceipt sends msg without waiting for the receiver to settle it.
+// It returns once the message is transferred, with a SendReceipt that's
+// notified when the receiver settles it. This lets producers pipeline
+// messages, typically with ReceiverSettleModeSecond, and track their
+// outcomes asynchronously.
+//
+// As with SendWithOutcome, a rejected message isn't reported as an error.
+func (s *Sender) SendWithReceipt(ctx context.Context, msg *Message) (_ *SendReceipt, err error) {
+	defer func() { err = s.l.translateErr(err) }()
+
+	done, tag, release, err := s.beginSend(ctx, msg)
+	if err != nil {
+		s.l.metrics().MessageSent(s.l.target.Address, err)
+		return nil, err
+	}
+
+	r := &SendReceipt{tag: tag, done: make(chan struct{})}
+	go func() {
+		defer release()
+		select {
+		case state := <-done:
+			r.outcome = newOutcome(state)
+		case <-s.l.detached:
+			select {
+			case state := <-done:
+				// settled as the link was detached
+				r.outcome = newOutcome(state)
+			default:
+				r.err = s.l.translateErr(s.l.err)
+			}
+		}
+		s.l.metrics().MessageSent(s.l.target.Address, r.err)
+		close(r.done)
+	}()
+	return r, nil
+}
+
+// DeliveryTag returns the delivery tag of the message.
+func (r *SendReceipt) DeliveryTag() []byte {
+	return r.tag
+}
+
+// Done returns a channel that's closed once the message is settled by the
+// receiver, or the link is detached before it is.
+func (r *SendReceipt) Done() <-chan struct{} {
+	return r.done
+}
+
+// Wait blocks until the message is settled by the receiver, ctx completes,
+// or the link is detached, returning the outcome reported by the receiver.
+// Canceling ctx only stops waiting, the receipt is still notified.
+func (r *SendReceipt) Wait(ctx context.Context) (Outcome, error) {
+	select {
+	case <-r.done:
+		return r.outcome, r.err
+	case <-ctx.Done():
+		return Outcome{}, ctx.Err()
+	}
+}
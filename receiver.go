@@ -12,7 +12,6 @@ import (
 	"github.com/Azure/go-amqp/internal/debug"
 	"github.com/Azure/go-amqp/internal/encoding"
 	"github.com/Azure/go-amqp/internal/frames"
-	"github.com/Azure/go-amqp/internal/shared"
 )
 
 // Default link options
@@ -20,6 +19,8 @@ const (
 	defaultLinkCredit      = 1
 	defaultLinkBatching    = false
 	defaultLinkBatchMaxAge = 5 * time.Second
+
+	defaultMaxDecompressedSize = 64 << 20
 )
 
 type messageDisposition struct {
@@ -31,26 +32,75 @@ type messageDisposition struct {
 type Receiver struct {
 	l link
 	// message receiving
-	receiverReady         chan struct{}       // receiver sends on this when mux is paused to indicate it can handle more messages
-	messages              chan Message        // used to send completed messages to receiver
-	unsettledMessages     map[string]struct{} // used to keep track of messages being handled downstream
-	unsettledMessagesLock sync.RWMutex        // lock to protect concurrent access to unsettledMessages
-	msgBuf                buffer.Buffer       // buffered bytes for current message
-	more                  bool                // if true, buf contains a partial message
-	msg                   Message             // current message being decoded
-
-	autoSendFlow bool                    // automatically send flow frames as credit becomes available
-	batching     bool                    // enable batching of message dispositions
-	batchMaxAge  time.Duration           // maximum time between the start n batch and sending the batch to the server
-	dispositions chan messageDisposition // message dispositions are sent on this channel when batching is enabled
-	maxCredit    uint32                  // maximum allowed inflight messages
-	inFlight     inFlight                // used to track message disposition when rcv-settle-mode == second
-	creditor     creditor                // manages credits via calls to IssueCredit/DrainCredit
+	receiverReady chan struct{}         // receiver sends on this when mux is paused to indicate it can handle more messages
+	messages      *prefetchQueue        // used to send completed messages to receiver
+	unsettled     deliveryMap[struct{}] // used to keep track of messages being handled downstream, by delivery ID
+	msgBuf        buffer.Buffer         // buffered bytes for current message
+	more          bool                  // if true, buf contains a partial message
+	msg           Message               // current message being decoded
+
+	autoSendFlow        bool                    // automatically send flow frames as credit becomes available
+	discardExpired      bool                    // release messages that have expired on arrival
+	dedup               *DedupCache             // settles messages already accepted on arrival, nil when disabled
+	batching            bool                    // enable batching of message dispositions
+	batchMaxAge         time.Duration           // maximum time between the start n batch and sending the batch to the server
+	batchMaxSize        uint32                  // maximum number of dispositions in a batch, zero for maxCredit
+	dispositions        chan messageDisposition // message dispositions are sent on this channel when batching is enabled
+	maxCredit           uint32                  // maximum allowed inflight messages
+	inFlight            inFlight                // used to track message disposition when rcv-settle-mode == second
+	creditor            creditor                // manages credits via calls to IssueCredit/DrainCredit
+	decodeLimits        *buffer.Limits          // limits applied when decoding messages, nil to use the conn's limits
+	zeroCopy            bool                    // decoded messages take ownership of msgBuf instead of copying from it
+	pooled              bool                    // received messages are taken from messagePool
+	decompression       []Compression           // schemes used to decompress data payloads based on content-encoding
+	maxDecompressedSize uint64                  // limit on the size of decompressed data payloads
+	slowConsumer        stallTimer              // detects a prefetch queue that stays full, owned by mux
+	maxMessageSize      uint64                  // the receiver's own limit on message size, zero if unlimited
+	oversized           OversizedMessagePolicy  // what's done with messages larger than the limit
+	discarding          bool                    // the rest of the current oversized delivery is discarded
+	charges             receiverCharges         // resources held in the session's budget
+	opts                ReceiverOptions         // the options the receiver was created with, used by Reattach
+
+	resume *unsettledDeliveries // unsettled deliveries resumed by Reattach, nil when disabled
+}
+
+// OversizedMessagePolicy determines what a Receiver does with a message
+// larger than ReceiverOptions.MaxMessageSize.
+type OversizedMessagePolicy uint8
+
+const (
+	// OversizedMessageDetach detaches the link with the
+	// amqp:link:message-size-exceeded condition. The receiver's methods
+	// return a *DetachError wrapping a *MessageSizeError.
+	OversizedMessageDetach OversizedMessagePolicy = iota
+
+	// OversizedMessageReject settles the delivery with the rejected outcome
+	// and the amqp:link:message-size-exceeded condition, discarding it
+	// without buffering the rest of its transfers.
+	OversizedMessageReject
+
+	// OversizedMessageModify settles the delivery with the modified outcome,
+	// marked as failed and undeliverable here, discarding it without
+	// buffering the rest of its transfers. Brokers typically redeliver it to
+	// another consumer.
+	OversizedMessageModify
+)
+
+// zeroCopyPool contains message buffers released via Message.Release
+// for reuse by receivers with zero-copy decoding enabled.
+var zeroCopyPool sync.Pool
+
+// messagePool contains the messages released via Message.Release
+// by receivers with ReceiverOptions.PooledMessages enabled.
+var messagePool = sync.Pool{
+	New: func() any { return new(Message) },
 }
 
 // IssueCredit adds credits to be requested in the next flow
 // request.
-func (r *Receiver) IssueCredit(credit uint32) error {
+func (r *Receiver) IssueCredit(credit uint32) (err error) {
+	defer func() { err = r.l.translateErr(err) }()
+
 	if r.autoSendFlow {
 		return errors.New("issueCredit can only be used with receiver links using manual credit management")
 	}
@@ -70,11 +120,17 @@ func (r *Receiver) IssueCredit(credit uint32) error {
 
 // DrainCredit sets the drain flag on the next flow frame and
 // waits for the drain to be acknowledged.
-func (r *Receiver) DrainCredit(ctx context.Context) error {
+func (r *Receiver) DrainCredit(ctx context.Context) (err error) {
+	defer func() { err = r.l.translateErr(err) }()
+
 	if r.autoSendFlow {
 		return errors.New("drain can only be used with receiver links using manual credit management")
 	}
 
+	if err := r.l.lazy.ensure(ctx); err != nil {
+		return err
+	}
+
 	// cause mux() to check our flow conditions.
 	select {
 	case r.receiverReady <- struct{}{}:
@@ -93,6 +149,10 @@ func (r *Receiver) DrainCredit(ctx context.Context) error {
 // than SenderSettleModeSettled, you *must* take an action on the message by calling
 // one of the following: AcceptMessage, RejectMessage, ReleaseMessage, ModifyMessage.
 func (r *Receiver) Prefetched() *Message {
+	if !r.l.lazy.isAttached() {
+		return nil
+	}
+
 	select {
 	case r.receiverReady <- struct{}{}:
 	default:
@@ -100,15 +160,32 @@ func (r *Receiver) Prefetched() *Message {
 
 	// non-blocking receive to ensure buffered messages are
 	// delivered regardless of whether the link has been closed.
-	select {
-	case msg := <-r.messages:
-		debug.Log(3, "Receive() non blocking %d", msg.deliveryID)
-		msg.rcvr = r
-		return &msg
-	default:
+	msg, ok := r.messages.pop()
+	if !ok {
 		// done draining messages
 		return nil
 	}
+	debug.Log(3, "Receive() non blocking %d", msg.deliveryID)
+	return r.newMessage(msg)
+}
+
+// newMessage returns a *Message holding msg, taken from messagePool
+// if the receiver uses pooled messages.
+func (r *Receiver) newMessage(msg Message) *Message {
+	var m *Message
+	if r.pooled {
+		m = messagePool.Get().(*Message)
+		msg.pooled = true
+	} else {
+		m = new(Message)
+	}
+	*m = msg
+	m.rcvr = r
+	if m.chargedBytes != 0 {
+		r.charges.received(m.chargedBytes)
+		m.chargedBytes = 0
+	}
+	return m
 }
 
 // Receive returns the next message from the sender.
@@ -117,37 +194,55 @@ func (r *Receiver) Prefetched() *Message {
 // Once a message is received, and if the sender is configured in any mode other
 // than SenderSettleModeSettled, you *must* take an action on the message by calling
 // one of the following: AcceptMessage, RejectMessage, ReleaseMessage, ModifyMessage.
-func (r *Receiver) Receive(ctx context.Context) (*Message, error) {
+func (r *Receiver) Receive(ctx context.Context) (_ *Message, err error) {
+	defer func() { err = r.l.translateErr(err) }()
+
+	if err := r.l.lazy.ensure(ctx); err != nil {
+		return nil, err
+	}
+
 	if msg := r.Prefetched(); msg != nil {
 		return msg, nil
 	}
 
 	// wait for the next message
-	select {
-	case msg := <-r.messages:
-		debug.Log(3, "Receive() blocking %d", msg.deliveryID)
-		msg.rcvr = r
-		return &msg, nil
-	case <-r.l.detached:
-		return nil, r.l.err
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	for {
+		select {
+		case <-r.messages.ready:
+			if msg, ok := r.messages.pop(); ok {
+				debug.Log(3, "Receive() blocking %d", msg.deliveryID)
+				return r.newMessage(msg), nil
+			}
+		case <-r.l.detached:
+			return nil, r.l.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
 }
 
 // Accept notifies the server that the message has been
 // accepted and does not require redelivery.
-func (r *Receiver) AcceptMessage(ctx context.Context, msg *Message) error {
+func (r *Receiver) AcceptMessage(ctx context.Context, msg *Message) (err error) {
+	defer func() { err = r.l.translateErr(err) }()
+
 	if !msg.shouldSendDisposition() {
+		r.dedup.accepted(msg)
 		return nil
 	}
-	return r.messageDisposition(ctx, msg, &encoding.StateAccepted{})
+	if err := r.messageDisposition(ctx, msg, &encoding.StateAccepted{}); err != nil {
+		return err
+	}
+	r.dedup.accepted(msg)
+	return nil
 }
 
 // Reject notifies the server that the message is invalid.
 //
 // Rejection error is optional.
-func (r *Receiver) RejectMessage(ctx context.Context, msg *Message, e *Error) error {
+func (r *Receiver) RejectMessage(ctx context.Context, msg *Message, e *Error) (err error) {
+	defer func() { err = r.l.translateErr(err) }()
+
 	if !msg.shouldSendDisposition() {
 		return nil
 	}
@@ -156,7 +251,9 @@ func (r *Receiver) RejectMessage(ctx context.Context, msg *Message, e *Error) er
 
 // Release releases the message back to the server. The message
 // may be redelivered to this or another consumer.
-func (r *Receiver) ReleaseMessage(ctx context.Context, msg *Message) error {
+func (r *Receiver) ReleaseMessage(ctx context.Context, msg *Message) (err error) {
+	defer func() { err = r.l.translateErr(err) }()
+
 	if !msg.shouldSendDisposition() {
 		return nil
 	}
@@ -164,7 +261,9 @@ func (r *Receiver) ReleaseMessage(ctx context.Context, msg *Message) error {
 }
 
 // Modify notifies the server that the message was not acted upon and should be modifed.
-func (r *Receiver) ModifyMessage(ctx context.Context, msg *Message, options *ModifyMessageOptions) error {
+func (r *Receiver) ModifyMessage(ctx context.Context, msg *Message, options *ModifyMessageOptions) (err error) {
+	defer func() { err = r.l.translateErr(err) }()
+
 	if !msg.shouldSendDisposition() {
 		return nil
 	}
@@ -179,6 +278,36 @@ func (r *Receiver) ModifyMessage(ctx context.Context, msg *Message, options *Mod
 		})
 }
 
+// SettleByTag settles the unsettled delivery with the delivery tag tag with
+// outcome, without the *Message it was received as. It's intended for
+// deliveries received before the link was reattached with Reattach, which
+// requires ReceiverOptions.ResumeDeliveries. The outcome of a delivery the
+// sender hasn't resumed yet is sent once it's resumed.
+//
+// OutcomeUnknown isn't a valid outcome. If outcome.TransactionID is set, the
+// delivery is settled in that transaction.
+func (r *Receiver) SettleByTag(ctx context.Context, tag []byte, outcome Outcome) (err error) {
+	defer func() { err = r.l.translateErr(err) }()
+
+	if r.resume == nil {
+		return errors.New("amqp: settling by delivery tag requires ReceiverOptions.ResumeDeliveries")
+	}
+	state := outcome.deliveryState()
+	if state == nil {
+		return fmt.Errorf("amqp: invalid outcome %s", outcome.Type)
+	}
+	if id, ok := r.resume.find(tag); ok {
+		return r.messageDisposition(ctx, &Message{deliveryID: id, txnID: outcome.TransactionID}, state)
+	}
+	if outcome.TransactionID != nil {
+		state = &encoding.StateTransactional{TxnID: outcome.TransactionID, Outcome: state}
+	}
+	if !r.resume.setRecoveredState(tag, state) {
+		return fmt.Errorf("amqp: no unsettled delivery with tag %x", tag)
+	}
+	return nil
+}
+
 // ModifyMessageOptions contains the optional parameters to ModifyMessage.
 type ModifyMessageOptions struct {
 	// DeliveryFailed indicates that the server must consider this an
@@ -220,24 +349,116 @@ func (r *Receiver) LinkSourceFilterValue(name string) any {
 	return filter.Value
 }
 
+// RemoteProperties returns the link properties the peer sent in its attach
+// performative, or nil if it sent none or the receiver isn't attached yet.
+func (r *Receiver) RemoteProperties() map[string]any {
+	return r.l.remoteProperties()
+}
+
+// RemoteOfferedCapabilities returns the link capabilities the peer offered
+// in its attach performative, or nil if the receiver isn't attached yet.
+func (r *Receiver) RemoteOfferedCapabilities() []string {
+	offered, _ := r.l.remoteCapabilities()
+	return offered
+}
+
+// RemoteDesiredCapabilities returns the link capabilities the peer desired
+// in its attach performative, or nil if the receiver isn't attached yet.
+func (r *Receiver) RemoteDesiredCapabilities() []string {
+	_, desired := r.l.remoteCapabilities()
+	return desired
+}
+
+// RemoteSource returns the source of the link as attached by the peer, or
+// nil if the receiver isn't attached yet.
+func (r *Receiver) RemoteSource() *Terminus {
+	if r.l.remoteAttach == nil {
+		return nil
+	}
+	return newSourceTerminus(r.l.remoteAttach.Source)
+}
+
+// RemoteTarget returns the target of the link as attached by the peer, or
+// nil if the receiver isn't attached yet.
+func (r *Receiver) RemoteTarget() *Terminus {
+	if r.l.remoteAttach == nil {
+		return nil
+	}
+	return newTargetTerminus(r.l.remoteAttach.Target)
+}
+
+// RemoteMaxMessageSize returns the maximum message size the peer sent in
+// its attach performative, zero if it has no maximum or the receiver isn't
+// attached yet.
+func (r *Receiver) RemoteMaxMessageSize() uint64 {
+	if r.l.remoteAttach == nil {
+		return 0
+	}
+	return r.l.remoteAttach.MaxMessageSize
+}
+
+// Open attaches a Receiver created with ReceiverOptions.LazyAttach if it
+// isn't attached yet. It's a no-op for other receivers.
+func (r *Receiver) Open(ctx context.Context) (err error) {
+	defer func() { err = r.l.translateErr(err) }()
+
+	return r.l.lazy.ensure(ctx)
+}
+
 // Close closes the Receiver and AMQP link.
 //
 // If ctx expires while waiting for servers response, ctx.Err() will be returned.
 // The session will continue to wait for the response until the Session or Client
 // is closed.
-func (r *Receiver) Close(ctx context.Context) error {
+func (r *Receiver) Close(ctx context.Context) (err error) {
+	defer func() { err = r.l.translateErr(err) }()
+
 	return r.l.closeLink(ctx)
 }
 
+// Detach suspends the Receiver, detaching its link without closing it.
+// The peer retains the link's terminus state, so the link can be resumed
+// later with Reattach. The Receiver's methods return a *DetachError with
+// Suspended set once it's detached.
+func (r *Receiver) Detach(ctx context.Context) (err error) {
+	defer func() { err = r.l.translateErr(err) }()
+
+	return r.l.detachLink(ctx)
+}
+
+// Reattach attaches the link of a Receiver that was suspended, by Detach or
+// by the peer, again on the same session. It returns a new Receiver for the
+// link, with the same name, termini, and options as r. With
+// ReceiverOptions.ResumeDeliveries, the unsettled deliveries of r are
+// resumed by the new link.
+func (r *Receiver) Reattach(ctx context.Context) (_ *Receiver, err error) {
+	defer func() { err = r.l.session.conn.translateErr(err) }()
+	if !r.l.isSuspended() {
+		return nil, errors.New("amqp: receiver isn't suspended")
+	}
+
+	opts := r.opts
+	opts.Name = r.l.key.name
+	opts.DynamicAddress = false
+	opts.FollowRedirects = false
+	opts.LazyAttach = false
+	nr, err := newReceiver(r.Address(), r.l.session, &opts)
+	if err != nil {
+		return nil, err
+	}
+	if r.resume != nil {
+		nr.resume.recover(r.resume)
+	}
+	if err := nr.attach(ctx); err != nil {
+		return nil, err
+	}
+	nr.startBatching()
+	return nr, nil
+}
+
 // returns the error passed in
 func (r *Receiver) closeWithError(de *Error) error {
-	r.l.closeOnce.Do(func() {
-		r.l.detachErrorMu.Lock()
-		r.l.detachError = de
-		r.l.detachErrorMu.Unlock()
-		close(r.l.close)
-	})
-	return &DetachError{inner: de}
+	return r.l.closeWithError(de)
 }
 
 func (r *Receiver) dispositionBatcher() {
@@ -248,14 +469,14 @@ func (r *Receiver) dispositionBatcher() {
 	// accepted, and one for the rejected/released message. If messages are
 	// accepted out of order, send any existing batch and the current message.
 	var (
-		batchSize    = r.maxCredit
+		batchSize    = r.batchSize()
 		batchStarted bool
 		first        uint32
 		last         uint32
 	)
 
 	// create an unstarted timer
-	batchTimer := time.NewTimer(1 * time.Minute)
+	batchTimer := r.l.timers().NewTimer(1 * time.Minute)
 	batchTimer.Stop()
 	defer batchTimer.Stop()
 
@@ -304,12 +525,12 @@ func (r *Receiver) dispositionBatcher() {
 				}
 				batchStarted = false
 				if !batchTimer.Stop() {
-					<-batchTimer.C // batch timer must be drained if stop returns false
+					<-batchTimer.C() // batch timer must be drained if stop returns false
 				}
 			}
 
 		// maxBatchAge elapsed, send batch
-		case <-batchTimer.C:
+		case <-batchTimer.C():
 			lastCopy := last
 			err := r.sendDisposition(first, &lastCopy, &encoding.StateAccepted{})
 			if err != nil {
@@ -344,6 +565,11 @@ func (r *Receiver) sendDisposition(first uint32, last *uint32, state encoding.De
 }
 
 func (r *Receiver) messageDisposition(ctx context.Context, msg *Message, state encoding.DeliveryState) error {
+	if msg.txnID != nil {
+		// the outcome only takes effect once the transaction commits
+		state = &encoding.StateTransactional{TxnID: msg.txnID, Outcome: state}
+	}
+
 	var wait chan error
 	if r.l.receiverSettleMode != nil && *r.l.receiverSettleMode == ReceiverSettleModeSecond {
 		debug.Log(3, "RX (messageDisposition): add %d to inflight", msg.deliveryID)
@@ -362,15 +588,26 @@ func (r *Receiver) messageDisposition(ctx context.Context, msg *Message, state e
 			return err
 		}
 	}
+	r.charges.settled(msg.deliveryID)
+	r.l.metrics().MessageSettled(r.l.source.Address, outcomeName(state))
 
 	if wait == nil {
+		if r.resume != nil {
+			r.resume.settle(msg.deliveryID, msg.deliveryID)
+		}
 		return nil
 	}
+	if r.resume != nil {
+		r.resume.setState(msg.deliveryID, state)
+	}
 
 	select {
 	case err := <-wait:
 		// we've received confirmation of disposition
 		r.deleteUnsettled(msg)
+		if r.resume != nil {
+			r.resume.settle(msg.deliveryID, msg.deliveryID)
+		}
 		msg.settled = true
 		return err
 	case <-ctx.Done():
@@ -379,55 +616,74 @@ func (r *Receiver) messageDisposition(ctx context.Context, msg *Message, state e
 }
 
 func (r *Receiver) addUnsettled(msg *Message) {
-	r.unsettledMessagesLock.Lock()
-	r.unsettledMessages[string(msg.DeliveryTag)] = struct{}{}
-	r.unsettledMessagesLock.Unlock()
+	r.unsettled.store(msg.deliveryID, struct{}{})
 }
 
 func (r *Receiver) deleteUnsettled(msg *Message) {
-	r.unsettledMessagesLock.Lock()
-	delete(r.unsettledMessages, string(msg.DeliveryTag))
-	r.unsettledMessagesLock.Unlock()
+	r.unsettled.remove(msg.deliveryID)
 }
 
 func (r *Receiver) countUnsettled() int {
-	r.unsettledMessagesLock.RLock()
-	count := len(r.unsettledMessages)
-	r.unsettledMessagesLock.RUnlock()
-	return count
+	return r.unsettled.len()
 }
 
 func newReceiver(source string, session *Session, opts *ReceiverOptions) (*Receiver, error) {
+	name, err := newLinkName(session)
+	if err != nil {
+		return nil, err
+	}
 	r := &Receiver{
 		l: link{
-			key:      linkKey{shared.RandString(40), encoding.RoleReceiver},
+			key:      linkKey{name, encoding.RoleReceiver},
 			session:  session,
 			close:    make(chan struct{}),
 			detached: make(chan struct{}),
+			debugReq: make(chan chan string),
 			source:   &frames.Source{Address: source},
 			target:   new(frames.Target),
 		},
-		autoSendFlow:  true,
-		receiverReady: make(chan struct{}, 1),
-		batching:      defaultLinkBatching,
-		batchMaxAge:   defaultLinkBatchMaxAge,
-		maxCredit:     defaultLinkCredit,
+		autoSendFlow:        true,
+		receiverReady:       make(chan struct{}, 1),
+		batching:            defaultLinkBatching,
+		batchMaxAge:         defaultLinkBatchMaxAge,
+		maxCredit:           defaultLinkCredit,
+		maxDecompressedSize: defaultMaxDecompressedSize,
 	}
 
 	if opts == nil {
 		return r, nil
 	}
+	r.opts = *opts
 
 	r.batching = opts.Batching
 	if opts.BatchMaxAge > 0 {
 		r.batchMaxAge = opts.BatchMaxAge
 	}
+	r.batchMaxSize = opts.BatchMaxSize
 	for _, v := range opts.Capabilities {
 		r.l.target.Capabilities = append(r.l.target.Capabilities, encoding.Symbol(v))
 	}
 	if opts.Credit > 0 {
 		r.maxCredit = opts.Credit
 	}
+	if opts.DecodeLimits != nil {
+		if opts.DecodeLimits.MaxDepth < 0 {
+			return nil, fmt.Errorf("invalid DecodeLimits.MaxDepth value %d", opts.DecodeLimits.MaxDepth)
+		}
+		limits := opts.DecodeLimits.limits()
+		r.decodeLimits = &limits
+	}
+	r.decompression = opts.Decompression
+	r.discardExpired = opts.DiscardExpired
+	r.dedup = opts.Dedup
+	if err := r.l.setTimeouts(opts.AttachTimeout, opts.DetachTimeout); err != nil {
+		return nil, err
+	}
+	if opts.SlowConsumerThreshold < 0 {
+		return nil, fmt.Errorf("invalid SlowConsumerThreshold %d", opts.SlowConsumerThreshold)
+	}
+	r.slowConsumer.clock = r.l.timers()
+	r.slowConsumer.threshold = opts.SlowConsumerThreshold
 	if opts.Durability > DurabilityUnsettledState {
 		return nil, fmt.Errorf("invalid Durability %d", opts.Durability)
 	}
@@ -452,8 +708,19 @@ func newReceiver(source string, session *Session, opts *ReceiverOptions) (*Recei
 	if opts.ManualCredits {
 		r.autoSendFlow = false
 	}
+	if opts.MaxDecompressedSize > 0 {
+		r.maxDecompressedSize = opts.MaxDecompressedSize
+	}
 	if opts.MaxMessageSize > 0 {
 		r.l.maxMessageSize = opts.MaxMessageSize
+		r.maxMessageSize = opts.MaxMessageSize
+	}
+	if opts.OversizedMessages > OversizedMessageModify {
+		return nil, fmt.Errorf("invalid OversizedMessages %d", opts.OversizedMessages)
+	}
+	r.oversized = opts.OversizedMessages
+	if opts.ResumeDeliveries {
+		r.resume = newUnsettledDeliveries()
 	}
 	if opts.Name != "" {
 		r.l.key.name = opts.Name
@@ -467,6 +734,9 @@ func newReceiver(source string, session *Session, opts *ReceiverOptions) (*Recei
 			r.l.properties[encoding.Symbol(k)] = v
 		}
 	}
+	r.l.setCapabilities(opts.OfferedCapabilities, opts.DesiredCapabilities)
+	r.zeroCopy = opts.ZeroCopy
+	r.pooled = opts.PooledMessages
 	if opts.RequestedSenderSettleMode != nil {
 		if rsm := *opts.RequestedSenderSettleMode; rsm > SenderSettleModeMixed {
 			return nil, fmt.Errorf("invalid RequestedSenderSettleMode %d", rsm)
@@ -483,6 +753,11 @@ func newReceiver(source string, session *Session, opts *ReceiverOptions) (*Recei
 	for _, v := range opts.SenderCapabilities {
 		r.l.source.Capabilities = append(r.l.source.Capabilities, encoding.Symbol(v))
 	}
+	if opts.RoutingType > RoutingTypeMulticast {
+		return nil, fmt.Errorf("invalid RoutingType %d", opts.RoutingType)
+	}
+	r.l.source.Capabilities = append(r.l.source.Capabilities, opts.RoutingType.capabilities()...)
+	r.l.source.DistributionMode = encoding.Symbol(opts.DistributionMode)
 	if opts.SenderDurability != DurabilityNone {
 		r.l.source.Durable = opts.SenderDurability
 	}
@@ -495,11 +770,47 @@ func newReceiver(source string, session *Session, opts *ReceiverOptions) (*Recei
 	return r, nil
 }
 
+// startBatching starts the dispositionBatcher if batching is enabled.
+// It's called once the link has been attached.
+func (r *Receiver) startBatching() {
+	// batching is just extra overhead when batches hold a single disposition
+	if r.batchSize() == 1 {
+		r.batching = false
+	}
+
+	// create dispositions channel and start dispositionBatcher if batching enabled
+	if r.batching {
+		// buffer dispositions chan to prevent disposition sends from blocking
+		r.dispositions = make(chan messageDisposition, r.maxCredit)
+		go r.dispositionBatcher()
+	}
+}
+
+// batchSize returns the maximum number of dispositions in a batch.
+func (r *Receiver) batchSize() uint32 {
+	if r.batchMaxSize > 0 && r.batchMaxSize < r.maxCredit {
+		return r.batchMaxSize
+	}
+	return r.maxCredit
+}
+
+// prepareAttach initializes the state required before the link is attached.
+func (r *Receiver) prepareAttach() {
+	// TODO: remove double-buffering
+	r.l.rx = make(chan frames.FrameBody, r.maxCredit)
+
+	if r.decodeLimits != nil {
+		r.msgBuf.SetLimits(*r.decodeLimits)
+	} else {
+		r.msgBuf.SetLimits(r.l.session.conn.decodeLimits)
+	}
+	r.msgBuf.SetZeroCopy(r.zeroCopy)
+}
+
 // attach sends the Attach performative to establish the link with its parent session.
 // this is automatically called by the new*Link constructors.
 func (r *Receiver) attach(ctx context.Context) error {
-	// TODO: remove double-buffering
-	r.l.rx = make(chan frames.FrameBody, r.maxCredit)
+	r.prepareAttach()
 
 	if err := r.l.attach(ctx, func(pa *frames.PerformAttach) {
 		pa.Role = encoding.RoleReceiver
@@ -507,6 +818,9 @@ func (r *Receiver) attach(ctx context.Context) error {
 			pa.Source = new(frames.Source)
 		}
 		pa.Source.Dynamic = r.l.dynamicAddr
+		if r.resume != nil {
+			pa.Unsettled = r.resume.unsettledMap()
+		}
 	}, func(pa *frames.PerformAttach) {
 		if r.l.source == nil {
 			r.l.source = new(frames.Source)
@@ -518,12 +832,16 @@ func (r *Receiver) attach(ctx context.Context) error {
 		// deliveryCount is a sequence number, must initialize to sender's initial sequence number
 		r.l.deliveryCount = pa.InitialDeliveryCount
 		// buffer receiver so that link.mux doesn't block
-		r.messages = make(chan Message, r.maxCredit)
-		r.unsettledMessages = map[string]struct{}{}
+		r.messages = newPrefetchQueue(r.maxCredit)
+		r.charges.budget = r.l.session.budget
 		// copy the received filter values
 		if pa.Source != nil {
 			r.l.source.Filter = pa.Source.Filter
 		}
+		// deliveries the sender doesn't have won't be resumed
+		if r.resume != nil && !pa.IncompleteUnsettled {
+			r.resume.forget(pa.Unsettled)
+		}
 	}); err != nil {
 		return err
 	}
@@ -534,27 +852,36 @@ func (r *Receiver) attach(ctx context.Context) error {
 }
 
 func (r *Receiver) mux() {
-	defer r.l.muxDetach(context.Background(), func() {
-		// unblock any in flight message dispositions
-		r.inFlight.clear(r.l.err)
+	defer func() {
+		ctx, cancel := r.l.detachContext(0)
+		defer cancel()
+		r.l.muxDetach(ctx, func() {
+			// unblock any in flight message dispositions
+			r.inFlight.clear(r.l.err)
+
+			// return the resources held by the link to the session's budget
+			r.charges.close()
+
+			if !r.autoSendFlow {
+				// unblock any pending drain requests
+				r.creditor.EndDrain()
+			}
 
-		if !r.autoSendFlow {
-			// unblock any pending drain requests
-			r.creditor.EndDrain()
-		}
-	}, func(fr frames.PerformTransfer) {
-		_ = r.muxReceive(fr)
-	})
+			r.l.metrics().LinkDetached(r.l.source.Address, r.l.key.name)
+		}, func(fr frames.PerformTransfer) {
+			_ = r.muxReceive(fr)
+		})
+	}()
 
 	for {
 		// max - (availableCredit + countUnsettled) == pending credit (i.e. credit we can reclaim)
 		// once we have pending credit equal to or greater than half our max, reclaim it.  we do this
 		// instead of pending > 0 to prevent flow frames from being too chatty.
 		if pendingCredit := r.maxCredit - (r.l.availableCredit + uint32(r.countUnsettled())); pendingCredit >= r.maxCredit/2 && r.autoSendFlow {
-			debug.Log(1, "receiver (auto): source: %s, inflight: %d, credit: %d, deliveryCount: %d, messages: %d, unsettled: %d, maxCredit: %d, settleMode: %s", r.l.source.Address, r.inFlight.len(), r.l.availableCredit, r.l.deliveryCount, len(r.messages), r.countUnsettled(), r.maxCredit, r.l.receiverSettleMode.String())
+			debug.Log(1, "receiver (auto): source: %s, inflight: %d, credit: %d, deliveryCount: %d, messages: %d, unsettled: %d, maxCredit: %d, settleMode: %s", r.l.source.Address, r.inFlight.len(), r.l.availableCredit, r.l.deliveryCount, r.messages.len(), r.countUnsettled(), r.maxCredit, r.l.receiverSettleMode.String())
 			r.l.err = r.creditor.IssueCredit(pendingCredit, r)
 		} else if r.l.availableCredit == 0 {
-			debug.Log(1, "receiver (pause): source: %s, inflight: %d, credit: %d, deliveryCount: %d, messages: %d, unsettled: %d, maxCredit: %d, settleMode: %s", r.l.source.Address, r.inFlight.len(), r.l.availableCredit, r.l.deliveryCount, len(r.messages), r.countUnsettled(), r.maxCredit, r.l.receiverSettleMode.String())
+			debug.Log(1, "receiver (pause): source: %s, inflight: %d, credit: %d, deliveryCount: %d, messages: %d, unsettled: %d, maxCredit: %d, settleMode: %s", r.l.source.Address, r.inFlight.len(), r.l.availableCredit, r.l.deliveryCount, r.messages.len(), r.countUnsettled(), r.maxCredit, r.l.receiverSettleMode.String())
 		}
 
 		if r.l.err != nil {
@@ -564,7 +891,7 @@ func (r *Receiver) mux() {
 		drain, credits := r.creditor.FlowBits(r.l.availableCredit)
 		if drain || credits > 0 {
 			debug.Log(1, "receiver (flow): source: %s, inflight: %d, credit: %d, creditsToAdd: %d, drain: %v, deliveryCount: %d, messages: %d, unsettled: %d, maxCredit: %d, settleMode: %s",
-				r.l.source.Address, r.inFlight.len(), r.l.availableCredit, credits, drain, r.l.deliveryCount, len(r.messages), r.countUnsettled(), r.maxCredit, r.l.receiverSettleMode.String())
+				r.l.source.Address, r.inFlight.len(), r.l.availableCredit, credits, drain, r.l.deliveryCount, r.messages.len(), r.countUnsettled(), r.maxCredit, r.l.receiverSettleMode.String())
 
 			// send a flow frame.
 			r.l.err = r.muxFlow(credits, drain)
@@ -584,6 +911,9 @@ func (r *Receiver) mux() {
 
 		case <-r.receiverReady:
 			continue
+		case req := <-r.l.debugReq:
+			req <- fmt.Sprintf("%s maxCredit=%d queued=%d unsettled=%d inflight=%d",
+				r.l.debugState(), r.maxCredit, r.messages.len(), r.countUnsettled(), r.inFlight.len())
 		case <-r.l.close:
 			r.l.err = &DetachError{}
 			return
@@ -601,13 +931,14 @@ func (r *Receiver) muxFlow(linkCredit uint32, drain bool) error {
 		deliveryCount = r.l.deliveryCount
 	)
 
-	debug.Log(3, "muxFlow: len(l.Messages):%d - linkCredit: %d - deliveryCount: %d, inFlight: %d", len(r.messages), linkCredit, deliveryCount, r.inFlight.len())
+	debug.Log(3, "muxFlow: len(l.Messages):%d - linkCredit: %d - deliveryCount: %d, inFlight: %d", r.messages.len(), linkCredit, deliveryCount, r.inFlight.len())
 
 	fr := &frames.PerformFlow{
 		Handle:        &r.l.handle,
 		DeliveryCount: &deliveryCount,
 		LinkCredit:    &linkCredit, // max number of messages,
 		Drain:         drain,
+		Echo:          r.l.profile().EchoFlow,
 	}
 	debug.Log(3, "TX (muxFlow): %s", fr)
 
@@ -620,6 +951,8 @@ func (r *Receiver) muxFlow(linkCredit uint32, drain bool) error {
 		// if we're draining we don't want to touch our internal credit - we're not changing it so any issued credits
 		// are still valid until drain completes, at which point they will be naturally zeroed.
 		r.l.availableCredit = linkCredit
+		r.l.metrics().LinkCredit(r.l.source.Address, r.l.key.name, linkCredit)
+		r.l.emitEvent(LinkEventCreditGranted, nil)
 	}
 
 	// Ensure the session mux is not blocked
@@ -691,6 +1024,13 @@ func (r *Receiver) muxHandleFrame(fr frames.FrameBody) error {
 		}
 		// removal from the in-flight map will also remove the message from the unsettled map
 		r.inFlight.remove(fr.First, fr.Last, dispositionError)
+		if r.resume != nil && fr.Settled {
+			last := fr.First
+			if fr.Last != nil {
+				last = *fr.Last
+			}
+			r.resume.settle(fr.First, last)
+		}
 
 	default:
 		return r.l.muxHandleFrame(fr)
@@ -711,6 +1051,11 @@ func (r *Receiver) muxReceive(fr frames.PerformTransfer) error {
 			r.msg.Format = *fr.MessageFormat
 		}
 		r.msg.DeliveryTag = fr.DeliveryTag
+		// a copy, as the application may modify DeliveryTag in place
+		r.msg.tag = append([]byte(nil), fr.DeliveryTag...)
+		if state, ok := fr.State.(*encoding.StateTransactional); ok {
+			r.msg.txnID = state.TxnID
+		}
 
 		// these fields are required on first transfer of a message
 		if fr.DeliveryID == nil {
@@ -731,6 +1076,11 @@ func (r *Receiver) muxReceive(fr frames.PerformTransfer) error {
 				Description: "received message without a delivery-tag",
 			})
 		}
+		if fr.Resume && r.resume != nil {
+			if err := r.muxResume(fr); err != nil {
+				return err
+			}
+		}
 	} else {
 		// this is a continuation of a multipart message
 		// some fields may be omitted on continuation transfers,
@@ -774,15 +1124,30 @@ func (r *Receiver) muxReceive(fr frames.PerformTransfer) error {
 		r.msgBuf.Reset()
 		r.msg = Message{}
 		r.more = false
+		r.discarding = false
 		return nil
 	}
 
-	// ensure maxMessageSize will not be exceeded
-	if r.l.maxMessageSize != 0 && uint64(r.msgBuf.Len())+uint64(len(fr.Payload)) > r.l.maxMessageSize {
-		return r.closeWithError(&Error{
-			Condition:   ErrCondMessageSizeExceeded,
-			Description: fmt.Sprintf("received message larger than max size of %d", r.l.maxMessageSize),
-		})
+	// ensure the message size limit will not be exceeded
+	if limit := r.messageSizeLimit(); !r.discarding && limit != 0 && uint64(r.msgBuf.Len())+uint64(len(fr.Payload)) > limit {
+		if err := r.messageSizeExceeded(&MessageSizeError{
+			DeliveryID:     r.msg.deliveryID,
+			Size:           uint64(r.msgBuf.Len()) + uint64(len(fr.Payload)),
+			MaxMessageSize: limit,
+		}); err != nil {
+			return err
+		}
+	}
+	if r.discarding {
+		r.more = fr.More
+		if !fr.More {
+			// the last transfer of the discarded delivery
+			r.msgBuf.Reset()
+			r.msg = Message{}
+			r.discarding = false
+			r.l.creditConsumed()
+		}
+		return nil
 	}
 
 	// add the payload the the buffer
@@ -799,63 +1164,265 @@ func (r *Receiver) muxReceive(fr frames.PerformTransfer) error {
 	}
 
 	// last frame in message
-	err := r.msg.Unmarshal(&r.msgBuf)
-	if err != nil {
+	size := int64(r.msgBuf.Len())
+	if r.msg.Format != 0 {
+		// non-standard formats are passed through undecoded
+		if r.zeroCopy {
+			r.msg.RawPayload = r.msgBuf.Bytes()
+		} else {
+			r.msg.RawPayload = append([]byte(nil), r.msgBuf.Bytes()...)
+		}
+	} else if err := r.msg.Unmarshal(&r.msgBuf); err != nil {
 		return &DetachError{inner: err}
 	}
-	debug.Log(1, "deliveryID %d before push to receiver - deliveryCount : %d - linkCredit: %d, len(messages): %d, len(inflight): %d", r.msg.deliveryID, r.l.deliveryCount, r.l.availableCredit, len(r.messages), r.inFlight.len())
+	if r.zeroCopy {
+		// the decoded message references msgBuf, so ownership
+		// of its storage is transferred to the message.
+		r.msg.buf = r.msgBuf.Detach()
+		if p, ok := zeroCopyPool.Get().(*[]byte); ok {
+			r.msgBuf.Attach(*p)
+		}
+	}
+	if r.discardExpired && r.msg.Expired(r.l.clock().Now()) {
+		debug.Log(1, "RX (receiver): releasing expired message deliveryID %d", r.msg.deliveryID)
+		if !r.msg.settled {
+			if err := r.sendDisposition(r.msg.deliveryID, nil, &encoding.StateReleased{}); err != nil {
+				return err
+			}
+		}
+		r.msg.Release()
+		r.msgBuf.Reset()
+		r.msg = Message{}
+		r.l.creditConsumed()
+		return nil
+	}
+	if r.dedup != nil && r.dedup.seen(&r.msg) {
+		debug.Log(1, "RX (receiver): settling duplicate message deliveryID %d", r.msg.deliveryID)
+		if !r.msg.settled {
+			if err := r.sendDisposition(r.msg.deliveryID, nil, r.dedup.duplicateState()); err != nil {
+				return err
+			}
+		}
+		r.msg.Release()
+		r.msgBuf.Reset()
+		r.msg = Message{}
+		r.l.creditConsumed()
+		return nil
+	}
+	if err := decompressMessage(r.decompression, &r.msg, r.decompressedSizeLimit()); err != nil {
+		var sizeErr *MessageSizeError
+		if errors.As(err, &sizeErr) {
+			// the message is within the limit, but not once decompressed
+			if err := r.messageSizeExceeded(sizeErr); err != nil {
+				return err
+			}
+			r.msg.Release()
+			r.msgBuf.Reset()
+			r.msg = Message{}
+			r.discarding = false
+			r.l.creditConsumed()
+			return nil
+		}
+		// deliver the message as-is, the content-encoding is left
+		// intact so the application can tell it wasn't decompressed.
+		debug.Log(1, "RX (receiver): failed to decompress deliveryID %d: %v", r.msg.deliveryID, err)
+	}
+
+	if r.charges.budget != nil {
+		if ok, err := r.chargeMessage(size); !ok {
+			return err
+		}
+	}
+
+	debug.Log(1, "deliveryID %d before push to receiver - deliveryCount : %d - linkCredit: %d, len(messages): %d, len(inflight): %d", r.msg.deliveryID, r.l.deliveryCount, r.l.availableCredit, r.messages.len(), r.inFlight.len())
 	// send to receiver
 	if receiverSettleModeValue(r.l.receiverSettleMode) == ReceiverSettleModeSecond {
 		r.addUnsettled(&r.msg)
 	}
-	select {
-	case r.messages <- r.msg:
-		// message received
-	case <-r.l.detached:
-		// link has been detached
-		return r.l.err
+	if r.resume != nil && !r.msg.settled {
+		r.resume.add(r.msg.deliveryID, &unsettledDelivery{tag: append([]byte(nil), r.msg.DeliveryTag...)})
+	}
+	if !r.messages.push(&r.msg) {
+		// the prefetch queue is full
+		if err := r.pushBlocked(); err != nil {
+			return err
+		}
 	}
+	r.l.metrics().MessageReceived(r.l.source.Address)
 
-	debug.Log(1, "deliveryID %d after push to receiver - deliveryCount : %d - linkCredit: %d, len(messages): %d, len(inflight): %d", r.msg.deliveryID, r.l.deliveryCount, r.l.availableCredit, len(r.messages), r.inFlight.len())
+	debug.Log(1, "deliveryID %d after push to receiver - deliveryCount : %d - linkCredit: %d, len(messages): %d, len(inflight): %d", r.msg.deliveryID, r.l.deliveryCount, r.l.availableCredit, r.messages.len(), r.inFlight.len())
 
 	// reset progress
 	r.msgBuf.Reset()
 	r.msg = Message{}
 
 	// decrement link-credit after entire message received
-	r.l.deliveryCount++
-	r.l.availableCredit--
-	debug.Log(1, "deliveryID %d before exit - deliveryCount : %d - linkCredit: %d, len(messages): %d", r.msg.deliveryID, r.l.deliveryCount, r.l.availableCredit, len(r.messages))
+	r.l.creditConsumed()
+	debug.Log(1, "deliveryID %d before exit - deliveryCount : %d - linkCredit: %d, len(messages): %d", r.msg.deliveryID, r.l.deliveryCount, r.l.availableCredit, r.messages.len())
+	return nil
+}
+
+// muxResume handles the first transfer of a resumed delivery. A delivery
+// that was already received by the suspended link isn't received again,
+// and the outcome it was settled with is sent again.
+func (r *Receiver) muxResume(fr frames.PerformTransfer) error {
+	d, ok := r.resume.resume(fr.DeliveryTag, *fr.DeliveryID)
+	if !ok {
+		return nil
+	}
+	debug.Log(1, "RX (receiver): resumed deliveryID %d", *fr.DeliveryID)
+
+	// discard the payload of the delivery
+	r.discarding = true
+
+	if fr.Settled {
+		// the sender settled it with the outcome we had
+		r.resume.settle(*fr.DeliveryID, *fr.DeliveryID)
+		return nil
+	}
+	if d.state == nil {
+		// waiting for the outcome, the delivery is still tracked
+		return nil
+	}
+	if err := r.sendDisposition(*fr.DeliveryID, nil, d.state); err != nil {
+		return err
+	}
+	if receiverSettleModeValue(r.l.receiverSettleMode) == ReceiverSettleModeFirst {
+		r.resume.settle(*fr.DeliveryID, *fr.DeliveryID)
+	}
+	return nil
+}
+
+// chargeMessage reserves the resources held by r.msg, of the given size,
+// from the session's budget. It returns false if the message was discarded
+// or the link must detach, as determined by the budget's LimitPolicy.
+func (r *Receiver) chargeMessage(size int64) (bool, error) {
+	unsettled := 0
+	if !r.msg.settled {
+		unsettled = 1
+	}
+	err := r.charges.budget.reserve(unsettled, size, func(released <-chan struct{}) error {
+		select {
+		case <-released:
+			return nil
+		case <-r.l.close:
+			return &DetachError{}
+		case <-r.l.session.done:
+			return r.l.session.err
+		}
+	})
+	if err == nil {
+		r.charges.add(r.msg.deliveryID, unsettled > 0, size)
+		r.msg.chargedBytes = size
+		return true, nil
+	}
+
+	limitErr, ok := err.(*LimitError)
+	if !ok {
+		return false, err
+	}
+	if limitErr.policy == LimitDetach {
+		_ = r.closeWithError(limitErr.remoteErr())
+		return false, &DetachError{inner: limitErr}
+	}
+
+	debug.Log(1, "RX (receiver): rejecting deliveryID %d: %v", r.msg.deliveryID, limitErr)
+	if !r.msg.settled {
+		if err := r.sendDisposition(r.msg.deliveryID, nil, &encoding.StateRejected{Error: limitErr.remoteErr()}); err != nil {
+			return false, err
+		}
+	}
+	r.msg.Release()
+	r.msgBuf.Reset()
+	r.msg = Message{}
+	r.l.creditConsumed()
+	return false, nil
+}
+
+// messageSizeLimit returns the size limit of received messages, the
+// smaller of the receiver's own and the one negotiated on attach.
+func (r *Receiver) messageSizeLimit() uint64 {
+	if r.l.maxMessageSize == 0 || (r.maxMessageSize != 0 && r.maxMessageSize < r.l.maxMessageSize) {
+		return r.maxMessageSize
+	}
+	return r.l.maxMessageSize
+}
+
+// decompressedSizeLimit returns the limit on the size of a message once
+// decompressed.
+func (r *Receiver) decompressedSizeLimit() uint64 {
+	if limit := r.messageSizeLimit(); limit != 0 && limit < r.maxDecompressedSize {
+		return limit
+	}
+	return r.maxDecompressedSize
+}
+
+// messageSizeExceeded applies the receiver's OversizedMessagePolicy to the
+// current delivery, which is larger than the size limit.
+func (r *Receiver) messageSizeExceeded(sizeErr *MessageSizeError) error {
+	remoteErr := &Error{
+		Condition:   ErrCondMessageSizeExceeded,
+		Description: fmt.Sprintf("received message larger than max size of %d", sizeErr.MaxMessageSize),
+	}
+	sizeErr.inner = remoteErr
+
+	var state encoding.DeliveryState
+	switch r.oversized {
+	case OversizedMessageReject:
+		state = &encoding.StateRejected{Error: remoteErr}
+	case OversizedMessageModify:
+		state = &encoding.StateModified{DeliveryFailed: true, UndeliverableHere: true}
+	default:
+		_ = r.closeWithError(remoteErr)
+		return &DetachError{inner: sizeErr}
+	}
+
+	debug.Log(1, "RX (receiver): discarding oversized deliveryID %d", sizeErr.DeliveryID)
+	if !r.msg.settled {
+		if err := r.sendDisposition(sizeErr.DeliveryID, nil, state); err != nil {
+			return err
+		}
+	}
+	r.discarding = true
+	r.l.emitEvent(LinkEventMessageSizeExceeded, sizeErr)
 	return nil
 }
 
+// pushBlocked waits for space in the prefetch queue to deliver r.msg,
+// reporting a slow consumer if it waits longer than the threshold.
+func (r *Receiver) pushBlocked() error {
+	defer r.slowConsumer.update(false)
+	for {
+		select {
+		case <-r.messages.space:
+			if r.messages.push(&r.msg) {
+				return nil
+			}
+		case <-r.slowConsumer.update(true):
+			r.slowConsumer.fire()
+			r.l.reportStall(LinkEventSlowConsumer)
+		case <-r.l.detached:
+			// link has been detached
+			return r.l.err
+		}
+	}
+}
+
 // inFlight tracks in-flight message dispositions allowing receivers
 // to block waiting for the server to respond when an appropriate
 // settlement mode is configured.
 type inFlight struct {
-	mu sync.RWMutex
-	m  map[uint32]chan error
+	m deliveryMap[chan error]
 }
 
 func (f *inFlight) add(id uint32) chan error {
 	wait := make(chan error, 1)
-
-	f.mu.Lock()
-	if f.m == nil {
-		f.m = map[uint32]chan error{id: wait}
-	} else {
-		f.m[id] = wait
-	}
-	f.mu.Unlock()
-
+	f.m.store(id, wait)
 	return wait
 }
 
 func (f *inFlight) remove(first uint32, last *uint32, err error) {
-	f.mu.Lock()
-
-	if f.m == nil {
-		f.mu.Unlock()
+	if f.m.len() == 0 {
 		return
 	}
 
@@ -865,27 +1432,18 @@ func (f *inFlight) remove(first uint32, last *uint32, err error) {
 	}
 
 	for i := first; i <= ll; i++ {
-		wait, ok := f.m[i]
-		if ok {
+		if wait, ok := f.m.remove(i); ok {
 			wait <- err
-			delete(f.m, i)
 		}
 	}
-
-	f.mu.Unlock()
 }
 
 func (f *inFlight) clear(err error) {
-	f.mu.Lock()
-	for id, wait := range f.m {
+	f.m.drain(func(_ uint32, wait chan error) {
 		wait <- err
-		delete(f.m, id)
-	}
-	f.mu.Unlock()
+	})
 }
 
 func (f *inFlight) len() int {
-	f.mu.RLock()
-	defer f.mu.RUnlock()
-	return len(f.m)
+	return f.m.len()
 }
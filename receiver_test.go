@@ -1,16 +1,18 @@
 package amqp
 
 import (
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/Azure/go-amqp/internal/encoding"
 	"github.com/Azure/go-amqp/internal/frames"
-	"github.com/Azure/go-amqp/internal/mocks"
+	"github.com/Azure/go-amqp/mocks"
 	"github.com/stretchr/testify/require"
 )
 
@@ -45,6 +47,14 @@ func TestReceiverInvalidOptions(t *testing.T) {
 	cancel()
 	require.Error(t, err)
 	require.Nil(t, r)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err = session.NewReceiver(ctx, "source", &ReceiverOptions{
+		RoutingType: RoutingType(3),
+	})
+	cancel()
+	require.Error(t, err)
+	require.Nil(t, r)
 }
 
 func TestReceiverMethodsNoReceive(t *testing.T) {
@@ -120,6 +130,37 @@ func TestReceiverLinkSourceFilter(t *testing.T) {
 	cancel()
 }
 
+func TestReceiverRemoteAttach(t *testing.T) {
+	conn := mocks.NewNetConn(receiverFrameHandlerNoUnhandled(ReceiverSettleModeFirst))
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+
+	r, err := newReceiver("source", nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, r.RemoteSource())
+	require.Nil(t, r.RemoteTarget())
+	require.Zero(t, r.RemoteMaxMessageSize())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err = session.NewReceiver(ctx, "source", &ReceiverOptions{
+		Filters: []LinkFilter{NewSelectorFilter("color = 'red'")},
+	})
+	cancel()
+	require.NoError(t, err)
+	require.Equal(t, &Terminus{
+		Address:      "test",
+		ExpiryPolicy: ExpiryPolicySessionEnd,
+		Filters:      map[string]any{selectorFilter: "color = 'red'"},
+	}, r.RemoteSource())
+	require.Nil(t, r.RemoteTarget())
+	require.Equal(t, uint64(math.MaxUint32), r.RemoteMaxMessageSize())
+	require.NoError(t, client.Close())
+}
+
 func TestReceiverOnClosed(t *testing.T) {
 	conn := mocks.NewNetConn(receiverFrameHandlerNoUnhandled(ReceiverSettleModeFirst))
 	client, err := NewConn(conn, nil)
@@ -247,6 +288,54 @@ func TestReceiverOnDetached(t *testing.T) {
 	require.ErrorAs(t, err, &deErr)
 }
 
+func TestReceiverAttachStolen(t *testing.T) {
+	var conn *mocks.NetConn
+	var properties map[encoding.Symbol]any
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *frames.PerformAttach:
+			properties = tt.Properties
+			b, err := mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformAttach{
+				Name: tt.Name,
+				Role: encoding.RoleSender,
+			})
+			if err != nil {
+				return nil, err
+			}
+			conn.SendFrame(b)
+			return mocks.PerformDetach(0, 0, &Error{
+				Condition:   ErrCondStolen,
+				Description: "a receiver with a higher epoch is attached",
+			})
+		case *frames.PerformDetach:
+			return nil, nil
+		default:
+			return receiverFrameHandler(ReceiverSettleModeFirst)(req)
+		}
+	}
+	conn = mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		Properties: map[string]any{"com.microsoft:epoch": int64(1)},
+	})
+	cancel()
+	require.Nil(t, r)
+	require.Equal(t, map[encoding.Symbol]any{"com.microsoft:epoch": int64(1)}, properties)
+	var stolenErr *LinkStolenError
+	require.ErrorAs(t, err, &stolenErr)
+	require.Equal(t, ErrCondStolen, stolenErr.RemoteErr.Condition)
+	var amqpErr *Error
+	require.ErrorAs(t, err, &amqpErr)
+	require.False(t, IsRetryable(err))
+	require.NoError(t, client.Close())
+}
+
 func TestReceiveInvalidMessage(t *testing.T) {
 	const linkHandle = 0
 	deliveryID := uint32(1)
@@ -326,35 +415,570 @@ func TestReceiveInvalidMessage(t *testing.T) {
 	// missing delivery tag
 	format := uint32(0)
 	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
-	r, err = session.NewReceiver(ctx, "source", nil)
+	r, err = session.NewReceiver(ctx, "source", nil)
+	cancel()
+	require.NoError(t, err)
+	go func() {
+		msg, err := r.Receive(context.Background())
+		msgChan <- msg
+		errChan <- err
+	}()
+	fr, err = mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformTransfer{
+		DeliveryID:    &deliveryID,
+		Handle:        linkHandle,
+		MessageFormat: &format,
+	})
+	require.NoError(t, err)
+	conn.SendFrame(fr)
+
+	require.Nil(t, <-msgChan)
+	require.ErrorAs(t, <-errChan, &detachErr)
+	require.Contains(t, detachErr.Error(), ErrCondNotAllowed)
+
+	_, err = r.Receive(context.Background())
+	require.ErrorAs(t, err, &detachErr)
+
+	require.NoError(t, client.Close())
+}
+
+func TestReceiveSuccessReceiverSettleModeFirst(t *testing.T) {
+	const linkHandle = 0
+	deliveryID := uint32(1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		b, err := receiverFrameHandler(ReceiverSettleModeFirst)(req)
+		if b != nil || err != nil {
+			return b, err
+		}
+		switch ff := req.(type) {
+		case *frames.PerformFlow:
+			if *ff.NextIncomingID == deliveryID {
+				// this is the first flow frame, send our payload
+				return mocks.PerformTransfer(0, linkHandle, deliveryID, []byte("hello"))
+			}
+			// ignore future flow frames as we have no response
+			return nil, nil
+		case *frames.PerformDisposition:
+			return mocks.PerformDisposition(encoding.RoleSender, 0, deliveryID, nil, &encoding.StateAccepted{})
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		SettlementMode: ReceiverSettleModeFirst.Ptr(),
+	})
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	msg, err := r.Receive(ctx)
+	cancel()
+	require.NoError(t, err)
+	if c := r.countUnsettled(); c != 0 {
+		t.Fatalf("unexpected unsettled count %d", c)
+	}
+	// wait for the link to unpause as credit should now be available
+	require.NoError(t, waitForReceiver(r, false))
+	// link credit should be 1
+	if c := r.l.availableCredit; c != 1 {
+		t.Fatalf("unexpected link credit %d", c)
+	}
+	// subsequent dispositions should have no effect
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	err = r.AcceptMessage(ctx, msg)
+	cancel()
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+}
+
+func TestReceiverLazyAttach(t *testing.T) {
+	const linkHandle = 0
+	deliveryID := uint32(1)
+	attached := make(chan struct{}, 1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		if _, ok := req.(*frames.PerformAttach); ok {
+			attached <- struct{}{}
+		}
+		b, err := receiverFrameHandler(ReceiverSettleModeFirst)(req)
+		if b != nil || err != nil {
+			return b, err
+		}
+		switch ff := req.(type) {
+		case *frames.PerformFlow:
+			if *ff.NextIncomingID == deliveryID {
+				return mocks.PerformTransfer(0, linkHandle, deliveryID, []byte("hello"))
+			}
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		LazyAttach:     true,
+		SettlementMode: ReceiverSettleModeFirst.Ptr(),
+	})
+	require.NoError(t, err)
+	select {
+	case <-attached:
+		t.Fatal("attach must be deferred")
+	default:
+	}
+	require.Nil(t, r.Prefetched())
+
+	// the first Receive attaches the link
+	msg, err := r.Receive(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), msg.GetData())
+	require.Len(t, attached, 1)
+	require.NoError(t, r.Close(ctx))
+	require.NoError(t, client.Close())
+}
+
+func TestReceiveTransactional(t *testing.T) {
+	const linkHandle = 0
+	deliveryID := uint32(1)
+	txnID := []byte("txn-1")
+	dispositions := make(chan encoding.DeliveryState, 1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		b, err := receiverFrameHandler(ReceiverSettleModeFirst)(req)
+		if b != nil || err != nil {
+			return b, err
+		}
+		switch ff := req.(type) {
+		case *frames.PerformFlow:
+			if *ff.NextIncomingID == deliveryID {
+				format := uint32(0)
+				payload, err := (&Message{Value: "hello"}).MarshalBinary()
+				if err != nil {
+					return nil, err
+				}
+				return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformTransfer{
+					Handle:        linkHandle,
+					DeliveryID:    &deliveryID,
+					DeliveryTag:   []byte("tag"),
+					MessageFormat: &format,
+					State:         &encoding.StateTransactional{TxnID: txnID},
+					Payload:       payload,
+				})
+			}
+			return nil, nil
+		case *frames.PerformDisposition:
+			dispositions <- ff.State
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	session, err := client.NewSession(ctx, nil)
+	require.NoError(t, err)
+	r, err := session.NewReceiver(ctx, "source", nil)
+	require.NoError(t, err)
+	msg, err := r.Receive(ctx)
+	require.NoError(t, err)
+	require.Equal(t, txnID, msg.TransactionID())
+	require.NoError(t, r.AcceptMessage(ctx, msg))
+	require.Equal(t, &encoding.StateTransactional{
+		TxnID:   txnID,
+		Outcome: &encoding.StateAccepted{},
+	}, <-dispositions)
+	require.NoError(t, client.Close())
+}
+
+func TestReceiveZeroCopy(t *testing.T) {
+	const linkHandle = 0
+	deliveryID := uint32(1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		b, err := receiverFrameHandler(ReceiverSettleModeFirst)(req)
+		if b != nil || err != nil {
+			return b, err
+		}
+		switch ff := req.(type) {
+		case *frames.PerformFlow:
+			if *ff.NextIncomingID == deliveryID {
+				return mocks.PerformTransfer(0, linkHandle, deliveryID, []byte("hello"))
+			}
+			return nil, nil
+		case *frames.PerformDisposition:
+			return mocks.PerformDisposition(encoding.RoleSender, 0, deliveryID, nil, &encoding.StateAccepted{})
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		SettlementMode: ReceiverSettleModeFirst.Ptr(),
+		ZeroCopy:       true,
+	})
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	msg, err := r.Receive(ctx)
+	cancel()
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), msg.GetData())
+	require.NotNil(t, msg.buf)
+	// the data section must reference the message's buffer
+	require.Equal(t, cap(msg.GetData()), len(msg.GetData()))
+	msg.Release()
+	require.Nil(t, msg.buf)
+	require.Nil(t, msg.Data)
+	// releasing twice is a no-op
+	msg.Release()
+	require.NoError(t, client.Close())
+}
+
+func TestReceivePooledMessages(t *testing.T) {
+	const linkHandle = 0
+	deliveryID := uint32(1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		b, err := receiverFrameHandler(ReceiverSettleModeFirst)(req)
+		if b != nil || err != nil {
+			return b, err
+		}
+		switch ff := req.(type) {
+		case *frames.PerformFlow:
+			if *ff.NextIncomingID == deliveryID {
+				return mocks.PerformTransfer(0, linkHandle, deliveryID, []byte("hello"))
+			}
+			return nil, nil
+		case *frames.PerformDisposition:
+			return mocks.PerformDisposition(encoding.RoleSender, 0, deliveryID, nil, &encoding.StateAccepted{})
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		SettlementMode: ReceiverSettleModeFirst.Ptr(),
+		PooledMessages: true,
+	})
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	msg, err := r.Receive(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), msg.GetData())
+	require.True(t, msg.pooled)
+	require.Equal(t, r.LinkName(), msg.LinkName())
+	require.NoError(t, r.AcceptMessage(ctx, msg))
+	cancel()
+	msg.Release()
+	// the message is reset before being returned to the pool
+	require.Equal(t, Message{}, *msg)
+	require.NoError(t, client.Close())
+}
+
+func TestReceiveDiscardExpired(t *testing.T) {
+	const linkHandle = 0
+	encodeTransfer := func(deliveryID uint32, msg *Message) ([]byte, error) {
+		payload, err := msg.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		format := uint32(0)
+		return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformTransfer{
+			Handle:        linkHandle,
+			DeliveryID:    &deliveryID,
+			DeliveryTag:   []byte(fmt.Sprintf("tag%d", deliveryID)),
+			MessageFormat: &format,
+			Payload:       payload,
+		})
+	}
+	expired := NewMessage([]byte("expired"))
+	expired.Properties = &MessageProperties{AbsoluteExpiryTime: &time.Time{}}
+	*expired.Properties.AbsoluteExpiryTime = time.Now().Add(-time.Minute)
+	live := NewMessage([]byte("live"))
+	live.SetTTL(time.Hour)
+
+	var released bool
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		b, err := receiverFrameHandler(ReceiverSettleModeFirst)(req)
+		if b != nil || err != nil {
+			return b, err
+		}
+		switch ff := req.(type) {
+		case *frames.PerformFlow:
+			if *ff.NextIncomingID == 1 {
+				return encodeTransfer(1, expired)
+			}
+			return nil, nil
+		case *frames.PerformDisposition:
+			if _, ok := ff.State.(*encoding.StateReleased); ok && ff.First == 1 {
+				released = true
+				return encodeTransfer(2, live)
+			}
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		Credit:         2,
+		DiscardExpired: true,
+		SettlementMode: ReceiverSettleModeFirst.Ptr(),
+	})
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	msg, err := r.Receive(ctx)
+	cancel()
+	require.NoError(t, err)
+	require.True(t, released)
+	require.Equal(t, []byte("live"), msg.GetData())
+	require.False(t, msg.Expired(time.Now()))
+	require.NoError(t, client.Close())
+}
+
+func TestReceiveDedup(t *testing.T) {
+	const linkHandle = 0
+	encodeTransfer := func(deliveryID uint32, id string) ([]byte, error) {
+		msg := NewMessage([]byte(id))
+		msg.Properties = &MessageProperties{MessageID: id}
+		payload, err := msg.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		format := uint32(0)
+		return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformTransfer{
+			Handle:        linkHandle,
+			DeliveryID:    &deliveryID,
+			DeliveryTag:   []byte(fmt.Sprintf("tag%d", deliveryID)),
+			MessageFormat: &format,
+			Payload:       payload,
+		})
+	}
+	cache, err := NewDedupCache(nil)
+	require.NoError(t, err)
+	cache.accepted(&Message{Properties: &MessageProperties{MessageID: "a"}})
+
+	var settled bool
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		b, err := receiverFrameHandler(ReceiverSettleModeFirst)(req)
+		if b != nil || err != nil {
+			return b, err
+		}
+		switch ff := req.(type) {
+		case *frames.PerformFlow:
+			if *ff.NextIncomingID == 1 {
+				return encodeTransfer(1, "a")
+			}
+			return nil, nil
+		case *frames.PerformDisposition:
+			if _, ok := ff.State.(*encoding.StateAccepted); ok && ff.First == 1 {
+				settled = true
+				return encodeTransfer(2, "b")
+			}
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		Credit:         2,
+		Dedup:          cache,
+		SettlementMode: ReceiverSettleModeFirst.Ptr(),
+	})
+	cancel()
+	require.NoError(t, err)
+
+	// the duplicate is accepted without being returned
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	msg, err := r.Receive(ctx)
+	require.NoError(t, err)
+	require.True(t, settled)
+	require.Equal(t, []byte("b"), msg.GetData())
+	require.EqualValues(t, 1, cache.Duplicates())
+	require.NoError(t, r.AcceptMessage(ctx, msg))
+	cancel()
+	require.Equal(t, 2, cache.Len())
+	require.NoError(t, client.Close())
+}
+
+func TestReceiveDecompression(t *testing.T) {
+	const linkHandle = 0
+	c := GzipCompression(gzip.DefaultCompression)
+	sent, err := compressMessage(c, 0, NewMessage([]byte("hello")))
+	require.NoError(t, err)
+
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		b, err := receiverFrameHandler(ReceiverSettleModeFirst)(req)
+		if b != nil || err != nil {
+			return b, err
+		}
+		switch req.(type) {
+		case *frames.PerformFlow:
+			payload, err := sent.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			deliveryID := uint32(1)
+			format := uint32(0)
+			return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformTransfer{
+				Handle:        linkHandle,
+				DeliveryID:    &deliveryID,
+				DeliveryTag:   []byte("tag"),
+				MessageFormat: &format,
+				Payload:       payload,
+				Settled:       true,
+			})
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		Decompression:  []Compression{c},
+		SettlementMode: ReceiverSettleModeFirst.Ptr(),
+	})
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	msg, err := r.Receive(ctx)
+	cancel()
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), msg.GetData())
+	require.Nil(t, msg.Properties.ContentEncoding)
+	require.NoError(t, client.Close())
+}
+
+func TestReceiveDecompressionTooBig(t *testing.T) {
+	const linkHandle = 0
+	c := GzipCompression(gzip.BestCompression)
+	sent, err := compressMessage(c, 0, NewMessage(make([]byte, 1<<20)))
+	require.NoError(t, err)
+	payload, err := sent.MarshalBinary()
+	require.NoError(t, err)
+	require.Less(t, len(payload), 2048)
+
+	dispositions := make(chan *frames.PerformDisposition, 1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch ff := req.(type) {
+		case *frames.PerformFlow:
+			return nil, nil
+		case *frames.PerformDisposition:
+			dispositions <- ff
+			return nil, nil
+		}
+		return receiverFrameHandlerNoUnhandled(ReceiverSettleModeFirst)(req)
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		Credit:            2,
+		Decompression:     []Compression{c},
+		MaxMessageSize:    4096,
+		OversizedMessages: OversizedMessageReject,
+	})
 	cancel()
 	require.NoError(t, err)
-	go func() {
-		msg, err := r.Receive(context.Background())
-		msgChan <- msg
-		errChan <- err
-	}()
-	fr, err = mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformTransfer{
-		DeliveryID:    &deliveryID,
+
+	// the message is within the limit, but not once decompressed
+	deliveryID := uint32(1)
+	format := uint32(0)
+	b, err := mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformTransfer{
 		Handle:        linkHandle,
+		DeliveryID:    &deliveryID,
+		DeliveryTag:   []byte("tag"),
 		MessageFormat: &format,
+		Payload:       payload,
 	})
 	require.NoError(t, err)
-	conn.SendFrame(fr)
-
-	require.Nil(t, <-msgChan)
-	require.ErrorAs(t, <-errChan, &detachErr)
-	require.Contains(t, detachErr.Error(), ErrCondNotAllowed)
-
-	_, err = r.Receive(context.Background())
-	require.ErrorAs(t, err, &detachErr)
+	// the mock connection doesn't split frames larger than the read buffer
+	for len(b) > 0 {
+		n := len(b)
+		if n > 256 {
+			n = 256
+		}
+		conn.SendFrame(b[:n])
+		b = b[n:]
+	}
+	select {
+	case dis := <-dispositions:
+		require.Equal(t, deliveryID, dis.First)
+		rejected, ok := dis.State.(*encoding.StateRejected)
+		require.True(t, ok)
+		require.Equal(t, ErrCondMessageSizeExceeded, rejected.Error.Condition)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for disposition")
+	}
 
+	// the next message is received
+	b, err = mocks.PerformTransfer(0, linkHandle, deliveryID+1, []byte("small message"))
+	require.NoError(t, err)
+	conn.SendFrame(b)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	msg, err := r.Receive(ctx)
+	cancel()
+	require.NoError(t, err)
+	require.Equal(t, []byte("small message"), msg.GetData())
 	require.NoError(t, client.Close())
 }
 
-func TestReceiveSuccessReceiverSettleModeFirst(t *testing.T) {
+func TestReceiveSlowConsumer(t *testing.T) {
 	const linkHandle = 0
-	deliveryID := uint32(1)
 	responder := func(req frames.FrameBody) ([]byte, error) {
 		b, err := receiverFrameHandler(ReceiverSettleModeFirst)(req)
 		if b != nil || err != nil {
@@ -362,14 +986,94 @@ func TestReceiveSuccessReceiverSettleModeFirst(t *testing.T) {
 		}
 		switch ff := req.(type) {
 		case *frames.PerformFlow:
-			if *ff.NextIncomingID == deliveryID {
-				// this is the first flow frame, send our payload
-				return mocks.PerformTransfer(0, linkHandle, deliveryID, []byte("hello"))
+			if *ff.LinkCredit == 0 {
+				return nil, nil
 			}
-			// ignore future flow frames as we have no response
-			return nil, nil
-		case *frames.PerformDisposition:
-			return mocks.PerformDisposition(encoding.RoleSender, 0, deliveryID, nil, &encoding.StateAccepted{})
+			// send one more message than the prefetch queue holds
+			var transfers []byte
+			for i := uint32(0); i < 2; i++ {
+				deliveryID := i
+				format := uint32(0)
+				fr, err := mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformTransfer{
+					Handle:        linkHandle,
+					DeliveryID:    &deliveryID,
+					DeliveryTag:   []byte{byte(i)},
+					MessageFormat: &format,
+					Payload:       []byte{0x00, 0x53, 0x75, 0xa0, 0x01, 'a' + byte(i)},
+					Settled:       true,
+				})
+				if err != nil {
+					return nil, err
+				}
+				transfers = append(transfers, fr...)
+			}
+			return transfers, nil
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	conn := mocks.NewNetConn(responder)
+	slow := make(chan LinkEvent, 1)
+	client, err := NewConn(conn, &ConnOptions{
+		LinkEventHook: func(ev LinkEvent) {
+			if ev.Type == LinkEventSlowConsumer {
+				slow <- ev
+			}
+		},
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		Credit:                1,
+		SettlementMode:        ReceiverSettleModeFirst.Ptr(),
+		SlowConsumerThreshold: 10 * time.Millisecond,
+	})
+	cancel()
+	require.NoError(t, err)
+
+	select {
+	case ev := <-slow:
+		require.True(t, ev.Receiver)
+		require.Equal(t, "source", ev.Source)
+	case <-time.After(time.Second):
+		t.Fatal("didn't receive slow consumer event")
+	}
+	require.EqualValues(t, 1, client.Stats().SlowConsumers)
+
+	for _, want := range []string{"a", "b"} {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		msg, err := r.Receive(ctx)
+		cancel()
+		require.NoError(t, err)
+		require.Equal(t, want, string(msg.GetData()))
+	}
+	require.NoError(t, client.Close())
+}
+
+func TestReceiveNonDefaultFormat(t *testing.T) {
+	const linkHandle = 0
+	raw := []byte{0xde, 0xad, 0xbe, 0xef}
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		b, err := receiverFrameHandler(ReceiverSettleModeFirst)(req)
+		if b != nil || err != nil {
+			return b, err
+		}
+		switch req.(type) {
+		case *frames.PerformFlow:
+			deliveryID := uint32(1)
+			format := uint32(0x80013700)
+			return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformTransfer{
+				Handle:        linkHandle,
+				DeliveryID:    &deliveryID,
+				DeliveryTag:   []byte("tag"),
+				MessageFormat: &format,
+				Payload:       raw,
+				Settled:       true,
+			})
 		default:
 			return nil, fmt.Errorf("unhandled frame %T", req)
 		}
@@ -391,20 +1095,9 @@ func TestReceiveSuccessReceiverSettleModeFirst(t *testing.T) {
 	msg, err := r.Receive(ctx)
 	cancel()
 	require.NoError(t, err)
-	if c := r.countUnsettled(); c != 0 {
-		t.Fatalf("unexpected unsettled count %d", c)
-	}
-	// wait for the link to unpause as credit should now be available
-	require.NoError(t, waitForReceiver(r, false))
-	// link credit should be 1
-	if c := r.l.availableCredit; c != 1 {
-		t.Fatalf("unexpected link credit %d", c)
-	}
-	// subsequent dispositions should have no effect
-	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
-	err = r.AcceptMessage(ctx, msg)
-	cancel()
-	require.NoError(t, err)
+	require.Equal(t, uint32(0x80013700), msg.Format)
+	require.Equal(t, raw, msg.RawPayload)
+	require.Nil(t, msg.Data)
 	require.NoError(t, client.Close())
 }
 
@@ -782,10 +1475,8 @@ func TestReceiveSuccessReceiverSettleModeSecondModify(t *testing.T) {
 }
 
 func TestReceiverPrefetch(t *testing.T) {
-	messagesCh := make(chan Message, 1)
-
 	receiver := &Receiver{
-		messages:      messagesCh,
+		messages:      newPrefetchQueue(1),
 		receiverReady: make(chan struct{}),
 	}
 
@@ -793,18 +1484,18 @@ func TestReceiverPrefetch(t *testing.T) {
 	msg := receiver.Prefetched()
 	require.Nil(t, msg)
 
-	messagesCh <- Message{
+	require.True(t, receiver.messages.push(&Message{
 		ApplicationProperties: map[string]any{
 			"prop": "hello",
 		},
 		settled: true,
-	}
+	}))
 
-	require.NotEmpty(t, messagesCh)
+	require.Equal(t, 1, receiver.messages.len())
 	msg = receiver.Prefetched()
 
 	require.EqualValues(t, "hello", msg.ApplicationProperties["prop"].(string))
-	require.Empty(t, messagesCh)
+	require.Zero(t, receiver.messages.len())
 }
 
 func TestReceiveMultiFrameMessageSuccess(t *testing.T) {
@@ -1098,9 +1789,90 @@ func TestReceiveMessageTooBig(t *testing.T) {
 	var detachErr *DetachError
 	require.ErrorAs(t, err, &detachErr)
 	require.Contains(t, detachErr.Error(), ErrCondMessageSizeExceeded)
+	var sizeErr *MessageSizeError
+	require.ErrorAs(t, err, &sizeErr)
+	require.Equal(t, deliveryID, sizeErr.DeliveryID)
+	require.EqualValues(t, 128, sizeErr.MaxMessageSize)
+	require.ErrorIs(t, err, ErrCondMessageSizeExceeded)
 	require.NoError(t, client.Close())
 }
 
+func TestReceiveMessageTooBigDiscarded(t *testing.T) {
+	for name, policy := range map[string]OversizedMessagePolicy{
+		"reject": OversizedMessageReject,
+		"modify": OversizedMessageModify,
+	} {
+		t.Run(name, func(t *testing.T) {
+			const linkHandle = 0
+			deliveryID := uint32(1)
+			dispositions := make(chan *frames.PerformDisposition, 1)
+			responder := func(req frames.FrameBody) ([]byte, error) {
+				switch ff := req.(type) {
+				case *frames.PerformFlow:
+					return nil, nil
+				case *frames.PerformDisposition:
+					dispositions <- ff
+					return nil, nil
+				}
+				return receiverFrameHandlerNoUnhandled(ReceiverSettleModeFirst)(req)
+			}
+			conn := mocks.NewNetConn(responder)
+			events := make(chan LinkEvent, 10)
+			client, err := NewConn(conn, &ConnOptions{LinkEventHook: func(ev LinkEvent) {
+				if ev.Type == LinkEventMessageSizeExceeded {
+					events <- ev
+				}
+			}})
+			require.NoError(t, err)
+			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+			session, err := client.NewSession(ctx, nil)
+			cancel()
+			require.NoError(t, err)
+			ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+			r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+				Credit:            2,
+				MaxMessageSize:    64,
+				OversizedMessages: policy,
+			})
+			cancel()
+			require.NoError(t, err)
+
+			// the oversized message is settled once it exceeds the limit and the rest is discarded
+			require.NoError(t, conn.SendMultiFrameTransfer(0, linkHandle, deliveryID, make([]byte, 256), nil))
+			dis := <-dispositions
+			require.Equal(t, deliveryID, dis.First)
+			require.True(t, dis.Settled)
+			switch policy {
+			case OversizedMessageReject:
+				rejected, ok := dis.State.(*encoding.StateRejected)
+				require.True(t, ok)
+				require.Equal(t, ErrCondMessageSizeExceeded, rejected.Error.Condition)
+			case OversizedMessageModify:
+				modified, ok := dis.State.(*encoding.StateModified)
+				require.True(t, ok)
+				require.True(t, modified.DeliveryFailed)
+				require.True(t, modified.UndeliverableHere)
+			}
+			ev := <-events
+			var sizeErr *MessageSizeError
+			require.ErrorAs(t, ev.Err, &sizeErr)
+			require.Equal(t, deliveryID, sizeErr.DeliveryID)
+
+			// the next message is received
+			payload := []byte("small message")
+			b, err := mocks.PerformTransfer(0, linkHandle, deliveryID+1, payload)
+			require.NoError(t, err)
+			conn.SendFrame(b)
+			ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			msg, err := r.Receive(ctx)
+			cancel()
+			require.NoError(t, err)
+			require.Equal(t, payload, msg.GetData())
+			require.NoError(t, client.Close())
+		})
+	}
+}
+
 func TestReceiveSuccessAcceptFails(t *testing.T) {
 	const linkHandle = 0
 	deliveryID := uint32(1)
@@ -1298,6 +2070,65 @@ func TestReceiverDispositionBatcherFull(t *testing.T) {
 	require.NoError(t, client.Close())
 }
 
+func TestReceiverDispositionBatcherMaxSize(t *testing.T) {
+	const credit = 4
+	dispositions := make(chan *frames.PerformDisposition, credit)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		b, err := receiverFrameHandler(ReceiverSettleModeFirst)(req)
+		if b != nil || err != nil {
+			return b, err
+		}
+		switch ff := req.(type) {
+		case *frames.PerformFlow, *mocks.KeepAlive:
+			return nil, nil
+		case *frames.PerformDisposition:
+			dispositions <- ff
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		Batching:     true,
+		BatchMaxAge:  time.Minute,
+		BatchMaxSize: 2,
+		Credit:       credit,
+	})
+	cancel()
+	require.NoError(t, err)
+	for deliveryID := uint32(1); deliveryID <= credit; deliveryID++ {
+		b, err := mocks.PerformTransfer(0, 0, deliveryID, []byte("hello"))
+		require.NoError(t, err)
+		conn.SendFrame(b)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		msg, err := r.Receive(ctx)
+		cancel()
+		require.NoError(t, err)
+		require.NoError(t, r.AcceptMessage(context.Background(), msg))
+	}
+	// the batches are sent once they're full, without waiting for BatchMaxAge
+	for _, first := range []uint32{1, 3} {
+		var disp *frames.PerformDisposition
+		select {
+		case disp = <-dispositions:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for disposition")
+		}
+		require.Equal(t, first, disp.First)
+		require.NotNil(t, disp.Last)
+		require.Equal(t, first+1, *disp.Last)
+	}
+	require.NoError(t, client.Close())
+}
+
 func TestReceiverDispositionBatcherRelease(t *testing.T) {
 	const credit = 3
 	const linkHandle = 0
@@ -1473,3 +2304,249 @@ func TestReceiverConnWriterError(t *testing.T) {
 }
 
 // TODO: add unit tests for manual credit management
+
+func TestReceiverSuspendedByPeer(t *testing.T) {
+	attaches := make(chan *frames.PerformAttach, 2)
+	detaches := make(chan *frames.PerformDetach, 1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *frames.PerformAttach:
+			attaches <- tt
+		case *frames.PerformDetach:
+			detaches <- tt
+			return nil, nil
+		}
+		return receiverFrameHandlerNoUnhandled(ReceiverSettleModeFirst)(req)
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		Filters: []LinkFilter{NewSelectorFilter("color = 'red'")},
+	})
+	cancel()
+	require.NoError(t, err)
+	<-attaches
+
+	b, err := mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformDetach{Handle: 0})
+	require.NoError(t, err)
+	conn.SendFrame(b)
+
+	_, err = r.Receive(context.Background())
+	var detachErr *DetachError
+	require.ErrorAs(t, err, &detachErr)
+	require.True(t, detachErr.Suspended)
+	require.Equal(t, ErrorSeverityRetryLink, ClassifyError(err))
+	require.False(t, (<-detaches).Closed)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r2, err := r.Reattach(ctx)
+	cancel()
+	require.NoError(t, err)
+	attach := <-attaches
+	require.Equal(t, r.LinkName(), attach.Name)
+	require.Equal(t, "source", attach.Source.Address)
+	require.Equal(t, "color = 'red'", r2.LinkSourceFilterValue(selectorFilter))
+	require.NoError(t, client.Close())
+}
+
+func TestReceiverResumeDeliveries(t *testing.T) {
+	var attaches int
+	unsettled := make(chan encoding.Unsettled, 1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *frames.PerformAttach:
+			if attaches++; attaches == 1 {
+				break
+			}
+			unsettled <- tt.Unsettled
+			return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformAttach{
+				Name:      tt.Name,
+				Role:      encoding.RoleSender,
+				Source:    &frames.Source{Address: "source"},
+				Unsettled: encoding.Unsettled{"second": nil},
+			})
+		case *frames.PerformDetach:
+			return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformDetach{Handle: 0})
+		case *frames.PerformFlow, *frames.PerformDisposition:
+			return nil, nil
+		}
+		return receiverFrameHandlerNoUnhandled(ReceiverSettleModeFirst)(req)
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", &ReceiverOptions{
+		ResumeDeliveries: true,
+	})
+	cancel()
+	require.NoError(t, err)
+
+	format := uint32(0)
+	transfer := func(deliveryID uint32, tag string, resume bool) {
+		b, err := mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformTransfer{
+			DeliveryID:    &deliveryID,
+			DeliveryTag:   []byte(tag),
+			MessageFormat: &format,
+			Resume:        resume,
+			Payload:       append([]byte{0, 0x53, 0x77, 0xa1, byte(len(tag))}, tag...),
+		})
+		require.NoError(t, err)
+		conn.SendFrame(b)
+	}
+	receive := func(r *Receiver) *Message {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		msg, err := r.Receive(ctx)
+		require.NoError(t, err)
+		return msg
+	}
+
+	transfer(1, "first", false)
+	transfer(2, "second", false)
+	first := receive(r)
+	require.Equal(t, "first", first.Value)
+	require.Equal(t, "second", receive(r).Value)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	require.NoError(t, r.AcceptMessage(ctx, first))
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	require.NoError(t, r.Detach(ctx))
+	cancel()
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r2, err := r.Reattach(ctx)
+	cancel()
+	require.NoError(t, err)
+	// only the delivery that wasn't settled is resumed
+	require.Equal(t, encoding.Unsettled{"second": nil}, <-unsettled)
+
+	// the resumed delivery isn't received again
+	transfer(3, "second", true)
+	transfer(4, "third", false)
+	require.Equal(t, "third", receive(r2).Value)
+	require.NoError(t, client.Close())
+}
+
+func TestReceiverSettleByTag(t *testing.T) {
+	var attaches int
+	dispositions := make(chan *frames.PerformDisposition, 2)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *frames.PerformAttach:
+			if attaches++; attaches == 1 {
+				break
+			}
+			return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformAttach{
+				Name:      tt.Name,
+				Role:      encoding.RoleSender,
+				Source:    &frames.Source{Address: "source"},
+				Unsettled: encoding.Unsettled{"first": nil, "second": nil},
+			})
+		case *frames.PerformDetach:
+			return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformDetach{Handle: 0, Closed: tt.Closed})
+		case *frames.PerformDisposition:
+			dispositions <- tt
+			return nil, nil
+		case *frames.PerformFlow:
+			return nil, nil
+		}
+		return receiverFrameHandlerNoUnhandled(ReceiverSettleModeFirst)(req)
+	}
+	conn := mocks.NewNetConn(responder)
+	client, err := NewConn(conn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err := session.NewReceiver(ctx, "source", nil)
+	cancel()
+	require.NoError(t, err)
+	require.EqualError(t, r.SettleByTag(context.Background(), []byte("first"), Outcome{Type: OutcomeAccepted}),
+		"amqp: settling by delivery tag requires ReceiverOptions.ResumeDeliveries")
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	require.NoError(t, r.Close(ctx))
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r, err = session.NewReceiver(ctx, "source", &ReceiverOptions{
+		ResumeDeliveries: true,
+	})
+	cancel()
+	require.NoError(t, err)
+
+	format := uint32(0)
+	transfer := func(deliveryID uint32, tag string, resume bool) {
+		b, err := mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformTransfer{
+			DeliveryID:    &deliveryID,
+			DeliveryTag:   []byte(tag),
+			MessageFormat: &format,
+			Resume:        resume,
+			Payload:       append([]byte{0, 0x53, 0x77, 0xa1, byte(len(tag))}, tag...),
+		})
+		require.NoError(t, err)
+		conn.SendFrame(b)
+	}
+
+	transfer(1, "first", false)
+	transfer(2, "second", false)
+	for i, tag := range []string{"first", "second"} {
+		ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+		msg, err := r.Receive(ctx)
+		cancel()
+		require.NoError(t, err)
+		require.Equal(t, []byte(tag), msg.DeliveryTag)
+		msg.DeliveryTag[0] = 'x'
+		msg.ReceivedDeliveryTag()[0] = 'x'
+		require.Equal(t, []byte(tag), msg.ReceivedDeliveryTag())
+		msg.DeliveryTag = nil
+		require.Equal(t, []byte(tag), msg.ReceivedDeliveryTag())
+		deliveryID, ok := msg.DeliveryID()
+		require.True(t, ok)
+		require.Equal(t, uint32(i+1), deliveryID)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	require.NoError(t, r.Detach(ctx))
+	cancel()
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	r2, err := r.Reattach(ctx)
+	cancel()
+	require.NoError(t, err)
+
+	// resumed, the outcome is sent right away
+	transfer(3, "first", true)
+	require.Eventually(t, func() bool {
+		return r2.SettleByTag(context.Background(), []byte("first"), Outcome{Type: OutcomeAccepted}) == nil
+	}, time.Second, 10*time.Millisecond)
+	disp := <-dispositions
+	require.Equal(t, uint32(3), disp.First)
+	require.True(t, disp.Settled)
+	require.Equal(t, &encoding.StateAccepted{}, disp.State)
+
+	// not resumed yet, the outcome is sent once it is
+	require.NoError(t, r2.SettleByTag(context.Background(), []byte("second"), Outcome{Type: OutcomeReleased}))
+	transfer(4, "second", true)
+	disp = <-dispositions
+	require.Equal(t, uint32(4), disp.First)
+	require.Equal(t, &encoding.StateReleased{}, disp.State)
+
+	require.EqualError(t, r2.SettleByTag(context.Background(), []byte("third"), Outcome{Type: OutcomeAccepted}),
+		"amqp: no unsettled delivery with tag 7468697264")
+	require.EqualError(t, r2.SettleByTag(context.Background(), []byte("first"), Outcome{}),
+		"amqp: invalid outcome unknown")
+	require.NoError(t, client.Close())
+}
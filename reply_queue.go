@@ -0,0 +1,144 @@
+package amqp
+
+import (
+	"context"
+	"sync"
+)
+
+// ReplyQueueOptions contains the optional settings for configuring a ReplyQueue.
+type ReplyQueueOptions struct {
+	// Credit is the maximum number of responses the peer can send
+	// before they're matched to their waiting callers.
+	//
+	// Default: 100.
+	Credit uint32
+}
+
+// ReplyQueue receives responses on a receiver attached to a dynamic node,
+// the temporary queue created by the peer, and hands them to the callers
+// waiting for them, matched by correlation-id.
+//
+// The receiver is attached when the first caller waits for a response. If
+// it fails, as when the connection is lost, the callers waiting for
+// responses return its error, and a new dynamic node is created on the
+// session returned by the session function for the next caller. Callers
+// must then use the new address returned by PendingReply.Address.
+//
+// A ReplyQueue is safe for concurrent use by multiple goroutines.
+type ReplyQueue struct {
+	session func(ctx context.Context) (*Session, error)
+	credit  uint32
+
+	mu      sync.Mutex
+	replies *replyDemux // nil until the receiver is attached
+	ids     *messageIDs
+	closed  bool
+}
+
+// PendingReply is a caller waiting for a response received by a ReplyQueue.
+type PendingReply struct {
+	// ID is the message-id of the request, which must be the correlation-id of the response.
+	ID MessageID
+
+	replies *replyDemux
+	resp    chan *Message
+}
+
+// NewReplyQueue creates a ReplyQueue attaching its receiver on the session
+// returned by session. It's called when the receiver is first attached, and
+// after it fails, so it can return a session of a new connection when the
+// previous one has been lost.
+//
+// opts: pass nil to accept the default values.
+func NewReplyQueue(session func(ctx context.Context) (*Session, error), opts *ReplyQueueOptions) *ReplyQueue {
+	q := &ReplyQueue{session: session, credit: 100}
+	if opts != nil && opts.Credit > 0 {
+		q.credit = opts.Credit
+	}
+	return q
+}
+
+// Expect registers a caller waiting for the response to a request with
+// message-id id. If id is nil, a message-id is generated.
+//
+// The request must be sent with the message-id in PendingReply.ID and the
+// reply-to in PendingReply.Address. Call Cancel once the response has been
+// received or is no longer awaited.
+func (q *ReplyQueue) Expect(ctx context.Context, id MessageID) (*PendingReply, error) {
+	replies, ids, err := q.receiver(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if id == nil {
+		id = ids.next()
+	}
+	resp, err := replies.expect(id)
+	if err != nil {
+		return nil, err
+	}
+	return &PendingReply{ID: id, replies: replies, resp: resp}, nil
+}
+
+// Close detaches the receiver. Callers waiting for responses return a *DetachError.
+func (q *ReplyQueue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	replies := q.replies
+	q.closed = true
+	q.mu.Unlock()
+	if replies == nil {
+		return nil
+	}
+	return replies.close(ctx)
+}
+
+// receiver returns the demultiplexer of the current receiver,
+// attaching a new receiver if there's none or it has failed.
+func (q *ReplyQueue) receiver(ctx context.Context) (*replyDemux, *messageIDs, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, nil, &DetachError{}
+	}
+	if q.replies != nil {
+		select {
+		case <-q.replies.done:
+		default:
+			return q.replies, q.ids, nil
+		}
+	}
+
+	session, err := q.session(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids, err := newMessageIDs(session.conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	rcv, err := session.NewReceiver(ctx, "", &ReceiverOptions{
+		Credit:         q.credit,
+		DynamicAddress: true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	q.replies = newReplyDemux(rcv)
+	q.ids = ids
+	return q.replies, q.ids, nil
+}
+
+// Address returns the address to set as the reply-to of the request.
+func (p *PendingReply) Address() string {
+	return p.replies.rcv.Address()
+}
+
+// Wait waits for the response. If the receiver fails first, its error is returned.
+func (p *PendingReply) Wait(ctx context.Context) (*Message, error) {
+	return p.replies.wait(ctx, p.resp)
+}
+
+// Cancel stops waiting for the response. A response received later is discarded.
+func (p *PendingReply) Cancel() {
+	p.replies.forget(p.ID)
+}
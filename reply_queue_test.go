@@ -0,0 +1,73 @@
+package amqp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplyQueue(t *testing.T) {
+	client, server := newTestServerConn(t)
+	clientSession, serverSession := acceptTestSession(t, client, server)
+
+	// the server creates a dynamic node named tmp-<n> for each receiver
+	senders := make(chan *Sender, 2)
+	go func() {
+		for i := 1; i <= cap(senders); i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			req, err := serverSession.NextLink(ctx)
+			cancel()
+			if err != nil || !req.DynamicAddress {
+				return
+			}
+			snd, err := req.AcceptSender(&SenderOptions{SourceAddress: fmt.Sprintf("tmp-%d", i)})
+			if err != nil {
+				return
+			}
+			senders <- snd
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var sessions int
+	q := NewReplyQueue(func(context.Context) (*Session, error) {
+		sessions++
+		return clientSession, nil
+	}, nil)
+	defer q.Close(ctx)
+
+	p, err := q.Expect(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, "tmp-1", p.Address())
+	pending, err := q.Expect(ctx, MessageIDUlong(7))
+	require.NoError(t, err)
+	require.Equal(t, "tmp-1", pending.Address())
+	require.Equal(t, 1, sessions)
+
+	snd := <-senders
+	resp := NewMessage([]byte("response"))
+	resp.Properties = &MessageProperties{CorrelationID: p.ID}
+	require.NoError(t, snd.Send(ctx, resp))
+	msg, err := p.Wait(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "response", string(msg.GetData()))
+	p.Cancel()
+
+	// the node is deleted, the caller still waiting fails,
+	// and the next caller gets a new node
+	require.NoError(t, snd.Close(ctx))
+	_, err = pending.Wait(ctx)
+	var detachErr *DetachError
+	require.ErrorAs(t, err, &detachErr)
+	pending.Cancel()
+
+	p, err = q.Expect(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, "tmp-2", p.Address())
+	require.Equal(t, 2, sessions)
+	p.Cancel()
+}
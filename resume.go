@@ -0,0 +1,168 @@
+package amqp
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/Azure/go-amqp/internal/encoding"
+)
+
+// unsettledDelivery is an unsettled delivery of a link with
+// ResumeDeliveries enabled.
+type unsettledDelivery struct {
+	tag     []byte
+	format  uint32                 // the message format, senders only
+	payload []byte                 // the encoded message, senders only
+	state   encoding.DeliveryState // the last known state, nil if none
+}
+
+// unsettledDeliveries tracks the unsettled deliveries of a link so they're
+// resumed when the link is reattached, rather than duplicated or orphaned.
+type unsettledDeliveries struct {
+	mu        sync.Mutex
+	byID      map[uint32]*unsettledDelivery // deliveries on this link, by delivery ID
+	recovered map[string]*unsettledDelivery // deliveries of the suspended link not yet resumed, by tag
+}
+
+func newUnsettledDeliveries() *unsettledDeliveries {
+	return &unsettledDeliveries{
+		byID:      map[uint32]*unsettledDelivery{},
+		recovered: map[string]*unsettledDelivery{},
+	}
+}
+
+// add starts tracking the delivery with ID id.
+func (u *unsettledDeliveries) add(id uint32, d *unsettledDelivery) {
+	u.mu.Lock()
+	u.byID[id] = d
+	u.mu.Unlock()
+}
+
+// setState sets the last known state of the delivery with ID id.
+func (u *unsettledDeliveries) setState(id uint32, state encoding.DeliveryState) {
+	u.mu.Lock()
+	if d, ok := u.byID[id]; ok {
+		d.state = state
+	}
+	u.mu.Unlock()
+}
+
+// settle stops tracking the deliveries with IDs first through last.
+func (u *unsettledDeliveries) settle(first, last uint32) {
+	if last < first {
+		return
+	}
+	u.mu.Lock()
+	for id := first; ; id++ {
+		delete(u.byID, id)
+		if id == last {
+			break
+		}
+	}
+	u.mu.Unlock()
+}
+
+// recover takes the unsettled deliveries of from, the tracker of the link
+// being reattached, to be resumed by this one.
+func (u *unsettledDeliveries) recover(from *unsettledDeliveries) {
+	from.mu.Lock()
+	defer from.mu.Unlock()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for tag, d := range from.recovered {
+		u.recovered[tag] = d
+	}
+	for _, d := range from.byID {
+		u.recovered[string(d.tag)] = d
+	}
+	from.byID = map[uint32]*unsettledDelivery{}
+	from.recovered = map[string]*unsettledDelivery{}
+}
+
+// unsettledMap returns the unsettled map sent in the attach of the link.
+func (u *unsettledDeliveries) unsettledMap() encoding.Unsettled {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.recovered) == 0 {
+		return nil
+	}
+	m := make(encoding.Unsettled, len(u.recovered))
+	for tag, d := range u.recovered {
+		m[tag] = d.state
+	}
+	return m
+}
+
+// resume returns the recovered delivery with tag, tracking it as the
+// delivery with ID id. It returns false if there's no such delivery.
+func (u *unsettledDeliveries) resume(tag []byte, id uint32) (*unsettledDelivery, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	d, ok := u.recovered[string(tag)]
+	if !ok {
+		return nil, false
+	}
+	delete(u.recovered, string(tag))
+	u.byID[id] = d
+	return d, true
+}
+
+// find returns the ID of the delivery with tag on this link.
+func (u *unsettledDeliveries) find(tag []byte) (uint32, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for id, d := range u.byID {
+		if bytes.Equal(d.tag, tag) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// setRecoveredState sets the state of the recovered delivery with tag,
+// sent once the delivery is resumed. It returns false if there's no such
+// delivery.
+func (u *unsettledDeliveries) setRecoveredState(tag []byte, state encoding.DeliveryState) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	d, ok := u.recovered[string(tag)]
+	if ok {
+		d.state = state
+	}
+	return ok
+}
+
+// takeRecovered returns the recovered deliveries, which are no longer tracked.
+func (u *unsettledDeliveries) takeRecovered() []*unsettledDelivery {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	ds := make([]*unsettledDelivery, 0, len(u.recovered))
+	for _, d := range u.recovered {
+		ds = append(ds, d)
+	}
+	u.recovered = map[string]*unsettledDelivery{}
+	return ds
+}
+
+// forget stops tracking the recovered deliveries the peer doesn't have in
+// its unsettled map, as they won't be resumed.
+func (u *unsettledDeliveries) forget(peer encoding.Unsettled) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for tag := range u.recovered {
+		if _, ok := peer[tag]; !ok {
+			delete(u.recovered, tag)
+		}
+	}
+}
+
+// isTerminal returns true if state is an outcome, after which the delivery
+// can be settled.
+func isTerminal(state encoding.DeliveryState) bool {
+	switch state.(type) {
+	case *encoding.StateAccepted, *encoding.StateRejected, *encoding.StateReleased, *encoding.StateModified:
+		return true
+	default:
+		return false
+	}
+}
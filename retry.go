@@ -0,0 +1,162 @@
+package amqp
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy determines if, and when, Retry retries a failed operation.
+//
+// Implementations must be safe for concurrent use.
+type RetryPolicy interface {
+	// Delay returns how long to wait before retrying the operation after
+	// its attempt-th consecutive failure with err. attempt starts at 1.
+	// Returning false stops retrying and Retry returns err.
+	Delay(attempt int, err error) (time.Duration, bool)
+}
+
+// Retry calls fn until it succeeds, returns an error that isn't retryable,
+// or policy stops retrying. The last error returned by fn is returned.
+//
+// Errors are classified with IsRetryable. When fn fails with an error whose
+// ClassifyError severity is ErrorSeverityRetryLink or ErrorSeverityRetryConn,
+// fn is responsible for recreating the link or connection on its next call.
+//
+// If ctx is cancelled or its deadline is exceeded while waiting to retry,
+// ctx.Err() is returned.
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+
+		delay, ok := policy.Delay(attempt, err)
+		if !ok {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// ExponentialBackoffOptions contains the optional settings for ExponentialBackoff.
+type ExponentialBackoffOptions struct {
+	// InitialDelay is the delay before the first retry. Subsequent delays
+	// are doubled until MaxDelay is reached.
+	//
+	// Default: 500 milliseconds.
+	InitialDelay time.Duration
+
+	// MaxDelay is the upper bound for the delay between retries.
+	//
+	// Default: 30 seconds.
+	MaxDelay time.Duration
+
+	// MaxRetries is the maximum number of times the operation is retried.
+	// A negative value disables retries.
+	//
+	// Default: 3.
+	MaxRetries int
+
+	// Rand is the source of randomness for the jitter applied to delays.
+	// Set it to a seeded source for reproducible delays. It must be safe
+	// for concurrent use if the policy is shared.
+	//
+	// Default: the math/rand package's global source.
+	Rand io.Reader
+}
+
+// ExponentialBackoff returns a RetryPolicy with exponentially increasing
+// delays. Each delay is randomly chosen between half of and the full
+// computed delay to avoid clients retrying in lockstep.
+//
+// opts: pass nil to accept the default values.
+func ExponentialBackoff(opts *ExponentialBackoffOptions) RetryPolicy {
+	p := exponentialBackoff{
+		initialDelay: 500 * time.Millisecond,
+		maxDelay:     30 * time.Second,
+		maxRetries:   3,
+	}
+	if opts == nil {
+		return p
+	}
+	if opts.InitialDelay > 0 {
+		p.initialDelay = opts.InitialDelay
+	}
+	if opts.MaxDelay > 0 {
+		p.maxDelay = opts.MaxDelay
+	}
+	if opts.MaxRetries < 0 {
+		p.maxRetries = 0
+	} else if opts.MaxRetries > 0 {
+		p.maxRetries = opts.MaxRetries
+	}
+	p.rand = opts.Rand
+	return p
+}
+
+type exponentialBackoff struct {
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	maxRetries   int
+	rand         io.Reader
+}
+
+func (p exponentialBackoff) Delay(attempt int, _ error) (time.Duration, bool) {
+	if attempt > p.maxRetries {
+		return 0, false
+	}
+
+	delay := p.initialDelay
+	for i := 1; i < attempt && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+
+	// equal jitter: [delay/2, delay]
+	half := delay / 2
+	return half + time.Duration(p.int63n(int64(delay-half)+1)), true
+}
+
+// int63n returns a random number in [0, n) read from p.rand.
+// The global math/rand source is used if p.rand isn't set or can't be read.
+func (p exponentialBackoff) int63n(n int64) int64 {
+	if p.rand != nil {
+		var b [8]byte
+		if _, err := io.ReadFull(p.rand, b[:]); err == nil {
+			return int64(binary.BigEndian.Uint64(b[:])>>1) % n
+		}
+	}
+	return rand.Int63n(n)
+}
+
+// ConstantBackoff returns a RetryPolicy that retries up to maxRetries
+// times, waiting delay between each attempt.
+func ConstantBackoff(delay time.Duration, maxRetries int) RetryPolicy {
+	return constantBackoff{delay: delay, maxRetries: maxRetries}
+}
+
+type constantBackoff struct {
+	delay      time.Duration
+	maxRetries int
+}
+
+func (p constantBackoff) Delay(attempt int, _ error) (time.Duration, bool) {
+	return p.delay, attempt <= p.maxRetries
+}
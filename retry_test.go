@@ -0,0 +1,137 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	retryable := &Error{Condition: ErrCondInternalError}
+	fatal := &Error{Condition: ErrCondUnauthorizedAccess}
+
+	t.Run("success", func(t *testing.T) {
+		calls := 0
+		err := Retry(context.Background(), ConstantBackoff(0, 3), func(ctx context.Context) error {
+			calls++
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("eventual success", func(t *testing.T) {
+		calls := 0
+		err := Retry(context.Background(), ConstantBackoff(0, 3), func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return retryable
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		calls := 0
+		err := Retry(context.Background(), ConstantBackoff(0, 2), func(ctx context.Context) error {
+			calls++
+			return retryable
+		})
+		require.ErrorIs(t, err, retryable)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		calls := 0
+		err := Retry(context.Background(), ConstantBackoff(0, 3), func(ctx context.Context) error {
+			calls++
+			return fatal
+		})
+		require.ErrorIs(t, err, fatal)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		calls := 0
+		err := Retry(ctx, ConstantBackoff(time.Hour, 3), func(ctx context.Context) error {
+			calls++
+			return retryable
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("policy sees error", func(t *testing.T) {
+		var seen []error
+		policy := retryPolicyFunc(func(attempt int, err error) (time.Duration, bool) {
+			seen = append(seen, err)
+			return 0, ClassifyError(err) == ErrorSeverityRetry
+		})
+		connErr := &ConnError{inner: errors.New("boom")}
+		err := Retry(context.Background(), policy, func(ctx context.Context) error {
+			if len(seen) == 0 {
+				return retryable
+			}
+			return connErr
+		})
+		require.ErrorIs(t, err, connErr)
+		require.Equal(t, []error{retryable, connErr}, seen)
+	})
+}
+
+type retryPolicyFunc func(attempt int, err error) (time.Duration, bool)
+
+func (f retryPolicyFunc) Delay(attempt int, err error) (time.Duration, bool) {
+	return f(attempt, err)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	p := ExponentialBackoff(&ExponentialBackoffOptions{
+		InitialDelay: time.Second,
+		MaxDelay:     5 * time.Second,
+		MaxRetries:   5,
+	})
+
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		delay, ok := p.Delay(attempt+1, nil)
+		require.True(t, ok)
+		require.GreaterOrEqual(t, delay, want/2)
+		require.LessOrEqual(t, delay, want)
+	}
+	_, ok := p.Delay(6, nil)
+	require.False(t, ok)
+
+	// defaults
+	p = ExponentialBackoff(nil)
+	delay, ok := p.Delay(1, nil)
+	require.True(t, ok)
+	require.LessOrEqual(t, delay, 500*time.Millisecond)
+	_, ok = p.Delay(4, nil)
+	require.False(t, ok)
+
+	// retries disabled
+	p = ExponentialBackoff(&ExponentialBackoffOptions{MaxRetries: -1})
+	_, ok = p.Delay(1, nil)
+	require.False(t, ok)
+
+	// seeded jitter is reproducible
+	delays := func() []time.Duration {
+		p := ExponentialBackoff(&ExponentialBackoffOptions{MaxRetries: 5, Rand: rand.New(rand.NewSource(1))})
+		var delays []time.Duration
+		for attempt := 1; attempt <= 5; attempt++ {
+			delay, ok := p.Delay(attempt, nil)
+			require.True(t, ok)
+			delays = append(delays, delay)
+		}
+		return delays
+	}
+	require.Equal(t, delays(), delays())
+}
@@ -0,0 +1,201 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// MessageHandler handles a message received by a Router.
+//
+// The message is accepted when it returns nil. Returning an error rejects
+// the message, with the error itself if it's an *Error, or with
+// amqp:internal-error otherwise.
+type MessageHandler func(ctx context.Context, msg *Message) error
+
+// Middleware wraps a MessageHandler to run code before or after it, such as
+// logging, metrics, or validation. It can handle the message itself instead
+// of calling next.
+type Middleware func(next MessageHandler) MessageHandler
+
+// Chain returns h wrapped by mws. The first middleware is the outermost,
+// so it's the first to see messages.
+func Chain(h MessageHandler, mws ...Middleware) MessageHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// RouteOptions contains the optional settings for configuring a route of a Router.
+type RouteOptions struct {
+	// Concurrency is the maximum number of messages handled concurrently by the route.
+	// The messages handled concurrently by all routes are also bounded by the
+	// credit of the receiver.
+	//
+	// Default: 1.
+	Concurrency int
+}
+
+// Router passes the messages received on a receiver to the handlers of
+// the routes they match, based on their subject, to-address, or
+// application properties.
+//
+// Routes are matched in the order they were added. Messages matching no
+// route are passed to the fallback handler, or rejected with
+// amqp:not-found if there's none.
+//
+// Routes and middleware must be added before Serve is called.
+type Router struct {
+	routes      []*route
+	fallback    *route
+	middlewares []Middleware
+}
+
+// route is a handler of a Router and the messages it handles.
+type route struct {
+	match   func(*Message) bool // nil for the fallback route
+	handler MessageHandler
+	sem     chan struct{} // bounds the messages handled concurrently
+}
+
+// NewRouter creates a Router without any routes.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// HandleSubject passes the messages whose subject is subject to h.
+//
+// opts: pass nil to accept the default values.
+func (r *Router) HandleSubject(subject string, h MessageHandler, opts *RouteOptions) {
+	r.Handle(func(msg *Message) bool {
+		return msg.Properties != nil && msg.Properties.Subject != nil && *msg.Properties.Subject == subject
+	}, h, opts)
+}
+
+// HandleTo passes the messages whose to-address is address to h.
+//
+// opts: pass nil to accept the default values.
+func (r *Router) HandleTo(address string, h MessageHandler, opts *RouteOptions) {
+	r.Handle(func(msg *Message) bool {
+		return msg.Properties != nil && msg.Properties.To != nil && *msg.Properties.To == address
+	}, h, opts)
+}
+
+// HandleProperty passes the messages whose application property
+// key is equal to value to h. value must be comparable, and of the
+// type the property is decoded as, such as int64 for AMQP longs.
+//
+// opts: pass nil to accept the default values.
+func (r *Router) HandleProperty(key string, value any, h MessageHandler, opts *RouteOptions) {
+	r.Handle(func(msg *Message) bool {
+		v, ok := msg.ApplicationProperties[key]
+		return ok && v == value
+	}, h, opts)
+}
+
+// Handle passes the messages for which match returns true to h.
+//
+// opts: pass nil to accept the default values.
+func (r *Router) Handle(match func(*Message) bool, h MessageHandler, opts *RouteOptions) {
+	r.routes = append(r.routes, newRoute(match, h, opts))
+}
+
+// Use adds middleware wrapping the handlers of every route, including the
+// fallback. The middleware added first is the outermost. Messages matching
+// no route, without a fallback, aren't passed to the middleware.
+func (r *Router) Use(mws ...Middleware) {
+	r.middlewares = append(r.middlewares, mws...)
+}
+
+// Fallback passes the messages matching no route to h.
+//
+// opts: pass nil to accept the default values.
+func (r *Router) Fallback(h MessageHandler, opts *RouteOptions) {
+	r.fallback = newRoute(nil, h, opts)
+}
+
+func newRoute(match func(*Message) bool, h MessageHandler, opts *RouteOptions) *route {
+	concurrency := 1
+	if opts != nil && opts.Concurrency > 0 {
+		concurrency = opts.Concurrency
+	}
+	return &route{match: match, handler: h, sem: make(chan struct{}, concurrency)}
+}
+
+// Serve receives messages on rcv and passes them to the handlers of their
+// routes until ctx is canceled, or rcv is detached. It returns the
+// corresponding error once the messages being handled have been settled.
+//
+// Messages waiting for a route's concurrency limit aren't blocking the
+// messages of other routes. Use ReceiverOptions.Credit to bound the number
+// of messages received but not yet settled.
+func (r *Router) Serve(ctx context.Context, rcv *Receiver) error {
+	handlers := make(map[*route]MessageHandler, len(r.routes)+1)
+	for _, rt := range r.routes {
+		handlers[rt] = Chain(rt.handler, r.middlewares...)
+	}
+	if r.fallback != nil {
+		handlers[r.fallback] = Chain(r.fallback.handler, r.middlewares...)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		msg, err := rcv.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		rt := r.route(msg)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rt == nil {
+				_ = rcv.RejectMessage(context.Background(), msg, &Error{
+					Condition:   ErrCondNotFound,
+					Description: "no route matches the message",
+				})
+				return
+			}
+			select {
+			case rt.sem <- struct{}{}:
+			case <-ctx.Done():
+				_ = rcv.ReleaseMessage(context.Background(), msg)
+				return
+			}
+			defer func() { <-rt.sem }()
+			settle(rcv, msg, handlers[rt](ctx, msg))
+		}()
+	}
+}
+
+// route returns the route of msg, or nil if it matches none and there's no fallback.
+func (r *Router) route(msg *Message) *route {
+	for _, rt := range r.routes {
+		if rt.match(msg) {
+			return rt
+		}
+	}
+	return r.fallback
+}
+
+// settle accepts msg if err is nil, or rejects it with err otherwise.
+func settle(rcv *Receiver, msg *Message, err error) {
+	if err == nil {
+		_ = rcv.AcceptMessage(context.Background(), msg)
+		return
+	}
+	_ = rcv.RejectMessage(context.Background(), msg, rejectionError(err))
+}
+
+// rejectionError returns err if it's an *Error, or an amqp:internal-error describing it otherwise.
+func rejectionError(err error) *Error {
+	var amqpErr *Error
+	if errors.As(err, &amqpErr) {
+		return amqpErr
+	}
+	return &Error{
+		Condition:   ErrCondInternalError,
+		Description: err.Error(),
+	}
+}
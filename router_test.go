@@ -0,0 +1,163 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestReceiver attaches a receiver on a client connection, and returns it
+// along with the sender the server accepted for it.
+func newTestReceiver(t *testing.T, opts *ReceiverOptions) (*Sender, *Receiver) {
+	client, server := newTestServerConn(t)
+	clientSession, serverSession := acceptTestSession(t, client, server)
+
+	accepted := make(chan *Sender, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, err := serverSession.NextLink(ctx)
+		if err != nil {
+			close(accepted)
+			return
+		}
+		snd, _ := req.AcceptSender(&SenderOptions{IgnoreDispositionErrors: true})
+		accepted <- snd
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	rcv, err := clientSession.NewReceiver(ctx, "in", opts)
+	require.NoError(t, err)
+	snd := <-accepted
+	require.NotNil(t, snd)
+	return snd, rcv
+}
+
+func TestRouter(t *testing.T) {
+	snd, rcv := newTestReceiver(t, &ReceiverOptions{Credit: 10})
+
+	var mu sync.Mutex
+	var handled []string
+	record := func(route string) MessageHandler {
+		return func(ctx context.Context, msg *Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, route+":"+string(msg.GetData()))
+			return nil
+		}
+	}
+	r := NewRouter()
+	r.HandleSubject("orders", record("subject"), nil)
+	r.HandleTo("audit", record("to"), &RouteOptions{Concurrency: 2})
+	r.HandleProperty("kind", int64(1), func(ctx context.Context, msg *Message) error {
+		return errors.New("failed")
+	}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	serveCtx, stop := context.WithCancel(ctx)
+	served := make(chan error, 1)
+	go func() { served <- r.Serve(serveCtx, rcv) }()
+
+	subject, to := "orders", "audit"
+	outcome, err := snd.SendWithOutcome(ctx, &Message{
+		Properties: &MessageProperties{Subject: &subject},
+		Data:       [][]byte{[]byte("a")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, OutcomeAccepted, outcome.Type)
+
+	outcome, err = snd.SendWithOutcome(ctx, &Message{
+		Properties: &MessageProperties{To: &to},
+		Data:       [][]byte{[]byte("b")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, OutcomeAccepted, outcome.Type)
+
+	outcome, err = snd.SendWithOutcome(ctx, &Message{
+		ApplicationProperties: map[string]any{"kind": int64(1)},
+		Data:                  [][]byte{[]byte("c")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, OutcomeRejected, outcome.Type)
+	require.Equal(t, ErrCondInternalError, outcome.Error.Condition)
+
+	// no route and no fallback
+	outcome, err = snd.SendWithOutcome(ctx, NewMessage([]byte("d")))
+	require.NoError(t, err)
+	require.Equal(t, OutcomeRejected, outcome.Type)
+	require.Equal(t, ErrCondNotFound, outcome.Error.Condition)
+
+	stop()
+	require.ErrorIs(t, <-served, context.Canceled)
+	require.Equal(t, []string{"subject:a", "to:b"}, handled)
+
+	r.Fallback(record("fallback"), nil)
+	go func() { served <- r.Serve(ctx, rcv) }()
+	outcome, err = snd.SendWithOutcome(ctx, NewMessage([]byte("e")))
+	require.NoError(t, err)
+	require.Equal(t, OutcomeAccepted, outcome.Type)
+	mu.Lock()
+	require.Equal(t, "fallback:e", handled[len(handled)-1])
+	mu.Unlock()
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	snd, rcv := newTestReceiver(t, nil)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+	recorded := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+	trace := func(name string) Middleware {
+		return func(next MessageHandler) MessageHandler {
+			return func(ctx context.Context, msg *Message) error {
+				record(name)
+				return next(ctx, msg)
+			}
+		}
+	}
+	validate := func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg *Message) error {
+			if len(msg.GetData()) == 0 {
+				return &Error{Condition: ErrCondInvalidField, Description: "empty body"}
+			}
+			return next(ctx, msg)
+		}
+	}
+	r := NewRouter()
+	r.Use(trace("outer"), trace("inner"))
+	r.Use(validate)
+	r.Fallback(func(ctx context.Context, msg *Message) error {
+		record("handler")
+		return nil
+	}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = r.Serve(ctx, rcv) }()
+
+	outcome, err := snd.SendWithOutcome(ctx, NewMessage([]byte("a")))
+	require.NoError(t, err)
+	require.Equal(t, OutcomeAccepted, outcome.Type)
+	require.Equal(t, []string{"outer", "inner", "handler"}, recorded())
+
+	outcome, err = snd.SendWithOutcome(ctx, NewMessage(nil))
+	require.NoError(t, err)
+	require.Equal(t, OutcomeRejected, outcome.Type)
+	require.Equal(t, ErrCondInvalidField, outcome.Error.Condition)
+	require.Equal(t, []string{"outer", "inner", "handler", "outer", "inner"}, recorded())
+}
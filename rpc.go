@@ -0,0 +1,337 @@
+package amqp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RPCClientOptions contains the optional settings for configuring an RPCClient.
+type RPCClientOptions struct {
+	// Credit is the maximum number of responses the peer can send
+	// before they're matched to their requests.
+	//
+	// Default: 100.
+	Credit uint32
+
+	// ReplyAddress is the address set as the reply-to of requests.
+	//
+	// Responses are received from the node at ReplyAddress, unless
+	// ReplyToLinkTarget is true.
+	//
+	// Default: when ReplyToLinkTarget is false, a dynamic address assigned
+	// by the peer. Otherwise, a randomly generated address.
+	ReplyAddress string
+
+	// ReplyToLinkTarget causes responses to be received on a link attached
+	// from the service's address, with ReplyAddress as its target address,
+	// instead of from the node at ReplyAddress. It's the convention of
+	// management nodes such as $management.
+	//
+	// Default: false.
+	ReplyToLinkTarget bool
+
+	// Timeout is the maximum time Call waits for a response, in addition
+	// to the deadline of the context passed to it.
+	//
+	// Default: 0 (no timeout).
+	Timeout time.Duration
+}
+
+// RPCClient sends requests to a service and waits for their responses.
+//
+// Requests are sent on a sender attached to the service's address, with
+// their reply-to set to the reply address. Responses are received on a
+// receiver attached to the reply address, and matched to their request by
+// their correlation-id, which the service sets to the request's message-id.
+//
+// An RPCClient is safe for concurrent use by multiple goroutines.
+type RPCClient struct {
+	snd     *Sender
+	replies *replyDemux
+	replyTo string
+	timeout time.Duration
+	ids     *messageIDs
+}
+
+// NewRPCClient attaches the links of an RPCClient sending
+// requests to the service at address.
+//
+// opts: pass nil to accept the default values.
+func (s *Session) NewRPCClient(ctx context.Context, address string, opts *RPCClientOptions) (*RPCClient, error) {
+	var o RPCClientOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Credit == 0 {
+		o.Credit = 100
+	}
+
+	ids, err := newMessageIDs(s.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	rcvOpts := &ReceiverOptions{Credit: o.Credit}
+	source := o.ReplyAddress
+	if o.ReplyToLinkTarget {
+		if o.ReplyAddress == "" {
+			suffix, err := s.conn.randString(12)
+			if err != nil {
+				return nil, fmt.Errorf("amqp: generating reply address: %w", err)
+			}
+			o.ReplyAddress = address + "-reply-" + suffix
+		}
+		source = address
+		rcvOpts.TargetAddress = o.ReplyAddress
+	} else if o.ReplyAddress == "" {
+		rcvOpts.DynamicAddress = true
+	}
+
+	rcv, err := s.NewReceiver(ctx, source, rcvOpts)
+	if err != nil {
+		return nil, err
+	}
+	snd, err := s.NewSender(ctx, address, nil)
+	if err != nil {
+		_ = rcv.Close(ctx)
+		return nil, err
+	}
+
+	c := &RPCClient{
+		snd:     snd,
+		replies: newReplyDemux(rcv),
+		replyTo: o.ReplyAddress,
+		timeout: o.Timeout,
+		ids:     ids,
+	}
+	if !o.ReplyToLinkTarget {
+		c.replyTo = rcv.Address()
+	}
+	return c, nil
+}
+
+// ReplyAddress returns the address set as the reply-to of requests.
+func (c *RPCClient) ReplyAddress() string {
+	return c.replyTo
+}
+
+// Call sends req to the service and returns its response.
+//
+// The reply-to of req is set to the reply address, and its message-id is
+// generated if it's nil. The caller's message is not modified.
+//
+// If ctx expires, or the timeout of the client elapses, before the
+// response is received, ctx.Err() or context.DeadlineExceeded is returned
+// and a response received later is discarded.
+//
+// If the response is an error response, as sent by an RPCServer whose
+// handler failed, the error is returned as an *Error.
+func (c *RPCClient) Call(ctx context.Context, req *Message) (*Message, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	msg := *req
+	props := MessageProperties{}
+	if req.Properties != nil {
+		props = *req.Properties
+	}
+	msg.Properties = &props
+	replyTo := c.replyTo
+	props.ReplyTo = &replyTo
+	if props.MessageID == nil {
+		props.MessageID = c.ids.next()
+	}
+
+	resp, err := c.replies.expect(props.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	defer c.replies.forget(props.MessageID)
+
+	if err := c.snd.Send(ctx, &msg); err != nil {
+		return nil, err
+	}
+	m, err := c.replies.wait(ctx, resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := rpcResponseError(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// application properties of the error responses sent by RPCServer
+const (
+	rpcErrorCondition   = "error-condition"
+	rpcErrorDescription = "error-description"
+)
+
+// newRPCErrorResponse returns the error response for err.
+func newRPCErrorResponse(err *Error) *Message {
+	return &Message{
+		ApplicationProperties: map[string]any{
+			rpcErrorCondition:   string(err.Condition),
+			rpcErrorDescription: err.Description,
+		},
+	}
+}
+
+// rpcResponseError returns the error of resp if it's an error response.
+func rpcResponseError(resp *Message) *Error {
+	cond, ok := resp.ApplicationProperties[rpcErrorCondition].(string)
+	if !ok {
+		return nil
+	}
+	desc, _ := resp.ApplicationProperties[rpcErrorDescription].(string)
+	return &Error{Condition: ErrCond(cond), Description: desc}
+}
+
+// Close detaches the links of the client. Pending and subsequent
+// calls return a *DetachError.
+func (c *RPCClient) Close(ctx context.Context) error {
+	sndErr := c.snd.Close(ctx)
+	rcvErr := c.replies.close(ctx)
+	if sndErr != nil {
+		return sndErr
+	}
+	return rcvErr
+}
+
+// messageIDs generates the message-ids of requests, unique to a connection.
+type messageIDs struct {
+	seq  uint64 // accessed atomically, first for 64-bit alignment
+	base string
+}
+
+func newMessageIDs(c *Conn) (*messageIDs, error) {
+	base, err := c.randString(12)
+	if err != nil {
+		return nil, fmt.Errorf("amqp: generating message-id: %w", err)
+	}
+	return &messageIDs{base: base}, nil
+}
+
+// next returns a new message-id.
+func (m *messageIDs) next() MessageID {
+	return fmt.Sprintf("%s:%d", m.base, atomic.AddUint64(&m.seq, 1))
+}
+
+// replyDemux accepts the responses received on a receiver and hands them
+// to the callers waiting for them, matched by correlation-id. Responses
+// not matching a waiting caller are discarded.
+type replyDemux struct {
+	rcv  *Receiver
+	done chan struct{} // closed when the receiver has failed or been closed
+	err  error         // the error of the receiver, set before done is closed
+
+	mu      sync.Mutex
+	pending map[any]chan *Message // keyed by rpcKey of the correlation-id
+}
+
+// newReplyDemux starts demultiplexing the responses received on rcv.
+func newReplyDemux(rcv *Receiver) *replyDemux {
+	d := &replyDemux{
+		rcv:     rcv,
+		done:    make(chan struct{}),
+		pending: map[any]chan *Message{},
+	}
+	go d.receive()
+	return d
+}
+
+// expect registers a caller waiting for the response with correlation-id
+// id, and returns the channel the response is sent to. The caller must
+// call forget once it stops waiting.
+func (d *replyDemux) expect(id MessageID) (chan *Message, error) {
+	select {
+	case <-d.done:
+		return nil, d.err
+	default:
+	}
+	key := rpcKey(id)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.pending[key]; ok {
+		return nil, fmt.Errorf("amqp: a call with message-id %v is already in progress", id)
+	}
+	resp := make(chan *Message, 1)
+	d.pending[key] = resp
+	return resp, nil
+}
+
+// forget unregisters the caller waiting for the response with correlation-id id.
+func (d *replyDemux) forget(id MessageID) {
+	d.mu.Lock()
+	delete(d.pending, rpcKey(id))
+	d.mu.Unlock()
+}
+
+// wait waits for the response sent to resp, returned by expect.
+func (d *replyDemux) wait(ctx context.Context, resp chan *Message) (*Message, error) {
+	select {
+	case m := <-resp:
+		return m, nil
+	case <-d.done:
+		// the response might have been matched before the receiver failed
+		select {
+		case m := <-resp:
+			return m, nil
+		default:
+		}
+		return nil, d.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// close detaches the receiver and waits for the receive loop to exit.
+func (d *replyDemux) close(ctx context.Context) error {
+	err := d.rcv.Close(ctx)
+	<-d.done
+	return err
+}
+
+// receive hands the responses received to the callers waiting for them
+// until the receiver fails.
+func (d *replyDemux) receive() {
+	for {
+		msg, err := d.rcv.Receive(context.Background())
+		if err != nil {
+			d.err = err
+			close(d.done)
+			return
+		}
+		_ = d.rcv.AcceptMessage(context.Background(), msg)
+		if msg.Properties == nil || msg.Properties.CorrelationID == nil {
+			continue
+		}
+		d.mu.Lock()
+		if resp, ok := d.pending[rpcKey(msg.Properties.CorrelationID)]; ok {
+			select {
+			case resp <- msg:
+			default:
+				// a duplicate response
+			}
+		}
+		d.mu.Unlock()
+	}
+}
+
+// binaryRPCKey is the key of a binary message-id, which
+// isn't comparable, distinct from its string variant.
+type binaryRPCKey string
+
+// rpcKey returns the key of message-id id in the pending calls of a replyDemux.
+func rpcKey(id MessageID) any {
+	if b, ok := id.([]byte); ok {
+		return binaryRPCKey(b)
+	}
+	return id
+}
@@ -0,0 +1,253 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// RPCHandler responds to the requests received by an RPCServer.
+//
+// It returns the response to send to the request's reply-to address, or
+// nil if there's no response. Returning an error rejects the request, with
+// the error itself if it's an *Error, or with amqp:internal-error otherwise.
+// The error is also sent to the reply-to address, so that RPCClient.Call
+// returns it to the caller.
+type RPCHandler func(ctx context.Context, req *Message) (*Message, error)
+
+// RPCServerOptions contains the optional settings for configuring an RPCServer.
+type RPCServerOptions struct {
+	// Concurrency is the maximum number of requests handled concurrently.
+	//
+	// Default: 1.
+	Concurrency int
+
+	// MaxReplySenders is the maximum number of senders to reply-to addresses
+	// kept attached between requests. The least recently used sender is
+	// detached when a request is received from a new reply-to address past
+	// the limit.
+	//
+	// Default: 100.
+	MaxReplySenders int
+}
+
+// RPCServer receives requests sent to an address, passes them to an
+// RPCHandler, and sends its responses to the reply-to address of the
+// requests, with their correlation-id set to the request's message-id.
+//
+// Responses are sent on senders attached to each reply-to address. As reply
+// addresses are often temporary, a sender whose node is gone is detached,
+// and attached again for the next request using the address.
+type RPCServer struct {
+	session     *Session
+	rcv         *Receiver
+	handler     RPCHandler
+	concurrency int
+	maxSenders  int
+
+	mu      sync.Mutex
+	senders map[string]*replySender
+	useSeq  uint64 // incremented on each use of a sender
+}
+
+// replySender is a sender to a reply-to address kept attached by an RPCServer.
+type replySender struct {
+	snd     *Sender
+	lastUse uint64
+}
+
+// NewRPCServer attaches the receiver of an RPCServer receiving requests sent to address.
+// Call Serve to start handling them.
+//
+// opts: pass nil to accept the default values.
+func (s *Session) NewRPCServer(ctx context.Context, address string, handler RPCHandler, opts *RPCServerOptions) (*RPCServer, error) {
+	var o RPCServerOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Concurrency < 1 {
+		o.Concurrency = 1
+	}
+	if o.MaxReplySenders < 1 {
+		o.MaxReplySenders = 100
+	}
+	rcv, err := s.NewReceiver(ctx, address, &ReceiverOptions{Credit: uint32(o.Concurrency)})
+	if err != nil {
+		return nil, err
+	}
+	return &RPCServer{
+		session:     s,
+		rcv:         rcv,
+		handler:     handler,
+		concurrency: o.Concurrency,
+		maxSenders:  o.MaxReplySenders,
+		senders:     map[string]*replySender{},
+	}, nil
+}
+
+// Serve handles the requests received until ctx is canceled, or the receiver
+// is detached, and returns the corresponding error once the requests being
+// handled have been answered.
+func (s *RPCServer) Serve(ctx context.Context) error {
+	errs := make(chan error, s.concurrency)
+	for i := 0; i < s.concurrency; i++ {
+		go func() {
+			for {
+				req, err := s.rcv.Receive(ctx)
+				if err != nil {
+					errs <- err
+					return
+				}
+				s.handle(ctx, req)
+			}
+		}()
+	}
+	var err error
+	for i := 0; i < s.concurrency; i++ {
+		if e := <-errs; err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Close detaches the receiver of the server and its reply senders.
+func (s *RPCServer) Close(ctx context.Context) error {
+	err := s.rcv.Close(ctx)
+	s.mu.Lock()
+	senders := s.senders
+	s.senders = map[string]*replySender{}
+	s.mu.Unlock()
+	for _, rs := range senders {
+		_ = rs.snd.Close(ctx)
+	}
+	return err
+}
+
+// handle passes req to the handler, sends the response, and settles req.
+// Requests the handler fails are rejected after sending the error to the
+// caller. Requests whose response can't be sent are rejected, unless ctx is
+// canceled, in which case they're released to be handled again.
+func (s *RPCServer) handle(ctx context.Context, req *Message) {
+	resp, err := s.handler(ctx, req)
+	if err != nil && ctx.Err() == nil {
+		rejectErr := rejectionError(err)
+		// the caller is waiting for a response, failing to send it
+		// only leaves the caller to time out
+		_ = s.reply(ctx, req, newRPCErrorResponse(rejectErr))
+		_ = s.rcv.RejectMessage(ctx, req, rejectErr)
+		return
+	}
+	if err == nil && resp != nil {
+		err = s.reply(ctx, req, resp)
+	}
+	if err == nil {
+		_ = s.rcv.AcceptMessage(ctx, req)
+		return
+	}
+	if ctx.Err() != nil {
+		_ = s.rcv.ReleaseMessage(context.Background(), req)
+		return
+	}
+	_ = s.rcv.RejectMessage(ctx, req, rejectionError(err))
+}
+
+// reply sends resp to the reply-to address of req. Responses to requests
+// without a reply-to address are discarded.
+func (s *RPCServer) reply(ctx context.Context, req, resp *Message) error {
+	if req.Properties == nil || req.Properties.ReplyTo == nil {
+		return nil
+	}
+	replyTo := *req.Properties.ReplyTo
+
+	msg := *resp
+	props := MessageProperties{}
+	if resp.Properties != nil {
+		props = *resp.Properties
+	}
+	props.To = &replyTo
+	props.CorrelationID = req.Properties.MessageID
+	if props.CorrelationID == nil {
+		props.CorrelationID = req.Properties.CorrelationID
+	}
+	msg.Properties = &props
+
+	// a sender whose node was deleted since it was last used is only
+	// noticed when sending fails, in which case it's attached again
+	for attempt := 0; ; attempt++ {
+		snd, err := s.replySender(ctx, replyTo)
+		if err != nil {
+			return err
+		}
+		err = snd.Send(ctx, &msg)
+		if err == nil {
+			return nil
+		}
+		var detachErr *DetachError
+		if !errors.As(err, &detachErr) || attempt > 0 {
+			return fmt.Errorf("amqp: sending response to %q: %w", replyTo, err)
+		}
+		s.dropReplySender(replyTo, snd)
+	}
+}
+
+// replySender returns the sender to address, attaching it if needed.
+func (s *RPCServer) replySender(ctx context.Context, address string) (*Sender, error) {
+	if snd := s.cachedReplySender(address); snd != nil {
+		return snd, nil
+	}
+
+	// attach without holding mu, so that handlers replying to other
+	// addresses aren't blocked by the attach
+	snd, err := s.session.NewSender(ctx, address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.useSeq++
+	if rs := s.senders[address]; rs != nil {
+		// attached concurrently by another handler
+		rs.lastUse = s.useSeq
+		go func() { _ = snd.Close(context.Background()) }()
+		return rs.snd, nil
+	}
+	if len(s.senders) >= s.maxSenders {
+		var lruAddr string
+		var lru *replySender
+		for addr, rs := range s.senders {
+			if lru == nil || rs.lastUse < lru.lastUse {
+				lruAddr, lru = addr, rs
+			}
+		}
+		delete(s.senders, lruAddr)
+		go func() { _ = lru.snd.Close(context.Background()) }()
+	}
+	s.senders[address] = &replySender{snd: snd, lastUse: s.useSeq}
+	return snd, nil
+}
+
+// cachedReplySender returns the sender to address, or nil if it isn't attached.
+func (s *RPCServer) cachedReplySender(address string) *Sender {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := s.senders[address]
+	if rs == nil {
+		return nil
+	}
+	s.useSeq++
+	rs.lastUse = s.useSeq
+	return rs.snd
+}
+
+// dropReplySender detaches snd and forgets it as the sender to address.
+func (s *RPCServer) dropReplySender(address string, snd *Sender) {
+	s.mu.Lock()
+	if rs := s.senders[address]; rs != nil && rs.snd == snd {
+		delete(s.senders, address)
+	}
+	s.mu.Unlock()
+	go func() { _ = snd.Close(context.Background()) }()
+}
@@ -0,0 +1,64 @@
+package amqp_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPCServer(t *testing.T) {
+	b := broker.New(&broker.Options{AutoCreateQueues: true})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+
+	conn, err := amqp.Dial(srv.URL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+
+	server, err := session.NewRPCServer(ctx, "upper", func(ctx context.Context, req *amqp.Message) (*amqp.Message, error) {
+		body := string(req.GetData())
+		if body == "" {
+			return nil, &amqp.Error{Condition: amqp.ErrCondInvalidField, Description: "empty request"}
+		}
+		return amqp.NewMessage([]byte(strings.ToUpper(body))), nil
+	}, &amqp.RPCServerOptions{Concurrency: 2})
+	require.NoError(t, err)
+	serveCtx, stop := context.WithCancel(ctx)
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(serveCtx) }()
+
+	client, err := session.NewRPCClient(ctx, "upper", &amqp.RPCClientOptions{ReplyAddress: "replies"})
+	require.NoError(t, err)
+
+	for _, body := range []string{"hello", "world"} {
+		resp, err := client.Call(ctx, amqp.NewMessage([]byte(body)))
+		require.NoError(t, err)
+		require.Equal(t, strings.ToUpper(body), string(resp.GetData()))
+		require.Equal(t, "replies", *resp.Properties.To)
+	}
+
+	// the handler's error is returned to the caller, and the
+	// rejected request isn't redelivered
+	_, err = client.Call(ctx, amqp.NewMessage(nil))
+	var amqpErr *amqp.Error
+	require.ErrorAs(t, err, &amqpErr)
+	require.Equal(t, amqp.ErrCondInvalidField, amqpErr.Condition)
+	require.Equal(t, "empty request", amqpErr.Description)
+	require.Zero(t, b.Queue("upper").Len())
+
+	stop()
+	require.ErrorIs(t, <-served, context.Canceled)
+	require.NoError(t, client.Close(ctx))
+	require.NoError(t, server.Close(ctx))
+}
@@ -0,0 +1,88 @@
+package amqp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// serveTestRPC accepts the links of an RPCClient on serverSession, and
+// responds to each request with its body in upper case, unless it's "ignore".
+func serveTestRPC(t *testing.T, serverSession *Session) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var replies *Sender
+		var requests *Receiver
+		for replies == nil || requests == nil {
+			req, err := serverSession.NextLink(ctx)
+			if err != nil {
+				return
+			}
+			if req.Receiver {
+				requests, err = req.AcceptReceiver(&ReceiverOptions{Credit: 10})
+			} else {
+				replies, err = req.AcceptSender(&SenderOptions{SourceAddress: "reply-1"})
+			}
+			if err != nil {
+				return
+			}
+		}
+
+		for {
+			msg, err := requests.Receive(ctx)
+			if err != nil {
+				return
+			}
+			if err := requests.AcceptMessage(ctx, msg); err != nil {
+				return
+			}
+			body := string(msg.GetData())
+			if body == "ignore" || *msg.Properties.ReplyTo != "reply-1" {
+				continue
+			}
+			resp := NewMessage([]byte(strings.ToUpper(body)))
+			resp.Properties = &MessageProperties{CorrelationID: msg.Properties.MessageID}
+			if err := replies.Send(ctx, resp); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func TestRPCClientCall(t *testing.T) {
+	client, server := newTestServerConn(t)
+	clientSession, serverSession := acceptTestSession(t, client, server)
+	serveTestRPC(t, serverSession)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rpc, err := clientSession.NewRPCClient(ctx, "service", &RPCClientOptions{Timeout: 100 * time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, "reply-1", rpc.ReplyAddress())
+
+	req := NewMessage([]byte("hello"))
+	resp, err := rpc.Call(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, "HELLO", string(resp.GetData()))
+	require.Nil(t, req.Properties)
+
+	req = NewMessage([]byte("binary id"))
+	req.Properties = &MessageProperties{MessageID: MessageIDBinary([]byte{1, 2, 3})}
+	resp, err = rpc.Call(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, "BINARY ID", string(resp.GetData()))
+	require.Nil(t, req.Properties.ReplyTo)
+
+	_, err = rpc.Call(ctx, NewMessage([]byte("ignore")))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, rpc.Close(ctx))
+	_, err = rpc.Call(ctx, NewMessage([]byte("closed")))
+	var detachErr *DetachError
+	require.ErrorAs(t, err, &detachErr)
+}
@@ -1,7 +1,10 @@
 package amqp
 
 import (
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/Azure/go-amqp/internal/debug"
 	"github.com/Azure/go-amqp/internal/encoding"
@@ -235,3 +238,172 @@ func saslXOAUTH2InitialResponse(username string, bearer string) ([]byte, error)
 	}
 	return []byte("user=" + username + "\x01auth=Bearer " + bearer + "\x01\x01"), nil
 }
+
+// SASLServerType represents a SASL mechanism offered to clients by a Listener.
+type SASLServerType func(c *Conn) error
+
+// saslServerMechanism is a SASL mechanism offered by a server-side connection.
+type saslServerMechanism struct {
+	name encoding.Symbol
+
+	// authenticate validates the client's initial response and
+	// returns the authenticated identity.
+	authenticate func(response []byte) (string, error)
+}
+
+func addSASLServerMechanism(c *Conn, m saslServerMechanism) {
+	for i := range c.saslServer {
+		if c.saslServer[i].name == m.name {
+			c.saslServer[i] = m
+			return
+		}
+	}
+	c.saslServer = append(c.saslServer, m)
+}
+
+// SASLServerPlain offers SASL PLAIN authentication, calling validate with
+// the credentials sent by the client. A non-nil error fails authentication.
+// The username becomes the connection's SASL identity.
+//
+// validate is called concurrently for connections that are being negotiated.
+func SASLServerPlain(validate func(username, password string) error) SASLServerType {
+	return func(c *Conn) error {
+		if validate == nil {
+			return errors.New("SASLServerPlain requires a validate function")
+		}
+		addSASLServerMechanism(c, saslServerMechanism{
+			name: saslMechanismPLAIN,
+			authenticate: func(response []byte) (string, error) {
+				// authzid NUL authcid NUL passwd
+				parts := strings.Split(string(response), "\x00")
+				if len(parts) != 3 {
+					return "", errors.New("malformed PLAIN response")
+				}
+				authzid, username, password := parts[0], parts[1], parts[2]
+				if authzid != "" && authzid != username {
+					return "", fmt.Errorf("authorization identity %q not permitted for %q", authzid, username)
+				}
+				if err := validate(username, password); err != nil {
+					return "", err
+				}
+				return username, nil
+			},
+		})
+		return nil
+	}
+}
+
+// SASLServerAnonymous offers SASL ANONYMOUS authentication, which accepts
+// every client. The connection's SASL identity is empty.
+func SASLServerAnonymous() SASLServerType {
+	return func(c *Conn) error {
+		addSASLServerMechanism(c, saslServerMechanism{
+			name: saslMechanismANONYMOUS,
+			authenticate: func([]byte) (string, error) {
+				return "", nil
+			},
+		})
+		return nil
+	}
+}
+
+// SASLServerExternal offers SASL EXTERNAL authentication, where the client
+// has been authenticated by a TLS client certificate. Authentication fails
+// unless the client presented a certificate that was verified during the TLS
+// handshake, see ListenerOptions.TLSConfig.
+// validate is called with the verified certificate and the authorization
+// identity requested by the client, which is often empty. A non-nil error
+// fails authentication, otherwise the requested identity becomes the
+// connection's SASL identity.
+//
+// validate is called concurrently for connections that are being negotiated.
+func SASLServerExternal(validate func(cert *x509.Certificate, identity string) error) SASLServerType {
+	return func(c *Conn) error {
+		if validate == nil {
+			return errors.New("SASLServerExternal requires a validate function")
+		}
+		addSASLServerMechanism(c, saslServerMechanism{
+			name: saslMechanismEXTERNAL,
+			authenticate: func(response []byte) (string, error) {
+				cert := c.PeerCertificate()
+				if cert == nil {
+					return "", errors.New("EXTERNAL requires a verified client certificate")
+				}
+				if err := validate(cert, string(response)); err != nil {
+					return "", err
+				}
+				return string(response), nil
+			},
+		})
+		return nil
+	}
+}
+
+// SASLIdentity returns the identity the client authenticated as during SASL
+// negotiation on a server-side connection. It's empty for client connections,
+// when SASL wasn't negotiated, or when the mechanism doesn't convey an identity.
+func (c *Conn) SASLIdentity() string {
+	return c.saslIdentity
+}
+
+// saslServerMechanisms advertises the offered mechanisms and returns
+// the state to process the client's choice.
+func (c *Conn) saslServerMechanisms() (stateFunc, error) {
+	mechs := make(encoding.MultiSymbol, len(c.saslServer))
+	for i, m := range c.saslServer {
+		mechs[i] = m.name
+	}
+	sm := &frames.SASLMechanisms{Mechanisms: mechs}
+	debug.Log(1, "TX (saslServerMechanisms): %s", sm)
+	err := c.writeFrame(frames.Frame{
+		Type: frames.TypeSASL,
+		Body: sm,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.saslServerInit, nil
+}
+
+// saslServerInit authenticates the client's sasl-init and sends the outcome.
+// On success it returns to acceptProtoHeader for the AMQP protocol header.
+func (c *Conn) saslServerInit() (stateFunc, error) {
+	fr, err := c.readSingleFrame()
+	if err != nil {
+		return nil, err
+	}
+	init, ok := fr.Body.(*frames.SASLInit)
+	if !ok {
+		return nil, fmt.Errorf("saslServerInit: unexpected frame type %T", fr.Body)
+	}
+	debug.Log(1, "RX (saslServerInit): %s", init)
+
+	var identity string
+	authErr := fmt.Errorf("unsupported mechanism %s", init.Mechanism)
+	for _, m := range c.saslServer {
+		if m.name == init.Mechanism {
+			identity, authErr = m.authenticate(init.InitialResponse)
+			break
+		}
+	}
+
+	outcome := &frames.SASLOutcome{Code: encoding.CodeSASLOK}
+	if authErr != nil {
+		outcome.Code = encoding.CodeSASLAuth
+	}
+	debug.Log(1, "TX (saslServerInit): %s", outcome)
+	err = c.writeFrame(frames.Frame{
+		Type: frames.TypeSASL,
+		Body: outcome,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if authErr != nil {
+		return nil, fmt.Errorf("SASL %s auth failed: %w", init.Mechanism, authErr)
+	}
+
+	c.saslIdentity = identity
+	c.saslComplete = true
+	return c.acceptProtoHeader, nil
+}
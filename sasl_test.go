@@ -2,7 +2,10 @@ package amqp
 
 import (
 	"bytes"
+	"context"
+	"crypto/x509"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -13,6 +16,7 @@ import (
 	"github.com/Azure/go-amqp/internal/frames"
 	"github.com/Azure/go-amqp/internal/test"
 	"github.com/Azure/go-amqp/internal/testconn"
+	"github.com/stretchr/testify/require"
 )
 
 // Known good challenges/responses taken following specification:
@@ -284,3 +288,66 @@ func peerResponse(items ...any) ([]byte, error) {
 	}
 	return buf, nil
 }
+
+func TestListenerSASL(t *testing.T) {
+	l := newTestListener(t, &ListenerOptions{
+		SASLTypes: []SASLServerType{
+			SASLServerPlain(func(username, password string) error {
+				if username != "user" || password != "secret" {
+					return errors.New("invalid credentials")
+				}
+				return nil
+			}),
+			SASLServerAnonymous(),
+			SASLServerExternal(func(*x509.Certificate, string) error { return nil }),
+		},
+	})
+	addr := "amqp://" + l.Addr().String()
+
+	for _, tt := range []struct {
+		name     string
+		saslType SASLType
+		identity string
+		fail     bool
+	}{
+		{name: "plain", saslType: SASLTypePlain("user", "secret"), identity: "user"},
+		{name: "plain bad password", saslType: SASLTypePlain("user", "wrong"), fail: true},
+		{name: "anonymous", saslType: SASLTypeAnonymous()},
+		{name: "external without certificate", saslType: SASLTypeExternal("admin"), fail: true},
+		{name: "unsupported mechanism", saslType: SASLTypeXOAUTH2("user", "token", 512), fail: true},
+		{name: "no SASL", fail: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := Dial(addr, &ConnOptions{SASLType: tt.saslType, Timeout: time.Second})
+			if tt.fail {
+				require.Error(t, err)
+				require.Nil(t, client)
+				return
+			}
+			require.NoError(t, err)
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			server, err := l.Accept(ctx)
+			cancel()
+			require.NoError(t, err)
+			require.Equal(t, tt.identity, server.SASLIdentity())
+			require.NoError(t, server.Close())
+		})
+	}
+}
+
+func TestSASLServerPlainMalformed(t *testing.T) {
+	c, err := newConn(nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, SASLServerPlain(func(string, string) error { return nil })(c))
+
+	auth := c.saslServer[0].authenticate
+	_, err = auth([]byte("user\x00secret"))
+	require.Error(t, err)
+	_, err = auth([]byte("other\x00user\x00secret"))
+	require.Error(t, err)
+	identity, err := auth([]byte("user\x00user\x00secret"))
+	require.NoError(t, err)
+	require.Equal(t, "user", identity)
+}
@@ -12,23 +12,43 @@ import (
 	"github.com/Azure/go-amqp/internal/debug"
 	"github.com/Azure/go-amqp/internal/encoding"
 	"github.com/Azure/go-amqp/internal/frames"
-	"github.com/Azure/go-amqp/internal/shared"
 )
 
+// SendInterceptor is called on a message being sent, before it's encoded.
+//
+// It returns the message to send, which can be msg or a modified copy of it,
+// to inject properties or sign payloads. msg is the caller's message, or the
+// message returned by the previous interceptor, and must not be modified.
+// Returning an error vetoes the message: it isn't sent, and the error is
+// returned by Send.
+type SendInterceptor func(ctx context.Context, msg *Message) (*Message, error)
+
 // Sender sends messages on a single AMQP link.
 type Sender struct {
 	l         link
 	transfers chan frames.PerformTransfer // sender uses to send transfer frames
 
+	// creditReady is sent on by mux while credit is available, services WaitForCredit
+	creditReady chan struct{}
+
 	// Indicates whether we should allow detaches on disposition errors or not.
 	// Some AMQP servers (like Event Hubs) benefit from keeping the link open on disposition errors
 	// (for instance, if you're doing many parallel sends over the same link and you get back a
 	// throttling error, which is not fatal)
 	detachOnDispositionError bool
 
+	compression          Compression       // compresses data payloads, nil when disabled
+	compressionThreshold int               // minimum data payload size to compress
+	validateMessages     bool              // call Message.Validate before sending
+	interceptors         []SendInterceptor // called on messages before validation
+	starvation           stallTimer        // detects prolonged lack of link credit, owned by mux
+	opts                 SenderOptions     // the options the sender was created with, used by Reattach
+
 	mu              sync.Mutex // protects buf and nextDeliveryTag
 	buf             buffer.Buffer
 	nextDeliveryTag uint64
+
+	resume *unsettledDeliveries // unsettled deliveries resumed by Reattach, nil when disabled
 }
 
 // LinkName() is the name of the link used for this Sender.
@@ -41,6 +61,54 @@ func (s *Sender) MaxMessageSize() uint64 {
 	return s.l.maxMessageSize
 }
 
+// RemoteProperties returns the link properties the peer sent in its attach
+// performative, or nil if it sent none or the sender isn't attached yet.
+func (s *Sender) RemoteProperties() map[string]any {
+	return s.l.remoteProperties()
+}
+
+// RemoteOfferedCapabilities returns the link capabilities the peer offered
+// in its attach performative, or nil if the sender isn't attached yet.
+func (s *Sender) RemoteOfferedCapabilities() []string {
+	offered, _ := s.l.remoteCapabilities()
+	return offered
+}
+
+// RemoteDesiredCapabilities returns the link capabilities the peer desired
+// in its attach performative, or nil if the sender isn't attached yet.
+func (s *Sender) RemoteDesiredCapabilities() []string {
+	_, desired := s.l.remoteCapabilities()
+	return desired
+}
+
+// RemoteSource returns the source of the link as attached by the peer, or
+// nil if the sender isn't attached yet.
+func (s *Sender) RemoteSource() *Terminus {
+	if s.l.remoteAttach == nil {
+		return nil
+	}
+	return newSourceTerminus(s.l.remoteAttach.Source)
+}
+
+// RemoteTarget returns the target of the link as attached by the peer, or
+// nil if the sender isn't attached yet.
+func (s *Sender) RemoteTarget() *Terminus {
+	if s.l.remoteAttach == nil {
+		return nil
+	}
+	return newTargetTerminus(s.l.remoteAttach.Target)
+}
+
+// RemoteMaxMessageSize returns the maximum message size the peer sent in
+// its attach performative, zero if it has no maximum or the sender isn't
+// attached yet.
+func (s *Sender) RemoteMaxMessageSize() uint64 {
+	if s.l.remoteAttach == nil {
+		return 0
+	}
+	return s.l.remoteAttach.MaxMessageSize
+}
+
 // Send sends a Message.
 //
 // Blocks until the message is sent, ctx completes, or an error occurs.
@@ -50,30 +118,127 @@ func (s *Sender) MaxMessageSize() uint64 {
 // has been requested (receiver settle mode is "Second"). In this case,
 // additional messages can be sent while the current goroutine is waiting
 // for the confirmation.
-func (s *Sender) Send(ctx context.Context, msg *Message) error {
+func (s *Sender) Send(ctx context.Context, msg *Message) (err error) {
+	defer func() {
+		s.l.metrics().MessageSent(s.l.target.Address, err)
+		err = s.l.translateErr(err)
+	}()
+
+	state, err := s.sendAndWait(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if state, ok := state.(*encoding.StateRejected); ok {
+		if s.detachOnRejectDisp() {
+			// TODO: this appears to be duplicated in the mux
+			return &DetachError{RemoteErr: state.Error}
+		}
+		return state.Error
+	}
+	return nil
+}
+
+// SendWithOutcome is like Send but returns the outcome of the delivery
+// reported by the receiver, allowing released or modified messages to be
+// redelivered.
+//
+// Unlike Send, a rejected message isn't reported as an error, the error
+// provided by the receiver is in Outcome.Error. Whether the link is detached
+// on rejection is still controlled by SenderOptions.IgnoreDispositionErrors.
+func (s *Sender) SendWithOutcome(ctx context.Context, msg *Message) (_ Outcome, err error) {
+	defer func() {
+		s.l.metrics().MessageSent(s.l.target.Address, err)
+		err = s.l.translateErr(err)
+	}()
+
+	state, err := s.sendAndWait(ctx, msg)
+	if err != nil {
+		return Outcome{}, err
+	}
+	return newOutcome(state), nil
+}
+
+// sendAndWait sends msg and waits for the delivery to be settled.
+func (s *Sender) sendAndWait(ctx context.Context, msg *Message) (encoding.DeliveryState, error) {
+	done, _, release, err := s.beginSend(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// wait for transfer to be confirmed
+	select {
+	case state := <-done:
+		return state, nil
+	case <-s.l.detached:
+		return nil, s.l.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// beginSend sends msg, returning the channel its delivery state is sent on
+// once it's settled and its delivery tag. release must be called once the
+// delivery is settled, or no longer waited for, to release the resources
+// reserved for it.
+func (s *Sender) beginSend(ctx context.Context, msg *Message) (_ chan encoding.DeliveryState, tag []byte, release func(), _ error) {
+	if err := s.l.lazy.ensure(ctx); err != nil {
+		return nil, nil, nil, err
+	}
+
 	// check if the link is dead.  while it's safe to call s.send
 	// in this case, this will avoid some allocations etc.
 	select {
 	case <-s.l.detached:
-		return s.l.err
+		return nil, nil, nil, s.l.err
 	default:
 		// link is still active
 	}
-	done, err := s.send(ctx, msg)
+	release = func() {}
+	if budget := s.l.session.budget; budget != nil && !s.presettled(msg) {
+		err := budget.reserve(1, 0, func(released <-chan struct{}) error {
+			select {
+			case <-released:
+				return nil
+			case <-s.l.detached:
+				return s.l.err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if limitErr, ok := err.(*LimitError); ok && limitErr.policy == LimitDetach {
+			_ = s.l.closeWithError(limitErr.remoteErr())
+			return nil, nil, nil, &DetachError{inner: limitErr}
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		release = func() { budget.release(1, 0) }
+	}
+
+	done, tag, err := s.send(ctx, msg)
 	if err != nil {
+		release()
+		return nil, nil, nil, err
+	}
+	return done, tag, release, nil
+}
+
+// WaitForCredit blocks until the receiver has granted credit for at least
+// one message, ctx completes, or the link is detached.
+//
+// It's intended for dispatchers that choose which message to send, and to
+// which Sender, once credit is available. When Send is called concurrently
+// the credit may have been consumed by the time the caller sends.
+func (s *Sender) WaitForCredit(ctx context.Context) (err error) {
+	defer func() { err = s.l.translateErr(err) }()
+
+	if err := s.l.lazy.ensure(ctx); err != nil {
 		return err
 	}
 
-	// wait for transfer to be confirmed
 	select {
-	case state := <-done:
-		if state, ok := state.(*encoding.StateRejected); ok {
-			if s.detachOnRejectDisp() {
-				// TODO: this appears to be duplicated in the mux
-				return &DetachError{RemoteErr: state.Error}
-			}
-			return state.Error
-		}
+	case <-s.creditReady:
 		return nil
 	case <-s.l.detached:
 		return s.l.err
@@ -82,15 +247,44 @@ func (s *Sender) Send(ctx context.Context, msg *Message) error {
 	}
 }
 
+// SendValue encodes v using the body codec registered for contentType
+// and sends it as the data payload of a new message.
+//
+// See NewMessageWithBody and RegisterBodyCodec for details.
+func (s *Sender) SendValue(ctx context.Context, contentType string, v any) error {
+	msg, err := NewMessageWithBody(contentType, v)
+	if err != nil {
+		return s.l.translateErr(err)
+	}
+	return s.Send(ctx, msg)
+}
+
 // send is separated from Send so that the mutex unlock can be deferred without
 // locking the transfer confirmation that happens in Send.
-func (s *Sender) send(ctx context.Context, msg *Message) (chan encoding.DeliveryState, error) {
-	const (
-		maxDeliveryTagLength   = 32
-		maxTransferFrameHeader = 66 // determined by calcMaxTransferFrameHeader
-	)
+func (s *Sender) send(ctx context.Context, msg *Message) (chan encoding.DeliveryState, []byte, error) {
+	const maxDeliveryTagLength = 32
 	if len(msg.DeliveryTag) > maxDeliveryTagLength {
-		return nil, fmt.Errorf("delivery tag is over the allowed %v bytes, len: %v", maxDeliveryTagLength, len(msg.DeliveryTag))
+		return nil, nil, fmt.Errorf("delivery tag is over the allowed %v bytes, len: %v", maxDeliveryTagLength, len(msg.DeliveryTag))
+	}
+
+	for _, intercept := range s.interceptors {
+		var err error
+		if msg, err = intercept(ctx, msg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if s.validateMessages {
+		if err := msg.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if s.compression != nil {
+		var err error
+		if msg, err = compressMessage(s.compression, s.compressionThreshold, msg); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	s.mu.Lock()
@@ -99,18 +293,16 @@ func (s *Sender) send(ctx context.Context, msg *Message) (chan encoding.Delivery
 	s.buf.Reset()
 	err := msg.Marshal(&s.buf)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if s.l.maxMessageSize != 0 && uint64(s.buf.Len()) > s.l.maxMessageSize {
-		return nil, fmt.Errorf("encoded message size exceeds max of %d", s.l.maxMessageSize)
+		return nil, nil, fmt.Errorf("encoded message size exceeds max of %d", s.l.maxMessageSize)
 	}
 
 	var (
-		maxPayloadSize = int64(s.l.session.conn.peerMaxFrameSize) - maxTransferFrameHeader
-		sndSettleMode  = s.l.senderSettleMode
-		senderSettled  = sndSettleMode != nil && (*sndSettleMode == SenderSettleModeSettled || (*sndSettleMode == SenderSettleModeMixed && msg.SendSettled))
-		deliveryID     = atomic.AddUint32(&s.l.session.nextDeliveryID, 1)
+		senderSettled = s.presettled(msg)
+		deliveryID    = atomic.AddUint32(&s.l.session.nextDeliveryID, 1)
 	)
 
 	deliveryTag := msg.DeliveryTag
@@ -121,15 +313,39 @@ func (s *Sender) send(ctx context.Context, msg *Message) (chan encoding.Delivery
 		s.nextDeliveryTag++
 	}
 
+	if s.resume != nil && !senderSettled {
+		s.resume.add(deliveryID, &unsettledDelivery{
+			tag:     deliveryTag,
+			format:  msg.Format,
+			payload: append([]byte(nil), s.buf.Bytes()...),
+		})
+	}
+
 	fr := frames.PerformTransfer{
 		Handle:        s.l.handle,
 		DeliveryID:    &deliveryID,
 		DeliveryTag:   deliveryTag,
 		MessageFormat: &msg.Format,
-		More:          s.buf.Len() > 0,
+	}
+	if msg.txnID != nil {
+		// the message is only published once the transaction commits
+		fr.State = &encoding.StateTransactional{TxnID: msg.txnID}
 	}
 
-	for fr.More {
+	done, err := s.transfer(ctx, fr, senderSettled)
+	if err != nil && s.resume != nil {
+		s.resume.settle(deliveryID, deliveryID)
+	}
+	return done, deliveryTag, err
+}
+
+// transfer sends fr with the contents of s.buf as its payload, split across
+// as many transfer frames as needed. s.mu must be held.
+func (s *Sender) transfer(ctx context.Context, fr frames.PerformTransfer, senderSettled bool) (chan encoding.DeliveryState, error) {
+	const maxTransferFrameHeader = 66 // determined by calcMaxTransferFrameHeader
+	maxPayloadSize := int64(s.l.session.conn.peerMaxFrameSize) - maxTransferFrameHeader
+
+	for {
 		buf, _ := s.buf.Next(maxPayloadSize)
 		fr.Payload = append([]byte(nil), buf...)
 		fr.More = s.buf.Len() > 0
@@ -154,13 +370,65 @@ func (s *Sender) send(ctx context.Context, msg *Message) (chan encoding.Delivery
 			return nil, ctx.Err()
 		}
 
+		if !fr.More {
+			return fr.Done, nil
+		}
+
 		// clear values that are only required on first message
 		fr.DeliveryID = nil
 		fr.DeliveryTag = nil
 		fr.MessageFormat = nil
 	}
+}
+
+// resumeDeliveries resumes the deliveries recovered from the suspended link
+// after it's reattached, based on the unsettled map of the peer's attach.
+func (s *Sender) resumeDeliveries(ctx context.Context) error {
+	peer := s.l.remoteAttach.Unsettled
+	for _, d := range s.resume.takeRecovered() {
+		state, known := peer[string(d.tag)]
+		if err := s.resumeDelivery(ctx, d, state, known); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumeDelivery resumes d. If the receiver has an outcome for it, it's
+// settled with that outcome, else it's transferred again. known is true if
+// the receiver has a record of d, in which case the transfer is flagged as
+// resumed.
+func (s *Sender) resumeDelivery(ctx context.Context, d *unsettledDelivery, state encoding.DeliveryState, known bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveryID := atomic.AddUint32(&s.l.session.nextDeliveryID, 1)
+	fr := frames.PerformTransfer{
+		Handle:        s.l.handle,
+		DeliveryID:    &deliveryID,
+		DeliveryTag:   d.tag,
+		MessageFormat: &d.format,
+		Resume:        known,
+	}
+
+	s.buf.Reset()
+	settled := known && isTerminal(state)
+	if settled {
+		// the receiver has the outcome, settle the delivery with it
+		fr.State = state
+	} else {
+		s.buf.Append(d.payload)
+		s.resume.add(deliveryID, d)
+	}
+
+	_, err := s.transfer(ctx, fr, settled)
+	return err
+}
 
-	return fr.Done, nil
+// presettled returns true if msg is sent settled.
+func (s *Sender) presettled(msg *Message) bool {
+	ssm := s.l.senderSettleMode
+	return ssm != nil && (*ssm == SenderSettleModeSettled || (*ssm == SenderSettleModeMixed && msg.SendSettled))
 }
 
 // Address returns the link's address.
@@ -171,28 +439,90 @@ func (s *Sender) Address() string {
 	return s.l.target.Address
 }
 
+// Open attaches a Sender created with SenderOptions.LazyAttach if it isn't
+// attached yet. It's a no-op for other senders.
+func (s *Sender) Open(ctx context.Context) (err error) {
+	defer func() { err = s.l.translateErr(err) }()
+
+	return s.l.lazy.ensure(ctx)
+}
+
 // Close closes the Sender and AMQP link.
-func (s *Sender) Close(ctx context.Context) error {
+func (s *Sender) Close(ctx context.Context) (err error) {
+	defer func() { err = s.l.translateErr(err) }()
+
 	return s.l.closeLink(ctx)
 }
 
+// Detach suspends the Sender, detaching its link without closing it.
+// The peer retains the link's terminus state, so the link can be resumed
+// later with Reattach. The Sender's methods return a *DetachError with
+// Suspended set once it's detached.
+func (s *Sender) Detach(ctx context.Context) (err error) {
+	defer func() { err = s.l.translateErr(err) }()
+
+	return s.l.detachLink(ctx)
+}
+
+// Reattach attaches the link of a Sender that was suspended, by Detach or
+// by the peer, again on the same session. It returns a new Sender for the
+// link, with the same name, termini, and options as s. With
+// SenderOptions.ResumeDeliveries, the unsettled deliveries of s are resumed
+// on the new link before it's returned.
+func (s *Sender) Reattach(ctx context.Context) (_ *Sender, err error) {
+	defer func() { err = s.l.session.conn.translateErr(err) }()
+	if !s.l.isSuspended() {
+		return nil, errors.New("amqp: sender isn't suspended")
+	}
+
+	opts := s.opts
+	opts.Name = s.l.key.name
+	opts.DynamicAddress = false
+	opts.FollowRedirects = false
+	opts.LazyAttach = false
+	ns, err := newSender(s.Address(), s.l.session, &opts)
+	if err != nil {
+		return nil, err
+	}
+	if s.resume != nil {
+		ns.resume.recover(s.resume)
+	}
+	if err := ns.attach(ctx); err != nil {
+		return nil, err
+	}
+	if ns.resume != nil {
+		if err := ns.resumeDeliveries(ctx); err != nil {
+			_ = ns.l.closeWithError(nil)
+			return nil, err
+		}
+	}
+	return ns, nil
+}
+
 // newSendingLink creates a new sending link and attaches it to the session
 func newSender(target string, session *Session, opts *SenderOptions) (*Sender, error) {
+	name, err := newLinkName(session)
+	if err != nil {
+		return nil, err
+	}
 	s := &Sender{
 		l: link{
-			key:      linkKey{shared.RandString(40), encoding.RoleSender},
+			key:      linkKey{name, encoding.RoleSender},
 			session:  session,
 			close:    make(chan struct{}),
 			detached: make(chan struct{}),
+			debugReq: make(chan chan string),
 			target:   &frames.Target{Address: target},
 			source:   new(frames.Source),
 		},
 		detachOnDispositionError: true,
+		creditReady:              make(chan struct{}),
 	}
 
 	if opts == nil {
 		return s, nil
 	}
+	s.opts = *opts
 
 	for _, v := range opts.Capabilities {
 		s.l.source.Capabilities = append(s.l.source.Capabilities, encoding.Symbol(v))
@@ -201,6 +531,19 @@ func newSender(target string, session *Session, opts *SenderOptions) (*Sender, e
 		return nil, fmt.Errorf("invalid Durability %d", opts.Durability)
 	}
 	s.l.source.Durable = opts.Durability
+	if opts.CompressionThreshold < 0 {
+		return nil, fmt.Errorf("invalid CompressionThreshold %d", opts.CompressionThreshold)
+	}
+	s.compression = opts.Compression
+	s.compressionThreshold = opts.CompressionThreshold
+	if opts.CreditStarvationThreshold < 0 {
+		return nil, fmt.Errorf("invalid CreditStarvationThreshold %d", opts.CreditStarvationThreshold)
+	}
+	s.starvation.clock = s.l.timers()
+	s.starvation.threshold = opts.CreditStarvationThreshold
+	if err := s.l.setTimeouts(opts.AttachTimeout, opts.DetachTimeout); err != nil {
+		return nil, err
+	}
 	if opts.DynamicAddress {
 		s.l.target.Address = ""
 		s.l.dynamicAddr = opts.DynamicAddress
@@ -213,6 +556,11 @@ func newSender(target string, session *Session, opts *SenderOptions) (*Sender, e
 	}
 	s.l.source.Timeout = opts.ExpiryTimeout
 	s.detachOnDispositionError = !opts.IgnoreDispositionErrors
+	if opts.ResumeDeliveries {
+		s.resume = newUnsettledDeliveries()
+	}
+	s.interceptors = append([]SendInterceptor(nil), opts.Interceptors...)
+	s.validateMessages = opts.ValidateMessages
 	if opts.Name != "" {
 		s.l.key.name = opts.Name
 	}
@@ -225,6 +573,7 @@ func newSender(target string, session *Session, opts *SenderOptions) (*Sender, e
 			s.l.properties[encoding.Symbol(k)] = v
 		}
 	}
+	s.l.setCapabilities(opts.OfferedCapabilities, opts.DesiredCapabilities)
 	if opts.RequestedReceiverSettleMode != nil {
 		if rsm := *opts.RequestedReceiverSettleMode; rsm > ReceiverSettleModeSecond {
 			return nil, fmt.Errorf("invalid RequestedReceiverSettleMode %d", rsm)
@@ -241,6 +590,10 @@ func newSender(target string, session *Session, opts *SenderOptions) (*Sender, e
 	for _, v := range opts.TargetCapabilities {
 		s.l.target.Capabilities = append(s.l.target.Capabilities, encoding.Symbol(v))
 	}
+	if opts.RoutingType > RoutingTypeMulticast {
+		return nil, fmt.Errorf("invalid RoutingType %d", opts.RoutingType)
+	}
+	s.l.target.Capabilities = append(s.l.target.Capabilities, opts.RoutingType.capabilities()...)
 	if opts.TargetDurability != DurabilityNone {
 		s.l.target.Durable = opts.TargetDurability
 	}
@@ -268,6 +621,9 @@ func (s *Sender) attach(ctx context.Context) error {
 			pa.Target = new(frames.Target)
 		}
 		pa.Target.Dynamic = s.l.dynamicAddr
+		if s.resume != nil {
+			pa.Unsettled = s.resume.unsettledMap()
+		}
 	}, func(pa *frames.PerformAttach) {
 		if s.l.target == nil {
 			s.l.target = new(frames.Target)
@@ -289,17 +645,30 @@ func (s *Sender) attach(ctx context.Context) error {
 }
 
 func (s *Sender) mux() {
-	defer s.l.muxDetach(context.Background(), nil, nil)
+	defer func() {
+		ctx, cancel := s.l.detachContext(0)
+		defer cancel()
+		s.l.muxDetach(ctx, s.starvation.stop, nil)
+	}()
 
 Loop:
 	for {
 		var outgoingTransfers chan frames.PerformTransfer
+		var creditReady chan struct{}
 		if s.l.availableCredit > 0 {
 			debug.Log(1, "sender: credit: %d, deliveryCount: %d", s.l.availableCredit, s.l.deliveryCount)
 			outgoingTransfers = s.transfers
+			creditReady = s.creditReady
 		}
 
 		select {
+		case creditReady <- struct{}{}:
+			// a WaitForCredit caller has been released
+
+		case <-s.starvation.update(s.l.availableCredit == 0):
+			s.starvation.fire()
+			s.l.reportStall(LinkEventCreditStarved)
+
 		// received frame
 		case fr := <-s.l.rx:
 			s.l.err = s.muxHandleFrame(fr)
@@ -317,8 +686,7 @@ Loop:
 				case s.l.session.txTransfer <- &tr:
 					// decrement link-credit after entire message transferred
 					if !tr.More {
-						s.l.deliveryCount++
-						s.l.availableCredit--
+						s.l.creditConsumed()
 						// we are the sender and we keep track of the peer's link credit
 						debug.Log(3, "TX (sender): key:%s, decremented linkCredit: %d", s.l.key.name, s.l.availableCredit)
 					}
@@ -337,6 +705,9 @@ Loop:
 				}
 			}
 
+		case req := <-s.l.debugReq:
+			req <- s.l.debugState()
+
 		case <-s.l.close:
 			s.l.err = &DetachError{}
 			return
@@ -361,6 +732,7 @@ func (s *Sender) muxHandleFrame(fr frames.FrameBody) error {
 			linkCredit += *fr.DeliveryCount
 		}
 		s.l.availableCredit = linkCredit
+		s.l.emitEvent(LinkEventCreditGranted, nil)
 
 		if !fr.Echo {
 			return nil
@@ -383,6 +755,15 @@ func (s *Sender) muxHandleFrame(fr frames.FrameBody) error {
 
 	case *frames.PerformDisposition:
 		debug.Log(3, "RX (sender): %s", fr)
+		if s.resume != nil {
+			// the deliveries are settled below if the receiver didn't
+			last := fr.First
+			if fr.Last != nil {
+				last = *fr.Last
+			}
+			s.resume.settle(fr.First, last)
+		}
+
 		// If sending async and a message is rejected, cause a link error.
 		//
 		// This isn't ideal, but there isn't a clear better way to handle it.
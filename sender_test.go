@@ -4,13 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/Azure/go-amqp/internal/encoding"
 	"github.com/Azure/go-amqp/internal/frames"
-	"github.com/Azure/go-amqp/internal/mocks"
+	"github.com/Azure/go-amqp/mocks"
 	"github.com/stretchr/testify/require"
 )
 
@@ -32,6 +34,22 @@ func TestSenderInvalidOptions(t *testing.T) {
 	cancel()
 	require.Error(t, err)
 	require.Nil(t, snd)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err = session.NewSender(ctx, "target", &SenderOptions{
+		CompressionThreshold: -1,
+	})
+	cancel()
+	require.Error(t, err)
+	require.Nil(t, snd)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err = session.NewSender(ctx, "target", &SenderOptions{
+		CreditStarvationThreshold: -1,
+	})
+	cancel()
+	require.Error(t, err)
+	require.Nil(t, snd)
 }
 
 func TestSenderMethodsNoSend(t *testing.T) {
@@ -190,7 +208,7 @@ func TestSenderSendOnDetached(t *testing.T) {
 		errcon  = "detaching"
 		errdesc = "server side detach"
 	)
-	b, err := mocks.PerformDetach(0, 0, &Error{Condition: errcon, Description: errdesc})
+	b, err := mocks.PerformDetach(0, 0, &Error{Condition: errcon, Description: errdesc, Info: map[string]any{"tracking-id": "abc"}})
 	require.NoError(t, err)
 	netConn.SendFrame(b)
 	// sending on a detached link returns a DetachError
@@ -201,6 +219,295 @@ func TestSenderSendOnDetached(t *testing.T) {
 	require.ErrorAs(t, de, &detachErr)
 	require.Equal(t, ErrCond(errcon), detachErr.RemoteErr.Condition)
 	require.Equal(t, errdesc, detachErr.RemoteErr.Description)
+	info, ok := ErrorInfo(err)
+	require.True(t, ok)
+	require.Equal(t, map[string]any{"tracking-id": "abc"}, info)
+	require.NoError(t, client.Close())
+}
+
+func TestSenderSendOnLinkStolen(t *testing.T) {
+	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
+
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", nil)
+	cancel()
+	require.NoError(t, err)
+	b, err := mocks.PerformDetach(0, 0, &Error{Condition: ErrCondStolen, Description: "link stolen"})
+	require.NoError(t, err)
+	netConn.SendFrame(b)
+	err = snd.Send(context.Background(), NewMessage([]byte("failed")))
+	var stolenErr *LinkStolenError
+	require.ErrorAs(t, err, &stolenErr)
+	require.Equal(t, ErrCondStolen, stolenErr.RemoteErr.Condition)
+	require.False(t, IsRetryable(err))
+	require.NoError(t, client.Close())
+}
+
+func TestSenderRemoteAttach(t *testing.T) {
+	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", &SenderOptions{
+		TargetDurability: DurabilityUnsettledState,
+	})
+	cancel()
+	require.NoError(t, err)
+	// the peer didn't agree to the durability requested
+	require.Equal(t, &Terminus{
+		Address:      "test",
+		Durability:   DurabilityNone,
+		ExpiryPolicy: ExpiryPolicySessionEnd,
+	}, snd.RemoteTarget())
+	require.Nil(t, snd.RemoteSource())
+	require.Equal(t, uint64(math.MaxUint32), snd.RemoteMaxMessageSize())
+	require.NoError(t, client.Close())
+}
+
+func TestSenderAttachRedirect(t *testing.T) {
+	var netConn *mocks.NetConn
+	var attaches []string
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *frames.PerformAttach:
+			attaches = append(attaches, tt.Target.Address)
+			if tt.Target.Address == "new" {
+				return mocks.SenderAttach(0, tt.Name, 0, SenderSettleModeUnsettled)
+			}
+			b, err := mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformAttach{
+				Name: tt.Name,
+				Role: encoding.RoleReceiver,
+			})
+			if err != nil {
+				return nil, err
+			}
+			netConn.SendFrame(b)
+			return mocks.PerformDetach(0, 0, &Error{
+				Condition: ErrCondLinkRedirect,
+				Info: map[string]any{
+					"hostname":     "example.com",
+					"network-host": "10.0.0.1",
+					"port":         uint32(5671),
+					"address":      "new",
+				},
+			})
+		case *frames.PerformDetach:
+			return nil, nil
+		default:
+			return senderFrameHandler(SenderSettleModeUnsettled)(req)
+		}
+	}
+	netConn = mocks.NewNetConn(responder)
+	client, err := NewConn(netConn, &ConnOptions{HostName: "10.0.0.1"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+
+	// without FollowRedirects the redirect is returned
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "old", nil)
+	cancel()
+	require.Nil(t, snd)
+	var redirectErr *LinkRedirectError
+	require.ErrorAs(t, err, &redirectErr)
+	require.Equal(t, "example.com", redirectErr.Hostname)
+	require.Equal(t, "10.0.0.1", redirectErr.NetworkHost)
+	require.Equal(t, uint16(5671), redirectErr.Port)
+	require.Equal(t, "new", redirectErr.Address)
+	var amqpErr *Error
+	require.ErrorAs(t, err, &amqpErr)
+
+	// with FollowRedirects the sender attaches to the new address
+	attaches = nil
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err = session.NewSender(ctx, "old", &SenderOptions{FollowRedirects: true})
+	cancel()
+	require.NoError(t, err)
+	require.Equal(t, "new", snd.Address())
+	require.Equal(t, []string{"old", "new"}, attaches)
+	require.NoError(t, client.Close())
+}
+
+func TestSenderAttachTimeout(t *testing.T) {
+	detached := make(chan struct{}, 1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch req.(type) {
+		case *frames.PerformAttach:
+			// the broker is too slow to respond
+			return nil, nil
+		case *frames.PerformDetach:
+			detached <- struct{}{}
+			return nil, nil
+		}
+		return senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)(req)
+	}
+	netConn := mocks.NewNetConn(responder)
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	snd, err := session.NewSender(ctx, "target", &SenderOptions{
+		Name:          "slow",
+		AttachTimeout: 20 * time.Millisecond,
+		DetachTimeout: 20 * time.Millisecond,
+	})
+	cancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Nil(t, snd)
+
+	// the half-open link is detached
+	select {
+	case <-detached:
+	case <-time.After(time.Second):
+		t.Fatal("half-open link wasn't detached")
+	}
+	linkCount := func() int {
+		session.linksMu.RLock()
+		defer session.linksMu.RUnlock()
+		return len(session.linksByKey)
+	}
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 1, linkCount(), "the handle must be kept until the peer detaches")
+
+	// the peer eventually responds and acknowledges the detach
+	b, err := mocks.NewFrameBuilder().
+		SenderAttach(0, "slow", 0, SenderSettleModeUnsettled).
+		Detach(0, 0, nil).
+		Bytes()
+	require.NoError(t, err)
+	netConn.SendFrame(b)
+	require.Eventually(t, func() bool { return linkCount() == 0 }, time.Second, time.Millisecond)
+
+	require.NoError(t, client.Close())
+}
+
+func TestSenderCloseDetachTimeout(t *testing.T) {
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		if _, ok := req.(*frames.PerformDetach); ok {
+			// the detach is never acknowledged
+			return nil, nil
+		}
+		return senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)(req)
+	}
+	netConn := mocks.NewNetConn(responder)
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", &SenderOptions{DetachTimeout: 20 * time.Millisecond})
+	cancel()
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	start := time.Now()
+	require.NoError(t, snd.Close(ctx))
+	cancel()
+	require.Less(t, time.Since(start), time.Second)
+	require.NoError(t, client.Close())
+}
+
+func TestSenderInvalidTimeouts(t *testing.T) {
+	_, err := newSender("target", nil, &SenderOptions{AttachTimeout: -1})
+	require.ErrorContains(t, err, "invalid AttachTimeout")
+	_, err = newSender("target", nil, &SenderOptions{DetachTimeout: -1})
+	require.ErrorContains(t, err, "invalid DetachTimeout")
+}
+
+func TestSenderLazyAttach(t *testing.T) {
+	var attaches sync.Map
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		if attach, ok := req.(*frames.PerformAttach); ok {
+			attaches.Store(attach.Name, true)
+		}
+		return senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)(req)
+	}
+	netConn := mocks.NewNetConn(responder)
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	snd, err := session.NewSender(ctx, "target", &SenderOptions{Name: "opened", LazyAttach: true})
+	require.NoError(t, err)
+	unused, err := session.NewSender(ctx, "target", &SenderOptions{Name: "unused", LazyAttach: true})
+	require.NoError(t, err)
+	_, ok := attaches.Load("opened")
+	require.False(t, ok, "attach must be deferred")
+
+	require.NoError(t, snd.Open(ctx))
+	_, ok = attaches.Load("opened")
+	require.True(t, ok)
+	require.NoError(t, snd.Open(ctx), "opening an attached sender is a no-op")
+	require.NoError(t, snd.Close(ctx))
+
+	// a sender closed before being used is never attached
+	require.NoError(t, unused.Close(ctx))
+	var detachErr *DetachError
+	require.ErrorAs(t, unused.Send(ctx, NewMessage([]byte("test"))), &detachErr)
+	_, ok = attaches.Load("unused")
+	require.False(t, ok)
+
+	require.NoError(t, client.Close())
+}
+
+func TestSenderLazyAttachContextExpires(t *testing.T) {
+	attached := make(chan string, 1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		if attach, ok := req.(*frames.PerformAttach); ok {
+			// respond once the first caller has given up
+			attached <- attach.Name
+			return nil, nil
+		}
+		return senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)(req)
+	}
+	netConn := mocks.NewNetConn(responder)
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	snd, err := session.NewSender(context.Background(), "target", &SenderOptions{LazyAttach: true})
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	require.ErrorIs(t, snd.Open(ctx), context.DeadlineExceeded)
+	cancel()
+
+	// the context error isn't kept, a later call completes the attach
+	b, err := mocks.SenderAttach(0, <-attached, 0, SenderSettleModeUnsettled)
+	require.NoError(t, err)
+	netConn.SendFrame(b)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	require.NoError(t, snd.Open(ctx))
+	require.NoError(t, snd.Close(ctx))
 	require.NoError(t, client.Close())
 }
 
@@ -345,6 +652,233 @@ func TestSenderSendSuccess(t *testing.T) {
 	require.NoError(t, client.Close())
 }
 
+func TestSenderSendValidateMessages(t *testing.T) {
+	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
+
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", &SenderOptions{
+		ValidateMessages: true,
+	})
+	cancel()
+	require.NoError(t, err)
+
+	sendInitialFlowFrame(t, netConn, 0, 100)
+
+	// the invalid message is rejected before any transfer is sent
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	err = snd.Send(ctx, &Message{})
+	cancel()
+	require.ErrorContains(t, err, "invalid message")
+
+	require.NoError(t, client.Close())
+}
+
+type recordingMetrics struct {
+	nopMetrics
+	mu             sync.Mutex
+	opened, closed int
+	framesSent     map[string]int
+	framesReceived map[string]int
+	sent           []string
+}
+
+func (m *recordingMetrics) ConnOpened() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.opened++
+}
+
+func (m *recordingMetrics) ConnClosed(error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed++
+}
+
+func (m *recordingMetrics) FrameSent(performative string, _ int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.framesSent[performative]++
+}
+
+func (m *recordingMetrics) FrameReceived(performative string, _ int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.framesReceived[performative]++
+}
+
+func (m *recordingMetrics) MessageSent(address string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, fmt.Sprintf("%s:%v", address, err))
+}
+
+func TestSenderSendMetrics(t *testing.T) {
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		b, err := senderFrameHandler(SenderSettleModeUnsettled)(req)
+		if err != nil || b != nil {
+			return b, err
+		}
+		switch tt := req.(type) {
+		case *frames.PerformTransfer:
+			return mocks.PerformDisposition(encoding.RoleReceiver, 0, *tt.DeliveryID, nil, &encoding.StateAccepted{})
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	netConn := mocks.NewNetConn(responder)
+
+	metrics := &recordingMetrics{framesSent: map[string]int{}, framesReceived: map[string]int{}}
+	client, err := NewConn(netConn, &ConnOptions{Metrics: metrics})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", nil)
+	cancel()
+	require.NoError(t, err)
+
+	sendInitialFlowFrame(t, netConn, 0, 100)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	require.NoError(t, snd.Send(ctx, NewMessage([]byte("test"))))
+	cancel()
+
+	require.NoError(t, client.Close())
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	require.Equal(t, 1, metrics.opened)
+	require.Equal(t, 1, metrics.closed)
+	require.Equal(t, []string{"target:<nil>"}, metrics.sent)
+	require.Equal(t, 1, metrics.framesSent["transfer"])
+	require.Equal(t, 1, metrics.framesSent["attach"])
+	require.Equal(t, 1, metrics.framesReceived["disposition"])
+	require.Equal(t, 1, metrics.framesReceived["flow"])
+}
+
+func TestSenderLinkEvents(t *testing.T) {
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		b, err := senderFrameHandler(SenderSettleModeUnsettled)(req)
+		if err != nil || b != nil {
+			return b, err
+		}
+		switch tt := req.(type) {
+		case *frames.PerformTransfer:
+			return mocks.PerformDisposition(encoding.RoleReceiver, 0, *tt.DeliveryID, nil, &encoding.StateAccepted{})
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	netConn := mocks.NewNetConn(responder)
+
+	var (
+		mu     sync.Mutex
+		events []LinkEvent
+	)
+	client, err := NewConn(netConn, &ConnOptions{
+		LinkEventHook: func(ev LinkEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, ev)
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", &SenderOptions{Name: "test-link"})
+	cancel()
+	require.NoError(t, err)
+
+	sendInitialFlowFrame(t, netConn, 0, 1)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	require.NoError(t, snd.Send(ctx, NewMessage([]byte("test"))))
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	require.NoError(t, snd.Close(ctx))
+	cancel()
+	require.NoError(t, client.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	var types []LinkEventType
+	for _, ev := range events {
+		types = append(types, ev.Type)
+		require.Equal(t, "test-link", ev.Name)
+		require.Equal(t, "target", ev.Target)
+		require.False(t, ev.Receiver)
+	}
+	require.Equal(t, []LinkEventType{
+		LinkEventAttach,
+		LinkEventAttachConfirmed,
+		LinkEventCreditGranted,
+		LinkEventCreditExhausted,
+		LinkEventDetach,
+	}, types)
+	require.EqualValues(t, 1, events[2].Credit)
+	require.Zero(t, events[3].Credit)
+
+	var detachErr *DetachError
+	require.ErrorAs(t, events[4].Err, &detachErr)
+	require.Nil(t, detachErr.RemoteErr)
+}
+
+func TestSenderCreditStarvation(t *testing.T) {
+	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
+
+	starved := make(chan LinkEvent, 2)
+	client, err := NewConn(netConn, &ConnOptions{
+		LinkEventHook: func(ev LinkEvent) {
+			if ev.Type == LinkEventCreditStarved {
+				starved <- ev
+			}
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	_, err = session.NewSender(ctx, "target", &SenderOptions{CreditStarvationThreshold: 10 * time.Millisecond})
+	cancel()
+	require.NoError(t, err)
+
+	// no credit has been granted
+	select {
+	case ev := <-starved:
+		require.False(t, ev.Receiver)
+		require.Equal(t, "target", ev.Target)
+		require.Zero(t, ev.Credit)
+	case <-time.After(time.Second):
+		t.Fatal("didn't receive credit starved event")
+	}
+
+	// the event is emitted once per period without credit
+	sendInitialFlowFrame(t, netConn, 0, 100)
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(t, starved)
+	require.EqualValues(t, 1, client.Stats().CreditStarvations)
+
+	require.NoError(t, client.Close())
+}
+
 func TestSenderSendSettled(t *testing.T) {
 	responder := func(req frames.FrameBody) ([]byte, error) {
 		b, err := senderFrameHandler(SenderSettleModeSettled)(req)
@@ -511,6 +1045,66 @@ func TestSenderSendRejectedNoDetach(t *testing.T) {
 	require.NoError(t, client.Close())
 }
 
+func TestSenderSendWithOutcome(t *testing.T) {
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *mocks.AMQPProto:
+			return []byte{'A', 'M', 'Q', 'P', 0, 1, 0, 0}, nil
+		case *frames.PerformOpen:
+			return mocks.PerformOpen("container")
+		case *frames.PerformBegin:
+			return mocks.PerformBegin(0)
+		case *frames.PerformEnd:
+			return mocks.PerformEnd(0, nil)
+		case *frames.PerformAttach:
+			return mocks.SenderAttach(0, tt.Name, 0, SenderSettleModeUnsettled)
+		case *frames.PerformTransfer:
+			return mocks.PerformDisposition(encoding.RoleReceiver, 0, *tt.DeliveryID, nil, &encoding.StateModified{
+				DeliveryFailed:     true,
+				MessageAnnotations: encoding.Annotations{"key": "value"},
+			})
+		case *frames.PerformDetach:
+			return mocks.PerformDetach(0, 0, nil)
+		case *frames.PerformClose:
+			return mocks.PerformClose(nil)
+		default:
+			return nil, fmt.Errorf("unhandled frame %T", req)
+		}
+	}
+	netConn := mocks.NewNetConn(responder)
+
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", nil)
+	cancel()
+	require.NoError(t, err)
+
+	// no credit has been issued yet
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	err = snd.WaitForCredit(ctx)
+	cancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	sendInitialFlowFrame(t, netConn, 0, 100)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	require.NoError(t, snd.WaitForCredit(ctx))
+	outcome, err := snd.SendWithOutcome(ctx, NewMessage([]byte("test")))
+	cancel()
+	require.NoError(t, err)
+	require.Equal(t, OutcomeModified, outcome.Type)
+	require.True(t, outcome.DeliveryFailed)
+	require.False(t, outcome.UndeliverableHere)
+	require.Equal(t, Annotations{"key": "value"}, outcome.Annotations)
+	require.NoError(t, client.Close())
+}
+
 func TestSenderSendDetached(t *testing.T) {
 	responder := func(req frames.FrameBody) ([]byte, error) {
 		b, err := senderFrameHandler(SenderSettleModeUnsettled)(req)
@@ -1055,3 +1649,298 @@ func TestNewSenderContextCancelled(t *testing.T) {
 	// don't let the test exit before the attach frame has a chance to arrive
 	time.Sleep(time.Second)
 }
+
+func TestSenderInterceptors(t *testing.T) {
+	client, server := newTestServerConn(t)
+	clientSession, serverSession := acceptTestSession(t, client, server)
+
+	accepted := make(chan *Receiver, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, err := serverSession.NextLink(ctx)
+		if err != nil {
+			close(accepted)
+			return
+		}
+		rcv, _ := req.AcceptReceiver(&ReceiverOptions{Credit: 10})
+		accepted <- rcv
+	}()
+
+	var order []string
+	addHeader := func(ctx context.Context, msg *Message) (*Message, error) {
+		order = append(order, "header")
+		m := *msg
+		m.ApplicationProperties = map[string]any{"tenant": "contoso"}
+		for k, v := range msg.ApplicationProperties {
+			m.ApplicationProperties[k] = v
+		}
+		return &m, nil
+	}
+	limitSize := func(ctx context.Context, msg *Message) (*Message, error) {
+		order = append(order, "limit")
+		if len(msg.GetData()) > 4 {
+			return nil, errors.New("message too large")
+		}
+		return msg, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	snd, err := clientSession.NewSender(ctx, "target", &SenderOptions{
+		Interceptors: []SendInterceptor{addHeader, limitSize},
+	})
+	require.NoError(t, err)
+	rcv := <-accepted
+	require.NotNil(t, rcv)
+
+	msg := NewMessage([]byte("ping"))
+	go func() {
+		if m, err := rcv.Receive(ctx); err == nil {
+			if m.ApplicationProperties["tenant"] != "contoso" {
+				t.Errorf("unexpected application properties %v", m.ApplicationProperties)
+			}
+			_ = rcv.AcceptMessage(ctx, m)
+			accepted <- nil
+		}
+	}()
+	require.NoError(t, snd.Send(ctx, msg))
+	<-accepted
+	require.Nil(t, msg.ApplicationProperties)
+	require.Equal(t, []string{"header", "limit"}, order)
+
+	// vetoed messages aren't sent
+	require.EqualError(t, snd.Send(ctx, NewMessage([]byte("too large"))), "message too large")
+}
+
+func TestSenderDetachReattach(t *testing.T) {
+	attaches := make(chan *frames.PerformAttach, 2)
+	detaches := make(chan *frames.PerformDetach, 1)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *frames.PerformAttach:
+			attaches <- tt
+		case *frames.PerformDetach:
+			detaches <- tt
+			// acknowledge the suspension without closing the link
+			return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformDetach{Handle: 0})
+		}
+		return senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)(req)
+	}
+	netConn := mocks.NewNetConn(responder)
+
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", &SenderOptions{
+		TargetDurability: DurabilityUnsettledState,
+	})
+	cancel()
+	require.NoError(t, err)
+	first := <-attaches
+
+	_, err = snd.Reattach(context.Background())
+	require.EqualError(t, err, "amqp: sender isn't suspended")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	require.NoError(t, snd.Detach(ctx))
+	cancel()
+	require.False(t, (<-detaches).Closed)
+
+	var detachErr *DetachError
+	require.ErrorAs(t, snd.Send(context.Background(), NewMessage([]byte("test"))), &detachErr)
+	require.True(t, detachErr.Suspended)
+	require.Equal(t, "amqp: link detached", detachErr.Error())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd2, err := snd.Reattach(ctx)
+	cancel()
+	require.NoError(t, err)
+	second := <-attaches
+	require.Equal(t, first.Name, second.Name)
+	require.Equal(t, snd.LinkName(), snd2.LinkName())
+	require.Equal(t, "target", second.Target.Address)
+	require.Equal(t, encoding.DurabilityUnsettledState, second.Target.Durable)
+	require.NoError(t, client.Close())
+}
+
+func TestSenderResumeDeliveries(t *testing.T) {
+	var attaches int
+	unsettled := make(chan encoding.Unsettled, 1)
+	transfers := make(chan *frames.PerformTransfer, 6)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *frames.PerformAttach:
+			if attaches++; attaches == 1 {
+				break
+			}
+			unsettled <- tt.Unsettled
+			// the receiver has an outcome for one delivery and
+			// a record of another, but not of the last one
+			attach, err := mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformAttach{
+				Name:   tt.Name,
+				Role:   encoding.RoleReceiver,
+				Target: &frames.Target{Address: "target"},
+				Unsettled: encoding.Unsettled{
+					"accepted": &encoding.StateAccepted{},
+					"received": nil,
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+			credit, count := uint32(10), uint32(0)
+			flow, err := mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformFlow{
+				NextIncomingID: &count,
+				IncomingWindow: 1000,
+				OutgoingWindow: 1000,
+				Handle:         &count,
+				DeliveryCount:  &count,
+				LinkCredit:     &credit,
+			})
+			return append(attach, flow...), err
+		case *frames.PerformTransfer:
+			transfers <- tt
+			return nil, nil
+		case *frames.PerformDetach:
+			return mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformDetach{Handle: 0})
+		}
+		return senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)(req)
+	}
+	netConn := mocks.NewNetConn(responder)
+
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", &SenderOptions{
+		ResumeDeliveries: true,
+	})
+	cancel()
+	require.NoError(t, err)
+
+	sendInitialFlowFrame(t, netConn, 0, 100)
+
+	// the deliveries aren't settled before the link is suspended
+	for _, tag := range []string{"accepted", "received", "unknown"} {
+		msg := NewMessage([]byte(tag))
+		msg.DeliveryTag = []byte(tag)
+		ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+		require.ErrorIs(t, snd.Send(ctx, msg), context.DeadlineExceeded)
+		cancel()
+		require.Equal(t, []byte(tag), (<-transfers).DeliveryTag)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	require.NoError(t, snd.Detach(ctx))
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	_, err = snd.Reattach(ctx)
+	cancel()
+	require.NoError(t, err)
+	require.Equal(t, encoding.Unsettled{
+		"accepted": nil,
+		"received": nil,
+		"unknown":  nil,
+	}, <-unsettled)
+
+	resumed := map[string]*frames.PerformTransfer{}
+	for i := 0; i < 3; i++ {
+		tr := <-transfers
+		resumed[string(tr.DeliveryTag)] = tr
+	}
+	// settled with the receiver's outcome
+	require.True(t, resumed["accepted"].Resume)
+	require.True(t, resumed["accepted"].Settled)
+	require.Equal(t, &encoding.StateAccepted{}, resumed["accepted"].State)
+	require.Empty(t, resumed["accepted"].Payload)
+	// transferred again
+	require.True(t, resumed["received"].Resume)
+	require.False(t, resumed["received"].Settled)
+	require.NotEmpty(t, resumed["received"].Payload)
+	require.False(t, resumed["unknown"].Resume)
+	require.NotEmpty(t, resumed["unknown"].Payload)
+
+	require.NoError(t, client.Close())
+}
+
+func TestSenderSendWithReceipt(t *testing.T) {
+	deliveryIDs := make(chan uint32, 3)
+	responder := func(req frames.FrameBody) ([]byte, error) {
+		if tr, ok := req.(*frames.PerformTransfer); ok {
+			deliveryIDs <- *tr.DeliveryID
+			return nil, nil
+		}
+		return senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)(req)
+	}
+	netConn := mocks.NewNetConn(responder)
+
+	client, err := NewConn(netConn, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", nil)
+	cancel()
+	require.NoError(t, err)
+
+	sendInitialFlowFrame(t, netConn, 0, 100)
+
+	var receipts []*SendReceipt
+	for _, tag := range []string{"first", "second", "third"} {
+		msg := NewMessage([]byte(tag))
+		msg.DeliveryTag = []byte(tag)
+		ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+		receipt, err := snd.SendWithReceipt(ctx, msg)
+		cancel()
+		require.NoError(t, err)
+		require.Equal(t, []byte(tag), receipt.DeliveryTag())
+		receipts = append(receipts, receipt)
+	}
+	first, second := <-deliveryIDs, <-deliveryIDs
+	<-deliveryIDs
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	_, err = receipts[0].Wait(ctx)
+	cancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// settled out of order
+	b, err := mocks.PerformDisposition(encoding.RoleReceiver, 0, second, nil, &encoding.StateReleased{})
+	require.NoError(t, err)
+	netConn.SendFrame(b)
+	<-receipts[1].Done()
+	b, err = mocks.PerformDisposition(encoding.RoleReceiver, 0, first, nil, &encoding.StateAccepted{})
+	require.NoError(t, err)
+	netConn.SendFrame(b)
+
+	outcome, err := receipts[0].Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, OutcomeAccepted, outcome.Type)
+	outcome, err = receipts[1].Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, OutcomeReleased, outcome.Type)
+
+	// the link is closed before the last one is settled
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	require.NoError(t, snd.Close(ctx))
+	cancel()
+	_, err = receipts[2].Wait(context.Background())
+	var detachErr *DetachError
+	require.ErrorAs(t, err, &detachErr)
+
+	require.NoError(t, client.Close())
+}
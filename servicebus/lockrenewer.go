@@ -0,0 +1,139 @@
+package servicebus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+// LockRenewerOptions contains the optional settings for configuring a
+// LockRenewer.
+type LockRenewerOptions struct {
+	// RenewBefore is how long before a lock expires it's renewed. Locks
+	// shorter than twice RenewBefore are renewed halfway to their expiry.
+	//
+	// Default: 10 seconds.
+	RenewBefore time.Duration
+
+	// MaxDuration is how long locks are renewed for at most, so a stuck
+	// handler doesn't hold a message or session forever.
+	//
+	// Default: 5 minutes.
+	MaxDuration time.Duration
+
+	// OnError is called with the error renewing a lock, after which the
+	// lock isn't renewed anymore and the context returned with it is
+	// canceled.
+	//
+	// Default: nil.
+	OnError func(err error)
+}
+
+// LockRenewer renews the locks of the messages and sessions being processed,
+// so slow handlers don't lose them. The lock of a message or session is
+// renewed until the cancel function returned with it is called, typically
+// once the message is settled or the session is closed.
+//
+//	ctx, cancel := renewer.RenewMessage(ctx, msg)
+//	err := process(ctx, msg)
+//	cancel()
+//
+// A LockRenewer is safe for concurrent use by multiple goroutines.
+type LockRenewer struct {
+	client      *Client
+	renewBefore time.Duration
+	maxDuration time.Duration
+	onError     func(error)
+}
+
+// NewLockRenewer creates a LockRenewer renewing locks with client, created
+// with the ClientOptions.AssociatedLinkName of the receiver for message locks.
+//
+// opts: pass nil to accept the default values.
+func NewLockRenewer(client *Client, opts *LockRenewerOptions) *LockRenewer {
+	r := &LockRenewer{
+		client:      client,
+		renewBefore: 10 * time.Second,
+		maxDuration: 5 * time.Minute,
+	}
+	if opts != nil {
+		if opts.RenewBefore > 0 {
+			r.renewBefore = opts.RenewBefore
+		}
+		if opts.MaxDuration > 0 {
+			r.maxDuration = opts.MaxDuration
+		}
+		r.onError = opts.OnError
+	}
+	return r
+}
+
+// RenewMessage renews the lock of msg, received on a link with
+// amqp.ReceiverSettleModeSecond, until cancel is called or ctx is done.
+// The returned context is canceled when the lock can't be renewed.
+// Messages without a lock token aren't renewed.
+func (r *LockRenewer) RenewMessage(ctx context.Context, msg *amqp.Message) (context.Context, context.CancelFunc) {
+	token, ok := LockToken(msg)
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	lockedUntil, _ := msg.Annotations[AnnotationLockedUntil].(time.Time)
+	return r.start(ctx, lockedUntil, func(ctx context.Context) (time.Time, error) {
+		expirations, err := r.client.RenewLocks(ctx, token)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if len(expirations) != 1 {
+			return time.Time{}, fmt.Errorf("servicebus: unexpected %d expirations", len(expirations))
+		}
+		return expirations[0], nil
+	})
+}
+
+// RenewSession renews the lock of the session with ID sessionID until
+// cancel is called or ctx is done. The lock is renewed right away, as the
+// time it expires isn't known yet. The returned context is canceled when
+// the lock can't be renewed.
+func (r *LockRenewer) RenewSession(ctx context.Context, sessionID string) (context.Context, context.CancelFunc) {
+	return r.start(ctx, time.Time{}, func(ctx context.Context) (time.Time, error) {
+		return r.client.RenewSessionLock(ctx, sessionID)
+	})
+}
+
+// start renews a lock expiring at lockedUntil with renew, in a goroutine
+// running until the returned context is done. A zero lockedUntil renews
+// the lock right away.
+func (r *LockRenewer) start(ctx context.Context, lockedUntil time.Time, renew func(context.Context) (time.Time, error)) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	deadline := time.Now().Add(r.maxDuration)
+	go func() {
+		for !lockedUntil.After(deadline) {
+			var wait time.Duration
+			if !lockedUntil.IsZero() {
+				remaining := time.Until(lockedUntil)
+				if wait = remaining - r.renewBefore; wait < remaining/2 {
+					wait = remaining / 2
+				}
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			var err error
+			if lockedUntil, err = renew(ctx); err != nil {
+				if ctx.Err() == nil && r.onError != nil {
+					r.onError(err)
+				}
+				cancel()
+				return
+			}
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,77 @@
+package servicebus
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockRenewer(t *testing.T) {
+	const lockDuration = 60 * time.Millisecond
+	var messageRenewals, sessionRenewals int32
+	session := serveTestManagement(t, func(req *amqp.Message) (int, any) {
+		body, _ := req.Value.(map[string]any)
+		switch req.ApplicationProperties["operation"] {
+		case operationRenewLock:
+			atomic.AddInt32(&messageRenewals, 1)
+			return http.StatusOK, map[string]any{"expirations": []time.Time{time.Now().Add(lockDuration)}}
+		case operationRenewSessionLock:
+			if body["session-id"] != "customer-42" {
+				return http.StatusGone, nil
+			}
+			atomic.AddInt32(&sessionRenewals, 1)
+			return http.StatusOK, map[string]any{"expiration": time.Now().Add(lockDuration)}
+		}
+		return http.StatusBadRequest, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := NewClient(ctx, session, "orders", nil)
+	require.NoError(t, err)
+
+	errs := make(chan error, 1)
+	renewer := NewLockRenewer(client, &LockRenewerOptions{
+		RenewBefore: 40 * time.Millisecond,
+		OnError:     func(err error) { errs <- err },
+	})
+
+	// message locks are renewed until canceled
+	msg := &amqp.Message{
+		DeliveryTag: make([]byte, 16),
+		Annotations: amqp.Annotations{AnnotationLockedUntil: time.Now().Add(lockDuration)},
+	}
+	msgCtx, stop := renewer.RenewMessage(ctx, msg)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&messageRenewals) >= 3 }, time.Second, 5*time.Millisecond)
+	stop()
+	require.Error(t, msgCtx.Err())
+	renewals := atomic.LoadInt32(&messageRenewals)
+	time.Sleep(2 * lockDuration)
+	require.Equal(t, renewals, atomic.LoadInt32(&messageRenewals))
+
+	// session locks are renewed right away
+	sessionCtx, stop := renewer.RenewSession(ctx, "customer-42")
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&sessionRenewals) >= 2 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, sessionCtx.Err())
+	stop()
+
+	// a lost lock cancels the context
+	lostCtx, stop := renewer.RenewSession(ctx, "unknown")
+	defer stop()
+	select {
+	case <-lostCtx.Done():
+	case <-ctx.Done():
+		t.Fatal("lost lock didn't cancel the context")
+	}
+	require.Error(t, <-errs)
+
+	// messages without a lock token aren't renewed
+	unlockedCtx, stop := renewer.RenewMessage(ctx, &amqp.Message{})
+	require.NoError(t, unlockedCtx.Err())
+	stop()
+	require.Equal(t, renewals, atomic.LoadInt32(&messageRenewals))
+}
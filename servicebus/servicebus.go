@@ -0,0 +1,336 @@
+// Package servicebus implements the operations of the management node of
+// Azure Service Bus entities that messages links can't perform: renewing
+// message locks, scheduling and canceling the scheduled enqueue of messages,
+// receiving deferred messages, and peeking at messages. It also provides the
+// filters attaching receivers to the sessions of session-enabled entities.
+//
+// Each queue or subscription has its own management node, at the entity's
+// path followed by "/$management".
+//
+//	client, err := servicebus.NewClient(ctx, session, "orders", nil)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer client.Close(ctx)
+//	seqs, err := client.ScheduleMessages(ctx, time.Now().Add(time.Hour), msg)
+package servicebus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/management"
+)
+
+// Operations of the Service Bus management node.
+const (
+	operationRenewLock              = "com.microsoft:renew-lock"
+	operationRenewSessionLock       = "com.microsoft:renew-session-lock"
+	operationScheduleMessage        = "com.microsoft:schedule-message"
+	operationCancelScheduledMessage = "com.microsoft:cancel-scheduled-message"
+	operationReceiveBySequenceNum   = "com.microsoft:receive-by-sequence-number"
+	operationPeekMessage            = "com.microsoft:peek-message"
+)
+
+// Message annotations set by Service Bus.
+const (
+	// AnnotationSequenceNumber is the sequence number assigned to a message by Service Bus.
+	AnnotationSequenceNumber = "x-opt-sequence-number"
+
+	// AnnotationScheduledEnqueueTime is the time a scheduled message is enqueued.
+	AnnotationScheduledEnqueueTime = "x-opt-scheduled-enqueue-time"
+
+	// AnnotationLockedUntil is the time the lock of a message expires.
+	AnnotationLockedUntil = "x-opt-locked-until"
+)
+
+// ClientOptions contains the optional settings for configuring a Client.
+type ClientOptions struct {
+	// AssociatedLinkName is the name of the receiver link the operations
+	// apply to. Service Bus requires it to renew the locks of messages
+	// received on that link.
+	//
+	// Default: empty.
+	AssociatedLinkName string
+
+	// Timeout is the maximum time an operation waits for its response, in
+	// addition to the deadline of the context passed to it.
+	//
+	// Default: 0 (no timeout).
+	Timeout time.Duration
+
+	// Rand is the source of randomness for the message IDs generated
+	// for scheduled messages that don't have one.
+	//
+	// Default: crypto/rand.Reader.
+	Rand io.Reader
+}
+
+// Client performs operations on the management node of a Service Bus entity.
+//
+// A Client is safe for concurrent use by multiple goroutines.
+type Client struct {
+	mgmt     *management.Client
+	linkName string
+	rand     io.Reader
+}
+
+// NewClient attaches the links of a Client to the management node
+// of the queue or subscription at entityPath on session.
+//
+// opts: pass nil to accept the default values.
+func NewClient(ctx context.Context, session *amqp.Session, entityPath string, opts *ClientOptions) (*Client, error) {
+	if opts == nil {
+		opts = &ClientOptions{}
+	}
+	mgmt, err := management.NewClient(ctx, session, &management.ClientOptions{
+		Address: entityPath + "/$management",
+		Timeout: opts.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Client{mgmt: mgmt, linkName: opts.AssociatedLinkName, rand: opts.Rand}, nil
+}
+
+// newUUID generates a message ID with the client's source of randomness.
+func (c *Client) newUUID() (amqp.UUID, error) {
+	if c.rand == nil {
+		return amqp.NewUUID()
+	}
+	return amqp.NewUUIDFromReader(c.rand)
+}
+
+// Close detaches the links of the client.
+func (c *Client) Close(ctx context.Context) error {
+	return c.mgmt.Close(ctx)
+}
+
+// RenewLocks renews the locks of the messages with the specified lock
+// tokens, and returns the times the renewed locks expire, in order.
+func (c *Client) RenewLocks(ctx context.Context, lockTokens ...amqp.UUID) ([]time.Time, error) {
+	body, err := c.do(ctx, operationRenewLock, map[string]any{
+		"lock-tokens": lockTokens,
+	})
+	if err != nil {
+		return nil, err
+	}
+	expirations, ok := body["expirations"].([]time.Time)
+	if !ok {
+		return nil, fmt.Errorf("servicebus: unexpected expirations %T", body["expirations"])
+	}
+	return expirations, nil
+}
+
+// ScheduleMessages schedules msgs to be enqueued at enqueueTime, and returns
+// their sequence numbers, in order, to cancel them with CancelScheduledMessages.
+//
+// Messages without a message-id are assigned a generated one. The caller's
+// messages are not modified.
+func (c *Client) ScheduleMessages(ctx context.Context, enqueueTime time.Time, msgs ...*amqp.Message) ([]int64, error) {
+	entries := make([]any, len(msgs))
+	for i, msg := range msgs {
+		scheduled := *msg
+		scheduled.Annotations = make(amqp.Annotations, len(msg.Annotations)+1)
+		for k, v := range msg.Annotations {
+			scheduled.Annotations[k] = v
+		}
+		scheduled.Annotations[AnnotationScheduledEnqueueTime] = enqueueTime
+
+		props := amqp.MessageProperties{}
+		if msg.Properties != nil {
+			props = *msg.Properties
+		}
+		if props.MessageID == nil {
+			id, err := c.newUUID()
+			if err != nil {
+				return nil, err
+			}
+			props.MessageID = id.String()
+		}
+		scheduled.Properties = &props
+
+		encoded, err := scheduled.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		entry := map[string]any{
+			"message-id": fmt.Sprint(props.MessageID),
+			"message":    encoded,
+		}
+		if props.GroupID != nil {
+			entry["session-id"] = *props.GroupID
+		}
+		if key, ok := msg.Annotations["x-opt-partition-key"].(string); ok {
+			entry["partition-key"] = key
+		}
+		entries[i] = entry
+	}
+
+	body, err := c.do(ctx, operationScheduleMessage, map[string]any{
+		"messages": entries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	seqs, ok := body["sequence-numbers"].([]int64)
+	if !ok {
+		return nil, fmt.Errorf("servicebus: unexpected sequence-numbers %T", body["sequence-numbers"])
+	}
+	return seqs, nil
+}
+
+// CancelScheduledMessages cancels the enqueue of the scheduled
+// messages with the specified sequence numbers.
+func (c *Client) CancelScheduledMessages(ctx context.Context, sequenceNumbers ...int64) error {
+	_, err := c.do(ctx, operationCancelScheduledMessage, map[string]any{
+		"sequence-numbers": sequenceNumbers,
+	})
+	return err
+}
+
+// DeferredMessage is a deferred message received by sequence number.
+type DeferredMessage struct {
+	// Message is the received message.
+	Message *amqp.Message
+
+	// LockToken is the lock token of the message.
+	// It's zero for messages received with ReceiverSettleModeFirst.
+	LockToken amqp.UUID
+}
+
+// ReceiveDeferred receives the deferred messages with the specified sequence
+// numbers. With amqp.ReceiverSettleModeFirst, the messages are removed from
+// the entity as they're received. With amqp.ReceiverSettleModeSecond, they're
+// locked, and must be settled with their lock token.
+func (c *Client) ReceiveDeferred(ctx context.Context, mode amqp.ReceiverSettleMode, sequenceNumbers ...int64) ([]DeferredMessage, error) {
+	body, err := c.do(ctx, operationReceiveBySequenceNum, map[string]any{
+		"sequence-numbers":     sequenceNumbers,
+		"receiver-settle-mode": uint32(mode),
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := messageEntries(body)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]DeferredMessage, len(entries))
+	for i, entry := range entries {
+		if msgs[i].Message, err = decodeMessage(entry); err != nil {
+			return nil, err
+		}
+		if token, ok := entry["lock-token"].(amqp.UUID); ok {
+			msgs[i].LockToken = token
+		}
+	}
+	return msgs, nil
+}
+
+// Peek returns up to count messages of the entity, starting at the message
+// with sequence number fromSequenceNumber, without locking or removing them.
+// It returns no messages if there are none left.
+func (c *Client) Peek(ctx context.Context, fromSequenceNumber int64, count int32) ([]*amqp.Message, error) {
+	body, err := c.do(ctx, operationPeekMessage, map[string]any{
+		"from-sequence-number": fromSequenceNumber,
+		"message-count":        count,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := messageEntries(body)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*amqp.Message, len(entries))
+	for i, entry := range entries {
+		if msgs[i], err = decodeMessage(entry); err != nil {
+			return nil, err
+		}
+	}
+	return msgs, nil
+}
+
+// LockToken returns the lock token of msg, received on a link with
+// amqp.ReceiverSettleModeSecond, from its delivery tag.
+func LockToken(msg *amqp.Message) (amqp.UUID, bool) {
+	var token amqp.UUID
+	if len(msg.DeliveryTag) != len(token) {
+		return token, false
+	}
+	copy(token[:], msg.DeliveryTag)
+	// delivery tags are in the byte order of .NET GUIDs,
+	// with the first three fields little-endian
+	token[0], token[1], token[2], token[3] = token[3], token[2], token[1], token[0]
+	token[4], token[5] = token[5], token[4]
+	token[6], token[7] = token[7], token[6]
+	return token, true
+}
+
+// SequenceNumber returns the sequence number assigned to msg by Service Bus.
+func SequenceNumber(msg *amqp.Message) (int64, bool) {
+	seq, ok := msg.Annotations[AnnotationSequenceNumber].(int64)
+	return seq, ok
+}
+
+// do performs operation with the specified request body, and returns
+// the body of the response. A response without a body, as sent with
+// status 204 when there are no messages to peek, returns a nil map.
+func (c *Client) do(ctx context.Context, operation string, body map[string]any) (map[string]any, error) {
+	req := &management.Request{
+		Operation: operation,
+		Body:      body,
+	}
+	if c.linkName != "" {
+		req.Properties = map[string]any{"associated-link-name": c.linkName}
+	}
+	resp, err := c.mgmt.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	switch v := resp.Message.Value.(type) {
+	case map[string]any:
+		return v, nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("servicebus: unexpected %s response body %T", operation, resp.Message.Value)
+}
+
+// messageEntries returns the entries of the "messages" list of body.
+func messageEntries(body map[string]any) ([]map[string]any, error) {
+	list, ok := body["messages"].([]any)
+	if !ok {
+		if body["messages"] == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("servicebus: unexpected messages %T", body["messages"])
+	}
+	entries := make([]map[string]any, len(list))
+	for i, v := range list {
+		if entries[i], ok = v.(map[string]any); !ok {
+			return nil, fmt.Errorf("servicebus: unexpected message entry %T", v)
+		}
+	}
+	return entries, nil
+}
+
+// decodeMessage decodes the encoded message of entry.
+func decodeMessage(entry map[string]any) (*amqp.Message, error) {
+	encoded, ok := entry["message"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("servicebus: unexpected message %T", entry["message"])
+	}
+	msg := &amqp.Message{}
+	if err := msg.UnmarshalBinary(encoded); err != nil {
+		return nil, fmt.Errorf("servicebus: decoding message: %w", err)
+	}
+	return msg, nil
+}
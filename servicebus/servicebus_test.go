@@ -0,0 +1,164 @@
+package servicebus
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/require"
+)
+
+// serveTestManagement serves a management node on a single connection,
+// answering requests with handle, and returns the session of a client
+// connected to it.
+func serveTestManagement(t *testing.T, handle func(req *amqp.Message) (int, any)) *amqp.Session {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := amqp.NewListener(ln, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	go func() {
+		conn, err := l.Accept(ctx)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sreq, err := conn.NextSession(ctx)
+		if err != nil {
+			return
+		}
+		session, err := sreq.Accept(nil)
+		if err != nil {
+			return
+		}
+
+		var requests *amqp.Receiver
+		var responses *amqp.Sender
+		for requests == nil || responses == nil {
+			req, err := session.NextLink(ctx)
+			if err != nil {
+				return
+			}
+			if req.Receiver {
+				if req.TargetAddress != "orders/$management" {
+					_ = req.Reject(nil)
+					continue
+				}
+				requests, err = req.AcceptReceiver(&amqp.ReceiverOptions{Credit: 10})
+			} else {
+				responses, err = req.AcceptSender(nil)
+			}
+			if err != nil {
+				return
+			}
+		}
+
+		for {
+			msg, err := requests.Receive(ctx)
+			if err != nil {
+				return
+			}
+			_ = requests.AcceptMessage(ctx, msg)
+			status, body := handle(msg)
+			resp := &amqp.Message{
+				Properties:            &amqp.MessageProperties{CorrelationID: msg.Properties.MessageID},
+				ApplicationProperties: map[string]any{"statusCode": int32(status)},
+				Value:                 body,
+			}
+			if err := responses.Send(ctx, resp); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn, err := amqp.Dial("amqp://"+l.Addr().String(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	return session
+}
+
+func TestScheduleAndPeek(t *testing.T) {
+	enqueueTime := time.Unix(1700000000, 0).UTC()
+	var scheduled []*amqp.Message
+	session := serveTestManagement(t, func(req *amqp.Message) (int, any) {
+		body, _ := req.Value.(map[string]any)
+		switch req.ApplicationProperties["operation"] {
+		case operationScheduleMessage:
+			var seqs []int64
+			for _, entry := range body["messages"].([]any) {
+				msg, err := decodeMessage(entry.(map[string]any))
+				if err != nil || !msg.Annotations[AnnotationScheduledEnqueueTime].(time.Time).Equal(enqueueTime) {
+					return http.StatusBadRequest, nil
+				}
+				msg.Annotations[AnnotationSequenceNumber] = int64(len(scheduled) + 1)
+				scheduled = append(scheduled, msg)
+				seqs = append(seqs, int64(len(scheduled)))
+			}
+			return http.StatusOK, map[string]any{"sequence-numbers": seqs}
+		case operationPeekMessage:
+			from := body["from-sequence-number"].(int64)
+			if from > int64(len(scheduled)) {
+				return http.StatusNoContent, nil
+			}
+			var entries []any
+			for _, msg := range scheduled[from-1:] {
+				encoded, _ := msg.MarshalBinary()
+				entries = append(entries, map[string]any{"message": encoded})
+			}
+			return http.StatusOK, map[string]any{"messages": entries}
+		}
+		return http.StatusNotImplemented, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	random := bytes.Repeat([]byte{0xab}, 32)
+	client, err := NewClient(ctx, session, "orders", &ClientOptions{Rand: bytes.NewReader(random)})
+	require.NoError(t, err)
+	defer client.Close(ctx)
+
+	first := amqp.NewMessage([]byte("first"))
+	seqs, err := client.ScheduleMessages(ctx, enqueueTime, first, amqp.NewMessage([]byte("second")))
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2}, seqs)
+	require.Nil(t, first.Annotations)
+	require.Nil(t, first.Properties)
+
+	msgs, err := client.Peek(ctx, 2, 10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "second", string(msgs[0].GetData()))
+	// the generated message ID is read from ClientOptions.Rand
+	id, err := amqp.NewUUIDFromReader(bytes.NewReader(random))
+	require.NoError(t, err)
+	require.Equal(t, id.String(), msgs[0].Properties.MessageID)
+	seq, ok := SequenceNumber(msgs[0])
+	require.True(t, ok)
+	require.EqualValues(t, 2, seq)
+
+	msgs, err = client.Peek(ctx, 3, 10)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+}
+
+func TestLockToken(t *testing.T) {
+	msg := &amqp.Message{DeliveryTag: []byte{
+		0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66,
+		0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff,
+	}}
+	token, ok := LockToken(msg)
+	require.True(t, ok)
+	require.Equal(t, "00112233-4455-6677-8899-aabbccddeeff", token.String())
+
+	_, ok = LockToken(&amqp.Message{})
+	require.False(t, ok)
+}
@@ -0,0 +1,58 @@
+package servicebus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+// The filter attaching a receiver to a session of a session-enabled entity.
+const (
+	sessionFilterName = "com.microsoft:session-filter"
+	sessionFilterCode = uint64(0x000001370000000C)
+)
+
+// SessionFilter returns the filter attaching a receiver to the session
+// with ID sessionID. The receiver gets an exclusive lock on the session.
+//
+//	rcv, err := session.NewReceiver(ctx, "orders", &amqp.ReceiverOptions{
+//		Filters: []amqp.LinkFilter{servicebus.SessionFilter("customer-42")},
+//	})
+func SessionFilter(sessionID string) amqp.LinkFilter {
+	return amqp.NewLinkFilter(sessionFilterName, sessionFilterCode, sessionID)
+}
+
+// NextSessionFilter returns the filter attaching a receiver to the next
+// available session, that isn't locked by another receiver. Use SessionID
+// to get the ID of the session granted once attached. If no session is
+// available, the attach fails once the entity's timeout elapses.
+func NextSessionFilter() amqp.LinkFilter {
+	return amqp.NewLinkFilter(sessionFilterName, sessionFilterCode, nil)
+}
+
+// SessionID returns the ID of the session rcv, attached with SessionFilter
+// or NextSessionFilter, was granted by Service Bus, and false if it wasn't
+// granted one.
+func SessionID(rcv *amqp.Receiver) (string, bool) {
+	id, ok := rcv.LinkSourceFilterValue(sessionFilterName).(string)
+	return id, ok
+}
+
+// RenewSessionLock renews the lock of the session with ID sessionID, held by
+// a receiver attached with SessionFilter or NextSessionFilter, and returns the
+// time the renewed lock expires.
+func (c *Client) RenewSessionLock(ctx context.Context, sessionID string) (time.Time, error) {
+	body, err := c.do(ctx, operationRenewSessionLock, map[string]any{
+		"session-id": sessionID,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	expiration, ok := body["expiration"].(time.Time)
+	if !ok {
+		return time.Time{}, fmt.Errorf("servicebus: unexpected expiration %T", body["expiration"])
+	}
+	return expiration, nil
+}
@@ -0,0 +1,74 @@
+package servicebus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionFilter(t *testing.T) {
+	var requested []any
+	netConn := mocks.NewNetConn(func(req mocks.FrameBody) ([]byte, error) {
+		switch tt := req.(type) {
+		case *mocks.AMQPProto:
+			return mocks.ProtoHeader(mocks.ProtoAMQP)
+		case *mocks.Open:
+			return mocks.PerformOpen("servicebus")
+		case *mocks.Begin:
+			return mocks.PerformBegin(0)
+		case *mocks.Attach:
+			filter := tt.Source.Filter[sessionFilterName]
+			// the descriptor is com.microsoft:0x0000000C
+			if filter == nil || filter.Descriptor != uint64(0x000001370000000C) {
+				return mocks.ReceiverAttach(0, tt.Name, 0, amqp.ReceiverSettleModeSecond, nil)
+			}
+			requested = append(requested, filter.Value)
+			// grant the requested session, or the next available one
+			granted := &mocks.DescribedType{Descriptor: sessionFilterCode, Value: "next"}
+			if filter.Value != nil {
+				granted.Value = filter.Value
+			}
+			return mocks.ReceiverAttach(0, tt.Name, 0, amqp.ReceiverSettleModeSecond, mocks.Filter{sessionFilterName: granted})
+		case *mocks.Detach:
+			return mocks.PerformDetach(0, 0, nil)
+		case *mocks.Close:
+			return mocks.PerformClose(nil)
+		default:
+			return nil, nil
+		}
+	})
+	conn, err := amqp.NewConn(netConn, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+
+	rcv, err := session.NewReceiver(ctx, "orders", &amqp.ReceiverOptions{
+		Filters: []amqp.LinkFilter{SessionFilter("customer-42")},
+	})
+	require.NoError(t, err)
+	id, ok := SessionID(rcv)
+	require.True(t, ok)
+	require.Equal(t, "customer-42", id)
+
+	rcv, err = session.NewReceiver(ctx, "orders", &amqp.ReceiverOptions{
+		Filters: []amqp.LinkFilter{NextSessionFilter()},
+	})
+	require.NoError(t, err)
+	id, ok = SessionID(rcv)
+	require.True(t, ok)
+	require.Equal(t, "next", id)
+	require.Equal(t, []any{"customer-42", nil}, requested)
+
+	rcv, err = session.NewReceiver(ctx, "orders", nil)
+	require.NoError(t, err)
+	_, ok = SessionID(rcv)
+	require.False(t, ok)
+}
@@ -35,6 +35,13 @@ type SessionOptions struct {
 	// Minimum: 1.
 	// Default: 4294967295.
 	MaxLinks uint32
+
+	// ResourceLimits caps the unsettled deliveries and buffered bytes
+	// of the session's links. They're also counted against the
+	// connection's limits, see ConnOptions.ResourceLimits.
+	//
+	// Default: no limits.
+	ResourceLimits *ResourceLimits
 }
 
 // Session is an AMQP session.
@@ -57,11 +64,18 @@ type Session struct {
 
 	nextDeliveryID uint32 // atomically accessed sequence for deliveryIDs
 
+	budget   *resourceBudget // resources held by links, nil if unlimited
+	activity *activity       // the mux's, nil when the watchdog is disabled
+
 	// link management
 	linksMu    sync.RWMutex      // used to synchronize link handle allocation
 	linksByKey map[linkKey]*link // mapping of name+role link
 	handles    *bitmap.Bitmap    // allocated handles
 
+	debugReq chan chan string  // services Conn.DebugDump
+	linkReqs chan *LinkRequest // links attached by the peer, nil unless server-side
+	endError *Error            // error sent in the end performative, set before close is closed
+
 	// used for gracefully closing link
 	close     chan struct{}
 	closeOnce sync.Once
@@ -81,9 +95,17 @@ func newSession(c *Conn, channel uint16, opts *SessionOptions) *Session {
 		handleMax:      math.MaxUint32,
 		linksMu:        sync.RWMutex{},
 		linksByKey:     make(map[linkKey]*link),
+		debugReq:       make(chan chan string),
 		close:          make(chan struct{}),
 		done:           make(chan struct{}),
 	}
+	if c != nil && c.sessionReqs != nil {
+		s.linkReqs = make(chan *LinkRequest)
+	}
+	if c != nil {
+		s.budget = c.budget
+	}
+	s.activity = newActivity(c)
 
 	if opts != nil {
 		if opts.IncomingWindow != 0 {
@@ -98,6 +120,7 @@ func newSession(c *Conn, channel uint16, opts *SessionOptions) *Session {
 		if opts.OutgoingWindow != 0 {
 			s.outgoingWindow = opts.OutgoingWindow
 		}
+		s.budget = newResourceBudget(opts.ResourceLimits, s.budget)
 	}
 	// create handle map after options have been applied
 	s.handles = bitmap.New(s.handleMax)
@@ -106,12 +129,7 @@ func newSession(c *Conn, channel uint16, opts *SessionOptions) *Session {
 
 func (s *Session) begin(ctx context.Context) error {
 	// send Begin to server
-	begin := &frames.PerformBegin{
-		NextOutgoingID: 0,
-		IncomingWindow: s.incomingWindow,
-		OutgoingWindow: s.outgoingWindow,
-		HandleMax:      s.handleMax,
-	}
+	begin := s.beginFrame()
 	debug.Log(1, "TX (NewSession): %s", begin)
 
 	_ = s.txFrame(begin, nil)
@@ -125,10 +143,12 @@ func (s *Session) begin(ctx context.Context) error {
 		// the ack.
 		go func() {
 			_ = s.txFrame(&frames.PerformEnd{}, nil)
+			timeout := s.conn.clock.NewTimer(5 * time.Second)
+			defer timeout.Stop()
 			select {
 			case <-s.conn.done:
 				// conn has terminated, no need to delete the session
-			case <-time.After(5 * time.Second):
+			case <-timeout.C():
 				// don't delete the session in this case. this is to avoid recylcing
 				// a channel number for a session that might not have terminated
 				debug.Log(3, "session.begin clean-up timed out waiting for PerformEnd ack")
@@ -157,17 +177,31 @@ func (s *Session) begin(ctx context.Context) error {
 		return fmt.Errorf("unexpected begin response: %+v", fr.Body)
 	}
 
+	s.conn.logger.Debug("session begun", "channel", s.channel, "remoteChannel", s.remoteChannel)
+
 	// start Session multiplexor
 	go s.mux(begin)
 
 	return nil
 }
 
+// beginFrame returns the begin performative announcing our session settings.
+func (s *Session) beginFrame() *frames.PerformBegin {
+	return &frames.PerformBegin{
+		NextOutgoingID: 0,
+		IncomingWindow: s.incomingWindow,
+		OutgoingWindow: s.outgoingWindow,
+		HandleMax:      s.handleMax,
+	}
+}
+
 // Close gracefully closes the session.
 //
 // If ctx expires while waiting for servers response, ctx.Err() will be returned.
 // The session will continue to wait for the response until the Client is closed.
-func (s *Session) Close(ctx context.Context) error {
+func (s *Session) Close(ctx context.Context) (err error) {
+	defer func() { err = s.conn.translateErr(err) }()
+
 	s.closeOnce.Do(func() { close(s.close) })
 	select {
 	case <-s.done:
@@ -195,25 +229,27 @@ func (s *Session) txFrame(p frames.FrameBody, done chan encoding.DeliveryState)
 
 // NewReceiver opens a new receiver link on the session.
 // opts: pass nil to accept the default values.
-func (s *Session) NewReceiver(ctx context.Context, source string, opts *ReceiverOptions) (*Receiver, error) {
+func (s *Session) NewReceiver(ctx context.Context, source string, opts *ReceiverOptions) (_ *Receiver, err error) {
+	defer func() { err = s.conn.translateErr(err) }()
+
 	r, err := newReceiver(source, s, opts)
 	if err != nil {
 		return nil, err
 	}
-	if err = r.attach(ctx); err != nil {
-		return nil, err
+	followRedirects := opts != nil && opts.FollowRedirects
+	open := func(ctx context.Context) error {
+		if err := r.l.attachFollowingRedirects(ctx, followRedirects, r.attach, func(addr string) { r.l.source.Address = addr }); err != nil {
+			return err
+		}
+		r.startBatching()
+		return nil
 	}
-
-	// batching is just extra overhead when maxCredits == 1
-	if r.maxCredit == 1 {
-		r.batching = false
+	if opts != nil && opts.LazyAttach {
+		r.l.lazy = newLazyAttach(open)
+		return r, nil
 	}
-
-	// create dispositions channel and start dispositionBatcher if batching enabled
-	if r.batching {
-		// buffer dispositions chan to prevent disposition sends from blocking
-		r.dispositions = make(chan messageDisposition, r.maxCredit)
-		go r.dispositionBatcher()
+	if err = open(ctx); err != nil {
+		return nil, err
 	}
 
 	return r, nil
@@ -221,12 +257,22 @@ func (s *Session) NewReceiver(ctx context.Context, source string, opts *Receiver
 
 // NewSender opens a new sender link on the session.
 // opts: pass nil to accept the default values.
-func (s *Session) NewSender(ctx context.Context, target string, opts *SenderOptions) (*Sender, error) {
+func (s *Session) NewSender(ctx context.Context, target string, opts *SenderOptions) (_ *Sender, err error) {
+	defer func() { err = s.conn.translateErr(err) }()
+
 	l, err := newSender(target, s, opts)
 	if err != nil {
 		return nil, err
 	}
-	if err = l.attach(ctx); err != nil {
+	followRedirects := opts != nil && opts.FollowRedirects
+	open := func(ctx context.Context) error {
+		return l.l.attachFollowingRedirects(ctx, followRedirects, l.attach, func(addr string) { l.l.target.Address = addr })
+	}
+	if opts != nil && opts.LazyAttach {
+		l.l.lazy = newLazyAttach(open)
+		return l, nil
+	}
+	if err = open(ctx); err != nil {
 		return nil, err
 	}
 
@@ -247,18 +293,27 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 				s.err = &SessionError{inner: s.err}
 			}
 		}
+		var sessionErr *SessionError
+		if errors.As(s.err, &sessionErr) && sessionErr.RemoteErr == nil && sessionErr.inner == nil {
+			s.conn.logger.Debug("session ended", "channel", s.channel)
+		} else {
+			s.conn.logger.Warn("session ended with error", "channel", s.channel, "error", s.err)
+		}
 		// Signal goroutines waiting on the session.
 		close(s.done)
 	}()
 
 	var (
-		links                     = make(map[uint32]*link)  // mapping of remote handles to links
+		links                     handleTable[*link]        // mapping of remote handles to links
 		handlesByDeliveryID       = make(map[uint32]uint32) // mapping of deliveryIDs to handles
-		deliveryIDByHandle        = make(map[uint32]uint32) // mapping of handles to latest deliveryID
+		deliveryIDByHandle        handleTable[uint32]       // mapping of handles to latest deliveryID
 		handlesByRemoteDeliveryID = make(map[uint32]uint32) // mapping of remote deliveryID to handles
 
 		settlementByDeliveryID = make(map[uint32]chan encoding.DeliveryState)
 
+		// flows received before their link's attach, see Profile.FlowBeforeAttach
+		earlyFlows map[uint32]*frames.PerformFlow
+
 		// flow control values
 		nextOutgoingID       uint32
 		nextIncomingID       = remoteBegin.NextOutgoingID
@@ -267,6 +322,7 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 	)
 
 	for {
+		s.activity.end()
 		txTransfer := s.txTransfer
 		// disable txTransfer if flow control windows have been exceeded
 		if remoteIncomingWindow == 0 || s.outgoingWindow == 0 {
@@ -282,9 +338,13 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 			s.err = s.conn.doneErr
 			return
 
+		case req := <-s.debugReq:
+			req <- fmt.Sprintf("remoteChannel=%d nextIncomingID=%d nextOutgoingID=%d incomingWindow=%d outgoingWindow=%d remoteIncomingWindow=%d remoteOutgoingWindow=%d links=%d unsettledDeliveries=%d",
+				s.remoteChannel, nextIncomingID, nextOutgoingID, s.incomingWindow, s.outgoingWindow, remoteIncomingWindow, remoteOutgoingWindow, links.len(), len(settlementByDeliveryID))
+
 		// session is being closed by user
 		case <-s.close:
-			_ = s.txFrame(&frames.PerformEnd{}, nil)
+			_ = s.txFrame(&frames.PerformEnd{Error: s.endError}, nil)
 
 			// wait for the ack that the session is closed.
 			// we can't exit the mux, which deletes the session,
@@ -306,6 +366,7 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 
 		// incoming frame for link
 		case fr := <-s.rx:
+			s.activity.begin()
 			debug.Log(1, "RX(Session): %s", fr.Body)
 
 			switch body := fr.Body.(type) {
@@ -343,7 +404,7 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 						}
 					}
 
-					link, ok := links[handle]
+					link, ok := links.get(handle)
 					if !ok {
 						continue
 					}
@@ -356,14 +417,17 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 					// This is a protocol error:
 					//       "[...] MUST be set if the peer has received
 					//        the begin frame for the session"
-					_ = s.txFrame(&frames.PerformEnd{
-						Error: &Error{
-							Condition:   ErrCondNotAllowed,
-							Description: "next-incoming-id not set after session established",
-						},
-					}, nil)
-					s.err = errors.New("protocol error: received flow without next-incoming-id after session established")
-					return
+					fe := newFrameError(fr.Channel, fr.Body, nil, errors.New("received flow without next-incoming-id after session established"))
+					if !s.conn.tolerate(fe) {
+						_ = s.txFrame(&frames.PerformEnd{
+							Error: &Error{
+								Condition:   ErrCondNotAllowed,
+								Description: "next-incoming-id not set after session established",
+							},
+						}, nil)
+						s.err = fe
+						return
+					}
 				}
 
 				// "When the endpoint receives a flow frame from its peer,
@@ -382,13 +446,21 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 				//
 				// initial-outgoing-id(endpoint) + incoming-window(flow) - next-outgoing-id(endpoint)"
 				remoteIncomingWindow = body.IncomingWindow - nextOutgoingID
-				remoteIncomingWindow += *body.NextIncomingID
+				if body.NextIncomingID != nil {
+					remoteIncomingWindow += *body.NextIncomingID
+				}
 				debug.Log(3, "RX(Session) Flow - remoteOutgoingWindow: %d remoteIncomingWindow: %d nextOutgoingID: %d", remoteOutgoingWindow, remoteIncomingWindow, nextOutgoingID)
 
 				// Send to link if handle is set
 				if body.Handle != nil {
-					link, ok := links[*body.Handle]
+					link, ok := links.get(*body.Handle)
 					if !ok {
+						if s.conn.profile.FlowBeforeAttach {
+							if earlyFlows == nil {
+								earlyFlows = make(map[uint32]*frames.PerformFlow)
+							}
+							earlyFlows[*body.Handle] = body
+						}
 						continue
 					}
 
@@ -417,16 +489,39 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 				s.linksMu.RLock()
 				link, linkOk := s.linksByKey[linkKey{name: body.Name, role: !body.Role}]
 				s.linksMu.RUnlock()
+				if !linkOk && s.linkReqs != nil {
+					// the peer is attaching a link on a server-side session
+					link, ok := s.muxRequestLink(body)
+					if !ok {
+						s.err = s.conn.doneErr
+						return
+					}
+					if link != nil {
+						link.unlinked = make(chan struct{})
+						links.set(link.remoteHandle, link)
+					}
+					continue
+				}
 				if !linkOk {
-					s.err = fmt.Errorf("protocol error: received mismatched attach frame %+v", body)
+					fe := newFrameError(fr.Channel, fr.Body, nil, fmt.Errorf("received mismatched attach frame for link %q", body.Name))
+					if s.conn.tolerate(fe) {
+						continue
+					}
+					s.err = fe
 					return
 				}
 
 				link.remoteHandle = body.Handle
-				links[link.remoteHandle] = link
+				link.unlinked = make(chan struct{})
+				links.set(link.remoteHandle, link)
 
 				s.muxFrameToLink(link, fr.Body)
 
+				if flow, ok := earlyFlows[body.Handle]; ok {
+					delete(earlyFlows, body.Handle)
+					s.muxFrameToLink(link, flow)
+				}
+
 			case *frames.PerformTransfer:
 				s.needFlowCount++
 				// "Upon receiving a transfer, the receiving endpoint will
@@ -439,7 +534,7 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 				if remoteOutgoingWindow > 0 {
 					remoteOutgoingWindow--
 				}
-				link, ok := links[body.Handle]
+				link, ok := links.get(body.Handle)
 				if !ok {
 					// TODO: per section 2.8.17 I think this should return an error
 					continue
@@ -472,7 +567,7 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 				}
 
 			case *frames.PerformDetach:
-				link, ok := links[body.Handle]
+				link, ok := links.get(body.Handle)
 				if !ok {
 					// TODO: per section 2.8.17 I think this should return an error
 					continue
@@ -484,8 +579,18 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 				// detach or our peer detached us. either way, now that
 				// the link has processed the frame it's detached so we
 				// are safe to clean up its state.
-				delete(links, link.remoteHandle)
-				delete(deliveryIDByHandle, link.handle)
+				links.delete(link.remoteHandle)
+				deliveryIDByHandle.delete(link.handle)
+
+				select {
+				case <-link.detached:
+					// the link stopped waiting for this detach, its handle can now be reused
+					if link.orphaned {
+						s.deallocateHandle(link)
+					}
+				default:
+				}
+				close(link.unlinked)
 
 			case *frames.PerformEnd:
 				_ = s.txFrame(&frames.PerformEnd{}, nil)
@@ -495,17 +600,28 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 				return
 
 			default:
-				// TODO: evaluate
 				debug.Log(1, "session mux: unexpected frame: %s\n", body)
+				if s.conn.protocolMode == ProtocolModeStrict {
+					_ = s.txFrame(&frames.PerformEnd{
+						Error: &Error{
+							Condition:   ErrCondNotAllowed,
+							Description: fmt.Sprintf("unexpected %s frame", performativeName(body)),
+						},
+					}, nil)
+					s.err = newFrameError(fr.Channel, fr.Body, nil, errors.New("unexpected frame"))
+					return
+				}
+				s.conn.logger.Warn("session received unexpected frame", "channel", s.channel, "frame", body)
 			}
 
 		case fr := <-txTransfer:
+			s.activity.begin()
 
 			// record current delivery ID
 			var deliveryID uint32
 			if fr.DeliveryID != nil {
 				deliveryID = *fr.DeliveryID
-				deliveryIDByHandle[fr.Handle] = deliveryID
+				deliveryIDByHandle.set(fr.Handle, deliveryID)
 
 				// add to handleByDeliveryID if not sender-settled
 				if !fr.Settled {
@@ -514,7 +630,7 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 			} else {
 				// if fr.DeliveryID is nil it must have been added
 				// to deliveryIDByHandle already
-				deliveryID = deliveryIDByHandle[fr.Handle]
+				deliveryID, _ = deliveryIDByHandle.get(fr.Handle)
 			}
 
 			// frame has been sender-settled, remove from map
@@ -542,6 +658,7 @@ func (s *Session) mux(remoteBegin *frames.PerformBegin) {
 			}
 
 		case fr := <-s.tx:
+			s.activity.begin()
 			switch fr := fr.(type) {
 			case *frames.PerformFlow:
 				niID := nextIncomingID
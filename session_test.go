@@ -8,7 +8,7 @@ import (
 
 	"github.com/Azure/go-amqp/internal/encoding"
 	"github.com/Azure/go-amqp/internal/frames"
-	"github.com/Azure/go-amqp/internal/mocks"
+	"github.com/Azure/go-amqp/mocks"
 	"github.com/stretchr/testify/require"
 )
 
@@ -87,7 +87,7 @@ func TestSessionServerClose(t *testing.T) {
 	cancel()
 	require.NoError(t, err)
 	// initiate server-side closing of session
-	fr, err := mocks.PerformEnd(0, &encoding.Error{Condition: "closing", Description: "server side close"})
+	fr, err := mocks.PerformEnd(0, &encoding.Error{Condition: "closing", Description: "server side close", Info: map[string]any{"retry-after": int64(5)}})
 	require.NoError(t, err)
 	netConn.SendFrame(fr)
 	// wait a bit for connReader to read from the mock
@@ -101,6 +101,9 @@ func TestSessionServerClose(t *testing.T) {
 	require.NotNil(t, sessionErr.RemoteErr)
 	require.Equal(t, ErrCond("closing"), sessionErr.RemoteErr.Condition)
 	require.Equal(t, "server side close", sessionErr.RemoteErr.Description)
+	info, ok := ErrorInfo(err)
+	require.True(t, ok)
+	require.Equal(t, map[string]any{"retry-after": int64(5)}, info)
 	require.NoError(t, client.Close())
 }
 
@@ -501,6 +504,75 @@ func TestSessionInvalidFlowFrame(t *testing.T) {
 	require.NoError(t, client.Close())
 }
 
+func TestSessionProtocolModeLenient(t *testing.T) {
+	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
+
+	client, err := NewConn(netConn, &ConnOptions{ProtocolMode: ProtocolModeLenient})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+
+	// NextIncomingID cannot be nil once the session has been established
+	b, err := mocks.EncodeFrame(mocks.FrameAMQP, 0, &frames.PerformFlow{})
+	require.NoError(t, err)
+	netConn.SendFrame(b)
+
+	// there's no session on channel 5
+	b, err = mocks.EncodeFrame(mocks.FrameAMQP, 5, &frames.PerformFlow{})
+	require.NoError(t, err)
+	netConn.SendFrame(b)
+
+	require.Eventually(t, func() bool {
+		return client.Stats().ProtocolViolations == 2
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	snd, err := session.NewSender(ctx, "target", nil)
+	cancel()
+	require.NoError(t, err)
+	require.NotNil(t, snd)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1*time.Second)
+	err = session.Close(ctx)
+	cancel()
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+}
+
+func TestSessionProtocolModeStrict(t *testing.T) {
+	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
+
+	client, err := NewConn(netConn, &ConnOptions{ProtocolMode: ProtocolModeStrict})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+
+	// unexpected frames end the session
+	b, err := mocks.EncodeFrame(mocks.FrameSASL, 0, &frames.SASLMechanisms{})
+	require.NoError(t, err)
+	netConn.SendFrame(b)
+
+	select {
+	case <-session.done:
+	case <-time.After(time.Second):
+		t.Fatal("session didn't end")
+	}
+	var frameErr *FrameError
+	require.ErrorAs(t, session.err, &frameErr)
+	require.NoError(t, client.Close())
+}
+
+func TestConnProtocolModeInvalid(t *testing.T) {
+	_, err := NewConn(mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)), &ConnOptions{ProtocolMode: ProtocolModeLenient + 1})
+	require.ErrorContains(t, err, "invalid ProtocolMode")
+}
+
 func TestSessionFlowFrameWithEcho(t *testing.T) {
 	nextIncomingID := uint32(1)
 	const nextOutgoingID = 2
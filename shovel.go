@@ -0,0 +1,223 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ShovelOptions contains the optional settings for configuring a Shovel.
+type ShovelOptions struct {
+	// Transform is called on each message received from the source, and
+	// returns the message to send to the target. Returning a nil message
+	// discards it, and it's accepted by the source. Returning an error
+	// rejects it, with the error itself if it's an *Error, or with
+	// amqp:internal-error otherwise.
+	//
+	// Default: nil, messages are forwarded unchanged.
+	Transform func(ctx context.Context, msg *Message) (*Message, error)
+
+	// RetryPolicy determines if, and when, failed links are recreated.
+	// Consecutive failures are counted from the last message forwarded.
+	//
+	// Default: ExponentialBackoff(nil).
+	RetryPolicy RetryPolicy
+}
+
+// Shovel forwards the messages received on a source receiver to a target
+// sender, which can be on different connections.
+//
+// Messages are settled on the source with the outcome reported by the
+// target, so they're only accepted by the source once accepted by the
+// target. Messages that can't be sent because the target failed are
+// released to be redelivered.
+//
+// The links are attached by the source and target functions, which are
+// called again when their link fails with a retryable error. They're
+// responsible for dialing a new connection when the error requires it, as
+// classified by ClassifyError.
+type Shovel struct {
+	source    func(ctx context.Context) (*Receiver, error)
+	target    func(ctx context.Context) (*Sender, error)
+	transform func(ctx context.Context, msg *Message) (*Message, error)
+	policy    RetryPolicy
+
+	rcv *Receiver // nil until attached, or after failing
+	snd *Sender   // nil until attached, or after failing
+}
+
+// NewShovel creates a Shovel forwarding the messages received on the
+// receiver returned by source to the sender returned by target.
+//
+// opts: pass nil to accept the default values.
+func NewShovel(source func(ctx context.Context) (*Receiver, error), target func(ctx context.Context) (*Sender, error), opts *ShovelOptions) *Shovel {
+	s := &Shovel{
+		source: source,
+		target: target,
+	}
+	if opts != nil {
+		s.transform = opts.Transform
+		s.policy = opts.RetryPolicy
+	}
+	if s.policy == nil {
+		s.policy = ExponentialBackoff(nil)
+	}
+	return s
+}
+
+// Run forwards messages until ctx is canceled, or a link fails with an error
+// that isn't retryable or for which the retry policy stops retrying, and
+// returns the corresponding error. The links are detached when it returns.
+func (s *Shovel) Run(ctx context.Context) error {
+	defer s.closeLinks()
+	for attempt := 1; ; attempt++ {
+		forwarded, err := s.pump(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if forwarded {
+			attempt = 1
+		}
+		if !IsRetryable(err) {
+			return err
+		}
+		delay, ok := s.policy.Delay(attempt, err)
+		if !ok {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// pump attaches the links that aren't attached, and forwards messages
+// until one of them fails. It returns true if any message was forwarded.
+func (s *Shovel) pump(ctx context.Context) (bool, error) {
+	var err error
+	if s.rcv == nil {
+		if s.rcv, err = s.source(ctx); err != nil {
+			return false, err
+		}
+	}
+	if s.snd == nil {
+		if s.snd, err = s.target(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	forwarded := false
+	for {
+		msg, err := s.rcv.Receive(ctx)
+		if err != nil {
+			s.closeReceiver()
+			return forwarded, err
+		}
+		if err := s.forward(ctx, msg); err != nil {
+			return forwarded, err
+		}
+		forwarded = true
+	}
+}
+
+// forward sends msg to the target and settles it on the source with the
+// outcome. It returns an error if the message couldn't be sent, or settled.
+func (s *Shovel) forward(ctx context.Context, msg *Message) error {
+	out := forwardedMessage(msg)
+	if s.transform != nil {
+		var err error
+		if out, err = s.transform(ctx, out); err != nil {
+			return s.settled(s.rcv.RejectMessage(ctx, msg, rejectionError(err)))
+		}
+		if out == nil {
+			return s.settled(s.rcv.AcceptMessage(ctx, msg))
+		}
+	}
+
+	outcome, err := s.snd.SendWithOutcome(ctx, out)
+	if err != nil {
+		var detachErr *DetachError
+		var sessionErr *SessionError
+		var connErr *ConnError
+		if ctx.Err() == nil && !errors.As(err, &detachErr) && !errors.As(err, &sessionErr) && !errors.As(err, &connErr) {
+			// the message can't be sent, but the sender is still attached
+			return s.settled(s.rcv.RejectMessage(ctx, msg, rejectionError(err)))
+		}
+		_ = s.rcv.ReleaseMessage(context.Background(), msg)
+		s.closeSender()
+		return err
+	}
+	switch outcome.Type {
+	case OutcomeRejected:
+		err = s.rcv.RejectMessage(ctx, msg, outcome.Error)
+	case OutcomeReleased:
+		err = s.rcv.ReleaseMessage(ctx, msg)
+	case OutcomeModified:
+		err = s.rcv.ModifyMessage(ctx, msg, &ModifyMessageOptions{
+			DeliveryFailed:    outcome.DeliveryFailed,
+			UndeliverableHere: outcome.UndeliverableHere,
+			Annotations:       outcome.Annotations,
+		})
+	default:
+		err = s.rcv.AcceptMessage(ctx, msg)
+	}
+	return s.settled(err)
+}
+
+// settled returns err, the error settling a message on the source,
+// after closing the receiver if it failed.
+func (s *Shovel) settled(err error) error {
+	if err != nil {
+		s.closeReceiver()
+	}
+	return err
+}
+
+// closeLinks detaches the links that are attached.
+func (s *Shovel) closeLinks() {
+	if s.rcv != nil {
+		s.closeReceiver()
+	}
+	if s.snd != nil {
+		s.closeSender()
+	}
+}
+
+func (s *Shovel) closeReceiver() {
+	ctx, cancel := context.WithTimeout(context.Background(), shovelDetachTimeout)
+	defer cancel()
+	_ = s.rcv.Close(ctx)
+	s.rcv = nil
+}
+
+func (s *Shovel) closeSender() {
+	ctx, cancel := context.WithTimeout(context.Background(), shovelDetachTimeout)
+	defer cancel()
+	_ = s.snd.Close(ctx)
+	s.snd = nil
+}
+
+// shovelDetachTimeout is how long a Shovel waits for the peer to
+// acknowledge the detach of a failed link.
+const shovelDetachTimeout = 5 * time.Second
+
+// forwardedMessage returns a copy of the bare message and annotations of msg,
+// without its delivery details, to be sent on another link.
+func forwardedMessage(msg *Message) *Message {
+	return &Message{
+		Format:                msg.Format,
+		Header:                msg.Header,
+		Annotations:           msg.Annotations,
+		Properties:            msg.Properties,
+		ApplicationProperties: msg.ApplicationProperties,
+		Data:                  msg.Data,
+		Value:                 msg.Value,
+		Sequence:              msg.Sequence,
+		Footer:                msg.Footer,
+		RawPayload:            msg.RawPayload,
+	}
+}
@@ -0,0 +1,89 @@
+package amqp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShovel(t *testing.T) {
+	// the first message sent to out is rejected
+	b := broker.New(&broker.Options{
+		AutoCreateQueues: true,
+		FaultHook: broker.NewScript(broker.FaultRule{
+			Point:   broker.FaultPointEnqueue,
+			Address: "out",
+			Count:   1,
+			Fault:   broker.Fault{Error: &amqp.Error{Condition: amqp.ErrCondResourceLimitExceeded}},
+		}).Hook,
+	})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+	in, err := b.DeclareQueue("in")
+	require.NoError(t, err)
+	out, err := b.DeclareQueue("out")
+	require.NoError(t, err)
+
+	newSession := func(ctx context.Context) (*amqp.Session, error) {
+		conn, err := amqp.Dial(srv.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn.NewSession(ctx, nil)
+	}
+	shovel := amqp.NewShovel(func(ctx context.Context) (*amqp.Receiver, error) {
+		session, err := newSession(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return session.NewReceiver(ctx, "in", nil)
+	}, func(ctx context.Context) (*amqp.Sender, error) {
+		session, err := newSession(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return session.NewSender(ctx, "out", nil)
+	}, &amqp.ShovelOptions{
+		Transform: func(ctx context.Context, msg *amqp.Message) (*amqp.Message, error) {
+			if string(msg.GetData()) == "discarded" {
+				return nil, nil
+			}
+			msg.ApplicationProperties = map[string]any{"shoveled": true}
+			return msg, nil
+		},
+	})
+
+	for _, body := range []string{"first", "discarded", "second"} {
+		require.NoError(t, in.Enqueue(amqp.NewMessage([]byte(body))))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ran := make(chan error, 1)
+	go func() { ran <- shovel.Run(ctx) }()
+
+	conn, err := amqp.Dial(srv.URL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	rcv, err := session.NewReceiver(ctx, "out", nil)
+	require.NoError(t, err)
+	msg, err := rcv.Receive(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "second", string(msg.GetData()))
+	require.Equal(t, true, msg.ApplicationProperties["shoveled"])
+	require.NoError(t, rcv.AcceptMessage(ctx, msg))
+
+	// first was rejected by the source too, and discarded accepted
+	require.Zero(t, in.Len())
+	require.Zero(t, out.Len())
+
+	cancel()
+	require.ErrorIs(t, <-ran, context.Canceled)
+}
@@ -0,0 +1,63 @@
+package amqp
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/go-amqp/internal/clock"
+)
+
+// stallTimer detects a condition that persists beyond a threshold.
+// It's owned by a single mux goroutine.
+type stallTimer struct {
+	clock     clock.Clock
+	threshold time.Duration
+	timer     clock.Timer
+	fired     bool
+}
+
+// update arms the timer when stalled becomes true and disarms it when
+// stalled becomes false. It returns the channel to wait on, which is nil
+// when the timer is disabled, disarmed, or has already fired for the
+// current stall.
+func (t *stallTimer) update(stalled bool) <-chan time.Time {
+	switch {
+	case t.threshold <= 0:
+		return nil
+	case !stalled:
+		t.stop()
+		t.fired = false
+		return nil
+	case t.fired:
+		return nil
+	case t.timer == nil:
+		t.timer = t.clock.NewTimer(t.threshold)
+	}
+	return t.timer.C()
+}
+
+// fire records that the timer fired for the current stall.
+func (t *stallTimer) fire() {
+	t.timer = nil
+	t.fired = true
+}
+
+func (t *stallTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// reportStall emits typ for the link and increments the corresponding counter.
+func (l *link) reportStall(typ LinkEventType) {
+	if l.session != nil && l.session.conn != nil {
+		switch typ {
+		case LinkEventCreditStarved:
+			atomic.AddUint64(&l.session.conn.stats.creditStarvations, 1)
+		case LinkEventSlowConsumer:
+			atomic.AddUint64(&l.session.conn.stats.slowConsumers, 1)
+		}
+	}
+	l.emitEvent(typ, nil)
+}
@@ -0,0 +1,167 @@
+package amqp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ConnStats contains statistics and negotiated parameters for a Conn.
+// See Conn.Stats.
+type ConnStats struct {
+	// BytesRead and BytesWritten are the number of bytes
+	// read from and written to the network.
+	BytesRead    uint64
+	BytesWritten uint64
+
+	// FramesRead and FramesWritten are the number of frames,
+	// including empty keep-alive frames, received and sent.
+	FramesRead    uint64
+	FramesWritten uint64
+
+	// ReadQueued is the number of frames read from the network
+	// waiting to be processed by sessions, out of ReadQueueDepth.
+	ReadQueued     int
+	ReadQueueDepth int
+
+	// ReadPauses is the number of times reading from the network
+	// paused because ReadQueueDepth frames were waiting.
+	ReadPauses uint64
+
+	// LastRead and LastWrite are the times data was last
+	// read from and written to the network.
+	LastRead  time.Time
+	LastWrite time.Time
+
+	// CreditStarvations is the number of LinkEventCreditStarved
+	// events emitted by senders on the connection.
+	CreditStarvations uint64
+
+	// SlowConsumers is the number of LinkEventSlowConsumer
+	// events emitted by receivers on the connection.
+	SlowConsumers uint64
+
+	// ProtocolViolations is the number of violations of the AMQP
+	// specification by the peer ignored in ProtocolModeLenient.
+	ProtocolViolations uint64
+
+	// Sessions is the number of sessions currently open on the connection.
+	Sessions int
+
+	// MaxFrameSize is the maximum frame size the connection accepts.
+	MaxFrameSize uint32
+
+	// PeerMaxFrameSize is the maximum frame size the peer accepts.
+	PeerMaxFrameSize uint32
+
+	// ChannelMax is the negotiated maximum channel number.
+	ChannelMax uint16
+
+	// IdleTimeout is the local idle timeout, zero if disabled.
+	IdleTimeout time.Duration
+
+	// PeerIdleTimeout is the idle timeout requested by the peer,
+	// zero if the peer didn't request one.
+	PeerIdleTimeout time.Duration
+
+	// PeerContainerID is the container ID sent by the peer.
+	PeerContainerID string
+}
+
+// connStats contains the counters updated by the connection's goroutines.
+// All fields are accessed atomically.
+type connStats struct {
+	bytesRead     uint64
+	bytesWritten  uint64
+	framesRead    uint64
+	framesWritten uint64
+	readPauses    uint64
+	lastRead      int64 // Unix nanoseconds
+	lastWrite     int64 // Unix nanoseconds
+
+	creditStarvations uint64
+	slowConsumers     uint64
+
+	protocolViolations uint64
+}
+
+func (s *connStats) read(n int, now time.Time) {
+	if n > 0 {
+		atomic.AddUint64(&s.bytesRead, uint64(n))
+		atomic.StoreInt64(&s.lastRead, now.UnixNano())
+	}
+}
+
+func (s *connStats) wrote(n int, now time.Time) {
+	if n > 0 {
+		atomic.AddUint64(&s.bytesWritten, uint64(n))
+		atomic.StoreInt64(&s.lastWrite, now.UnixNano())
+	}
+}
+
+func (s *connStats) frameRead() {
+	atomic.AddUint64(&s.framesRead, 1)
+}
+
+func (s *connStats) frameWritten() {
+	atomic.AddUint64(&s.framesWritten, 1)
+}
+
+func (s *connStats) readPaused() {
+	atomic.AddUint64(&s.readPauses, 1)
+}
+
+func (s *connStats) protocolViolation() {
+	atomic.AddUint64(&s.protocolViolations, 1)
+}
+
+// Stats returns statistics about the connection.
+// It's safe to call concurrently with other methods and after Close.
+func (c *Conn) Stats() ConnStats {
+	stats := ConnStats{
+		BytesRead:          atomic.LoadUint64(&c.stats.bytesRead),
+		BytesWritten:       atomic.LoadUint64(&c.stats.bytesWritten),
+		FramesRead:         atomic.LoadUint64(&c.stats.framesRead),
+		FramesWritten:      atomic.LoadUint64(&c.stats.framesWritten),
+		ReadQueued:         len(c.rxQueue),
+		ReadQueueDepth:     cap(c.rxQueue),
+		ReadPauses:         atomic.LoadUint64(&c.stats.readPauses),
+		LastRead:           unixNanoTime(atomic.LoadInt64(&c.stats.lastRead)),
+		LastWrite:          unixNanoTime(atomic.LoadInt64(&c.stats.lastWrite)),
+		CreditStarvations:  atomic.LoadUint64(&c.stats.creditStarvations),
+		SlowConsumers:      atomic.LoadUint64(&c.stats.slowConsumers),
+		ProtocolViolations: atomic.LoadUint64(&c.stats.protocolViolations),
+		MaxFrameSize:       c.maxFrameSize,
+		PeerMaxFrameSize:   c.peerMaxFrameSize,
+		ChannelMax:         c.channelMax,
+		IdleTimeout:        c.idleTimeout,
+		PeerIdleTimeout:    c.peerIdleTimeout,
+		PeerContainerID:    c.peerContainerID,
+	}
+	c.sessionsByChannelMu.RLock()
+	stats.Sessions = len(c.sessionsByChannel)
+	c.sessionsByChannelMu.RUnlock()
+	return stats
+}
+
+// unixNanoTime converts ns to a time.Time, returning the zero Time for zero.
+func unixNanoTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// readNet reads from the network into c.rxBuf.
+func (c *Conn) readNet() error {
+	before := c.rxBuf.Len()
+	err := c.rxBuf.ReadFromOnce(c.net)
+	c.stats.read(c.rxBuf.Len()-before, c.clock.Now())
+	return err
+}
+
+// writeNet writes b to the network.
+func (c *Conn) writeNet(b []byte) (int, error) {
+	n, err := c.net.Write(b)
+	c.stats.wrote(n, c.clock.Now())
+	return n, err
+}
@@ -0,0 +1,298 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// SubscriptionState is the state of a subscription of a SubscriptionManager.
+type SubscriptionState int
+
+const (
+	// SubscriptionAttaching is the state of a subscription whose receiver
+	// is being attached.
+	SubscriptionAttaching SubscriptionState = iota
+
+	// SubscriptionActive is the state of a subscription whose receiver is
+	// attached and receiving messages.
+	SubscriptionActive
+
+	// SubscriptionBackoff is the state of a subscription waiting to attach
+	// its receiver again after it failed.
+	SubscriptionBackoff
+
+	// SubscriptionFailed is the state of a subscription that stopped
+	// because its receiver failed with an error that isn't retryable, or
+	// for which the retry policy stopped retrying.
+	SubscriptionFailed
+
+	// SubscriptionClosed is the state of a subscription that was removed
+	// with Unsubscribe, or closed with its SubscriptionManager.
+	SubscriptionClosed
+)
+
+// String implements the fmt.Stringer interface for SubscriptionState.
+func (s SubscriptionState) String() string {
+	switch s {
+	case SubscriptionAttaching:
+		return "attaching"
+	case SubscriptionActive:
+		return "active"
+	case SubscriptionBackoff:
+		return "backoff"
+	case SubscriptionFailed:
+		return "failed"
+	case SubscriptionClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("unknown subscription state %d", int(s))
+	}
+}
+
+// SubscriptionEvent describes a change in the state of a subscription.
+// See SubscriptionManager.Events.
+type SubscriptionEvent struct {
+	// Name is the name of the subscription.
+	Name string
+
+	// State is the state the subscription entered.
+	State SubscriptionState
+
+	// Err is the error that caused a SubscriptionBackoff or
+	// SubscriptionFailed state.
+	Err error
+
+	// Time is when the subscription entered State.
+	Time time.Time
+}
+
+// SubscriptionManagerOptions contains the optional settings for configuring
+// a SubscriptionManager.
+type SubscriptionManagerOptions struct {
+	// RetryPolicy determines if, and when, the receiver of a subscription
+	// is attached again after failing. Consecutive failures are counted
+	// from the last message received.
+	//
+	// Default: ExponentialBackoff retrying indefinitely.
+	RetryPolicy RetryPolicy
+
+	// EventBuffer is the number of events buffered by the channel returned
+	// by Events. Events are dropped while it's full.
+	//
+	// Default: 100.
+	EventBuffer int
+}
+
+// SubscriptionOptions contains the optional settings for configuring a
+// subscription of a SubscriptionManager.
+type SubscriptionOptions struct {
+	// Concurrency is the maximum number of messages handled concurrently.
+	//
+	// Default: 1.
+	Concurrency int
+
+	// ReceiverOptions are the options of the subscription's receiver.
+	//
+	// Default: nil.
+	ReceiverOptions *ReceiverOptions
+}
+
+// SubscriptionManager keeps a set of subscriptions, receivers passing
+// messages to a MessageHandler, attached across detaches and reconnects.
+//
+// Each subscription is run by a Consumer, whose receiver is attached on
+// the session returned by the session function. The function is called
+// again once the session ends, and is responsible for dialing a new
+// connection when the previous one failed.
+type SubscriptionManager struct {
+	newSession func(ctx context.Context) (*Session, error)
+	policy     RetryPolicy
+	events     chan SubscriptionEvent
+
+	running sync.WaitGroup // tracks the consumers, which send events
+
+	mu      sync.Mutex
+	current *Session // nil until created
+	subs    map[string]*subscription
+	closed  bool
+}
+
+// subscription is a subscription of a SubscriptionManager.
+type subscription struct {
+	consumer *Consumer
+	status   SubscriptionEvent // guarded by SubscriptionManager.mu
+}
+
+// NewSubscriptionManager creates a SubscriptionManager without any
+// subscriptions, attaching receivers on the session returned by session.
+//
+// opts: pass nil to accept the default values.
+func NewSubscriptionManager(session func(ctx context.Context) (*Session, error), opts *SubscriptionManagerOptions) *SubscriptionManager {
+	m := &SubscriptionManager{
+		newSession: session,
+		subs:       map[string]*subscription{},
+	}
+	eventBuffer := 100
+	if opts != nil {
+		m.policy = opts.RetryPolicy
+		if opts.EventBuffer > 0 {
+			eventBuffer = opts.EventBuffer
+		}
+	}
+	if m.policy == nil {
+		m.policy = ExponentialBackoff(&ExponentialBackoffOptions{MaxRetries: math.MaxInt32})
+	}
+	m.events = make(chan SubscriptionEvent, eventBuffer)
+	return m
+}
+
+// Subscribe adds the subscription name, passing the messages received from
+// source to h, and starts attaching its receiver. Messages are settled as
+// done by Consumer.
+//
+// opts: pass nil to accept the default values.
+func (m *SubscriptionManager) Subscribe(name, source string, h MessageHandler, opts *SubscriptionOptions) error {
+	var o SubscriptionOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return errors.New("amqp: subscription manager has been closed")
+	}
+	if _, ok := m.subs[name]; ok {
+		return fmt.Errorf("amqp: subscription %q already exists", name)
+	}
+
+	sub := &subscription{}
+	sub.consumer = NewConsumer(func(ctx context.Context) (*Receiver, error) {
+		session, err := m.session(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return session.NewReceiver(ctx, source, o.ReceiverOptions)
+	}, h, &ConsumerOptions{
+		Concurrency: o.Concurrency,
+		RetryPolicy: m.policy,
+	})
+	sub.consumer.stateChanged = func(state SubscriptionState, err error) {
+		m.setStatus(name, sub, state, err)
+	}
+	m.subs[name] = sub
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		<-sub.consumer.Done()
+	}()
+	return sub.consumer.Start()
+}
+
+// Unsubscribe removes the subscription name, waiting for the messages
+// being handled to be settled, and detaches its receiver. See Consumer.Stop.
+func (m *SubscriptionManager) Unsubscribe(ctx context.Context, name string) error {
+	m.mu.Lock()
+	sub, ok := m.subs[name]
+	delete(m.subs, name)
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("amqp: subscription %q doesn't exist", name)
+	}
+	if err := sub.consumer.Stop(ctx); err != nil && ctx.Err() != nil {
+		return err
+	}
+	return nil
+}
+
+// Status returns the last state change of the subscription name,
+// and false if there's no such subscription.
+func (m *SubscriptionManager) Status(name string) (SubscriptionEvent, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub, ok := m.subs[name]
+	if !ok {
+		return SubscriptionEvent{}, false
+	}
+	return sub.status, true
+}
+
+// Events returns the channel receiving the state changes of the
+// subscriptions. It's closed once the SubscriptionManager is closed.
+func (m *SubscriptionManager) Events() <-chan SubscriptionEvent {
+	return m.events
+}
+
+// Close removes the subscriptions as done by Unsubscribe, and ends the
+// session returned by the session function.
+func (m *SubscriptionManager) Close(ctx context.Context) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	subs := m.subs
+	m.subs = map[string]*subscription{}
+	m.mu.Unlock()
+
+	var err error
+	for _, sub := range subs {
+		if stopErr := sub.consumer.Stop(ctx); stopErr != nil && ctx.Err() != nil {
+			err = stopErr
+		}
+	}
+
+	// once the consumers have stopped, there are no more events
+	m.running.Wait()
+	close(m.events)
+
+	m.mu.Lock()
+	session := m.current
+	m.current = nil
+	m.mu.Unlock()
+	if session != nil {
+		_ = session.Close(ctx)
+	}
+	return err
+}
+
+// session returns the current session, creating a new one if it ended.
+func (m *SubscriptionManager) session(ctx context.Context) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.current != nil {
+		select {
+		case <-m.current.done:
+			m.current = nil
+		default:
+			return m.current, nil
+		}
+	}
+	if m.closed {
+		return nil, errors.New("amqp: subscription manager has been closed")
+	}
+	session, err := m.newSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.current = session
+	return session, nil
+}
+
+// setStatus records the state change of sub, and sends it to the events channel.
+func (m *SubscriptionManager) setStatus(name string, sub *subscription, state SubscriptionState, err error) {
+	ev := SubscriptionEvent{Name: name, State: state, Err: err, Time: time.Now()}
+	m.mu.Lock()
+	sub.status = ev
+	m.mu.Unlock()
+	select {
+	case m.events <- ev:
+	default:
+		// the events aren't read
+	}
+}
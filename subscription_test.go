@@ -0,0 +1,77 @@
+package amqp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionManager(t *testing.T) {
+	// the link is detached when the first message is dispatched
+	b := broker.New(&broker.Options{
+		AutoCreateQueues: true,
+		FaultHook: broker.NewScript(broker.FaultRule{
+			Point:   broker.FaultPointDispatch,
+			Address: "q",
+			Count:   1,
+			Fault:   broker.Fault{Detach: true},
+		}).Hook,
+	})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+	q, err := b.DeclareQueue("q")
+	require.NoError(t, err)
+
+	conn, err := amqp.Dial(srv.URL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	manager := amqp.NewSubscriptionManager(func(ctx context.Context) (*amqp.Session, error) {
+		return conn.NewSession(ctx, nil)
+	}, &amqp.SubscriptionManagerOptions{
+		RetryPolicy: amqp.ConstantBackoff(time.Millisecond, 3),
+	})
+
+	received := make(chan string, 1)
+	require.NoError(t, manager.Subscribe("orders", "q", func(ctx context.Context, msg *amqp.Message) error {
+		received <- string(msg.GetData())
+		return nil
+	}, nil))
+	require.Error(t, manager.Subscribe("orders", "q", nil, nil))
+
+	nextState := func() amqp.SubscriptionState {
+		select {
+		case ev := <-manager.Events():
+			require.Equal(t, "orders", ev.Name)
+			return ev.State
+		case <-time.After(5 * time.Second):
+			t.Fatal("no subscription event")
+			return 0
+		}
+	}
+	require.Equal(t, amqp.SubscriptionAttaching, nextState())
+	require.Equal(t, amqp.SubscriptionActive, nextState())
+
+	// the subscription is attached again after the detach
+	require.NoError(t, q.Enqueue(amqp.NewMessage([]byte("order"))))
+	require.Equal(t, amqp.SubscriptionBackoff, nextState())
+	require.Equal(t, amqp.SubscriptionAttaching, nextState())
+	require.Equal(t, amqp.SubscriptionActive, nextState())
+	require.Equal(t, "order", <-received)
+	status, ok := manager.Status("orders")
+	require.True(t, ok)
+	require.Equal(t, amqp.SubscriptionActive, status.State)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, manager.Close(ctx))
+	require.Equal(t, amqp.SubscriptionClosed, nextState())
+	_, ok = <-manager.Events()
+	require.False(t, ok)
+	_, ok = manager.Status("orders")
+	require.False(t, ok)
+}
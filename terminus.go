@@ -0,0 +1,85 @@
+package amqp
+
+import (
+	"github.com/Azure/go-amqp/internal/frames"
+)
+
+// Terminus is the source or target of a link as the peer attached it, which
+// can differ from the one requested. Brokers can ignore filters, pick
+// another address, or reduce the durability requested, so clients that
+// depend on them verify what the peer agreed to.
+//
+// See Sender.RemoteSource, Sender.RemoteTarget, Receiver.RemoteSource, and
+// Receiver.RemoteTarget.
+type Terminus struct {
+	// Address is the address of the node.
+	Address string
+
+	// Durability is what state of the terminus is retained durably.
+	Durability Durability
+
+	// ExpiryPolicy determines when the expiry timer of the terminus
+	// starts counting down from ExpiryTimeout.
+	ExpiryPolicy ExpiryPolicy
+
+	// ExpiryTimeout is the duration in seconds the terminus is retained.
+	ExpiryTimeout uint32
+
+	// Dynamic is true if the node was created dynamically.
+	Dynamic bool
+
+	// Capabilities is the list of capabilities of the node.
+	Capabilities []string
+
+	// DistributionMode is the distribution mode of a source, if any.
+	DistributionMode DistributionMode
+
+	// Filters contains the values of the filters a source applies, keyed by
+	// name. Filters requested but not applied by the peer are omitted.
+	Filters map[string]any
+
+	// Outcomes is the list of outcomes a source supports, e.g.
+	// "amqp:accepted:list", when the peer restricts them.
+	Outcomes []string
+}
+
+// newSourceTerminus returns the Terminus of s, or nil if s is nil.
+func newSourceTerminus(s *frames.Source) *Terminus {
+	if s == nil {
+		return nil
+	}
+	t := &Terminus{
+		Address:          s.Address,
+		Durability:       s.Durable,
+		ExpiryPolicy:     s.ExpiryPolicy,
+		ExpiryTimeout:    s.Timeout,
+		Dynamic:          s.Dynamic,
+		Capabilities:     symbolStrings(s.Capabilities),
+		DistributionMode: DistributionMode(s.DistributionMode),
+		Outcomes:         symbolStrings(s.Outcomes),
+	}
+	if len(s.Filter) > 0 {
+		t.Filters = make(map[string]any, len(s.Filter))
+		for name, filter := range s.Filter {
+			if filter != nil {
+				t.Filters[string(name)] = filter.Value
+			}
+		}
+	}
+	return t
+}
+
+// newTargetTerminus returns the Terminus of t, or nil if t is nil.
+func newTargetTerminus(t *frames.Target) *Terminus {
+	if t == nil {
+		return nil
+	}
+	return &Terminus{
+		Address:       t.Address,
+		Durability:    t.Durable,
+		ExpiryPolicy:  t.ExpiryPolicy,
+		ExpiryTimeout: t.Timeout,
+		Dynamic:       t.Dynamic,
+		Capabilities:  symbolStrings(t.Capabilities),
+	}
+}
@@ -0,0 +1,517 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// TopologyOptions contains the optional settings for configuring a Topology.
+type TopologyOptions struct {
+	// RetryPolicy determines if, and when, the topology is recreated after
+	// failing to recreate it on a new connection.
+	//
+	// Default: ExponentialBackoff(nil).
+	RetryPolicy RetryPolicy
+
+	// OnRecovered is called once the topology has been recreated on a new
+	// connection, or with the error that stopped its recovery, in which case
+	// the Topology's handles return the error.
+	//
+	// Default: nil.
+	OnRecovered func(conn *Conn, err error)
+}
+
+// Topology records the sessions, senders, and receivers created through it
+// on a connection, and recreates them on a new connection when the
+// connection fails, rebinding the handles held by the application.
+//
+// Links are recreated with the same names, addresses, and options. Messages
+// being sent when the connection fails aren't sent again, the error is
+// returned to the caller. Receivers continue receiving on the new link,
+// and messages received but not settled are redelivered by the peer.
+//
+// Recovery only applies to connection failures. Closing the Topology closes
+// the connection; closing the connection returned by Conn directly is
+// treated as a failure.
+type Topology struct {
+	dial        func(ctx context.Context) (*Conn, error)
+	policy      RetryPolicy
+	onRecovered func(conn *Conn, err error)
+
+	ctx    context.Context // canceled by Close
+	cancel context.CancelFunc
+
+	// mu guards the fields below, and those of the handles. While recovering,
+	// the handles are only used by the recovery, which rebinds them.
+	// It isn't held across network round trips, see bind.
+	mu         sync.Mutex
+	conn       *Conn
+	sessions   []*TopologySession
+	recovering chan struct{} // closed once recovery completes, nil when not recovering
+	err        error         // error that stopped recovery, or errTopologyClosed
+}
+
+// errTopologyClosed is returned by the handles of a closed Topology.
+var errTopologyClosed = errors.New("amqp: topology has been closed")
+
+// NewTopology dials the connection of a Topology with dial, which is
+// called again to dial a new connection when it fails.
+//
+// opts: pass nil to accept the default values.
+func NewTopology(ctx context.Context, dial func(ctx context.Context) (*Conn, error), opts *TopologyOptions) (*Topology, error) {
+	conn, err := dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t := &Topology{
+		dial: dial,
+		conn: conn,
+	}
+	if opts != nil {
+		t.policy = opts.RetryPolicy
+		t.onRecovered = opts.OnRecovered
+	}
+	if t.policy == nil {
+		t.policy = ExponentialBackoff(nil)
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	go t.watch(conn)
+	return t, nil
+}
+
+// Conn returns the current connection, or nil while it's being recovered.
+func (t *Topology) Conn() *Conn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.recovering != nil {
+		return nil
+	}
+	return t.conn
+}
+
+// NewSession begins a new session on the connection, recreated with the
+// same options after a reconnect.
+//
+// opts: pass nil to accept the default values.
+func (t *Topology) NewSession(ctx context.Context, opts *SessionOptions) (*TopologySession, error) {
+	for {
+		if err := t.wait(ctx); err != nil {
+			return nil, err
+		}
+		conn := t.conn
+		t.mu.Unlock()
+
+		session, err := conn.NewSession(ctx, opts)
+		if !t.bind(conn) {
+			if session != nil {
+				_ = session.Close(ctx)
+			}
+			continue
+		}
+		if err != nil {
+			t.mu.Unlock()
+			return nil, err
+		}
+		if opts != nil {
+			o := *opts
+			opts = &o
+		}
+		ts := &TopologySession{topology: t, opts: opts, session: session}
+		t.sessions = append(t.sessions, ts)
+		t.mu.Unlock()
+		return ts, nil
+	}
+}
+
+// Close stops recovering the topology and closes the connection.
+func (t *Topology) Close() error {
+	t.cancel()
+	t.mu.Lock()
+	if t.err == nil {
+		t.err = errTopologyClosed
+	}
+	conn, recovering := t.conn, t.recovering
+	t.mu.Unlock()
+	if recovering != nil {
+		// the connection has already failed
+		return nil
+	}
+	return conn.Close()
+}
+
+// wait waits for the topology not to be recovering, and returns with t.mu
+// held, unless the topology is closed, recovery failed, or ctx completes.
+func (t *Topology) wait(ctx context.Context) error {
+	for {
+		t.mu.Lock()
+		if t.err != nil {
+			t.mu.Unlock()
+			return t.err
+		}
+		recovering := t.recovering
+		if recovering == nil {
+			return nil
+		}
+		t.mu.Unlock()
+
+		select {
+		case <-recovering:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// bind locks t.mu once a handle has been created on conn without it held.
+// It returns false, with t.mu unlocked, if conn has failed in the meantime
+// so the handle must be created again once the topology is recovered, or
+// the topology was closed.
+func (t *Topology) bind(conn *Conn) bool {
+	t.mu.Lock()
+	if t.conn == conn && t.recovering == nil && t.err == nil {
+		return true
+	}
+	t.mu.Unlock()
+	return false
+}
+
+// watch recovers the topology each time its connection fails.
+func (t *Topology) watch(conn *Conn) {
+	for {
+		select {
+		case <-conn.done:
+		case <-t.ctx.Done():
+			return
+		}
+		if t.ctx.Err() != nil {
+			return
+		}
+		conn.logger.Warn("recovering topology", "hostname", conn.hostname, "error", conn.doneErr)
+
+		t.mu.Lock()
+		recovering := make(chan struct{})
+		t.recovering = recovering
+		t.mu.Unlock()
+
+		var err error
+		conn, err = t.recover()
+
+		t.mu.Lock()
+		if err == nil && t.err != nil {
+			// closed while recovering
+			_ = conn.Close()
+			conn, err = nil, t.err
+		}
+		if err == nil {
+			t.conn = conn
+		} else if t.err == nil {
+			t.err = err
+		}
+		t.recovering = nil
+		close(recovering)
+		t.mu.Unlock()
+
+		if t.onRecovered != nil {
+			t.onRecovered(conn, err)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// recover dials a new connection and recreates the topology on it,
+// retrying according to the retry policy.
+func (t *Topology) recover() (*Conn, error) {
+	for attempt := 1; ; attempt++ {
+		conn, err := t.dial(t.ctx)
+		if err == nil {
+			if err = t.rebuild(conn); err == nil {
+				return conn, nil
+			}
+			_ = conn.Close()
+		}
+
+		if t.ctx.Err() != nil {
+			return nil, errTopologyClosed
+		}
+		if !IsRetryable(err) {
+			return nil, err
+		}
+		delay, ok := t.policy.Delay(attempt, err)
+		if !ok {
+			return nil, err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-t.ctx.Done():
+			timer.Stop()
+			return nil, errTopologyClosed
+		}
+	}
+}
+
+// rebuild recreates the sessions and links of the topology on conn,
+// and rebinds their handles.
+func (t *Topology) rebuild(conn *Conn) error {
+	t.mu.Lock()
+	sessions := t.sessions
+	t.mu.Unlock()
+	for _, ts := range sessions {
+		session, err := conn.NewSession(t.ctx, ts.opts)
+		if err != nil {
+			return err
+		}
+		ts.session = session
+		for _, tsnd := range ts.senders {
+			if tsnd.snd, err = session.NewSender(t.ctx, tsnd.target, &tsnd.opts); err != nil {
+				return err
+			}
+		}
+		for _, trcv := range ts.receivers {
+			if trcv.rcv, err = session.NewReceiver(t.ctx, trcv.source, &trcv.opts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// TopologySession is a session of a Topology.
+type TopologySession struct {
+	topology *Topology
+	opts     *SessionOptions
+
+	session   *Session
+	senders   []*TopologySender
+	receivers []*TopologyReceiver
+}
+
+// Session returns the current session, or nil while the topology is being
+// recovered.
+func (ts *TopologySession) Session() *Session {
+	ts.topology.mu.Lock()
+	defer ts.topology.mu.Unlock()
+	if ts.topology.recovering != nil {
+		return nil
+	}
+	return ts.session
+}
+
+// NewSender opens a new sender link on the session, recreated with the
+// same name, target, and options after a reconnect.
+//
+// opts: pass nil to accept the default values.
+func (ts *TopologySession) NewSender(ctx context.Context, target string, opts *SenderOptions) (*TopologySender, error) {
+	t := ts.topology
+	for {
+		if err := t.wait(ctx); err != nil {
+			return nil, err
+		}
+		conn, session := t.conn, ts.session
+		t.mu.Unlock()
+
+		snd, err := session.NewSender(ctx, target, opts)
+		if !t.bind(conn) {
+			if snd != nil {
+				_ = snd.Close(ctx)
+			}
+			continue
+		}
+		if err != nil {
+			t.mu.Unlock()
+			return nil, err
+		}
+		tsnd := &TopologySender{session: ts, target: target, snd: snd}
+		if opts != nil {
+			tsnd.opts = *opts
+		}
+		tsnd.opts.Name = snd.LinkName()
+		ts.senders = append(ts.senders, tsnd)
+		t.mu.Unlock()
+		return tsnd, nil
+	}
+}
+
+// NewReceiver opens a new receiver link on the session, recreated with
+// the same name, source, and options after a reconnect.
+//
+// opts: pass nil to accept the default values.
+func (ts *TopologySession) NewReceiver(ctx context.Context, source string, opts *ReceiverOptions) (*TopologyReceiver, error) {
+	t := ts.topology
+	for {
+		if err := t.wait(ctx); err != nil {
+			return nil, err
+		}
+		conn, session := t.conn, ts.session
+		t.mu.Unlock()
+
+		rcv, err := session.NewReceiver(ctx, source, opts)
+		if !t.bind(conn) {
+			if rcv != nil {
+				_ = rcv.Close(ctx)
+			}
+			continue
+		}
+		if err != nil {
+			t.mu.Unlock()
+			return nil, err
+		}
+		trcv := &TopologyReceiver{session: ts, source: source, rcv: rcv}
+		if opts != nil {
+			trcv.opts = *opts
+		}
+		trcv.opts.Name = rcv.LinkName()
+		ts.receivers = append(ts.receivers, trcv)
+		t.mu.Unlock()
+		return trcv, nil
+	}
+}
+
+// Close ends the session, which is no longer recreated.
+func (ts *TopologySession) Close(ctx context.Context) error {
+	if err := ts.topology.wait(ctx); err != nil {
+		return err
+	}
+	ts.topology.sessions = removeItem(ts.topology.sessions, ts)
+	session := ts.session
+	ts.topology.mu.Unlock()
+	return session.Close(ctx)
+}
+
+// TopologySender is a sender of a Topology.
+type TopologySender struct {
+	session *TopologySession
+	target  string
+	opts    SenderOptions
+	snd     *Sender // guarded by session.topology.mu
+}
+
+// Sender returns the current sender, waiting for the topology to be
+// recovered if needed.
+func (tsnd *TopologySender) Sender(ctx context.Context) (*Sender, error) {
+	if err := tsnd.session.topology.wait(ctx); err != nil {
+		return nil, err
+	}
+	defer tsnd.session.topology.mu.Unlock()
+	return tsnd.snd, nil
+}
+
+// Send sends msg on the current sender. See Sender.Send.
+func (tsnd *TopologySender) Send(ctx context.Context, msg *Message) error {
+	snd, err := tsnd.Sender(ctx)
+	if err != nil {
+		return err
+	}
+	return snd.Send(ctx, msg)
+}
+
+// SendWithOutcome sends msg on the current sender. See Sender.SendWithOutcome.
+func (tsnd *TopologySender) SendWithOutcome(ctx context.Context, msg *Message) (Outcome, error) {
+	snd, err := tsnd.Sender(ctx)
+	if err != nil {
+		return Outcome{}, err
+	}
+	return snd.SendWithOutcome(ctx, msg)
+}
+
+// Close closes the sender, which is no longer recreated.
+func (tsnd *TopologySender) Close(ctx context.Context) error {
+	t := tsnd.session.topology
+	if err := t.wait(ctx); err != nil {
+		return err
+	}
+	tsnd.session.senders = removeItem(tsnd.session.senders, tsnd)
+	snd := tsnd.snd
+	t.mu.Unlock()
+	return snd.Close(ctx)
+}
+
+// TopologyReceiver is a receiver of a Topology.
+type TopologyReceiver struct {
+	session *TopologySession
+	source  string
+	opts    ReceiverOptions
+	rcv     *Receiver // guarded by session.topology.mu
+}
+
+// Receiver returns the current receiver, waiting for the topology to be
+// recovered if needed.
+func (trcv *TopologyReceiver) Receiver(ctx context.Context) (*Receiver, error) {
+	if err := trcv.session.topology.wait(ctx); err != nil {
+		return nil, err
+	}
+	defer trcv.session.topology.mu.Unlock()
+	return trcv.rcv, nil
+}
+
+// Receive returns the next message received on the current receiver. When
+// the connection fails, it waits for the topology to be recovered and
+// receives on the new receiver. See Receiver.Receive.
+func (trcv *TopologyReceiver) Receive(ctx context.Context) (*Message, error) {
+	for {
+		rcv, err := trcv.Receiver(ctx)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := rcv.Receive(ctx)
+		if err == nil || ctx.Err() != nil {
+			return msg, err
+		}
+		select {
+		case <-rcv.l.session.conn.done:
+			// receive again once recovered
+		default:
+			return nil, err
+		}
+	}
+}
+
+// AcceptMessage accepts msg on the receiver it was received on.
+// See Receiver.AcceptMessage.
+func (trcv *TopologyReceiver) AcceptMessage(ctx context.Context, msg *Message) error {
+	return msg.rcvr.AcceptMessage(ctx, msg)
+}
+
+// RejectMessage rejects msg on the receiver it was received on.
+// See Receiver.RejectMessage.
+func (trcv *TopologyReceiver) RejectMessage(ctx context.Context, msg *Message, e *Error) error {
+	return msg.rcvr.RejectMessage(ctx, msg, e)
+}
+
+// ReleaseMessage releases msg on the receiver it was received on.
+// See Receiver.ReleaseMessage.
+func (trcv *TopologyReceiver) ReleaseMessage(ctx context.Context, msg *Message) error {
+	return msg.rcvr.ReleaseMessage(ctx, msg)
+}
+
+// ModifyMessage modifies msg on the receiver it was received on.
+// See Receiver.ModifyMessage.
+func (trcv *TopologyReceiver) ModifyMessage(ctx context.Context, msg *Message, options *ModifyMessageOptions) error {
+	return msg.rcvr.ModifyMessage(ctx, msg, options)
+}
+
+// Close closes the receiver, which is no longer recreated.
+func (trcv *TopologyReceiver) Close(ctx context.Context) error {
+	t := trcv.session.topology
+	if err := t.wait(ctx); err != nil {
+		return err
+	}
+	trcv.session.receivers = removeItem(trcv.session.receivers, trcv)
+	rcv := trcv.rcv
+	t.mu.Unlock()
+	return rcv.Close(ctx)
+}
+
+// removeItem returns items without item.
+func removeItem[T comparable](items []T, item T) []T {
+	for i, it := range items {
+		if it == item {
+			return append(items[:i:i], items[i+1:]...)
+		}
+	}
+	return items
+}
@@ -0,0 +1,134 @@
+package amqp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopology(t *testing.T) {
+	b := broker.New(&broker.Options{AutoCreateQueues: true})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dials := 0
+	recovered := make(chan error, 1)
+	topology, err := amqp.NewTopology(ctx, func(ctx context.Context) (*amqp.Conn, error) {
+		dials++
+		return amqp.Dial(srv.URL, nil)
+	}, &amqp.TopologyOptions{
+		RetryPolicy: amqp.ConstantBackoff(time.Millisecond, 3),
+		OnRecovered: func(_ *amqp.Conn, err error) { recovered <- err },
+	})
+	require.NoError(t, err)
+	defer topology.Close()
+
+	session, err := topology.NewSession(ctx, nil)
+	require.NoError(t, err)
+	snd, err := session.NewSender(ctx, "q", nil)
+	require.NoError(t, err)
+	rcv, err := session.NewReceiver(ctx, "q", nil)
+	require.NoError(t, err)
+	sender, err := snd.Sender(ctx)
+	require.NoError(t, err)
+	receiver, err := rcv.Receiver(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, snd.Send(ctx, amqp.NewMessage([]byte("before"))))
+	msg, err := rcv.Receive(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "before", string(msg.GetData()))
+	require.NoError(t, rcv.AcceptMessage(ctx, msg))
+
+	// the receiver continues receiving once recovered
+	received := make(chan *amqp.Message, 1)
+	go func() {
+		msg, err := rcv.Receive(ctx)
+		if err != nil {
+			close(received)
+			return
+		}
+		received <- msg
+	}()
+
+	require.NoError(t, topology.Conn().Close())
+	require.NoError(t, <-recovered)
+	require.Equal(t, 2, dials)
+
+	// the handles are rebound to links with the same names
+	newSender, err := snd.Sender(ctx)
+	require.NoError(t, err)
+	require.NotSame(t, sender, newSender)
+	require.Equal(t, sender.LinkName(), newSender.LinkName())
+	newReceiver, err := rcv.Receiver(ctx)
+	require.NoError(t, err)
+	require.Equal(t, receiver.LinkName(), newReceiver.LinkName())
+
+	require.NoError(t, snd.Send(ctx, amqp.NewMessage([]byte("after"))))
+	msg = <-received
+	require.NotNil(t, msg)
+	require.Equal(t, "after", string(msg.GetData()))
+	require.NoError(t, rcv.AcceptMessage(ctx, msg))
+
+	require.NoError(t, topology.Close())
+	require.Error(t, snd.Send(ctx, amqp.NewMessage([]byte("closed"))))
+}
+
+func TestTopologySlowAttach(t *testing.T) {
+	attaching, release := make(chan struct{}), make(chan struct{})
+	b := broker.New(&broker.Options{
+		AutoCreateQueues: true,
+		FaultHook: func(ev broker.FaultEvent) broker.Fault {
+			if ev.Point == broker.FaultPointAttach && ev.Address == "slow" {
+				close(attaching)
+				<-release
+			}
+			return broker.Fault{}
+		},
+	})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	topology, err := amqp.NewTopology(ctx, func(ctx context.Context) (*amqp.Conn, error) {
+		return amqp.Dial(srv.URL, nil)
+	}, nil)
+	require.NoError(t, err)
+	defer topology.Close()
+	session, err := topology.NewSession(ctx, nil)
+	require.NoError(t, err)
+	snd, err := session.NewSender(ctx, "q", nil)
+	require.NoError(t, err)
+
+	// the broker accepts the links of a session one at a time
+	slowSession, err := topology.NewSession(ctx, nil)
+	require.NoError(t, err)
+	attached := make(chan error, 1)
+	go func() {
+		_, err := slowSession.NewSender(ctx, "slow", nil)
+		attached <- err
+	}()
+
+	// the other handles are usable while the attach is in progress
+	<-attaching
+	sendCtx, sendCancel := context.WithTimeout(ctx, time.Second)
+	defer sendCancel()
+	require.NoError(t, snd.Send(sendCtx, amqp.NewMessage([]byte("hello"))))
+	select {
+	case err := <-attached:
+		t.Fatalf("attach completed early: %v", err)
+	default:
+	}
+
+	close(release)
+	require.NoError(t, <-attached)
+}
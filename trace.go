@@ -0,0 +1,78 @@
+package amqp
+
+import (
+	"fmt"
+
+	"github.com/Azure/go-amqp/internal/frames"
+)
+
+// FrameTraceEvent describes a performative sent to or received from the peer.
+// See ConnOptions.FrameTrace.
+type FrameTraceEvent struct {
+	// Outbound is true if the frame was sent to the peer
+	// and false if it was received from the peer.
+	Outbound bool
+
+	// Channel is the channel number in the frame header.
+	Channel uint16
+
+	// Performative is the AMQP name of the performative, e.g. "attach" or "sasl-init".
+	Performative string
+
+	// Summary describes the performative's fields. Message payloads
+	// are reported by size only and SASL credentials are elided.
+	Summary string
+
+	// Size is the encoded size of the frame, including the frame header.
+	Size int
+}
+
+// traceFrame invokes the FrameTrace callback, if set, for the frame.
+func (c *Conn) traceFrame(outbound bool, channel uint16, body frames.FrameBody, size int) {
+	if c.frameTrace == nil || body == nil {
+		return
+	}
+	c.frameTrace(FrameTraceEvent{
+		Outbound:     outbound,
+		Channel:      channel,
+		Performative: performativeName(body),
+		Summary:      fmt.Sprint(body),
+		Size:         size,
+	})
+}
+
+// performativeName returns the AMQP name of the performative in body.
+func performativeName(body frames.FrameBody) string {
+	switch body.(type) {
+	case *frames.PerformOpen:
+		return "open"
+	case *frames.PerformBegin:
+		return "begin"
+	case *frames.PerformAttach:
+		return "attach"
+	case *frames.PerformFlow:
+		return "flow"
+	case *frames.PerformTransfer:
+		return "transfer"
+	case *frames.PerformDisposition:
+		return "disposition"
+	case *frames.PerformDetach:
+		return "detach"
+	case *frames.PerformEnd:
+		return "end"
+	case *frames.PerformClose:
+		return "close"
+	case *frames.SASLMechanisms:
+		return "sasl-mechanisms"
+	case *frames.SASLInit:
+		return "sasl-init"
+	case *frames.SASLChallenge:
+		return "sasl-challenge"
+	case *frames.SASLResponse:
+		return "sasl-response"
+	case *frames.SASLOutcome:
+		return "sasl-outcome"
+	default:
+		return fmt.Sprintf("%T", body)
+	}
+}
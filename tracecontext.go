@@ -0,0 +1,135 @@
+package amqp
+
+import (
+	"strings"
+
+	"github.com/Azure/go-amqp/internal/encoding"
+)
+
+const (
+	traceParentKey = "traceparent"
+	traceStateKey  = "tracestate"
+)
+
+// TraceContext is a W3C Trace Context, used to continue a distributed
+// trace across the broker. See https://www.w3.org/TR/trace-context/.
+type TraceContext struct {
+	// TraceParent is the traceparent value, e.g.
+	// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+	TraceParent string
+
+	// TraceState is the optional vendor-specific tracestate value.
+	TraceState string
+}
+
+// Valid returns true if TraceParent is well-formed.
+func (tc TraceContext) Valid() bool {
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	parts := strings.Split(tc.TraceParent, "-")
+	if len(parts) < 4 {
+		return false
+	}
+	version := parts[0]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return false
+	}
+	traceID, parentID, flags := parts[1], parts[2], parts[3]
+	return isLowerHex(traceID, 32) && traceID != strings.Repeat("0", 32) &&
+		isLowerHex(parentID, 16) && parentID != strings.Repeat("0", 16) &&
+		isLowerHex(flags, 2)
+}
+
+// isLowerHex returns true if s is n lowercase hex digits.
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !(s[i] >= '0' && s[i] <= '9' || s[i] >= 'a' && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// TraceContextLocation specifies the message section that
+// carries a TraceContext.
+type TraceContextLocation uint8
+
+const (
+	// TraceContextAnnotations stores the trace context in the message
+	// annotations. This follows the W3C Trace Context AMQP protocol
+	// draft, as brokers may modify annotations but not application properties.
+	TraceContextAnnotations TraceContextLocation = iota
+
+	// TraceContextApplicationProperties stores the trace context in
+	// the application properties, for consumers that only inspect them.
+	TraceContextApplicationProperties
+)
+
+// SetTraceContext stores tc in the message section specified by loc,
+// under the "traceparent" and "tracestate" keys. An empty TraceState
+// removes any existing tracestate entry.
+func (m *Message) SetTraceContext(tc TraceContext, loc TraceContextLocation) {
+	set := func(key, value string) {
+		switch loc {
+		case TraceContextApplicationProperties:
+			if value == "" {
+				delete(m.ApplicationProperties, key)
+				return
+			}
+			if m.ApplicationProperties == nil {
+				m.ApplicationProperties = map[string]any{}
+			}
+			m.ApplicationProperties[key] = value
+		default:
+			if value == "" {
+				delete(m.Annotations, key)
+				delete(m.Annotations, encoding.Symbol(key))
+				return
+			}
+			if m.Annotations == nil {
+				m.Annotations = Annotations{}
+			}
+			delete(m.Annotations, encoding.Symbol(key))
+			m.Annotations[key] = value
+		}
+	}
+	set(traceParentKey, tc.TraceParent)
+	set(traceStateKey, tc.TraceState)
+}
+
+// TraceContext returns the trace context carried by the message.
+//
+// The message annotations are checked first, followed by the application
+// properties. It returns false if neither contains a valid traceparent.
+func (m *Message) TraceContext() (TraceContext, bool) {
+	lookupAnnotation := func(key string) string {
+		v, ok := m.Annotations[key]
+		if !ok {
+			v = m.Annotations[encoding.Symbol(key)]
+		}
+		s, _ := v.(string)
+		return s
+	}
+	tc := TraceContext{
+		TraceParent: lookupAnnotation(traceParentKey),
+		TraceState:  lookupAnnotation(traceStateKey),
+	}
+	if tc.Valid() {
+		return tc, true
+	}
+
+	lookupProperty := func(key string) string {
+		s, _ := m.ApplicationProperties[key].(string)
+		return s
+	}
+	tc = TraceContext{
+		TraceParent: lookupProperty(traceParentKey),
+		TraceState:  lookupProperty(traceStateKey),
+	}
+	if tc.Valid() {
+		return tc, true
+	}
+	return TraceContext{}, false
+}
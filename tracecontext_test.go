@@ -0,0 +1,69 @@
+package amqp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestTraceContextValid(t *testing.T) {
+	for _, tt := range []struct {
+		traceParent string
+		valid       bool
+	}{
+		{testTraceParent, true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false},
+	} {
+		require.Equal(t, tt.valid, TraceContext{TraceParent: tt.traceParent}.Valid(), tt.traceParent)
+	}
+}
+
+func TestMessageTraceContext(t *testing.T) {
+	tc := TraceContext{TraceParent: testTraceParent, TraceState: "vendor=value"}
+
+	for _, loc := range []TraceContextLocation{TraceContextAnnotations, TraceContextApplicationProperties} {
+		msg := NewMessage([]byte("data"))
+		_, ok := msg.TraceContext()
+		require.False(t, ok)
+
+		msg.SetTraceContext(tc, loc)
+
+		// round trip through the encoder to match what a receiver sees
+		b, err := msg.MarshalBinary()
+		require.NoError(t, err)
+		var received Message
+		require.NoError(t, received.UnmarshalBinary(b))
+
+		got, ok := received.TraceContext()
+		require.True(t, ok)
+		require.Equal(t, tc, got)
+
+		msg.SetTraceContext(TraceContext{TraceParent: testTraceParent}, loc)
+		got, ok = msg.TraceContext()
+		require.True(t, ok)
+		require.Empty(t, got.TraceState)
+	}
+
+	// annotations take precedence
+	msg := NewMessage(nil)
+	msg.SetTraceContext(TraceContext{TraceParent: "00-11111111111111111111111111111111-1111111111111111-00"}, TraceContextApplicationProperties)
+	msg.SetTraceContext(tc, TraceContextAnnotations)
+	got, ok := msg.TraceContext()
+	require.True(t, ok)
+	require.Equal(t, tc, got)
+
+	// invalid values are ignored
+	msg = NewMessage(nil)
+	msg.ApplicationProperties = map[string]any{"traceparent": "garbage"}
+	_, ok = msg.TraceContext()
+	require.False(t, ok)
+}
@@ -0,0 +1,154 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/go-amqp/internal/encoding"
+	"github.com/Azure/go-amqp/internal/frames"
+)
+
+// TransactionController declares and discharges local transactions with
+// the transaction coordinator of the peer.
+type TransactionController struct {
+	snd *Sender
+}
+
+// NewTransactionController attaches a link to the transaction coordinator of
+// the peer. Peers that don't support transactions refuse the link, in which
+// case a *DetachError or an *Error is returned.
+func (s *Session) NewTransactionController(ctx context.Context) (_ *TransactionController, err error) {
+	defer func() { err = s.conn.translateErr(err) }()
+
+	snd, err := newSender("", s, &SenderOptions{IgnoreDispositionErrors: true})
+	if err != nil {
+		return nil, err
+	}
+	snd.l.rx = make(chan frames.FrameBody, 1)
+	if err := snd.l.attach(ctx, func(pa *frames.PerformAttach) {
+		pa.Role = encoding.RoleSender
+		pa.Target = nil
+		pa.Coordinator = &frames.Coordinator{
+			Capabilities: encoding.MultiSymbol{"amqp:local-transactions"},
+		}
+	}, func(*frames.PerformAttach) {}); err != nil {
+		return nil, err
+	}
+	snd.transfers = make(chan frames.PerformTransfer)
+	go snd.mux()
+	return &TransactionController{snd: snd}, nil
+}
+
+// Declare begins a transaction.
+func (c *TransactionController) Declare(ctx context.Context) (*Transaction, error) {
+	state, err := c.request(ctx, &encoding.Declare{})
+	if err != nil {
+		return nil, err
+	}
+	declared, ok := state.(*encoding.StateDeclared)
+	if !ok {
+		return nil, fmt.Errorf("amqp: unexpected declare outcome %v", state)
+	}
+	return &Transaction{c: c, id: declared.TxnID}, nil
+}
+
+// Close detaches the link to the transaction coordinator. Transactions not
+// discharged yet are rolled back by the coordinator.
+func (c *TransactionController) Close(ctx context.Context) error {
+	return c.snd.Close(ctx)
+}
+
+// request sends a declare or discharge request, and returns the
+// delivery state the coordinator settled it with.
+func (c *TransactionController) request(ctx context.Context, req any) (_ encoding.DeliveryState, err error) {
+	defer func() { err = c.snd.l.translateErr(err) }()
+
+	state, err := c.snd.sendAndWait(ctx, &Message{Value: req})
+	if err != nil {
+		return nil, err
+	}
+	if rejected, ok := state.(*encoding.StateRejected); ok {
+		if rejected.Error == nil {
+			return nil, &Error{Condition: ErrCondTransactionRollback}
+		}
+		return nil, rejected.Error
+	}
+	return state, nil
+}
+
+// Transaction is a local transaction declared by a TransactionController.
+//
+// The messages sent with Send, and the outcomes of the messages settled
+// with Accept, only take effect once the transaction is committed.
+type Transaction struct {
+	c        *TransactionController
+	id       []byte
+	accepted []*Message // recorded by their receiver's DedupCache once committed
+}
+
+// ID returns the ID the coordinator allocated to the transaction.
+func (t *Transaction) ID() []byte {
+	return t.id
+}
+
+// Send sends msg on snd as part of the transaction. Unlike Sender.Send, it
+// returns the *Error of a provisionally rejected message.
+func (t *Transaction) Send(ctx context.Context, snd *Sender, msg *Message) error {
+	prev := msg.txnID
+	msg.txnID = t.id
+	defer func() { msg.txnID = prev }()
+
+	outcome, err := snd.SendWithOutcome(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if outcome.Type == OutcomeRejected {
+		if outcome.Error == nil {
+			return &Error{Condition: ErrCondTransactionRollback}
+		}
+		return outcome.Error
+	}
+	return nil
+}
+
+// Accept accepts msg, received on a Receiver, as part of the transaction.
+func (t *Transaction) Accept(ctx context.Context, msg *Message) error {
+	if msg.rcvr == nil {
+		return errors.New("amqp: message wasn't received on a receiver")
+	}
+	r := msg.rcvr
+	msg.txnID = t.id
+	if !msg.shouldSendDisposition() {
+		return nil
+	}
+	if err := r.messageDisposition(ctx, msg, &encoding.StateAccepted{}); err != nil {
+		return r.l.translateErr(err)
+	}
+	t.accepted = append(t.accepted, msg)
+	return nil
+}
+
+// Commit discharges the transaction, applying its work. If the coordinator
+// fails to commit it, the transaction is rolled back, and an *Error with the
+// amqp:transaction:rollback condition is typically returned.
+func (t *Transaction) Commit(ctx context.Context) error {
+	if err := t.discharge(ctx, false); err != nil {
+		return err
+	}
+	for _, msg := range t.accepted {
+		msg.rcvr.dedup.accepted(msg)
+	}
+	t.accepted = nil
+	return nil
+}
+
+// Rollback discharges the transaction, discarding its work.
+func (t *Transaction) Rollback(ctx context.Context) error {
+	return t.discharge(ctx, true)
+}
+
+func (t *Transaction) discharge(ctx context.Context, fail bool) error {
+	_, err := t.c.request(ctx, &encoding.Discharge{TxnID: t.id, Fail: fail})
+	return err
+}
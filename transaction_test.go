@@ -0,0 +1,66 @@
+package amqp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amqp "github.com/Azure/go-amqp"
+	"github.com/Azure/go-amqp/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransaction(t *testing.T) {
+	b := broker.New(&broker.Options{AutoCreateQueues: true})
+	srv, err := broker.NewServer(b, nil)
+	require.NoError(t, err)
+	defer srv.Close()
+	in, err := b.DeclareQueue("in")
+	require.NoError(t, err)
+	require.NoError(t, in.Enqueue(amqp.NewMessage([]byte("in"))))
+	out, err := b.DeclareQueue("out")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := amqp.Dial(srv.URL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	session, err := conn.NewSession(ctx, nil)
+	require.NoError(t, err)
+	ctrl, err := session.NewTransactionController(ctx)
+	require.NoError(t, err)
+	defer ctrl.Close(ctx)
+	rcv, err := session.NewReceiver(ctx, "in", nil)
+	require.NoError(t, err)
+	snd, err := session.NewSender(ctx, "out", nil)
+	require.NoError(t, err)
+
+	// the work of a transaction rolled back is discarded
+	msg, err := rcv.Receive(ctx)
+	require.NoError(t, err)
+	txn, err := ctrl.Declare(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, txn.ID())
+	require.NoError(t, txn.Send(ctx, snd, amqp.NewMessage([]byte("out"))))
+	require.NoError(t, txn.Accept(ctx, msg))
+	require.NoError(t, txn.Rollback(ctx))
+	require.Zero(t, out.Len())
+
+	// and applied once it commits
+	msg, err = rcv.Receive(ctx)
+	require.NoError(t, err)
+	txn, err = ctrl.Declare(ctx)
+	require.NoError(t, err)
+	require.NoError(t, txn.Send(ctx, snd, amqp.NewMessage([]byte("out"))))
+	require.NoError(t, txn.Accept(ctx, msg))
+	require.Zero(t, out.Len())
+	require.NoError(t, txn.Commit(ctx))
+	require.Equal(t, 1, out.Len())
+	require.Zero(t, in.Len())
+
+	// a discharged transaction is unknown
+	var amqpErr *amqp.Error
+	require.ErrorAs(t, txn.Commit(ctx), &amqpErr)
+	require.Equal(t, amqp.ErrCondTransactionUnknownID, amqpErr.Condition)
+}
@@ -0,0 +1,363 @@
+package amqp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// WAL is a write-ahead log persisting the messages published by a Producer
+// until they complete. See ProducerOptions.WAL.
+//
+// Implementations must be safe for concurrent use.
+type WAL interface {
+	// Append persists data, and returns the sequence number identifying it.
+	// Sequence numbers are greater than zero, and increase with each call.
+	Append(data []byte) (uint64, error)
+
+	// Remove removes the entry identified by seq.
+	Remove(seq uint64) error
+
+	// Entries returns the entries not removed, in the order they were appended.
+	Entries() ([]WALEntry, error)
+}
+
+// WALEntry is an entry of a WAL.
+type WALEntry struct {
+	// Seq is the sequence number returned by WAL.Append.
+	Seq uint64
+
+	// Data is the data passed to WAL.Append.
+	Data []byte
+}
+
+// FileWALOptions contains the optional settings for NewFileWAL.
+type FileWALOptions struct {
+	// NoSync disables syncing the file to stable storage after each write.
+	// Entries can then be lost when the system crashes, but not when only
+	// the process does.
+	//
+	// Default: false.
+	NoSync bool
+
+	// CompactAfter is the number of entries removed after which the file is
+	// rewritten with only the entries not removed. The file is also
+	// truncated whenever all its entries are removed.
+	//
+	// Default: 1024.
+	CompactAfter int
+}
+
+// FileWAL is a WAL appending its entries to a file. Entries are checksummed,
+// and an entry partially written when the process stopped is discarded when
+// the file is opened again.
+//
+// A failed write is truncated from the file. If that fails too, the
+// FileWAL returns the error from every later Append and Remove.
+type FileWAL struct {
+	path         string
+	noSync       bool
+	compactAfter int
+
+	mu      sync.Mutex
+	f       *os.File          // nil once closed
+	size    int64             // size of the records fully written to f
+	failed  error             // set once f couldn't be restored after a failed write
+	entries map[uint64][]byte // entries not removed
+	next    uint64            // sequence number of the next entry
+	removed int               // entries removed since the file was last compacted
+	buf     []byte
+}
+
+// walMagic starts the file of a FileWAL.
+const walMagic = "AMQPWAL\x01"
+
+// record kinds of a FileWAL
+const (
+	walAppend byte = 1
+	walRemove byte = 2
+)
+
+// walHeaderSize is the size of a record header: checksum, kind, sequence
+// number, and data size.
+const walHeaderSize = 4 + 1 + 8 + 4
+
+// NewFileWAL opens the WAL at path, creating it if it doesn't exist.
+//
+// opts: pass nil to accept the default values.
+func NewFileWAL(path string, opts *FileWALOptions) (*FileWAL, error) {
+	w := &FileWAL{
+		path:         path,
+		compactAfter: 1024,
+		entries:      map[uint64][]byte{},
+		next:         1,
+	}
+	if opts != nil {
+		w.noSync = opts.NoSync
+		if opts.CompactAfter < 0 {
+			return nil, fmt.Errorf("invalid CompactAfter value %d", opts.CompactAfter)
+		} else if opts.CompactAfter > 0 {
+			w.compactAfter = opts.CompactAfter
+		}
+	}
+
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+	// drops the removed entries, and any partially written one
+	if err := w.compact(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// load reads the entries of the existing file, if any.
+func (w *FileWAL) load() error {
+	f, err := os.Open(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(walMagic))
+	if _, err := io.ReadFull(r, magic); errors.Is(err, io.EOF) {
+		// empty file
+		return nil
+	} else if err != nil || string(magic) != walMagic {
+		return fmt.Errorf("amqp: %s isn't a WAL file", w.path)
+	}
+
+	header := make([]byte, walHeaderSize)
+	remaining := fi.Size() - int64(len(walMagic))
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			// end of file, or a partially written record
+			return nil
+		}
+		remaining -= walHeaderSize
+		// the length isn't verified until the CRC is, so a corrupted one
+		// mustn't be trusted to size the buffer
+		size := int64(binary.BigEndian.Uint32(header[13:17]))
+		if size > remaining {
+			return nil
+		}
+		remaining -= size
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil
+		}
+		crc := crc32.NewIEEE()
+		_, _ = crc.Write(header[4:])
+		_, _ = crc.Write(data)
+		if crc.Sum32() != binary.BigEndian.Uint32(header[0:4]) {
+			return nil
+		}
+
+		seq := binary.BigEndian.Uint64(header[5:13])
+		switch header[4] {
+		case walAppend:
+			w.entries[seq] = data
+			if seq >= w.next {
+				w.next = seq + 1
+			}
+		case walRemove:
+			delete(w.entries, seq)
+		default:
+			return fmt.Errorf("amqp: invalid WAL record kind %d in %s", header[4], w.path)
+		}
+	}
+}
+
+// Append implements the WAL interface for FileWAL.
+func (w *FileWAL) Append(data []byte) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return 0, errWALClosed
+	} else if w.failed != nil {
+		return 0, w.failed
+	}
+
+	seq := w.next
+	if err := w.write(walAppend, seq, data); err != nil {
+		return 0, err
+	}
+	w.next++
+	w.entries[seq] = append([]byte(nil), data...)
+	return seq, nil
+}
+
+// Remove implements the WAL interface for FileWAL.
+func (w *FileWAL) Remove(seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return errWALClosed
+	} else if w.failed != nil {
+		return w.failed
+	}
+	if _, ok := w.entries[seq]; !ok {
+		return nil
+	}
+
+	delete(w.entries, seq)
+	w.removed++
+	if len(w.entries) == 0 || w.removed >= w.compactAfter {
+		return w.compact()
+	}
+	return w.write(walRemove, seq, nil)
+}
+
+// Entries implements the WAL interface for FileWAL.
+func (w *FileWAL) Entries() ([]WALEntry, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil, errWALClosed
+	}
+	return w.sorted(), nil
+}
+
+// Close closes the file. The entries not removed are kept, to be returned
+// by Entries once it's opened again.
+func (w *FileWAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// sorted returns the entries not removed in sequence order.
+func (w *FileWAL) sorted() []WALEntry {
+	entries := make([]WALEntry, 0, len(w.entries))
+	for seq, data := range w.entries {
+		entries = append(entries, WALEntry{Seq: seq, Data: data})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries
+}
+
+// write appends a record to the file. If it fails, the file is truncated
+// to drop any part of the record written, as the records following it
+// would be discarded along with it when the file is loaded.
+func (w *FileWAL) write(kind byte, seq uint64, data []byte) error {
+	w.buf = appendWALRecord(w.buf[:0], kind, seq, data)
+	_, err := w.f.Write(w.buf)
+	if err == nil && !w.noSync {
+		err = w.f.Sync()
+	}
+	if err != nil {
+		w.rollback()
+		return err
+	}
+	w.size += int64(len(w.buf))
+	return nil
+}
+
+// rollback truncates the file to the records fully written. If that fails,
+// the WAL refuses further changes.
+func (w *FileWAL) rollback() {
+	err := w.f.Truncate(w.size)
+	if err == nil {
+		_, err = w.f.Seek(w.size, io.SeekStart)
+	}
+	if err != nil {
+		w.failed = fmt.Errorf("amqp: WAL %s failed: %w", w.path, err)
+	}
+}
+
+// compact rewrites the file with the entries not removed, replacing it
+// once the new file is written.
+func (w *FileWAL) compact() error {
+	tmp := w.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	buf := append([]byte(nil), walMagic...)
+	for _, entry := range w.sorted() {
+		buf = appendWALRecord(buf, walAppend, entry.Seq, entry.Data)
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if !w.noSync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		f.Close()
+		return err
+	}
+	if !w.noSync {
+		// makes the rename durable
+		if err := syncDir(filepath.Dir(w.path)); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if w.f != nil {
+		_ = w.f.Close()
+	}
+	w.f = f
+	w.size = int64(len(buf))
+	w.failed = nil
+	w.removed = 0
+	return nil
+}
+
+// syncDir syncs the directory at path to stable storage.
+func syncDir(path string) error {
+	if runtime.GOOS == "windows" {
+		// directories can't be synced, renames are durable once done
+		return nil
+	}
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// appendWALRecord appends the record for data to buf.
+func appendWALRecord(buf []byte, kind byte, seq uint64, data []byte) []byte {
+	var header [walHeaderSize]byte
+	header[4] = kind
+	binary.BigEndian.PutUint64(header[5:13], seq)
+	binary.BigEndian.PutUint32(header[13:17], uint32(len(data)))
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(header[4:])
+	_, _ = crc.Write(data)
+	binary.BigEndian.PutUint32(header[0:4], crc.Sum32())
+	return append(append(buf, header[:]...), data...)
+}
+
+// errWALClosed is returned by the methods of a FileWAL after Close.
+var errWALClosed = errors.New("amqp: WAL has been closed")
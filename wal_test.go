@@ -0,0 +1,140 @@
+package amqp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "producer.wal")
+	w, err := NewFileWAL(path, &FileWALOptions{NoSync: true})
+	require.NoError(t, err)
+	for i, data := range []string{"first", "second", "third"} {
+		seq, err := w.Append([]byte(data))
+		require.NoError(t, err)
+		require.EqualValues(t, i+1, seq)
+	}
+	require.NoError(t, w.Remove(2))
+	require.NoError(t, w.Remove(2))
+	require.NoError(t, w.Close())
+	_, err = w.Append([]byte("closed"))
+	require.Error(t, err)
+
+	// a partially written record is discarded
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write(appendWALRecord(nil, walAppend, 4, []byte("fourth"))[:10])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	w, err = NewFileWAL(path, nil)
+	require.NoError(t, err)
+	entries, err := w.Entries()
+	require.NoError(t, err)
+	require.Equal(t, []WALEntry{{Seq: 1, Data: []byte("first")}, {Seq: 3, Data: []byte("third")}}, entries)
+	seq, err := w.Append([]byte("fourth"))
+	require.NoError(t, err)
+	require.EqualValues(t, 4, seq)
+
+	// the file is truncated once all the entries are removed
+	for _, seq := range []uint64{1, 3, 4} {
+		require.NoError(t, w.Remove(seq))
+	}
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.EqualValues(t, len(walMagic), fi.Size())
+	require.NoError(t, w.Close())
+
+	require.NoError(t, os.WriteFile(path, []byte("not a WAL"), 0o600))
+	_, err = NewFileWAL(path, nil)
+	require.Error(t, err)
+	_, err = NewFileWAL(path, &FileWALOptions{CompactAfter: -1})
+	require.Error(t, err)
+}
+
+func TestFileWALCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "producer.wal")
+	w, err := NewFileWAL(path, &FileWALOptions{NoSync: true, CompactAfter: 2})
+	require.NoError(t, err)
+	defer w.Close()
+	for i := 0; i < 4; i++ {
+		_, err := w.Append([]byte("data"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Remove(1))
+	require.NoError(t, w.Remove(2))
+
+	// the file only holds the remaining entries
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.EqualValues(t, len(walMagic)+2*(walHeaderSize+len("data")), fi.Size())
+	seq, err := w.Append([]byte("data"))
+	require.NoError(t, err)
+	require.EqualValues(t, 5, seq)
+}
+
+func TestFileWALCorruptedLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "producer.wal")
+	w, err := NewFileWAL(path, nil)
+	require.NoError(t, err)
+	_, err = w.Append([]byte("first"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// a record whose length exceeds what's left of the file is discarded
+	// without allocating a buffer for it
+	record := appendWALRecord(nil, walAppend, 2, []byte("second"))
+	record[13], record[14], record[15], record[16] = 0xff, 0xff, 0xff, 0xff
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write(record)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	w, err = NewFileWAL(path, nil)
+	require.NoError(t, err)
+	defer w.Close()
+	entries, err := w.Entries()
+	require.NoError(t, err)
+	require.Equal(t, []WALEntry{{Seq: 1, Data: []byte("first")}}, entries)
+}
+
+func TestFileWALFailedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "producer.wal")
+	w, err := NewFileWAL(path, &FileWALOptions{NoSync: true})
+	require.NoError(t, err)
+	_, err = w.Append([]byte("first"))
+	require.NoError(t, err)
+
+	// a partially written record is truncated, so the records following
+	// it aren't discarded when the file is loaded
+	_, err = w.f.Write(appendWALRecord(nil, walAppend, 2, []byte("partial"))[:10])
+	require.NoError(t, err)
+	w.rollback()
+	require.NoError(t, w.failed)
+	_, err = w.Append([]byte("second"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	w, err = NewFileWAL(path, &FileWALOptions{NoSync: true})
+	require.NoError(t, err)
+	entries, err := w.Entries()
+	require.NoError(t, err)
+	require.Equal(t, []WALEntry{{Seq: 1, Data: []byte("first")}, {Seq: 2, Data: []byte("second")}}, entries)
+
+	// the WAL fails if the file can't be truncated
+	f := w.f
+	w.f, err = os.Open(path)
+	require.NoError(t, err)
+	_, err = w.Append([]byte("third"))
+	require.Error(t, err)
+	require.Error(t, w.failed)
+	_, err = w.Append([]byte("fourth"))
+	require.Equal(t, w.failed, err)
+	require.Error(t, w.Remove(1))
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+}
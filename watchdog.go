@@ -0,0 +1,184 @@
+package amqp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/go-amqp/internal/clock"
+)
+
+// defaultStallThreshold is the default WatchdogOptions.Threshold.
+const defaultStallThreshold = 30 * time.Second
+
+// WatchdogOptions configures the watchdog detecting the connection's
+// internal goroutines no longer making progress. See ConnOptions.Watchdog.
+type WatchdogOptions struct {
+	// Threshold is how long the connection's reader or writer, or a session,
+	// can spend on a single unit of work, such as writing frames to the
+	// network or handing a received frame to a link, before it's considered
+	// stalled. Goroutines waiting for work are never considered stalled.
+	//
+	// Default: 30 seconds.
+	Threshold time.Duration
+
+	// Teardown closes the connection when a stall is detected. The *StallError
+	// is returned, wrapped in a *ConnError, by pending and future operations.
+	//
+	// Default: false.
+	Teardown bool
+
+	// OnStall is called from the watchdog's goroutine for every stall
+	// detected. Stalls are also logged to ConnOptions.Logger.
+	//
+	// Default: nil.
+	OnStall func(*StallError)
+}
+
+// StallError describes an internal goroutine of a connection that stopped
+// making progress, as detected by the watchdog enabled with ConnOptions.Watchdog.
+type StallError struct {
+	// Goroutine is the stalled goroutine: "conn reader", "conn writer", or "session".
+	Goroutine string
+
+	// Channel is the channel of the stalled session.
+	// It's only set when Goroutine is "session".
+	Channel uint16
+
+	// Duration is how long the goroutine had been working on its
+	// current unit of work when the stall was detected.
+	Duration time.Duration
+
+	// Dump is the output of Conn.DebugDump when the stall was detected.
+	Dump string
+}
+
+// Error implements the error interface for StallError.
+func (e *StallError) Error() string {
+	if e.Goroutine == "session" {
+		return fmt.Sprintf("amqp: session on channel %d made no progress for %s", e.Channel, e.Duration)
+	}
+	return fmt.Sprintf("amqp: %s made no progress for %s", e.Goroutine, e.Duration)
+}
+
+// activity records when a goroutine began its current unit of work, for the
+// watchdog to detect it being stuck. A nil *activity records nothing.
+type activity struct {
+	clock clock.Clock
+	since int64 // Unix nanoseconds, zero while waiting for work
+}
+
+// newActivity returns an activity for a goroutine of c, or nil if c's
+// watchdog is disabled.
+func newActivity(c *Conn) *activity {
+	if c == nil || c.watchdog == nil {
+		return nil
+	}
+	return &activity{clock: c.clock}
+}
+
+// begin records that the goroutine started a unit of work.
+func (a *activity) begin() {
+	if a != nil {
+		atomic.StoreInt64(&a.since, a.clock.Now().UnixNano())
+	}
+}
+
+// end records that the goroutine is waiting for work.
+func (a *activity) end() {
+	if a != nil {
+		atomic.StoreInt64(&a.since, 0)
+	}
+}
+
+// busy returns when the current unit of work began, and false if the
+// goroutine is waiting for work.
+func (a *activity) busy() (int64, bool) {
+	since := atomic.LoadInt64(&a.since)
+	return since, since != 0
+}
+
+// runWatchdog checks that the connection's goroutines make progress until
+// the connection is closed. It's only started when the watchdog is enabled.
+func (c *Conn) runWatchdog() {
+	threshold := c.watchdog.Threshold
+	if threshold <= 0 {
+		threshold = defaultStallThreshold
+	}
+
+	// a goroutine is reported once per unit of work, identified by its start time
+	reported := map[*activity]int64{}
+
+	// checking twice per threshold bounds detection to 1.5 thresholds
+	timer := c.clock.NewTimer(threshold / 2)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C():
+		case <-c.done:
+			return
+		}
+
+		now := c.clock.Now()
+		for _, w := range c.watchedActivities() {
+			since, ok := w.activity.busy()
+			if !ok || reported[w.activity] == since || now.Sub(time.Unix(0, since)) < threshold {
+				continue
+			}
+			reported[w.activity] = since
+
+			stallErr := &StallError{
+				Goroutine: w.goroutine,
+				Channel:   w.channel,
+				Duration:  now.Sub(time.Unix(0, since)),
+			}
+			var dump strings.Builder
+			_ = c.DebugDump(&dump)
+			stallErr.Dump = dump.String()
+
+			c.logger.Error("connection goroutine stalled", "hostname", c.hostname, "error", stallErr, "dump", stallErr.Dump)
+			if c.watchdog.OnStall != nil {
+				c.watchdog.OnStall(stallErr)
+			}
+			if c.watchdog.Teardown {
+				c.teardown(stallErr)
+				return
+			}
+		}
+		timer.Reset(threshold / 2)
+	}
+}
+
+// watchedActivity is an activity with the goroutine it belongs to.
+type watchedActivity struct {
+	activity  *activity
+	goroutine string
+	channel   uint16
+}
+
+// watchedActivities returns the activities of the connection's goroutines.
+func (c *Conn) watchedActivities() []watchedActivity {
+	watched := []watchedActivity{
+		{activity: c.readerActivity, goroutine: "conn reader"},
+		{activity: c.writerActivity, goroutine: "conn writer"},
+	}
+	c.sessionsByChannelMu.RLock()
+	for _, s := range c.sessionsByChannel {
+		watched = append(watched, watchedActivity{activity: s.activity, goroutine: "session", channel: s.channel})
+	}
+	c.sessionsByChannelMu.RUnlock()
+	sort.Slice(watched[2:], func(i, j int) bool { return watched[2+i].channel < watched[2+j].channel })
+	return watched
+}
+
+// teardown closes the connection because of err. The network connection is
+// closed first to unblock the reader and writer if they're stuck on it.
+func (c *Conn) teardown(err error) {
+	c.closeErrMu.Lock()
+	c.teardownErr = err
+	c.closeErrMu.Unlock()
+	_ = c.net.Close()
+	c.close()
+}
@@ -0,0 +1,99 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+// stallingConn blocks writes once stall is set, until it's closed.
+type stallingConn struct {
+	*mocks.NetConn
+	stall     int32
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *stallingConn) Write(b []byte) (int, error) {
+	if atomic.LoadInt32(&c.stall) == 1 {
+		<-c.closed
+		return 0, errors.New("closed")
+	}
+	return c.NetConn.Write(b)
+}
+
+func (c *stallingConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.NetConn.Close()
+}
+
+func TestWatchdogTeardown(t *testing.T) {
+	netConn := &stallingConn{
+		NetConn: mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)),
+		closed:  make(chan struct{}),
+	}
+	stalls := make(chan *StallError, 1)
+	client, err := NewConn(netConn, &ConnOptions{
+		Watchdog: &WatchdogOptions{
+			Threshold: 50 * time.Millisecond,
+			Teardown:  true,
+			OnStall:   func(err *StallError) { stalls <- err },
+		},
+	})
+	require.NoError(t, err)
+
+	// the begin frame can't be written
+	atomic.StoreInt32(&netConn.stall, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err = client.NewSession(ctx, nil)
+	cancel()
+	var stallErr *StallError
+	require.ErrorAs(t, err, &stallErr)
+	require.Equal(t, "conn writer", stallErr.Goroutine)
+	require.GreaterOrEqual(t, stallErr.Duration, 50*time.Millisecond)
+	require.Contains(t, stallErr.Dump, "conn containerID=")
+
+	select {
+	case reported := <-stalls:
+		require.Same(t, stallErr, reported)
+	default:
+		t.Fatal("OnStall wasn't called")
+	}
+	require.ErrorAs(t, client.Close(), &stallErr)
+}
+
+func TestWatchdogIdle(t *testing.T) {
+	netConn := mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled))
+	client, err := NewConn(netConn, &ConnOptions{
+		Watchdog: &WatchdogOptions{
+			Threshold: 10 * time.Millisecond,
+			OnStall:   func(err *StallError) { t.Errorf("unexpected stall: %v", err) },
+		},
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	session, err := client.NewSession(ctx, nil)
+	cancel()
+	require.NoError(t, err)
+
+	// goroutines waiting for work aren't stalled
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	require.NoError(t, session.Close(ctx))
+	cancel()
+	require.NoError(t, client.Close())
+}
+
+func TestWatchdogInvalidThreshold(t *testing.T) {
+	_, err := NewConn(mocks.NewNetConn(senderFrameHandlerNoUnhandled(SenderSettleModeUnsettled)), &ConnOptions{
+		Watchdog: &WatchdogOptions{Threshold: -1},
+	})
+	require.ErrorContains(t, err, "invalid Watchdog.Threshold")
+}